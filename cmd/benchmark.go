@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/shop"
+)
+
+// benchmarkCommand produces pre-generated records at the max sustainable rate
+// and reports the throughput, so that the shop doubles as a load generator.
+func benchmarkCommand(args []string) error {
+	flags, configFilepath := newFlagSet("benchmark")
+	duration := flags.Duration("duration", time.Minute, "Duration of the benchmark")
+	producers := flags.Int("producers", 4, "Number of producer goroutines, each with a Kafka client of its own")
+	poolSize := flags.Int("pool-size", 10000, "Number of pre-generated payloads")
+	reportInterval := flags.Duration("report-interval", 5*time.Second, "Interval in which the throughput is logged")
+	_ = flags.Parse(args)
+
+	cfg, logger, err := loadConfig(*configFilepath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := shop.Benchmark(ctx, cfg, logger, shop.BenchmarkOptions{
+		Duration:       *duration,
+		Producers:      *producers,
+		PoolSize:       *poolSize,
+		ReportInterval: *reportInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d records (%.2f records/s), %.2f MB/s, %d errors in %v\n",
+		result.Records, result.RecordsPerSecond(), result.MegabytesPerSecond(), result.Errors, result.Elapsed.Round(time.Millisecond))
+
+	return nil
+}
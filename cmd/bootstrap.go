@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/shop"
+)
+
+// bootstrapCommand creates a SASL user with least privilege ACLs for the shop,
+// so that secured clusters can be set up in one command. It must be run with
+// admin credentials. The password is read from the OWLSHOP_BOOTSTRAP_PASSWORD
+// environment variable or from stdin, so that it doesn't show up in the
+// process list or the shell history.
+func bootstrapCommand(args []string) error {
+	flags, configFilepath := newFlagSet("bootstrap")
+	timeout := flags.Duration("timeout", time.Minute, "Max duration of the bootstrap")
+	username := flags.String("username", "owlshop", "Name of the SCRAM user that is created")
+	passwordStdin := flags.Bool("password-stdin", false, "Read the password of the SCRAM user that is created from the first line of stdin rather than the OWLSHOP_BOOTSTRAP_PASSWORD environment variable")
+	mechanism := flags.String("mechanism", config.SASLMechanismScramSHA512, "SCRAM mechanism of the user, SCRAM-SHA-256 or SCRAM-SHA-512")
+	iterations := flags.Int("iterations", 8192, "SCRAM iterations of the user's credentials")
+	dryRun := flags.Bool("dry-run", false, "Only log the user and ACLs that would be created")
+	_ = flags.Parse(args)
+
+	password, err := bootstrapPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, logger, err := loadConfig(*configFilepath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	return shop.Bootstrap(ctx, cfg, logger, shop.BootstrapOptions{
+		Username:   *username,
+		Password:   password,
+		Mechanism:  *mechanism,
+		Iterations: int32(*iterations),
+		DryRun:     *dryRun,
+	})
+}
+
+// bootstrapPassword returns the password of the created user from the first
+// line of stdin or from the OWLSHOP_BOOTSTRAP_PASSWORD environment variable.
+func bootstrapPassword(fromStdin bool) (string, error) {
+	if !fromStdin {
+		return os.Getenv("OWLSHOP_BOOTSTRAP_PASSWORD"), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
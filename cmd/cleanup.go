@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/shop"
+)
+
+// cleanupCommand deletes all resources that have been created by the shop, so
+// that demo environments can be reset. The shop must be stopped beforehand.
+func cleanupCommand(args []string) error {
+	flags, configFilepath := newFlagSet("cleanup")
+	timeout := flags.Duration("timeout", time.Minute, "Max duration of the cleanup")
+	dryRun := flags.Bool("dry-run", false, "Only log the topics, consumer groups and subjects that would be deleted")
+	_ = flags.Parse(args)
+
+	cfg, logger, err := loadConfig(*configFilepath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	return shop.Cleanup(ctx, cfg, logger, *dryRun)
+}
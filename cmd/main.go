@@ -1,6 +1,12 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/cloudhut/common/logging"
 	"go.uber.org/zap"
 
@@ -8,21 +14,87 @@ import (
 	"github.com/cloudhut/owl-shop/pkg/shop"
 )
 
+// command is a subcommand of the binary. It receives the arguments after the
+// command name.
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+// defaultCommand is run if the binary is started without a command, so that
+// existing deployments keep simulating traffic.
+const defaultCommand = "run"
+
+var commands = []command{
+	{name: "run", description: "Simulates traffic until the shop is stopped (default)", run: runCommand},
+	{name: "seed", description: "Simulates a fixed number of page impressions, flushes all records and exits", run: seedCommand},
+	{name: "validate-config", description: "Parses and validates the config without connecting to any cluster", run: validateConfigCommand},
+	{name: "benchmark", description: "Produces pre-generated records at the max sustainable rate and reports the throughput", run: benchmarkCommand},
+	{name: "replay", description: "Produces the records of files that have been recorded by the file sink with their recorded timing", run: replayCommand},
+	{name: "cleanup", description: "Deletes all topics, consumer groups and schema registry subjects that start with their prefix", run: cleanupCommand},
+	{name: "bootstrap", description: "Creates a SASL user with least privilege ACLs for the topics, groups and transactional IDs of the shop", run: bootstrapCommand},
+}
+
 func main() {
-	startupLogger := zap.NewExample()
-	cfg, err := config.LoadConfig(startupLogger)
-	if err != nil {
-		startupLogger.Fatal("failed to parse config", zap.Error(err))
+	name, args := defaultCommand, os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	logger := logging.NewLogger(&cfg.Logger, "owl_shop")
+	if name == "help" {
+		printUsage()
+		return
+	}
 
-	shopSvc, err := shop.New(cfg, logger)
-	if err != nil {
-		logger.Fatal("failed to initialize shop", zap.Error(err))
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil {
+			fmt.Fprintf(os.Stderr, "%v failed: %v\n", cmd.name, err)
+			// Integrity violations have an exit code of their own, so that
+			// failover tests can tell them apart from other failures
+			if errors.Is(err, shop.ErrIntegrityViolations) {
+				os.Exit(3)
+			}
+			os.Exit(1)
+		}
+		return
 	}
-	err = shopSvc.Start()
+
+	fmt.Fprintf(os.Stderr, "unknown command '%v'\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: owlshop [command] [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16v %v\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'owlshop <command> -h' for the flags of a command.\n")
+}
+
+// newFlagSet returns the flags of the given command, which include the config
+// filepath.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	configFilepath := flags.String("config.filepath", "", "Path to the YAML config file, defaults to the CONFIG_FILEPATH env variable")
+	return flags, configFilepath
+}
+
+// loadConfig loads and validates the config and creates the logger that is
+// configured by it.
+func loadConfig(configFilepath string) (config.Config, *zap.Logger, error) {
+	startupLogger := zap.NewExample()
+	cfg, err := config.LoadConfig(startupLogger, configFilepath)
 	if err != nil {
-		logger.Fatal("failed to start shop", zap.Error(err))
+		return config.Config{}, nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return config.Config{}, nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+
+	return cfg, logging.NewLogger(&cfg.Logger, "owl_shop"), nil
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/shop"
+)
+
+// replayCommand produces the records of files that have been recorded by the
+// file sink, so that demos can be reproduced exactly.
+func replayCommand(args []string) error {
+	flags, configFilepath := newFlagSet("replay")
+	speed := flags.Float64("speed", 1, "Factor by which the recorded timing is accelerated, 0 produces all records as fast as possible")
+	keepTimestamps := flags.Bool("keep-timestamps", false, "Produce the records with their recorded timestamps rather than the replay time")
+	flushTimeout := flags.Duration("flush-timeout", 10*time.Second, "Max duration to flush the buffered records once all records have been produced or the replay has been stopped")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: owlshop replay [flags] <file or directory>...\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	cfg, logger, err := loadConfig(*configFilepath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := shop.Replay(ctx, cfg, logger, shop.ReplayOptions{
+		Paths:          flags.Args(),
+		Speed:          *speed,
+		KeepTimestamps: *keepTimestamps,
+		FlushTimeout:   *flushTimeout,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d records, %d errors in %v\n", result.Records, result.Errors, result.Elapsed.Round(time.Millisecond))
+
+	return nil
+}
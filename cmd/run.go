@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/shop"
+)
+
+// defaultSeedEvents is the number of page impressions that are simulated by
+// the seed command, unless configured otherwise.
+const defaultSeedEvents = 1000
+
+func runCommand(args []string) error {
+	flags, configFilepath := newFlagSet("run")
+	_ = flags.Parse(args)
+
+	cfg, logger, err := loadConfig(*configFilepath)
+	if err != nil {
+		return err
+	}
+
+	return runShop(cfg, logger)
+}
+
+// seedCommand simulates a fixed number of page impressions, so that a cluster
+// can be populated once, e.g. in CI or as a Kubernetes Job. The number of page
+// impressions defaults to shop.maxEvents.
+func seedCommand(args []string) error {
+	flags, configFilepath := newFlagSet("seed")
+	events := flags.Int("events", 0, fmt.Sprintf("Number of page impressions to simulate, defaults to shop.maxEvents or %d", defaultSeedEvents))
+	_ = flags.Parse(args)
+
+	cfg, logger, err := loadConfig(*configFilepath)
+	if err != nil {
+		return err
+	}
+
+	// Seeding is limited to a single profile by the config validation
+	shopCfg := &cfg.Shop
+	if len(cfg.Profiles) > 0 {
+		shopCfg = &cfg.Profiles[0].Shop
+	}
+	switch {
+	case *events > 0:
+		shopCfg.MaxEvents = *events
+	case shopCfg.MaxEvents == 0:
+		shopCfg.MaxEvents = defaultSeedEvents
+	}
+	shopCfg.RunDuration = 0
+	logger.Info("seeding cluster", zap.Int("max_events", shopCfg.MaxEvents))
+
+	return runShop(cfg, logger)
+}
+
+// runShop simulates the traffic of all shop profiles until a shutdown signal
+// is received or the configured max events or run duration have been reached.
+// Afterwards the shops are stopped gracefully.
+func runShop(cfg config.Config, logger *zap.Logger) error {
+	runner, err := shop.NewRunner(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize shop: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	startErrCh := make(chan error, 1)
+	go func() {
+		startErrCh <- runner.Start()
+	}()
+
+	var startErr error
+	select {
+	case <-ctx.Done():
+		logger.Info("received shutdown signal")
+	case startErr = <-startErrCh:
+		if startErr == nil {
+			logger.Info("traffic simulation completed")
+		}
+	}
+
+	// A second signal terminates the process immediately
+	stop()
+
+	// The runner is stopped even if a shop has failed, so that the other
+	// shops flush their records and the listeners are closed
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stopErr := runner.Stop(shutdownCtx)
+	if startErr != nil {
+		if stopErr != nil {
+			logger.Warn("failed to gracefully stop shop after it has failed", zap.Error(stopErr))
+		}
+		return fmt.Errorf("failed to start shop: %w", startErr)
+	}
+	if stopErr != nil {
+		return fmt.Errorf("failed to gracefully stop shop: %w", stopErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+)
+
+// validateConfigCommand parses and validates the config without connecting to
+// any cluster, so that config changes can be checked before they are
+// deployed.
+func validateConfigCommand(args []string) error {
+	flags, configFilepath := newFlagSet("validate-config")
+	_ = flags.Parse(args)
+
+	if _, _, err := loadConfig(*configFilepath); err != nil {
+		return err
+	}
+	fmt.Println("config is valid")
+
+	return nil
+}
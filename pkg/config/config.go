@@ -21,12 +21,20 @@ type Config struct {
 	Kafka          Kafka          `yaml:"kafka"`
 	SchemaRegistry SchemaRegistry `yaml:"schemaRegistry"`
 	Shop           Shop           `yaml:"shop"`
+	Metrics        Metrics        `yaml:"metrics"`
+	Tracing        Tracing        `yaml:"tracing"`
+
+	// Profiles are the shops that run concurrently in this process. If none
+	// are configured, a single shop is run with the shop config.
+	Profiles []Profile `yaml:"profiles"`
 }
 
 func (c *Config) SetDefaults() {
 	c.Logger.SetDefaults()
 	c.Kafka.SetDefaults()
 	c.Shop.SetDefaults()
+	c.Metrics.SetDefaults()
+	c.Tracing.SetDefaults()
 }
 
 func (c *Config) Validate() error {
@@ -38,22 +46,192 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("failed to validate Kafka config: %w", err)
 	}
 
+	if err := c.SchemaRegistry.Validate(); err != nil {
+		return fmt.Errorf("failed to validate schema registry config: %w", err)
+	}
+
+	if err := c.Shop.Validate(); err != nil {
+		return fmt.Errorf("failed to validate shop config: %w", err)
+	}
+
+	if err := c.validateProfiles(); err != nil {
+		return err
+	}
+
+	if err := c.Metrics.Validate(); err != nil {
+		return fmt.Errorf("failed to validate metrics config: %w", err)
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		return fmt.Errorf("failed to validate tracing config: %w", err)
+	}
+
+	admin := c.Shop.AdminAPI
+	if admin.Enabled && admin.ListenAddress == "" && !c.Metrics.Enabled {
+		return fmt.Errorf("admin api requires a listen address of its own if the metrics listener is disabled")
+	}
+
+	return nil
+}
+
+// validateHTTPProducers rejects the options that rely on record headers,
+// timestamps or partitioners of the Kafka client if any service of the shop
+// produces via the HTTP proxy, which only passes the key, value and partition
+// of the records.
+func (c *Config) validateHTTPProducers(shop Shop) error {
+	http := false
+	for _, svc := range shop.Services.ByName() {
+		if c.Kafka.Producer.WithOverrides(svc.Producer).Protocol == ProducerProtocolHTTP {
+			http = true
+		}
+	}
+	if !http {
+		return nil
+	}
+
+	switch {
+	case shop.Headers.Enabled:
+		return fmt.Errorf("the http producer protocol does not support record headers, which requires headers to be disabled")
+	case shop.CloudEvents.Enabled && shop.CloudEvents.Mode == CloudEventsModeBinary:
+		return fmt.Errorf("the http producer protocol does not support record headers, which requires the structured mode of cloud events")
+	case shop.Integrity.Enabled:
+		return fmt.Errorf("the http producer protocol does not support record headers, which can't be combined with integrity sequence numbers")
+	case shop.LateRecords.Enabled:
+		return fmt.Errorf("the http producer protocol does not support record timestamps, which can't be combined with late records")
+	}
+	for name, topic := range shop.Topics {
+		switch topic.Partitioner {
+		case "", PartitionerDefault, PartitionerManual:
+		default:
+			return fmt.Errorf("the http producer protocol only supports the default and manual partitioners, but topic '%v' uses the '%v' partitioner", name, topic.Partitioner)
+		}
+	}
+
+	return nil
+}
+
+// validateProfiles validates the shop config of all profiles against the
+// Kafka clusters and makes sure that their resources don't collide.
+func (c *Config) validateProfiles() error {
+	profiles := c.Shops()
+	names := make(map[string]bool, len(profiles))
+	topicPrefixes := make(map[string]string, len(profiles))
+	groupPrefixes := make(map[string]string, len(profiles))
+
+	for _, profile := range profiles {
+		// The unnamed profile of the shop config has been validated already
+		if profile.Name != "" {
+			if err := profile.Validate(); err != nil {
+				return fmt.Errorf("failed to validate profile '%v': %w", profile.Name, err)
+			}
+			if names[profile.Name] {
+				return fmt.Errorf("profile name '%v' is not unique", profile.Name)
+			}
+			names[profile.Name] = true
+		}
+
+		shop := profile.Shop
+		for name, svc := range shop.Services.ByName() {
+			if _, err := c.Kafka.Cluster(svc.Cluster); err != nil {
+				return fmt.Errorf("failed to validate cluster of %v service: %w", name, err)
+			}
+		}
+		if _, err := c.Kafka.Cluster(shop.Verifier.Cluster); err != nil {
+			return fmt.Errorf("failed to validate cluster of verifier: %w", err)
+		}
+		if _, err := c.Kafka.Cluster(shop.ManyTopics.Cluster); err != nil {
+			return fmt.Errorf("failed to validate cluster of many topics: %w", err)
+		}
+		for name, generator := range shop.Generators {
+			if _, err := c.Kafka.Cluster(generator.Cluster); err != nil {
+				return fmt.Errorf("failed to validate cluster of %v generator: %w", name, err)
+			}
+		}
+
+		// Transactions can not span multiple clusters
+		services := shop.Services
+		if shop.Transactions.Enabled && services.Order.Cluster != services.ProductCatalog.Cluster {
+			return fmt.Errorf("transactional mode requires the order and product catalog services to use the same cluster")
+		}
+		orderProducer := c.Kafka.Producer.WithOverrides(services.Order.Producer)
+		if shop.Transactions.Enabled && (!orderProducer.Idempotent() || shop.Duplicates.Enabled) {
+			return fmt.Errorf("transactional mode requires an idempotent producer and can't be combined with duplicates")
+		}
+		paymentProducer := c.Kafka.Producer.WithOverrides(services.Payment.Producer)
+		if shop.ExactlyOnce.Enabled && (!paymentProducer.Idempotent() || shop.Duplicates.Enabled) {
+			return fmt.Errorf("exactly-once mode requires an idempotent producer and can't be combined with duplicates")
+		}
+		// The transactional session produces the payments itself, bypassing
+		// the producer wrappers of the payment service
+		if shop.ExactlyOnce.Enabled {
+			paymentCluster, _ := c.Kafka.Cluster(services.Payment.Cluster)
+			switch {
+			case paymentProducer.Protocol == ProducerProtocolHTTP:
+				return fmt.Errorf("exactly-once mode can't be combined with the http producer protocol of the payment service")
+			case paymentProducer.Failures.Enabled:
+				return fmt.Errorf("exactly-once mode can't be combined with producer failures of the payment service")
+			case paymentCluster.OutageTolerance.Enabled:
+				return fmt.Errorf("exactly-once mode can't be combined with the outage tolerance of the payment service's cluster")
+			}
+		}
+		if err := c.validateHTTPProducers(shop); err != nil {
+			return err
+		}
+
+		if other, ok := topicPrefixes[shop.TopicNamePrefix()]; ok {
+			return fmt.Errorf("profiles '%v' and '%v' must use different topic prefixes", other, profile.Name)
+		}
+		topicPrefixes[shop.TopicNamePrefix()] = profile.Name
+		if other, ok := groupPrefixes[shop.GroupIDPrefix()]; ok {
+			return fmt.Errorf("profiles '%v' and '%v' must use different group prefixes", other, profile.Name)
+		}
+		groupPrefixes[shop.GroupIDPrefix()] = profile.Name
+
+		// The random source is shared by all shops of the process
+		if len(profiles) > 1 && shop.Seed != 0 {
+			return fmt.Errorf("a seed can only be configured if a single shop is run, but profile '%v' has one", profile.Name)
+		}
+	}
+
 	return nil
 }
 
-func LoadConfig(logger *zap.Logger) (Config, error) {
+// Shops returns the profiles of all shops that shall run. If no profiles are
+// configured, this is a single unnamed profile with the shop config. Profiles
+// with tenants are replaced by a profile per tenant.
+func (c *Config) Shops() []Profile {
+	profiles := c.Profiles
+	if len(profiles) == 0 {
+		profiles = []Profile{{Shop: c.Shop}}
+	}
+
+	shops := make([]Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		if len(profile.Shop.Tenants) == 0 {
+			shops = append(shops, profile)
+			continue
+		}
+		shops = append(shops, tenantProfiles(profile)...)
+	}
+
+	return shops
+}
+
+// LoadConfig loads the config from the YAML file at the given filepath and
+// from environment variables, which take precedence. If the filepath is empty,
+// it is read from the CONFIG_FILEPATH environment variable. The returned config
+// has not been validated yet.
+func LoadConfig(logger *zap.Logger, configFilepath string) (Config, error) {
 	k := koanf.New(".")
 	var cfg Config
 	cfg.SetDefaults()
 
 	// 1. Check if a config filepath is set via flags. If there is one we'll try to load the file using a YAML Parser
-	var configFilepath string
-	if cfg.ConfigFilepath != "" {
-		configFilepath = cfg.ConfigFilepath
-	} else {
+	if configFilepath == "" {
 		envKey := "CONFIG_FILEPATH"
 		configFilepath = os.Getenv(envKey)
 	}
+	cfg.ConfigFilepath = configFilepath
 	if configFilepath == "" {
 		logger.Info("config filepath is not set, proceeding with options set from env variables and flags")
 	} else {
@@ -87,16 +265,38 @@ func LoadConfig(logger *zap.Logger) (Config, error) {
 		return Config{}, fmt.Errorf("failed to unmarshal YAML config into config struct: %w", err)
 	}
 
-	err = k.Load(env.Provider("", "_", nil), nil)
+	envK := koanf.New(".")
+	err = envK.Load(env.Provider("", "_", nil), nil)
 	if err != nil {
 		return Config{}, fmt.Errorf("failed to unmarshal environment variables into config struct: %w", err)
 	}
 
 	unmarshalCfg.DecoderConfig.ErrorUnused = false
-	err = k.UnmarshalWithConf("", &cfg, unmarshalCfg)
+	err = envK.UnmarshalWithConf("", &cfg, unmarshalCfg)
 	if err != nil {
 		return Config{}, err
 	}
+	if cfg.Shop.applyDeprecatedOptions() {
+		logger.Warn("the shop options requestRate and interval are deprecated, use eventsPerSecond instead",
+			zap.Float64("events_per_second", cfg.Shop.EventsPerSecond))
+	}
+
+	// 3. Each profile's shop config is derived from the shop config of the YAML file and the environment variables,
+	// whose values are overridden by the profile.
+	for i, profileK := range k.Slices("profiles") {
+		profile := Profile{}
+		profile.Shop.SetDefaults()
+		decoderCfg := *unmarshalCfg.DecoderConfig
+		decoderCfg.Result = &profile
+		profileUnmarshalCfg := koanf.UnmarshalConf{Tag: unmarshalCfg.Tag, DecoderConfig: &decoderCfg}
+		for _, layer := range []*koanf.Koanf{k.Cut("shop"), envK.Cut("SHOP"), profileK} {
+			if err := layer.UnmarshalWithConf("", &profile, profileUnmarshalCfg); err != nil {
+				return Config{}, fmt.Errorf("failed to unmarshal config of profile %d: %w", i, err)
+			}
+		}
+		profile.Shop.applyDeprecatedOptions()
+		cfg.Profiles[i] = profile
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+)
+
+// HTTPServerTLS configures TLS for an HTTP listener.
+type HTTPServerTLS struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFilepath string `yaml:"certFilepath"`
+	KeyFilepath  string `yaml:"keyFilepath"`
+}
+
+// Validate HTTP server TLS config.
+func (c *HTTPServerTLS) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.CertFilepath == "" || c.KeyFilepath == "" {
+		return fmt.Errorf("tls requires a cert and key filepath to be set")
+	}
+
+	return nil
+}
+
+// Metrics configures the HTTP listener that serves the Prometheus metrics on
+// /metrics.
+type Metrics struct {
+	Enabled bool `yaml:"enabled"`
+
+	// ListenAddress is the address the listener binds to, e.g. ":8080" or
+	// "127.0.0.1:9090".
+	ListenAddress string        `yaml:"listenAddress"`
+	TLS           HTTPServerTLS `yaml:"tls"`
+}
+
+// SetDefaults for metrics config.
+func (c *Metrics) SetDefaults() {
+	c.Enabled = true
+	c.ListenAddress = ":8080"
+}
+
+// Validate metrics config.
+func (c *Metrics) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.ListenAddress == "" {
+		return fmt.Errorf("listen address must be set")
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("failed to validate tls config: %w", err)
+	}
+
+	return nil
+}
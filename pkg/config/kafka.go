@@ -9,6 +9,31 @@ type Kafka struct {
 	Brokers []string `yaml:"brokers"`
 	TLS     TLS      `yaml:"tls"`
 	SASL    SASL     `yaml:"sasl"`
+
+	// HTTPProxy is the HTTP proxy of the cluster, which is required if
+	// records are produced with the http protocol.
+	HTTPProxy HTTPProxy `yaml:"httpProxy"`
+
+	// Producer configures the compression and batching of all producing
+	// clients on all clusters. It can be overridden per service.
+	Producer Producer `yaml:"producer"`
+
+	// Consumer configures the group protocol of all consumer groups on all
+	// clusters.
+	Consumer Consumer `yaml:"consumer"`
+
+	// Rack is the client.rack of all clients on all clusters. Consumers
+	// fetch from the closest replica in the same rack, if the brokers have
+	// a rack aware replica selector configured.
+	Rack string `yaml:"rack"`
+
+	// OutageTolerance bounds the buffered records of all producing clients on
+	// all clusters while the brokers are unavailable.
+	OutageTolerance OutageTolerance `yaml:"outageTolerance"`
+
+	// Clusters are additional Kafka clusters that individual services can be
+	// pinned to. Services that are not pinned use the cluster above.
+	Clusters []KafkaCluster `yaml:"clusters"`
 }
 
 // Validate Kafka config.
@@ -22,10 +47,59 @@ func (c *Kafka) Validate() error {
 		return fmt.Errorf("failed to validate SASL config: %w", err)
 	}
 
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("failed to validate TLS config: %w", err)
+	}
+
+	if err := c.HTTPProxy.Validate(); err != nil {
+		return fmt.Errorf("failed to validate HTTP proxy config: %w", err)
+	}
+
+	if err := c.Producer.Validate(); err != nil {
+		return fmt.Errorf("failed to validate producer config: %w", err)
+	}
+
+	if err := c.Consumer.Validate(); err != nil {
+		return fmt.Errorf("failed to validate consumer config: %w", err)
+	}
+
+	if err := c.OutageTolerance.Validate(); err != nil {
+		return fmt.Errorf("failed to validate outage tolerance config: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(c.Clusters))
+	for i, cluster := range c.Clusters {
+		if err := cluster.Validate(); err != nil {
+			return fmt.Errorf("failed to validate cluster at index %d: %w", i, err)
+		}
+		if _, exists := names[cluster.Name]; exists {
+			return fmt.Errorf("cluster name '%v' is not unique", cluster.Name)
+		}
+		names[cluster.Name] = struct{}{}
+	}
+
 	return nil
 }
 
+// Cluster returns the connection config of the cluster with the given name.
+// An empty name returns the default cluster.
+func (c *Kafka) Cluster(name string) (Kafka, error) {
+	if name == "" {
+		return Kafka{Brokers: c.Brokers, TLS: c.TLS, SASL: c.SASL, HTTPProxy: c.HTTPProxy, Producer: c.Producer, Consumer: c.Consumer, Rack: c.Rack, OutageTolerance: c.OutageTolerance}, nil
+	}
+
+	for _, cluster := range c.Clusters {
+		if cluster.Name == name {
+			return Kafka{Brokers: cluster.Brokers, TLS: cluster.TLS, SASL: cluster.SASL, HTTPProxy: cluster.HTTPProxy, Producer: c.Producer, Consumer: c.Consumer, Rack: c.Rack, OutageTolerance: c.OutageTolerance}, nil
+		}
+	}
+
+	return Kafka{}, fmt.Errorf("cluster '%v' is not configured", name)
+}
+
 // SetDefaults for Kafka config
 func (c *Kafka) SetDefaults() {
 	c.SASL.SetDefaults()
+	c.Producer.Failures.SetDefaults()
+	c.OutageTolerance.SetDefaults()
 }
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+)
+
+// KafkaCluster is an additional Kafka cluster that services can be pinned to,
+// so that a single Owl Shop process can populate multiple clusters.
+type KafkaCluster struct {
+	// Name that is used for pinning services to this cluster.
+	Name    string   `yaml:"name"`
+	Brokers []string `yaml:"brokers"`
+	TLS     TLS      `yaml:"tls"`
+
+	// SASL config of the cluster. Unlike for the default cluster, the
+	// mechanism has no default value and must be set if SASL is enabled.
+	SASL SASL `yaml:"sasl"`
+
+	// HTTPProxy is the HTTP proxy of the cluster.
+	HTTPProxy HTTPProxy `yaml:"httpProxy"`
+}
+
+// Validate Kafka cluster config.
+func (c *KafkaCluster) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("you must configure a name for each cluster")
+	}
+
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("you must configure at least one broker to connect to")
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("failed to validate TLS config: %w", err)
+	}
+
+	if err := c.HTTPProxy.Validate(); err != nil {
+		return fmt.Errorf("failed to validate HTTP proxy config: %w", err)
+	}
+
+	if c.SASL.Enabled {
+		err := c.SASL.Validate()
+		if err != nil {
+			return fmt.Errorf("failed to validate SASL config: %w", err)
+		}
+	}
+
+	return nil
+}
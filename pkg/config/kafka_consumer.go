@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	BalancerCooperativeSticky = "cooperative-sticky"
+	BalancerSticky            = "sticky"
+	BalancerRange             = "range"
+	BalancerRoundRobin        = "roundrobin"
+)
+
+// The defaults of the Kafka client.
+const (
+	defaultSessionTimeout    = 45 * time.Second
+	defaultHeartbeatInterval = 3 * time.Second
+)
+
+// Consumer configures the group protocol of all consumer groups on all
+// clusters. Unset options keep the defaults of the Kafka client.
+type Consumer struct {
+	// Balancer is the partition assignor of the groups. Valid values are
+	// cooperative-sticky, which rebalances incrementally, and the eager
+	// assignors sticky, range and roundrobin, which revoke all partitions on
+	// each rebalance. Defaults to cooperative-sticky.
+	Balancer string `yaml:"balancer"`
+
+	// InstanceID enables static group membership. Each consumer joins its
+	// group with the instance ID <instanceID>-<client ID>, so that a
+	// restarted consumer gets its partitions back without a rebalance if it
+	// rejoins within the session timeout. Instance IDs must be unique across
+	// Owl Shop replicas that share the groups, e.g. the pod name. The extra
+	// members of the simulated rebalances are no static members.
+	InstanceID string `yaml:"instanceID"`
+
+	// SessionTimeout after which a member that has not sent a heartbeat is
+	// removed from the group. Defaults to 45s.
+	SessionTimeout time.Duration `yaml:"sessionTimeout"`
+
+	// HeartbeatInterval is the interval in which members send heartbeats,
+	// which must be shorter than the session timeout. Defaults to 3s.
+	HeartbeatInterval time.Duration `yaml:"heartbeatInterval"`
+
+	// RebalanceTimeout is the time that members have to rejoin the group
+	// once a rebalance has begun. Defaults to 60s.
+	RebalanceTimeout time.Duration `yaml:"rebalanceTimeout"`
+}
+
+// Validate consumer config.
+func (c *Consumer) Validate() error {
+	switch c.Balancer {
+	case "", BalancerCooperativeSticky, BalancerSticky, BalancerRange, BalancerRoundRobin:
+		// Valid and supported
+	default:
+		return fmt.Errorf("given balancer '%v' is invalid", c.Balancer)
+	}
+
+	if c.SessionTimeout < 0 || c.HeartbeatInterval < 0 || c.RebalanceTimeout < 0 {
+		return fmt.Errorf("session timeout, heartbeat interval and rebalance timeout must not be negative")
+	}
+
+	// Unset options are compared with the defaults of the Kafka client
+	sessionTimeout, heartbeatInterval := c.SessionTimeout, c.HeartbeatInterval
+	if sessionTimeout == 0 {
+		sessionTimeout = defaultSessionTimeout
+	}
+	if heartbeatInterval == 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+	if heartbeatInterval >= sessionTimeout {
+		return fmt.Errorf("heartbeat interval (%v) must be shorter than the session timeout (%v)", heartbeatInterval, sessionTimeout)
+	}
+
+	return nil
+}
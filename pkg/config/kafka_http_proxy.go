@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// HTTPProxy is the Kafka REST API of a cluster, e.g. Redpanda's HTTP Proxy
+// (pandaproxy) or the Confluent REST Proxy, which producers with the http
+// protocol send their records to.
+type HTTPProxy struct {
+	// Address is the base URL of the proxy, e.g. http://localhost:8082.
+	Address   string        `yaml:"address"`
+	BasicAuth HTTPBasicAuth `yaml:"basicAuth"`
+	TLS       TLS           `yaml:"tls"`
+}
+
+// Validate HTTP proxy config.
+func (c *HTTPProxy) Validate() error {
+	if c.Address == "" {
+		return nil
+	}
+
+	u, err := url.Parse(c.Address)
+	if err != nil {
+		return fmt.Errorf("failed to parse address: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("address must be an absolute http or https url")
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("failed to validate TLS config: %w", err)
+	}
+
+	return nil
+}
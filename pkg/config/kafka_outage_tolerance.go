@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// OutageTolerance bounds the records that are buffered while the brokers are
+// unavailable, e.g. during a rolling restart or upgrade. Records that are
+// produced while the buffer of a client is full are dropped instead of
+// blocking the service, and the outage and its recovery are reported.
+type OutageTolerance struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MaxBufferedRecords of each producing client. Defaults to 100000.
+	MaxBufferedRecords int `yaml:"maxBufferedRecords"`
+
+	// DetectionTimeout is the duration without any acknowledged record while
+	// records are buffered, after which the brokers are considered
+	// unavailable. Defaults to 5s.
+	DetectionTimeout time.Duration `yaml:"detectionTimeout"`
+}
+
+// SetDefaults for outage tolerance config.
+func (c *OutageTolerance) SetDefaults() {
+	c.MaxBufferedRecords = 100000
+	c.DetectionTimeout = 5 * time.Second
+}
+
+// Validate outage tolerance config.
+func (c *OutageTolerance) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxBufferedRecords <= 0 {
+		return fmt.Errorf("max buffered records must be greater than 0")
+	}
+	if c.DetectionTimeout <= 0 {
+		return fmt.Errorf("detection timeout must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionLz4    = "lz4"
+	CompressionZstd   = "zstd"
+)
+
+const (
+	AcksNone   = "0"
+	AcksLeader = "1"
+	AcksAll    = "all"
+)
+
+const (
+	// ProducerProtocolKafka produces records via the Kafka protocol.
+	ProducerProtocolKafka = "kafka"
+	// ProducerProtocolHTTP produces records via the cluster's HTTP proxy.
+	ProducerProtocolHTTP = "http"
+)
+
+// Producer configures the compression, batching and delivery semantics of
+// produced records. Unset options keep the defaults of the Kafka client.
+type Producer struct {
+	// Compression is the codec of produced record batches. Valid values are
+	// none, gzip, snappy, lz4 and zstd. Defaults to snappy.
+	Compression string `yaml:"compression"`
+
+	// Linger is the duration for which a partition's records are buffered
+	// before their batch is produced, unless the batch is full earlier.
+	// Defaults to 0, which produces batches as soon as possible.
+	Linger time.Duration `yaml:"linger"`
+
+	// BatchMaxBytes is the max size of a record batch before compression.
+	// Defaults to 1000012 bytes, the broker's default max.message.bytes.
+	BatchMaxBytes int32 `yaml:"batchMaxBytes"`
+
+	// DisableIdempotence makes retried produce requests write their records
+	// again, if the previous attempt has been written but not acknowledged.
+	DisableIdempotence bool `yaml:"disableIdempotence"`
+
+	// Acks is the number of acknowledgements that the leader waits for
+	// before it responds to a produce request. Valid values are 0 (no
+	// response at all), 1 (leader only) and all (all in-sync replicas).
+	// Acks other than all disable idempotence. Defaults to all.
+	Acks string `yaml:"acks"`
+
+	// MaxInFlight is the max number of produce requests in flight per
+	// broker. More than 1 may reorder the records of a partition if requests
+	// are retried. Setting it disables idempotence. Defaults to 0, which keeps
+	// the default of 5 for idempotent and 1 for other producers.
+	MaxInFlight int `yaml:"maxInFlight"`
+
+	// Retries of a record whose produce request has failed before the record
+	// fails. Defaults to 0, which retries until the delivery timeout
+	// expires. -1 fails records without retrying them.
+	Retries int `yaml:"retries"`
+
+	// DeliveryTimeout is the duration after which a buffered record fails
+	// if it has not been acknowledged. Defaults to 0, which keeps the
+	// client's delivery timeout of 10s. It can't be disabled.
+	DeliveryTimeout time.Duration `yaml:"deliveryTimeout"`
+
+	// Protocol that records are produced with, either kafka or http. The
+	// http protocol sends the records to the cluster's HTTP proxy instead,
+	// batched by topic for the linger and up to the batch max bytes, with up
+	// to the max in flight requests at a time. The proxy does not support
+	// record headers and timestamps, so the options that rely on them must be
+	// disabled. Transactional records are always produced via the Kafka
+	// protocol. Defaults to kafka.
+	Protocol string `yaml:"protocol"`
+
+	// Failures injects failures into the produce calls of the services.
+	Failures ProducerFailures `yaml:"failures"`
+}
+
+// Validate producer config.
+func (c *Producer) Validate() error {
+	switch c.Compression {
+	case "", CompressionNone, CompressionGzip, CompressionSnappy, CompressionLz4, CompressionZstd:
+		// Valid and supported
+	default:
+		return fmt.Errorf("given compression '%v' is invalid", c.Compression)
+	}
+
+	if c.Linger < 0 {
+		return fmt.Errorf("linger must not be negative")
+	}
+
+	if c.BatchMaxBytes < 0 {
+		return fmt.Errorf("batch max bytes must not be negative")
+	}
+
+	switch c.Acks {
+	case "", AcksNone, AcksLeader, AcksAll:
+	default:
+		return fmt.Errorf("acks must be either '%v', '%v' or '%v'", AcksNone, AcksLeader, AcksAll)
+	}
+
+	if c.MaxInFlight < 0 {
+		return fmt.Errorf("max in flight must not be negative")
+	}
+
+	if c.Retries < -1 {
+		return fmt.Errorf("retries must be -1 or greater")
+	}
+
+	if c.DeliveryTimeout < 0 {
+		return fmt.Errorf("delivery timeout must not be negative")
+	}
+
+	switch c.Protocol {
+	case "", ProducerProtocolKafka, ProducerProtocolHTTP:
+	default:
+		return fmt.Errorf("protocol must be either '%v' or '%v'", ProducerProtocolKafka, ProducerProtocolHTTP)
+	}
+
+	if err := c.Failures.Validate(); err != nil {
+		return fmt.Errorf("failed to validate failures config: %w", err)
+	}
+
+	return nil
+}
+
+// WithOverrides returns the producer config with all options that are set in
+// the given overrides replaced.
+func (c Producer) WithOverrides(overrides Producer) Producer {
+	if overrides.Compression != "" {
+		c.Compression = overrides.Compression
+	}
+	if overrides.Linger != 0 {
+		c.Linger = overrides.Linger
+	}
+	if overrides.BatchMaxBytes != 0 {
+		c.BatchMaxBytes = overrides.BatchMaxBytes
+	}
+	if overrides.DisableIdempotence {
+		c.DisableIdempotence = true
+	}
+	if overrides.Acks != "" {
+		c.Acks = overrides.Acks
+	}
+	if overrides.MaxInFlight != 0 {
+		c.MaxInFlight = overrides.MaxInFlight
+	}
+	if overrides.Retries != 0 {
+		c.Retries = overrides.Retries
+	}
+	if overrides.DeliveryTimeout != 0 {
+		c.DeliveryTimeout = overrides.DeliveryTimeout
+	}
+	if overrides.Protocol != "" {
+		c.Protocol = overrides.Protocol
+	}
+	c.Failures = c.Failures.WithOverrides(overrides.Failures)
+	return c
+}
+
+// Idempotent returns whether the producer is idempotent, which requires acks
+// from all in-sync replicas and the default max in flight.
+func (c Producer) Idempotent() bool {
+	return !c.DisableIdempotence && (c.Acks == "" || c.Acks == AcksAll) && c.MaxInFlight == 0
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProducerFailures injects failures into produce calls, so that alerting rules
+// on produce retries and errors can be exercised without breaking the
+// cluster. Each attempt of a record fails with the given ratio, either with a
+// request timeout or a NOT_LEADER_FOR_PARTITION error, and is retried after
+// the backoff. Records whose retries are exhausted fail with the last error.
+type ProducerFailures struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Ratio of produce attempts that fail. Defaults to 0.01.
+	Ratio float64 `yaml:"ratio"`
+
+	// TimeoutRatio is the share of failed attempts that time out, the others
+	// fail immediately because the partition's leader has moved. Defaults to
+	// 0.5.
+	TimeoutRatio float64 `yaml:"timeoutRatio"`
+
+	// Timeout after which a timed out attempt fails. Defaults to 1s.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// MaxRetries of a failed record before its error is returned. Defaults
+	// to 3.
+	MaxRetries int `yaml:"maxRetries"`
+
+	// RetryBackoff is the duration between a failed attempt and its retry.
+	// Defaults to 100ms.
+	RetryBackoff time.Duration `yaml:"retryBackoff"`
+}
+
+// SetDefaults for producer failures config.
+func (c *ProducerFailures) SetDefaults() {
+	c.Ratio = 0.01
+	c.TimeoutRatio = 0.5
+	c.Timeout = time.Second
+	c.MaxRetries = 3
+	c.RetryBackoff = 100 * time.Millisecond
+}
+
+// Validate producer failures config.
+func (c *ProducerFailures) Validate() error {
+	if c.Ratio < 0 || c.Ratio > 1 {
+		return fmt.Errorf("ratio must be between 0 and 1")
+	}
+	if c.TimeoutRatio < 0 || c.TimeoutRatio > 1 {
+		return fmt.Errorf("timeout ratio must be between 0 and 1")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+
+	return nil
+}
+
+// WithOverrides returns the producer failures config with all options that
+// are set in the given overrides replaced.
+func (c ProducerFailures) WithOverrides(overrides ProducerFailures) ProducerFailures {
+	if overrides.Enabled {
+		c.Enabled = true
+	}
+	if overrides.Ratio != 0 {
+		c.Ratio = overrides.Ratio
+	}
+	if overrides.TimeoutRatio != 0 {
+		c.TimeoutRatio = overrides.TimeoutRatio
+	}
+	if overrides.Timeout != 0 {
+		c.Timeout = overrides.Timeout
+	}
+	if overrides.MaxRetries != 0 {
+		c.MaxRetries = overrides.MaxRetries
+	}
+	if overrides.RetryBackoff != 0 {
+		c.RetryBackoff = overrides.RetryBackoff
+	}
+	return c
+}
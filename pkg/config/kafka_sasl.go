@@ -10,6 +10,7 @@ const (
 	SASLMechanismScramSHA512 = "SCRAM-SHA-512"
 	SASLMechanismGSSAPI      = "GSSAPI"
 	SASLMechanismOAuthBearer = "OAUTHBEARER"
+	SASLMechanismAWSMSKIAM   = "AWS_MSK_IAM"
 )
 
 // SASL config for Kafka client
@@ -19,6 +20,8 @@ type SASL struct {
 	Password     string           `yaml:"password"`
 	Mechanism    string           `yaml:"mechanism"`
 	GSSAPIConfig SASLGSSAPIConfig `yaml:"gssapi"`
+	OAuthBearer  OAuthBearer      `yaml:"oauth"`
+	AWSMSKIAM    SASLAWSMSKIAM    `yaml:"awsMskIam"`
 }
 
 // SetDefaults for SASL Config
@@ -29,10 +32,12 @@ func (c *SASL) SetDefaults() {
 // Validate SASL config input
 func (c *SASL) Validate() error {
 	switch c.Mechanism {
-	case SASLMechanismPlain, SASLMechanismScramSHA256, SASLMechanismScramSHA512, SASLMechanismGSSAPI:
+	case SASLMechanismPlain, SASLMechanismScramSHA256, SASLMechanismScramSHA512, SASLMechanismGSSAPI, SASLMechanismAWSMSKIAM:
 		// Valid and supported
 	case SASLMechanismOAuthBearer:
-		return fmt.Errorf("sasl mechanism '%v' is valid but not yet supported. Please submit an issue if you need it", c.Mechanism)
+		if err := c.OAuthBearer.Validate(); err != nil {
+			return fmt.Errorf("failed to validate oauth config: %w", err)
+		}
 	default:
 		return fmt.Errorf("given sasl mechanism '%v' is invalid", c.Mechanism)
 	}
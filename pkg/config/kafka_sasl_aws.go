@@ -0,0 +1,16 @@
+package config
+
+// SASLAWSMSKIAM configures the credentials for the AWS_MSK_IAM mechanism of
+// Amazon MSK. If no access key is configured, the credentials are taken from
+// the standard AWS environment variables: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, or AWS_ROLE_ARN and
+// AWS_WEB_IDENTITY_TOKEN_FILE for IAM roles for service accounts on EKS.
+type SASLAWSMSKIAM struct {
+	AccessKey    string `yaml:"accessKey"`
+	SecretKey    string `yaml:"secretKey"`
+	SessionToken string `yaml:"sessionToken"`
+
+	// UserAgent is sent to the broker for auditing purposes. Defaults to
+	// franz-go's user agent.
+	UserAgent string `yaml:"userAgent"`
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+)
+
+// OAuthBearer configures how bearer tokens are retrieved, both for the
+// OAUTHBEARER SASL mechanism and for the schema registry. Exactly one token
+// source must be configured: a static token, a file that contains the token
+// (e.g. a projected Kubernetes service account token) or a token endpoint that
+// issues tokens via the OAuth 2.0 client credentials flow (e.g. Azure AD, Okta
+// or Keycloak).
+type OAuthBearer struct {
+	Token         string `yaml:"token"`
+	TokenFilepath string `yaml:"tokenFilepath"`
+
+	// TokenEndpoint is the URL of the OAuth 2.0 token endpoint, e.g.
+	// https://login.microsoftonline.com/<tenant-id>/oauth2/v2.0/token.
+	TokenEndpoint string `yaml:"tokenEndpoint"`
+	ClientID      string `yaml:"clientId"`
+	ClientSecret  string `yaml:"clientSecret"`
+	// Scope that is requested from the token endpoint, e.g.
+	// https://<namespace>.servicebus.windows.net/.default for Azure Event Hubs.
+	Scope string `yaml:"scope"`
+
+	// Extensions are sent to the broker along with the token, as required by
+	// some providers (e.g. logicalCluster and identityPoolId for Confluent
+	// Cloud). They are only used for the OAUTHBEARER SASL mechanism.
+	Extensions map[string]string `yaml:"extensions"`
+}
+
+// IsConfigured returns whether any token source has been configured.
+func (c *OAuthBearer) IsConfigured() bool {
+	return c.Token != "" || c.TokenFilepath != "" || c.TokenEndpoint != ""
+}
+
+// Validate OAuth bearer config.
+func (c *OAuthBearer) Validate() error {
+	sources := 0
+	for _, source := range []string{c.Token, c.TokenFilepath, c.TokenEndpoint} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of token, token filepath or token endpoint must be set")
+	}
+
+	if c.TokenEndpoint != "" && (c.ClientID == "" || c.ClientSecret == "") {
+		return fmt.Errorf("token endpoint requires a client id and client secret")
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+// ObjectStorage configures the credentials and endpoints of the object
+// storages that files are uploaded to and downloaded from. The storage is
+// chosen by the scheme of the file's URL: s3://bucket/key, gs://bucket/key or
+// azblob://container/key.
+type ObjectStorage struct {
+	S3    ObjectStorageS3    `yaml:"s3"`
+	GCS   ObjectStorageGCS   `yaml:"gcs"`
+	Azure ObjectStorageAzure `yaml:"azure"`
+}
+
+// ObjectStorageS3 configures Amazon S3 or an S3 compatible storage. If no
+// access key is configured, the credentials are taken from the standard AWS
+// environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN.
+type ObjectStorageS3 struct {
+	// Region of the bucket. Defaults to the AWS_REGION environment variable
+	// or us-east-1.
+	Region string `yaml:"region"`
+
+	// Endpoint of an S3 compatible storage, e.g. MinIO or the XML API of
+	// Google Cloud Storage. Buckets are addressed by path rather than by host
+	// if it is set. Defaults to the Amazon S3 endpoint of the region.
+	Endpoint string `yaml:"endpoint"`
+
+	AccessKey    string `yaml:"accessKey"`
+	SecretKey    string `yaml:"secretKey"`
+	SessionToken string `yaml:"sessionToken"`
+}
+
+// ObjectStorageGCS configures Google Cloud Storage. If no access token is
+// configured, it is taken from the GOOGLE_OAUTH_ACCESS_TOKEN environment
+// variable or requested from the metadata server, e.g. for workload identity
+// on GKE.
+type ObjectStorageGCS struct {
+	// Endpoint of the JSON API. Defaults to https://storage.googleapis.com.
+	Endpoint string `yaml:"endpoint"`
+
+	AccessToken string `yaml:"accessToken"`
+}
+
+// ObjectStorageAzure configures Azure Blob Storage, which is authenticated
+// with a shared access signature. The account and SAS token default to the
+// AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_SAS_TOKEN environment variables.
+type ObjectStorageAzure struct {
+	Account  string `yaml:"account"`
+	SASToken string `yaml:"sasToken"`
+
+	// Endpoint of the blob service. Defaults to
+	// https://<account>.blob.core.windows.net.
+	Endpoint string `yaml:"endpoint"`
+}
+
+// SetDefaults for object storage config.
+func (c *ObjectStorage) SetDefaults() {
+	c.GCS.Endpoint = "https://storage.googleapis.com"
+}
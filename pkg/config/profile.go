@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// profileNamePattern restricts profile names to characters that can be used
+// in URL paths and log fields.
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Profile is a named shop that runs concurrently with the shops of all other
+// profiles in the same process, e.g. "eu-shop" and "us-shop". Its shop config
+// inherits all settings of the root shop config and overrides them, so that
+// profiles only need to set what differs, such as prefixes, rates and event
+// weights. The HTTP listeners, including the admin API, are shared by all
+// profiles and are configured by the root shop config.
+type Profile struct {
+	Name string `yaml:"name"`
+	Shop Shop   `yaml:",squash"`
+}
+
+// Validate profile config.
+func (c *Profile) Validate() error {
+	if !profileNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("name '%v' must consist of lower case alphanumeric characters or '-'", c.Name)
+	}
+
+	if err := c.Shop.Validate(); err != nil {
+		return fmt.Errorf("failed to validate shop config: %w", err)
+	}
+
+	return nil
+}
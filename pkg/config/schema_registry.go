@@ -1,10 +1,18 @@
 package config
 
+import (
+	"fmt"
+)
+
 // SchemaRegistry is the configuration for the schema registry.
 type SchemaRegistry struct {
 	Address   string        `yaml:"address"`
 	BasicAuth HTTPBasicAuth `yaml:"basicAuth"`
-	TLS       TLS           `yaml:"tls"`
+	// BearerAuth authenticates requests with a bearer token, e.g. an OAuth
+	// token for a registry secured by an identity provider. It can't be
+	// combined with basic auth.
+	BearerAuth OAuthBearer `yaml:"bearerAuth"`
+	TLS        TLS         `yaml:"tls"`
 }
 
 // HTTPBasicAuth for authentication via HTTP.
@@ -19,6 +27,19 @@ func (c *SchemaRegistry) Validate() error {
 		return nil
 	}
 
+	if c.BearerAuth.IsConfigured() {
+		if c.BasicAuth.Username != "" {
+			return fmt.Errorf("basic auth and bearer auth can't be combined")
+		}
+		if err := c.BearerAuth.Validate(); err != nil {
+			return fmt.Errorf("failed to validate bearer auth config: %w", err)
+		}
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("failed to validate TLS config: %w", err)
+	}
+
 	return nil
 }
 
@@ -2,48 +2,410 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
 // Shop is the configuration for the virtual shop that emits Kafka records
 // upon simulated page impressions.
 type Shop struct {
-	// RequestRate is the number of requests per rate interval that shall
-	// be simulated on the shop. Defaults to 2 requests at a default interval
-	// of 1s.
+	// EventsPerSecond is the number of page impressions that shall be
+	// simulated on the shop per second. The rate is enforced by a token
+	// bucket, so that it stays stable regardless of how long the individual
+	// events take. Defaults to 2.
+	EventsPerSecond float64 `yaml:"eventsPerSecond"`
+
+	// Burst is the max number of page impressions that may be simulated at
+	// once, e.g. to catch up after slow events. Defaults to 0, which allows
+	// bursts of a tenth of a second's worth of page impressions, but at least
+	// one.
+	Burst int `yaml:"burst"`
+
+	// RequestRate is the number of page impressions per RequestRateInterval.
+	// Deprecated: Use EventsPerSecond instead. If set, it overrides
+	// EventsPerSecond with RequestRate / RequestRateInterval.
 	RequestRate int `yaml:"requestRate"`
 
-	// RequestRateInterval is the interval in which {RequestRate} requests
-	// shall be performed. Defaults to 1s.
+	// RequestRateInterval is the interval in which RequestRate page
+	// impressions are simulated. Defaults to 1s if only RequestRate is set.
+	// Deprecated: Use EventsPerSecond instead.
 	RequestRateInterval time.Duration `yaml:"interval"`
 
+	// MaxEvents is the number of page impressions after which the traffic
+	// simulation stops, so that the shop exits once all records have been
+	// flushed. The historical backfill is not counted. Defaults to 0, which
+	// means that the traffic is simulated until the shop is stopped.
+	MaxEvents int `yaml:"maxEvents"`
+
+	// RunDuration is the duration after which the traffic simulation stops,
+	// so that the shop exits once all records have been flushed. It starts
+	// after the historical backfill. Defaults to 0, which means that the
+	// traffic is simulated until the shop is stopped.
+	RunDuration time.Duration `yaml:"runDuration"`
+
+	// Traffic configures the load shape of the simulated requests.
+	Traffic Traffic `yaml:"traffic"`
+
+	// Workers configures the goroutines that simulate page impressions.
+	Workers Workers `yaml:"workers"`
+
+	// PayloadCache configures the pre-generation of customers and addresses.
+	PayloadCache PayloadCache `yaml:"payloadCache"`
+
+	// TimeAcceleration is the factor by which the simulated time passes
+	// faster than the wall clock. The events per second and all delays of the
+	// simulation, such as the page delays of sessions, the steps of shipments
+	// and the traffic pattern, are based on the simulated time, so that e.g.
+	// an hour of shop activity is simulated in a minute with a factor of 60.
+	// Defaults to 1.
+	TimeAcceleration float64 `yaml:"timeAcceleration"`
+
 	// Prefix for all topic names, consumer group names, client ids etc.
 	GlobalPrefix string `yaml:"globalPrefix"`
 
+	// TopicPrefix overrides the global prefix of all topic names. Defaults to
+	// the global prefix.
+	TopicPrefix string `yaml:"topicPrefix"`
+
+	// GroupPrefix overrides the global prefix of all consumer group names.
+	// Defaults to the global prefix.
+	GroupPrefix string `yaml:"groupPrefix"`
+
+	// SubjectPrefix overrides the prefix of all schema registry subjects,
+	// which are named after their topic without its prefix. Defaults to the
+	// topic prefix, so that the subjects follow the topic name strategy.
+	SubjectPrefix string `yaml:"subjectPrefix"`
+
 	// TopicReplicationFactor that shall be used for all Kafka topics.
 	TopicReplicationFactor int16 `yaml:"topicReplicationFactor"`
 
 	// TopicPartitionCount that shall be used for all Kafka topics.
 	TopicPartitionCount int32 `yaml:"topicPartitionCount"`
+
+	// Topics overrides the settings of individual topics, keyed by the topic
+	// name without the topic prefix (e.g. "orders").
+	Topics map[string]Topic `yaml:"topics"`
+
+	// PartitionPins pin the records of specific customer classes to
+	// designated partitions, e.g. VIP customers to partition 3, so that the
+	// traffic of a partition is predictable in demos. The first matching pin
+	// of a record's topic applies. Compacted topics can't be pinned.
+	PartitionPins []PartitionPin `yaml:"partitionPins"`
+
+	// Seed for the random data generation. Two runs with the same non-zero seed
+	// simulate the same sequence of page impressions, which allows to reproduce
+	// issues and to compare benchmarks. Events that are produced in reaction to
+	// consumed records (e.g. payments) still depend on their timing, as does the
+	// interleaving of services with a rate of their own. Defaults to 0, which
+	// means that a random seed is used.
+	Seed int64 `yaml:"seed"`
+
+	// Locale of the generated customers and their addresses, which controls
+	// their names, phone numbers and address formats (e.g. "de_DE"). Defaults
+	// to "en_US".
+	Locale string `yaml:"locale"`
+
+	// Regions generate customers with different locales by weight, which
+	// overrides the locale.
+	Regions []Region `yaml:"regions"`
+
+	// Initialization configures the retries of the initialization upon
+	// startup.
+	Initialization Initialization `yaml:"initialization"`
+
+	// Backfill configures the historical backfill upon startup.
+	Backfill Backfill `yaml:"backfill"`
+
+	// EventWeights are the relative weights of the simulated events.
+	EventWeights EventWeights `yaml:"eventWeights"`
+
+	// Customers configures the simulated customer deletions.
+	Customers Customers `yaml:"customers"`
+
+	// Sessions configures the simulated user sessions of the frontend.
+	Sessions Sessions `yaml:"sessions"`
+
+	// Search configures the search queries of the frontend sessions.
+	Search Search `yaml:"search"`
+
+	// Carts configures the simulated shopping carts.
+	Carts Carts `yaml:"carts"`
+
+	// B2B configures the wholesale mode with company customers, large orders
+	// and invoices.
+	B2B B2B `yaml:"b2b"`
+
+	// Payments configures the outcomes of simulated payments.
+	Payments Payments `yaml:"payments"`
+
+	// Warehouses configures the warehouses and stock levels of the inventory.
+	Warehouses Warehouses `yaml:"warehouses"`
+
+	// Shipments configures the lifecycle of simulated shipments.
+	Shipments Shipments `yaml:"shipments"`
+
+	// Notifications configures the notification outbox of orders, shipments
+	// and customers.
+	Notifications Notifications `yaml:"notifications"`
+
+	// Returns configures the product returns of delivered orders.
+	Returns Returns `yaml:"returns"`
+
+	// Support configures the support tickets of orders.
+	Support Support `yaml:"support"`
+
+	// OrderLifecycle configures the event-sourced lifecycle of simulated
+	// orders.
+	OrderLifecycle OrderLifecycle `yaml:"orderLifecycle"`
+
+	// OrderCompensations configures the cancellations and refunds of placed
+	// orders.
+	OrderCompensations OrderCompensations `yaml:"orderCompensations"`
+
+	// Fraud configures the fraud signals of simulated orders.
+	Fraud Fraud `yaml:"fraud"`
+
+	// Pricing configures the product prices and the currencies of orders and
+	// carts.
+	Pricing Pricing `yaml:"pricing"`
+
+	// PriceChanges configures the periodic price updates and promotions of
+	// the products.
+	PriceChanges PriceChanges `yaml:"priceChanges"`
+
+	// LargeMessages configures the large product media records of the
+	// product catalog.
+	LargeMessages LargeMessages `yaml:"largeMessages"`
+
+	// Transactions configures the transactional mode of the order service.
+	Transactions Transactions `yaml:"transactions"`
+
+	// ExactlyOnce configures the exactly-once mode of the payment service.
+	ExactlyOnce ExactlyOnce `yaml:"exactlyOnce"`
+
+	// Duplicates configures the injection of duplicate records.
+	Duplicates Duplicates `yaml:"duplicates"`
+
+	// LateRecords configures the injection of late records.
+	LateRecords LateRecords `yaml:"lateRecords"`
+
+	// Rebalances configures the periodic rebalancing of consumer groups.
+	Rebalances Rebalances `yaml:"rebalances"`
+
+	// DeadLetters configures the injection of poison messages and their
+	// routing to the dead letter queue.
+	DeadLetters DeadLetters `yaml:"deadLetters"`
+
+	// Headers configures the headers that are added to every produced
+	// record.
+	Headers Headers `yaml:"headers"`
+
+	// CloudEvents configures the CloudEvents envelope of all produced
+	// records.
+	CloudEvents CloudEvents `yaml:"cloudEvents"`
+
+	// CDC configures the Debezium-style change events of customers,
+	// addresses and orders.
+	CDC CDC `yaml:"cdc"`
+
+	// Streams configures the internal topics of a simulated Kafka Streams
+	// application.
+	Streams Streams `yaml:"streams"`
+
+	// Webhook configures the HTTP sink, which sends all produced records to
+	// a webhook endpoint as well.
+	Webhook Webhook `yaml:"webhook"`
+
+	// FileSink configures the sink that writes a sample of all produced
+	// records to local files as well.
+	FileSink FileSink `yaml:"fileSink"`
+
+	// RecordLog configures the sampled debug logging of produced records.
+	RecordLog RecordLog `yaml:"recordLog"`
+
+	// Services contains the configuration for individual services.
+	Services Services `yaml:"services"`
+
+	// SchemaEvolution configures the simulated evolution of the order schema.
+	SchemaEvolution SchemaEvolution `yaml:"schemaEvolution"`
+
+	// AvroLogicalTypes configures the enrichment of the Avro order schema
+	// with logical types and complex fields.
+	AvroLogicalTypes AvroLogicalTypes `yaml:"avroLogicalTypes"`
+
+	// SchemaRegistryFailures configures the injection of unknown schema IDs
+	// and skipped schema registrations.
+	SchemaRegistryFailures SchemaRegistryFailures `yaml:"schemaRegistryFailures"`
+
+	// Verifier configures the consumer that measures the end-to-end latency
+	// and ordering of all topics.
+	Verifier Verifier `yaml:"verifier"`
+
+	// Integrity configures the sequence numbers of all produced records,
+	// which are verified by the verifier.
+	Integrity Integrity `yaml:"integrity"`
+
+	// ManyTopics configures the creation of many low traffic topics, which
+	// stresses the metadata handling of Kafka tooling.
+	ManyTopics ManyTopics `yaml:"manyTopics"`
+
+	// Sales are scheduled sale events, which multiply the traffic of some
+	// services for a window and produce promo code usages.
+	Sales []Sale `yaml:"sales"`
+
+	// Generators configures the custom event generators by the name they
+	// have been registered with.
+	Generators map[string]Generator `yaml:"generators"`
+
+	// Scenario configures a script of timed steps that is run alongside the
+	// traffic simulation.
+	Scenario Scenario `yaml:"scenario"`
+
+	// RecentOrders is the number of recently placed orders that each service
+	// tracks, e.g. so that they can be cancelled on demand. The oldest order
+	// is dropped once it is exceeded. Defaults to 10000.
+	RecentOrders int `yaml:"recentOrders"`
+
+	// PendingFollowUps is the number of scheduled follow-up events, e.g. the
+	// replies of support tickets, that each service buffers until they are
+	// due. Further follow-ups are dropped once it is exceeded. Defaults to
+	// 10000.
+	PendingFollowUps int `yaml:"pendingFollowUps"`
+
+	// AdminAPI configures the HTTP API for changing the traffic at runtime.
+	AdminAPI AdminAPI `yaml:"adminApi"`
+
+	// Tenants run a shop with its own topics and consumer groups per tenant
+	// in place of this shop. Each tenant is run as a profile that is named
+	// after the tenant.
+	Tenants []Tenant `yaml:"tenants"`
 }
 
 // SetDefaults for shop config.
 func (c *Shop) SetDefaults() {
 	c.GlobalPrefix = "owlshop-"
-	c.RequestRate = 2
-	c.RequestRateInterval = time.Second
+	c.EventsPerSecond = 2
+	c.TimeAcceleration = 1
 	c.TopicReplicationFactor = -1
 	c.TopicPartitionCount = 1
+	c.Locale = "en_US"
+	c.RecentOrders = 10000
+	c.PendingFollowUps = 10000
+	c.Traffic.SetDefaults()
+	c.Workers.SetDefaults()
+	c.PayloadCache.SetDefaults()
+	c.Initialization.SetDefaults()
+	c.Backfill.SetDefaults()
+	c.EventWeights.SetDefaults()
+	c.Customers.SetDefaults()
+	c.Sessions.SetDefaults()
+	c.Search.SetDefaults()
+	c.Carts.SetDefaults()
+	c.B2B.SetDefaults()
+	c.Payments.SetDefaults()
+	c.Warehouses.SetDefaults()
+	c.Shipments.SetDefaults()
+	c.Notifications.SetDefaults()
+	c.Returns.SetDefaults()
+	c.Support.SetDefaults()
+	c.OrderLifecycle.SetDefaults()
+	c.OrderCompensations.SetDefaults()
+	c.Fraud.SetDefaults()
+	c.Pricing.SetDefaults()
+	c.PriceChanges.SetDefaults()
+	c.LargeMessages.SetDefaults()
+	c.Duplicates.SetDefaults()
+	c.LateRecords.SetDefaults()
+	c.Rebalances.SetDefaults()
+	c.Transactions.SetDefaults()
+	c.ExactlyOnce.SetDefaults()
+	c.DeadLetters.SetDefaults()
+	c.Headers.SetDefaults()
+	c.CloudEvents.SetDefaults()
+	c.CDC.SetDefaults()
+	c.Streams.SetDefaults()
+	c.Webhook.SetDefaults()
+	c.FileSink.SetDefaults()
+	c.RecordLog.SetDefaults()
+	c.Integrity.SetDefaults()
+	c.ManyTopics.SetDefaults()
+	c.Services.SetDefaults()
+	c.SchemaEvolution.SetDefaults()
+	c.AvroLogicalTypes.SetDefaults()
+	c.SchemaRegistryFailures.SetDefaults()
+	c.AdminAPI.SetDefaults()
+}
+
+// applyDeprecatedOptions maps the deprecated request rate and interval onto
+// the events per second. It returns whether any deprecated option is set.
+func (c *Shop) applyDeprecatedOptions() bool {
+	if c.RequestRate <= 0 && c.RequestRateInterval <= 0 {
+		return false
+	}
+
+	// Both options default to the defaults they had before they have been
+	// deprecated
+	requestRate := c.RequestRate
+	if requestRate <= 0 {
+		requestRate = 2
+	}
+	interval := c.RequestRateInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	c.EventsPerSecond = float64(requestRate) / interval.Seconds()
+
+	return true
 }
 
 // Validate shop configuration.
 func (c *Shop) Validate() error {
-	if c.RequestRate <= 0 {
-		return fmt.Errorf("request rate must be a positive integer")
+	if c.RequestRate < 0 {
+		return fmt.Errorf("request rate must not be negative")
+	}
+
+	if c.RequestRateInterval < 0 {
+		return fmt.Errorf("request rate interval must not be negative")
+	}
+
+	if c.EventsPerSecond <= 0 {
+		return fmt.Errorf("events per second must be a positive number")
+	}
+
+	if c.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+
+	if c.TimeAcceleration <= 0 {
+		return fmt.Errorf("time acceleration must be a positive number")
 	}
 
-	if c.RequestRateInterval == 0 {
-		return fmt.Errorf("request rate must be a valid duration (e.g. '1s')")
+	if c.MaxEvents < 0 {
+		return fmt.Errorf("max events must not be negative")
+	}
+
+	if c.RecentOrders <= 0 {
+		return fmt.Errorf("recent orders must be a positive number")
+	}
+
+	if c.PendingFollowUps <= 0 {
+		return fmt.Errorf("pending follow ups must be a positive number")
+	}
+
+	if c.RunDuration < 0 {
+		return fmt.Errorf("run duration must not be negative")
+	}
+
+	if err := c.Traffic.Validate(); err != nil {
+		return fmt.Errorf("failed to validate traffic config: %w", err)
+	}
+
+	if err := c.Workers.Validate(); err != nil {
+		return fmt.Errorf("failed to validate workers config: %w", err)
+	}
+
+	if err := c.PayloadCache.Validate(); err != nil {
+		return fmt.Errorf("failed to validate payload cache config: %w", err)
 	}
 
 	if c.TopicReplicationFactor < -1 || c.TopicReplicationFactor == 0 {
@@ -54,5 +416,294 @@ func (c *Shop) Validate() error {
 		return fmt.Errorf("partition count must be a positive integer or '-1' for using the default partition count")
 	}
 
+	for name, topic := range c.Topics {
+		if err := topic.Validate(); err != nil {
+			return fmt.Errorf("failed to validate config of topic '%v': %w", name, err)
+		}
+		if err := topic.validateKey(name); err != nil {
+			return fmt.Errorf("failed to validate config of topic '%v': %w", name, err)
+		}
+	}
+
+	for i, pin := range c.PartitionPins {
+		if err := pin.Validate(); err != nil {
+			return fmt.Errorf("failed to validate partition pin at index %d: %w", i, err)
+		}
+		topic := c.Topics[pin.Topic]
+		if cleanupPolicy := topic.cleanupPolicy(pin.Topic); strings.Contains(cleanupPolicy, "compact") {
+			return fmt.Errorf("partition pin at index %d can't pin the records of topic '%v' with cleanup policy '%v', "+
+				"because records of the same key would end up on different partitions", i, pin.Topic, cleanupPolicy)
+		}
+	}
+
+	if c.Locale == "" {
+		return fmt.Errorf("locale must be set")
+	}
+
+	for i, region := range c.Regions {
+		if err := region.Validate(); err != nil {
+			return fmt.Errorf("failed to validate region %d: %w", i, err)
+		}
+	}
+
+	if err := c.Initialization.Validate(); err != nil {
+		return fmt.Errorf("failed to validate initialization config: %w", err)
+	}
+
+	if err := c.Backfill.Validate(); err != nil {
+		return fmt.Errorf("failed to validate backfill config: %w", err)
+	}
+
+	if err := c.EventWeights.Validate(); err != nil {
+		return fmt.Errorf("failed to validate event weights config: %w", err)
+	}
+
+	if err := c.Customers.Validate(); err != nil {
+		return fmt.Errorf("failed to validate customers config: %w", err)
+	}
+
+	if err := c.Sessions.Validate(); err != nil {
+		return fmt.Errorf("failed to validate sessions config: %w", err)
+	}
+
+	if err := c.Search.Validate(); err != nil {
+		return fmt.Errorf("failed to validate search config: %w", err)
+	}
+
+	if err := c.Carts.Validate(); err != nil {
+		return fmt.Errorf("failed to validate carts config: %w", err)
+	}
+
+	if err := c.B2B.Validate(); err != nil {
+		return fmt.Errorf("failed to validate b2b config: %w", err)
+	}
+
+	if err := c.Payments.Validate(); err != nil {
+		return fmt.Errorf("failed to validate payments config: %w", err)
+	}
+
+	if err := c.Warehouses.Validate(); err != nil {
+		return fmt.Errorf("failed to validate warehouses config: %w", err)
+	}
+
+	if err := c.Shipments.Validate(); err != nil {
+		return fmt.Errorf("failed to validate shipments config: %w", err)
+	}
+
+	if err := c.Notifications.Validate(); err != nil {
+		return fmt.Errorf("failed to validate notifications config: %w", err)
+	}
+
+	if err := c.Returns.Validate(); err != nil {
+		return fmt.Errorf("failed to validate returns config: %w", err)
+	}
+
+	if err := c.Support.Validate(); err != nil {
+		return fmt.Errorf("failed to validate support config: %w", err)
+	}
+
+	if err := c.OrderLifecycle.Validate(); err != nil {
+		return fmt.Errorf("failed to validate order lifecycle config: %w", err)
+	}
+
+	if err := c.OrderCompensations.Validate(); err != nil {
+		return fmt.Errorf("failed to validate order compensations config: %w", err)
+	}
+
+	if err := c.Fraud.Validate(); err != nil {
+		return fmt.Errorf("failed to validate fraud config: %w", err)
+	}
+
+	if err := c.Pricing.Validate(); err != nil {
+		return fmt.Errorf("failed to validate pricing config: %w", err)
+	}
+
+	if err := c.PriceChanges.Validate(); err != nil {
+		return fmt.Errorf("failed to validate price changes config: %w", err)
+	}
+
+	if err := c.LargeMessages.Validate(); err != nil {
+		return fmt.Errorf("failed to validate large messages config: %w", err)
+	}
+
+	if err := c.Transactions.Validate(); err != nil {
+		return fmt.Errorf("failed to validate transactions config: %w", err)
+	}
+
+	if err := c.ExactlyOnce.Validate(); err != nil {
+		return fmt.Errorf("failed to validate exactly-once config: %w", err)
+	}
+	if c.ExactlyOnce.Enabled && c.B2B.Enabled {
+		return fmt.Errorf("exactly-once mode can't be combined with the wholesale mode, whose invoices are advanced outside of the transactions")
+	}
+
+	if err := c.Duplicates.Validate(); err != nil {
+		return fmt.Errorf("failed to validate duplicates config: %w", err)
+	}
+
+	if err := c.LateRecords.Validate(); err != nil {
+		return fmt.Errorf("failed to validate late records config: %w", err)
+	}
+
+	if err := c.Rebalances.Validate(); err != nil {
+		return fmt.Errorf("failed to validate rebalances config: %w", err)
+	}
+
+	if err := c.DeadLetters.Validate(); err != nil {
+		return fmt.Errorf("failed to validate dead letters config: %w", err)
+	}
+
+	if err := c.Headers.Validate(); err != nil {
+		return fmt.Errorf("failed to validate headers config: %w", err)
+	}
+
+	if err := c.CloudEvents.Validate(); err != nil {
+		return fmt.Errorf("failed to validate cloud events config: %w", err)
+	}
+
+	if err := c.CDC.Validate(); err != nil {
+		return fmt.Errorf("failed to validate cdc config: %w", err)
+	}
+
+	if err := c.Streams.Validate(); err != nil {
+		return fmt.Errorf("failed to validate streams config: %w", err)
+	}
+
+	if err := c.Webhook.Validate(); err != nil {
+		return fmt.Errorf("failed to validate webhook config: %w", err)
+	}
+
+	if err := c.FileSink.Validate(); err != nil {
+		return fmt.Errorf("failed to validate file sink config: %w", err)
+	}
+
+	if err := c.RecordLog.Validate(); err != nil {
+		return fmt.Errorf("failed to validate record log config: %w", err)
+	}
+
+	if err := c.Verifier.Validate(); err != nil {
+		return fmt.Errorf("failed to validate verifier config: %w", err)
+	}
+
+	if err := c.Integrity.Validate(); err != nil {
+		return fmt.Errorf("failed to validate integrity config: %w", err)
+	}
+	if c.Integrity.FailOnViolations && !c.Verifier.Enabled {
+		return fmt.Errorf("failing on integrity violations requires the verifier to be enabled")
+	}
+
+	if err := c.ManyTopics.Validate(); err != nil {
+		return fmt.Errorf("failed to validate many topics config: %w", err)
+	}
+
+	tenants := make(map[string]bool, len(c.Tenants))
+	for i, tenant := range c.Tenants {
+		if err := tenant.Validate(); err != nil {
+			return fmt.Errorf("failed to validate tenant at index %d: %w", i, err)
+		}
+		if tenants[tenant.Name] {
+			return fmt.Errorf("tenant name '%v' is not unique", tenant.Name)
+		}
+		tenants[tenant.Name] = true
+	}
+
+	sales := make(map[string]bool, len(c.Sales))
+	for i, sale := range c.Sales {
+		if err := sale.Validate(); err != nil {
+			return fmt.Errorf("failed to validate sale at index %d: %w", i, err)
+		}
+		if sales[sale.Name] {
+			return fmt.Errorf("sale name '%v' is not unique", sale.Name)
+		}
+		sales[sale.Name] = true
+	}
+
+	for name, generator := range c.Generators {
+		if err := generator.Validate(); err != nil {
+			return fmt.Errorf("failed to validate %v generator config: %w", name, err)
+		}
+	}
+
+	if err := c.Scenario.Validate(); err != nil {
+		return fmt.Errorf("failed to validate scenario config: %w", err)
+	}
+
+	if err := c.Services.Validate(); err != nil {
+		return fmt.Errorf("failed to validate services config: %w", err)
+	}
+
+	if err := c.SchemaEvolution.Validate(); err != nil {
+		return fmt.Errorf("failed to validate schema evolution config: %w", err)
+	}
+
+	if err := c.SchemaRegistryFailures.Validate(); err != nil {
+		return fmt.Errorf("failed to validate schema registry failures config: %w", err)
+	}
+
+	if err := c.AdminAPI.Validate(); err != nil {
+		return fmt.Errorf("failed to validate admin api config: %w", err)
+	}
+
+	if c.SchemaEvolution.Enabled && c.Services.Order.Serde != SerdeAvro {
+		return fmt.Errorf("schema evolution requires the order service to use the '%v' serde", SerdeAvro)
+	}
+
+	if c.AvroLogicalTypes.Enabled && c.Services.Order.Serde != SerdeAvro {
+		return fmt.Errorf("avro logical types require the order service to use the '%v' serde", SerdeAvro)
+	}
+
 	return nil
 }
+
+// TopicName returns the given topic name with the topic prefix.
+func (c *Shop) TopicName(name string) string {
+	return c.TopicNamePrefix() + name
+}
+
+// TopicNamePrefix returns the topic prefix, which defaults to the global
+// prefix.
+func (c *Shop) TopicNamePrefix() string {
+	if c.TopicPrefix != "" {
+		return c.TopicPrefix
+	}
+	return c.GlobalPrefix
+}
+
+// GroupID returns the given consumer group name with the group prefix.
+func (c *Shop) GroupID(name string) string {
+	return c.GroupIDPrefix() + name
+}
+
+// GroupIDPrefix returns the group prefix, which defaults to the global
+// prefix.
+func (c *Shop) GroupIDPrefix() string {
+	if c.GroupPrefix != "" {
+		return c.GroupPrefix
+	}
+	return c.GlobalPrefix
+}
+
+// SubjectName returns the value subject of the given topic, whose name is
+// passed without the topic prefix, according to the topic's subject name
+// strategy. The record name is the fully qualified name of the value schema's
+// record, e.g. "shop.v1.Order". Like the Confluent strategies, the record and
+// topic record strategies don't use the subject prefix.
+func (c *Shop) SubjectName(topic string, recordName string) string {
+	switch c.Topics[topic].SubjectNameStrategy {
+	case SubjectNameStrategyRecord:
+		return recordName
+	case SubjectNameStrategyTopicRecord:
+		return c.TopicName(topic) + "-" + recordName
+	default:
+		return c.SubjectNamePrefix() + topic + "-value"
+	}
+}
+
+// SubjectNamePrefix returns the subject prefix, which defaults to the topic
+// prefix.
+func (c *Shop) SubjectNamePrefix() string {
+	if c.SubjectPrefix != "" {
+		return c.SubjectPrefix
+	}
+	return c.TopicNamePrefix()
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+)
+
+// AdminAPI configures the HTTP admin API, which allows to change the traffic
+// simulation (request rate, event weights, pause/resume) at runtime. Unless
+// it has a listen address of its own, it is served alongside the metrics
+// endpoint.
+type AdminAPI struct {
+	Enabled bool `yaml:"enabled"`
+
+	// ListenAddress is the address of a dedicated listener for the admin API.
+	// If empty, the admin API is served by the metrics listener.
+	ListenAddress string `yaml:"listenAddress"`
+
+	// TLS configures the dedicated listener. It is ignored if the admin API is
+	// served by the metrics listener.
+	TLS HTTPServerTLS `yaml:"tls"`
+}
+
+// SetDefaults for admin api config.
+func (c *AdminAPI) SetDefaults() {
+	c.Enabled = false
+	c.ListenAddress = ""
+}
+
+// Validate admin api config.
+func (c *AdminAPI) Validate() error {
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("failed to validate tls config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package config
+
+// AvroLogicalTypes configures the enrichment of the Avro order schema with
+// logical types and complex fields. If enabled, the order schema has
+// additional fields of the uuid, decimal and timestamp-millis logical types,
+// an enum, a map and an array, all of them nullable or with an empty
+// default, so that deserializers and UIs are tested against the full breadth
+// of Avro types. The added fields duplicate the simple fields of the order,
+// e.g. the order value as decimal of the currency's major unit.
+type AvroLogicalTypes struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// SetDefaults for avro logical types config.
+func (c *AvroLogicalTypes) SetDefaults() {
+	c.Enabled = false
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+)
+
+// B2B configures the wholesale mode of the shop, which mimics a wholesale
+// platform rather than a consumer shop. Registered customers are companies,
+// orders are fewer but much larger, referenced by a purchase order number and
+// paid by invoice within the payment terms. The payment service produces the
+// lifecycle of each invoice to the invoices topic.
+type B2B struct {
+	Enabled bool `yaml:"enabled"`
+
+	// OrderRatio is the share of simulated order creations that place a
+	// wholesale order.
+	OrderRatio float64 `yaml:"orderRatio"`
+
+	// MinQuantityFactor is the minimum factor by which the quantities of a
+	// wholesale order's line items are multiplied.
+	MinQuantityFactor int `yaml:"minQuantityFactor"`
+
+	// MaxQuantityFactor is the maximum factor by which the quantities of a
+	// wholesale order's line items are multiplied. The factor is capped, so
+	// that the order value does not exceed the max 32-bit integer.
+	MaxQuantityFactor int `yaml:"maxQuantityFactor"`
+
+	// PaymentTermDays is the number of days after which an invoice is due,
+	// e.g. 30 for net-30 payment terms.
+	PaymentTermDays int `yaml:"paymentTermDays"`
+
+	// LatePaymentRatio is the share of invoices that become overdue before
+	// they are paid.
+	LatePaymentRatio float64 `yaml:"latePaymentRatio"`
+}
+
+// SetDefaults for b2b config.
+func (c *B2B) SetDefaults() {
+	c.Enabled = false
+	c.OrderRatio = 0.1
+	c.MinQuantityFactor = 10
+	c.MaxQuantityFactor = 100
+	c.PaymentTermDays = 30
+	c.LatePaymentRatio = 0.15
+}
+
+// Validate b2b config.
+func (c *B2B) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.OrderRatio <= 0 || c.OrderRatio > 1 {
+		return fmt.Errorf("order ratio must be greater than 0 and at most 1")
+	}
+
+	if c.MinQuantityFactor <= 0 {
+		return fmt.Errorf("min quantity factor must be greater than 0")
+	}
+
+	if c.MaxQuantityFactor < c.MinQuantityFactor {
+		return fmt.Errorf("max quantity factor must be greater than or equal to the min quantity factor")
+	}
+
+	if c.PaymentTermDays <= 0 {
+		return fmt.Errorf("payment term days must be greater than 0")
+	}
+
+	if c.LatePaymentRatio < 0 || c.LatePaymentRatio > 1 {
+		return fmt.Errorf("late payment ratio must be between 0 and 1")
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Backfill configures the historical backfill that is performed once upon
+// startup, before the live traffic simulation starts. The backfilled records
+// carry timestamps in the past, so that time-based retention, timestamp based
+// offset lookups and time-window queries can be demonstrated right away.
+type Backfill struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Days is the number of days in the past at which the backfill starts.
+	// The page impressions are evenly distributed from then until now.
+	Days int `yaml:"days"`
+
+	// PageImpressions is the total number of page impressions that are
+	// simulated during the backfill.
+	PageImpressions int `yaml:"pageImpressions"`
+}
+
+// SetDefaults for backfill config.
+func (c *Backfill) SetDefaults() {
+	c.Enabled = false
+	c.Days = 7
+	c.PageImpressions = 10000
+}
+
+// Validate backfill config.
+func (c *Backfill) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Days <= 0 {
+		return fmt.Errorf("days must be a positive integer")
+	}
+	if c.PageImpressions <= 0 {
+		return fmt.Errorf("page impressions must be a positive integer")
+	}
+
+	return nil
+}
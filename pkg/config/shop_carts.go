@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Carts configures the simulated shopping carts. Carts are created and
+// updated by simulated page impressions and are either checked out or
+// abandoned eventually.
+type Carts struct {
+	// AbandonAfter is the duration without any change after which a cart is
+	// abandoned.
+	AbandonAfter time.Duration `yaml:"abandonAfter"`
+}
+
+// SetDefaults for carts config.
+func (c *Carts) SetDefaults() {
+	c.AbandonAfter = 10 * time.Minute
+}
+
+// Validate carts config.
+func (c *Carts) Validate() error {
+	if c.AbandonAfter <= 0 {
+		return fmt.Errorf("abandon after must be a positive duration (e.g. '10m')")
+	}
+
+	return nil
+}
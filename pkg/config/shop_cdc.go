@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CDC configures the emission of Debezium-style change events for customers,
+// addresses and orders, as if the shop's database was captured by a Debezium
+// connector. The change events are produced to dedicated topics, so that the
+// topics of the services are not affected.
+type CDC struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Server is the logical name of the captured database server, which
+	// prefixes the topics like Debezium's topic.prefix. The topics are named
+	// <server>.public.<table>, e.g. cdc.public.customers, and are prefixed
+	// with the topic prefix.
+	Server string `yaml:"server"`
+}
+
+// SetDefaults for cdc config.
+func (c *CDC) SetDefaults() {
+	c.Enabled = false
+	c.Server = "cdc"
+}
+
+// Validate cdc config.
+func (c *CDC) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Server == "" || strings.ContainsAny(c.Server, " /") {
+		return fmt.Errorf("server must be a non-empty topic name prefix")
+	}
+
+	return nil
+}
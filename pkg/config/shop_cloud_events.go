@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	// CloudEventsModeBinary keeps the record value and maps the CloudEvents
+	// attributes to ce_ prefixed headers.
+	CloudEventsModeBinary = "binary"
+	// CloudEventsModeStructured wraps the record value in a JSON encoded
+	// CloudEvent.
+	CloudEventsModeStructured = "structured"
+)
+
+// CloudEvents configures the CloudEvents 1.0 Kafka protocol binding of all
+// produced records, so that the shop can feed systems that expect CloudEvents
+// (e.g. Knative or Dapr). The shop's own consumers unwrap structured events
+// before they decode the records. Tombstones are never wrapped.
+type CloudEvents struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Mode is the content mode of the binding, either binary or structured.
+	Mode string `yaml:"mode"`
+
+	// TypePrefix is prepended to the lower-cased event type of each record to
+	// form the CloudEvents type, e.g. "com.owlshop.order_created".
+	TypePrefix string `yaml:"typePrefix"`
+}
+
+// SetDefaults for cloud events config.
+func (c *CloudEvents) SetDefaults() {
+	c.Enabled = false
+	c.Mode = CloudEventsModeBinary
+	c.TypePrefix = "com.owlshop."
+}
+
+// Validate cloud events config.
+func (c *CloudEvents) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Mode {
+	case CloudEventsModeBinary, CloudEventsModeStructured:
+	default:
+		return fmt.Errorf("mode must be either '%v' or '%v'", CloudEventsModeBinary, CloudEventsModeStructured)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// CommitStrategyAuto commits the offsets of the polled records in the
+	// auto commit interval.
+	CommitStrategyAuto = "auto"
+	// CommitStrategyBatch commits the offsets of each polled batch once it
+	// has been processed.
+	CommitStrategyBatch = "batch"
+	// CommitStrategyRebalance only commits offsets when partitions are
+	// revoked, i.e. on rebalances and when the service stops.
+	CommitStrategyRebalance = "rebalance"
+
+	StartOffsetEarliest  = "earliest"
+	StartOffsetLatest    = "latest"
+	StartOffsetTimestamp = "timestamp"
+)
+
+// ConsumerOffsets configures how a service's consumer group commits its
+// offsets and where it starts consuming partitions without a committed
+// offset.
+type ConsumerOffsets struct {
+	// CommitStrategy is one of auto, batch or rebalance. Defaults to auto.
+	CommitStrategy string `yaml:"commitStrategy"`
+
+	// AutoCommitInterval is the interval of the auto commit strategy.
+	// Defaults to 500ms.
+	AutoCommitInterval time.Duration `yaml:"autoCommitInterval"`
+
+	// StartOffset is one of earliest, latest or timestamp. Defaults to
+	// earliest.
+	StartOffset string `yaml:"startOffset"`
+
+	// StartTimestamp is the RFC 3339 timestamp from which the timestamp
+	// start offset consumes, e.g. 2023-07-01T12:00:00Z.
+	StartTimestamp string `yaml:"startTimestamp"`
+}
+
+// SetDefaults for consumer offsets config.
+func (c *ConsumerOffsets) SetDefaults() {
+	c.CommitStrategy = CommitStrategyAuto
+	c.AutoCommitInterval = 500 * time.Millisecond
+	c.StartOffset = StartOffsetEarliest
+}
+
+// Validate consumer offsets config.
+func (c *ConsumerOffsets) Validate() error {
+	switch c.CommitStrategy {
+	case CommitStrategyAuto:
+		if c.AutoCommitInterval <= 0 {
+			return fmt.Errorf("auto commit interval must be greater than 0")
+		}
+	case CommitStrategyBatch, CommitStrategyRebalance:
+	default:
+		return fmt.Errorf("given commit strategy '%v' is invalid, valid strategies are auto, batch and rebalance", c.CommitStrategy)
+	}
+
+	switch c.StartOffset {
+	case StartOffsetEarliest, StartOffsetLatest:
+	case StartOffsetTimestamp:
+		if _, err := c.StartTime(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("given start offset '%v' is invalid, valid start offsets are earliest, latest and timestamp", c.StartOffset)
+	}
+
+	return nil
+}
+
+// StartTime returns the parsed start timestamp.
+func (c *ConsumerOffsets) StartTime() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, c.StartTimestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse start timestamp: %w", err)
+	}
+
+	return t, nil
+}
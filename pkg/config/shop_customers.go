@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Customers configures how customer deletions are simulated. Deleted customers
+// are published as tombstones to the compacted customers topic, so that log
+// compaction and GDPR deletion workflows can be demonstrated.
+type Customers struct {
+	// TombstoneInterval is the interval in which an existing customer is
+	// deleted, in addition to the deletions that are triggered by the
+	// deleteCustomer event weight. This produces tombstones at a fixed
+	// frequency, regardless of the simulated traffic. 0 disables the
+	// periodic deletions.
+	TombstoneInterval time.Duration `yaml:"tombstoneInterval"`
+
+	// CascadeDeletes makes the address service produce tombstones for all
+	// addresses of a deleted customer, once it consumes the customer's
+	// tombstone.
+	CascadeDeletes bool `yaml:"cascadeDeletes"`
+
+	// ChangeStream makes the customer service produce all changes of customers
+	// as append-only events to the customer-changes topic rather than to the
+	// customers topic. An internal consumer applies the changes to the
+	// compacted customers topic, which thus holds the latest state of each
+	// customer.
+	ChangeStream bool `yaml:"changeStream"`
+
+	// RegistrySize is the max number of customers that the order service
+	// keeps track of, so that orders are placed by existing customers. Once
+	// the registry is full, new customers replace random existing ones.
+	RegistrySize int `yaml:"registrySize"`
+
+	// Whales attributes a share of the orders to a small set of customers.
+	Whales Whales `yaml:"whales"`
+
+	// Loyalty enriches the customers with segments and loyalty tiers, which
+	// evolve as orders are placed.
+	Loyalty Loyalty `yaml:"loyalty"`
+}
+
+// Whales configures a small set of customers that place a disproportionate
+// share of all orders, which makes their keys hot and skews the partitions of
+// all customer keyed topics.
+type Whales struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Count is the number of whale customers. The first customers that are
+	// tracked by the order service become whales. Deleted whales are
+	// replaced by the next customer that is created or modified.
+	Count int `yaml:"count"`
+
+	// TrafficRatio is the share of orders that are placed by one of the
+	// whales rather than by a random customer.
+	TrafficRatio float64 `yaml:"trafficRatio"`
+}
+
+// SetDefaults for customers config.
+func (c *Customers) SetDefaults() {
+	c.TombstoneInterval = 0
+	c.CascadeDeletes = true
+	c.ChangeStream = false
+	c.RegistrySize = 10000
+	c.Whales.SetDefaults()
+	c.Loyalty.SetDefaults()
+}
+
+// Validate customers config.
+func (c *Customers) Validate() error {
+	if c.TombstoneInterval < 0 {
+		return fmt.Errorf("tombstone interval must not be negative")
+	}
+
+	if c.RegistrySize <= 0 {
+		return fmt.Errorf("registry size must be greater than 0")
+	}
+
+	if err := c.Whales.Validate(); err != nil {
+		return fmt.Errorf("failed to validate whales config: %w", err)
+	}
+
+	if err := c.Loyalty.Validate(); err != nil {
+		return fmt.Errorf("failed to validate loyalty config: %w", err)
+	}
+
+	return nil
+}
+
+// SetDefaults for whales config.
+func (c *Whales) SetDefaults() {
+	c.Enabled = false
+	c.Count = 3
+	c.TrafficRatio = 0.5
+}
+
+// Validate whales config.
+func (c *Whales) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Count <= 0 {
+		return fmt.Errorf("count must be greater than 0")
+	}
+
+	if c.TrafficRatio < 0 || c.TrafficRatio > 1 {
+		return fmt.Errorf("traffic ratio must be between 0 and 1")
+	}
+
+	return nil
+}
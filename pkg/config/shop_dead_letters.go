@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeadLetterTopics are the topics, without the topic prefix, into which
+// poison messages can be injected.
+var DeadLetterTopics = []string{"customers", "frontend-events", "products"}
+
+// DeadLetters configures the injection of poison messages. If enabled, a
+// malformed record (truncated payload, unknown schema id or invalid magic
+// byte) is produced into the configured topic in each interval. A dead letter
+// service consumes the topic and routes all records that can't be decoded to
+// the dead letter queue topic, so that DLQ tooling and error handling patterns
+// can be demonstrated.
+type DeadLetters struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Topic is the name of the topic, without the topic prefix, into which
+	// poison messages are injected. Must be one of DeadLetterTopics.
+	Topic string `yaml:"topic"`
+
+	// Interval is the interval in which a poison message is injected.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SetDefaults for dead letters config.
+func (c *DeadLetters) SetDefaults() {
+	c.Enabled = false
+	c.Topic = "frontend-events"
+	c.Interval = 10 * time.Second
+}
+
+// Validate dead letters config.
+func (c *DeadLetters) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	supported := false
+	for _, topic := range DeadLetterTopics {
+		if c.Topic == topic {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("topic '%v' is not supported, must be one of %v", c.Topic, DeadLetterTopics)
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be a positive duration (e.g. '10s')")
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Duplicates configures the injection of duplicate records. Idempotent
+// producers make sure that retried produce requests are not written twice.
+// Without idempotence, a produce request whose acknowledgement has been lost
+// is retried and written again, which is what the injected duplicates mimic.
+type Duplicates struct {
+	// Enabled disables the idempotence of the producers of all services and
+	// re-sends a share of the acknowledged records.
+	Enabled bool `yaml:"enabled"`
+
+	// Ratio is the share of acknowledged records that are re-sent.
+	Ratio float64 `yaml:"ratio"`
+}
+
+// SetDefaults for duplicates config.
+func (c *Duplicates) SetDefaults() {
+	c.Enabled = false
+	c.Ratio = 0.01
+}
+
+// Validate duplicates config.
+func (c *Duplicates) Validate() error {
+	if c.Ratio < 0 || c.Ratio > 1 {
+		return fmt.Errorf("ratio must be between 0 and 1")
+	}
+
+	return nil
+}
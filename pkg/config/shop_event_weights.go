@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+)
+
+// EventWeights are the relative weights of the events that are triggered by
+// simulated page impressions. Each page impression triggers exactly one event,
+// so that the weights can be used to shape the traffic, e.g. an orders-heavy
+// or a churn-heavy simulation. An event with a weight of 0 is never triggered.
+type EventWeights struct {
+	CreateFrontendEvent uint `yaml:"createFrontendEvent"`
+	CreateCustomer      uint `yaml:"createCustomer"`
+	CreateAddress       uint `yaml:"createAddress"`
+	ModifyAddress       uint `yaml:"modifyAddress"`
+	DeleteCustomer      uint `yaml:"deleteCustomer"`
+	ModifyCustomer      uint `yaml:"modifyCustomer"`
+	CreateOrder         uint `yaml:"createOrder"`
+	ModifyProduct       uint `yaml:"modifyProduct"`
+	CreateProduct       uint `yaml:"createProduct"`
+	ReleaseStock        uint `yaml:"releaseStock"`
+	CreateReview        uint `yaml:"createReview"`
+	ModifyReview        uint `yaml:"modifyReview"`
+	DeleteReview        uint `yaml:"deleteReview"`
+	CreateCart          uint `yaml:"createCart"`
+	UpdateCart          uint `yaml:"updateCart"`
+}
+
+// SetDefaults for event weights config.
+func (c *EventWeights) SetDefaults() {
+	c.CreateFrontendEvent = 1000
+	c.CreateCustomer = 50
+	c.CreateAddress = 30
+	c.ModifyAddress = 4
+	c.DeleteCustomer = 8
+	c.ModifyCustomer = 6
+	c.CreateOrder = 5
+	c.ModifyProduct = 3
+	c.CreateProduct = 1
+	c.ReleaseStock = 4
+	c.CreateReview = 3
+	c.ModifyReview = 1
+	c.DeleteReview = 1
+	c.CreateCart = 5
+	c.UpdateCart = 20
+}
+
+// Validate event weights config.
+func (c *EventWeights) Validate() error {
+	total := c.CreateFrontendEvent + c.CreateCustomer + c.CreateAddress + c.ModifyAddress + c.DeleteCustomer + c.ModifyCustomer +
+		c.CreateOrder + c.ModifyProduct + c.CreateProduct + c.ReleaseStock + c.CreateReview + c.ModifyReview +
+		c.DeleteReview + c.CreateCart + c.UpdateCart
+	if total == 0 {
+		return fmt.Errorf("at least one event weight must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+)
+
+// ExactlyOnce configures the exactly-once mode of the payment service. If
+// enabled, the payment events of each polled batch of orders are produced in
+// a single transaction, which also commits the offsets of the consumed orders
+// (consume-transform-produce). A read_committed consumer of the payments topic
+// therefore sees the payments of each order exactly once, even if the payment
+// service is restarted or its group rebalances. Some transactions are aborted
+// on purpose, after which their orders are consumed and processed again.
+// The transactions are produced by the transactional client itself, so the
+// payment service can't use the http producer protocol, producer failures or
+// the outage tolerance of its cluster.
+type ExactlyOnce struct {
+	Enabled bool `yaml:"enabled"`
+
+	// AbortRatio is the share of transactions that are aborted, in the
+	// range [0, 1].
+	AbortRatio float64 `yaml:"abortRatio"`
+}
+
+// SetDefaults for exactly-once config.
+func (c *ExactlyOnce) SetDefaults() {
+	c.Enabled = false
+	c.AbortRatio = 0
+}
+
+// Validate exactly-once config.
+func (c *ExactlyOnce) Validate() error {
+	if c.AbortRatio < 0 || c.AbortRatio > 1 {
+		return fmt.Errorf("abort ratio must be between 0 and 1")
+	}
+
+	return nil
+}
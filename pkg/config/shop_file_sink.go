@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// FileSink configures the file sink, which writes a sample of all records
+// that have been successfully produced to Kafka to local files as well, so
+// that the generated events can be inspected and reused as a corpus for
+// offline tests without consuming from Kafka. Rotated files can be uploaded to
+// an object storage, so that corpora can be shared and the shop doesn't need
+// a persistent volume.
+type FileSink struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Directory that the files are written to. It is created if it does not
+	// exist.
+	Directory string `yaml:"directory"`
+
+	// Format of the files, either ndjson (one JSON object per line) or avro
+	// (Avro object container files). Both contain the same fields for each
+	// record.
+	Format string `yaml:"format"`
+
+	// SampleRatio is the share of produced records that are written.
+	SampleRatio float64 `yaml:"sampleRatio"`
+
+	// MaxFileBytes is the size after which a file is rotated.
+	MaxFileBytes int `yaml:"maxFileBytes"`
+
+	// MaxFiles is the number of rotated files that are kept in addition to
+	// the current one. The oldest files are deleted first. 0 keeps all files.
+	MaxFiles int `yaml:"maxFiles"`
+
+	// BufferSize is the number of records that are buffered while files are
+	// being written. Records are dropped if the buffer is full, so that a
+	// slow disk does not slow down the traffic to Kafka.
+	BufferSize int `yaml:"bufferSize"`
+
+	// UploadURL is the object storage location that each file is uploaded to
+	// once it has been rotated or the shop is stopped, e.g.
+	// s3://bucket/owlshop/. Uploaded files are deleted from the directory,
+	// so that max files only applies to files that failed to be uploaded.
+	// Files are not uploaded if it is empty.
+	UploadURL string `yaml:"uploadUrl"`
+
+	// UploadMaxRetries is the number of times a file is uploaded again if
+	// its upload failed. The backoff doubles with each retry.
+	UploadMaxRetries   int           `yaml:"uploadMaxRetries"`
+	UploadRetryBackoff time.Duration `yaml:"uploadRetryBackoff"`
+
+	// Storage configures the credentials of the object storages, which are
+	// used for uploading files as well as for replaying files from an object
+	// storage.
+	Storage ObjectStorage `yaml:"storage"`
+}
+
+// SetDefaults for file sink config.
+func (c *FileSink) SetDefaults() {
+	c.Enabled = false
+	c.Directory = "samples"
+	c.Format = "ndjson"
+	c.SampleRatio = 0.01
+	c.MaxFileBytes = 100 * 1024 * 1024
+	c.MaxFiles = 10
+	c.BufferSize = 10000
+	c.UploadMaxRetries = 3
+	c.UploadRetryBackoff = time.Second
+	c.Storage.SetDefaults()
+}
+
+// Validate file sink config.
+func (c *FileSink) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Directory == "" {
+		return fmt.Errorf("directory must be set")
+	}
+	if c.Format != "ndjson" && c.Format != "avro" {
+		return fmt.Errorf("format must be either ndjson or avro, but got '%v'", c.Format)
+	}
+	if c.SampleRatio <= 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample ratio must be greater than 0 and at most 1")
+	}
+	if c.MaxFileBytes <= 0 {
+		return fmt.Errorf("max file bytes must be a positive number")
+	}
+	if c.MaxFiles < 0 {
+		return fmt.Errorf("max files must not be negative")
+	}
+	if c.BufferSize <= 0 {
+		return fmt.Errorf("buffer size must be a positive number")
+	}
+	if c.UploadURL != "" {
+		u, err := url.Parse(c.UploadURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse upload url: %w", err)
+		}
+		if (u.Scheme != "s3" && u.Scheme != "gs" && u.Scheme != "azblob") || u.Host == "" {
+			return fmt.Errorf("upload url must be an s3://, gs:// or azblob:// url with a bucket")
+		}
+	}
+	if c.UploadMaxRetries < 0 {
+		return fmt.Errorf("upload max retries must not be negative")
+	}
+	if c.UploadRetryBackoff < 0 {
+		return fmt.Errorf("upload retry backoff must not be negative")
+	}
+
+	return nil
+}
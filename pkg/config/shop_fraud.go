@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Fraud configures the simulated fraud detection of the order service, which
+// scores each order that is created by the createOrder event. A share of these
+// orders is suspicious on purpose, so that the fraud signals are a labeled
+// anomaly stream for ML and streaming analytics demos.
+type Fraud struct {
+	// Enabled produces a fraud signal for each order to the fraud-signals
+	// topic.
+	Enabled bool `yaml:"enabled"`
+
+	// SuspiciousRatio is the share of orders that are suspicious, because of
+	// a country mismatch, an unusually high order value or rapid-fire orders
+	// of the same customer.
+	SuspiciousRatio float64 `yaml:"suspiciousRatio"`
+
+	// RapidFireOrders is the number of orders that a customer places in quick
+	// succession in case of rapid-fire orders.
+	RapidFireOrders int `yaml:"rapidFireOrders"`
+}
+
+// SetDefaults for fraud config.
+func (c *Fraud) SetDefaults() {
+	c.Enabled = false
+	c.SuspiciousRatio = 0.02
+	c.RapidFireOrders = 5
+}
+
+// Validate fraud config.
+func (c *Fraud) Validate() error {
+	if c.SuspiciousRatio < 0 || c.SuspiciousRatio > 1 {
+		return fmt.Errorf("suspicious ratio must be between 0 and 1")
+	}
+
+	if c.RapidFireOrders < 2 {
+		return fmt.Errorf("rapid-fire orders must be at least 2")
+	}
+
+	return nil
+}
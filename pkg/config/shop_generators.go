@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Generator configures a custom event generator, which has been registered
+// with the shop package by a plugin under the same name or, if the exec
+// command is set, runs as an external process. Only registered generators
+// that are configured are created.
+type Generator struct {
+	// Weight of the generator's events among the event weights of the
+	// built-in services. Defaults to 0, so that the generator only runs its
+	// background tasks unless a weight is set.
+	Weight uint `yaml:"weight"`
+
+	// Cluster is the name of the Kafka cluster the generator produces to.
+	// Defaults to the default cluster.
+	Cluster string `yaml:"cluster"`
+
+	// Producer overrides the producer config of the Kafka config for the
+	// generator's clients.
+	Producer Producer `yaml:"producer"`
+
+	// Settings are passed to the generator as they are, so that each
+	// generator can define its own config.
+	Settings map[string]interface{} `yaml:"settings"`
+
+	// Exec runs the generator as a subprocess, which receives requests and
+	// responds with the records to produce as JSON lines over stdin and
+	// stdout.
+	Exec GeneratorExec `yaml:"exec"`
+}
+
+// GeneratorExec configures the subprocess of an external generator.
+type GeneratorExec struct {
+	// Command is the path of the executable. If empty, the generator must
+	// have been registered by a plugin.
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+
+	// Env are additional environment variables of the subprocess, which
+	// inherits the environment of Owl Shop.
+	Env map[string]string `yaml:"env"`
+
+	// Timeout of each request to the subprocess. Defaults to 10s.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Validate generator config.
+func (c *Generator) Validate() error {
+	if err := c.Producer.Validate(); err != nil {
+		return fmt.Errorf("failed to validate producer config: %w", err)
+	}
+
+	if c.Exec.Timeout < 0 {
+		return fmt.Errorf("exec timeout must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Headers configures the headers that are added to every produced record, so
+// that header inspection and header based routing can be demonstrated. The
+// standard headers are event_type, source (the producing service), version and
+// the W3C traceparent. Headers that a record carries already are kept.
+type Headers struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Version is the value of the version header, e.g. to demonstrate routing
+	// by event version.
+	Version string `yaml:"version"`
+
+	// TraceContext adds a W3C traceparent header. Records that are produced in
+	// reaction to a consumed record continue the trace of that record.
+	TraceContext bool `yaml:"traceContext"`
+
+	// Static headers that are added to every record in addition to the
+	// standard headers.
+	Static map[string]string `yaml:"static"`
+}
+
+// SetDefaults for headers config.
+func (c *Headers) SetDefaults() {
+	c.Enabled = true
+	c.Version = "1"
+	c.TraceContext = true
+}
+
+// Validate headers config.
+func (c *Headers) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	for key := range c.Static {
+		if key == "" {
+			return fmt.Errorf("static header keys must not be empty")
+		}
+	}
+
+	return nil
+}
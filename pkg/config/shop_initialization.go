@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Initialization configures how the shop's components are initialized upon
+// startup (topic creation, schema registration). Failed initializations are
+// retried with an exponential backoff, so that the shop tolerates a Kafka
+// cluster or schema registry that is not yet ready, which is common with
+// docker-compose and Kubernetes startup ordering.
+type Initialization struct {
+	// AttemptTimeout is the timeout of a single initialization attempt of a
+	// component.
+	AttemptTimeout time.Duration `yaml:"attemptTimeout"`
+
+	// MaxAttempts is the number of attempts to initialize each component,
+	// before the startup fails. 0 means unlimited attempts. It does not apply
+	// to the initialization in the background, which is retried until the
+	// shop is stopped.
+	MaxAttempts int `yaml:"maxAttempts"`
+
+	// InitialBackoff is the duration after the first failed attempt, which is
+	// doubled after each further failed attempt.
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+
+	// MaxBackoff is the maximum duration between two attempts.
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+
+	// InBackground starts the shop without waiting for its components to be
+	// initialized. The components are initialized in the background, the
+	// services and the traffic simulation are started once all components
+	// have been initialized. The readiness probe fails until then.
+	InBackground bool `yaml:"inBackground"`
+}
+
+// SetDefaults for initialization config.
+func (c *Initialization) SetDefaults() {
+	c.AttemptTimeout = time.Minute
+	c.MaxAttempts = 5
+	c.InitialBackoff = time.Second
+	c.MaxBackoff = 30 * time.Second
+	c.InBackground = false
+}
+
+// Validate initialization config.
+func (c *Initialization) Validate() error {
+	if c.AttemptTimeout <= 0 {
+		return fmt.Errorf("attempt timeout must be a positive duration (e.g. '1m')")
+	}
+
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must not be negative")
+	}
+
+	if c.InitialBackoff <= 0 {
+		return fmt.Errorf("initial backoff must be a positive duration (e.g. '1s')")
+	}
+
+	if c.MaxBackoff < c.InitialBackoff {
+		return fmt.Errorf("max backoff must be greater than or equal to the initial backoff")
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import "fmt"
+
+// Integrity configures the sequence numbers that are embedded in the headers
+// of all produced records with a key. The sequence numbers increase
+// monotonically per producing client, topic and key, so that the verifier of this or
+// any other shop can detect lost, duplicated and reordered records, e.g.
+// while a cluster is upgraded or fails over.
+type Integrity struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MaxKeys is the number of keys whose sequence numbers are tracked by the
+	// producers and the verifier. The limit applies to each producing
+	// client. The least recently used key is evicted once it is exceeded
+	// and its sequence restarts at 1 under a new producer id, which the
+	// verifier treats as a new baseline. Defaults to 1000000.
+	MaxKeys int `yaml:"maxKeys"`
+
+	// FailOnViolations makes the shop fail on shutdown if the verifier has
+	// detected any violation, so that the process exits with code 3.
+	FailOnViolations bool `yaml:"failOnViolations"`
+}
+
+// SetDefaults for integrity config.
+func (c *Integrity) SetDefaults() {
+	c.MaxKeys = 1000000
+}
+
+// Validate integrity config.
+func (c *Integrity) Validate() error {
+	if c.MaxKeys <= 0 {
+		return fmt.Errorf("max keys must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+)
+
+// LargeMessages configures the product media records, which embed a long
+// product description and a base64 encoded product image. Their sizes are
+// spread between the configured bounds, so that max.message.bytes, fetch
+// sizing and the truncation of large records can be tested.
+type LargeMessages struct {
+	// Enabled produces product media records to the product-media topic.
+	Enabled bool `yaml:"enabled"`
+
+	// Ratio is the share of created and modified products for which a
+	// product media record is produced.
+	Ratio float64 `yaml:"ratio"`
+
+	// MinBytes is the min size of a product media record's value.
+	MinBytes int `yaml:"minBytes"`
+
+	// MaxBytes is the max size of a product media record's value. The
+	// product-media topic's max.message.bytes and the producer's max batch
+	// size are raised accordingly.
+	MaxBytes int `yaml:"maxBytes"`
+}
+
+// SetDefaults for large messages config.
+func (c *LargeMessages) SetDefaults() {
+	c.Enabled = false
+	c.Ratio = 0.05
+	c.MinBytes = 100 * 1024
+	c.MaxBytes = 1024 * 1024
+}
+
+// Validate large messages config.
+func (c *LargeMessages) Validate() error {
+	if c.Ratio < 0 || c.Ratio > 1 {
+		return fmt.Errorf("ratio must be between 0 and 1")
+	}
+
+	if c.MinBytes <= 0 {
+		return fmt.Errorf("min bytes must be greater than 0")
+	}
+
+	if c.MaxBytes < c.MinBytes {
+		return fmt.Errorf("max bytes must not be less than min bytes")
+	}
+
+	// Leaves room for the record batch overhead below the max batch size of
+	// the Kafka protocol
+	if c.MaxBytes > 100*1024*1024 {
+		return fmt.Errorf("max bytes must not exceed 100 MiB")
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// LateRecords configures the injection of late records. A late record keeps
+// the timestamp of its event, but is held back for its lateness before it is
+// produced, so that the record arrives after records with a later timestamp.
+// This allows for demonstrating watermarks and the handling of late data in
+// stream processors that use the record timestamps as event time.
+type LateRecords struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Ratio is the share of produced records that are late.
+	Ratio float64 `yaml:"ratio"`
+
+	// MinLateness is the min duration of simulated time for which a late
+	// record is held back.
+	MinLateness time.Duration `yaml:"minLateness"`
+
+	// MaxLateness is the max duration of simulated time for which a late
+	// record is held back.
+	MaxLateness time.Duration `yaml:"maxLateness"`
+}
+
+// SetDefaults for late records config.
+func (c *LateRecords) SetDefaults() {
+	c.Enabled = false
+	c.Ratio = 0.05
+	c.MinLateness = 1 * time.Minute
+	c.MaxLateness = 5 * time.Minute
+}
+
+// Validate late records config.
+func (c *LateRecords) Validate() error {
+	if c.Ratio < 0 || c.Ratio > 1 {
+		return fmt.Errorf("ratio must be between 0 and 1")
+	}
+
+	if c.MinLateness <= 0 {
+		return fmt.Errorf("min lateness must be greater than 0")
+	}
+
+	if c.MaxLateness < c.MinLateness {
+		return fmt.Errorf("max lateness must not be less than min lateness")
+	}
+
+	return nil
+}
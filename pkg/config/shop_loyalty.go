@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Loyalty configures the customer segments and the loyalty program. The
+// customer service consumes the orders and updates the segment, loyalty tier,
+// points and lifetime value of each ordering customer. The earned points are
+// produced to the loyalty-points topic.
+type Loyalty struct {
+	Enabled bool `yaml:"enabled"`
+
+	// PointsPerUnit is the number of loyalty points that are earned per unit
+	// of the base currency, e.g. per dollar.
+	PointsPerUnit int `yaml:"pointsPerUnit"`
+
+	// ReturningOrders is the number of orders after which a customer is in
+	// the returning segment rather than in the new one.
+	ReturningOrders int `yaml:"returningOrders"`
+
+	// VIPLifetimeValue is the lifetime value in minor units of the base
+	// currency from which on a customer is in the VIP segment.
+	VIPLifetimeValue int `yaml:"vipLifetimeValue"`
+
+	// Tiers are the loyalty points that are required for each tier above
+	// bronze.
+	Tiers LoyaltyTiers `yaml:"tiers"`
+}
+
+// LoyaltyTiers are the loyalty points from which on a customer reaches a
+// tier.
+type LoyaltyTiers struct {
+	Silver   int `yaml:"silver"`
+	Gold     int `yaml:"gold"`
+	Platinum int `yaml:"platinum"`
+}
+
+// SetDefaults for loyalty config.
+func (c *Loyalty) SetDefaults() {
+	c.Enabled = false
+	c.PointsPerUnit = 1
+	c.ReturningOrders = 2
+	c.VIPLifetimeValue = 500000
+	c.Tiers = LoyaltyTiers{
+		Silver:   1000,
+		Gold:     5000,
+		Platinum: 20000,
+	}
+}
+
+// Validate loyalty config.
+func (c *Loyalty) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.PointsPerUnit <= 0 {
+		return fmt.Errorf("points per unit must be greater than 0")
+	}
+
+	if c.ReturningOrders <= 0 {
+		return fmt.Errorf("returning orders must be greater than 0")
+	}
+
+	if c.VIPLifetimeValue <= 0 {
+		return fmt.Errorf("vip lifetime value must be greater than 0")
+	}
+
+	if c.Tiers.Silver <= 0 || c.Tiers.Gold <= c.Tiers.Silver || c.Tiers.Platinum <= c.Tiers.Gold {
+		return fmt.Errorf("tiers must be positive and ascending from silver to platinum")
+	}
+
+	return nil
+}
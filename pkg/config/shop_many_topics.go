@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ManyTopics configures the topic count stress mode. If enabled, the
+// configured number of additional low traffic topics with shop related names
+// is created, e.g. owlshop-stress-payments-refunds-audit, and a small record
+// is produced into a random one of them in each interval. This allows to
+// stress test the pagination, search and metadata handling of Kafka tooling.
+type ManyTopics struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Cluster is the name of the Kafka cluster on which the topics are
+	// created. Defaults to the default cluster.
+	Cluster string `yaml:"cluster"`
+
+	// Count is the number of topics that are created.
+	Count int `yaml:"count"`
+
+	// PartitionCount of each topic.
+	PartitionCount int32 `yaml:"partitionCount"`
+
+	// Interval is the interval in which a record is produced into a random
+	// topic.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SetDefaults for many topics config.
+func (c *ManyTopics) SetDefaults() {
+	c.Enabled = false
+	c.Count = 1000
+	c.PartitionCount = 1
+	c.Interval = 100 * time.Millisecond
+}
+
+// Validate many topics config.
+func (c *ManyTopics) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Count <= 0 {
+		return fmt.Errorf("count must be greater than 0")
+	}
+
+	if c.PartitionCount <= 0 {
+		return fmt.Errorf("partition count must be greater than 0")
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be a positive duration (e.g. '100ms')")
+	}
+
+	return nil
+}
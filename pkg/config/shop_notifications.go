@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Notifications configures the notification service, which simulates the
+// outbox of a notification system. It consumes the orders, customers and
+// shipments and produces a notification for each order confirmation,
+// shipping update and password reset to the notifications topic. Each
+// notification is followed up by its delivery status.
+type Notifications struct {
+	Enabled bool `yaml:"enabled"`
+
+	// PasswordResetRatio is the share of the consumed customer changes that
+	// request a password reset.
+	PasswordResetRatio float64 `yaml:"passwordResetRatio"`
+
+	// FailureRatio is the share of notifications that fail to be sent.
+	FailureRatio float64 `yaml:"failureRatio"`
+
+	// BounceRatio is the share of the sent notifications that bounce rather
+	// than being delivered.
+	BounceRatio float64 `yaml:"bounceRatio"`
+
+	// MinStatusDelay is the minimum duration between two delivery status
+	// updates of the same notification.
+	MinStatusDelay time.Duration `yaml:"minStatusDelay"`
+
+	// MaxStatusDelay is the maximum duration between two delivery status
+	// updates of the same notification.
+	MaxStatusDelay time.Duration `yaml:"maxStatusDelay"`
+}
+
+// SetDefaults for notifications config.
+func (c *Notifications) SetDefaults() {
+	c.Enabled = false
+	c.PasswordResetRatio = 0.05
+	c.FailureRatio = 0.01
+	c.BounceRatio = 0.03
+	c.MinStatusDelay = time.Second
+	c.MaxStatusDelay = 30 * time.Second
+}
+
+// Validate notifications config.
+func (c *Notifications) Validate() error {
+	if c.PasswordResetRatio < 0 || c.PasswordResetRatio > 1 {
+		return fmt.Errorf("password reset ratio must be between 0 and 1")
+	}
+
+	if c.FailureRatio < 0 || c.FailureRatio > 1 {
+		return fmt.Errorf("failure ratio must be between 0 and 1")
+	}
+
+	if c.BounceRatio < 0 || c.BounceRatio > 1 {
+		return fmt.Errorf("bounce ratio must be between 0 and 1")
+	}
+
+	if c.MinStatusDelay < 0 {
+		return fmt.Errorf("min status delay must not be negative")
+	}
+
+	if c.MaxStatusDelay < c.MinStatusDelay {
+		return fmt.Errorf("max status delay must be greater than or equal to the min status delay")
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderCompensations configures the share of placed orders that are later
+// cancelled or refunded. Each cancellation or refund is produced as a
+// compensating event that references the original order, so that saga and
+// compensation patterns can be demonstrated.
+type OrderCompensations struct {
+	// CancelRatio is the share of placed orders that are cancelled later on.
+	CancelRatio float64 `yaml:"cancelRatio"`
+
+	// RefundRatio is the share of placed orders that are refunded later on.
+	RefundRatio float64 `yaml:"refundRatio"`
+
+	// MinDelay is the minimum duration between placing an order and its
+	// compensation.
+	MinDelay time.Duration `yaml:"minDelay"`
+
+	// MaxDelay is the maximum duration between placing an order and its
+	// compensation.
+	MaxDelay time.Duration `yaml:"maxDelay"`
+}
+
+// SetDefaults for order compensations config.
+func (c *OrderCompensations) SetDefaults() {
+	c.CancelRatio = 0
+	c.RefundRatio = 0
+	c.MinDelay = time.Minute
+	c.MaxDelay = 10 * time.Minute
+}
+
+// Validate order compensations config.
+func (c *OrderCompensations) Validate() error {
+	if c.CancelRatio < 0 || c.RefundRatio < 0 || c.CancelRatio+c.RefundRatio > 1 {
+		return fmt.Errorf("cancel and refund ratio must not be negative and must not exceed 1 in total")
+	}
+
+	if c.MinDelay < 0 {
+		return fmt.Errorf("min delay must not be negative")
+	}
+
+	if c.MaxDelay < c.MinDelay {
+		return fmt.Errorf("max delay must be greater than or equal to the min delay")
+	}
+
+	return nil
+}
+
+// Enabled returns whether any orders are compensated.
+func (c *OrderCompensations) Enabled() bool {
+	return c.CancelRatio > 0 || c.RefundRatio > 0
+}
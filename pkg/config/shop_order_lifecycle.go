@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderLifecycle configures the event-sourced lifecycle of simulated orders.
+// Each placed order passes through the created, confirmed, packed and shipped
+// states, unless it is cancelled on the way. Each transition is produced as an
+// event keyed by the order ID, so that the state of an order can be rebuilt by
+// replaying its events.
+type OrderLifecycle struct {
+	// Enabled produces the lifecycle events of each placed order to the
+	// order-events topic, in addition to the order snapshots on the orders
+	// topic.
+	Enabled bool `yaml:"enabled"`
+
+	// ConfirmRatio is the share of created orders that are confirmed. All
+	// other created orders are cancelled.
+	ConfirmRatio float64 `yaml:"confirmRatio"`
+
+	// PackRatio is the share of confirmed orders that are packed. All other
+	// confirmed orders are cancelled.
+	PackRatio float64 `yaml:"packRatio"`
+
+	// ShipRatio is the share of packed orders that are shipped. All other
+	// packed orders are cancelled.
+	ShipRatio float64 `yaml:"shipRatio"`
+
+	// MinStepDelay is the minimum duration between two consecutive events
+	// of the same order.
+	MinStepDelay time.Duration `yaml:"minStepDelay"`
+
+	// MaxStepDelay is the maximum duration between two consecutive events
+	// of the same order.
+	MaxStepDelay time.Duration `yaml:"maxStepDelay"`
+}
+
+// SetDefaults for order lifecycle config.
+func (c *OrderLifecycle) SetDefaults() {
+	c.Enabled = false
+	c.ConfirmRatio = 0.95
+	c.PackRatio = 0.97
+	c.ShipRatio = 0.99
+	c.MinStepDelay = 10 * time.Second
+	c.MaxStepDelay = time.Minute
+}
+
+// Validate order lifecycle config.
+func (c *OrderLifecycle) Validate() error {
+	ratios := map[string]float64{"confirm": c.ConfirmRatio, "pack": c.PackRatio, "ship": c.ShipRatio}
+	for name, ratio := range ratios {
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("%v ratio must be between 0 and 1", name)
+		}
+	}
+
+	if c.MinStepDelay < 0 {
+		return fmt.Errorf("min step delay must not be negative")
+	}
+
+	if c.MaxStepDelay < c.MinStepDelay {
+		return fmt.Errorf("max step delay must be greater than or equal to the min step delay")
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+)
+
+// PartitionPin pins the records of customers of a specific class to a
+// designated partition of a topic regardless of the topic's partitioner, e.g.
+// all orders of VIP customers to partition 3. Records refer to a customer of
+// the class if the customer matches all configured criteria. Pins apply to
+// the records of the customers and customer changes topics as well as the
+// order topics, which embed their customer.
+//
+// Records move to the pinned partition once their customer's class changes,
+// so the previous records of the same key remain on their former partition.
+// Tombstones carry no customer and are never pinned. Thus pins are rejected
+// for compacted topics, e.g. the customers and orders topics, unless their
+// cleanup policy is overridden.
+type PartitionPin struct {
+	// Topic without prefix whose records are pinned, e.g. orders.
+	Topic string `yaml:"topic"`
+
+	// CustomerSegment of the customers, either NEW, RETURNING or VIP.
+	// Customers only have a segment if the loyalty program is enabled.
+	CustomerSegment string `yaml:"customerSegment"`
+
+	// CustomerType of the customers, either PERSONAL or BUSINESS.
+	CustomerType string `yaml:"customerType"`
+
+	// LoyaltyTier of the customers, either BRONZE, SILVER, GOLD or PLATINUM.
+	LoyaltyTier string `yaml:"loyaltyTier"`
+
+	// Partition that the records are produced to. Records fail if the topic
+	// has fewer partitions.
+	Partition int32 `yaml:"partition"`
+}
+
+// Validate partition pin config.
+func (c *PartitionPin) Validate() error {
+	if c.Topic == "" {
+		return fmt.Errorf("topic must be set")
+	}
+
+	if c.CustomerSegment == "" && c.CustomerType == "" && c.LoyaltyTier == "" {
+		return fmt.Errorf("at least one of customer segment, customer type and loyalty tier must be set")
+	}
+
+	switch c.CustomerSegment {
+	case "", "NEW", "RETURNING", "VIP":
+	default:
+		return fmt.Errorf("given customer segment '%v' is invalid", c.CustomerSegment)
+	}
+
+	switch c.CustomerType {
+	case "", "PERSONAL", "BUSINESS":
+	default:
+		return fmt.Errorf("given customer type '%v' is invalid", c.CustomerType)
+	}
+
+	switch c.LoyaltyTier {
+	case "", "BRONZE", "SILVER", "GOLD", "PLATINUM":
+	default:
+		return fmt.Errorf("given loyalty tier '%v' is invalid", c.LoyaltyTier)
+	}
+
+	if c.Partition < 0 {
+		return fmt.Errorf("partition must not be negative")
+	}
+
+	return nil
+}
+
+// Matches returns whether a customer with the given segment, type and loyalty
+// tier is of the pinned class.
+func (c *PartitionPin) Matches(segment string, customerType string, loyaltyTier string) bool {
+	return (c.CustomerSegment == "" || c.CustomerSegment == segment) &&
+		(c.CustomerType == "" || c.CustomerType == customerType) &&
+		(c.LoyaltyTier == "" || c.LoyaltyTier == loyaltyTier)
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+)
+
+// PayloadCache configures the pre-generation of customers and addresses. If
+// enabled, new customers and addresses are copies of a random pre-generated
+// one with a fresh ID, which reduces the CPU and allocation cost of the fake
+// data at high rates. Names, emails and streets repeat accordingly.
+type PayloadCache struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Size is the number of pre-generated customers and addresses per
+	// locale.
+	Size int `yaml:"size"`
+}
+
+// SetDefaults for payload cache config.
+func (c *PayloadCache) SetDefaults() {
+	c.Enabled = false
+	c.Size = 10000
+}
+
+// Validate payload cache config.
+func (c *PayloadCache) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Size <= 0 {
+		return fmt.Errorf("size must be greater than 0")
+	}
+
+	return nil
+}
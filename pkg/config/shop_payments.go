@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Payments configures the weighted outcomes of the payments that are simulated
+// by the payment service for each order. A higher weight makes the outcome more
+// likely relative to the other outcomes.
+type Payments struct {
+	// AuthorizedWeight is the weight for payments that are authorized, but
+	// never captured.
+	AuthorizedWeight uint `yaml:"authorizedWeight"`
+
+	// CapturedWeight is the weight for payments that are authorized and then
+	// captured.
+	CapturedWeight uint `yaml:"capturedWeight"`
+
+	// DeclinedWeight is the weight for payments that are declined.
+	DeclinedWeight uint `yaml:"declinedWeight"`
+
+	// RefundedWeight is the weight for payments that are authorized, captured
+	// and then refunded.
+	RefundedWeight uint `yaml:"refundedWeight"`
+}
+
+// SetDefaults for payments config.
+func (c *Payments) SetDefaults() {
+	c.AuthorizedWeight = 5
+	c.CapturedWeight = 80
+	c.DeclinedWeight = 10
+	c.RefundedWeight = 5
+}
+
+// Validate payments config.
+func (c *Payments) Validate() error {
+	if c.AuthorizedWeight+c.CapturedWeight+c.DeclinedWeight+c.RefundedWeight == 0 {
+		return fmt.Errorf("at least one payment outcome must have a positive weight")
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// PriceChanges configures the periodic price changes of the product catalog.
+// In each interval, the price of a random product is either updated or the
+// product goes on sale for a while, after which it reverts to its regular
+// price. Each change is produced to the price-changes topic, keyed by the
+// product's SKU, and the product with its new price to the products topic, so
+// that orders can be joined to the price at order time.
+type PriceChanges struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval in which the price of a random product is changed.
+	Interval time.Duration `yaml:"interval"`
+
+	// MaxChangePercent is the maximum percentage by which a price update
+	// raises or lowers the regular price of a product.
+	MaxChangePercent int `yaml:"maxChangePercent"`
+
+	// PromotionRatio is the share of price changes that start a promotion
+	// rather than updating the regular price.
+	PromotionRatio float64 `yaml:"promotionRatio"`
+
+	// MinDiscountPercent is the minimum discount of a promotion.
+	MinDiscountPercent int `yaml:"minDiscountPercent"`
+
+	// MaxDiscountPercent is the maximum discount of a promotion.
+	MaxDiscountPercent int `yaml:"maxDiscountPercent"`
+
+	// MinPromotionDuration is the minimum duration of a promotion.
+	MinPromotionDuration time.Duration `yaml:"minPromotionDuration"`
+
+	// MaxPromotionDuration is the maximum duration of a promotion.
+	MaxPromotionDuration time.Duration `yaml:"maxPromotionDuration"`
+}
+
+// SetDefaults for price changes config.
+func (c *PriceChanges) SetDefaults() {
+	c.Enabled = false
+	c.Interval = 30 * time.Second
+	c.MaxChangePercent = 10
+	c.PromotionRatio = 0.3
+	c.MinDiscountPercent = 10
+	c.MaxDiscountPercent = 50
+	c.MinPromotionDuration = 10 * time.Minute
+	c.MaxPromotionDuration = time.Hour
+}
+
+// Validate price changes config.
+func (c *PriceChanges) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+
+	if c.MaxChangePercent <= 0 || c.MaxChangePercent >= 100 {
+		return fmt.Errorf("max change percent must be between 1 and 99")
+	}
+
+	if c.PromotionRatio < 0 || c.PromotionRatio > 1 {
+		return fmt.Errorf("promotion ratio must be between 0 and 1")
+	}
+
+	if c.MinDiscountPercent <= 0 || c.MaxDiscountPercent >= 100 {
+		return fmt.Errorf("discount percents must be between 1 and 99")
+	}
+
+	if c.MaxDiscountPercent < c.MinDiscountPercent {
+		return fmt.Errorf("max discount percent must be greater than or equal to the min discount percent")
+	}
+
+	if c.MinPromotionDuration <= 0 {
+		return fmt.Errorf("min promotion duration must be greater than 0")
+	}
+
+	if c.MaxPromotionDuration < c.MinPromotionDuration {
+		return fmt.Errorf("max promotion duration must be greater than or equal to the min promotion duration")
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"math"
+)
+
+// Pricing configures the prices of the products and the currencies of orders
+// and carts. Product prices are drawn from a log-normal distribution per
+// product category, so that most products are cheap and few are expensive.
+// Orders and carts are priced in the currency of the customer's locale, if
+// its exchange rate is configured, and in the base currency otherwise.
+type Pricing struct {
+	// BaseCurrency is the ISO 4217 code of the currency of all product
+	// prices. Defaults to "USD".
+	BaseCurrency string `yaml:"baseCurrency"`
+
+	// Categories are the price distributions of the product categories, keyed
+	// by the category (e.g. "FRUITS"). Categories without distribution use
+	// the distribution of the household category.
+	Categories map[string]PriceDistribution `yaml:"categories"`
+
+	// ExchangeRates are the amounts of each currency that equal one unit of
+	// the base currency, keyed by the ISO 4217 code of the currency.
+	ExchangeRates map[string]float64 `yaml:"exchangeRates"`
+}
+
+// ProductCategories are the categories of the generated products, which the
+// price distributions are keyed by.
+var ProductCategories = []string{"FRUITS", "VEGETABLES", "BEVERAGES", "SNACKS", "HOUSEHOLD"}
+
+// PriceDistribution is a log-normal distribution of prices.
+type PriceDistribution struct {
+	// Median price in cents of the base currency.
+	Median int `yaml:"median"`
+
+	// Sigma is the standard deviation of the price's natural logarithm. The
+	// larger sigma, the wider prices spread around the median.
+	Sigma float64 `yaml:"sigma"`
+}
+
+// SetDefaults for pricing config.
+func (c *Pricing) SetDefaults() {
+	c.BaseCurrency = "USD"
+	c.Categories = map[string]PriceDistribution{
+		"FRUITS":     {Median: 250, Sigma: 0.5},
+		"VEGETABLES": {Median: 200, Sigma: 0.5},
+		"BEVERAGES":  {Median: 350, Sigma: 0.6},
+		"SNACKS":     {Median: 300, Sigma: 0.5},
+		"HOUSEHOLD":  {Median: 800, Sigma: 0.8},
+	}
+	c.ExchangeRates = map[string]float64{
+		"EUR": 0.92,
+		"GBP": 0.79,
+		"JPY": 150,
+	}
+}
+
+// Validate pricing config.
+func (c *Pricing) Validate() error {
+	if len(c.BaseCurrency) != 3 {
+		return fmt.Errorf("base currency must be an ISO 4217 code")
+	}
+
+	for category, distribution := range c.Categories {
+		known := false
+		for _, name := range ProductCategories {
+			if category == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("category '%v' is not supported, must be one of %v", category, ProductCategories)
+		}
+		if err := distribution.Validate(); err != nil {
+			return fmt.Errorf("failed to validate price distribution of category '%v': %w", category, err)
+		}
+	}
+
+	for currency, rate := range c.ExchangeRates {
+		if len(currency) != 3 {
+			return fmt.Errorf("currency '%v' must be an ISO 4217 code", currency)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return fmt.Errorf("exchange rate of currency '%v' must be a positive number", currency)
+		}
+		if currency == c.BaseCurrency && rate != 1 {
+			return fmt.Errorf("exchange rate of the base currency must be 1")
+		}
+	}
+
+	return nil
+}
+
+// Validate price distribution config.
+func (c *PriceDistribution) Validate() error {
+	if c.Median <= 0 {
+		return fmt.Errorf("median must be greater than 0")
+	}
+
+	if c.Sigma < 0 {
+		return fmt.Errorf("sigma must not be negative")
+	}
+
+	return nil
+}
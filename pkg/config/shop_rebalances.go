@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rebalances configures the periodic rebalancing of the services' consumer
+// groups. In each interval, extra members join the group of a random service
+// and leave it again after the configured duration. Each join and leave
+// triggers a rebalance and bumps the group's generation. The extra members
+// don't fetch any records, so that the partitions that are assigned to them
+// build up lag until they leave and the service resumes from the committed
+// offsets.
+type Rebalances struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval is the duration between two joins of extra members.
+	Interval time.Duration `yaml:"interval"`
+
+	// Duration for which the extra members stay in the group.
+	Duration time.Duration `yaml:"duration"`
+
+	// ExtraMembers is the number of members that join the group at once.
+	ExtraMembers int `yaml:"extraMembers"`
+
+	// Groups are the consumer groups that extra members may join, without
+	// the group prefix, e.g. payment-service. Empty allows the groups of all
+	// services that consume the customers or orders topic.
+	Groups []string `yaml:"groups"`
+}
+
+// SetDefaults for rebalances config.
+func (c *Rebalances) SetDefaults() {
+	c.Enabled = false
+	c.Interval = 5 * time.Minute
+	c.Duration = 1 * time.Minute
+	c.ExtraMembers = 1
+}
+
+// Validate rebalances config.
+func (c *Rebalances) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+
+	if c.Duration <= 0 || c.Duration >= c.Interval {
+		return fmt.Errorf("duration must be greater than 0 and shorter than the interval")
+	}
+
+	if c.ExtraMembers <= 0 {
+		return fmt.Errorf("extra members must be greater than 0")
+	}
+
+	return nil
+}
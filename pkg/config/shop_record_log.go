@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+)
+
+// RecordLog configures the debug logging of produced records, which logs a
+// sample of all records with their topic, key, size and a truncated payload,
+// so that it can be diagnosed what the generator produces. Records are logged
+// at debug level, so the logger must be configured with level debug.
+type RecordLog struct {
+	Enabled bool `yaml:"enabled"`
+
+	// SampleRatio is the share of produced records that are logged.
+	SampleRatio float64 `yaml:"sampleRatio"`
+
+	// MaxPerSecond is the max number of logged records per second across all
+	// services, so that the log stays readable at high throughput. Sampled
+	// records beyond this rate are counted and reported with the next logged
+	// record.
+	MaxPerSecond float64 `yaml:"maxPerSecond"`
+
+	// MaxPayloadBytes is the number of bytes of the value after which the
+	// payload is truncated.
+	MaxPayloadBytes int `yaml:"maxPayloadBytes"`
+}
+
+// SetDefaults for record log config.
+func (c *RecordLog) SetDefaults() {
+	c.Enabled = false
+	c.SampleRatio = 0.01
+	c.MaxPerSecond = 10
+	c.MaxPayloadBytes = 256
+}
+
+// Validate record log config.
+func (c *RecordLog) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.SampleRatio <= 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample ratio must be greater than 0 and at most 1")
+	}
+	if c.MaxPerSecond <= 0 {
+		return fmt.Errorf("max per second must be greater than 0")
+	}
+	if c.MaxPayloadBytes < 0 {
+		return fmt.Errorf("max payload bytes must not be negative")
+	}
+
+	return nil
+}
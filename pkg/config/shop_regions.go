@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Region is a share of the customers that are generated with the given
+// locale, e.g. for demos of an international shop.
+type Region struct {
+	// Locale of the region's customers, e.g. "de_DE".
+	Locale string `yaml:"locale"`
+
+	// Weight is the relative weight of the region among all regions.
+	Weight uint `yaml:"weight"`
+}
+
+// Validate region config. The locale itself is validated by the data
+// generator, which knows the supported locales.
+func (c *Region) Validate() error {
+	if c.Locale == "" {
+		return fmt.Errorf("locale must be set")
+	}
+
+	if c.Weight == 0 {
+		return fmt.Errorf("weight must be greater than 0")
+	}
+
+	return nil
+}
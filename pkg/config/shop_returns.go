@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Returns configures the return service, which simulates product returns of
+// delivered orders. Each return passes return_requested, return_received and
+// refund_issued, spread over several days of simulated time.
+type Returns struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Ratio is the share of delivered orders that are returned.
+	Ratio float64 `yaml:"ratio"`
+
+	// MinRequestDelay is the minimum duration between the delivery of an
+	// order and its return request.
+	MinRequestDelay time.Duration `yaml:"minRequestDelay"`
+
+	// MaxRequestDelay is the maximum duration between the delivery of an
+	// order and its return request.
+	MaxRequestDelay time.Duration `yaml:"maxRequestDelay"`
+
+	// MinStepDelay is the minimum duration between two events of the same
+	// return.
+	MinStepDelay time.Duration `yaml:"minStepDelay"`
+
+	// MaxStepDelay is the maximum duration between two events of the same
+	// return.
+	MaxStepDelay time.Duration `yaml:"maxStepDelay"`
+}
+
+// SetDefaults for returns config.
+func (c *Returns) SetDefaults() {
+	c.Enabled = false
+	c.Ratio = 0.08
+	c.MinRequestDelay = 24 * time.Hour
+	c.MaxRequestDelay = 14 * 24 * time.Hour
+	c.MinStepDelay = 24 * time.Hour
+	c.MaxStepDelay = 5 * 24 * time.Hour
+}
+
+// Validate returns config.
+func (c *Returns) Validate() error {
+	if c.Ratio < 0 || c.Ratio > 1 {
+		return fmt.Errorf("ratio must be between 0 and 1")
+	}
+
+	if c.MinRequestDelay < 0 {
+		return fmt.Errorf("min request delay must not be negative")
+	}
+
+	if c.MaxRequestDelay < c.MinRequestDelay {
+		return fmt.Errorf("max request delay must be greater than or equal to the min request delay")
+	}
+
+	if c.MinStepDelay < 0 {
+		return fmt.Errorf("min step delay must not be negative")
+	}
+
+	if c.MaxStepDelay < c.MinStepDelay {
+		return fmt.Errorf("max step delay must be greater than or equal to the min step delay")
+	}
+
+	return nil
+}
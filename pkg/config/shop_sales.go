@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// saleServices are the yaml keys of the services whose traffic can be boosted
+// by a sale, i.e. the services that trigger events.
+var saleServices = []string{"customer", "address", "frontend", "order", "productCatalog", "inventory", "review", "cart"}
+
+// Sale is a scheduled sale event such as Black Friday or a flash sale. While
+// the sale is active, the traffic of its services is multiplied by the sale's
+// factor and orders redeem its promo code. The traffic returns to its
+// baseline once the sale has ended. The schedule refers to the simulated
+// time, see TimeAcceleration.
+type Sale struct {
+	Name string `yaml:"name"`
+
+	// Start is the RFC 3339 timestamp at which the sale starts, e.g.
+	// 2023-11-24T08:00:00Z.
+	Start string `yaml:"start"`
+
+	// Duration is the length of the sale's window.
+	Duration time.Duration `yaml:"duration"`
+
+	// Every repeats the sale in the given interval after its start, e.g. 24h
+	// for a daily flash sale. The sale only takes place once if zero.
+	Every time.Duration `yaml:"every"`
+
+	// Factor multiplies the traffic of the sale's services, e.g. 5 for five
+	// times as many page impressions and orders.
+	Factor float64 `yaml:"factor"`
+
+	// Services are the yaml keys of the services whose traffic is multiplied.
+	// Defaults to frontend and order.
+	Services []string `yaml:"services"`
+
+	// PromoCode is redeemed by orders that are placed during the sale. No
+	// promo code usages are produced if it is empty.
+	PromoCode string `yaml:"promoCode"`
+
+	// DiscountPercent is the discount that the promo code grants on the order
+	// value.
+	DiscountPercent int `yaml:"discountPercent"`
+
+	// RedemptionRatio is the share of the orders during the sale that redeem
+	// the promo code.
+	RedemptionRatio float64 `yaml:"redemptionRatio"`
+}
+
+// Validate sale config.
+func (c *Sale) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name must be set")
+	}
+
+	if _, err := c.StartTime(); err != nil {
+		return err
+	}
+
+	if c.Duration <= 0 {
+		return fmt.Errorf("duration must be greater than 0")
+	}
+
+	if c.Every < 0 {
+		return fmt.Errorf("every must not be negative")
+	}
+	if c.Every > 0 && c.Every < c.Duration {
+		return fmt.Errorf("every must not be shorter than the duration, so that the sale's windows don't overlap")
+	}
+
+	if c.Factor <= 0 {
+		return fmt.Errorf("factor must be a positive number")
+	}
+
+	for _, service := range c.Services {
+		valid := false
+		for _, name := range saleServices {
+			if service == name {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("given service '%v' is invalid, valid services are: %v", service, saleServices)
+		}
+	}
+
+	if c.DiscountPercent < 0 || c.DiscountPercent > 100 {
+		return fmt.Errorf("discount percent must be between 0 and 100")
+	}
+
+	if c.RedemptionRatio < 0 || c.RedemptionRatio > 1 {
+		return fmt.Errorf("redemption ratio must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// StartTime returns the parsed start timestamp.
+func (c *Sale) StartTime() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, c.Start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse start timestamp: %w", err)
+	}
+
+	return t, nil
+}
+
+// BoostedServices returns the services whose traffic is multiplied by the
+// sale.
+func (c *Sale) BoostedServices() []string {
+	if len(c.Services) == 0 {
+		return []string{"frontend", "order"}
+	}
+	return c.Services
+}
+
+// ActiveAt returns true if the given time is within one of the sale's
+// windows.
+func (c *Sale) ActiveAt(t time.Time) bool {
+	start, err := c.StartTime()
+	if err != nil || t.Before(start) {
+		return false
+	}
+
+	elapsed := t.Sub(start)
+	if c.Every > 0 {
+		elapsed %= c.Every
+	}
+	return elapsed < c.Duration
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// ScenarioActionTrigger triggers an event count times right away.
+	ScenarioActionTrigger = "trigger"
+	// ScenarioActionSpike multiplies the weight of an event, or the events
+	// per second if no event is set. The previous weight or rate is restored
+	// after the duration of the step, if it has any.
+	ScenarioActionSpike = "spike"
+	// ScenarioActionRate changes the events per second and the burst.
+	ScenarioActionRate = "rate"
+	// ScenarioActionPause pauses the traffic simulation.
+	ScenarioActionPause = "pause"
+	// ScenarioActionResume resumes the traffic simulation.
+	ScenarioActionResume = "resume"
+	// ScenarioActionPoison injects count poison messages, which requires dead
+	// letters to be enabled.
+	ScenarioActionPoison = "poison"
+)
+
+// Scenario configures a script of timed steps that is run alongside the
+// traffic simulation, e.g. "at 30s create 100 customers, at 60s spike orders
+// 10x", so that the narrative of a demo is reproducible. The steps are either
+// configured inline or loaded from a YAML file.
+type Scenario struct {
+	// Filepath of a YAML file with a list of steps below the steps key. If
+	// set, the steps of the file replace the inline steps.
+	Filepath string `yaml:"filepath"`
+
+	Steps []ScenarioStep `yaml:"steps"`
+
+	// Repeat restarts the scenario once its last step has run and all of its
+	// spikes have ended. It requires the scenario to take a positive
+	// duration, i.e. a step with a positive offset or spike duration.
+	Repeat bool `yaml:"repeat"`
+}
+
+// ScenarioStep is a single action of a scenario.
+type ScenarioStep struct {
+	// At is the offset from the start of the traffic simulation at which the
+	// step runs.
+	At time.Duration `yaml:"at"`
+
+	// Action is one of trigger, spike, rate, pause, resume or poison.
+	Action string `yaml:"action"`
+
+	// Event is the name of a traffic event as in the event weights, e.g.
+	// createOrder. It is required for trigger and optional for spike.
+	Event string `yaml:"event"`
+
+	// Count is the number of triggered events or injected poison messages.
+	// Defaults to 1.
+	Count int `yaml:"count"`
+
+	// Multiplier of a spike.
+	Multiplier float64 `yaml:"multiplier"`
+
+	// Duration of a spike, after which the previous weight or rate is
+	// restored. If 0, the spike lasts until it is changed by another step.
+	Duration time.Duration `yaml:"duration"`
+
+	// EventsPerSecond and Burst of a rate step.
+	EventsPerSecond float64 `yaml:"eventsPerSecond"`
+	Burst           int     `yaml:"burst"`
+}
+
+// Enabled returns whether the scenario has any steps or a file to load them
+// from.
+func (c *Scenario) Enabled() bool {
+	return c.Filepath != "" || len(c.Steps) > 0
+}
+
+// Validate scenario config. If a filepath is set, the steps are loaded from
+// the file to validate them.
+func (c *Scenario) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	_, err := c.LoadSteps()
+	return err
+}
+
+// LoadSteps returns the steps of the scenario, which are read from the file if
+// a filepath is set.
+func (c *Scenario) LoadSteps() ([]ScenarioStep, error) {
+	if c.Filepath == "" {
+		if err := c.validateSteps(c.Steps); err != nil {
+			return nil, err
+		}
+		return c.Steps, nil
+	}
+
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(c.Filepath), yaml.Parser()); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML scenario: %w", err)
+	}
+
+	var script struct {
+		Steps []ScenarioStep `yaml:"steps"`
+	}
+	err := k.UnmarshalWithConf("", &script, koanf.UnmarshalConf{
+		Tag: "yaml",
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+			Result:           &script,
+			WeaklyTypedInput: true,
+			ErrorUnused:      true,
+			TagName:          "yaml",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML scenario: %w", err)
+	}
+
+	if err := c.validateSteps(script.Steps); err != nil {
+		return nil, fmt.Errorf("failed to validate scenario '%v': %w", c.Filepath, err)
+	}
+
+	return script.Steps, nil
+}
+
+func (c *Scenario) validateSteps(steps []ScenarioStep) error {
+	var duration time.Duration
+	for i, step := range steps {
+		if err := step.Validate(); err != nil {
+			return fmt.Errorf("failed to validate step at index %d: %w", i, err)
+		}
+		end := step.At
+		if step.Action == ScenarioActionSpike {
+			end += step.Duration
+		}
+		if end > duration {
+			duration = end
+		}
+	}
+
+	// A repeated scenario without duration would restart right away forever
+	if c.Repeat && duration == 0 {
+		return fmt.Errorf("a repeated scenario requires a step with a positive offset or spike duration")
+	}
+
+	return nil
+}
+
+// Validate scenario step.
+func (c *ScenarioStep) Validate() error {
+	if c.At < 0 {
+		return fmt.Errorf("at must not be negative")
+	}
+	if c.Count < 0 {
+		return fmt.Errorf("count must not be negative")
+	}
+
+	switch c.Action {
+	case ScenarioActionTrigger:
+		if c.Event == "" {
+			return fmt.Errorf("trigger requires an event")
+		}
+	case ScenarioActionSpike:
+		if c.Multiplier <= 0 {
+			return fmt.Errorf("spike requires a positive multiplier")
+		}
+		if c.Duration < 0 {
+			return fmt.Errorf("duration must not be negative")
+		}
+	case ScenarioActionRate:
+		if c.EventsPerSecond <= 0 {
+			return fmt.Errorf("rate requires positive events per second")
+		}
+		if c.Burst < 0 {
+			return fmt.Errorf("burst must not be negative")
+		}
+	case ScenarioActionPause, ScenarioActionResume, ScenarioActionPoison:
+	default:
+		return fmt.Errorf("action '%v' is invalid, valid actions are trigger, spike, rate, pause, resume and poison", c.Action)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchemaEvolution configures the simulated evolution of the Avro order schema.
+// If enabled, a new optional field is added to the order schema in each interval
+// and registered as a new schema version in the schema registry, so that the
+// orders subject contains multiple schema versions.
+type SchemaEvolution struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval after which the next schema version is registered.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SetDefaults for schema evolution config.
+func (c *SchemaEvolution) SetDefaults() {
+	c.Enabled = false
+	c.Interval = 10 * time.Minute
+}
+
+// Validate schema evolution config.
+func (c *SchemaEvolution) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be a positive duration (e.g. '10m')")
+	}
+
+	return nil
+}
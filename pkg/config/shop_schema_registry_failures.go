@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+)
+
+// SchemaRegistryFailures configures the injection of schema registry
+// inconsistencies into the JSON Schema, Avro and Protobuf topics, so that
+// tools which resolve the schema IDs of records can be tested against them.
+// The shop's own consumers still decode the affected records.
+type SchemaRegistryFailures struct {
+	Enabled bool `yaml:"enabled"`
+
+	// UnknownSchemaIDRatio is the share of records whose wire format
+	// references the unknown schema ID rather than the ID of their schema.
+	UnknownSchemaIDRatio float64 `yaml:"unknownSchemaIdRatio"`
+
+	// UnknownSchemaID is the schema ID that is referenced by the affected
+	// records. It must not exist in the schema registry.
+	UnknownSchemaID int `yaml:"unknownSchemaId"`
+
+	// SkipRegistration are the topics, without prefix, whose schemas are not
+	// registered at all, e.g. orders. All of their records reference the
+	// unknown schema ID and their subjects don't exist. The topics must have
+	// a serde, which is checked once the serdes are created.
+	SkipRegistration []string `yaml:"skipRegistration"`
+}
+
+// SetDefaults for schema registry failures config.
+func (c *SchemaRegistryFailures) SetDefaults() {
+	c.Enabled = false
+	c.UnknownSchemaIDRatio = 0.01
+	c.UnknownSchemaID = 2147483647
+}
+
+// Validate schema registry failures config.
+func (c *SchemaRegistryFailures) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.UnknownSchemaIDRatio < 0 || c.UnknownSchemaIDRatio > 1 {
+		return fmt.Errorf("unknown schema id ratio must be between 0 and 1")
+	}
+	if c.UnknownSchemaID <= 0 {
+		return fmt.Errorf("unknown schema id must be greater than 0")
+	}
+
+	return nil
+}
+
+// Skips returns whether the registration of the given topic's schema is
+// skipped.
+func (c *SchemaRegistryFailures) Skips(topic string) bool {
+	if !c.Enabled {
+		return false
+	}
+	for _, t := range c.SkipRegistration {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Search configures the search queries of the frontend sessions. Each view of
+// the search page produces a search query to the search-queries topic, which
+// is keyed by the session id like the frontend events.
+type Search struct {
+	Enabled bool `yaml:"enabled"`
+
+	// ZeroResultRatio is the share of searches without any results.
+	ZeroResultRatio float64 `yaml:"zeroResultRatio"`
+
+	// ClickRatio is the share of searches with results in which one of the
+	// results is clicked. The session views the clicked product next.
+	ClickRatio float64 `yaml:"clickRatio"`
+
+	// MaxResults is the maximum number of results of a search.
+	MaxResults int `yaml:"maxResults"`
+}
+
+// SetDefaults for search config.
+func (c *Search) SetDefaults() {
+	c.Enabled = false
+	c.ZeroResultRatio = 0.1
+	c.ClickRatio = 0.6
+	c.MaxResults = 500
+}
+
+// Validate search config.
+func (c *Search) Validate() error {
+	if c.ZeroResultRatio < 0 || c.ZeroResultRatio > 1 {
+		return fmt.Errorf("zero result ratio must be between 0 and 1")
+	}
+
+	if c.ClickRatio < 0 || c.ClickRatio > 1 {
+		return fmt.Errorf("click ratio must be between 0 and 1")
+	}
+
+	if c.MaxResults <= 0 {
+		return fmt.Errorf("max results must be greater than 0")
+	}
+
+	return nil
+}
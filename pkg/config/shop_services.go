@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	SerdeJSON     = "json"
+	SerdeAvro     = "avro"
+	SerdeProtobuf = "protobuf"
+	// SerdeJSONSchema produces JSON records with the schema registry wire
+	// format, whose JSON Schema is registered in the schema registry.
+	SerdeJSONSchema = "json-schema"
+)
+
+// Services contains the individual configuration of the shop's services.
+type Services struct {
+	Customer Service `yaml:"customer"`
+	Address  Service `yaml:"address"`
+	Frontend Service `yaml:"frontend"`
+	Order    Service `yaml:"order"`
+
+	ProductCatalog Service `yaml:"productCatalog"`
+	Inventory      Service `yaml:"inventory"`
+	Payment        Service `yaml:"payment"`
+	Shipment       Service `yaml:"shipment"`
+	Review         Service `yaml:"review"`
+	Cart           Service `yaml:"cart"`
+	Notification   Service `yaml:"notification"`
+	Return         Service `yaml:"return"`
+	Support        Service `yaml:"support"`
+}
+
+// SetDefaults for services config.
+func (c *Services) SetDefaults() {
+	c.Customer.SetDefaults()
+	c.Address.SetDefaults()
+	c.Frontend.SetDefaults()
+	c.Order.SetDefaults()
+	c.ProductCatalog.SetDefaults()
+	c.Inventory.SetDefaults()
+	c.Payment.SetDefaults()
+	c.Shipment.SetDefaults()
+	c.Review.SetDefaults()
+	c.Cart.SetDefaults()
+	c.Notification.SetDefaults()
+	c.Return.SetDefaults()
+	c.Support.SetDefaults()
+}
+
+// ByName returns the config of all services keyed by a human readable name.
+func (c *Services) ByName() map[string]Service {
+	return map[string]Service{
+		"customer":        c.Customer,
+		"address":         c.Address,
+		"frontend":        c.Frontend,
+		"order":           c.Order,
+		"product catalog": c.ProductCatalog,
+		"inventory":       c.Inventory,
+		"payment":         c.Payment,
+		"shipment":        c.Shipment,
+		"review":          c.Review,
+		"cart":            c.Cart,
+		"notification":    c.Notification,
+		"return":          c.Return,
+		"support":         c.Support,
+	}
+}
+
+// Validate services config.
+func (c *Services) Validate() error {
+	if err := c.Customer.Validate(); err != nil {
+		return fmt.Errorf("failed to validate customer service config: %w", err)
+	}
+	if err := c.Address.Validate(); err != nil {
+		return fmt.Errorf("failed to validate address service config: %w", err)
+	}
+	if err := c.Frontend.Validate(); err != nil {
+		return fmt.Errorf("failed to validate frontend service config: %w", err)
+	}
+	if err := c.Order.Validate(); err != nil {
+		return fmt.Errorf("failed to validate order service config: %w", err)
+	}
+	if err := c.ProductCatalog.Validate(); err != nil {
+		return fmt.Errorf("failed to validate product catalog service config: %w", err)
+	}
+	if err := c.Inventory.Validate(); err != nil {
+		return fmt.Errorf("failed to validate inventory service config: %w", err)
+	}
+	if err := c.Payment.Validate(); err != nil {
+		return fmt.Errorf("failed to validate payment service config: %w", err)
+	}
+	if err := c.Shipment.Validate(); err != nil {
+		return fmt.Errorf("failed to validate shipment service config: %w", err)
+	}
+	if err := c.Review.Validate(); err != nil {
+		return fmt.Errorf("failed to validate review service config: %w", err)
+	}
+	if err := c.Cart.Validate(); err != nil {
+		return fmt.Errorf("failed to validate cart service config: %w", err)
+	}
+	if err := c.Notification.Validate(); err != nil {
+		return fmt.Errorf("failed to validate notification service config: %w", err)
+	}
+	if err := c.Return.Validate(); err != nil {
+		return fmt.Errorf("failed to validate return service config: %w", err)
+	}
+	if err := c.Support.Validate(); err != nil {
+		return fmt.Errorf("failed to validate support service config: %w", err)
+	}
+
+	return nil
+}
+
+// Service is the configuration for a single service of the shop.
+type Service struct {
+	// Serde is the serialization format of the records that are produced
+	// to the service's topic. Valid values are json, json-schema, avro and
+	// protobuf. JSON Schema, avro and protobuf records are serialized using
+	// the schema registry wire format and therefore require a configured
+	// schema registry.
+	Serde string `yaml:"serde"`
+
+	// SlowConsumer throttles the consumption of the service's input topics.
+	// It has no effect on services that do not consume any topic.
+	SlowConsumer SlowConsumer `yaml:"slowConsumer"`
+
+	// Offsets configures the offset commit strategy and the start offset of
+	// the service's consumer group. It has no effect on services that do not
+	// consume any topic.
+	Offsets ConsumerOffsets `yaml:"offsets"`
+
+	// Cluster is the name of the Kafka cluster the service produces to and
+	// consumes from. Defaults to the default cluster. Services that consume
+	// another service's topic should be pinned to the same cluster.
+	Cluster string `yaml:"cluster"`
+
+	// Producer overrides the producer config of the Kafka config for the
+	// service's clients.
+	Producer Producer `yaml:"producer"`
+
+	// EventsPerSecond makes the service trigger its events at this rate of
+	// its own, independently of the shop's events per second, e.g. 500 for
+	// the frontend and 2 for orders. The service's events are picked by
+	// their weights and are no longer part of the weighted page impressions.
+	// The traffic pattern and the time acceleration are applied to the rate
+	// as well. It has no effect on services without events of their own.
+	// Defaults to 0, which keeps the service's events weighted.
+	EventsPerSecond float64 `yaml:"eventsPerSecond"`
+
+	// ClientID is the template of the client.id of the service's clients,
+	// so that broker quotas can be applied per service and replica. The
+	// placeholders {prefix}, {service}, {hostname} and {pod} are replaced by
+	// the global prefix, the service name such as order-service, the
+	// hostname and the POD_NAME environment variable, which defaults to the
+	// hostname. Defaults to {prefix}{service}.
+	ClientID string `yaml:"clientID"`
+}
+
+// SetDefaults for service config.
+func (c *Service) SetDefaults() {
+	c.Serde = SerdeJSON
+	c.SlowConsumer.SetDefaults()
+	c.Offsets.SetDefaults()
+	c.ClientID = "{prefix}{service}"
+}
+
+// Validate service config.
+func (c *Service) Validate() error {
+	switch c.Serde {
+	case SerdeJSON, SerdeJSONSchema, SerdeAvro, SerdeProtobuf:
+		// Valid and supported
+	default:
+		return fmt.Errorf("given serde '%v' is invalid", c.Serde)
+	}
+
+	if err := c.SlowConsumer.Validate(); err != nil {
+		return fmt.Errorf("failed to validate slow consumer config: %w", err)
+	}
+
+	if err := c.Offsets.Validate(); err != nil {
+		return fmt.Errorf("failed to validate offsets config: %w", err)
+	}
+
+	if err := c.Producer.Validate(); err != nil {
+		return fmt.Errorf("failed to validate producer config: %w", err)
+	}
+
+	if c.EventsPerSecond < 0 {
+		return fmt.Errorf("events per second must not be negative")
+	}
+
+	if !strings.Contains(c.ClientID, "{service}") {
+		return fmt.Errorf("client id template must contain the {service} placeholder, so that the services' clients can be told apart")
+	}
+
+	return nil
+}
+
+// ClientIDFor returns the client.id of the given service's clients, e.g.
+// order-service, based on the client ID template.
+func (c *Service) ClientIDFor(globalPrefix, service string) string {
+	hostname, _ := os.Hostname()
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		pod = hostname
+	}
+
+	return strings.NewReplacer(
+		"{prefix}", globalPrefix,
+		"{service}", service,
+		"{hostname}", hostname,
+		"{pod}", pod,
+	).Replace(c.ClientID)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sessions configures the simulated user sessions of the shop's frontend.
+// Each session starts with a landing page, continues with several page views
+// that share the session id and form a referrer chain, and ends with the user
+// either converting or leaving the shop.
+type Sessions struct {
+	// MaxPages is the maximum number of pages that are viewed within a
+	// single session.
+	MaxPages int `yaml:"maxPages"`
+
+	// BounceRatio is the share of sessions that end after the landing page,
+	// in the range [0, 1].
+	BounceRatio float64 `yaml:"bounceRatio"`
+
+	// ConversionRatio is the share of the not bounced sessions that end with
+	// a checkout, in the range [0, 1].
+	ConversionRatio float64 `yaml:"conversionRatio"`
+
+	// MinPageDelay is the minimum duration between two consecutive page views
+	// of the same session.
+	MinPageDelay time.Duration `yaml:"minPageDelay"`
+
+	// MaxPageDelay is the maximum duration between two consecutive page views
+	// of the same session.
+	MaxPageDelay time.Duration `yaml:"maxPageDelay"`
+
+	// MaxActive is the maximum number of sessions that are in progress at the
+	// same time. Sessions that start while this limit is reached bounce.
+	MaxActive int `yaml:"maxActive"`
+}
+
+// SetDefaults for sessions config.
+func (c *Sessions) SetDefaults() {
+	c.MaxPages = 12
+	c.BounceRatio = 0.4
+	c.ConversionRatio = 0.1
+	c.MinPageDelay = 2 * time.Second
+	c.MaxPageDelay = time.Minute
+	c.MaxActive = 10000
+}
+
+// Validate sessions config.
+func (c *Sessions) Validate() error {
+	// Converting sessions view the cart, checkout and confirmation page after landing
+	if c.MaxPages < 4 {
+		return fmt.Errorf("max pages must be at least 4")
+	}
+
+	if c.BounceRatio < 0 || c.BounceRatio > 1 {
+		return fmt.Errorf("bounce ratio must be between 0 and 1")
+	}
+
+	if c.ConversionRatio < 0 || c.ConversionRatio > 1 {
+		return fmt.Errorf("conversion ratio must be between 0 and 1")
+	}
+
+	if c.MinPageDelay < 0 {
+		return fmt.Errorf("min page delay must not be negative")
+	}
+
+	if c.MaxPageDelay < c.MinPageDelay {
+		return fmt.Errorf("max page delay must be greater than or equal to the min page delay")
+	}
+
+	if c.MaxActive < 0 {
+		return fmt.Errorf("max active sessions must not be negative")
+	}
+
+	return nil
+}
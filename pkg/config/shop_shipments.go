@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Shipments configures how fast simulated shipments pass through their
+// lifecycle (label created, picked up, in transit, delivered).
+type Shipments struct {
+	// MinStepDelay is the minimum duration between two consecutive events
+	// of the same shipment.
+	MinStepDelay time.Duration `yaml:"minStepDelay"`
+
+	// MaxStepDelay is the maximum duration between two consecutive events
+	// of the same shipment.
+	MaxStepDelay time.Duration `yaml:"maxStepDelay"`
+}
+
+// SetDefaults for shipments config.
+func (c *Shipments) SetDefaults() {
+	c.MinStepDelay = 30 * time.Second
+	c.MaxStepDelay = 3 * time.Minute
+}
+
+// Validate shipments config.
+func (c *Shipments) Validate() error {
+	if c.MinStepDelay < 0 {
+		return fmt.Errorf("min step delay must not be negative")
+	}
+
+	if c.MaxStepDelay < c.MinStepDelay {
+		return fmt.Errorf("max step delay must be greater than or equal to the min step delay")
+	}
+
+	return nil
+}
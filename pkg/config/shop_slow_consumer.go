@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// SlowConsumer deliberately slows down the consumption of a service's input
+// topics, so that tools which monitor consumer group lag have realistic lag
+// curves to display.
+type SlowConsumer struct {
+	Enabled bool `yaml:"enabled"`
+
+	// RecordDelay is the artificial processing time of each consumed record.
+	RecordDelay time.Duration `yaml:"recordDelay"`
+
+	// MaxRecordsPerSecond bounds the consumer's throughput. 0 means that the
+	// throughput is only bounded by the record delay.
+	MaxRecordsPerSecond int `yaml:"maxRecordsPerSecond"`
+}
+
+// SetDefaults for slow consumer config.
+func (c *SlowConsumer) SetDefaults() {
+	c.Enabled = false
+	c.RecordDelay = 100 * time.Millisecond
+	c.MaxRecordsPerSecond = 0
+}
+
+// Validate slow consumer config.
+func (c *SlowConsumer) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.RecordDelay < 0 {
+		return fmt.Errorf("record delay must not be negative")
+	}
+
+	if c.MaxRecordsPerSecond < 0 {
+		return fmt.Errorf("max records per second must not be negative")
+	}
+
+	if c.RecordDelay == 0 && c.MaxRecordsPerSecond == 0 {
+		return fmt.Errorf("either record delay or max records per second must be set")
+	}
+
+	return nil
+}
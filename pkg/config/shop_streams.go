@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Streams configures the internal topics of a simulated Kafka Streams
+// application that counts the orders of each customer. The order service
+// repartitions its orders by customer ID and the application aggregates the
+// repartitioned orders into a changelog, so that the topics and the consumer
+// group show up in monitoring UIs like those of a real stream processor.
+type Streams struct {
+	Enabled bool `yaml:"enabled"`
+
+	// ApplicationID is the application.id of the simulated application, which
+	// is the consumer group and prefixes the internal topics as Kafka Streams
+	// does, e.g. owlshop-orders-by-customer-repartition. Unlike the topics of
+	// the services, the internal topics are not prefixed with the topic
+	// prefix.
+	ApplicationID string `yaml:"applicationId"`
+}
+
+// SetDefaults for streams config.
+func (c *Streams) SetDefaults() {
+	c.Enabled = false
+	c.ApplicationID = "owlshop"
+}
+
+// Validate streams config.
+func (c *Streams) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.ApplicationID == "" || strings.ContainsAny(c.ApplicationID, " /") {
+		return fmt.Errorf("application id must be a non-empty topic name prefix")
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Support configures the support service, which opens support tickets for
+// some of the orders. Tickets are more likely for orders whose payment has
+// been declined or whose shipment has been delayed, so that support tickets
+// correlate with the payments and shipments topics.
+type Support struct {
+	Enabled bool `yaml:"enabled"`
+
+	// BaseRatio is the share of delivered orders without delay for which a
+	// ticket is opened.
+	BaseRatio float64 `yaml:"baseRatio"`
+
+	// DelayedShipmentRatio is the share of delayed shipments for which a
+	// ticket is opened.
+	DelayedShipmentRatio float64 `yaml:"delayedShipmentRatio"`
+
+	// DeclinedPaymentRatio is the share of declined payments for which a
+	// ticket is opened.
+	DeclinedPaymentRatio float64 `yaml:"declinedPaymentRatio"`
+
+	// DelayedShipmentAfter is the duration between the label creation and
+	// the delivery of a shipment after which the shipment is delayed.
+	DelayedShipmentAfter time.Duration `yaml:"delayedShipmentAfter"`
+
+	// MaxReplies is the maximum number of replies before a ticket is
+	// resolved. Each ticket is replied to at least once.
+	MaxReplies int `yaml:"maxReplies"`
+
+	// MinReplyDelay is the minimum duration between two events of the same
+	// ticket.
+	MinReplyDelay time.Duration `yaml:"minReplyDelay"`
+
+	// MaxReplyDelay is the maximum duration between two events of the same
+	// ticket.
+	MaxReplyDelay time.Duration `yaml:"maxReplyDelay"`
+}
+
+// SetDefaults for support config.
+func (c *Support) SetDefaults() {
+	c.Enabled = false
+	c.BaseRatio = 0.01
+	c.DelayedShipmentRatio = 0.2
+	c.DeclinedPaymentRatio = 0.3
+	c.DelayedShipmentAfter = 6 * time.Minute
+	c.MaxReplies = 4
+	c.MinReplyDelay = 5 * time.Minute
+	c.MaxReplyDelay = 2 * time.Hour
+}
+
+// Validate support config.
+func (c *Support) Validate() error {
+	if c.BaseRatio < 0 || c.BaseRatio > 1 {
+		return fmt.Errorf("base ratio must be between 0 and 1")
+	}
+
+	if c.DelayedShipmentRatio < 0 || c.DelayedShipmentRatio > 1 {
+		return fmt.Errorf("delayed shipment ratio must be between 0 and 1")
+	}
+
+	if c.DeclinedPaymentRatio < 0 || c.DeclinedPaymentRatio > 1 {
+		return fmt.Errorf("declined payment ratio must be between 0 and 1")
+	}
+
+	if c.DelayedShipmentAfter <= 0 {
+		return fmt.Errorf("delayed shipment after must be greater than 0")
+	}
+
+	if c.MaxReplies <= 0 {
+		return fmt.Errorf("max replies must be greater than 0")
+	}
+
+	if c.MinReplyDelay < 0 {
+		return fmt.Errorf("min reply delay must not be negative")
+	}
+
+	if c.MaxReplyDelay < c.MinReplyDelay {
+		return fmt.Errorf("max reply delay must be greater than or equal to the min reply delay")
+	}
+
+	return nil
+}
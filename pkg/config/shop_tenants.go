@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Tenant is a shop with its own set of topics and consumer groups, e.g. the
+// tenant acme produces to owlshop-acme-orders. All tenants share the shop
+// config and run concurrently like profiles, so that a single process
+// simulates a multi-tenant platform cluster with many topics.
+type Tenant struct {
+	Name string `yaml:"name"`
+
+	// EventsPerSecond overrides the shop's rate of page impressions for the
+	// tenant. Defaults to the shop's rate.
+	EventsPerSecond float64 `yaml:"eventsPerSecond"`
+}
+
+// Validate tenant config.
+func (c *Tenant) Validate() error {
+	if !profileNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("name '%v' must consist of lower case alphanumeric characters or '-'", c.Name)
+	}
+
+	if c.EventsPerSecond < 0 {
+		return fmt.Errorf("events per second must not be negative")
+	}
+
+	return nil
+}
+
+// tenantProfiles returns a profile for each tenant of the given profile. The
+// tenant's name is appended to the profile's name and to all prefixes that
+// are set, so that the tenants' topics, groups, subjects, client ids and
+// streams applications don't collide.
+func tenantProfiles(profile Profile) []Profile {
+	profiles := make([]Profile, 0, len(profile.Shop.Tenants))
+	for _, tenant := range profile.Shop.Tenants {
+		shop := profile.Shop
+		shop.Tenants = nil
+		shop.GlobalPrefix += tenant.Name + "-"
+		if shop.TopicPrefix != "" {
+			shop.TopicPrefix += tenant.Name + "-"
+		}
+		if shop.GroupPrefix != "" {
+			shop.GroupPrefix += tenant.Name + "-"
+		}
+		if shop.SubjectPrefix != "" {
+			shop.SubjectPrefix += tenant.Name + "-"
+		}
+		shop.Streams.ApplicationID += "-" + tenant.Name
+		if tenant.EventsPerSecond > 0 {
+			shop.EventsPerSecond = tenant.EventsPerSecond
+		}
+
+		name := tenant.Name
+		if profile.Name != "" {
+			name = profile.Name + "-" + tenant.Name
+		}
+		profiles = append(profiles, Profile{Name: name, Shop: shop})
+	}
+
+	return profiles
+}
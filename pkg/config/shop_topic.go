@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topic overrides the settings that are used when a topic is created. Unset
+// options keep the shop-wide settings respectively the topic's default config.
+// Overrides only take effect for topics that do not exist yet.
+type Topic struct {
+	// PartitionCount overrides the shop's topic partition count.
+	PartitionCount int32 `yaml:"partitionCount"`
+
+	// ReplicationFactor overrides the shop's topic replication factor.
+	ReplicationFactor int16 `yaml:"replicationFactor"`
+
+	// RetentionMs sets the topic's retention.ms config. -1 means unlimited.
+	RetentionMs int64 `yaml:"retentionMs"`
+
+	// RetentionBytes sets the topic's retention.bytes config. -1 means
+	// unlimited.
+	RetentionBytes int64 `yaml:"retentionBytes"`
+
+	// CleanupPolicy sets the topic's cleanup.policy config. Valid values
+	// are delete, compact and compact,delete.
+	CleanupPolicy string `yaml:"cleanupPolicy"`
+
+	// Key sets the key strategy of the topic's records, which is one of the
+	// KeyStrategy constants. Unlike the other overrides it also applies to
+	// topics that exist already. Empty uses the topic's entity id.
+	Key string `yaml:"key"`
+
+	// Partitioner of the topic's records, which is one of the Partitioner
+	// constants. Like the key strategy it also applies to topics that exist
+	// already. Empty uses the partitioner of the Kafka client.
+	Partitioner string `yaml:"partitioner"`
+
+	// Partition that all records are produced to by the manual partitioner.
+	// Records fail if the topic has fewer partitions.
+	Partition int32 `yaml:"partition"`
+
+	// SubjectNameStrategy of the topic's value schema, which is one of the
+	// SubjectNameStrategy constants. Like the key strategy it also applies to
+	// topics that exist already. Empty uses the topic name strategy.
+	SubjectNameStrategy string `yaml:"subjectNameStrategy"`
+}
+
+const (
+	// KeyStrategyEntity keys records by the id of the topic's entity, e.g.
+	// the order id on the orders topic. This is the default.
+	KeyStrategyEntity = "entity"
+	// KeyStrategyCustomer keys records by the id of the customer that they
+	// refer to, so that topics are co-partitioned by customer.
+	KeyStrategyCustomer = "customer"
+	// KeyStrategyNull produces records without key, which are spread over
+	// all partitions. Compacted topics reject records without key.
+	KeyStrategyNull = "null"
+	// KeyStrategyComposite keys records by the customer id and the entity
+	// id, separated by a colon.
+	KeyStrategyComposite = "composite"
+)
+
+const (
+	// PartitionerDefault is the default partitioner of the Kafka client,
+	// which hashes keys with murmur2 and spreads records without key evenly
+	// by their size.
+	PartitionerDefault = "default"
+	// PartitionerMurmur2 partitions records like the default partitioner of
+	// the Java client by the murmur2 hash of their key. Records without key
+	// stick to a partition until the partition's batch is full.
+	PartitionerMurmur2 = "murmur2"
+	// PartitionerCRC32 partitions records by the CRC32 hash of their key like
+	// the consistent_random default partitioner of librdkafka.
+	PartitionerCRC32 = "crc32"
+	// PartitionerFNV1a partitions records by the FNV-1a hash of their key
+	// like the default hash partitioner of Sarama.
+	PartitionerFNV1a = "fnv1a"
+	// PartitionerRoundRobin produces records to all partitions in turn,
+	// regardless of their key.
+	PartitionerRoundRobin = "round-robin"
+	// PartitionerManual produces all records to the topic's partition.
+	PartitionerManual = "manual"
+)
+
+const (
+	// SubjectNameStrategyTopic names the subject after the topic, e.g.
+	// "orders-value". This is the default and matches the TopicNameStrategy
+	// of the Confluent serializers.
+	SubjectNameStrategyTopic = "topic"
+	// SubjectNameStrategyRecord names the subject after the fully qualified
+	// name of the schema's record, e.g. "shop.v1.Order", so that topics with
+	// the same record type share the subject. It matches the
+	// RecordNameStrategy of the Confluent serializers, so the subject has no
+	// prefix and is neither separated by profiles and tenants nor deleted by
+	// the cleanup.
+	SubjectNameStrategyRecord = "record"
+	// SubjectNameStrategyTopicRecord names the subject after the prefixed
+	// topic and the fully qualified record name, e.g.
+	// "owlshop-orders-shop.v1.Order". It matches the TopicRecordNameStrategy
+	// of the Confluent serializers, so it ignores the subject prefix.
+	SubjectNameStrategyTopicRecord = "topic-record"
+)
+
+// Validate topic config.
+func (c *Topic) Validate() error {
+	if c.PartitionCount < -1 {
+		return fmt.Errorf("partition count must be a positive integer or '-1' for using the default partition count")
+	}
+
+	if c.ReplicationFactor < -1 {
+		return fmt.Errorf("replication factor must be a positive integer or '-1' for using default replication factor")
+	}
+
+	if c.RetentionMs < -1 {
+		return fmt.Errorf("retention ms must be a positive integer or '-1' for unlimited retention")
+	}
+
+	if c.RetentionBytes < -1 {
+		return fmt.Errorf("retention bytes must be a positive integer or '-1' for unlimited retention")
+	}
+
+	switch c.CleanupPolicy {
+	case "", "delete", "compact", "compact,delete", "delete,compact":
+		// Valid and supported
+	default:
+		return fmt.Errorf("given cleanup policy '%v' is invalid", c.CleanupPolicy)
+	}
+
+	switch c.Key {
+	case "", KeyStrategyEntity, KeyStrategyCustomer, KeyStrategyComposite, KeyStrategyNull:
+		// Valid and supported
+	default:
+		return fmt.Errorf("given key strategy '%v' is invalid", c.Key)
+	}
+
+	switch c.Partitioner {
+	case "", PartitionerDefault, PartitionerMurmur2, PartitionerCRC32, PartitionerFNV1a, PartitionerRoundRobin, PartitionerManual:
+		// Valid and supported
+	default:
+		return fmt.Errorf("given partitioner '%v' is invalid", c.Partitioner)
+	}
+
+	if c.Partition < 0 {
+		return fmt.Errorf("partition must not be negative")
+	}
+
+	switch c.SubjectNameStrategy {
+	case "", SubjectNameStrategyTopic, SubjectNameStrategyRecord, SubjectNameStrategyTopicRecord:
+		// Valid and supported
+	default:
+		return fmt.Errorf("given subject name strategy '%v' is invalid", c.SubjectNameStrategy)
+	}
+
+	return nil
+}
+
+// compactedTopics are the names of the topics, without topic prefix, that are
+// created with cleanup policy compact unless overridden.
+var compactedTopics = map[string]bool{
+	"addresses":     true,
+	"customers":     true,
+	"orders":        true,
+	"products":      true,
+	"product-media": true,
+	"reviews":       true,
+}
+
+// validateKey checks that the key strategy fits the effective cleanup policy
+// of the topic with the given name, because compacted topics reject records
+// without key.
+func (c *Topic) validateKey(name string) error {
+	if c.Key != KeyStrategyNull {
+		return nil
+	}
+
+	if cleanupPolicy := c.cleanupPolicy(name); strings.Contains(cleanupPolicy, "compact") {
+		return fmt.Errorf("key strategy '%v' can't be used with cleanup policy '%v'", c.Key, cleanupPolicy)
+	}
+
+	return nil
+}
+
+// cleanupPolicy returns the cleanup policy that the topic with the given name
+// is created with.
+func (c *Topic) cleanupPolicy(name string) string {
+	if c.CleanupPolicy == "" && compactedTopics[name] {
+		return "compact"
+	}
+	return c.CleanupPolicy
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"time"
+	// The time zone database is embedded, because the diurnal pattern
+	// resolves its time zone in containers that come without one
+	_ "time/tzdata"
+)
+
+const (
+	TrafficPatternConstant   = "constant"
+	TrafficPatternSinusoidal = "sinusoidal"
+	TrafficPatternSpikes     = "spikes"
+	TrafficPatternRamp       = "ramp"
+	TrafficPatternDiurnal    = "diurnal"
+)
+
+// Traffic configures the shape of the simulated load. The pattern scales the
+// configured request rate over time, so that consumer lag and throughput
+// dashboards show realistic curves rather than a flat line.
+type Traffic struct {
+	// Pattern is the load shape. Valid values are constant, sinusoidal,
+	// spikes, ramp and diurnal.
+	Pattern string `yaml:"pattern"`
+
+	Sinusoidal SinusoidalTraffic `yaml:"sinusoidal"`
+	Spikes     SpikesTraffic     `yaml:"spikes"`
+	Ramp       RampTraffic       `yaml:"ramp"`
+	Diurnal    DiurnalTraffic    `yaml:"diurnal"`
+}
+
+// SetDefaults for traffic config.
+func (c *Traffic) SetDefaults() {
+	c.Pattern = TrafficPatternConstant
+	c.Sinusoidal.SetDefaults()
+	c.Spikes.SetDefaults()
+	c.Ramp.SetDefaults()
+	c.Diurnal.SetDefaults()
+}
+
+// Validate traffic config.
+func (c *Traffic) Validate() error {
+	switch c.Pattern {
+	case TrafficPatternConstant:
+		// Valid and supported
+	case TrafficPatternSinusoidal:
+		if err := c.Sinusoidal.Validate(); err != nil {
+			return fmt.Errorf("failed to validate sinusoidal traffic config: %w", err)
+		}
+	case TrafficPatternSpikes:
+		if err := c.Spikes.Validate(); err != nil {
+			return fmt.Errorf("failed to validate spikes traffic config: %w", err)
+		}
+	case TrafficPatternRamp:
+		if err := c.Ramp.Validate(); err != nil {
+			return fmt.Errorf("failed to validate ramp traffic config: %w", err)
+		}
+	case TrafficPatternDiurnal:
+		if err := c.Diurnal.Validate(); err != nil {
+			return fmt.Errorf("failed to validate diurnal traffic config: %w", err)
+		}
+	default:
+		return fmt.Errorf("given traffic pattern '%v' is invalid", c.Pattern)
+	}
+
+	return nil
+}
+
+// SinusoidalTraffic oscillates the request rate around the configured rate,
+// which resembles the diurnal load of a shop.
+type SinusoidalTraffic struct {
+	// Period is the duration of one full oscillation.
+	Period time.Duration `yaml:"period"`
+
+	// Amplitude is the relative deviation from the configured request rate,
+	// in the range (0, 1]. An amplitude of 0.5 varies the rate between 50%
+	// and 150% of the configured rate.
+	Amplitude float64 `yaml:"amplitude"`
+}
+
+// SetDefaults for sinusoidal traffic config.
+func (c *SinusoidalTraffic) SetDefaults() {
+	c.Period = 10 * time.Minute
+	c.Amplitude = 0.5
+}
+
+// Validate sinusoidal traffic config.
+func (c *SinusoidalTraffic) Validate() error {
+	if c.Period <= 0 {
+		return fmt.Errorf("period must be a positive duration (e.g. '10m')")
+	}
+
+	if c.Amplitude <= 0 || c.Amplitude > 1 {
+		return fmt.Errorf("amplitude must be greater than 0 and less than or equal to 1")
+	}
+
+	return nil
+}
+
+// SpikesTraffic keeps the configured request rate, but regularly multiplies it
+// for a short duration.
+type SpikesTraffic struct {
+	// Interval is the duration between the start of two consecutive spikes.
+	Interval time.Duration `yaml:"interval"`
+
+	// Duration of each spike.
+	Duration time.Duration `yaml:"duration"`
+
+	// Factor by which the request rate is multiplied during a spike.
+	Factor float64 `yaml:"factor"`
+}
+
+// SetDefaults for spikes traffic config.
+func (c *SpikesTraffic) SetDefaults() {
+	c.Interval = 5 * time.Minute
+	c.Duration = 30 * time.Second
+	c.Factor = 10
+}
+
+// Validate spikes traffic config.
+func (c *SpikesTraffic) Validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be a positive duration (e.g. '5m')")
+	}
+
+	if c.Duration <= 0 || c.Duration > c.Interval {
+		return fmt.Errorf("duration must be a positive duration that is not longer than the interval")
+	}
+
+	if c.Factor < 1 {
+		return fmt.Errorf("factor must be greater than or equal to 1")
+	}
+
+	return nil
+}
+
+// RampTraffic repeatedly ramps the request rate up from zero to the configured
+// rate, holds it and ramps it down to zero again.
+type RampTraffic struct {
+	RampUp   time.Duration `yaml:"rampUp"`
+	Hold     time.Duration `yaml:"hold"`
+	RampDown time.Duration `yaml:"rampDown"`
+}
+
+// SetDefaults for ramp traffic config.
+func (c *RampTraffic) SetDefaults() {
+	c.RampUp = 5 * time.Minute
+	c.Hold = 10 * time.Minute
+	c.RampDown = 5 * time.Minute
+}
+
+// Validate ramp traffic config.
+func (c *RampTraffic) Validate() error {
+	if c.RampUp < 0 || c.Hold < 0 || c.RampDown < 0 {
+		return fmt.Errorf("ramp up, hold and ramp down must not be negative")
+	}
+
+	if c.RampUp+c.Hold+c.RampDown == 0 {
+		return fmt.Errorf("at least one of ramp up, hold and ramp down must be a positive duration")
+	}
+
+	return nil
+}
+
+// DiurnalTraffic follows the day and night curve of an e-commerce shop in the
+// configured time zone: the rate is lowest at 4am and peaks at lunch and in
+// the evening, with a boost on weekends. The curve is based on the simulated
+// time, so that long running clusters show believable weekly dashboards and
+// time acceleration compresses a week into hours.
+type DiurnalTraffic struct {
+	// Timezone is the IANA time zone of the shop's customers, e.g.
+	// Europe/Berlin. Defaults to UTC.
+	Timezone string `yaml:"timezone"`
+
+	// WeekendBoost is the factor by which the rate is multiplied on
+	// Saturdays and Sundays. Defaults to 1.2.
+	WeekendBoost float64 `yaml:"weekendBoost"`
+
+	// HourlyWeights optionally replace the built-in curve with 24 relative
+	// weights, one for each hour of the day starting at midnight. The rate
+	// is interpolated linearly between the hours.
+	HourlyWeights []float64 `yaml:"hourlyWeights"`
+}
+
+// SetDefaults for diurnal traffic config.
+func (c *DiurnalTraffic) SetDefaults() {
+	c.Timezone = "UTC"
+	c.WeekendBoost = 1.2
+}
+
+// Validate diurnal traffic config.
+func (c *DiurnalTraffic) Validate() error {
+	if _, err := c.Location(); err != nil {
+		return err
+	}
+
+	if c.WeekendBoost <= 0 {
+		return fmt.Errorf("weekend boost must be greater than 0")
+	}
+
+	if len(c.HourlyWeights) == 0 {
+		return nil
+	}
+	if len(c.HourlyWeights) != 24 {
+		return fmt.Errorf("hourly weights must consist of 24 weights, one for each hour of the day")
+	}
+	total := 0.0
+	for _, weight := range c.HourlyWeights {
+		if weight < 0 {
+			return fmt.Errorf("hourly weights must not be negative")
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one hourly weight must be greater than 0")
+	}
+
+	return nil
+}
+
+// Location returns the configured time zone.
+func (c *DiurnalTraffic) Location() (*time.Location, error) {
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load time zone: %w", err)
+	}
+
+	return location, nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Transactions configures the transactional mode of the order service. If
+// enabled, each order is written together with the decremented stock of the
+// ordered products and a customer activity record in a single Kafka
+// transaction. Some transactions are aborted on purpose, so that the
+// difference between read_committed and read_uncommitted consumers can be
+// demonstrated.
+type Transactions struct {
+	Enabled bool `yaml:"enabled"`
+
+	// AbortRatio is the share of transactions that are aborted, in the
+	// range [0, 1].
+	AbortRatio float64 `yaml:"abortRatio"`
+}
+
+// SetDefaults for transactions config.
+func (c *Transactions) SetDefaults() {
+	c.Enabled = false
+	c.AbortRatio = 0.1
+}
+
+// Validate transactions config.
+func (c *Transactions) Validate() error {
+	if c.AbortRatio < 0 || c.AbortRatio > 1 {
+		return fmt.Errorf("abort ratio must be between 0 and 1")
+	}
+
+	return nil
+}
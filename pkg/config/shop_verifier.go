@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Verifier configures a consumer that reads all topics of the shop and
+// measures the end-to-end latency from producing to consuming each record, as
+// well as offset gaps and ordering violations per partition. The results are
+// exposed as Prometheus metrics, so that the shop doubles as a lightweight
+// health probe of the cluster.
+type Verifier struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Cluster is the name of the Kafka cluster whose topics are verified.
+	// Defaults to the default cluster.
+	Cluster string `yaml:"cluster"`
+
+	// TopicRegex selects the verified topics. Defaults to all topics with the
+	// shop's topic prefix.
+	TopicRegex string `yaml:"topicRegex"`
+}
+
+// Validate verifier config.
+func (c *Verifier) Validate() error {
+	if !c.Enabled || c.TopicRegex == "" {
+		return nil
+	}
+
+	if _, err := regexp.Compile(c.TopicRegex); err != nil {
+		return fmt.Errorf("failed to compile topic regex: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Warehouses configures the warehouses of the inventory. If enabled, the
+// inventory service tracks the stock level of each article per warehouse,
+// transfers stock between warehouses once a warehouse runs low and produces a
+// stock-out event whenever the ordered quantity exceeds the stock of all
+// warehouses.
+type Warehouses struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Names of the warehouses, which are used as warehouse ids.
+	Names []string `yaml:"names"`
+
+	// InitialStock is the stock level of each article in each warehouse once
+	// the article is ordered for the first time. Restocked articles are
+	// restocked to this level as well.
+	InitialStock int `yaml:"initialStock"`
+
+	// TransferThreshold is the stock level below which a warehouse is
+	// supplied by the warehouse with the most stock of the article.
+	TransferThreshold int `yaml:"transferThreshold"`
+
+	// TransferQuantity is the quantity of each transfer between two
+	// warehouses.
+	TransferQuantity int `yaml:"transferQuantity"`
+
+	// RestockDelay is the duration after a stock-out after which the article
+	// is restocked in the warehouse.
+	RestockDelay time.Duration `yaml:"restockDelay"`
+
+	// MaxArticles is the number of articles whose stock levels are tracked.
+	// Once it is exceeded, a random article is dropped and its stock starts
+	// over with the initial stock when it is ordered again.
+	MaxArticles int `yaml:"maxArticles"`
+}
+
+// SetDefaults for warehouses config.
+func (c *Warehouses) SetDefaults() {
+	c.Enabled = false
+	c.Names = []string{"berlin", "rotterdam", "milan"}
+	c.InitialStock = 50
+	c.TransferThreshold = 10
+	c.TransferQuantity = 10
+	c.RestockDelay = 10 * time.Minute
+	c.MaxArticles = 100000
+}
+
+// Validate warehouses config.
+func (c *Warehouses) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Names) == 0 {
+		return fmt.Errorf("at least one warehouse name must be set")
+	}
+	seen := make(map[string]struct{}, len(c.Names))
+	for _, name := range c.Names {
+		if name == "" {
+			return fmt.Errorf("warehouse names must not be empty")
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("warehouse name '%v' is not unique", name)
+		}
+		seen[name] = struct{}{}
+	}
+
+	if c.InitialStock <= 0 {
+		return fmt.Errorf("initial stock must be greater than 0")
+	}
+
+	if c.TransferThreshold < 0 {
+		return fmt.Errorf("transfer threshold must not be negative")
+	}
+
+	if c.TransferQuantity <= 0 {
+		return fmt.Errorf("transfer quantity must be greater than 0")
+	}
+
+	if c.RestockDelay < 0 {
+		return fmt.Errorf("restock delay must not be negative")
+	}
+
+	if c.MaxArticles <= 0 {
+		return fmt.Errorf("max articles must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook configures the HTTP sink, which POSTs all records that have been
+// successfully produced to Kafka to a webhook endpoint as well, so that
+// systems without a Kafka integration can consume the shop's events. Records
+// are sent in batches, each of which is a JSON array of events.
+type Webhook struct {
+	Enabled bool `yaml:"enabled"`
+
+	// URL is the http or https endpoint that the batches are POSTed to.
+	URL string `yaml:"url"`
+
+	// Headers are added to each request, e.g. an Authorization header.
+	Headers map[string]string `yaml:"headers"`
+
+	// BatchSize is the max number of events per request.
+	BatchSize int `yaml:"batchSize"`
+
+	// FlushInterval is the max time an event is buffered before its batch is
+	// sent, even if the batch is not full.
+	FlushInterval time.Duration `yaml:"flushInterval"`
+
+	// Timeout of each request.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// MaxRetries is the number of times a batch is resent if the request
+	// failed or the endpoint responded with status 429 or 5xx. The backoff
+	// doubles with each retry.
+	MaxRetries   int           `yaml:"maxRetries"`
+	RetryBackoff time.Duration `yaml:"retryBackoff"`
+
+	// BufferSize is the number of events that are buffered while batches are
+	// being sent. Events are dropped if the buffer is full, so that a slow
+	// endpoint does not slow down the traffic to Kafka.
+	BufferSize int `yaml:"bufferSize"`
+}
+
+// SetDefaults for webhook config.
+func (c *Webhook) SetDefaults() {
+	c.Enabled = false
+	c.BatchSize = 100
+	c.FlushInterval = time.Second
+	c.Timeout = 10 * time.Second
+	c.MaxRetries = 3
+	c.RetryBackoff = 500 * time.Millisecond
+	c.BufferSize = 10000
+}
+
+// Validate webhook config.
+func (c *Webhook) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("failed to parse url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https url")
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("batch size must be a positive number")
+	}
+	if c.FlushInterval <= 0 {
+		return fmt.Errorf("flush interval must be a positive duration")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be a positive duration")
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	if c.BufferSize < c.BatchSize {
+		return fmt.Errorf("buffer size must not be smaller than the batch size")
+	}
+
+	return nil
+}
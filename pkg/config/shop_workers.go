@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	// WorkersOverflowDelay makes the traffic simulation wait until the queue
+	// has room for the next page impression.
+	WorkersOverflowDelay = "delay"
+	// WorkersOverflowDrop drops page impressions while the queue is full.
+	WorkersOverflowDrop = "drop"
+)
+
+// Workers configures the goroutines that simulate page impressions and the
+// queue of page impressions that wait for an idle worker.
+type Workers struct {
+	// Count is the number of page impressions that are simulated
+	// concurrently, so that the number of goroutines stays bounded at high
+	// rates or with a slow cluster. Defaults to 64.
+	Count int `yaml:"count"`
+
+	// QueueSize is the number of page impressions that wait for an idle
+	// worker, which absorbs short bursts. Defaults to 1000.
+	QueueSize int `yaml:"queueSize"`
+
+	// Overflow decides what happens to page impressions while the queue is
+	// full, which means that the cluster can't keep up with the rate. Valid
+	// values are delay and drop. Defaults to delay.
+	Overflow string `yaml:"overflow"`
+}
+
+// SetDefaults for workers config.
+func (c *Workers) SetDefaults() {
+	c.Count = 64
+	c.QueueSize = 1000
+	c.Overflow = WorkersOverflowDelay
+}
+
+// Validate workers config.
+func (c *Workers) Validate() error {
+	if c.Count <= 0 {
+		return fmt.Errorf("count must be greater than 0")
+	}
+
+	if c.QueueSize < 0 {
+		return fmt.Errorf("queue size must not be negative")
+	}
+
+	switch c.Overflow {
+	case WorkersOverflowDelay, WorkersOverflowDrop:
+	default:
+		return fmt.Errorf("overflow must be either '%v' or '%v'", WorkersOverflowDelay, WorkersOverflowDrop)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"crypto/tls"
+	"fmt"
 
 	"github.com/twmb/tlscfg"
 )
@@ -13,17 +14,55 @@ type TLS struct {
 	CertFilepath          string `yaml:"certFilepath"`
 	KeyFilepath           string `yaml:"keyFilepath"`
 	InsecureSkipTLSVerify bool   `yaml:"insecureSkipTlsVerify"`
+
+	// ReloadCertificate reloads the client certificate and key from disk
+	// when they change, so that rotated short-lived certificates (e.g. issued
+	// by cert-manager or Vault) are used for new connections without a
+	// restart.
+	ReloadCertificate bool `yaml:"reloadCertificate"`
+}
+
+// Validate TLS config.
+func (c *TLS) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if (c.CertFilepath == "") != (c.KeyFilepath == "") {
+		return fmt.Errorf("cert filepath and key filepath must be set together")
+	}
+
+	if c.ReloadCertificate && c.CertFilepath == "" {
+		return fmt.Errorf("reloading the certificate requires a cert filepath and key filepath")
+	}
+
+	return nil
 }
 
 // TLSConfig builds a tls.Config based on the configuration.
 func (c *TLS) TLSConfig() (*tls.Config, error) {
+	certFilepath, keyFilepath := c.CertFilepath, c.KeyFilepath
+	var reloader *certificateReloader
+	if c.ReloadCertificate {
+		var err error
+		reloader, err = newCertificateReloader(c.CertFilepath, c.KeyFilepath)
+		if err != nil {
+			return nil, err
+		}
+		// The key pair is served by the reloader instead
+		certFilepath, keyFilepath = "", ""
+	}
+
 	return tlscfg.New(
 		tlscfg.MaybeWithDiskCA(c.CaFilepath, tlscfg.ForClient),
-		tlscfg.MaybeWithDiskKeyPair(c.CertFilepath, c.KeyFilepath),
+		tlscfg.MaybeWithDiskKeyPair(certFilepath, keyFilepath),
 		tlscfg.WithOverride(func(config *tls.Config) error {
 			if c.InsecureSkipTLSVerify {
 				config.InsecureSkipVerify = true
 			}
+			if reloader != nil {
+				config.GetClientCertificate = reloader.getClientCertificate
+			}
 			return nil
 		}),
 	)
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certificateReloader serves a client certificate that is reloaded from disk
+// whenever the modification time of the cert or key file changes. If a
+// changed key pair can't be loaded, e.g. because only one of both files has
+// been rotated yet, the previous certificate is served until the next
+// handshake tries again.
+type certificateReloader struct {
+	certFilepath string
+	keyFilepath  string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertificateReloader(certFilepath, keyFilepath string) (*certificateReloader, error) {
+	r := &certificateReloader{
+		certFilepath: certFilepath,
+		keyFilepath:  keyFilepath,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// getClientCertificate is used as tls.Config.GetClientCertificate, which is
+// called on each handshake of a new connection.
+func (r *certificateReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Keep serving the previous certificate if the reload fails
+	_ = r.reloadIfChanged()
+
+	return r.certificate, nil
+}
+
+func (r *certificateReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reloadIfChanged()
+}
+
+// reloadIfChanged loads the key pair if any of the files has been modified
+// since it has been loaded last. It must be called while holding the lock.
+func (r *certificateReloader) reloadIfChanged() error {
+	certInfo, err := os.Stat(r.certFilepath)
+	if err != nil {
+		return fmt.Errorf("failed to stat cert file: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFilepath)
+	if err != nil {
+		return fmt.Errorf("failed to stat key file: %w", err)
+	}
+
+	if r.certificate != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFilepath, r.keyFilepath)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	r.certificate = &certificate
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+
+	return nil
+}
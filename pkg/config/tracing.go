@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Tracing configures the export of OpenTelemetry traces via OTLP/HTTP. Each
+// page impression starts a trace that spans the producing service and all
+// services that consume its records, e.g. orders, payments and shipments.
+type Tracing struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Endpoint is the host and port of the OTLP/HTTP receiver, e.g.
+	// "otel-collector:4318".
+	Endpoint string `yaml:"endpoint"`
+
+	// Insecure sends the spans via plaintext HTTP rather than HTTPS.
+	Insecure bool `yaml:"insecure"`
+
+	// Headers are sent along with each export request, e.g. for
+	// authenticating against a tracing backend.
+	Headers map[string]string `yaml:"headers"`
+
+	// SampleRatio is the share of traces that are exported.
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// SetDefaults for tracing config.
+func (c *Tracing) SetDefaults() {
+	c.Enabled = false
+	c.Endpoint = "localhost:4318"
+	c.SampleRatio = 1
+}
+
+// Validate tracing config.
+func (c *Tracing) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Endpoint == "" {
+		return fmt.Errorf("endpoint must be set")
+	}
+
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample ratio must be between 0 and 1")
+	}
+
+	return nil
+}
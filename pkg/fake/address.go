@@ -1,7 +1,6 @@
 package fake
 
 import (
-	"math/rand"
 	"strconv"
 	"time"
 
@@ -19,8 +18,15 @@ const (
 	AddressTypeDelivery AddressType = "DELIVERY"
 )
 
-func NewAddress(customer Customer) Address {
-	address := gofakeit.Address()
+// NewAddress returns an address in the country of the customer's locale.
+// Customers without locale are given an address of the default locale. The
+// address has been created at the given time.
+func NewAddress(customer Customer, createdAt time.Time) Address {
+	locale := customer.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	address := newLocalAddress(locale)
 
 	return Address{
 		Version: 0,
@@ -34,20 +40,40 @@ func NewAddress(customer Customer) Address {
 		Type:                  newAddressType(),
 		FirstName:             customer.FirstName,
 		LastName:              customer.LastName,
-		State:                 address.State,
-		Street:                address.Street,
+		State:                 address.state,
+		Street:                address.street,
 		HouseNumber:           strconv.Itoa(gofakeit.Number(1, 1000)),
-		City:                  address.City,
-		Zip:                   address.Zip,
-		Latitude:              address.Latitude,
-		Longitude:             address.Longitude,
-		Phone:                 gofakeit.PhoneFormatted(),
+		City:                  address.city,
+		Zip:                   address.zip,
+		Country:               countryOfLocale(locale),
+		Latitude:              address.latitude,
+		Longitude:             address.longitude,
+		Phone:                 address.phone,
 		AdditionalAddressInfo: newAdditionalAddressInfo(),
-		CreatedAt:             time.Now(),
+		CreatedAt:             createdAt,
 		Revision:              0,
 	}
 }
 
+// ChangeAddress returns the next revision of the given address, as if the
+// customer moved within the address's country. The address keeps its ID, type
+// and customer.
+func ChangeAddress(address Address) Address {
+	moved := newLocalAddress(LocaleOfCountry(address.Country))
+
+	address.State = moved.state
+	address.Street = moved.street
+	address.HouseNumber = strconv.Itoa(gofakeit.Number(1, 1000))
+	address.City = moved.city
+	address.Zip = moved.zip
+	address.Latitude = moved.latitude
+	address.Longitude = moved.longitude
+	address.AdditionalAddressInfo = newAdditionalAddressInfo()
+	address.Revision++
+
+	return address
+}
+
 type Address struct {
 	// VersionedStruct
 	Version int    `json:"version"`
@@ -64,6 +90,7 @@ type Address struct {
 	HouseNumber           string      `json:"houseNumber"`
 	City                  string      `json:"city"`
 	Zip                   string      `json:"zip"`
+	Country               string      `json:"country"` // ISO 3166-1 alpha-2 country code
 	Latitude              float64     `json:"latitude"`
 	Longitude             float64     `json:"longitude"`
 	Phone                 string      `json:"phone"`
@@ -84,6 +111,7 @@ func (a *Address) Protobuf() *shoppb.Address {
 		HouseNumber:           a.HouseNumber,
 		City:                  a.City,
 		Zip:                   a.Zip,
+		Country:               a.Country,
 		Latitude:              float32(a.Latitude),
 		Longitude:             float32(a.Longitude),
 		Phone:                 a.Phone,
@@ -93,6 +121,32 @@ func (a *Address) Protobuf() *shoppb.Address {
 	}
 }
 
+// NewAddressFromProtobuf converts a protobuf address into an Address.
+func NewAddressFromProtobuf(pb *shoppb.Address) Address {
+	return Address{
+		Version: int(pb.GetVersion()),
+		ID:      pb.GetId(),
+		Customer: AddressCustomer{
+			CustomerID:   pb.GetCustomer().GetCustomerId(),
+			CustomerType: CustomerType(pb.GetCustomer().GetCustomerType()),
+		},
+		Type:                  AddressType(pb.GetType()),
+		FirstName:             pb.GetFirstName(),
+		LastName:              pb.GetLastName(),
+		State:                 pb.GetState(),
+		HouseNumber:           pb.GetHouseNumber(),
+		City:                  pb.GetCity(),
+		Zip:                   pb.GetZip(),
+		Country:               pb.GetCountry(),
+		Latitude:              float64(pb.GetLatitude()),
+		Longitude:             float64(pb.GetLongitude()),
+		Phone:                 pb.GetPhone(),
+		AdditionalAddressInfo: pb.GetAdditionalAddressInfo(),
+		CreatedAt:             pb.GetCreatedAt().AsTime(),
+		Revision:              int(pb.GetRevision()),
+	}
+}
+
 type AddressCustomer struct {
 	CustomerID   string       `json:"id"`
 	CustomerType CustomerType `json:"type"`
@@ -107,7 +161,6 @@ func (a *AddressCustomer) Protobuf() *shoppb.Address_Customer {
 
 // newAddressType returns an address type based on a weighted random choice
 func newAddressType() AddressType {
-	rand.Seed(time.Now().UTC().UnixNano())
 	c, err := weightedrand.NewChooser(
 		weightedrand.Choice{Item: AddressTypeDelivery, Weight: 20},
 		weightedrand.Choice{Item: AddressTypeInvoice, Weight: 80},
@@ -120,7 +173,6 @@ func newAddressType() AddressType {
 }
 
 func newAdditionalAddressInfo() string {
-	rand.Seed(time.Now().UTC().UnixNano())
 	c, err := weightedrand.NewChooser(
 		weightedrand.Choice{Item: "", Weight: 200},
 
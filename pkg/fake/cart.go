@@ -0,0 +1,156 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
+)
+
+type CartEventType string
+
+const (
+	CartEventTypeCreated     CartEventType = "CART_CREATED"
+	CartEventTypeItemAdded   CartEventType = "ITEM_ADDED"
+	CartEventTypeItemRemoved CartEventType = "ITEM_REMOVED"
+	CartEventTypeCheckedOut  CartEventType = "CHECKED_OUT"
+	CartEventTypeAbandoned   CartEventType = "ABANDONED"
+)
+
+// Cart is a customer's shopping cart, which is either checked out or abandoned
+// eventually.
+type Cart struct {
+	ID       string          `json:"id"`
+	Customer Customer        `json:"customer"`
+	Items    []OrderLineItem `json:"items"`
+	Currency string          `json:"currency"`
+
+	pricing Pricing
+}
+
+// NewCart returns an empty cart, which is priced in the customer's currency.
+func NewCart(customer Customer, pricing Pricing) Cart {
+	return Cart{
+		ID:       gofakeit.UUID(),
+		Customer: customer,
+		Items:    make([]OrderLineItem, 0),
+		Currency: pricing.Currency(customer),
+		pricing:  pricing,
+	}
+}
+
+// AddItem adds a random quantity of the given product to the cart and returns
+// the added line item.
+func (c *Cart) AddItem(product Product) OrderLineItem {
+	item := newOrderLineItem(product, gofakeit.Number(1, 10), c.pricing, c.Currency)
+	c.Items = append(c.Items, item)
+
+	return item
+}
+
+// RemoveItem removes the line item at the given index and returns it.
+func (c *Cart) RemoveItem(i int) OrderLineItem {
+	item := c.Items[i]
+	c.Items = append(c.Items[:i], c.Items[i+1:]...)
+
+	return item
+}
+
+// CartEvent describes a single change of a shopping cart.
+type CartEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID         string        `json:"id"`
+	Type       CartEventType `json:"type"`
+	CartID     string        `json:"cartId"`
+	CustomerID string        `json:"customerId"`
+	// Item is the added or removed line item. It is only set for the
+	// ITEM_ADDED and ITEM_REMOVED events.
+	Item *OrderLineItem `json:"item"`
+	// OrderID references the order that has been placed upon checkout. It is
+	// only set for the CHECKED_OUT event.
+	OrderID   *string   `json:"orderId"`
+	CartValue int       `json:"cartValue"` // In minor units of the currency, e.g. cents
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewCartEvent creates a cart event of the given type that reflects the current
+// state of the cart at the given time.
+func NewCartEvent(cart Cart, eventType CartEventType, item *OrderLineItem, orderID *string, createdAt time.Time) CartEvent {
+	cartValue := 0
+	for _, cartItem := range cart.Items {
+		cartValue += cartItem.TotalPrice
+	}
+
+	return CartEvent{
+		Version:    0,
+		ID:         gofakeit.UUID(),
+		Type:       eventType,
+		CartID:     cart.ID,
+		CustomerID: cart.Customer.ID,
+		Item:       item,
+		OrderID:    orderID,
+		CartValue:  cartValue,
+		Currency:   cart.Currency,
+		CreatedAt:  createdAt,
+	}
+}
+
+func (e *CartEvent) Protobuf() *shoppb.CartEvent {
+	var item *shoppb.CartEvent_Item
+	if e.Item != nil {
+		item = &shoppb.CartEvent_Item{
+			ArticleId:    e.Item.ArticleID,
+			Name:         e.Item.Name,
+			Quantity:     int32(e.Item.Quantity),
+			QuantityUnit: e.Item.QuantityUnit,
+			UnitPrice:    int32(e.Item.UnitPrice),
+			TotalPrice:   int32(e.Item.TotalPrice),
+		}
+	}
+
+	return &shoppb.CartEvent{
+		Version:    int32(e.Version),
+		Id:         e.ID,
+		Type:       string(e.Type),
+		CartId:     e.CartID,
+		CustomerId: e.CustomerID,
+		Item:       item,
+		OrderId:    e.OrderID,
+		CartValue:  int32(e.CartValue),
+		Currency:   e.Currency,
+		CreatedAt:  timestamppb.New(e.CreatedAt),
+	}
+}
+
+// NewCartEventFromProtobuf converts a protobuf cart event into a CartEvent.
+func NewCartEventFromProtobuf(pb *shoppb.CartEvent) CartEvent {
+	var item *OrderLineItem
+	if pb.GetItem() != nil {
+		item = &OrderLineItem{
+			ArticleID:    pb.GetItem().GetArticleId(),
+			Name:         pb.GetItem().GetName(),
+			Quantity:     int(pb.GetItem().GetQuantity()),
+			QuantityUnit: pb.GetItem().GetQuantityUnit(),
+			UnitPrice:    int(pb.GetItem().GetUnitPrice()),
+			TotalPrice:   int(pb.GetItem().GetTotalPrice()),
+		}
+	}
+
+	return CartEvent{
+		Version:    int(pb.GetVersion()),
+		ID:         pb.GetId(),
+		Type:       CartEventType(pb.GetType()),
+		CartID:     pb.GetCartId(),
+		CustomerID: pb.GetCustomerId(),
+		Item:       item,
+		OrderID:    pb.OrderId,
+		CartValue:  int(pb.GetCartValue()),
+		Currency:   pb.GetCurrency(),
+		CreatedAt:  pb.GetCreatedAt().AsTime(),
+	}
+}
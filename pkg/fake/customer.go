@@ -1,6 +1,8 @@
 package fake
 
 import (
+	"strings"
+
 	"github.com/brianvoe/gofakeit/v5"
 	"github.com/mroth/weightedrand"
 
@@ -14,6 +16,26 @@ const (
 	CustomerTypeBusiness CustomerType = "BUSINESS"
 )
 
+// CustomerSegment classifies customers by their orders.
+type CustomerSegment string
+
+const (
+	CustomerSegmentNew       CustomerSegment = "NEW"
+	CustomerSegmentReturning CustomerSegment = "RETURNING"
+	CustomerSegmentVIP       CustomerSegment = "VIP"
+)
+
+// LoyaltyTier is the tier of a customer's loyalty program membership, which
+// depends on the collected loyalty points.
+type LoyaltyTier string
+
+const (
+	LoyaltyTierBronze   LoyaltyTier = "BRONZE"
+	LoyaltyTierSilver   LoyaltyTier = "SILVER"
+	LoyaltyTierGold     LoyaltyTier = "GOLD"
+	LoyaltyTierPlatinum LoyaltyTier = "PLATINUM"
+)
+
 type Customer struct {
 	// VersionedStruct
 	Version int `json:"version"`
@@ -26,6 +48,15 @@ type Customer struct {
 	Email        string       `json:"email"`
 	CustomerType CustomerType `json:"customerType"` // PERSONAL | BUSINESS
 	Revision     int          `json:"revision"`     // Each change on the customer increments the revision
+	Locale       string       `json:"locale"`       // Locale of the customer's name and addresses, e.g. de_DE
+
+	// Loyalty attributes, which evolve as the customer places orders. They
+	// are empty unless the loyalty program is enabled.
+	Segment       CustomerSegment `json:"segment"`       // NEW | RETURNING | VIP
+	LoyaltyTier   LoyaltyTier     `json:"loyaltyTier"`   // BRONZE | SILVER | GOLD | PLATINUM
+	LoyaltyPoints int             `json:"loyaltyPoints"` // Current balance of loyalty points
+	LifetimeValue int             `json:"lifetimeValue"` // Sum of all order values in minor units of the base currency
+	OrderCount    int             `json:"orderCount"`
 }
 
 func (c *Customer) Protobuf() *shoppb.Customer {
@@ -49,11 +80,60 @@ func (c *Customer) Protobuf() *shoppb.Customer {
 		Email:        c.Email,
 		CustomerType: customerType,
 		Revision:     int32(c.Revision),
+		Locale:       c.Locale,
+
+		Segment:       shoppb.Customer_Segment(shoppb.Customer_Segment_value["SEGMENT_"+string(c.Segment)]),
+		LoyaltyTier:   shoppb.Customer_LoyaltyTier(shoppb.Customer_LoyaltyTier_value["LOYALTY_TIER_"+string(c.LoyaltyTier)]),
+		LoyaltyPoints: int32(c.LoyaltyPoints),
+		LifetimeValue: int32(c.LifetimeValue),
+		OrderCount:    int32(c.OrderCount),
+	}
+}
+
+// NewCustomerFromProtobuf converts a protobuf customer into a Customer.
+func NewCustomerFromProtobuf(pb *shoppb.Customer) Customer {
+	var companyName *string
+	if pb.GetCompanyName() != "" {
+		companyName = &pb.CompanyName
+	}
+
+	customerType := CustomerTypePersonal
+	if pb.GetCustomerType() == shoppb.Customer_CUSTOMER_TYPE_BUSINESS {
+		customerType = CustomerTypeBusiness
+	}
+
+	var segment CustomerSegment
+	if pb.GetSegment() != shoppb.Customer_SEGMENT_UNSPECIFIED {
+		segment = CustomerSegment(strings.TrimPrefix(pb.GetSegment().String(), "SEGMENT_"))
+	}
+	var loyaltyTier LoyaltyTier
+	if pb.GetLoyaltyTier() != shoppb.Customer_LOYALTY_TIER_UNSPECIFIED {
+		loyaltyTier = LoyaltyTier(strings.TrimPrefix(pb.GetLoyaltyTier().String(), "LOYALTY_TIER_"))
+	}
+
+	return Customer{
+		Version:      int(pb.GetVersion()),
+		ID:           pb.GetId(),
+		FirstName:    pb.GetFirstName(),
+		LastName:     pb.GetLastName(),
+		Gender:       pb.GetGender(),
+		CompanyName:  companyName,
+		Email:        pb.GetEmail(),
+		CustomerType: customerType,
+		Revision:     int(pb.GetRevision()),
+		Locale:       pb.GetLocale(),
+
+		Segment:       segment,
+		LoyaltyTier:   loyaltyTier,
+		LoyaltyPoints: int(pb.GetLoyaltyPoints()),
+		LifetimeValue: int(pb.GetLifetimeValue()),
+		OrderCount:    int(pb.GetOrderCount()),
 	}
 }
 
-func NewCustomer() Customer {
-	person := gofakeit.Person()
+// NewCustomer returns a customer of the given locale, see Locales.
+func NewCustomer(locale string) Customer {
+	firstName, lastName, gender, email := newPerson(locale)
 
 	var companyName *string
 	customerType := newCustomerType()
@@ -65,13 +145,25 @@ func NewCustomer() Customer {
 	return Customer{
 		Version:      0,
 		ID:           gofakeit.UUID(),
-		FirstName:    person.FirstName,
-		LastName:     person.LastName,
-		Gender:       person.Gender,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Gender:       gender,
 		CompanyName:  companyName,
-		Email:        gofakeit.Email(),
+		Email:        email,
 		CustomerType: customerType,
+		Locale:       locale,
+	}
+}
+
+// MakeBusiness turns the customer into a business customer of a random
+// company, unless it is a business customer already.
+func (c *Customer) MakeBusiness() {
+	if c.CustomerType == CustomerTypeBusiness {
+		return
 	}
+	company := gofakeit.Company()
+	c.CompanyName = &company
+	c.CustomerType = CustomerTypeBusiness
 }
 
 // newCustomerType returns a customer type based on a weighted random choice
@@ -0,0 +1,39 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type CustomerActivityType string
+
+const (
+	CustomerActivityTypeOrderPlaced CustomerActivityType = "ORDER_PLACED"
+)
+
+// CustomerActivity is an entry in a customer's activity history, such as a
+// placed order.
+type CustomerActivity struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID         string               `json:"id"`
+	Type       CustomerActivityType `json:"type"`
+	CustomerID string               `json:"customerId"`
+	OrderID    string               `json:"orderId"`
+	CreatedAt  time.Time            `json:"createdAt"`
+}
+
+// NewOrderPlacedActivity creates the customer activity for a placed order at
+// the given time.
+func NewOrderPlacedActivity(order Order, createdAt time.Time) CustomerActivity {
+	return CustomerActivity{
+		Version:    0,
+		ID:         gofakeit.UUID(),
+		Type:       CustomerActivityTypeOrderPlaced,
+		CustomerID: order.Customer.ID,
+		OrderID:    order.ID,
+		CreatedAt:  createdAt,
+	}
+}
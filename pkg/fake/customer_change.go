@@ -0,0 +1,48 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type CustomerChangeType string
+
+const (
+	CustomerChangeTypeCreated  CustomerChangeType = "CREATED"
+	CustomerChangeTypeModified CustomerChangeType = "MODIFIED"
+	CustomerChangeTypeDeleted  CustomerChangeType = "DELETED"
+)
+
+// CustomerChange is an append-only event that describes a single change of a
+// customer. Applying all changes of a customer in order results in the latest
+// state of the customer.
+type CustomerChange struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID         string             `json:"id"`
+	Type       CustomerChangeType `json:"type"`
+	CustomerID string             `json:"customerId"`
+	// Customer is the state of the customer after the change, which is nil if
+	// the customer has been deleted.
+	Customer  *Customer `json:"customer"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewCustomerChange creates a change of the given type for a customer at the
+// given time.
+func NewCustomerChange(customer Customer, changeType CustomerChangeType, createdAt time.Time) CustomerChange {
+	change := CustomerChange{
+		Version:    0,
+		ID:         gofakeit.UUID(),
+		Type:       changeType,
+		CustomerID: customer.ID,
+		CreatedAt:  createdAt,
+	}
+	if changeType != CustomerChangeTypeDeleted {
+		change.Customer = &customer
+	}
+
+	return change
+}
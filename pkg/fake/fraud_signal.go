@@ -0,0 +1,82 @@
+package fake
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type FraudReason string
+
+const (
+	FraudReasonCountryMismatch FraudReason = "COUNTRY_MISMATCH"
+	FraudReasonHighOrderValue  FraudReason = "HIGH_ORDER_VALUE"
+	FraudReasonRapidFire       FraudReason = "RAPID_FIRE"
+)
+
+// FraudReasons are all reasons why an order can be suspicious.
+var FraudReasons = []FraudReason{
+	FraudReasonCountryMismatch,
+	FraudReasonHighOrderValue,
+	FraudReasonRapidFire,
+}
+
+// FraudSignal is the fraud score of a single order. Suspicious is the ground
+// truth label of the order, whereas the score is an imperfect estimate, so
+// that some orders are false positives or negatives.
+type FraudSignal struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID             string        `json:"id"`
+	OrderID        string        `json:"orderId"`
+	CustomerID     string        `json:"customerId"`
+	OrderValue     int           `json:"orderValue"`
+	BillingCountry string        `json:"billingCountry"`
+	IPCountry      string        `json:"ipCountry"`
+	Score          float64       `json:"score"`
+	Suspicious     bool          `json:"suspicious"`
+	Reasons        []FraudReason `json:"reasons"`
+	CreatedAt      time.Time     `json:"createdAt"`
+}
+
+// NewFraudSignal creates the fraud signal of the given order. The order is
+// suspicious if any reasons are given. The billing country is the country of
+// the customer's locale, and so is the IP country unless the countries
+// mismatch.
+func NewFraudSignal(order Order, reasons []FraudReason, createdAt time.Time) FraudSignal {
+	if reasons == nil {
+		reasons = []FraudReason{}
+	}
+
+	billingCountry := countryOfLocale(order.Customer.Locale)
+	ipCountry := billingCountry
+	for _, reason := range reasons {
+		if reason == FraudReasonCountryMismatch {
+			for ipCountry == billingCountry {
+				ipCountry = gofakeit.CountryAbr()
+			}
+		}
+	}
+
+	// Suspicious orders mostly score above 0.5 and legit orders below
+	score := rand.Float64() * 0.6
+	if len(reasons) > 0 {
+		score = 0.4 + rand.Float64()*0.6
+	}
+
+	return FraudSignal{
+		Version:        0,
+		ID:             gofakeit.UUID(),
+		OrderID:        order.ID,
+		CustomerID:     order.Customer.ID,
+		OrderValue:     order.OrderValue,
+		BillingCountry: billingCountry,
+		IPCountry:      ipCountry,
+		Score:          score,
+		Suspicious:     len(reasons) > 0,
+		Reasons:        reasons,
+		CreatedAt:      createdAt,
+	}
+}
@@ -1,9 +1,11 @@
 package fake
 
 import (
-	"github.com/brianvoe/gofakeit/v5"
-	"github.com/mroth/weightedrand"
 	"net/http"
+
+	"github.com/mroth/weightedrand"
+
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
 )
 
 type FrontendEvent struct {
@@ -17,38 +19,65 @@ type FrontendEvent struct {
 	RequestDuration int                   `json:"requestDuration"`
 	Response        FrontendEventResponse `json:"response"`
 	Headers         map[string]string     `json:"headers"`
+
+	// SessionID is shared by all requests of the same user session.
+	SessionID string `json:"sessionId"`
+	// Referrer is the page that linked to the requested page. It is empty
+	// if the user has entered the URL directly.
+	Referrer string `json:"referrer"`
+	// SequenceNumber is the position of the request within its session,
+	// starting at 1.
+	SequenceNumber int `json:"sequenceNumber"`
+	// SessionOutcome is set on the last request of a session and is one of
+	// the FrontendSessionOutcome constants. It is empty for all other
+	// requests.
+	SessionOutcome string `json:"sessionOutcome"`
 }
 
-type FrontendEventResponse struct {
-	Size       int `json:"size"`
-	StatusCode int `json:"statusCode"`
+func (f *FrontendEvent) Protobuf() *shoppb.FrontendEvent {
+	return &shoppb.FrontendEvent{
+		Version:         int32(f.Version),
+		RequestedUrl:    f.RequestedURL,
+		Method:          f.Method,
+		CorrelationId:   f.CorrelationID,
+		IpAddress:       f.IPAddress,
+		RequestDuration: int32(f.RequestDuration),
+		Response: &shoppb.FrontendEvent_Response{
+			Size:       int32(f.Response.Size),
+			StatusCode: int32(f.Response.StatusCode),
+		},
+		Headers:        f.Headers,
+		SessionId:      f.SessionID,
+		Referrer:       f.Referrer,
+		SequenceNumber: int32(f.SequenceNumber),
+		SessionOutcome: f.SessionOutcome,
+	}
 }
 
-func NewFrontendEvent() FrontendEvent {
+// NewFrontendEventFromProtobuf converts a protobuf frontend event into a FrontendEvent.
+func NewFrontendEventFromProtobuf(pb *shoppb.FrontendEvent) FrontendEvent {
 	return FrontendEvent{
-		Version:         0,
-		RequestedURL:    gofakeit.URL(),
-		Method:          gofakeit.HTTPMethod(),
-		CorrelationID:   gofakeit.UUID(),
-		IPAddress:       gofakeit.IPv4Address(),
-		RequestDuration: gofakeit.Number(1, 1500),
+		Version:         int(pb.GetVersion()),
+		RequestedURL:    pb.GetRequestedUrl(),
+		Method:          pb.GetMethod(),
+		CorrelationID:   pb.GetCorrelationId(),
+		IPAddress:       pb.GetIpAddress(),
+		RequestDuration: int(pb.GetRequestDuration()),
 		Response: FrontendEventResponse{
-			Size:       gofakeit.Number(40, 2500),
-			StatusCode: newStatusCode(),
+			Size:       int(pb.GetResponse().GetSize()),
+			StatusCode: int(pb.GetResponse().GetStatusCode()),
 		},
-		Headers: newHTTPHeaders(),
+		Headers:        pb.GetHeaders(),
+		SessionID:      pb.GetSessionId(),
+		Referrer:       pb.GetReferrer(),
+		SequenceNumber: int(pb.GetSequenceNumber()),
+		SessionOutcome: pb.GetSessionOutcome(),
 	}
 }
 
-func newHTTPHeaders() map[string]string {
-	return map[string]string{
-		"user-agent":      gofakeit.UserAgent(),
-		"accept":          "*/*",
-		"accept-encoding": "gzip",
-		"cache-control":   "max-age=0",
-		"origin":          gofakeit.URL(),
-		"referrer":        gofakeit.URL(),
-	}
+type FrontendEventResponse struct {
+	Size       int `json:"size"`
+	StatusCode int `json:"statusCode"`
 }
 
 // newStatusCode returns a weighted status code
@@ -0,0 +1,178 @@
+package fake
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+const (
+	// FrontendSessionOutcomeConverted is the outcome of a session that has
+	// ended with a checkout.
+	FrontendSessionOutcomeConverted = "CONVERTED"
+	// FrontendSessionOutcomeBounced is the outcome of a session that has
+	// ended right after the landing page.
+	FrontendSessionOutcomeBounced = "BOUNCED"
+	// FrontendSessionOutcomeExited is the outcome of a session that has
+	// ended after browsing several pages without a checkout.
+	FrontendSessionOutcomeExited = "EXITED"
+)
+
+const shopBaseURL = "https://www.owlshop.com"
+
+// externalReferrers are the sites that users come from. The empty referrer
+// stands for users that enter the shop's URL directly.
+var externalReferrers = []string{
+	"",
+	"https://www.google.com/",
+	"https://www.bing.com/",
+	"https://duckduckgo.com/",
+	"https://www.facebook.com/",
+	"https://t.co/",
+	"https://www.reddit.com/",
+	"https://newsletter.owlshop.com/",
+}
+
+// FrontendSession is the visit of a single user to the shop's frontend. A
+// session produces one FrontendEvent per page view. All of them share the
+// session id, the user's IP address and user agent, and each of them refers
+// to the previously viewed page.
+type FrontendSession struct {
+	ID        string
+	IPAddress string
+	UserAgent string
+
+	// Pages is the number of pages that are viewed in total.
+	Pages int
+	// Converts is true if the session ends with a checkout.
+	Converts bool
+	// Viewed is the number of pages that have been viewed so far.
+	Viewed int
+	// LastURL is the URL of the previously viewed page.
+	LastURL string
+	// ClickedProductID is set once a result of the previous search has been
+	// clicked, so that the next page is the clicked product.
+	ClickedProductID string
+}
+
+// NewFrontendSession creates a new session of a user that arrives at the shop.
+// The session bounces with the given bounce ratio. Otherwise between 2 and
+// maxPages pages are viewed and the session ends with a checkout with the
+// given conversion ratio. Converting sessions view at least 4 pages.
+func NewFrontendSession(maxPages int, bounceRatio float64, conversionRatio float64) FrontendSession {
+	session := FrontendSession{
+		ID:        gofakeit.UUID(),
+		IPAddress: gofakeit.IPv4Address(),
+		UserAgent: gofakeit.UserAgent(),
+		Pages:     1,
+		LastURL:   externalReferrers[gofakeit.Number(0, len(externalReferrers)-1)],
+	}
+	if rand.Float64() < bounceRatio {
+		return session
+	}
+
+	session.Pages = gofakeit.Number(2, maxPages)
+	if rand.Float64() < conversionRatio {
+		session.Converts = true
+		if session.Pages < 4 {
+			session.Pages = 4
+		}
+	}
+
+	return session
+}
+
+// NewBouncedFrontendSession creates a new session that ends after the landing
+// page.
+func NewBouncedFrontendSession() FrontendSession {
+	return NewFrontendSession(1, 1, 0)
+}
+
+// Ended returns true if all pages of the session have been viewed.
+func (s *FrontendSession) Ended() bool {
+	return s.Viewed >= s.Pages
+}
+
+// NextEvent views the next page of the session and returns the corresponding
+// frontend event. The last event of a session carries its outcome.
+func (s *FrontendSession) NextEvent() FrontendEvent {
+	s.Viewed++
+	requestedURL, method := s.nextPage()
+
+	event := FrontendEvent{
+		Version:         0,
+		RequestedURL:    requestedURL,
+		Method:          method,
+		CorrelationID:   gofakeit.UUID(),
+		IPAddress:       s.IPAddress,
+		RequestDuration: gofakeit.Number(1, 1500),
+		Response: FrontendEventResponse{
+			Size:       gofakeit.Number(40, 2500),
+			StatusCode: newStatusCode(),
+		},
+		Headers:        s.httpHeaders(),
+		SessionID:      s.ID,
+		Referrer:       s.LastURL,
+		SequenceNumber: s.Viewed,
+	}
+
+	if s.Ended() {
+		switch {
+		case s.Converts:
+			event.SessionOutcome = FrontendSessionOutcomeConverted
+		case s.Pages == 1:
+			event.SessionOutcome = FrontendSessionOutcomeBounced
+		default:
+			event.SessionOutcome = FrontendSessionOutcomeExited
+		}
+	}
+	s.LastURL = requestedURL
+
+	return event
+}
+
+// nextPage returns the URL and HTTP method of the next page view. Converting
+// sessions end with the cart, checkout and confirmation page.
+func (s *FrontendSession) nextPage() (string, string) {
+	if s.Converts {
+		switch s.Pages - s.Viewed {
+		case 2:
+			return shopBaseURL + "/cart", http.MethodGet
+		case 1:
+			return shopBaseURL + "/checkout", http.MethodGet
+		case 0:
+			return shopBaseURL + "/checkout/confirmation", http.MethodPost
+		}
+	}
+
+	if s.ClickedProductID != "" {
+		productID := s.ClickedProductID
+		s.ClickedProductID = ""
+		return shopBaseURL + "/products/" + productID, http.MethodGet
+	}
+
+	switch gofakeit.Number(1, 10) {
+	case 1:
+		return shopBaseURL + "/", http.MethodGet
+	case 2, 3:
+		return shopBaseURL + "/search?q=" + url.QueryEscape(newSearchTerms()), http.MethodGet
+	case 4, 5, 6:
+		return shopBaseURL + "/categories/" + strings.ToLower(gofakeit.Color()), http.MethodGet
+	default:
+		return shopBaseURL + "/products/" + gofakeit.UUID(), http.MethodGet
+	}
+}
+
+func (s *FrontendSession) httpHeaders() map[string]string {
+	return map[string]string{
+		"user-agent":      s.UserAgent,
+		"accept":          "*/*",
+		"accept-encoding": "gzip",
+		"cache-control":   "max-age=0",
+		"origin":          shopBaseURL,
+		"referrer":        s.LastURL,
+	}
+}
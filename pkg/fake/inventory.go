@@ -0,0 +1,157 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
+)
+
+type InventoryEventType string
+
+const (
+	InventoryEventTypeStockReserved InventoryEventType = "STOCK_RESERVED"
+	InventoryEventTypeStockReleased InventoryEventType = "STOCK_RELEASED"
+
+	// The following event types are only produced if the inventory is
+	// split into warehouses
+	InventoryEventTypeStockTransferred InventoryEventType = "STOCK_TRANSFERRED"
+	InventoryEventTypeStockOut         InventoryEventType = "STOCK_OUT"
+	InventoryEventTypeStockRestocked   InventoryEventType = "STOCK_RESTOCKED"
+)
+
+// InventoryEvent describes a change of the reserved stock for a single
+// article in the shop's inventory. If the inventory is split into warehouses,
+// WarehouseID is the warehouse whose stock has changed and StockLevel is its
+// remaining stock of the article. Transfers move the quantity from
+// WarehouseID to TargetWarehouseID.
+type InventoryEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID        string             `json:"id"`
+	Type      InventoryEventType `json:"type"`
+	OrderID   string             `json:"orderId"`
+	ArticleID string             `json:"articleId"`
+	Quantity  int                `json:"quantity"`
+	CreatedAt time.Time          `json:"createdAt"`
+
+	WarehouseID       string `json:"warehouseId"`
+	TargetWarehouseID string `json:"targetWarehouseId"`
+	StockLevel        int    `json:"stockLevel"`
+}
+
+// NewStockReservation creates an inventory event at createdAt that reserves
+// the stock for the given order line item.
+func NewStockReservation(orderID string, item OrderLineItem, createdAt time.Time) InventoryEvent {
+	return InventoryEvent{
+		Version:   0,
+		ID:        gofakeit.UUID(),
+		Type:      InventoryEventTypeStockReserved,
+		OrderID:   orderID,
+		ArticleID: item.ArticleID,
+		Quantity:  item.Quantity,
+		CreatedAt: createdAt,
+	}
+}
+
+// NewStockRelease creates an inventory event at createdAt that releases the
+// stock of a previous reservation again. The stock is released in the
+// warehouse of the reservation.
+func NewStockRelease(reservation InventoryEvent, createdAt time.Time) InventoryEvent {
+	return InventoryEvent{
+		Version:   0,
+		ID:        gofakeit.UUID(),
+		Type:      InventoryEventTypeStockReleased,
+		OrderID:   reservation.OrderID,
+		ArticleID: reservation.ArticleID,
+		Quantity:  reservation.Quantity,
+		CreatedAt: createdAt,
+
+		WarehouseID: reservation.WarehouseID,
+	}
+}
+
+// NewStockTransfer creates an inventory event at createdAt that transfers the
+// given quantity of an article between two warehouses. The stock level is the
+// remaining stock of the source warehouse.
+func NewStockTransfer(articleID string, from string, to string, quantity int, stockLevel int, createdAt time.Time) InventoryEvent {
+	return InventoryEvent{
+		Version:           0,
+		ID:                gofakeit.UUID(),
+		Type:              InventoryEventTypeStockTransferred,
+		ArticleID:         articleID,
+		Quantity:          quantity,
+		CreatedAt:         createdAt,
+		WarehouseID:       from,
+		TargetWarehouseID: to,
+		StockLevel:        stockLevel,
+	}
+}
+
+// NewStockOut creates an inventory event at createdAt for an order line item
+// that could not be reserved, because its quantity exceeds the stock of all
+// warehouses.
+func NewStockOut(orderID string, item OrderLineItem, warehouseID string, stockLevel int, createdAt time.Time) InventoryEvent {
+	return InventoryEvent{
+		Version:     0,
+		ID:          gofakeit.UUID(),
+		Type:        InventoryEventTypeStockOut,
+		OrderID:     orderID,
+		ArticleID:   item.ArticleID,
+		Quantity:    item.Quantity,
+		CreatedAt:   createdAt,
+		WarehouseID: warehouseID,
+		StockLevel:  stockLevel,
+	}
+}
+
+// NewStockRestock creates an inventory event at createdAt that adds the given
+// quantity of an article to the stock of a warehouse.
+func NewStockRestock(articleID string, warehouseID string, quantity int, stockLevel int, createdAt time.Time) InventoryEvent {
+	return InventoryEvent{
+		Version:     0,
+		ID:          gofakeit.UUID(),
+		Type:        InventoryEventTypeStockRestocked,
+		ArticleID:   articleID,
+		Quantity:    quantity,
+		CreatedAt:   createdAt,
+		WarehouseID: warehouseID,
+		StockLevel:  stockLevel,
+	}
+}
+
+func (e *InventoryEvent) Protobuf() *shoppb.InventoryEvent {
+	return &shoppb.InventoryEvent{
+		Version:   int32(e.Version),
+		Id:        e.ID,
+		Type:      string(e.Type),
+		OrderId:   e.OrderID,
+		ArticleId: e.ArticleID,
+		Quantity:  int32(e.Quantity),
+		CreatedAt: timestamppb.New(e.CreatedAt),
+
+		WarehouseId:       e.WarehouseID,
+		TargetWarehouseId: e.TargetWarehouseID,
+		StockLevel:        int32(e.StockLevel),
+	}
+}
+
+// NewInventoryEventFromProtobuf converts a protobuf inventory event into an InventoryEvent.
+func NewInventoryEventFromProtobuf(pb *shoppb.InventoryEvent) InventoryEvent {
+	return InventoryEvent{
+		Version:   int(pb.GetVersion()),
+		ID:        pb.GetId(),
+		Type:      InventoryEventType(pb.GetType()),
+		OrderID:   pb.GetOrderId(),
+		ArticleID: pb.GetArticleId(),
+		Quantity:  int(pb.GetQuantity()),
+		CreatedAt: pb.GetCreatedAt().AsTime(),
+
+		WarehouseID:       pb.GetWarehouseId(),
+		TargetWarehouseID: pb.GetTargetWarehouseId(),
+		StockLevel:        int(pb.GetStockLevel()),
+	}
+}
@@ -0,0 +1,74 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type InvoiceEventType string
+
+const (
+	InvoiceEventTypeIssued  InvoiceEventType = "INVOICE_ISSUED"
+	InvoiceEventTypeOverdue InvoiceEventType = "INVOICE_OVERDUE"
+	InvoiceEventTypePaid    InvoiceEventType = "INVOICE_PAID"
+)
+
+// Invoice is the invoice of a wholesale order, which is due within the
+// order's payment terms. The amount is given in minor units of the order's
+// currency.
+type Invoice struct {
+	ID                  string    `json:"id"`
+	OrderID             string    `json:"orderId"`
+	PurchaseOrderNumber string    `json:"purchaseOrderNumber"`
+	CustomerID          string    `json:"customerId"`
+	CompanyName         string    `json:"companyName"`
+	Amount              int       `json:"amount"`
+	Currency            string    `json:"currency"`
+	Terms               string    `json:"terms"`
+	IssuedAt            time.Time `json:"issuedAt"`
+	DueAt               time.Time `json:"dueAt"`
+}
+
+// NewInvoice creates the invoice of the given wholesale order, which is issued
+// at the given time and due after the given duration.
+func NewInvoice(order Order, issuedAt time.Time, due time.Duration) Invoice {
+	companyName := ""
+	if order.Customer.CompanyName != nil {
+		companyName = *order.Customer.CompanyName
+	}
+
+	return Invoice{
+		ID:                  "INV-" + gofakeit.DigitN(10),
+		OrderID:             order.ID,
+		PurchaseOrderNumber: order.PurchaseOrderNumber,
+		CustomerID:          order.Customer.ID,
+		CompanyName:         companyName,
+		Amount:              order.OrderValue,
+		Currency:            order.Currency,
+		Terms:               order.Payment.Terms,
+		IssuedAt:            issuedAt,
+		DueAt:               issuedAt.Add(due),
+	}
+}
+
+// InvoiceEvent describes a single step in the lifecycle of an invoice.
+type InvoiceEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID        string           `json:"id"`
+	Type      InvoiceEventType `json:"type"`
+	Invoice   Invoice          `json:"invoice"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+func NewInvoiceEvent(invoice Invoice, eventType InvoiceEventType, createdAt time.Time) InvoiceEvent {
+	return InvoiceEvent{
+		Version:   0,
+		ID:        gofakeit.UUID(),
+		Type:      eventType,
+		Invoice:   invoice,
+		CreatedAt: createdAt,
+	}
+}
@@ -0,0 +1,259 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+// DefaultLocale is the locale of customers without locale, e.g. customers
+// that have been produced before locales were introduced. Its data is
+// generated by gofakeit.
+const DefaultLocale = "en_US"
+
+// Locales are all supported locales.
+var Locales = []string{"de_DE", "en_GB", "en_US", "fr_FR", "ja_JP"}
+
+// locale holds the data from which the names, addresses and phone numbers of
+// a locale's customers are generated. Formats replace each # with a random
+// digit.
+type locale struct {
+	country          string
+	currency         string
+	femaleFirstNames []string
+	maleFirstNames   []string
+	lastNames        []string
+	cities           []localeCity
+	streets          []string
+	zipFormat        string
+	phoneFormat      string
+	emailDomains     []string
+}
+
+type localeCity struct {
+	name      string
+	state     string
+	latitude  float64
+	longitude float64
+}
+
+// locales are keyed by the locale name. The default locale is generated by
+// gofakeit and has no entry. Names are romanized, so that they can be used
+// in email addresses.
+var locales = map[string]locale{
+	"de_DE": {
+		country:          "DE",
+		currency:         "EUR",
+		femaleFirstNames: []string{"Anna", "Emma", "Hannah", "Lea", "Lena", "Laura", "Julia", "Sophie", "Marie", "Katharina", "Sabine", "Petra", "Ursula", "Monika", "Claudia"},
+		maleFirstNames:   []string{"Lukas", "Leon", "Finn", "Jonas", "Paul", "Felix", "Maximilian", "Tobias", "Stefan", "Andreas", "Michael", "Thomas", "Jürgen", "Klaus", "Markus"},
+		lastNames:        []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann", "Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf", "Schröder", "Neumann"},
+		cities: []localeCity{
+			{name: "Berlin", state: "Berlin", latitude: 52.52, longitude: 13.405},
+			{name: "Hamburg", state: "Hamburg", latitude: 53.551, longitude: 9.994},
+			{name: "München", state: "Bayern", latitude: 48.137, longitude: 11.575},
+			{name: "Köln", state: "Nordrhein-Westfalen", latitude: 50.938, longitude: 6.96},
+			{name: "Frankfurt am Main", state: "Hessen", latitude: 50.11, longitude: 8.682},
+			{name: "Stuttgart", state: "Baden-Württemberg", latitude: 48.776, longitude: 9.183},
+			{name: "Düsseldorf", state: "Nordrhein-Westfalen", latitude: 51.227, longitude: 6.774},
+			{name: "Leipzig", state: "Sachsen", latitude: 51.34, longitude: 12.375},
+			{name: "Dresden", state: "Sachsen", latitude: 51.05, longitude: 13.738},
+			{name: "Hannover", state: "Niedersachsen", latitude: 52.376, longitude: 9.732},
+		},
+		streets:      []string{"Hauptstraße", "Schulstraße", "Bahnhofstraße", "Gartenstraße", "Dorfstraße", "Bergstraße", "Lindenstraße", "Kirchstraße", "Goethestraße", "Schillerstraße", "Ringstraße", "Am Markt"},
+		zipFormat:    "#####",
+		phoneFormat:  "+49 1## #######",
+		emailDomains: []string{"web.de", "gmx.de", "t-online.de", "gmail.com"},
+	},
+	"en_GB": {
+		country:          "GB",
+		currency:         "GBP",
+		femaleFirstNames: []string{"Olivia", "Amelia", "Isla", "Ava", "Emily", "Sophie", "Grace", "Lily", "Charlotte", "Poppy", "Sarah", "Emma", "Claire", "Rachel", "Helen"},
+		maleFirstNames:   []string{"Oliver", "George", "Harry", "Jack", "Noah", "Charlie", "Thomas", "Oscar", "William", "James", "David", "Paul", "Richard", "Andrew", "Mark"},
+		lastNames:        []string{"Smith", "Jones", "Williams", "Taylor", "Brown", "Davies", "Evans", "Wilson", "Thomas", "Johnson", "Roberts", "Robinson", "Thompson", "Wright", "Walker", "White", "Hughes", "Edwards"},
+		cities: []localeCity{
+			{name: "London", state: "England", latitude: 51.507, longitude: -0.128},
+			{name: "Manchester", state: "England", latitude: 53.481, longitude: -2.243},
+			{name: "Birmingham", state: "England", latitude: 52.486, longitude: -1.89},
+			{name: "Leeds", state: "England", latitude: 53.8, longitude: -1.549},
+			{name: "Liverpool", state: "England", latitude: 53.408, longitude: -2.991},
+			{name: "Bristol", state: "England", latitude: 51.455, longitude: -2.588},
+			{name: "Glasgow", state: "Scotland", latitude: 55.864, longitude: -4.252},
+			{name: "Edinburgh", state: "Scotland", latitude: 55.953, longitude: -3.188},
+			{name: "Cardiff", state: "Wales", latitude: 51.481, longitude: -3.179},
+			{name: "Belfast", state: "Northern Ireland", latitude: 54.597, longitude: -5.93},
+		},
+		streets:      []string{"High Street", "Station Road", "Main Street", "Park Road", "Church Road", "Church Street", "London Road", "Victoria Road", "Green Lane", "Manor Road", "Kings Road", "Queens Road"},
+		zipFormat:    "?## #??",
+		phoneFormat:  "+44 7### ######",
+		emailDomains: []string{"btinternet.com", "hotmail.co.uk", "yahoo.co.uk", "gmail.com"},
+	},
+	"fr_FR": {
+		country:          "FR",
+		currency:         "EUR",
+		femaleFirstNames: []string{"Emma", "Jade", "Louise", "Alice", "Chloé", "Léa", "Manon", "Camille", "Inès", "Juliette", "Nathalie", "Isabelle", "Sophie", "Sandrine", "Céline"},
+		maleFirstNames:   []string{"Gabriel", "Léo", "Raphaël", "Louis", "Lucas", "Hugo", "Arthur", "Jules", "Adam", "Nathan", "Nicolas", "Julien", "Pierre", "Philippe", "François"},
+		lastNames:        []string{"Martin", "Bernard", "Thomas", "Petit", "Robert", "Richard", "Durand", "Dubois", "Moreau", "Laurent", "Simon", "Michel", "Lefebvre", "Leroy", "Roux", "David", "Bertrand", "Morel"},
+		cities: []localeCity{
+			{name: "Paris", state: "Île-de-France", latitude: 48.857, longitude: 2.352},
+			{name: "Marseille", state: "Provence-Alpes-Côte d'Azur", latitude: 43.297, longitude: 5.37},
+			{name: "Lyon", state: "Auvergne-Rhône-Alpes", latitude: 45.764, longitude: 4.836},
+			{name: "Toulouse", state: "Occitanie", latitude: 43.605, longitude: 1.444},
+			{name: "Nice", state: "Provence-Alpes-Côte d'Azur", latitude: 43.71, longitude: 7.262},
+			{name: "Nantes", state: "Pays de la Loire", latitude: 47.218, longitude: -1.554},
+			{name: "Strasbourg", state: "Grand Est", latitude: 48.573, longitude: 7.752},
+			{name: "Montpellier", state: "Occitanie", latitude: 43.611, longitude: 3.877},
+			{name: "Bordeaux", state: "Nouvelle-Aquitaine", latitude: 44.838, longitude: -0.579},
+			{name: "Lille", state: "Hauts-de-France", latitude: 50.629, longitude: 3.057},
+		},
+		streets:      []string{"Rue de la Paix", "Rue Victor Hugo", "Rue de l'Église", "Place de la République", "Rue du Moulin", "Avenue Jean Jaurès", "Rue Pasteur", "Rue de la Gare", "Boulevard Voltaire", "Rue des Écoles", "Avenue de la Libération", "Rue Nationale"},
+		zipFormat:    "#####",
+		phoneFormat:  "+33 6 ## ## ## ##",
+		emailDomains: []string{"orange.fr", "free.fr", "laposte.net", "gmail.com"},
+	},
+	"ja_JP": {
+		country:          "JP",
+		currency:         "JPY",
+		femaleFirstNames: []string{"Yui", "Hina", "Aoi", "Sakura", "Yuna", "Mio", "Rin", "Himari", "Akari", "Keiko", "Yoko", "Naoko", "Tomoko", "Yumi", "Emi"},
+		maleFirstNames:   []string{"Haruto", "Sota", "Yuto", "Riku", "Hinata", "Ren", "Minato", "Takumi", "Kaito", "Hiroshi", "Takeshi", "Kenji", "Daisuke", "Shota", "Yuki"},
+		lastNames:        []string{"Sato", "Suzuki", "Takahashi", "Tanaka", "Watanabe", "Ito", "Yamamoto", "Nakamura", "Kobayashi", "Kato", "Yoshida", "Yamada", "Sasaki", "Yamaguchi", "Matsumoto", "Inoue", "Kimura", "Hayashi"},
+		cities: []localeCity{
+			{name: "Tokyo", state: "Tokyo", latitude: 35.682, longitude: 139.769},
+			{name: "Yokohama", state: "Kanagawa", latitude: 35.444, longitude: 139.638},
+			{name: "Osaka", state: "Osaka", latitude: 34.694, longitude: 135.502},
+			{name: "Nagoya", state: "Aichi", latitude: 35.181, longitude: 136.906},
+			{name: "Sapporo", state: "Hokkaido", latitude: 43.062, longitude: 141.354},
+			{name: "Fukuoka", state: "Fukuoka", latitude: 33.59, longitude: 130.402},
+			{name: "Kobe", state: "Hyogo", latitude: 34.69, longitude: 135.196},
+			{name: "Kyoto", state: "Kyoto", latitude: 35.012, longitude: 135.768},
+			{name: "Kawasaki", state: "Kanagawa", latitude: 35.531, longitude: 139.703},
+			{name: "Sendai", state: "Miyagi", latitude: 38.268, longitude: 140.872},
+		},
+		streets:      []string{"Chuo", "Honcho", "Sakae", "Midori", "Asahi", "Saiwai", "Higashi", "Nishi", "Minami", "Kita", "Hon-dori", "Ginza"},
+		zipFormat:    "###-####",
+		phoneFormat:  "+81 90-####-####",
+		emailDomains: []string{"docomo.ne.jp", "yahoo.co.jp", "ezweb.ne.jp", "gmail.com"},
+	},
+}
+
+// ValidateLocale returns an error if the given locale is not supported.
+func ValidateLocale(name string) error {
+	for _, supported := range Locales {
+		if name == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("locale '%v' is not supported, supported locales are %v", name, strings.Join(Locales, ", "))
+}
+
+// LocaleOfCountry returns the locale of the given ISO 3166-1 alpha-2 country
+// code. Unknown countries return the default locale.
+func LocaleOfCountry(country string) string {
+	for name, loc := range locales {
+		if loc.country == country {
+			return name
+		}
+	}
+	return DefaultLocale
+}
+
+// countryOfLocale returns the ISO 3166-1 alpha-2 country code of the given
+// locale.
+func countryOfLocale(name string) string {
+	if loc, ok := locales[name]; ok {
+		return loc.country
+	}
+	return "US"
+}
+
+// CurrencyOfLocale returns the ISO 4217 code of the currency in which the
+// customers of the given locale pay.
+func CurrencyOfLocale(name string) string {
+	if loc, ok := locales[name]; ok {
+		return loc.currency
+	}
+	return "USD"
+}
+
+// newPerson returns the first name, last name, gender and email of a person of
+// the given locale.
+func newPerson(name string) (firstName string, lastName string, gender string, email string) {
+	loc, ok := locales[name]
+	if !ok {
+		person := gofakeit.Person()
+		return person.FirstName, person.LastName, person.Gender, gofakeit.Email()
+	}
+
+	gender = gofakeit.Gender()
+	if gender == "female" {
+		firstName = gofakeit.RandomString(loc.femaleFirstNames)
+	} else {
+		firstName = gofakeit.RandomString(loc.maleFirstNames)
+	}
+	lastName = gofakeit.RandomString(loc.lastNames)
+	email = fmt.Sprintf("%v.%v%v@%v",
+		emailLocalPart(firstName), emailLocalPart(lastName), gofakeit.Number(1, 99), gofakeit.RandomString(loc.emailDomains))
+
+	return firstName, lastName, gender, email
+}
+
+// NewLastName returns a last name of the given locale, e.g. for customers that
+// change their name.
+func NewLastName(name string) string {
+	loc, ok := locales[name]
+	if !ok {
+		return gofakeit.LastName()
+	}
+	return gofakeit.RandomString(loc.lastNames)
+}
+
+// emailTransliterations replace the characters of the locales' names that are
+// not allowed in the local part of email addresses.
+var emailTransliterations = strings.NewReplacer(
+	"ä", "ae", "ö", "oe", "ü", "ue", "Ä", "Ae", "Ö", "Oe", "Ü", "Ue", "ß", "ss",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "à", "a", "â", "a", "ç", "c", "î", "i", "ï", "i", "ô", "o", "û", "u", "É", "E",
+)
+
+func emailLocalPart(name string) string {
+	return strings.ToLower(emailTransliterations.Replace(name))
+}
+
+// localAddress is the part of an address that depends on the locale.
+type localAddress struct {
+	state     string
+	street    string
+	city      string
+	zip       string
+	latitude  float64
+	longitude float64
+	phone     string
+}
+
+// newLocalAddress returns an address of the given locale. Coordinates are
+// spread around the city center.
+func newLocalAddress(name string) localAddress {
+	loc, ok := locales[name]
+	if !ok {
+		address := gofakeit.Address()
+		return localAddress{
+			state:     address.State,
+			street:    address.Street,
+			city:      address.City,
+			zip:       address.Zip,
+			latitude:  address.Latitude,
+			longitude: address.Longitude,
+			phone:     gofakeit.PhoneFormatted(),
+		}
+	}
+
+	city := loc.cities[gofakeit.Number(0, len(loc.cities)-1)]
+	return localAddress{
+		state:     city.state,
+		street:    gofakeit.RandomString(loc.streets),
+		city:      city.name,
+		zip:       strings.ToUpper(gofakeit.Lexify(gofakeit.Numerify(loc.zipFormat))),
+		latitude:  city.latitude + gofakeit.Float64Range(-0.05, 0.05),
+		longitude: city.longitude + gofakeit.Float64Range(-0.05, 0.05),
+		phone:     gofakeit.Numerify(loc.phoneFormat),
+	}
+}
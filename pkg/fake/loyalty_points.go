@@ -0,0 +1,41 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+// LoyaltyPointsEvent is the credit of the loyalty points that a customer has
+// earned with an order. The tier is the customer's tier after the credit, so
+// that tier upgrades can be told by a differing previous tier.
+type LoyaltyPointsEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID           string      `json:"id"`
+	CustomerID   string      `json:"customerId"`
+	OrderID      string      `json:"orderId"`
+	Points       int         `json:"points"`
+	Balance      int         `json:"balance"`
+	PreviousTier LoyaltyTier `json:"previousTier"`
+	Tier         LoyaltyTier `json:"tier"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+// NewLoyaltyPointsEvent creates the credit of the given points, which the
+// customer has earned with the given order at the given time. The customer
+// must already hold the credited points and the resulting tier.
+func NewLoyaltyPointsEvent(customer Customer, orderID string, points int, previousTier LoyaltyTier, createdAt time.Time) LoyaltyPointsEvent {
+	return LoyaltyPointsEvent{
+		Version:      0,
+		ID:           gofakeit.UUID(),
+		CustomerID:   customer.ID,
+		OrderID:      orderID,
+		Points:       points,
+		Balance:      customer.LoyaltyPoints,
+		PreviousTier: previousTier,
+		Tier:         customer.LoyaltyTier,
+		CreatedAt:    createdAt,
+	}
+}
@@ -0,0 +1,112 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type NotificationType string
+
+const (
+	NotificationTypeOrderConfirmation NotificationType = "ORDER_CONFIRMATION"
+	NotificationTypeShippingUpdate    NotificationType = "SHIPPING_UPDATE"
+	NotificationTypePasswordReset     NotificationType = "PASSWORD_RESET"
+)
+
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail NotificationChannel = "EMAIL"
+	NotificationChannelSMS   NotificationChannel = "SMS"
+	NotificationChannelPush  NotificationChannel = "PUSH"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationStatusQueued    NotificationStatus = "QUEUED"
+	NotificationStatusSent      NotificationStatus = "SENT"
+	NotificationStatusDelivered NotificationStatus = "DELIVERED"
+	NotificationStatusBounced   NotificationStatus = "BOUNCED"
+	NotificationStatusFailed    NotificationStatus = "FAILED"
+)
+
+// Notification is a message to a customer in the notification outbox. The
+// same notification is produced once per delivery status, starting with
+// QUEUED and ending with DELIVERED, BOUNCED or FAILED.
+type Notification struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID         string              `json:"id"`
+	Type       NotificationType    `json:"type"`
+	Channel    NotificationChannel `json:"channel"`
+	Status     NotificationStatus  `json:"status"`
+	CustomerID string              `json:"customerId"`
+	Recipient  string              `json:"recipient"`
+	Subject    string              `json:"subject"`
+	// ReferenceID is the id of the order, shipment or customer that the
+	// notification is about.
+	ReferenceID string    `json:"referenceId"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// NewOrderConfirmation creates the queued confirmation email of the given
+// order at createdAt.
+func NewOrderConfirmation(order Order, createdAt time.Time) Notification {
+	return newNotification(NotificationTypeOrderConfirmation, NotificationChannelEmail, order.Customer, "Your order has been received", order.ID, createdAt)
+}
+
+// NewShippingUpdate creates the queued notification of the given shipment
+// event at createdAt, which is sent by email or push notification to the given
+// customer.
+func NewShippingUpdate(customer Customer, event ShipmentEvent, createdAt time.Time) Notification {
+	channel := NotificationChannelEmail
+	if gofakeit.Bool() {
+		channel = NotificationChannelPush
+	}
+	subject := "Your order is " + map[ShipmentEventType]string{
+		ShipmentEventTypeLabelCreated: "about to ship",
+		ShipmentEventTypePickedUp:     "on its way",
+		ShipmentEventTypeInTransit:    "in transit",
+		ShipmentEventTypeDelivered:    "delivered",
+	}[event.Type]
+
+	return newNotification(NotificationTypeShippingUpdate, channel, customer, subject, event.Shipment.ID, createdAt)
+}
+
+// NewPasswordReset creates the queued password reset email or SMS of the
+// given customer at createdAt.
+func NewPasswordReset(customer Customer, createdAt time.Time) Notification {
+	channel := NotificationChannelEmail
+	if gofakeit.Number(1, 5) == 1 {
+		channel = NotificationChannelSMS
+	}
+	return newNotification(NotificationTypePasswordReset, channel, customer, "Reset your password", customer.ID, createdAt)
+}
+
+func newNotification(notificationType NotificationType, channel NotificationChannel, customer Customer, subject string, referenceID string, createdAt time.Time) Notification {
+	recipient := customer.Email
+	switch channel {
+	case NotificationChannelSMS:
+		recipient = gofakeit.Phone()
+	case NotificationChannelPush:
+		recipient = gofakeit.UUID()
+	}
+
+	return Notification{
+		Version:     0,
+		ID:          gofakeit.UUID(),
+		Type:        notificationType,
+		Channel:     channel,
+		Status:      NotificationStatusQueued,
+		CustomerID:  customer.ID,
+		Recipient:   recipient,
+		Subject:     subject,
+		ReferenceID: referenceID,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+}
@@ -1,6 +1,9 @@
 package fake
 
 import (
+	"fmt"
+	"math"
+	"math/big"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v5"
@@ -9,24 +12,81 @@ import (
 	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
 )
 
-func NewOrder(customer Customer) Order {
-	return Order{
+// NewOrder creates a new fake order for the given customer. The line items
+// reference the passed products, which are usually taken from the product
+// catalog. The order is priced in the customer's currency and its value is the
+// sum of its line items. The order has been created at the given time.
+func NewOrder(customer Customer, products []Product, pricing Pricing, createdAt time.Time) Order {
+	currency := pricing.Currency(customer)
+	order := Order{
 		Version:       0,
 		ID:            gofakeit.UUID(),
-		CreatedAt:     time.Now(),
-		LastUpdatedAt: time.Now(),
+		CreatedAt:     createdAt,
+		LastUpdatedAt: createdAt,
 		DeliveredAt:   nil,
 		CompletedAt:   nil,
 		Customer:      customer,
-		OrderValue:    gofakeit.Number(5000, 250000),
-		LineItems:     newOrderLineItems(),
+		LineItems:     newOrderLineItems(products, pricing, currency),
+		Currency:      currency,
+		ExchangeRate:  pricing.ExchangeRate(currency),
 		Payment: OrderPayment{
 			PaymentID: gofakeit.UUID(),
 			Method:    gofakeit.RandomString([]string{"CASH", "DEBIT", "CREDIT_CARD", "PAYPAL"}),
 		},
-		DeliveryAddress: NewAddress(customer),
+		DeliveryAddress: NewAddress(customer, createdAt),
 		Revision:        0,
 	}
+	order.OrderValue = order.lineItemsValue()
+
+	return order
+}
+
+// NewOrderFromCart creates a new fake order for the line items of a checked
+// out shopping cart at the given time.
+func NewOrderFromCart(cart Cart, createdAt time.Time) Order {
+	order := NewOrder(cart.Customer, nil, cart.pricing, createdAt)
+	order.LineItems = make([]OrderLineItem, len(cart.Items))
+	copy(order.LineItems, cart.Items)
+	order.Currency = cart.Currency
+	order.ExchangeRate = cart.pricing.ExchangeRate(cart.Currency)
+	order.OrderValue = order.lineItemsValue()
+
+	return order
+}
+
+// MakeWholesale turns the order into a wholesale order, whose quantities are
+// multiplied by the given factor. It is referenced by a purchase order number
+// and paid by invoice within the given number of days.
+func (o *Order) MakeWholesale(quantityFactor int, paymentTermDays int) {
+	o.MultiplyQuantities(quantityFactor)
+	o.PurchaseOrderNumber = "PO-" + gofakeit.DigitN(8)
+	o.Payment.Method = "INVOICE"
+	o.Payment.Terms = fmt.Sprintf("NET_%d", paymentTermDays)
+}
+
+// MultiplyQuantities multiplies the quantities of all line items by the given
+// factor and updates the order value accordingly. The factor is capped, so
+// that the order value fits the 32-bit integers of the order schemas.
+func (o *Order) MultiplyQuantities(factor int) {
+	if value := o.lineItemsValue(); value > 0 && factor > math.MaxInt32/value {
+		factor = math.MaxInt32 / value
+	}
+	if factor < 1 {
+		factor = 1
+	}
+	for i := range o.LineItems {
+		o.LineItems[i].Quantity *= factor
+		o.LineItems[i].TotalPrice = o.LineItems[i].Quantity * o.LineItems[i].UnitPrice
+	}
+	o.OrderValue = o.lineItemsValue()
+}
+
+func (o *Order) lineItemsValue() int {
+	value := 0
+	for _, item := range o.LineItems {
+		value += item.TotalPrice
+	}
+	return value
 }
 
 type Order struct {
@@ -40,15 +100,20 @@ type Order struct {
 	CompletedAt   *time.Time `json:"completedAt"`
 
 	Customer        Customer        `json:"customer"`
-	OrderValue      int             `json:"orderValue"`
+	OrderValue      int             `json:"orderValue"` // In minor units of the currency, e.g. cents
 	LineItems       []OrderLineItem `json:"lineItems"`
+	Currency        string          `json:"currency"`     // ISO 4217 code
+	ExchangeRate    float64         `json:"exchangeRate"` // Amount of the currency that equals one unit of the base currency
 	Payment         OrderPayment    `json:"payment"`
 	DeliveryAddress Address         `json:"deliveryAddress"`
 	Revision        int             `json:"revision"`
+	// PurchaseOrderNumber is the buyer's reference of wholesale orders. It is
+	// empty for consumer orders.
+	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
 }
 
 func (o *Order) Protobuf() *shoppb.Order {
-	lineItems := make([]*shoppb.Order_LineItem, len(o.LineItems))
+	lineItems := make([]*shoppb.OrderLineItem, len(o.LineItems))
 	for i, item := range o.LineItems {
 		lineItems[i] = item.Protobuf()
 	}
@@ -66,34 +131,82 @@ func (o *Order) Protobuf() *shoppb.Order {
 		Payment:         o.Payment.Protobuf(),
 		DeliveryAddress: o.DeliveryAddress.Protobuf(),
 		Revision:        int32(o.Revision),
+		Currency:        o.Currency,
+		ExchangeRate:    o.ExchangeRate,
+
+		PurchaseOrderNumber: o.PurchaseOrderNumber,
 	}
 
 	return &order
 }
 
-func newOrderLineItems() []OrderLineItem {
-	itemCount := gofakeit.Number(8, 45)
-	items := make([]OrderLineItem, itemCount)
-	for i := 0; i < itemCount; i++ {
-		items[i] = newOrderLineItem()
+// NewOrderFromProtobuf converts a protobuf order into an Order.
+func NewOrderFromProtobuf(pb *shoppb.Order) Order {
+	lineItems := make([]OrderLineItem, len(pb.GetLineItems()))
+	for i, item := range pb.GetLineItems() {
+		lineItems[i] = OrderLineItem{
+			ArticleID:    item.GetArticleId(),
+			Name:         item.GetName(),
+			Quantity:     int(item.GetQuantity()),
+			QuantityUnit: item.GetQuantityUnit(),
+			UnitPrice:    int(item.GetUnitPrice()),
+			TotalPrice:   int(item.GetTotalPrice()),
+		}
+	}
+
+	return Order{
+		Version:       int(pb.GetVersion()),
+		ID:            pb.GetId(),
+		CreatedAt:     pb.GetCreatedAt().AsTime(),
+		LastUpdatedAt: pb.GetLastUpdatedAt().AsTime(),
+		DeliveredAt:   newTimePtrFromProtoTimestamp(pb.GetDeliveredAt()),
+		CompletedAt:   newTimePtrFromProtoTimestamp(pb.GetCompletedAt()),
+		Customer:      NewCustomerFromProtobuf(pb.GetCustomer()),
+		OrderValue:    int(pb.GetOrderValue()),
+		LineItems:     lineItems,
+		Payment: OrderPayment{
+			PaymentID: pb.GetPayment().GetPaymentId(),
+			Method:    pb.GetPayment().GetMethod(),
+			Terms:     pb.GetPayment().GetTerms(),
+		},
+		DeliveryAddress: NewAddressFromProtobuf(pb.GetDeliveryAddress()),
+		Revision:        int(pb.GetRevision()),
+		Currency:        pb.GetCurrency(),
+		ExchangeRate:    pb.GetExchangeRate(),
+
+		PurchaseOrderNumber: pb.GetPurchaseOrderNumber(),
+	}
+}
+
+func newOrderLineItems(products []Product, pricing Pricing, currency string) []OrderLineItem {
+	items := make([]OrderLineItem, len(products))
+	for i, product := range products {
+		items[i] = newOrderLineItem(product, gofakeit.Number(1, 500), pricing, currency)
 	}
 
 	return items
 }
 
-func newOrderLineItem() OrderLineItem {
-	quantity := gofakeit.Number(1, 500)
-	unitPrice := gofakeit.Number(1, 1000)
+// newOrderLineItem returns a line item of the given product, whose price is
+// converted into the given currency.
+func newOrderLineItem(product Product, quantity int, pricing Pricing, currency string) OrderLineItem {
+	unitPrice := pricing.Convert(product.Price, currency)
 	return OrderLineItem{
-		ArticleID:    gofakeit.UUID(),
-		Name:         gofakeit.Vegetable(),
+		ArticleID:    product.ID,
+		Name:         product.Name,
 		Quantity:     quantity,
-		QuantityUnit: gofakeit.RandomString([]string{"pieces", "gram"}),
+		QuantityUnit: product.QuantityUnit,
 		UnitPrice:    unitPrice,
 		TotalPrice:   quantity * unitPrice,
 	}
 }
 
+// Total returns the order value in major units of the order's currency, e.g.
+// dollars rather than cents.
+func (o *Order) Total() *big.Rat {
+	return big.NewRat(int64(o.OrderValue), int64(math.Pow10(minorUnits(o.Currency))))
+}
+
 type OrderLineItem struct {
 	ArticleID    string `json:"articleId"`
 	Name         string `json:"name"`
@@ -103,8 +216,8 @@ type OrderLineItem struct {
 	TotalPrice   int    `json:"totalPrice"`
 }
 
-func (o *OrderLineItem) Protobuf() *shoppb.Order_LineItem {
-	return &shoppb.Order_LineItem{
+func (o *OrderLineItem) Protobuf() *shoppb.OrderLineItem {
+	return &shoppb.OrderLineItem{
 		ArticleId:    o.ArticleID,
 		Name:         o.Name,
 		Quantity:     int32(o.Quantity),
@@ -116,13 +229,17 @@ func (o *OrderLineItem) Protobuf() *shoppb.Order_LineItem {
 
 type OrderPayment struct {
 	PaymentID string `json:"paymentId"`
-	Method    string `json:"method"` // PAYPAL | CREDIT_CARD | DEBIT | CASH
+	Method    string `json:"method"` // PAYPAL | CREDIT_CARD | DEBIT | CASH | INVOICE
+	// Terms are the payment terms of invoiced orders, e.g. NET_30. They are
+	// empty for orders that are paid right away.
+	Terms string `json:"terms"`
 }
 
 func (o *OrderPayment) Protobuf() *shoppb.Order_Payment {
 	return &shoppb.Order_Payment{
 		PaymentId: o.PaymentID,
 		Method:    o.Method,
+		Terms:     o.Terms,
 	}
 }
 
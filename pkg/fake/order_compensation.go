@@ -0,0 +1,54 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type OrderCompensationType string
+
+const (
+	OrderCompensationTypeCancelled OrderCompensationType = "CANCELLED"
+	OrderCompensationTypeRefunded  OrderCompensationType = "REFUNDED"
+)
+
+// OrderCompensation reverts a previously placed order, e.g. because the
+// customer cancelled it or returned the goods. It references the original
+// order, so that all services that acted upon the order can compensate their
+// actions.
+type OrderCompensation struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID         string                `json:"id"`
+	Type       OrderCompensationType `json:"type"`
+	OrderID    string                `json:"orderId"`
+	CustomerID string                `json:"customerId"`
+	PaymentID  string                `json:"paymentId"`
+	Amount     int                   `json:"amount"`
+	Currency   string                `json:"currency"`
+	Reason     string                `json:"reason"`
+	CreatedAt  time.Time             `json:"createdAt"`
+}
+
+// NewOrderCompensation creates a compensation of the given type for an order.
+func NewOrderCompensation(order Order, compensationType OrderCompensationType, createdAt time.Time) OrderCompensation {
+	reasons := []string{"CUSTOMER_REQUEST", "OUT_OF_STOCK", "SUSPECTED_FRAUD"}
+	if compensationType == OrderCompensationTypeRefunded {
+		reasons = []string{"DAMAGED", "WRONG_ITEM", "NOT_AS_DESCRIBED", "NO_LONGER_NEEDED"}
+	}
+
+	return OrderCompensation{
+		Version:    0,
+		ID:         gofakeit.UUID(),
+		Type:       compensationType,
+		OrderID:    order.ID,
+		CustomerID: order.Customer.ID,
+		PaymentID:  order.Payment.PaymentID,
+		Amount:     order.OrderValue,
+		Currency:   order.Currency,
+		Reason:     gofakeit.RandomString(reasons),
+		CreatedAt:  createdAt,
+	}
+}
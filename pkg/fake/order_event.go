@@ -0,0 +1,56 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type OrderEventType string
+
+const (
+	OrderEventTypeCreated   OrderEventType = "ORDER_CREATED"
+	OrderEventTypeConfirmed OrderEventType = "ORDER_CONFIRMED"
+	OrderEventTypePacked    OrderEventType = "ORDER_PACKED"
+	OrderEventTypeShipped   OrderEventType = "ORDER_SHIPPED"
+	OrderEventTypeCancelled OrderEventType = "ORDER_CANCELLED"
+)
+
+// OrderEvent is a single state transition of an order. The events of an order
+// are keyed by the order ID, so that the current state of an order is the
+// result of replaying all of its events in order.
+type OrderEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID      string         `json:"id"`
+	Type    OrderEventType `json:"type"`
+	OrderID string         `json:"orderId"`
+	// Sequence is the position of the event in the event stream of the order,
+	// starting with 0 for the created event.
+	Sequence int `json:"sequence"`
+	// Order is the placed order, which is only set on the created event.
+	Order     *Order    `json:"order,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewOrderCreatedEvent creates the first event of the given order, which
+// carries the placed order.
+func NewOrderCreatedEvent(order Order, createdAt time.Time) OrderEvent {
+	event := NewOrderEvent(order.ID, OrderEventTypeCreated, 0, createdAt)
+	event.Order = &order
+	return event
+}
+
+// NewOrderEvent creates an event of the given type at the given position of
+// the order's event stream.
+func NewOrderEvent(orderID string, eventType OrderEventType, sequence int, createdAt time.Time) OrderEvent {
+	return OrderEvent{
+		Version:   0,
+		ID:        gofakeit.UUID(),
+		Type:      eventType,
+		OrderID:   orderID,
+		Sequence:  sequence,
+		CreatedAt: createdAt,
+	}
+}
@@ -0,0 +1,89 @@
+package fake
+
+import (
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+// PayloadCache returns copies of pre-generated customers and addresses, which
+// are given fresh IDs, rather than generating every field of them. This
+// reduces the CPU and allocation cost of each record at high rates, at the
+// expense of repeating names, emails and streets. The templates of a locale
+// are generated on its first use. A nil cache generates all customers and
+// addresses anew.
+type PayloadCache struct {
+	size int
+
+	mu        sync.Mutex
+	customers map[string][]Customer
+	addresses map[string][]Address
+}
+
+// NewPayloadCache creates a cache with the given number of templates per
+// locale.
+func NewPayloadCache(size int) *PayloadCache {
+	return &PayloadCache{
+		size:      size,
+		customers: make(map[string][]Customer),
+		addresses: make(map[string][]Address),
+	}
+}
+
+// NewCustomer returns a customer of the given locale like NewCustomer.
+func (c *PayloadCache) NewCustomer(locale string) Customer {
+	if c == nil {
+		return NewCustomer(locale)
+	}
+
+	c.mu.Lock()
+	templates, ok := c.customers[locale]
+	if !ok {
+		templates = make([]Customer, c.size)
+		for i := range templates {
+			templates[i] = NewCustomer(locale)
+		}
+		c.customers[locale] = templates
+	}
+	c.mu.Unlock()
+
+	customer := templates[gofakeit.Number(0, len(templates)-1)]
+	customer.ID = gofakeit.UUID()
+	return customer
+}
+
+// NewAddress returns an address of the given customer like NewAddress, which
+// has been created at the given time.
+func (c *PayloadCache) NewAddress(customer Customer, createdAt time.Time) Address {
+	if c == nil {
+		return NewAddress(customer, createdAt)
+	}
+
+	locale := customer.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+
+	c.mu.Lock()
+	templates, ok := c.addresses[locale]
+	if !ok {
+		templates = make([]Address, c.size)
+		for i := range templates {
+			templates[i] = NewAddress(Customer{Locale: locale}, createdAt)
+		}
+		c.addresses[locale] = templates
+	}
+	c.mu.Unlock()
+
+	address := templates[gofakeit.Number(0, len(templates)-1)]
+	address.ID = gofakeit.UUID()
+	address.Customer = AddressCustomer{
+		CustomerID:   customer.ID,
+		CustomerType: customer.CustomerType,
+	}
+	address.FirstName = customer.FirstName
+	address.LastName = customer.LastName
+	address.CreatedAt = createdAt
+	return address
+}
@@ -0,0 +1,93 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
+)
+
+type PaymentEventType string
+
+const (
+	PaymentEventTypeAuthorized PaymentEventType = "AUTHORIZED"
+	PaymentEventTypeCaptured   PaymentEventType = "CAPTURED"
+	PaymentEventTypeDeclined   PaymentEventType = "DECLINED"
+	PaymentEventTypeRefunded   PaymentEventType = "REFUNDED"
+)
+
+// PaymentEvent describes a single step in the processing of an order's payment.
+type PaymentEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID            string           `json:"id"`
+	Type          PaymentEventType `json:"type"`
+	PaymentID     string           `json:"paymentId"`
+	OrderID       string           `json:"orderId"`
+	CustomerID    string           `json:"customerId"`
+	Method        string           `json:"method"`
+	Amount        int              `json:"amount"`
+	Currency      string           `json:"currency"`
+	DeclineReason *string          `json:"declineReason"`
+	CreatedAt     time.Time        `json:"createdAt"`
+}
+
+// NewPaymentEvent creates a payment event of the given type for an order at
+// the given time.
+func NewPaymentEvent(order Order, eventType PaymentEventType, createdAt time.Time) PaymentEvent {
+	var declineReason *string
+	if eventType == PaymentEventTypeDeclined {
+		reason := gofakeit.RandomString([]string{"INSUFFICIENT_FUNDS", "CARD_EXPIRED", "SUSPECTED_FRAUD", "LIMIT_EXCEEDED"})
+		declineReason = &reason
+	}
+
+	return PaymentEvent{
+		Version:       0,
+		ID:            gofakeit.UUID(),
+		Type:          eventType,
+		PaymentID:     order.Payment.PaymentID,
+		OrderID:       order.ID,
+		CustomerID:    order.Customer.ID,
+		Method:        order.Payment.Method,
+		Amount:        order.OrderValue,
+		Currency:      order.Currency,
+		DeclineReason: declineReason,
+		CreatedAt:     createdAt,
+	}
+}
+
+func (e *PaymentEvent) Protobuf() *shoppb.PaymentEvent {
+	return &shoppb.PaymentEvent{
+		Version:       int32(e.Version),
+		Id:            e.ID,
+		Type:          string(e.Type),
+		PaymentId:     e.PaymentID,
+		OrderId:       e.OrderID,
+		CustomerId:    e.CustomerID,
+		Method:        e.Method,
+		Amount:        int32(e.Amount),
+		Currency:      e.Currency,
+		DeclineReason: e.DeclineReason,
+		CreatedAt:     timestamppb.New(e.CreatedAt),
+	}
+}
+
+// NewPaymentEventFromProtobuf converts a protobuf payment event into a PaymentEvent.
+func NewPaymentEventFromProtobuf(pb *shoppb.PaymentEvent) PaymentEvent {
+	return PaymentEvent{
+		Version:       int(pb.GetVersion()),
+		ID:            pb.GetId(),
+		Type:          PaymentEventType(pb.GetType()),
+		PaymentID:     pb.GetPaymentId(),
+		OrderID:       pb.GetOrderId(),
+		CustomerID:    pb.GetCustomerId(),
+		Method:        pb.GetMethod(),
+		Amount:        int(pb.GetAmount()),
+		Currency:      pb.GetCurrency(),
+		DeclineReason: pb.DeclineReason,
+		CreatedAt:     pb.GetCreatedAt().AsTime(),
+	}
+}
@@ -0,0 +1,87 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type PriceChangeType string
+
+const (
+	PriceChangeTypeUpdated          PriceChangeType = "PRICE_UPDATED"
+	PriceChangeTypePromotionStarted PriceChangeType = "PROMOTION_STARTED"
+	PriceChangeTypePromotionEnded   PriceChangeType = "PROMOTION_ENDED"
+)
+
+// PriceChange is a change of a product's price, which is valid from the time
+// of the change until the next change of the same product. Promotions are
+// started with a discount on the regular price and end by reverting to it.
+// Prices are given in cents of the product's currency.
+type PriceChange struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID              string          `json:"id"`
+	Type            PriceChangeType `json:"type"`
+	ProductID       string          `json:"productId"`
+	SKU             string          `json:"sku"`
+	OldPrice        int             `json:"oldPrice"`
+	NewPrice        int             `json:"newPrice"`
+	RegularPrice    int             `json:"regularPrice"`
+	DiscountPercent int             `json:"discountPercent"`
+	Currency        string          `json:"currency"`
+	ValidFrom       time.Time       `json:"validFrom"`
+	// PromotionEndsAt is the time at which a started promotion ends. It is
+	// nil for all other changes.
+	PromotionEndsAt *time.Time `json:"promotionEndsAt"`
+}
+
+// NewPriceUpdate creates the change of the given product's regular price to
+// the new price, which is valid from the given time.
+func NewPriceUpdate(product Product, newPrice int, validFrom time.Time) PriceChange {
+	return newPriceChange(PriceChangeTypeUpdated, product, newPrice, newPrice, 0, validFrom)
+}
+
+// NewPromotionStart creates the change that puts the given product on sale
+// with the given discount on its current price for the given duration from
+// the given time on.
+func NewPromotionStart(product Product, discountPercent int, validFrom time.Time, duration time.Duration) PriceChange {
+	newPrice := product.Price * (100 - discountPercent) / 100
+	if newPrice < 1 {
+		newPrice = 1
+	}
+	change := newPriceChange(PriceChangeTypePromotionStarted, product, newPrice, product.Price, discountPercent, validFrom)
+	endsAt := change.ValidFrom.Add(duration)
+	change.PromotionEndsAt = &endsAt
+	return change
+}
+
+// NewPromotionEnd creates the change that reverts the given product on sale to
+// its regular price at the given time.
+func NewPromotionEnd(product Product, regularPrice int, validFrom time.Time) PriceChange {
+	return newPriceChange(PriceChangeTypePromotionEnded, product, regularPrice, regularPrice, 0, validFrom)
+}
+
+func newPriceChange(
+	changeType PriceChangeType,
+	product Product,
+	newPrice int,
+	regularPrice int,
+	discountPercent int,
+	validFrom time.Time,
+) PriceChange {
+	return PriceChange{
+		Version:         0,
+		ID:              gofakeit.UUID(),
+		Type:            changeType,
+		ProductID:       product.ID,
+		SKU:             product.SKU,
+		OldPrice:        product.Price,
+		NewPrice:        newPrice,
+		RegularPrice:    regularPrice,
+		DiscountPercent: discountPercent,
+		Currency:        product.Currency,
+		ValidFrom:       validFrom,
+	}
+}
@@ -0,0 +1,90 @@
+package fake
+
+import (
+	"math"
+	"math/rand"
+)
+
+// PriceDistribution is a log-normal distribution of prices in cents of the
+// base currency.
+type PriceDistribution struct {
+	Median int
+	Sigma  float64
+}
+
+// Pricing is the price model of products, orders and carts. Product prices
+// are drawn from the distribution of their category and are in the base
+// currency. Orders and carts are priced in the currency of their customer, if
+// its exchange rate is known, and in the base currency otherwise.
+type Pricing struct {
+	BaseCurrency  string
+	Categories    map[ProductCategory]PriceDistribution
+	ExchangeRates map[string]float64
+}
+
+// defaultPriceDistribution is used for categories without distribution.
+var defaultPriceDistribution = PriceDistribution{Median: 500, Sigma: 0.5}
+
+// currencyDecimals are the minor units of the currencies without cents.
+var currencyDecimals = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"ISK": 0,
+}
+
+// newPrice returns a price in cents of the base currency, which is at least
+// one cent.
+func (p Pricing) newPrice(category ProductCategory) int {
+	distribution, ok := p.Categories[category]
+	if !ok {
+		distribution, ok = p.Categories[ProductCategoryHousehold]
+	}
+	if !ok {
+		distribution = defaultPriceDistribution
+	}
+
+	price := float64(distribution.Median) * math.Exp(distribution.Sigma*rand.NormFloat64())
+	return int(math.Max(1, math.Round(price)))
+}
+
+// Currency returns the currency in which the given customer pays.
+func (p Pricing) Currency(customer Customer) string {
+	locale := customer.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	currency := CurrencyOfLocale(locale)
+	if _, ok := p.ExchangeRates[currency]; !ok {
+		return p.BaseCurrency
+	}
+	return currency
+}
+
+// ExchangeRate returns the amount of the given currency that equals one unit
+// of the base currency.
+func (p Pricing) ExchangeRate(currency string) float64 {
+	if rate, ok := p.ExchangeRates[currency]; ok && currency != p.BaseCurrency {
+		return rate
+	}
+	return 1
+}
+
+// Convert converts the given amount in minor units of the base currency, e.g.
+// cents, into minor units of the given currency. The converted amount is at
+// least one minor unit, so that cheap products never become free.
+func (p Pricing) Convert(amount int, currency string) int {
+	if currency == p.BaseCurrency {
+		return amount
+	}
+
+	major := float64(amount) / math.Pow10(minorUnits(p.BaseCurrency))
+	converted := major * p.ExchangeRate(currency) * math.Pow10(minorUnits(currency))
+	return int(math.Max(1, math.Round(converted)))
+}
+
+func minorUnits(currency string) int {
+	if decimals, ok := currencyDecimals[currency]; ok {
+		return decimals
+	}
+	return 2
+}
@@ -0,0 +1,137 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
+)
+
+type ProductCategory string
+
+const (
+	ProductCategoryFruits     ProductCategory = "FRUITS"
+	ProductCategoryVegetables ProductCategory = "VEGETABLES"
+	ProductCategoryBeverages  ProductCategory = "BEVERAGES"
+	ProductCategorySnacks     ProductCategory = "SNACKS"
+	ProductCategoryHousehold  ProductCategory = "HOUSEHOLD"
+)
+
+// productCategoryPrefixes is used to build SKUs like "VEG-4F2A1C" that
+// look like they have been assigned by a merchandising system.
+var productCategoryPrefixes = map[ProductCategory]string{
+	ProductCategoryFruits:     "FRU",
+	ProductCategoryVegetables: "VEG",
+	ProductCategoryBeverages:  "BEV",
+	ProductCategorySnacks:     "SNK",
+	ProductCategoryHousehold:  "HOU",
+}
+
+type Product struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID           string          `json:"id"`
+	SKU          string          `json:"sku"`
+	Name         string          `json:"name"`
+	Category     ProductCategory `json:"category"`
+	Price        int             `json:"price"` // In cents
+	Currency     string          `json:"currency"`
+	QuantityUnit string          `json:"quantityUnit"`
+	StockCount   int             `json:"stockCount"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	Revision     int             `json:"revision"` // Each change on the product increments the revision
+}
+
+// NewProduct returns a product of a random category, whose price is drawn from
+// the category's price distribution. The product has been created at the
+// given time.
+func NewProduct(pricing Pricing, createdAt time.Time) Product {
+	category := newProductCategory()
+
+	return Product{
+		Version:      0,
+		ID:           gofakeit.UUID(),
+		SKU:          newProductSKU(category),
+		Name:         newProductName(category),
+		Category:     category,
+		Price:        pricing.newPrice(category),
+		Currency:     pricing.BaseCurrency,
+		QuantityUnit: gofakeit.RandomString([]string{"pieces", "gram"}),
+		StockCount:   gofakeit.Number(0, 5000),
+		CreatedAt:    createdAt,
+		Revision:     0,
+	}
+}
+
+// ProductCategories are all product categories.
+var ProductCategories = []ProductCategory{
+	ProductCategoryFruits,
+	ProductCategoryVegetables,
+	ProductCategoryBeverages,
+	ProductCategorySnacks,
+	ProductCategoryHousehold,
+}
+
+func newProductCategory() ProductCategory {
+	return ProductCategories[gofakeit.Number(0, len(ProductCategories)-1)]
+}
+
+func newProductSKU(category ProductCategory) string {
+	return fmt.Sprintf("%v-%v", productCategoryPrefixes[category], strings.ToUpper(gofakeit.LetterN(2)+gofakeit.DigitN(4)))
+}
+
+func newProductName(category ProductCategory) string {
+	var noun string
+	switch category {
+	case ProductCategoryFruits:
+		noun = gofakeit.Fruit()
+	case ProductCategoryVegetables:
+		noun = gofakeit.Vegetable()
+	case ProductCategoryBeverages:
+		noun = gofakeit.BeerName()
+	case ProductCategorySnacks:
+		noun = gofakeit.Snack()
+	default:
+		noun = gofakeit.Noun()
+	}
+
+	return gofakeit.RandomString([]string{"Organic", "Fresh", "Premium", "Classic", "Regional", "Homemade"}) + " " + noun
+}
+
+func (p *Product) Protobuf() *shoppb.Product {
+	return &shoppb.Product{
+		Version:      int32(p.Version),
+		Id:           p.ID,
+		Sku:          p.SKU,
+		Name:         p.Name,
+		Category:     string(p.Category),
+		Price:        int32(p.Price),
+		Currency:     p.Currency,
+		QuantityUnit: p.QuantityUnit,
+		StockCount:   int32(p.StockCount),
+		CreatedAt:    timestamppb.New(p.CreatedAt),
+		Revision:     int32(p.Revision),
+	}
+}
+
+// NewProductFromProtobuf converts a protobuf product into a Product.
+func NewProductFromProtobuf(pb *shoppb.Product) Product {
+	return Product{
+		Version:      int(pb.GetVersion()),
+		ID:           pb.GetId(),
+		SKU:          pb.GetSku(),
+		Name:         pb.GetName(),
+		Category:     ProductCategory(pb.GetCategory()),
+		Price:        int(pb.GetPrice()),
+		Currency:     pb.GetCurrency(),
+		QuantityUnit: pb.GetQuantityUnit(),
+		StockCount:   int(pb.GetStockCount()),
+		CreatedAt:    pb.GetCreatedAt().AsTime(),
+		Revision:     int(pb.GetRevision()),
+	}
+}
@@ -0,0 +1,68 @@
+package fake
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+// pngSignature makes the generated image data look like a PNG file.
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// productMediaOverhead is the approximate size of a serialized product media
+// record without its description and image data.
+const productMediaOverhead = 400
+
+// ProductMedia holds the large assets of a product, which are published apart
+// from the product itself.
+type ProductMedia struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID          string       `json:"id"`
+	ProductID   string       `json:"productId"`
+	SKU         string       `json:"sku"`
+	Description string       `json:"description"`
+	Image       ProductImage `json:"image"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
+
+// ProductImage is an image of a product with base64 encoded data.
+type ProductImage struct {
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Data        string `json:"data"`
+}
+
+// NewProductMedia creates the media of the given product. The image data is
+// sized so that the serialized record approaches the given size in bytes.
+func NewProductMedia(product Product, size int, createdAt time.Time) ProductMedia {
+	description := gofakeit.Paragraph(3, 5, 12, "\n\n")
+
+	// Base64 encodes 3 bytes into 4 characters
+	dataSize := (size - productMediaOverhead - len(description)) * 3 / 4
+	if dataSize < len(pngSignature) {
+		dataSize = len(pngSignature)
+	}
+	data := make([]byte, dataSize)
+	copy(data, pngSignature)
+	rand.Read(data[len(pngSignature):])
+
+	return ProductMedia{
+		Version:     0,
+		ID:          gofakeit.UUID(),
+		ProductID:   product.ID,
+		SKU:         product.SKU,
+		Description: description,
+		Image: ProductImage{
+			ContentType: "image/png",
+			Width:       gofakeit.Number(640, 4096),
+			Height:      gofakeit.Number(480, 4096),
+			Data:        base64.StdEncoding.EncodeToString(data),
+		},
+		CreatedAt: createdAt,
+	}
+}
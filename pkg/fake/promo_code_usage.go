@@ -0,0 +1,44 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+// PromoCodeUsage is the redemption of a sale's promo code by an order. The
+// discount is given in minor units of the order's currency.
+type PromoCodeUsage struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID              string    `json:"id"`
+	PromoCode       string    `json:"promoCode"`
+	Sale            string    `json:"sale"`
+	OrderID         string    `json:"orderId"`
+	CustomerID      string    `json:"customerId"`
+	OrderValue      int       `json:"orderValue"`
+	DiscountPercent int       `json:"discountPercent"`
+	DiscountValue   int       `json:"discountValue"`
+	Currency        string    `json:"currency"`
+	RedeemedAt      time.Time `json:"redeemedAt"`
+}
+
+// NewPromoCodeUsage creates the usage of the given sale's promo code by the
+// given order at redeemedAt. The discount is taken off the order's current
+// value.
+func NewPromoCodeUsage(order Order, sale string, promoCode string, discountPercent int, redeemedAt time.Time) PromoCodeUsage {
+	return PromoCodeUsage{
+		Version:         0,
+		ID:              gofakeit.UUID(),
+		PromoCode:       promoCode,
+		Sale:            sale,
+		OrderID:         order.ID,
+		CustomerID:      order.Customer.ID,
+		OrderValue:      order.OrderValue,
+		DiscountPercent: discountPercent,
+		DiscountValue:   order.OrderValue * discountPercent / 100,
+		Currency:        order.Currency,
+		RedeemedAt:      redeemedAt,
+	}
+}
@@ -0,0 +1,87 @@
+package fake
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type ReturnEventType string
+
+const (
+	ReturnEventTypeRequested    ReturnEventType = "RETURN_REQUESTED"
+	ReturnEventTypeReceived     ReturnEventType = "RETURN_RECEIVED"
+	ReturnEventTypeRefundIssued ReturnEventType = "REFUND_ISSUED"
+)
+
+// ReturnLifecycle is the ordered sequence of events that each return passes
+// through.
+var ReturnLifecycle = []ReturnEventType{
+	ReturnEventTypeRequested,
+	ReturnEventTypeReceived,
+	ReturnEventTypeRefundIssued,
+}
+
+// Return is the return of some or all line items of a delivered order. Its id
+// is the return merchandise authorization (RMA) number. The refund value is
+// given in minor units of the order's currency.
+type Return struct {
+	ID          string          `json:"id"`
+	OrderID     string          `json:"orderId"`
+	CustomerID  string          `json:"customerId"`
+	LineItems   []OrderLineItem `json:"lineItems"`
+	Reason      string          `json:"reason"`
+	RefundValue int             `json:"refundValue"`
+	Currency    string          `json:"currency"`
+}
+
+// NewReturn returns a random non-empty subset of the given order's line items.
+// Returned line items may have a lower quantity than the ordered ones.
+func NewReturn(order Order) Return {
+	items := make([]OrderLineItem, 0, len(order.LineItems))
+	for _, i := range rand.Perm(len(order.LineItems))[:gofakeit.Number(1, len(order.LineItems))] {
+		item := order.LineItems[i]
+		item.Quantity = gofakeit.Number(1, item.Quantity)
+		item.TotalPrice = item.Quantity * item.UnitPrice
+		items = append(items, item)
+	}
+
+	refundValue := 0
+	for _, item := range items {
+		refundValue += item.TotalPrice
+	}
+
+	return Return{
+		ID:          "RMA-" + gofakeit.DigitN(10),
+		OrderID:     order.ID,
+		CustomerID:  order.Customer.ID,
+		LineItems:   items,
+		Reason:      gofakeit.RandomString([]string{"DAMAGED", "WRONG_ITEM", "NOT_AS_DESCRIBED", "TOO_LATE", "NO_LONGER_NEEDED"}),
+		RefundValue: refundValue,
+		Currency:    order.Currency,
+	}
+}
+
+// ReturnEvent describes a single step in the lifecycle of a return.
+type ReturnEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID        string          `json:"id"`
+	Type      ReturnEventType `json:"type"`
+	Return    Return          `json:"return"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// NewReturnEvent creates an event of the given return that is created at
+// createdAt.
+func NewReturnEvent(r Return, eventType ReturnEventType, createdAt time.Time) ReturnEvent {
+	return ReturnEvent{
+		Version:   0,
+		ID:        gofakeit.UUID(),
+		Type:      eventType,
+		Return:    r,
+		CreatedAt: createdAt,
+	}
+}
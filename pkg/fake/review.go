@@ -0,0 +1,109 @@
+package fake
+
+import (
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/mroth/weightedrand"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
+)
+
+// Review is a customer's rating of a product. Reviews of verified purchases
+// reference the order in which the customer has bought the product.
+type Review struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID               string    `json:"id"`
+	ProductID        string    `json:"productId"`
+	CustomerID       string    `json:"customerId"`
+	OrderID          *string   `json:"orderId"`
+	VerifiedPurchase bool      `json:"verifiedPurchase"`
+	Rating           int       `json:"rating"` // 1 to 5 stars
+	Title            string    `json:"title"`
+	Text             string    `json:"text"`
+	CreatedAt        time.Time `json:"createdAt"`
+	LastUpdatedAt    time.Time `json:"lastUpdatedAt"`
+	Revision         int       `json:"revision"` // Each edit of the review increments the revision
+}
+
+// NewReview creates a new review of the given product. The review is a verified
+// purchase if an order id is passed.
+func NewReview(productID string, customerID string, orderID *string, createdAt time.Time) Review {
+	return Review{
+		Version:          0,
+		ID:               gofakeit.UUID(),
+		ProductID:        productID,
+		CustomerID:       customerID,
+		OrderID:          orderID,
+		VerifiedPurchase: orderID != nil,
+		Rating:           newReviewRating(),
+		Title:            gofakeit.HipsterSentence(gofakeit.Number(2, 6)),
+		Text:             gofakeit.Paragraph(1, gofakeit.Number(1, 4), gofakeit.Number(6, 14), " "),
+		CreatedAt:        createdAt,
+		LastUpdatedAt:    createdAt,
+		Revision:         0,
+	}
+}
+
+// Edit changes the rating and text of the review at the given time, as if the
+// customer changed their mind.
+func (r *Review) Edit(editedAt time.Time) {
+	r.Rating = newReviewRating()
+	r.Text = gofakeit.Paragraph(1, gofakeit.Number(1, 4), gofakeit.Number(6, 14), " ")
+	r.LastUpdatedAt = editedAt
+	r.Revision++
+}
+
+// newReviewRating returns a random rating. Like in real shops most reviews are
+// either very positive or very negative.
+func newReviewRating() int {
+	c, err := weightedrand.NewChooser(
+		weightedrand.Choice{Item: 5, Weight: 45},
+		weightedrand.Choice{Item: 4, Weight: 25},
+		weightedrand.Choice{Item: 3, Weight: 10},
+		weightedrand.Choice{Item: 2, Weight: 7},
+		weightedrand.Choice{Item: 1, Weight: 13},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return c.Pick().(int)
+}
+
+func (r *Review) Protobuf() *shoppb.Review {
+	return &shoppb.Review{
+		Version:          int32(r.Version),
+		Id:               r.ID,
+		ProductId:        r.ProductID,
+		CustomerId:       r.CustomerID,
+		OrderId:          r.OrderID,
+		VerifiedPurchase: r.VerifiedPurchase,
+		Rating:           int32(r.Rating),
+		Title:            r.Title,
+		Text:             r.Text,
+		CreatedAt:        timestamppb.New(r.CreatedAt),
+		LastUpdatedAt:    timestamppb.New(r.LastUpdatedAt),
+		Revision:         int32(r.Revision),
+	}
+}
+
+// NewReviewFromProtobuf converts a protobuf review into a Review.
+func NewReviewFromProtobuf(pb *shoppb.Review) Review {
+	return Review{
+		Version:          int(pb.GetVersion()),
+		ID:               pb.GetId(),
+		ProductID:        pb.GetProductId(),
+		CustomerID:       pb.GetCustomerId(),
+		OrderID:          pb.OrderId,
+		VerifiedPurchase: pb.GetVerifiedPurchase(),
+		Rating:           int(pb.GetRating()),
+		Title:            pb.GetTitle(),
+		Text:             pb.GetText(),
+		CreatedAt:        pb.GetCreatedAt().AsTime(),
+		LastUpdatedAt:    pb.GetLastUpdatedAt().AsTime(),
+		Revision:         int(pb.GetRevision()),
+	}
+}
@@ -0,0 +1,105 @@
+package fake
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+// SearchResultsPerPage is the number of search results on the first page,
+// which are the only ones that are clicked.
+const SearchResultsPerPage = 20
+
+// SearchQuery is a search that has been performed on the search page of a
+// frontend session. It shares the session id and the correlation id with the
+// frontend event of the search page. Results are the ids of the products on
+// the first page of results. ClickedPosition is the 1-based position of the
+// clicked result and ClickedProductID its product, both are nil if no result
+// has been clicked.
+type SearchQuery struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID               string    `json:"id"`
+	SessionID        string    `json:"sessionId"`
+	CorrelationID    string    `json:"correlationId"`
+	Query            string    `json:"query"`
+	ResultCount      int       `json:"resultCount"`
+	ZeroResults      bool      `json:"zeroResults"`
+	Results          []string  `json:"results"`
+	ClickedPosition  *int      `json:"clickedPosition"`
+	ClickedProductID *string   `json:"clickedProductId"`
+	SearchedAt       time.Time `json:"searchedAt"`
+}
+
+// NewSearchQuery returns the search query of the given frontend event at
+// searchedAt. The first page of results are the given products, usually taken
+// from the product catalog. The search yields no results with the given zero
+// result ratio or if there are no products, otherwise one of the results is
+// clicked with the given click ratio. Top results are clicked more often. It
+// returns false if the event is not a search page view.
+func NewSearchQuery(
+	event FrontendEvent,
+	products []Product,
+	zeroResultRatio float64,
+	clickRatio float64,
+	maxResults int,
+	searchedAt time.Time,
+) (SearchQuery, bool) {
+	requestedURL, err := url.Parse(event.RequestedURL)
+	if err != nil || requestedURL.Path != "/search" {
+		return SearchQuery{}, false
+	}
+
+	query := SearchQuery{
+		Version:       0,
+		ID:            gofakeit.UUID(),
+		SessionID:     event.SessionID,
+		CorrelationID: event.CorrelationID,
+		Query:         requestedURL.Query().Get("q"),
+		Results:       []string{},
+		SearchedAt:    searchedAt,
+	}
+	if len(products) == 0 || gofakeit.Float64Range(0, 1) < zeroResultRatio {
+		query.ZeroResults = true
+		return query, true
+	}
+
+	query.ResultCount = gofakeit.Number(1, maxResults)
+	page := products
+	if len(page) > SearchResultsPerPage {
+		page = page[:SearchResultsPerPage]
+	}
+	if len(page) > query.ResultCount {
+		page = page[:query.ResultCount]
+	}
+	for _, product := range page {
+		query.Results = append(query.Results, product.ID)
+	}
+
+	if gofakeit.Float64Range(0, 1) < clickRatio {
+		position := 1
+		for position < len(page) && gofakeit.Float64Range(0, 1) < 0.5 {
+			position++
+		}
+		query.ClickedPosition = &position
+		query.ClickedProductID = &page[position-1].ID
+	}
+
+	return query, true
+}
+
+// newSearchTerms returns the terms of a search, which is a single noun or a
+// noun with a describing adjective or color.
+func newSearchTerms() string {
+	switch gofakeit.Number(1, 4) {
+	case 1:
+		return strings.ToLower(gofakeit.Adjective() + " " + gofakeit.Noun())
+	case 2:
+		return strings.ToLower(gofakeit.Color() + " " + gofakeit.Noun())
+	default:
+		return strings.ToLower(gofakeit.Noun())
+	}
+}
@@ -0,0 +1,111 @@
+package fake
+
+import (
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
+)
+
+type ShipmentEventType string
+
+const (
+	ShipmentEventTypeLabelCreated ShipmentEventType = "LABEL_CREATED"
+	ShipmentEventTypePickedUp     ShipmentEventType = "PICKED_UP"
+	ShipmentEventTypeInTransit    ShipmentEventType = "IN_TRANSIT"
+	ShipmentEventTypeDelivered    ShipmentEventType = "DELIVERED"
+)
+
+// ShipmentLifecycle is the ordered sequence of events that each shipment
+// passes through.
+var ShipmentLifecycle = []ShipmentEventType{
+	ShipmentEventTypeLabelCreated,
+	ShipmentEventTypePickedUp,
+	ShipmentEventTypeInTransit,
+	ShipmentEventTypeDelivered,
+}
+
+// Shipment is the delivery of a single order to the customer.
+type Shipment struct {
+	ID             string `json:"id"`
+	OrderID        string `json:"orderId"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"trackingNumber"`
+	City           string `json:"city"`
+}
+
+func NewShipment(order Order) Shipment {
+	return Shipment{
+		ID:             gofakeit.UUID(),
+		OrderID:        order.ID,
+		Carrier:        gofakeit.RandomString([]string{"DHL", "UPS", "FEDEX", "USPS", "DPD"}),
+		TrackingNumber: strings.ToUpper(gofakeit.LetterN(2) + gofakeit.DigitN(10)),
+		City:           order.DeliveryAddress.City,
+	}
+}
+
+// ShipmentEvent describes a single step in the lifecycle of a shipment.
+type ShipmentEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID        string            `json:"id"`
+	Type      ShipmentEventType `json:"type"`
+	Shipment  Shipment          `json:"shipment"`
+	Location  string            `json:"location"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+func NewShipmentEvent(shipment Shipment, eventType ShipmentEventType, createdAt time.Time) ShipmentEvent {
+	location := gofakeit.City()
+	if eventType == ShipmentEventTypeDelivered {
+		location = shipment.City
+	}
+
+	return ShipmentEvent{
+		Version:   0,
+		ID:        gofakeit.UUID(),
+		Type:      eventType,
+		Shipment:  shipment,
+		Location:  location,
+		CreatedAt: createdAt,
+	}
+}
+
+func (e *ShipmentEvent) Protobuf() *shoppb.ShipmentEvent {
+	return &shoppb.ShipmentEvent{
+		Version: int32(e.Version),
+		Id:      e.ID,
+		Type:    string(e.Type),
+		Shipment: &shoppb.ShipmentEvent_Shipment{
+			Id:             e.Shipment.ID,
+			OrderId:        e.Shipment.OrderID,
+			Carrier:        e.Shipment.Carrier,
+			TrackingNumber: e.Shipment.TrackingNumber,
+			City:           e.Shipment.City,
+		},
+		Location:  e.Location,
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+}
+
+// NewShipmentEventFromProtobuf converts a protobuf shipment event into a ShipmentEvent.
+func NewShipmentEventFromProtobuf(pb *shoppb.ShipmentEvent) ShipmentEvent {
+	return ShipmentEvent{
+		Version: int(pb.GetVersion()),
+		ID:      pb.GetId(),
+		Type:    ShipmentEventType(pb.GetType()),
+		Shipment: Shipment{
+			ID:             pb.GetShipment().GetId(),
+			OrderID:        pb.GetShipment().GetOrderId(),
+			Carrier:        pb.GetShipment().GetCarrier(),
+			TrackingNumber: pb.GetShipment().GetTrackingNumber(),
+			City:           pb.GetShipment().GetCity(),
+		},
+		Location:  pb.GetLocation(),
+		CreatedAt: pb.GetCreatedAt().AsTime(),
+	}
+}
@@ -0,0 +1,97 @@
+package fake
+
+import (
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+)
+
+type SupportTicketEventType string
+
+const (
+	SupportTicketEventTypeOpened   SupportTicketEventType = "TICKET_OPENED"
+	SupportTicketEventTypeReplied  SupportTicketEventType = "TICKET_REPLIED"
+	SupportTicketEventTypeResolved SupportTicketEventType = "TICKET_RESOLVED"
+)
+
+type SupportTicketCategory string
+
+const (
+	SupportTicketCategoryShippingDelay   SupportTicketCategory = "SHIPPING_DELAY"
+	SupportTicketCategoryPaymentDeclined SupportTicketCategory = "PAYMENT_DECLINED"
+	SupportTicketCategoryProductQuestion SupportTicketCategory = "PRODUCT_QUESTION"
+)
+
+// supportTicketSubjects are the subjects of the tickets of each category.
+var supportTicketSubjects = map[SupportTicketCategory][]string{
+	SupportTicketCategoryShippingDelay:   {"Where is my order?", "My delivery is late", "Package still not arrived"},
+	SupportTicketCategoryPaymentDeclined: {"Payment was declined", "Why was my card rejected?", "Unable to pay for my order"},
+	SupportTicketCategoryProductQuestion: {"Question about my order", "Product differs from description", "How do I use this product?"},
+}
+
+// SupportTicket is a customer's support request about an order. The category
+// names the cause of the ticket, e.g. a delayed shipment of the order.
+type SupportTicket struct {
+	ID         string                `json:"id"`
+	OrderID    string                `json:"orderId"`
+	CustomerID string                `json:"customerId"`
+	Category   SupportTicketCategory `json:"category"`
+	Priority   string                `json:"priority"`
+	Subject    string                `json:"subject"`
+}
+
+// NewSupportTicket creates a ticket of the given category about an order.
+// Tickets about declined payments have a high priority.
+func NewSupportTicket(orderID string, customerID string, category SupportTicketCategory) SupportTicket {
+	priority := gofakeit.RandomString([]string{"LOW", "NORMAL", "NORMAL", "HIGH"})
+	if category == SupportTicketCategoryPaymentDeclined {
+		priority = "HIGH"
+	}
+
+	return SupportTicket{
+		ID:         "TCK-" + strings.ToUpper(gofakeit.LetterN(2)+gofakeit.DigitN(6)),
+		OrderID:    orderID,
+		CustomerID: customerID,
+		Category:   category,
+		Priority:   priority,
+		Subject:    gofakeit.RandomString(supportTicketSubjects[category]),
+	}
+}
+
+// SupportTicketEvent is a single step in the conversation of a support
+// ticket. Author is either CUSTOMER or AGENT.
+type SupportTicketEvent struct {
+	// VersionedStruct
+	Version int `json:"version"`
+
+	ID        string                 `json:"id"`
+	Type      SupportTicketEventType `json:"type"`
+	Ticket    SupportTicket          `json:"ticket"`
+	Author    string                 `json:"author"`
+	Message   string                 `json:"message"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+// NewSupportTicketEvent creates an event of the given ticket. Tickets are
+// opened by the customer and resolved by an agent, replies alternate between
+// both, starting with the agent. The event is created at createdAt.
+func NewSupportTicketEvent(ticket SupportTicket, eventType SupportTicketEventType, reply int, createdAt time.Time) SupportTicketEvent {
+	author := "CUSTOMER"
+	switch {
+	case eventType == SupportTicketEventTypeResolved:
+		author = "AGENT"
+	case eventType == SupportTicketEventTypeReplied && reply%2 == 0:
+		author = "AGENT"
+	}
+
+	return SupportTicketEvent{
+		Version:   0,
+		ID:        gofakeit.UUID(),
+		Type:      eventType,
+		Ticket:    ticket,
+		Author:    author,
+		Message:   gofakeit.Sentence(gofakeit.Number(5, 20)),
+		CreatedAt: createdAt,
+	}
+}
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,13 +10,16 @@ import (
 	"github.com/jcmturner/gokrb5/v8/keytab"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/aws"
 	"github.com/twmb/franz-go/pkg/sasl/kerberos"
+	sasloauth "github.com/twmb/franz-go/pkg/sasl/oauth"
 	"github.com/twmb/franz-go/pkg/sasl/plain"
 	"github.com/twmb/franz-go/pkg/sasl/scram"
 	"github.com/twmb/franz-go/plugin/kzap"
 	"go.uber.org/zap"
 
 	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/oauth"
 )
 
 // NewKgoConfig creates a new Config for the Kafka Client as exposed by the franz-go library.
@@ -86,6 +90,26 @@ func NewKgoConfig(cfg *config.Kafka, logger *zap.Logger) ([]kgo.Opt, error) {
 			}.AsMechanism()
 			opts = append(opts, kgo.SASL(kerberosMechanism))
 		}
+
+		// OAuth Bearer
+		if cfg.SASL.Mechanism == "OAUTHBEARER" {
+			tokenSource := oauth.NewTokenSource(cfg.SASL.OAuthBearer)
+			extensions := cfg.SASL.OAuthBearer.Extensions
+			mechanism := sasloauth.Oauth(func(ctx context.Context) (sasloauth.Auth, error) {
+				token, err := tokenSource.Token(ctx)
+				if err != nil {
+					return sasloauth.Auth{}, err
+				}
+				return sasloauth.Auth{Token: token, Extensions: extensions}, nil
+			})
+			opts = append(opts, kgo.SASL(mechanism))
+		}
+
+		// AWS MSK IAM
+		if cfg.SASL.Mechanism == "AWS_MSK_IAM" {
+			credentialsSource := newAWSCredentialsSource(cfg.SASL.AWSMSKIAM)
+			opts = append(opts, kgo.SASL(aws.ManagedStreamingIAM(credentialsSource.auth)))
+		}
 	}
 
 	// Configure TLS
@@ -98,5 +122,90 @@ func NewKgoConfig(cfg *config.Kafka, logger *zap.Logger) ([]kgo.Opt, error) {
 		opts = append(opts, kgo.DialTLSConfig(tlsCfg))
 	}
 
+	// Configure compression and batching
+	if cfg.Producer.Compression != "" {
+		opts = append(opts, kgo.ProducerBatchCompression(compressionCodec(cfg.Producer.Compression)))
+	}
+	if cfg.Producer.Linger != 0 {
+		opts = append(opts, kgo.ProducerLinger(cfg.Producer.Linger))
+	}
+	if cfg.Producer.BatchMaxBytes != 0 {
+		opts = append(opts, kgo.ProducerBatchMaxBytes(cfg.Producer.BatchMaxBytes))
+	}
+	if !cfg.Producer.Idempotent() {
+		opts = append(opts, kgo.DisableIdempotentWrite())
+	}
+
+	// Configure the delivery semantics
+	switch cfg.Producer.Acks {
+	case config.AcksNone:
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()))
+	case config.AcksLeader:
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()))
+	}
+	if cfg.Producer.MaxInFlight != 0 {
+		opts = append(opts, kgo.MaxProduceRequestsInflightPerBroker(cfg.Producer.MaxInFlight))
+	}
+	// The client's record retries are the number of tries including the
+	// first one
+	switch {
+	case cfg.Producer.Retries > 0:
+		opts = append(opts, kgo.RecordRetries(cfg.Producer.Retries+1))
+	case cfg.Producer.Retries < 0:
+		opts = append(opts, kgo.RecordRetries(1))
+	}
+	if cfg.Producer.DeliveryTimeout != 0 {
+		opts = append(opts, kgo.RecordDeliveryTimeout(cfg.Producer.DeliveryTimeout))
+	}
+	if cfg.OutageTolerance.Enabled {
+		opts = append(opts, kgo.MaxBufferedRecords(cfg.OutageTolerance.MaxBufferedRecords))
+	}
+
+	if cfg.Rack != "" {
+		opts = append(opts, kgo.Rack(cfg.Rack))
+	}
+
+	// Configure the group protocol, which only applies to group consumers
+	if cfg.Consumer.Balancer != "" {
+		opts = append(opts, kgo.Balancers(groupBalancer(cfg.Consumer.Balancer)))
+	}
+	if cfg.Consumer.SessionTimeout != 0 {
+		opts = append(opts, kgo.SessionTimeout(cfg.Consumer.SessionTimeout))
+	}
+	if cfg.Consumer.HeartbeatInterval != 0 {
+		opts = append(opts, kgo.HeartbeatInterval(cfg.Consumer.HeartbeatInterval))
+	}
+	if cfg.Consumer.RebalanceTimeout != 0 {
+		opts = append(opts, kgo.RebalanceTimeout(cfg.Consumer.RebalanceTimeout))
+	}
+
 	return opts, nil
 }
+
+func compressionCodec(compression string) kgo.CompressionCodec {
+	switch compression {
+	case config.CompressionGzip:
+		return kgo.GzipCompression()
+	case config.CompressionSnappy:
+		return kgo.SnappyCompression()
+	case config.CompressionLz4:
+		return kgo.Lz4Compression()
+	case config.CompressionZstd:
+		return kgo.ZstdCompression()
+	default:
+		return kgo.NoCompression()
+	}
+}
+
+func groupBalancer(balancer string) kgo.GroupBalancer {
+	switch balancer {
+	case config.BalancerSticky:
+		return kgo.StickyBalancer()
+	case config.BalancerRange:
+		return kgo.RangeBalancer()
+	case config.BalancerRoundRobin:
+		return kgo.RoundRobinBalancer()
+	default:
+		return kgo.CooperativeStickyBalancer()
+	}
+}
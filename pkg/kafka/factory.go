@@ -14,6 +14,17 @@ import (
 type Factory struct {
 	Config config.Kafka
 	Logger *zap.Logger
+
+	// opts are added to the options of all created clients.
+	opts []kgo.Opt
+	// hooks are registered on all created clients. They are kept apart from
+	// the options, so that producers which do not produce via a client can
+	// call them, see Hooks.
+	hooks []kgo.Hook
+	// clientHooks create hooks that keep state per client, e.g. because
+	// the order of the records is only defined per client. Each created
+	// client registers its own instances.
+	clientHooks []func() kgo.Hook
 }
 
 // NewFactory creates a new Kafka factory.
@@ -24,18 +35,108 @@ func NewFactory(cfg config.Kafka, logger *zap.Logger) *Factory {
 	}
 }
 
+// NewFactories creates a Kafka factory for each configured cluster, keyed by
+// the cluster name. The default cluster is keyed by the empty string.
+func NewFactories(cfg config.Kafka, logger *zap.Logger) (map[string]*Factory, error) {
+	names := []string{""}
+	for _, cluster := range cfg.Clusters {
+		names = append(names, cluster.Name)
+	}
+
+	factories := make(map[string]*Factory, len(names))
+	for _, name := range names {
+		clusterCfg, err := cfg.Cluster(name)
+		if err != nil {
+			return nil, err
+		}
+		clusterLogger := logger
+		if name != "" {
+			clusterLogger = logger.With(zap.String("cluster", name))
+		}
+		factories[name] = NewFactory(clusterCfg, clusterLogger)
+	}
+
+	return factories, nil
+}
+
+// WithProducer returns a copy of the factory whose clients use the given
+// producer config in place of the options that it sets.
+func (s *Factory) WithProducer(cfg config.Producer) *Factory {
+	factoryCfg := s.Config
+	factoryCfg.Producer = factoryCfg.Producer.WithOverrides(cfg)
+	factory := NewFactory(factoryCfg, s.Logger)
+	factory.opts = s.opts
+	factory.hooks = s.hooks
+	factory.clientHooks = s.clientHooks
+	return factory
+}
+
+// WithOpts returns a copy of the factory whose clients additionally use the
+// given options, e.g. hooks that apply to all clients of a service.
+func (s *Factory) WithOpts(opts ...kgo.Opt) *Factory {
+	factory := NewFactory(s.Config, s.Logger)
+	factory.opts = append(append([]kgo.Opt{}, s.opts...), opts...)
+	factory.hooks = s.hooks
+	factory.clientHooks = s.clientHooks
+	return factory
+}
+
+// WithoutInstanceID returns a copy of the factory whose clients don't use
+// static group membership, e.g. for temporary group members. Unlike static
+// members, they leave their group when they are closed, which triggers a
+// rebalance right away.
+func (s *Factory) WithoutInstanceID() *Factory {
+	factoryCfg := s.Config
+	factoryCfg.Consumer.InstanceID = ""
+	factory := NewFactory(factoryCfg, s.Logger)
+	factory.opts = s.opts
+	factory.hooks = s.hooks
+	factory.clientHooks = s.clientHooks
+	return factory
+}
+
+// WithHooks returns a copy of the factory whose clients additionally use the
+// given hooks.
+func (s *Factory) WithHooks(hooks ...kgo.Hook) *Factory {
+	factory := NewFactory(s.Config, s.Logger)
+	factory.opts = s.opts
+	factory.hooks = append(append([]kgo.Hook{}, s.hooks...), hooks...)
+	factory.clientHooks = s.clientHooks
+	return factory
+}
+
+// WithClientHooks returns a copy of the factory whose clients additionally
+// use the hooks that are created by the given functions. Unlike the hooks of
+// WithHooks, each client uses its own instances.
+func (s *Factory) WithClientHooks(newHooks ...func() kgo.Hook) *Factory {
+	factory := NewFactory(s.Config, s.Logger)
+	factory.opts = s.opts
+	factory.hooks = s.hooks
+	factory.clientHooks = append(append([]func() kgo.Hook{}, s.clientHooks...), newHooks...)
+	return factory
+}
+
+// Hooks returns the hooks that are registered on all created clients,
+// including new instances of the per-client hooks. Hooks that are passed as
+// options are not included.
+func (s *Factory) Hooks() []kgo.Hook {
+	hooks := append([]kgo.Hook{}, s.hooks...)
+	for _, newHook := range s.clientHooks {
+		hooks = append(hooks, newHook())
+	}
+	return hooks
+}
+
 // NewKafkaClient creates a new Kafka client with the same stored
 // Kafka configuration.
 func (s *Factory) NewKafkaClient(
 	clientID string,
 	additionalOpts ...kgo.Opt,
 ) (*kgo.Client, error) {
-	kgoOpts, err := NewKgoConfig(&s.Config, s.Logger.Named(clientID))
+	kgoOpts, err := s.clientOpts(clientID, additionalOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create a valid kafka client config: %w", err)
+		return nil, err
 	}
-	kgoOpts = append(kgoOpts, kgo.ClientID(clientID))
-	kgoOpts = append(kgoOpts, additionalOpts...)
 
 	kafkaClient, err := kgo.NewClient(kgoOpts...)
 	if err != nil {
@@ -44,3 +145,44 @@ func (s *Factory) NewKafkaClient(
 
 	return kafkaClient, nil
 }
+
+// NewGroupTransactSession creates a new transactional group consumer for
+// consume-transform-produce transactions with the same stored Kafka
+// configuration. The options must include the consumer group and the
+// transactional ID.
+func (s *Factory) NewGroupTransactSession(
+	clientID string,
+	additionalOpts ...kgo.Opt,
+) (*kgo.GroupTransactSession, error) {
+	kgoOpts, err := s.clientOpts(clientID, additionalOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := kgo.NewGroupTransactSession(kgoOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka group transact session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (s *Factory) clientOpts(clientID string, additionalOpts ...kgo.Opt) ([]kgo.Opt, error) {
+	kgoOpts, err := NewKgoConfig(&s.Config, s.Logger.Named(clientID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a valid kafka client config: %w", err)
+	}
+	kgoOpts = append(kgoOpts, kgo.ClientID(clientID))
+	if s.Config.Consumer.InstanceID != "" {
+		// The instance ID is only used by clients that join a group, see
+		// WithoutInstanceID for temporary members
+		kgoOpts = append(kgoOpts, kgo.InstanceID(s.Config.Consumer.InstanceID+"-"+clientID))
+	}
+	if hooks := s.Hooks(); len(hooks) > 0 {
+		kgoOpts = append(kgoOpts, kgo.WithHooks(hooks...))
+	}
+	kgoOpts = append(kgoOpts, s.opts...)
+	kgoOpts = append(kgoOpts, additionalOpts...)
+
+	return kgoOpts, nil
+}
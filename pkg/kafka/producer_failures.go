@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// Producer produces records asynchronously, like a Kafka client.
+type Producer interface {
+	// Produce produces the record and calls the promise once it has been
+	// acknowledged or failed.
+	Produce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error))
+
+	// Flush waits until all produced records have been acknowledged.
+	Flush(ctx context.Context) error
+}
+
+// InjectedFailureHook is called for each attempt of a record that has failed
+// with an injected error. Retried is false if the retries of the record are
+// exhausted, in which case the record fails with the error.
+type InjectedFailureHook func(r *kgo.Record, err error, retried bool)
+
+// WithInjectedFailures returns the given producer wrapped by a producer that
+// injects the producer failures of the factory's config, or the producer
+// itself if they are disabled. The hook is called for each injected failure.
+func (s *Factory) WithInjectedFailures(producer Producer, hook InjectedFailureHook) Producer {
+	if !s.Config.Producer.Failures.Enabled {
+		return producer
+	}
+	return &failingProducer{
+		cfg:      s.Config.Producer.Failures,
+		producer: producer,
+		hook:     hook,
+	}
+}
+
+// failingProducer fails a ratio of the produce attempts before they reach the
+// wrapped producer. Failed attempts are retried by a timer after the backoff,
+// so that retried records may be produced after records that have been
+// produced later.
+type failingProducer struct {
+	cfg      config.ProducerFailures
+	producer Producer
+	hook     InjectedFailureHook
+
+	mu sync.Mutex
+	// retrying is the number of records whose attempts have failed and
+	// which have not been passed to the producer or failed yet. drained is
+	// closed once it drops to 0.
+	retrying int
+	drained  chan struct{}
+}
+
+// Produce passes the record to the producer, unless its first attempt fails.
+func (p *failingProducer) Produce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error)) {
+	err := p.attempt()
+	if err == nil {
+		p.producer.Produce(ctx, r, promise)
+		return
+	}
+
+	p.mu.Lock()
+	if p.retrying == 0 {
+		p.drained = make(chan struct{})
+	}
+	p.retrying++
+	p.mu.Unlock()
+
+	p.scheduleRetry(ctx, r, promise, err, 0)
+}
+
+// scheduleRetry retries the record once the failed attempt and the backoff
+// have elapsed, until an attempt succeeds or the retries are exhausted.
+func (p *failingProducer) scheduleRetry(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error), err error, retries int) {
+	wait := p.cfg.RetryBackoff
+	if err == kerr.RequestTimedOut {
+		wait += p.cfg.Timeout
+	}
+
+	time.AfterFunc(wait, func() {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			p.finish(func() { promise(r, ctxErr) })
+			return
+		}
+		if retries == p.cfg.MaxRetries {
+			p.hook(r, err, false)
+			p.finish(func() { promise(r, err) })
+			return
+		}
+		p.hook(r, err, true)
+
+		if err := p.attempt(); err != nil {
+			p.scheduleRetry(ctx, r, promise, err, retries+1)
+			return
+		}
+		p.finish(func() { p.producer.Produce(ctx, r, promise) })
+	})
+}
+
+// finish passes the retried record on and marks it as finished afterwards, so
+// that Flush flushes the producer once it has been passed to the producer.
+func (p *failingProducer) finish(pass func()) {
+	pass()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retrying--
+	if p.retrying == 0 {
+		close(p.drained)
+	}
+}
+
+// attempt returns the error of a failed attempt or nil.
+func (p *failingProducer) attempt() error {
+	if rand.Float64() >= p.cfg.Ratio {
+		return nil
+	}
+	if rand.Float64() < p.cfg.TimeoutRatio {
+		return kerr.RequestTimedOut
+	}
+	return kerr.NotLeaderForPartition
+}
+
+// Flush waits until all retried records have been passed to the producer or
+// failed, and then flushes the producer.
+func (p *failingProducer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	drained := p.drained
+	if p.retrying == 0 {
+		drained = nil
+	}
+	p.mu.Unlock()
+
+	if drained != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-drained:
+		}
+	}
+
+	return p.producer.Flush(ctx)
+}
@@ -0,0 +1,148 @@
+package kafka
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/sasl/aws"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/oauth"
+)
+
+// awsCredentialsSource retrieves the credentials for the AWS_MSK_IAM
+// mechanism. Credentials are taken from the config, the standard AWS
+// environment variables or, if a role and web identity token file are set in
+// the environment, requested from AWS STS. Credentials issued by STS are
+// cached until shortly before they expire.
+type awsCredentialsSource struct {
+	cfg        config.SASLAWSMSKIAM
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	credentials aws.Auth
+	expiresAt   time.Time
+}
+
+// assumeRoleWithWebIdentityResponse is the relevant part of the response of
+// the STS AssumeRoleWithWebIdentity action.
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+func newAWSCredentialsSource(cfg config.SASLAWSMSKIAM) *awsCredentialsSource {
+	return &awsCredentialsSource{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// auth returns the credentials for a single authentication. It is passed to
+// aws.ManagedStreamingIAM.
+func (s *awsCredentialsSource) auth(ctx context.Context) (aws.Auth, error) {
+	var credentials aws.Auth
+	switch {
+	case s.cfg.AccessKey != "":
+		credentials = aws.Auth{
+			AccessKey:    s.cfg.AccessKey,
+			SecretKey:    s.cfg.SecretKey,
+			SessionToken: s.cfg.SessionToken,
+		}
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		credentials = aws.Auth{
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	case os.Getenv("AWS_ROLE_ARN") != "" && os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "":
+		var err error
+		credentials, err = s.assumeRoleWithWebIdentity(ctx)
+		if err != nil {
+			return aws.Auth{}, err
+		}
+	default:
+		return aws.Auth{}, fmt.Errorf("no aws credentials configured or found in the environment")
+	}
+	credentials.UserAgent = s.cfg.UserAgent
+
+	return credentials, nil
+}
+
+// assumeRoleWithWebIdentity returns the cached credentials or requests new
+// credentials for the role in AWS_ROLE_ARN from AWS STS.
+func (s *awsCredentialsSource) assumeRoleWithWebIdentity(ctx context.Context) (aws.Auth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.credentials.AccessKey != "" && time.Now().Before(s.expiresAt) {
+		return s.credentials, nil
+	}
+
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return aws.Auth{}, fmt.Errorf("failed to read web identity token file: %w", err)
+	}
+
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("owl-shop-%d", time.Now().Unix())
+	}
+
+	endpoint := "https://sts.amazonaws.com/"
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		endpoint = fmt.Sprintf("https://sts.%v.amazonaws.com/", region)
+	}
+
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithWebIdentity")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", os.Getenv("AWS_ROLE_ARN"))
+	query.Set("RoleSessionName", sessionName)
+	query.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return aws.Auth{}, fmt.Errorf("failed to create sts request: %w", err)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return aws.Auth{}, fmt.Errorf("failed to assume role with web identity: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return aws.Auth{}, fmt.Errorf("failed to read sts response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return aws.Auth{}, fmt.Errorf("sts returned status code %d: %s", res.StatusCode, body)
+	}
+
+	var response assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return aws.Auth{}, fmt.Errorf("failed to decode sts response: %w", err)
+	}
+
+	s.credentials = aws.Auth{
+		AccessKey:    response.Credentials.AccessKeyID,
+		SecretKey:    response.Credentials.SecretAccessKey,
+		SessionToken: response.Credentials.SessionToken,
+	}
+	s.expiresAt = response.Credentials.Expiration.Add(-oauth.TokenExpiryMargin)
+
+	return s.credentials, nil
+}
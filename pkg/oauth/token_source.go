@@ -0,0 +1,116 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// TokenExpiryMargin is the duration before a token's expiry at which a new
+// token is requested, so that no expired token is sent to the server.
+const TokenExpiryMargin = 30 * time.Second
+
+// TokenSource retrieves bearer tokens. Tokens that are issued by a token
+// endpoint are cached until shortly before they expire, token files are
+// re-read on each call so that rotated tokens are picked up.
+type TokenSource struct {
+	cfg        config.OAuthBearer
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// tokenResponse is the response of an OAuth 2.0 token endpoint as defined in
+// RFC 6749, section 5.1.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// NewTokenSource creates a new TokenSource for the given config.
+func NewTokenSource(cfg config.OAuthBearer) *TokenSource {
+	return &TokenSource{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Token returns the configured static token, the content of the token file
+// or a token that has been issued by the token endpoint.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	switch {
+	case s.cfg.Token != "":
+		return s.cfg.Token, nil
+	case s.cfg.TokenFilepath != "":
+		token, err := os.ReadFile(s.cfg.TokenFilepath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	default:
+		return s.requestToken(ctx)
+	}
+}
+
+// requestToken returns the cached token or requests a new token from the token
+// endpoint using the client credentials grant.
+func (s *TokenSource) requestToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expiresAt) {
+		return s.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if s.cfg.Scope != "" {
+		form.Set("scope", s.cfg.Scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status code %d: %s", res.StatusCode, body)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned no access token")
+	}
+
+	s.token = token.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - TokenExpiryMargin)
+
+	return s.token, nil
+}
@@ -0,0 +1,27 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that authenticates each request with a
+// bearer token of the given token source.
+type Transport struct {
+	Source *TokenSource
+	Base   http.RoundTripper
+}
+
+// RoundTrip sets the Authorization header on a copy of the request and sends
+// it via the base round tripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve bearer token: %w", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return t.Base.RoundTrip(req)
+}
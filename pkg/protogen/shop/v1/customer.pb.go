@@ -69,20 +69,133 @@ func (Customer_CustomerType) EnumDescriptor() ([]byte, []int) {
 	return file_shop_v1_customer_proto_rawDescGZIP(), []int{0, 0}
 }
 
+type Customer_Segment int32
+
+const (
+	Customer_SEGMENT_UNSPECIFIED Customer_Segment = 0
+	Customer_SEGMENT_NEW         Customer_Segment = 1
+	Customer_SEGMENT_RETURNING   Customer_Segment = 2
+	Customer_SEGMENT_VIP         Customer_Segment = 3
+)
+
+// Enum value maps for Customer_Segment.
+var (
+	Customer_Segment_name = map[int32]string{
+		0: "SEGMENT_UNSPECIFIED",
+		1: "SEGMENT_NEW",
+		2: "SEGMENT_RETURNING",
+		3: "SEGMENT_VIP",
+	}
+	Customer_Segment_value = map[string]int32{
+		"SEGMENT_UNSPECIFIED": 0,
+		"SEGMENT_NEW":         1,
+		"SEGMENT_RETURNING":   2,
+		"SEGMENT_VIP":         3,
+	}
+)
+
+func (x Customer_Segment) Enum() *Customer_Segment {
+	p := new(Customer_Segment)
+	*p = x
+	return p
+}
+
+func (x Customer_Segment) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Customer_Segment) Descriptor() protoreflect.EnumDescriptor {
+	return file_shop_v1_customer_proto_enumTypes[1].Descriptor()
+}
+
+func (Customer_Segment) Type() protoreflect.EnumType {
+	return &file_shop_v1_customer_proto_enumTypes[1]
+}
+
+func (x Customer_Segment) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Customer_Segment.Descriptor instead.
+func (Customer_Segment) EnumDescriptor() ([]byte, []int) {
+	return file_shop_v1_customer_proto_rawDescGZIP(), []int{0, 1}
+}
+
+type Customer_LoyaltyTier int32
+
+const (
+	Customer_LOYALTY_TIER_UNSPECIFIED Customer_LoyaltyTier = 0
+	Customer_LOYALTY_TIER_BRONZE      Customer_LoyaltyTier = 1
+	Customer_LOYALTY_TIER_SILVER      Customer_LoyaltyTier = 2
+	Customer_LOYALTY_TIER_GOLD        Customer_LoyaltyTier = 3
+	Customer_LOYALTY_TIER_PLATINUM    Customer_LoyaltyTier = 4
+)
+
+// Enum value maps for Customer_LoyaltyTier.
+var (
+	Customer_LoyaltyTier_name = map[int32]string{
+		0: "LOYALTY_TIER_UNSPECIFIED",
+		1: "LOYALTY_TIER_BRONZE",
+		2: "LOYALTY_TIER_SILVER",
+		3: "LOYALTY_TIER_GOLD",
+		4: "LOYALTY_TIER_PLATINUM",
+	}
+	Customer_LoyaltyTier_value = map[string]int32{
+		"LOYALTY_TIER_UNSPECIFIED": 0,
+		"LOYALTY_TIER_BRONZE":      1,
+		"LOYALTY_TIER_SILVER":      2,
+		"LOYALTY_TIER_GOLD":        3,
+		"LOYALTY_TIER_PLATINUM":    4,
+	}
+)
+
+func (x Customer_LoyaltyTier) Enum() *Customer_LoyaltyTier {
+	p := new(Customer_LoyaltyTier)
+	*p = x
+	return p
+}
+
+func (x Customer_LoyaltyTier) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Customer_LoyaltyTier) Descriptor() protoreflect.EnumDescriptor {
+	return file_shop_v1_customer_proto_enumTypes[2].Descriptor()
+}
+
+func (Customer_LoyaltyTier) Type() protoreflect.EnumType {
+	return &file_shop_v1_customer_proto_enumTypes[2]
+}
+
+func (x Customer_LoyaltyTier) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Customer_LoyaltyTier.Descriptor instead.
+func (Customer_LoyaltyTier) EnumDescriptor() ([]byte, []int) {
+	return file_shop_v1_customer_proto_rawDescGZIP(), []int{0, 2}
+}
+
 type Customer struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Version      int32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	Id           string                `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
-	FirstName    string                `protobuf:"bytes,3,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
-	LastName     string                `protobuf:"bytes,4,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
-	Gender       string                `protobuf:"bytes,5,opt,name=gender,proto3" json:"gender,omitempty"`
-	CompanyName  string                `protobuf:"bytes,6,opt,name=company_name,json=companyName,proto3" json:"company_name,omitempty"`
-	Email        string                `protobuf:"bytes,7,opt,name=email,proto3" json:"email,omitempty"`
-	CustomerType Customer_CustomerType `protobuf:"varint,8,opt,name=customer_type,json=customerType,proto3,enum=shop.v1.Customer_CustomerType" json:"customer_type,omitempty"`
-	Revision     int32                 `protobuf:"varint,9,opt,name=revision,proto3" json:"revision,omitempty"`
+	Version       int32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Id            string                `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	FirstName     string                `protobuf:"bytes,3,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName      string                `protobuf:"bytes,4,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Gender        string                `protobuf:"bytes,5,opt,name=gender,proto3" json:"gender,omitempty"`
+	CompanyName   string                `protobuf:"bytes,6,opt,name=company_name,json=companyName,proto3" json:"company_name,omitempty"`
+	Email         string                `protobuf:"bytes,7,opt,name=email,proto3" json:"email,omitempty"`
+	CustomerType  Customer_CustomerType `protobuf:"varint,8,opt,name=customer_type,json=customerType,proto3,enum=shop.v1.Customer_CustomerType" json:"customer_type,omitempty"`
+	Revision      int32                 `protobuf:"varint,9,opt,name=revision,proto3" json:"revision,omitempty"`
+	Locale        string                `protobuf:"bytes,10,opt,name=locale,proto3" json:"locale,omitempty"`
+	Segment       Customer_Segment      `protobuf:"varint,11,opt,name=segment,proto3,enum=shop.v1.Customer_Segment" json:"segment,omitempty"`
+	LoyaltyTier   Customer_LoyaltyTier  `protobuf:"varint,12,opt,name=loyalty_tier,json=loyaltyTier,proto3,enum=shop.v1.Customer_LoyaltyTier" json:"loyalty_tier,omitempty"`
+	LoyaltyPoints int32                 `protobuf:"varint,13,opt,name=loyalty_points,json=loyaltyPoints,proto3" json:"loyalty_points,omitempty"`
+	LifetimeValue int32                 `protobuf:"varint,14,opt,name=lifetime_value,json=lifetimeValue,proto3" json:"lifetime_value,omitempty"`
+	OrderCount    int32                 `protobuf:"varint,15,opt,name=order_count,json=orderCount,proto3" json:"order_count,omitempty"`
 }
 
 func (x *Customer) Reset() {
@@ -180,12 +293,54 @@ func (x *Customer) GetRevision() int32 {
 	return 0
 }
 
+func (x *Customer) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *Customer) GetSegment() Customer_Segment {
+	if x != nil {
+		return x.Segment
+	}
+	return Customer_SEGMENT_UNSPECIFIED
+}
+
+func (x *Customer) GetLoyaltyTier() Customer_LoyaltyTier {
+	if x != nil {
+		return x.LoyaltyTier
+	}
+	return Customer_LOYALTY_TIER_UNSPECIFIED
+}
+
+func (x *Customer) GetLoyaltyPoints() int32 {
+	if x != nil {
+		return x.LoyaltyPoints
+	}
+	return 0
+}
+
+func (x *Customer) GetLifetimeValue() int32 {
+	if x != nil {
+		return x.LifetimeValue
+	}
+	return 0
+}
+
+func (x *Customer) GetOrderCount() int32 {
+	if x != nil {
+		return x.OrderCount
+	}
+	return 0
+}
+
 var File_shop_v1_customer_proto protoreflect.FileDescriptor
 
 var file_shop_v1_customer_proto_rawDesc = []byte{
 	0x0a, 0x16, 0x73, 0x68, 0x6f, 0x70, 0x2f, 0x76, 0x31, 0x2f, 0x63, 0x75, 0x73, 0x74, 0x6f, 0x6d,
 	0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x76,
-	0x31, 0x22, 0x89, 0x03, 0x0a, 0x08, 0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72, 0x12, 0x18,
+	0x31, 0x22, 0xf6, 0x06, 0x0a, 0x08, 0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72, 0x12, 0x18,
 	0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52,
 	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x02,
 	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x66, 0x69, 0x72, 0x73,
@@ -203,23 +358,54 @@ var file_shop_v1_customer_proto_rawDesc = []byte{
 	0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0c, 0x63, 0x75,
 	0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65,
 	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x09, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x72, 0x65,
-	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x65, 0x0a, 0x0c, 0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d,
-	0x65, 0x72, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x55, 0x53, 0x54, 0x4f, 0x4d,
-	0x45, 0x52, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46,
-	0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x55, 0x53, 0x54, 0x4f, 0x4d, 0x45,
-	0x52, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x50, 0x45, 0x52, 0x53, 0x4f, 0x4e, 0x41, 0x4c, 0x10,
-	0x01, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x55, 0x53, 0x54, 0x4f, 0x4d, 0x45, 0x52, 0x5f, 0x54, 0x59,
-	0x50, 0x45, 0x5f, 0x42, 0x55, 0x53, 0x49, 0x4e, 0x45, 0x53, 0x53, 0x10, 0x02, 0x42, 0x93, 0x01,
-	0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x76, 0x31, 0x42, 0x0d, 0x43,
-	0x75, 0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x38,
-	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x6c, 0x6f, 0x75, 0x64,
-	0x68, 0x75, 0x74, 0x2f, 0x6f, 0x77, 0x6c, 0x2d, 0x73, 0x68, 0x6f, 0x70, 0x2f, 0x70, 0x6b, 0x67,
-	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x67, 0x65, 0x6e, 0x2f, 0x73, 0x68, 0x6f, 0x70, 0x2f, 0x76,
-	0x31, 0x3b, 0x73, 0x68, 0x6f, 0x70, 0x76, 0x31, 0xa2, 0x02, 0x03, 0x53, 0x58, 0x58, 0xaa, 0x02,
-	0x07, 0x53, 0x68, 0x6f, 0x70, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x07, 0x53, 0x68, 0x6f, 0x70, 0x5c,
-	0x56, 0x31, 0xe2, 0x02, 0x13, 0x53, 0x68, 0x6f, 0x70, 0x5c, 0x56, 0x31, 0x5c, 0x47, 0x50, 0x42,
-	0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x08, 0x53, 0x68, 0x6f, 0x70, 0x3a,
-	0x3a, 0x56, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65,
+	0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x12, 0x33,
+	0x0a, 0x07, 0x73, 0x65, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x19, 0x2e, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d,
+	0x65, 0x72, 0x2e, 0x53, 0x65, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x07, 0x73, 0x65, 0x67, 0x6d,
+	0x65, 0x6e, 0x74, 0x12, 0x40, 0x0a, 0x0c, 0x6c, 0x6f, 0x79, 0x61, 0x6c, 0x74, 0x79, 0x5f, 0x74,
+	0x69, 0x65, 0x72, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1d, 0x2e, 0x73, 0x68, 0x6f, 0x70,
+	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72, 0x2e, 0x4c, 0x6f, 0x79,
+	0x61, 0x6c, 0x74, 0x79, 0x54, 0x69, 0x65, 0x72, 0x52, 0x0b, 0x6c, 0x6f, 0x79, 0x61, 0x6c, 0x74,
+	0x79, 0x54, 0x69, 0x65, 0x72, 0x12, 0x25, 0x0a, 0x0e, 0x6c, 0x6f, 0x79, 0x61, 0x6c, 0x74, 0x79,
+	0x5f, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x6c,
+	0x6f, 0x79, 0x61, 0x6c, 0x74, 0x79, 0x50, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x12, 0x25, 0x0a, 0x0e,
+	0x6c, 0x69, 0x66, 0x65, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x0e,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x6c, 0x69, 0x66, 0x65, 0x74, 0x69, 0x6d, 0x65, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x5f, 0x63, 0x6f, 0x75,
+	0x6e, 0x74, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x43,
+	0x6f, 0x75, 0x6e, 0x74, 0x22, 0x65, 0x0a, 0x0c, 0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x55, 0x53, 0x54, 0x4f, 0x4d, 0x45, 0x52,
+	0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45,
+	0x44, 0x10, 0x00, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x55, 0x53, 0x54, 0x4f, 0x4d, 0x45, 0x52, 0x5f,
+	0x54, 0x59, 0x50, 0x45, 0x5f, 0x50, 0x45, 0x52, 0x53, 0x4f, 0x4e, 0x41, 0x4c, 0x10, 0x01, 0x12,
+	0x1a, 0x0a, 0x16, 0x43, 0x55, 0x53, 0x54, 0x4f, 0x4d, 0x45, 0x52, 0x5f, 0x54, 0x59, 0x50, 0x45,
+	0x5f, 0x42, 0x55, 0x53, 0x49, 0x4e, 0x45, 0x53, 0x53, 0x10, 0x02, 0x22, 0x5b, 0x0a, 0x07, 0x53,
+	0x65, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x17, 0x0a, 0x13, 0x53, 0x45, 0x47, 0x4d, 0x45, 0x4e,
+	0x54, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12,
+	0x0f, 0x0a, 0x0b, 0x53, 0x45, 0x47, 0x4d, 0x45, 0x4e, 0x54, 0x5f, 0x4e, 0x45, 0x57, 0x10, 0x01,
+	0x12, 0x15, 0x0a, 0x11, 0x53, 0x45, 0x47, 0x4d, 0x45, 0x4e, 0x54, 0x5f, 0x52, 0x45, 0x54, 0x55,
+	0x52, 0x4e, 0x49, 0x4e, 0x47, 0x10, 0x02, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x45, 0x47, 0x4d, 0x45,
+	0x4e, 0x54, 0x5f, 0x56, 0x49, 0x50, 0x10, 0x03, 0x22, 0x8f, 0x01, 0x0a, 0x0b, 0x4c, 0x6f, 0x79,
+	0x61, 0x6c, 0x74, 0x79, 0x54, 0x69, 0x65, 0x72, 0x12, 0x1c, 0x0a, 0x18, 0x4c, 0x4f, 0x59, 0x41,
+	0x4c, 0x54, 0x59, 0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49,
+	0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x17, 0x0a, 0x13, 0x4c, 0x4f, 0x59, 0x41, 0x4c, 0x54,
+	0x59, 0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f, 0x42, 0x52, 0x4f, 0x4e, 0x5a, 0x45, 0x10, 0x01, 0x12,
+	0x17, 0x0a, 0x13, 0x4c, 0x4f, 0x59, 0x41, 0x4c, 0x54, 0x59, 0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f,
+	0x53, 0x49, 0x4c, 0x56, 0x45, 0x52, 0x10, 0x02, 0x12, 0x15, 0x0a, 0x11, 0x4c, 0x4f, 0x59, 0x41,
+	0x4c, 0x54, 0x59, 0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f, 0x47, 0x4f, 0x4c, 0x44, 0x10, 0x03, 0x12,
+	0x19, 0x0a, 0x15, 0x4c, 0x4f, 0x59, 0x41, 0x4c, 0x54, 0x59, 0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f,
+	0x50, 0x4c, 0x41, 0x54, 0x49, 0x4e, 0x55, 0x4d, 0x10, 0x04, 0x42, 0x93, 0x01, 0x0a, 0x0b, 0x63,
+	0x6f, 0x6d, 0x2e, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x76, 0x31, 0x42, 0x0d, 0x43, 0x75, 0x73, 0x74,
+	0x6f, 0x6d, 0x65, 0x72, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x38, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x6c, 0x6f, 0x75, 0x64, 0x68, 0x75, 0x74,
+	0x2f, 0x6f, 0x77, 0x6c, 0x2d, 0x73, 0x68, 0x6f, 0x70, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x67, 0x65, 0x6e, 0x2f, 0x73, 0x68, 0x6f, 0x70, 0x2f, 0x76, 0x31, 0x3b, 0x73,
+	0x68, 0x6f, 0x70, 0x76, 0x31, 0xa2, 0x02, 0x03, 0x53, 0x58, 0x58, 0xaa, 0x02, 0x07, 0x53, 0x68,
+	0x6f, 0x70, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x07, 0x53, 0x68, 0x6f, 0x70, 0x5c, 0x56, 0x31, 0xe2,
+	0x02, 0x13, 0x53, 0x68, 0x6f, 0x70, 0x5c, 0x56, 0x31, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74,
+	0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x08, 0x53, 0x68, 0x6f, 0x70, 0x3a, 0x3a, 0x56, 0x31,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -234,19 +420,23 @@ func file_shop_v1_customer_proto_rawDescGZIP() []byte {
 	return file_shop_v1_customer_proto_rawDescData
 }
 
-var file_shop_v1_customer_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_shop_v1_customer_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
 var file_shop_v1_customer_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
 var file_shop_v1_customer_proto_goTypes = []interface{}{
 	(Customer_CustomerType)(0), // 0: shop.v1.Customer.CustomerType
-	(*Customer)(nil),           // 1: shop.v1.Customer
+	(Customer_Segment)(0),      // 1: shop.v1.Customer.Segment
+	(Customer_LoyaltyTier)(0),  // 2: shop.v1.Customer.LoyaltyTier
+	(*Customer)(nil),           // 3: shop.v1.Customer
 }
 var file_shop_v1_customer_proto_depIdxs = []int32{
 	0, // 0: shop.v1.Customer.customer_type:type_name -> shop.v1.Customer.CustomerType
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1, // 1: shop.v1.Customer.segment:type_name -> shop.v1.Customer.Segment
+	2, // 2: shop.v1.Customer.loyalty_tier:type_name -> shop.v1.Customer.LoyaltyTier
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_shop_v1_customer_proto_init() }
@@ -273,7 +463,7 @@ func file_shop_v1_customer_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_shop_v1_customer_proto_rawDesc,
-			NumEnums:      1,
+			NumEnums:      3,
 			NumMessages:   1,
 			NumExtensions: 0,
 			NumServices:   0,
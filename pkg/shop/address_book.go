@@ -0,0 +1,79 @@
+package shop
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// addressBook tracks the latest address of each customer, which is the
+// address that is currently stored under the customer's key on the compacted
+// addresses topic.
+type addressBook struct {
+	maxSize int
+
+	mu        sync.Mutex
+	addresses map[string]fake.Address
+	// customerIDs are the keys of addresses, so that a random customer can be
+	// picked in a reproducible order.
+	customerIDs []string
+}
+
+func newAddressBook(maxSize int) *addressBook {
+	return &addressBook{
+		maxSize:     maxSize,
+		addresses:   make(map[string]fake.Address),
+		customerIDs: make([]string, 0, maxSize),
+	}
+}
+
+// put stores the given address as the latest address of its customer, unless
+// the address book is full and the customer is not tracked yet.
+func (b *addressBook) put(address fake.Address) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	customerID := address.Customer.CustomerID
+	if _, ok := b.addresses[customerID]; !ok {
+		if len(b.customerIDs) >= b.maxSize {
+			return
+		}
+		b.customerIDs = append(b.customerIDs, customerID)
+	}
+	b.addresses[customerID] = address
+}
+
+// remove removes the address of the given customer and returns it. It returns
+// false if the customer's address has not been tracked.
+func (b *addressBook) remove(customerID string) (fake.Address, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	address, ok := b.addresses[customerID]
+	if !ok {
+		return fake.Address{}, false
+	}
+	delete(b.addresses, customerID)
+	for i, id := range b.customerIDs {
+		if id == customerID {
+			b.customerIDs = append(b.customerIDs[:i], b.customerIDs[i+1:]...)
+			break
+		}
+	}
+
+	return address, true
+}
+
+// random returns the address of a random customer. It returns false if the
+// address book is empty.
+func (b *addressBook) random() (fake.Address, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.customerIDs) == 0 {
+		return fake.Address{}, false
+	}
+
+	return b.addresses[b.customerIDs[rand.Intn(len(b.customerIDs))]], true
+}
@@ -2,13 +2,13 @@ package shop
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"strconv"
 	"sync"
-	"time"
 
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/cloudhut/owl-shop/pkg/config"
@@ -17,19 +17,38 @@ import (
 )
 
 // AddressService consumes the customers topic to collect customer ID and name
-// and then produces fake addresses for that customer.
+// and then produces fake addresses for that customer. Addresses are keyed by
+// the customer ID, so that the compacted address topic keeps the latest
+// address of each customer, which changes whenever the customer moves. When it
+// consumes the tombstone of a deleted customer, it deletes the address of that
+// customer by producing a tombstone to the address topic.
 type AddressService struct {
 	cfg          config.Shop
 	logger       *zap.Logger
+	clock        *simulationClock
 	kafkaFactory *kafka.Factory
+	metrics      *clientMetrics
+	tracer       trace.Tracer
 
-	metaClient     *kgo.Client
-	consumerClient *kgo.Client
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	serde           *TopicSerde
+	customerSerde   *TopicSerde
+	cdc             *cdcTable
+	payloads        *fake.PayloadCache
 
 	bufferSize       int
 	recentCustomerMu sync.RWMutex
 	recentCustomers  []fake.Customer
 
+	// addresses are the latest addresses of the customers, which are required
+	// to change or delete the address of a customer.
+	addresses *addressBook
+
 	clientID  string
 	topicName string
 }
@@ -40,23 +59,39 @@ func NewAddressService(
 	cfg config.Shop,
 	logger *zap.Logger,
 	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
 ) (*AddressService, error) {
-	clientID := cfg.GlobalPrefix + "address-service"
-	topicName := cfg.GlobalPrefix + "addresses"
+	clientID := cfg.Services.Address.ClientIDFor(cfg.GlobalPrefix, "address-service")
+	metrics := newClientMetrics("address_service")
+	headers := newRecordHeaders(cfg.Headers, "address-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "address-service")
+	offsets := newConsumerOffsets(cfg.Services.Address.Offsets, logger)
 	consumerClient, err := kafkaFactory.NewKafkaClient(
 		clientID,
-		kgo.ConsumeTopics(topicName),
-		kgo.ConsumerGroup(clientID),
-		kgo.AutoCommitInterval(500*time.Millisecond),
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumeTopics(cfg.TopicName("customers")),
+			kgo.ConsumerGroup(cfg.GroupID("address-service")),
+		)...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create consumer client: %w", err)
 	}
 
-	metaClient, err := kafkaFactory.NewKafkaClient(clientID)
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create meta client: %w", err)
 	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
 
 	// This slice is used to keep some customers in the buffer so that we can produce addresses for these customers
 	bufferSize := 500
@@ -65,17 +100,30 @@ func NewAddressService(
 	return &AddressService{
 		cfg:          cfg,
 		logger:       logger.With(zap.String("service", "address_service")),
+		clock:        clock,
 		kafkaFactory: kafkaFactory,
+		metrics:      metrics,
+		tracer:       headers.tracer,
 
-		consumerClient: consumerClient,
-		metaClient:     metaClient,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Address.SlowConsumer),
+		offsets:         offsets,
+		metaClient:      metaClient,
+		producer:        producer,
+		serde:           serdes.Addresses,
+		customerSerde:   serdes.Customers,
+		cdc:             newCDCTable(cfg, logger, clock, metaClient, producer, "addresses", fake.Address{}),
+		payloads:        newPayloadCache(cfg.PayloadCache),
 
 		bufferSize:       bufferSize,
 		recentCustomerMu: sync.RWMutex{},
 		recentCustomers:  recentCustomers,
 
+		addresses: newAddressBook(10000),
+
 		clientID:  clientID,
-		topicName: cfg.GlobalPrefix + "addresses",
+		topicName: cfg.TopicName("addresses"),
 	}, nil
 }
 
@@ -83,11 +131,10 @@ func NewAddressService(
 func (svc *AddressService) Initialize(ctx context.Context) error {
 	svc.logger.Info("initializing address service")
 
-	err := kafka.ReconcileTopic(ctx,
+	err := reconcileTopic(ctx,
+		svc.cfg,
 		svc.metaClient,
 		svc.topicName,
-		svc.cfg.TopicPartitionCount,
-		svc.cfg.TopicReplicationFactor,
 		map[string]*string{
 			"cleanup.policy": kadm.StringPtr("compact"),
 		},
@@ -96,21 +143,35 @@ func (svc *AddressService) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to reconcile topic: %w", err)
 	}
 
+	if err := svc.cdc.Initialize(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Close stops consuming the customers topic, flushes all buffered records and
+// closes the Kafka clients.
+func (svc *AddressService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
 // Start consuming messages from customers topic that are required
 // to produce address records.
 func (svc *AddressService) Start() {
+	defer close(svc.consumerStopped)
+
 	for {
-		fetches := svc.consumerClient.PollFetches(context.Background())
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
 
-		if fetches.IsClientClosed() {
+		if svc.throttle.done(fetches) {
 			svc.logger.Warn("client closed")
 			return
 		}
 
 		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
 			svc.logger.Error("failed to poll fetches",
 				zap.String("topic", topic),
 				zap.Int32("partition", partition),
@@ -118,16 +179,20 @@ func (svc *AddressService) Start() {
 		})
 
 		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
 			kafkaMessagesConsumedTotal.
 				With(map[string]string{"event_type": EventTypeCustomerConsumed}).
 				Inc()
 
 			if rec.Value == nil {
+				ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+				svc.deleteCustomerAddresses(ctx, string(rec.Key))
+				span.End()
 				return
 			}
 
 			customer := fake.Customer{}
-			err := json.Unmarshal(rec.Value, &customer)
+			err := svc.customerSerde.Decode(rec.Value, &customer)
 			if err != nil {
 				// Skip message
 				svc.logger.Warn("failed to deserialize customer", zap.Error(err))
@@ -143,36 +208,99 @@ func (svc *AddressService) Start() {
 }
 
 // CreateAddress produces a new fake address record and produces that record
-// to the address topic.
+// to the address topic. It replaces a previous address of the same customer.
 func (svc *AddressService) CreateAddress() {
 	customer, err := svc.popCustomerFromBuffer()
 	if err != nil {
 		svc.logger.Debug("failed to pop customer from buffer", zap.Error(err))
 		return
 	}
-	address := fake.NewAddress(customer)
-	err = svc.produceAddress(address)
+	address := svc.payloads.NewAddress(customer, svc.clock.now())
+	err = svc.produceAddress(withEventType(context.Background(), EventTypeAddressCreated), address)
 	if err != nil {
 		svc.logger.Warn("failed to produce address", zap.Error(err))
 		return
 	}
 	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeAddressCreated}).Inc()
+	svc.addresses.put(address)
+	svc.cdc.emit(context.Background(), cdcOpCreate, address.ID, nil, address)
+}
+
+// ModifyAddress moves a random customer with a known address to a new address
+// and produces the next revision of the address to the address topic.
+func (svc *AddressService) ModifyAddress() {
+	address, ok := svc.addresses.random()
+	if !ok {
+		svc.logger.Debug("skipped modifying address, because the address book is empty")
+		return
+	}
+	before := address
+	address = fake.ChangeAddress(address)
+	err := svc.produceAddress(withEventType(context.Background(), EventTypeAddressModified), address)
+	if err != nil {
+		svc.logger.Warn("failed to produce address", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeAddressModified}).Inc()
+	svc.addresses.put(address)
+	svc.cdc.emit(context.Background(), cdcOpUpdate, address.ID, before, address)
+}
+
+// deleteCustomerAddresses removes the deleted customer from the buffer and
+// produces a tombstone for the address of that customer, if cascading deletes
+// are enabled. The tombstone continues the trace of the given context.
+func (svc *AddressService) deleteCustomerAddresses(ctx context.Context, customerID string) {
+	svc.recentCustomerMu.Lock()
+	for i, customer := range svc.recentCustomers {
+		if customer.ID == customerID {
+			svc.recentCustomers = append(svc.recentCustomers[:i], svc.recentCustomers[i+1:]...)
+			break
+		}
+	}
+	svc.recentCustomerMu.Unlock()
+
+	address, ok := svc.addresses.remove(customerID)
+	if !ok || !svc.cfg.Customers.CascadeDeletes {
+		return
+	}
+	svc.produceTombstone(withEventType(ctx, EventTypeAddressDeleted), customerID)
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeAddressDeleted}).Inc()
+	svc.cdc.emit(ctx, cdcOpDelete, address.ID, address, nil)
+}
+
+func (svc *AddressService) produceTombstone(ctx context.Context, customerID string) {
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, customerID, customerID),
+		Value:     nil,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce tombstone record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
 }
 
-func (svc *AddressService) produceAddress(address fake.Address) error {
-	serialized, err := json.Marshal(address)
+func (svc *AddressService) produceAddress(ctx context.Context, address fake.Address) error {
+	serialized, err := svc.serde.Encode(address)
 	if err != nil {
 		return fmt.Errorf("failed to serialize customer struct: %w", err)
 	}
 
 	rec := kgo.Record{
-		Key:     []byte(address.ID),
+		Key:     recordKey(svc.cfg, svc.topicName, address.Customer.CustomerID, address.Customer.CustomerID),
 		Value:   serialized,
-		Headers: []kgo.RecordHeader{{Key: "revision", Value: []byte("0")}},
+		Headers: []kgo.RecordHeader{{Key: "revision", Value: []byte(strconv.Itoa(address.Revision))}},
 		Topic:   svc.topicName,
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
 		if err == nil {
 			return
 		}
@@ -0,0 +1,136 @@
+package shop
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// registerAdminRoutes registers the admin API routes that allow to change the
+// traffic simulation at runtime:
+//
+//	GET  /admin/traffic          returns the current traffic settings
+//	PUT  /admin/traffic/rate     changes the request rate, e.g. {"eventsPerSecond": 10, "burst": 5}
+//	                             or the deprecated {"requestRate": 5, "interval": "500ms"}. Omitted
+//	                             settings are kept, e.g. {"burst": 5} keeps the events per second
+//	POST /admin/traffic/pause    pauses the traffic simulation
+//	POST /admin/traffic/resume   resumes the traffic simulation
+//	PUT  /admin/traffic/weights  changes the weights of the given events, e.g. {"createOrder": 100}
+//
+// All routes respond with the traffic settings after the change has been applied.
+// The routes of named profiles are registered below /admin/profiles/<name>
+// rather than /admin, see adminPathPrefix. The routes that trigger events on
+// demand are registered by registerEventRoutes.
+func (s *Shop) registerAdminRoutes(mux *http.ServeMux, pathPrefix string) {
+	mux.HandleFunc(pathPrefix+"/traffic", s.requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		s.writeTrafficSettings(w)
+	}))
+
+	mux.HandleFunc(pathPrefix+"/traffic/rate", s.requireMethod(http.MethodPut, func(w http.ResponseWriter, r *http.Request) {
+		// Settings that are omitted keep their current value
+		var req struct {
+			EventsPerSecond *float64 `json:"eventsPerSecond"`
+			Burst           *int     `json:"burst"`
+
+			// RequestRate and Interval are deprecated in favor of
+			// EventsPerSecond, which they override if set
+			RequestRate int    `json:"requestRate"`
+			Interval    string `json:"interval"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		current := s.traffic.settings()
+		eventsPerSecond, burst := current.EventsPerSecond, current.Burst
+		if req.EventsPerSecond != nil {
+			eventsPerSecond = *req.EventsPerSecond
+		}
+		if req.Burst != nil {
+			burst = *req.Burst
+		}
+		if req.RequestRate != 0 {
+			interval := time.Second
+			if req.Interval != "" {
+				parsed, err := time.ParseDuration(req.Interval)
+				if err != nil || parsed <= 0 {
+					http.Error(w, fmt.Sprintf("failed to parse interval '%v' as positive duration", req.Interval), http.StatusBadRequest)
+					return
+				}
+				interval = parsed
+			}
+			eventsPerSecond = float64(req.RequestRate) / interval.Seconds()
+		}
+
+		if err := s.traffic.setRate(eventsPerSecond, burst); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Info("changed request rate via admin api",
+			zap.Float64("events_per_second", eventsPerSecond),
+			zap.Int("burst", burst))
+		s.writeTrafficSettings(w)
+	}))
+
+	mux.HandleFunc(pathPrefix+"/traffic/pause", s.requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		s.traffic.setPaused(true)
+		s.logger.Info("paused traffic simulation via admin api")
+		s.writeTrafficSettings(w)
+	}))
+
+	mux.HandleFunc(pathPrefix+"/traffic/resume", s.requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		s.traffic.setPaused(false)
+		s.logger.Info("resumed traffic simulation via admin api")
+		s.writeTrafficSettings(w)
+	}))
+
+	mux.HandleFunc(pathPrefix+"/traffic/weights", s.requireMethod(http.MethodPut, func(w http.ResponseWriter, r *http.Request) {
+		var weights map[string]uint
+		if err := json.NewDecoder(r.Body).Decode(&weights); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.traffic.setWeights(weights); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Info("changed event weights via admin api", zap.Any("weights", weights))
+		s.writeTrafficSettings(w)
+	}))
+
+	s.registerEventRoutes(mux, pathPrefix)
+}
+
+// adminPathPrefix returns the path prefix of the admin routes of the given
+// profile.
+func adminPathPrefix(profile string) string {
+	if profile == "" {
+		return "/admin"
+	}
+	return "/admin/profiles/" + profile
+}
+
+// requireMethod responds with 405 Method Not Allowed to all requests that do
+// not use the given method.
+func (s *Shop) requireMethod(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Shop) writeTrafficSettings(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(s.traffic.settings())
+	if err != nil {
+		s.logger.Warn("failed to write traffic settings response", zap.Error(err))
+	}
+}
@@ -0,0 +1,109 @@
+package shop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// errNotFound is wrapped by errors of events that reference an unknown
+	// entity, which the admin API responds to with 404 Not Found.
+	errNotFound = errors.New("not found")
+	// errConflict is wrapped by errors of events that can't be triggered in
+	// the current state, which the admin API responds to with 409 Conflict.
+	errConflict = errors.New("conflict")
+)
+
+// registerEventRoutes registers the admin API routes that trigger specific
+// events on demand, e.g. to show a predictable event live during a demo:
+//
+//	POST /admin/events/customers      registers a new customer
+//	POST /admin/events/orders         places an order of the given customer, e.g. {"customerId": "..."}
+//	POST /admin/events/orders/cancel  cancels a recently placed order, e.g. {"orderId": "..."}
+//
+// All routes respond with 201 Created or 200 OK and the created or cancelled
+// entity. Customers are only known to the order service once it has consumed
+// them, so an order of a customer that has just been registered may have to be
+// retried.
+func (s *Shop) registerEventRoutes(mux *http.ServeMux, pathPrefix string) {
+	mux.HandleFunc(pathPrefix+"/events/customers", s.requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		customer, err := s.customerSvc.RegisterCustomer()
+		if err != nil {
+			s.writeEventError(w, err)
+			return
+		}
+		s.logger.Info("registered customer via admin api", zap.String("customer_id", customer.ID))
+		s.writeEvent(w, http.StatusCreated, customer)
+	}))
+
+	mux.HandleFunc(pathPrefix+"/events/orders", s.requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			CustomerID string `json:"customerId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.CustomerID == "" {
+			http.Error(w, "customer id must be set", http.StatusBadRequest)
+			return
+		}
+
+		order, err := s.orderSvc.PlaceCustomerOrder(req.CustomerID)
+		if err != nil {
+			s.writeEventError(w, err)
+			return
+		}
+		s.logger.Info("placed order via admin api",
+			zap.String("order_id", order.ID),
+			zap.String("customer_id", req.CustomerID))
+		s.writeEvent(w, http.StatusCreated, order)
+	}))
+
+	mux.HandleFunc(pathPrefix+"/events/orders/cancel", s.requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			OrderID string `json:"orderId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.OrderID == "" {
+			http.Error(w, "order id must be set", http.StatusBadRequest)
+			return
+		}
+
+		order, err := s.orderSvc.CancelOrder(req.OrderID)
+		if err != nil {
+			s.writeEventError(w, err)
+			return
+		}
+		s.logger.Info("cancelled order via admin api", zap.String("order_id", order.ID))
+		s.writeEvent(w, http.StatusOK, order)
+	}))
+}
+
+// writeEventError responds with the status that matches the error of a
+// triggered event.
+func (s *Shop) writeEventError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errConflict):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (s *Shop) writeEvent(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Warn("failed to write event response", zap.Error(err))
+	}
+}
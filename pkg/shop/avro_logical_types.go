@@ -0,0 +1,126 @@
+package shop
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	embedavro "github.com/cloudhut/owl-shop/pkg/shop/schemas/avro"
+)
+
+// logicalTypeOrderFields are appended to the avro order schema if avro logical
+// types are enabled. All fields have a default, so that the enriched schema is
+// compatible with the plain order schema.
+var logicalTypeOrderFields = []any{
+	map[string]any{
+		"name":    "uuid",
+		"type":    []any{"null", map[string]any{"type": "string", "logicalType": "uuid"}},
+		"default": nil,
+	},
+	map[string]any{
+		"name": "total",
+		"type": []any{"null", map[string]any{
+			"type":        "bytes",
+			"logicalType": "decimal",
+			"precision":   18,
+			"scale":       2,
+		}},
+		"default": nil,
+	},
+	map[string]any{
+		"name":    "placedAt",
+		"type":    []any{"null", map[string]any{"type": "long", "logicalType": "timestamp-millis"}},
+		"default": nil,
+	},
+	map[string]any{
+		"name": "paymentMethod",
+		"type": []any{"null", map[string]any{
+			"type":    "enum",
+			"name":    "OrderPaymentMethod",
+			"symbols": paymentMethods,
+		}},
+		"default": nil,
+	},
+	map[string]any{
+		"name":    "articleIds",
+		"type":    map[string]any{"type": "array", "items": "string"},
+		"default": []any{},
+	},
+	map[string]any{
+		"name":    "quantities",
+		"type":    map[string]any{"type": "map", "values": "int"},
+		"default": map[string]any{},
+	},
+}
+
+// paymentMethods are the symbols of the payment method enum.
+var paymentMethods = []string{"CASH", "DEBIT", "CREDIT_CARD", "PAYPAL", "INVOICE"}
+
+// avroLogicalOrder is used to encode avro orders. The fields of the logical
+// types are derived from the order and ignored if the schema does not have
+// them, so it encodes the plain order schema as well.
+type avroLogicalOrder struct {
+	fake.Order
+
+	UUID          *string          `json:"uuid"`
+	Total         *big.Rat         `json:"total"`
+	PlacedAt      *time.Time       `json:"placedAt"`
+	PaymentMethod *string          `json:"paymentMethod"`
+	ArticleIDs    []string         `json:"articleIds"`
+	Quantities    map[string]int32 `json:"quantities"`
+}
+
+func newAvroLogicalOrder(order fake.Order) avroLogicalOrder {
+	placedAt := order.CreatedAt.Truncate(time.Millisecond)
+	logicalOrder := avroLogicalOrder{
+		Order:      order,
+		UUID:       &order.ID,
+		Total:      order.Total(),
+		PlacedAt:   &placedAt,
+		ArticleIDs: make([]string, len(order.LineItems)),
+		Quantities: make(map[string]int32, len(order.LineItems)),
+	}
+	for _, method := range paymentMethods {
+		if method == order.Payment.Method {
+			logicalOrder.PaymentMethod = &order.Payment.Method
+		}
+	}
+	for i, item := range order.LineItems {
+		logicalOrder.ArticleIDs[i] = item.ArticleID
+		logicalOrder.Quantities[item.ArticleID] += int32(item.Quantity)
+	}
+	return logicalOrder
+}
+
+// avroOrderSchema returns the avro order schema, which is enriched with the
+// logical type fields if enabled.
+func (s *Serdes) avroOrderSchema() (string, error) {
+	if !s.cfg.AvroLogicalTypes.Enabled {
+		return embedavro.OrderAvro, nil
+	}
+	return appendAvroFields(embedavro.OrderAvro, logicalTypeOrderFields)
+}
+
+// appendAvroFields returns the given avro record schema with the fields
+// appended.
+func appendAvroFields(avroSchema string, appended []any) (string, error) {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(avroSchema), &schema); err != nil {
+		return "", fmt.Errorf("failed to unmarshal avro schema: %w", err)
+	}
+
+	fields, ok := schema["fields"].([]any)
+	if !ok {
+		return "", fmt.Errorf("avro schema has no fields")
+	}
+	schema["fields"] = append(fields, appended...)
+
+	serialized, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal avro schema: %w", err)
+	}
+
+	return string(serialized), nil
+}
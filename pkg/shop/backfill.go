@@ -0,0 +1,46 @@
+package shop
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// backfill simulates the configured number of page impressions with record
+// timestamps that are evenly distributed over the configured days in the past.
+// Page impressions are simulated one after another so that each of them is
+// produced with its own timestamp. Records that the consuming services produce
+// meanwhile carry the timestamp of the page impression that is currently
+// simulated. Frontend sessions advance along with the simulated time, so that
+// their page views are spread over seconds to minutes as well. It returns
+// false if the shop has been stopped during the backfill.
+func (s *Shop) backfill() bool {
+	cfg := s.cfg.Shop.Backfill
+	period := time.Duration(cfg.Days) * 24 * time.Hour
+	step := period / time.Duration(cfg.PageImpressions)
+	startedAt := s.clock.now().Add(-period)
+
+	s.logger.Info("starting historical backfill",
+		zap.Int("days", cfg.Days),
+		zap.Int("page_impressions", cfg.PageImpressions))
+	defer s.clock.set(time.Time{})
+
+	for i := 0; i < cfg.PageImpressions; i++ {
+		select {
+		case <-s.stopCh:
+			s.logger.Info("stopped historical backfill", zap.Int("simulated_page_impressions", i))
+			return false
+		default:
+		}
+
+		s.clock.set(startedAt.Add(time.Duration(i) * step))
+		pageImpressionsSimulated.Inc()
+		fn := s.traffic.pick()
+		fn()
+		s.frontendSvc.advanceDueSessions()
+	}
+
+	s.logger.Info("completed historical backfill")
+
+	return true
+}
@@ -0,0 +1,237 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// BenchmarkOptions configure a benchmark run.
+type BenchmarkOptions struct {
+	// Duration of the benchmark.
+	Duration time.Duration
+	// Producers is the number of producer goroutines, each of which uses a
+	// Kafka client of its own.
+	Producers int
+	// PoolSize is the number of payloads that are generated before the
+	// benchmark starts and produced over and over again.
+	PoolSize int
+	// ReportInterval is the interval in which the throughput is logged.
+	ReportInterval time.Duration
+}
+
+// BenchmarkResult is the throughput of a benchmark run.
+type BenchmarkResult struct {
+	// Records is the number of acknowledged records.
+	Records int64
+	// Bytes is the size of the keys and values of all acknowledged records.
+	Bytes int64
+	// Errors is the number of records that failed to be produced.
+	Errors  int64
+	Elapsed time.Duration
+}
+
+// RecordsPerSecond returns the average number of acknowledged records per
+// second.
+func (r BenchmarkResult) RecordsPerSecond() float64 {
+	return float64(r.Records) / r.Elapsed.Seconds()
+}
+
+// MegabytesPerSecond returns the average size of the acknowledged records in
+// MB per second.
+func (r BenchmarkResult) MegabytesPerSecond() float64 {
+	return float64(r.Bytes) / 1e6 / r.Elapsed.Seconds()
+}
+
+// benchmarkPayload is a pre-generated record key and value.
+type benchmarkPayload struct {
+	key   []byte
+	value []byte
+}
+
+// benchmarkCounters are updated by the produce callbacks of all producers.
+type benchmarkCounters struct {
+	records atomic.Int64
+	bytes   atomic.Int64
+	errors  atomic.Int64
+}
+
+// Benchmark produces pre-generated customers into the benchmark topic of the
+// default cluster at the max sustainable rate, so that the shop can be used
+// as a load generator. It bypasses the traffic simulation and all services and
+// returns once the configured duration has elapsed or the context has been
+// cancelled.
+func Benchmark(ctx context.Context, cfg config.Config, logger *zap.Logger, opts BenchmarkOptions) (BenchmarkResult, error) {
+	if opts.Duration <= 0 || opts.Producers <= 0 || opts.PoolSize <= 0 || opts.ReportInterval <= 0 {
+		return BenchmarkResult{}, fmt.Errorf("duration, producers, pool size and report interval must be greater than 0")
+	}
+
+	factory := kafka.NewFactory(cfg.Kafka, logger.Named("kafka_client"))
+	topicName := cfg.Shop.TopicName("benchmark")
+	clientID := cfg.Shop.GlobalPrefix + "benchmark"
+
+	metaClient, err := factory.NewKafkaClient(clientID)
+	if err != nil {
+		return BenchmarkResult{}, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	err = reconcileTopic(ctx, cfg.Shop, metaClient, topicName, map[string]*string{
+		"cleanup.policy": kadm.StringPtr("delete"),
+	})
+	metaClient.Close()
+	if err != nil {
+		return BenchmarkResult{}, fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	logger.Info("generating benchmark payloads", zap.Int("pool_size", opts.PoolSize))
+	payloads, err := newBenchmarkPayloads(cfg.Shop.Locale, opts.PoolSize)
+	if err != nil {
+		return BenchmarkResult{}, err
+	}
+
+	clients := make([]*kgo.Client, 0, opts.Producers)
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}()
+	for i := 0; i < opts.Producers; i++ {
+		client, err := factory.NewKafkaClient(fmt.Sprintf("%v-%d", clientID, i))
+		if err != nil {
+			return BenchmarkResult{}, fmt.Errorf("failed to create producer client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+
+	logger.Info("starting benchmark",
+		zap.String("topic_name", topicName),
+		zap.Duration("duration", opts.Duration),
+		zap.Int("producers", opts.Producers))
+
+	benchmarkCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	counters := &benchmarkCounters{}
+	startedAt := time.Now()
+
+	reportStopped := make(chan struct{})
+	go func() {
+		defer close(reportStopped)
+		reportBenchmarkThroughput(benchmarkCtx, logger, counters, opts.ReportInterval)
+	}()
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(offset int, client *kgo.Client) {
+			defer wg.Done()
+			produceBenchmarkRecords(benchmarkCtx, client, topicName, payloads, offset, opts.Producers, counters)
+		}(i, client)
+	}
+	wg.Wait()
+	<-reportStopped
+
+	// Records whose context has been cancelled are failed right away, so
+	// that only the in-flight records are awaited
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFlush()
+	for _, client := range clients {
+		if err := client.Flush(flushCtx); err != nil {
+			logger.Warn("failed to flush benchmark records", zap.Error(err))
+		}
+	}
+
+	result := BenchmarkResult{
+		Records: counters.records.Load(),
+		Bytes:   counters.bytes.Load(),
+		Errors:  counters.errors.Load(),
+		Elapsed: time.Since(startedAt),
+	}
+	logger.Info("completed benchmark",
+		zap.Int64("records", result.Records),
+		zap.Int64("bytes", result.Bytes),
+		zap.Int64("errors", result.Errors),
+		zap.Duration("elapsed", result.Elapsed),
+		zap.Float64("records_per_second", result.RecordsPerSecond()),
+		zap.Float64("megabytes_per_second", result.MegabytesPerSecond()))
+
+	return result, nil
+}
+
+// newBenchmarkPayloads generates the given number of serialized customers.
+func newBenchmarkPayloads(locale string, size int) ([]benchmarkPayload, error) {
+	payloads := make([]benchmarkPayload, size)
+	for i := range payloads {
+		customer := fake.NewCustomer(locale)
+		value, err := json.Marshal(customer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize customer: %w", err)
+		}
+		payloads[i] = benchmarkPayload{key: []byte(customer.ID), value: value}
+	}
+
+	return payloads, nil
+}
+
+// produceBenchmarkRecords produces every n-th payload, starting at the given
+// offset, until the context is cancelled. Produce blocks once the client's
+// buffer is full, which limits the rate to what the cluster can sustain.
+func produceBenchmarkRecords(
+	ctx context.Context,
+	client *kgo.Client,
+	topicName string,
+	payloads []benchmarkPayload,
+	offset int,
+	n int,
+	counters *benchmarkCounters,
+) {
+	for i := offset; ctx.Err() == nil; i += n {
+		payload := payloads[i%len(payloads)]
+		rec := &kgo.Record{Topic: topicName, Key: payload.key, Value: payload.value}
+		client.Produce(ctx, rec, func(rec *kgo.Record, err error) {
+			switch {
+			case err == nil:
+				counters.records.Add(1)
+				counters.bytes.Add(int64(len(rec.Key) + len(rec.Value)))
+			case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+				// Records that are buffered at the end of the benchmark
+			default:
+				counters.errors.Add(1)
+			}
+		})
+	}
+}
+
+// reportBenchmarkThroughput logs the throughput of the last interval until
+// the context is cancelled.
+func reportBenchmarkThroughput(ctx context.Context, logger *zap.Logger, counters *benchmarkCounters, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastRecords, lastBytes int64
+	lastReportAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			records, bytes := counters.records.Load(), counters.bytes.Load()
+			elapsed := now.Sub(lastReportAt).Seconds()
+			logger.Info("benchmark throughput",
+				zap.Float64("records_per_second", float64(records-lastRecords)/elapsed),
+				zap.Float64("megabytes_per_second", float64(bytes-lastBytes)/1e6/elapsed),
+				zap.Int64("errors", counters.errors.Load()))
+			lastRecords, lastBytes, lastReportAt = records, bytes, now
+		}
+	}
+}
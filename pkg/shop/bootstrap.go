@@ -0,0 +1,192 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// BootstrapOptions configures the SASL user that is created by Bootstrap.
+type BootstrapOptions struct {
+	// Username and Password of the created SCRAM user. The password of an
+	// existing user is overwritten.
+	Username string
+	Password string
+	// Mechanism is either SCRAM-SHA-256 or SCRAM-SHA-512.
+	Mechanism string
+	// Iterations of the SCRAM credentials, between 4096 and 16384.
+	Iterations int32
+	// DryRun only logs the user and ACLs that would be created.
+	DryRun bool
+}
+
+// bootstrapACL is a prefixed ACL that allows the bootstrapped user the given
+// operations on all resources of a type that start with the prefix.
+type bootstrapACL struct {
+	resource   string
+	prefix     string
+	operations []kadm.ACLOperation
+	builder    func(b *kadm.ACLBuilder, prefix string) *kadm.ACLBuilder
+}
+
+// Bootstrap creates a SCRAM user on all configured clusters and allows it to
+// use the topics, consumer groups and transactional IDs that start with the
+// prefixes of all profiles, so that secured clusters can be set up with least
+// privilege access for the shop. The configured Kafka credentials must be
+// allowed to alter users and ACLs, e.g. those of a superuser. Creating
+// existing ACLs again has no effect. Transactional IDs are derived from the
+// client IDs, so they are only covered if the client ID template starts with
+// the prefix, which is the default.
+func Bootstrap(ctx context.Context, cfg config.Config, logger *zap.Logger, opts BootstrapOptions) error {
+	if opts.Username == "" || opts.Password == "" {
+		return fmt.Errorf("username and password must be set")
+	}
+	var mechanism kadm.ScramMechanism
+	switch opts.Mechanism {
+	case config.SASLMechanismScramSHA256:
+		mechanism = kadm.ScramSha256
+	case config.SASLMechanismScramSHA512:
+		mechanism = kadm.ScramSha512
+	default:
+		return fmt.Errorf("given mechanism '%v' is invalid, must be %v or %v", opts.Mechanism, config.SASLMechanismScramSHA256, config.SASLMechanismScramSHA512)
+	}
+	if opts.Iterations < 4096 || opts.Iterations > 16384 {
+		return fmt.Errorf("iterations must be between 4096 and 16384")
+	}
+
+	var acls []bootstrapACL
+	for _, profile := range cfg.Shops() {
+		shopCfg := profile.Shop
+		if shopCfg.TopicNamePrefix() == "" || shopCfg.GroupIDPrefix() == "" || shopCfg.GlobalPrefix == "" {
+			return fmt.Errorf("refusing to bootstrap without global, topic and group prefixes, because the user would be allowed to access all topics or groups")
+		}
+		acls = append(acls,
+			bootstrapACL{
+				resource:   "topic",
+				prefix:     shopCfg.TopicNamePrefix(),
+				operations: []kadm.ACLOperation{kadm.OpRead, kadm.OpWrite, kadm.OpDescribe, kadm.OpCreate, kadm.OpDescribeConfigs},
+				builder: func(b *kadm.ACLBuilder, prefix string) *kadm.ACLBuilder {
+					return b.Topics(prefix)
+				},
+			},
+			bootstrapACL{
+				resource:   "group",
+				prefix:     shopCfg.GroupIDPrefix(),
+				operations: []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe},
+				builder: func(b *kadm.ACLBuilder, prefix string) *kadm.ACLBuilder {
+					return b.Groups(prefix)
+				},
+			},
+			bootstrapACL{
+				resource:   "transactional id",
+				prefix:     shopCfg.GlobalPrefix,
+				operations: []kadm.ACLOperation{kadm.OpWrite, kadm.OpDescribe},
+				builder: func(b *kadm.ACLBuilder, prefix string) *kadm.ACLBuilder {
+					return b.TransactionalIDs(prefix)
+				},
+			},
+		)
+	}
+
+	kafkaFactories, err := kafka.NewFactories(cfg.Kafka, logger.Named("kafka_client"))
+	if err != nil {
+		return fmt.Errorf("failed to create kafka factories: %w", err)
+	}
+	for name, factory := range kafkaFactories {
+		clusterLogger := logger
+		if name != "" {
+			clusterLogger = logger.With(zap.String("cluster", name))
+		}
+		if err := bootstrapCluster(ctx, cfg.Shop, factory, clusterLogger, mechanism, acls, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bootstrapCluster creates the user and its ACLs on a single cluster.
+// Idempotent producers require the idempotent write operation on the
+// cluster in Kafka versions before 2.8.
+func bootstrapCluster(
+	ctx context.Context,
+	cfg config.Shop,
+	factory *kafka.Factory,
+	logger *zap.Logger,
+	mechanism kadm.ScramMechanism,
+	acls []bootstrapACL,
+	opts BootstrapOptions,
+) error {
+	client, err := factory.NewKafkaClient(cfg.GlobalPrefix + "bootstrap")
+	if err != nil {
+		return fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	defer client.Close()
+	admClient := kadm.NewClient(client)
+	principal := "User:" + opts.Username
+
+	if !opts.DryRun {
+		responses, err := admClient.AlterUserSCRAMs(ctx, nil, []kadm.UpsertSCRAM{{
+			User:       opts.Username,
+			Mechanism:  mechanism,
+			Iterations: opts.Iterations,
+			Password:   opts.Password,
+		}})
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		for _, response := range responses.Sorted() {
+			if response.Err != nil {
+				return fmt.Errorf("failed to create user '%v': %w", response.User, response.Err)
+			}
+		}
+	}
+	logger.Info(createdMessage("user", opts.DryRun), zap.String("user", opts.Username), zap.String("mechanism", mechanism.String()))
+
+	builders := []*kadm.ACLBuilder{
+		kadm.NewACLs().Clusters().ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpIdempotentWrite),
+	}
+	logger.Info(createdMessage("acls", opts.DryRun),
+		zap.String("principal", principal),
+		zap.String("resource", "cluster"),
+		zap.Stringers("operations", []kadm.ACLOperation{kadm.OpIdempotentWrite}))
+	for _, acl := range acls {
+		builders = append(builders, acl.builder(kadm.NewACLs(), acl.prefix).
+			ResourcePatternType(kadm.ACLPatternPrefixed).
+			Operations(acl.operations...))
+		logger.Info(createdMessage("acls", opts.DryRun),
+			zap.String("principal", principal),
+			zap.String("resource", acl.resource),
+			zap.String("prefix", acl.prefix),
+			zap.Stringers("operations", acl.operations))
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	for _, builder := range builders {
+		results, err := admClient.CreateACLs(ctx, builder.Allow(principal))
+		if err != nil {
+			return fmt.Errorf("failed to create acls: %w", err)
+		}
+		for _, result := range results {
+			if result.Err != nil {
+				return fmt.Errorf("failed to create acl for %v on %v '%v': %w", result.Operation, result.Type, result.Name, result.Err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func createdMessage(resource string, dryRun bool) string {
+	if dryRun {
+		return "would create " + resource
+	}
+	return "created " + resource
+}
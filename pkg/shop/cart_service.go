@@ -0,0 +1,424 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// CartService simulates shopping carts. Customers create carts, add and remove
+// items and either check out their cart, which places an order via the order
+// service, or abandon it. All changes of a cart are produced as keyed events
+// to the carts topic, so that the current state of each cart can be derived
+// by stateful stream processors.
+type CartService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	customerSerde   *TopicSerde
+	serde           *TopicSerde
+
+	productCatalog *ProductCatalogService
+	orderSvc       *OrderService
+
+	bufferSize        int
+	recentCustomersMu sync.Mutex
+	recentCustomers   []fake.Customer
+
+	maxActiveCarts int
+	activeCartsMu  sync.Mutex
+	activeCarts    []activeCart
+
+	topicName string
+}
+
+// activeCart is a cart that has neither been checked out nor been abandoned.
+type activeCart struct {
+	cart fake.Cart
+	// lastActivityAt is the time of the last change, which is used to abandon
+	// carts that have not been changed for a while.
+	lastActivityAt time.Time
+}
+
+// NewCartService creates a new CartService.
+func NewCartService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	productCatalog *ProductCatalogService,
+	orderSvc *OrderService,
+	tracing *tracing,
+	clock *simulationClock,
+) (*CartService, error) {
+	clientID := cfg.Services.Cart.ClientIDFor(cfg.GlobalPrefix, "cart-service")
+	metrics := newClientMetrics("cart_service")
+	headers := newRecordHeaders(cfg.Headers, "cart-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "cart-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Cart.Offsets, logger)
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("cart-service")),
+			kgo.ConsumeTopics(cfg.TopicName("customers")),
+		)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	// This slice is used to keep some customers in the buffer so that they can create carts
+	bufferSize := 500
+	recentCustomers := make([]fake.Customer, 0, bufferSize)
+	maxActiveCarts := 500
+
+	return &CartService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "cart_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Cart.SlowConsumer),
+		offsets:         offsets,
+		customerSerde:   serdes.Customers,
+		serde:           serdes.Carts,
+
+		productCatalog: productCatalog,
+		orderSvc:       orderSvc,
+
+		bufferSize:        bufferSize,
+		recentCustomersMu: sync.Mutex{},
+		recentCustomers:   recentCustomers,
+
+		maxActiveCarts: maxActiveCarts,
+		activeCartsMu:  sync.Mutex{},
+		activeCarts:    make([]activeCart, 0, maxActiveCarts),
+
+		topicName: cfg.TopicName("carts"),
+	}, nil
+}
+
+// Initialize cart service by reconciling the carts topic.
+func (svc *CartService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing cart service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized cart service")
+
+	return nil
+}
+
+// Close stops consuming the customers topic, flushes all buffered records and
+// closes the Kafka clients.
+func (svc *CartService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// Start consuming messages from the customers topic and keep the consumed
+// customers in the buffer, so that they can create carts. Idle carts are
+// abandoned in the background until the consumer has been closed.
+func (svc *CartService) Start() {
+	defer close(svc.consumerStopped)
+
+	quit := make(chan struct{})
+	abandonStopped := make(chan struct{})
+	go func() {
+		defer close(abandonStopped)
+		svc.abandonIdleCarts(quit)
+	}()
+	defer func() {
+		close(quit)
+		<-abandonStopped
+	}()
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeCustomerConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+
+			customer := fake.Customer{}
+			err := svc.customerSerde.Decode(rec.Value, &customer)
+			if err != nil {
+				// Skip message
+				svc.logger.Warn("failed to deserialize customer", zap.Error(err))
+				return
+			}
+
+			svc.recentCustomersMu.Lock()
+			if len(svc.recentCustomers) < svc.bufferSize {
+				svc.recentCustomers = append(svc.recentCustomers, customer)
+			}
+			svc.recentCustomersMu.Unlock()
+		})
+	}
+}
+
+// CreateCart lets a previously consumed customer create a new cart with a
+// first item in it.
+func (svc *CartService) CreateCart() {
+	svc.activeCartsMu.Lock()
+	full := len(svc.activeCarts) >= svc.maxActiveCarts
+	svc.activeCartsMu.Unlock()
+	if full {
+		svc.logger.Debug("skipped creating cart, because the max active carts are reached", zap.Int("max_active_carts", svc.maxActiveCarts))
+		return
+	}
+
+	svc.recentCustomersMu.Lock()
+	if len(svc.recentCustomers) == 0 {
+		svc.recentCustomersMu.Unlock()
+		svc.logger.Debug("skipped creating cart, because no customers are buffered")
+		return
+	}
+	customer := svc.recentCustomers[0]
+	svc.recentCustomers = svc.recentCustomers[1:]
+	svc.recentCustomersMu.Unlock()
+
+	ctx, span := startTrace(context.Background(), svc.tracer, "create cart")
+	defer span.End()
+	cart := fake.NewCart(customer, svc.productCatalog.pricing)
+	if err := svc.produceCartEvent(ctx, fake.NewCartEvent(cart, fake.CartEventTypeCreated, nil, nil, svc.clock.now())); err != nil {
+		svc.logger.Warn("failed to produce cart event", zap.Error(err))
+		return
+	}
+	svc.addItem(ctx, &cart)
+
+	svc.pushCart(cart)
+}
+
+// UpdateCart picks a random active cart and either adds an item, removes an
+// item or checks out the cart.
+func (svc *CartService) UpdateCart() {
+	cart, ok := svc.popRandomCart()
+	if !ok {
+		svc.logger.Debug("skipped updating cart, because no carts are active")
+		return
+	}
+
+	ctx, span := startTrace(context.Background(), svc.tracer, "update cart")
+	defer span.End()
+	action := gofakeit.Number(1, 100)
+	switch {
+	case action <= 55:
+		svc.addItem(ctx, &cart)
+	case action <= 70 && len(cart.Items) > 1:
+		svc.removeItem(ctx, &cart)
+	default:
+		if svc.checkout(ctx, cart) {
+			return
+		}
+	}
+
+	svc.pushCart(cart)
+}
+
+func (svc *CartService) addItem(ctx context.Context, cart *fake.Cart) {
+	products := svc.productCatalog.RandomProducts(1)
+	if len(products) == 0 {
+		svc.logger.Debug("skipped adding item to cart, because the catalog is empty")
+		return
+	}
+
+	item := cart.AddItem(products[0])
+	if err := svc.produceCartEvent(ctx, fake.NewCartEvent(*cart, fake.CartEventTypeItemAdded, &item, nil, svc.clock.now())); err != nil {
+		svc.logger.Warn("failed to produce cart event", zap.Error(err))
+	}
+}
+
+func (svc *CartService) removeItem(ctx context.Context, cart *fake.Cart) {
+	item := cart.RemoveItem(rand.Intn(len(cart.Items)))
+	if err := svc.produceCartEvent(ctx, fake.NewCartEvent(*cart, fake.CartEventTypeItemRemoved, &item, nil, svc.clock.now())); err != nil {
+		svc.logger.Warn("failed to produce cart event", zap.Error(err))
+	}
+}
+
+// checkout places an order for the cart's items. It returns false if the order
+// could not be placed, in which case the cart remains active. The order
+// belongs to the trace of the given context.
+func (svc *CartService) checkout(ctx context.Context, cart fake.Cart) bool {
+	if len(cart.Items) == 0 {
+		return false
+	}
+
+	order := fake.NewOrderFromCart(cart, svc.clock.now())
+	if svc.cfg.B2B.Enabled {
+		// The quantities of the cart are kept, but like all wholesale orders
+		// the order is paid by invoice
+		order.MakeWholesale(1, svc.cfg.B2B.PaymentTermDays)
+	}
+	if !svc.orderSvc.PlaceOrder(ctx, &order) {
+		return false
+	}
+
+	if err := svc.produceCartEvent(ctx, fake.NewCartEvent(cart, fake.CartEventTypeCheckedOut, nil, &order.ID, svc.clock.now())); err != nil {
+		svc.logger.Warn("failed to produce cart event", zap.Error(err))
+	}
+
+	return true
+}
+
+// abandonIdleCarts regularly abandons all carts that have not been changed
+// within the configured duration. It returns once the quit channel has been
+// closed.
+func (svc *CartService) abandonIdleCarts(quit <-chan struct{}) {
+	ticker := time.NewTicker(svc.clock.realDuration(time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+
+		now := svc.clock.now()
+		var abandoned []fake.Cart
+		svc.activeCartsMu.Lock()
+		remaining := svc.activeCarts[:0]
+		for _, active := range svc.activeCarts {
+			if now.Sub(active.lastActivityAt) >= svc.cfg.Carts.AbandonAfter {
+				abandoned = append(abandoned, active.cart)
+				continue
+			}
+			remaining = append(remaining, active)
+		}
+		svc.activeCarts = remaining
+		svc.activeCartsMu.Unlock()
+
+		for _, cart := range abandoned {
+			if err := svc.produceCartEvent(context.Background(), fake.NewCartEvent(cart, fake.CartEventTypeAbandoned, nil, nil, svc.clock.now())); err != nil {
+				svc.logger.Warn("failed to produce cart event", zap.Error(err))
+			}
+		}
+	}
+}
+
+// popRandomCart removes a random cart from the active carts, so that it can be
+// changed without holding the lock. Changed carts must be returned via pushCart.
+func (svc *CartService) popRandomCart() (fake.Cart, bool) {
+	svc.activeCartsMu.Lock()
+	defer svc.activeCartsMu.Unlock()
+
+	if len(svc.activeCarts) == 0 {
+		return fake.Cart{}, false
+	}
+	i := rand.Intn(len(svc.activeCarts))
+	cart := svc.activeCarts[i].cart
+	last := len(svc.activeCarts) - 1
+	svc.activeCarts[i] = svc.activeCarts[last]
+	svc.activeCarts = svc.activeCarts[:last]
+
+	return cart, true
+}
+
+func (svc *CartService) pushCart(cart fake.Cart) {
+	svc.activeCartsMu.Lock()
+	defer svc.activeCartsMu.Unlock()
+
+	svc.activeCarts = append(svc.activeCarts, activeCart{cart: cart, lastActivityAt: svc.clock.now()})
+}
+
+func (svc *CartService) produceCartEvent(ctx context.Context, event fake.CartEvent) error {
+	serialized, err := svc.serde.Encode(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize cart event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, event.CartID, event.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	eventType := cartEventTypeMetricLabels[event.Type]
+	svc.producer.Produce(withEventType(ctx, eventType), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+
+	return nil
+}
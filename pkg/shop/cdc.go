@@ -0,0 +1,204 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+const (
+	cdcOpCreate = "c"
+	cdcOpUpdate = "u"
+	cdcOpDelete = "d"
+
+	// cdcDatabase is the name of the simulated database, whose tables are
+	// captured.
+	cdcDatabase = "owlshop"
+)
+
+// cdcTable emits Debezium-style change events for the rows of a single table
+// of the simulated database. Keys and values use the envelope of Connect's
+// JSON converter with schemas enabled, so that they can be consumed like the
+// change events of a Debezium connector. Deletes are followed by a tombstone,
+// like Debezium does by default.
+type cdcTable struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	client    *kgo.Client
+	producer  recordProducer
+	table     string
+	topicName string
+
+	keySchema   connectSchema
+	valueSchema connectSchema
+	// lsn is the position of the simulated write-ahead log, which increases
+	// with each change.
+	lsn atomic.Int64
+}
+
+// cdcRecord is a key or value of Connect's JSON converter.
+type cdcRecord struct {
+	Schema  connectSchema `json:"schema"`
+	Payload any           `json:"payload"`
+}
+
+// cdcEnvelope is the payload of a change event.
+type cdcEnvelope struct {
+	Before any       `json:"before"`
+	After  any       `json:"after"`
+	Source cdcSource `json:"source"`
+	Op     string    `json:"op"`
+	TsMs   int64     `json:"ts_ms"`
+}
+
+// cdcSource describes the origin of a change event.
+type cdcSource struct {
+	Version   string `json:"version"`
+	Connector string `json:"connector"`
+	Name      string `json:"name"`
+	TsMs      int64  `json:"ts_ms"`
+	Snapshot  string `json:"snapshot"`
+	DB        string `json:"db"`
+	Schema    string `json:"schema"`
+	Table     string `json:"table"`
+	LSN       int64  `json:"lsn"`
+}
+
+// newCDCTable creates the change event emitter of the given table, whose rows
+// are of the type of the given value. The client and producer must belong to
+// the service that owns the table.
+func newCDCTable(cfg config.Shop, logger *zap.Logger, clock *simulationClock, client *kgo.Client, producer recordProducer, table string, v any) *cdcTable {
+	topicName := cfg.TopicName(cfg.CDC.Server + ".public." + table)
+
+	return &cdcTable{
+		cfg:    cfg,
+		logger: logger,
+		clock:  clock,
+
+		client:    client,
+		producer:  producer,
+		table:     table,
+		topicName: topicName,
+
+		keySchema: connectSchema{
+			Type:   "struct",
+			Name:   topicName + ".Key",
+			Fields: []connectSchema{{Type: "string", Field: "id"}},
+		},
+		valueSchema: newCDCEnvelopeSchema(topicName, reflect.TypeOf(v)),
+	}
+}
+
+func newCDCEnvelopeSchema(topicName string, t reflect.Type) connectSchema {
+	before := newConnectSchema(t, topicName+".Value")
+	before.Field = "before"
+	before.Optional = true
+	after := before
+	after.Field = "after"
+
+	source := newConnectSchema(reflect.TypeOf(cdcSource{}), "io.debezium.connector.postgresql.Source")
+	source.Field = "source"
+
+	return connectSchema{
+		Type: "struct",
+		Name: topicName + ".Envelope",
+		Fields: []connectSchema{
+			before,
+			after,
+			source,
+			{Type: "string", Field: "op"},
+			{Type: "int64", Field: "ts_ms", Optional: true},
+		},
+	}
+}
+
+// Initialize creates the table's change event topic, if change events are
+// enabled.
+func (t *cdcTable) Initialize(ctx context.Context) error {
+	if !t.cfg.CDC.Enabled {
+		return nil
+	}
+
+	err := reconcileTopic(ctx, t.cfg, t.client, t.topicName, map[string]*string{
+		"cleanup.policy": kadm.StringPtr("delete"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile cdc topic: %w", err)
+	}
+
+	return nil
+}
+
+// emit produces the change event of the row with the given id, if change
+// events are enabled. Before is nil for creates and after is nil for deletes.
+func (t *cdcTable) emit(ctx context.Context, op string, id string, before any, after any) {
+	if !t.cfg.CDC.Enabled {
+		return
+	}
+
+	tsMs := t.clock.now().UnixMilli()
+	key, err := json.Marshal(cdcRecord{Schema: t.keySchema, Payload: map[string]string{"id": id}})
+	if err != nil {
+		t.logger.Warn("failed to serialize change event key", zap.Error(err))
+		return
+	}
+	value, err := json.Marshal(cdcRecord{
+		Schema: t.valueSchema,
+		Payload: cdcEnvelope{
+			Before: before,
+			After:  after,
+			Source: cdcSource{
+				Version:   "2.4.0.Final",
+				Connector: "postgresql",
+				Name:      t.cfg.CDC.Server,
+				TsMs:      tsMs,
+				Snapshot:  "false",
+				DB:        cdcDatabase,
+				Schema:    "public",
+				Table:     t.table,
+				LSN:       t.lsn.Add(1),
+			},
+			Op:   op,
+			TsMs: tsMs,
+		},
+	})
+	if err != nil {
+		t.logger.Warn("failed to serialize change event", zap.Error(err))
+		return
+	}
+
+	t.produce(ctx, EventTypeChangeEventProduced, key, value)
+	if op == cdcOpDelete {
+		t.produce(ctx, EventTypeChangeTombstoneProduced, key, nil)
+	}
+}
+
+func (t *cdcTable) produce(ctx context.Context, eventType string, key []byte, value []byte) {
+	rec := kgo.Record{
+		Key:       key,
+		Value:     value,
+		Timestamp: t.clock.now(),
+		Topic:     t.topicName,
+	}
+
+	t.producer.Produce(withEventType(ctx, eventType), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			t.logger.Error("failed to produce change event",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+}
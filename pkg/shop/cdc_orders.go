@@ -0,0 +1,94 @@
+package shop
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+const cdcOrderStatusCreated = "CREATED"
+
+// cdcOrder is a row of the orders table of the simulated database. In
+// addition to the order, it has the status that the order's lifecycle,
+// cancellation and compensations update.
+type cdcOrder struct {
+	fake.Order
+	Status string `json:"status"`
+}
+
+// cdcOrderRows keeps the rows of the most recently placed orders, so that the
+// change events of their updates carry the row before the update. Once it is
+// full, the oldest row is dropped and its order is not updated anymore.
+type cdcOrderRows struct {
+	table   *cdcTable
+	clock   *simulationClock
+	maxSize int
+
+	mu   sync.Mutex
+	rows map[string]cdcOrder
+	// orderIDs are the keys of rows in the order they have been inserted.
+	orderIDs []string
+}
+
+func newCDCOrderRows(table *cdcTable, clock *simulationClock, maxSize int) *cdcOrderRows {
+	return &cdcOrderRows{
+		table:   table,
+		clock:   clock,
+		maxSize: maxSize,
+		rows:    make(map[string]cdcOrder),
+	}
+}
+
+// insert emits the create event of the placed order and keeps its row, if
+// change events are enabled.
+func (r *cdcOrderRows) insert(ctx context.Context, order fake.Order) {
+	if !r.table.cfg.CDC.Enabled {
+		return
+	}
+	row := cdcOrder{Order: order, Status: cdcOrderStatusCreated}
+
+	r.mu.Lock()
+	if _, ok := r.rows[order.ID]; !ok {
+		for len(r.orderIDs) >= r.maxSize {
+			delete(r.rows, r.orderIDs[0])
+			r.orderIDs = r.orderIDs[1:]
+		}
+		r.orderIDs = append(r.orderIDs, order.ID)
+	}
+	r.rows[order.ID] = row
+	r.mu.Unlock()
+
+	r.table.emit(ctx, cdcOpCreate, order.ID, nil, row)
+}
+
+// update sets the status of the order's row and emits its update event with
+// the row before and after the update. Orders whose rows have been dropped
+// are not updated.
+func (r *cdcOrderRows) update(ctx context.Context, orderID string, status string) {
+	if !r.table.cfg.CDC.Enabled {
+		return
+	}
+
+	r.mu.Lock()
+	before, ok := r.rows[orderID]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	after := before
+	after.Status = status
+	after.Revision++
+	after.LastUpdatedAt = r.clock.now()
+	r.rows[orderID] = after
+	r.mu.Unlock()
+
+	r.table.emit(ctx, cdcOpUpdate, orderID, before, after)
+}
+
+// cdcOrderStatus returns the status of an order after the given event of its
+// lifecycle, e.g. CONFIRMED.
+func cdcOrderStatus(eventType fake.OrderEventType) string {
+	return strings.TrimPrefix(string(eventType), "ORDER_")
+}
@@ -0,0 +1,218 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/sr"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+	srfactory "github.com/cloudhut/owl-shop/pkg/sr"
+)
+
+// Cleanup deletes all topics, consumer groups and schema registry subjects
+// that start with their configured prefix from all configured clusters, so
+// that the shop starts from scratch the next time. Subjects of the topic
+// record strategy start with the prefixed topic name instead. The unprefixed subjects of
+// referenced schemas are deleted as well, unless they are still referenced by
+// other shops that share the schema registry. Consumer groups can only be
+// deleted once the shop has been stopped. In dry run mode, the resources that
+// would be deleted are only logged. The resources of all profiles are deleted.
+func Cleanup(ctx context.Context, cfg config.Config, logger *zap.Logger, dryRun bool) error {
+	profiles := cfg.Shops()
+	for _, profile := range profiles {
+		shopCfg := profile.Shop
+		if shopCfg.TopicNamePrefix() == "" || shopCfg.GroupIDPrefix() == "" || shopCfg.SubjectNamePrefix() == "" {
+			return fmt.Errorf("refusing to clean up without topic, group and subject prefixes, because all topics, groups or subjects would be deleted")
+		}
+	}
+
+	kafkaFactories, err := kafka.NewFactories(cfg.Kafka, logger.Named("kafka_client"))
+	if err != nil {
+		return fmt.Errorf("failed to create kafka factories: %w", err)
+	}
+	srClient, err := srfactory.NewFactory(cfg.SchemaRegistry, logger.Named("schema_registry")).NewSchemaRegistryClient()
+	if err != nil {
+		return fmt.Errorf("failed to create schema registry client: %w", err)
+	}
+
+	for _, profile := range profiles {
+		profileLogger := logger
+		if profile.Name != "" {
+			profileLogger = logger.With(zap.String("profile", profile.Name))
+		}
+
+		for name, factory := range kafkaFactories {
+			clusterLogger := profileLogger
+			if name != "" {
+				clusterLogger = profileLogger.With(zap.String("cluster", name))
+			}
+			if err := cleanupCluster(ctx, profile.Shop, factory, clusterLogger, dryRun); err != nil {
+				return err
+			}
+		}
+
+		// srClient is nil if schema registry hasn't been configured
+		if srClient == nil {
+			continue
+		}
+		if err := cleanupSubjects(ctx, subjectPrefixes(profile.Shop), srClient, profileLogger, dryRun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanupCluster deletes the consumer groups and the topics of a cluster. The
+// groups are deleted first, so that their offsets are not kept around for the
+// deleted topics.
+func cleanupCluster(ctx context.Context, cfg config.Shop, factory *kafka.Factory, logger *zap.Logger, dryRun bool) error {
+	client, err := factory.NewKafkaClient(cfg.GlobalPrefix + "cleanup")
+	if err != nil {
+		return fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	defer client.Close()
+	admClient := kadm.NewClient(client)
+
+	groups, err := admClient.ListGroups(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list consumer groups: %w", err)
+	}
+	groupNames := filterPrefix(groups.Groups(), cfg.GroupIDPrefix())
+	if len(groupNames) > 0 && !dryRun {
+		responses, err := admClient.DeleteGroups(ctx, groupNames...)
+		if err != nil {
+			return fmt.Errorf("failed to delete consumer groups: %w", err)
+		}
+		for _, response := range responses.Sorted() {
+			if response.Err != nil {
+				return fmt.Errorf("failed to delete consumer group '%v', the shop must be stopped beforehand: %w", response.Group, response.Err)
+			}
+		}
+	}
+	for _, group := range groupNames {
+		logger.Info(deletedMessage("consumer group", dryRun), zap.String("group", group))
+	}
+
+	topics, err := admClient.ListTopics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list topics: %w", err)
+	}
+	topicNames := filterPrefix(topics.Names(), cfg.TopicNamePrefix())
+	if len(topicNames) > 0 && !dryRun {
+		responses, err := admClient.DeleteTopics(ctx, topicNames...)
+		if err != nil {
+			return fmt.Errorf("failed to delete topics: %w", err)
+		}
+		for _, response := range responses.Sorted() {
+			if response.Err != nil {
+				return fmt.Errorf("failed to delete topic '%v': %w", response.Topic, response.Err)
+			}
+		}
+	}
+	for _, topic := range topicNames {
+		logger.Info(deletedMessage("topic", dryRun), zap.String("topic_name", topic))
+	}
+
+	return nil
+}
+
+// subjectPrefixes returns the prefixes of the shop's subjects. The subjects of
+// topics with the topic record strategy start with the prefixed topic name
+// rather than the subject prefix.
+func subjectPrefixes(cfg config.Shop) []string {
+	prefixes := []string{cfg.SubjectNamePrefix()}
+	for topic, topicCfg := range cfg.Topics {
+		if topicCfg.SubjectNameStrategy == config.SubjectNameStrategyTopicRecord {
+			prefixes = append(prefixes, cfg.TopicName(topic)+"-")
+		}
+	}
+	return prefixes
+}
+
+func cleanupSubjects(ctx context.Context, prefixes []string, srClient *sr.Client, logger *zap.Logger, dryRun bool) error {
+	// Soft deleted subjects must be listed as well, so that they are
+	// permanently deleted
+	subjects, err := srClient.Subjects(ctx, sr.ShowDeleted)
+	if err != nil {
+		return fmt.Errorf("failed to list subjects: %w", err)
+	}
+
+	// The referencing subjects are deleted first, otherwise the referenced
+	// subjects can't be deleted
+	for _, subject := range filterPrefix(subjects, prefixes...) {
+		if !dryRun {
+			if err := deleteSubject(ctx, srClient, subject); err != nil {
+				return err
+			}
+		}
+		logger.Info(deletedMessage("subject", dryRun), zap.String("subject", subject))
+	}
+
+	for _, subject := range referenceSubjects {
+		if !containsString(subjects, subject) {
+			continue
+		}
+		if !dryRun {
+			if err := deleteSubject(ctx, srClient, subject); err != nil {
+				logger.Warn("failed to delete referenced subject, it may still be referenced by another shop",
+					zap.String("subject", subject),
+					zap.Error(err))
+				continue
+			}
+		}
+		logger.Info(deletedMessage("subject", dryRun), zap.String("subject", subject))
+	}
+
+	return nil
+}
+
+// deleteSubject permanently deletes the subject, which must be soft deleted
+// first.
+func deleteSubject(ctx context.Context, srClient *sr.Client, subject string) error {
+	// Soft deleting an already soft deleted subject fails, which is ignored
+	// because the hard delete reports all relevant errors
+	_, _ = srClient.DeleteSubject(ctx, subject, sr.SoftDelete)
+	if _, err := srClient.DeleteSubject(ctx, subject, sr.HardDelete); err != nil {
+		return fmt.Errorf("failed to delete subject '%v': %w", subject, err)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPrefix returns the sorted values that start with any of the given
+// prefixes.
+func filterPrefix(values []string, prefixes ...string) []string {
+	var filtered []string
+	for _, value := range values {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(value, prefix) {
+				filtered = append(filtered, value)
+				break
+			}
+		}
+	}
+	sort.Strings(filtered)
+	return filtered
+}
+
+func deletedMessage(resource string, dryRun bool) string {
+	if dryRun {
+		return "would delete " + resource
+	}
+	return "deleted " + resource
+}
@@ -0,0 +1,95 @@
+package shop
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// produceStartedAtKey is the context key of the time at which a record has
+// been buffered for producing.
+type produceStartedAtKey struct{}
+
+// clientMetrics records the produced and consumed records of a service's
+// Kafka clients, labeled by service and topic. It is registered as a hook on
+// all Kafka clients of a service.
+type clientMetrics struct {
+	service string
+}
+
+var (
+	_ kgo.HookProduceRecordBuffered   = (*clientMetrics)(nil)
+	_ kgo.HookProduceRecordUnbuffered = (*clientMetrics)(nil)
+	_ kgo.HookProduceBatchWritten     = (*clientMetrics)(nil)
+	_ kgo.HookFetchRecordUnbuffered   = (*clientMetrics)(nil)
+)
+
+func newClientMetrics(service string) *clientMetrics {
+	return &clientMetrics{service: service}
+}
+
+// hook returns the client option that registers the metrics hooks.
+func (m *clientMetrics) hook() kgo.Opt {
+	return kgo.WithHooks(m)
+}
+
+func (m *clientMetrics) labels(topic string) prometheus.Labels {
+	return prometheus.Labels{"service": m.service, "topic": topic}
+}
+
+// OnProduceRecordBuffered tracks the record as in flight and remembers the
+// time at which it has been buffered in the record's context.
+func (m *clientMetrics) OnProduceRecordBuffered(r *kgo.Record) {
+	kafkaRecordsInFlight.With(m.labels(r.Topic)).Inc()
+	r.Context = context.WithValue(r.Context, produceStartedAtKey{}, time.Now())
+}
+
+// OnProduceRecordUnbuffered records the produce result and latency of the
+// record.
+func (m *clientMetrics) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	labels := m.labels(r.Topic)
+	kafkaRecordsInFlight.With(labels).Dec()
+
+	if err != nil {
+		m.error(r.Topic, "produce")
+		return
+	}
+	kafkaRecordsProducedTotal.With(labels).Inc()
+	if startedAt, ok := r.Context.Value(produceStartedAtKey{}).(time.Time); ok {
+		kafkaProduceLatencySeconds.With(labels).Observe(time.Since(startedAt).Seconds())
+	}
+}
+
+// OnProduceBatchWritten records the sizes of each produced batch before and
+// after compression, so that the compression ratio of each topic can be
+// compared across codecs.
+func (m *clientMetrics) OnProduceBatchWritten(_ kgo.BrokerMetadata, topic string, _ int32, batch kgo.ProduceBatchMetrics) {
+	labels := m.labels(topic)
+	kafkaProducedBatchesTotal.With(labels).Inc()
+	kafkaProducedUncompressedBytesTotal.With(labels).Add(float64(batch.UncompressedBytes))
+	kafkaProducedCompressedBytesTotal.With(labels).Add(float64(batch.CompressedBytes))
+}
+
+// OnFetchRecordUnbuffered counts all records that have been polled by the
+// service.
+func (m *clientMetrics) OnFetchRecordUnbuffered(r *kgo.Record, polled bool) {
+	if !polled {
+		return
+	}
+	kafkaRecordsConsumedTotal.With(m.labels(r.Topic)).Inc()
+}
+
+// fetchError counts an error that has been returned when polling the topic.
+func (m *clientMetrics) fetchError(topic string) {
+	m.error(topic, "fetch")
+}
+
+func (m *clientMetrics) error(topic string, operation string) {
+	kafkaClientErrorsTotal.With(prometheus.Labels{
+		"service":   m.service,
+		"topic":     topic,
+		"operation": operation,
+	}).Inc()
+}
@@ -0,0 +1,128 @@
+package shop
+
+import (
+	"sync"
+	"time"
+)
+
+// minPollInterval is the lower bound of the intervals in which services poll
+// for due events, so that a high time acceleration does not spin the CPU.
+const minPollInterval = 10 * time.Millisecond
+
+// Clock returns the current time. It can be injected via WithClock, e.g. to
+// produce records with deterministic timestamps in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock of the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock that only advances when it is set or advanced, so
+// that tests can drive the simulated time deterministically. All methods are
+// safe for concurrent use.
+type ManualClock struct {
+	mu sync.RWMutex
+	t  time.Time
+}
+
+// NewManualClock returns a manual clock that is set to the given time.
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{t: t}
+}
+
+// Now returns the time the clock has been set to.
+func (c *ManualClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.t
+}
+
+// Set sets the clock to the given time.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = t
+}
+
+// Advance moves the clock forward by the given duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = c.t.Add(d)
+}
+
+// simulationClock provides the time of the simulated shop, which is used for
+// the timestamps of the produced records and for all delays of the simulation,
+// such as the page delays of sessions or the steps of shipments. It returns
+// the time of its base clock, unless a backfill is in progress. During a
+// backfill it returns the point of time in the past that is currently being
+// simulated. With a time acceleration, the simulated time passes faster than
+// the time of the base clock, starting at the creation of the clock. All
+// methods are safe for concurrent use.
+type simulationClock struct {
+	base         Clock
+	acceleration float64
+	startedAt    time.Time
+
+	mu sync.RWMutex
+	// simulatedAt is the simulated point of time, or the zero time if no
+	// backfill is in progress.
+	simulatedAt time.Time
+}
+
+// newSimulationClock returns a clock whose base is the given clock or, if it
+// is nil, the system clock. An acceleration of 1 returns the time of the base
+// clock as it is.
+func newSimulationClock(base Clock, acceleration float64) *simulationClock {
+	if base == nil {
+		base = systemClock{}
+	}
+	return &simulationClock{
+		base:         base,
+		acceleration: acceleration,
+		startedAt:    base.Now(),
+	}
+}
+
+func (c *simulationClock) now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.simulatedAt.IsZero() {
+		return c.simulatedAt
+	}
+
+	t := c.base.Now()
+	if c.acceleration == 1 {
+		return t
+	}
+	return c.startedAt.Add(time.Duration(float64(t.Sub(c.startedAt)) * c.acceleration))
+}
+
+// realDuration returns the duration of the base clock in which the given
+// duration of simulated time passes, e.g. for the intervals in which due
+// events are polled. It is at least minPollInterval.
+func (c *simulationClock) realDuration(d time.Duration) time.Duration {
+	scaled := time.Duration(float64(d) / c.acceleration)
+	if scaled < minPollInterval {
+		return minPollInterval
+	}
+	return scaled
+}
+
+// set makes the clock return the given point of time. The zero time resets the
+// clock to the time of its base clock.
+func (c *simulationClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.simulatedAt = t
+}
@@ -0,0 +1,151 @@
+package shop
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+const (
+	cloudEventsSpecVersion       = "1.0"
+	cloudEventsContentType       = "application/cloudevents+json"
+	headerContentType            = "content-type"
+	headerCloudEventsSpecVersion = "ce_specversion"
+)
+
+// cloudEvents applies the CloudEvents 1.0 Kafka protocol binding to all
+// records that are produced by a service's Kafka clients. Records that carry
+// CloudEvents attributes already, e.g. injected duplicates, are kept as they
+// are. On consuming clients it unwraps structured events, so that the
+// services can decode the records regardless of the content mode. On producing
+// clients it is registered before all other hooks, so that they observe the
+// produced value.
+type cloudEvents struct {
+	cfg    config.CloudEvents
+	source string
+}
+
+var (
+	_ kgo.HookProduceRecordBuffered = (*cloudEvents)(nil)
+	_ kgo.HookFetchRecordBuffered   = (*cloudEvents)(nil)
+)
+
+// cloudEvent is a CloudEvent in the structured content mode.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	PartitionKey    string          `json:"partitionkey,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+func newCloudEvents(cfg config.CloudEvents, source string) *cloudEvents {
+	return &cloudEvents{cfg: cfg, source: source}
+}
+
+// hook returns the client option that registers the cloud events hook.
+func (c *cloudEvents) hook() kgo.Opt {
+	return kgo.WithHooks(c)
+}
+
+// OnProduceRecordBuffered turns the record into a CloudEvent of the configured
+// content mode. It is called synchronously within Produce, before the record
+// is added to a batch.
+func (c *cloudEvents) OnProduceRecordBuffered(r *kgo.Record) {
+	if !c.cfg.Enabled || r.Value == nil || hasHeader(r, headerCloudEventsSpecVersion) || isStructuredCloudEvent(r) {
+		return
+	}
+
+	eventType, ok := r.Context.Value(eventTypeKey{}).(string)
+	if !ok {
+		eventType = r.Topic
+	}
+	timestamp := r.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	contentType := "application/octet-stream"
+	if json.Valid(r.Value) {
+		contentType = "application/json"
+	}
+
+	event := cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              gofakeit.UUID(),
+		Source:          "/" + c.source,
+		Type:            c.cfg.TypePrefix + strings.ToLower(eventType),
+		Time:            timestamp.UTC(),
+		DataContentType: contentType,
+	}
+
+	if c.cfg.Mode == config.CloudEventsModeBinary {
+		setHeaderIfAbsent(r, headerCloudEventsSpecVersion, event.SpecVersion)
+		setHeaderIfAbsent(r, "ce_id", event.ID)
+		setHeaderIfAbsent(r, "ce_source", event.Source)
+		setHeaderIfAbsent(r, "ce_type", event.Type)
+		setHeaderIfAbsent(r, "ce_time", event.Time.Format(time.RFC3339Nano))
+		setHeaderIfAbsent(r, headerContentType, event.DataContentType)
+		return
+	}
+
+	// The record key is kept, so that the partitioning is not affected
+	event.PartitionKey = string(r.Key)
+	if contentType == "application/json" {
+		event.Data = r.Value
+	} else {
+		event.DataBase64 = r.Value
+	}
+	value, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	r.Value = value
+	setHeaderIfAbsent(r, headerContentType, cloudEventsContentType)
+}
+
+// OnFetchRecordBuffered replaces the value of structured CloudEvents with
+// their data before the record is polled. Structured events are unwrapped even
+// if CloudEvents are disabled, so that records of previous runs can be
+// decoded. Events that can't be unwrapped are kept as they are.
+func (c *cloudEvents) OnFetchRecordBuffered(r *kgo.Record) {
+	if !isStructuredCloudEvent(r) {
+		return
+	}
+
+	var event cloudEvent
+	if err := json.Unmarshal(r.Value, &event); err != nil {
+		return
+	}
+	if event.Data != nil {
+		r.Value = event.Data
+	} else {
+		r.Value = event.DataBase64
+	}
+}
+
+func isStructuredCloudEvent(r *kgo.Record) bool {
+	for _, header := range r.Headers {
+		if header.Key == headerContentType {
+			return string(header.Value) == cloudEventsContentType
+		}
+	}
+	return false
+}
+
+func hasHeader(r *kgo.Record, key string) bool {
+	for _, header := range r.Headers {
+		if header.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package shop
+
+import (
+	"reflect"
+	"strings"
+)
+
+// connectSchema is a Kafka Connect schema, as it is embedded in the records
+// of Connect's JSON converter with schemas enabled.
+type connectSchema struct {
+	Type     string          `json:"type"`
+	Optional bool            `json:"optional"`
+	Name     string          `json:"name,omitempty"`
+	Field    string          `json:"field,omitempty"`
+	Fields   []connectSchema `json:"fields,omitempty"`
+	Items    *connectSchema  `json:"items,omitempty"`
+	Keys     *connectSchema  `json:"keys,omitempty"`
+	Values   *connectSchema  `json:"values,omitempty"`
+}
+
+// newConnectSchema returns the Connect schema of the JSON serialization of
+// the given type. Nested structs are named after the given name and their
+// field, timestamps use Debezium's ZonedTimestamp semantic type.
+func newConnectSchema(t reflect.Type, name string) connectSchema {
+	if t == timeType {
+		return connectSchema{Type: "string", Name: "io.debezium.time.ZonedTimestamp"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := newConnectSchema(t.Elem(), name)
+		schema.Optional = true
+		return schema
+	case reflect.String:
+		return connectSchema{Type: "string"}
+	case reflect.Bool:
+		return connectSchema{Type: "boolean"}
+	case reflect.Int8:
+		return connectSchema{Type: "int8"}
+	case reflect.Int16, reflect.Uint8:
+		return connectSchema{Type: "int16"}
+	case reflect.Int32, reflect.Uint16:
+		return connectSchema{Type: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return connectSchema{Type: "int64"}
+	case reflect.Float32:
+		return connectSchema{Type: "float"}
+	case reflect.Float64:
+		return connectSchema{Type: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return connectSchema{Type: "bytes", Optional: t.Kind() == reflect.Slice}
+		}
+		items := newConnectSchema(t.Elem(), name)
+		return connectSchema{Type: "array", Optional: t.Kind() == reflect.Slice, Items: &items}
+	case reflect.Map:
+		keys := newConnectSchema(t.Key(), name)
+		values := newConnectSchema(t.Elem(), name)
+		return connectSchema{Type: "map", Optional: true, Keys: &keys, Values: &values}
+	case reflect.Struct:
+		return connectSchema{Type: "struct", Name: name, Fields: connectSchemaFields(t, name)}
+	default:
+		return connectSchema{Type: "string", Optional: true}
+	}
+}
+
+func connectSchemaFields(t reflect.Type, name string) []connectSchema {
+	fields := make([]connectSchema, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		fieldName, options, _ := strings.Cut(tag, ",")
+
+		// Fields of embedded structs without tag are promoted to the parent
+		if field.Anonymous && fieldName == "" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, connectSchemaFields(field.Type, name)...)
+			continue
+		}
+
+		if fieldName == "" {
+			fieldName = field.Name
+		}
+		schema := newConnectSchema(field.Type, name+"."+fieldName)
+		schema.Field = fieldName
+		if strings.Contains(options, "omitempty") {
+			schema.Optional = true
+		}
+		fields = append(fields, schema)
+	}
+
+	return fields
+}
@@ -0,0 +1,75 @@
+package shop
+
+import (
+	"context"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// consumerOffsets applies the offset commit strategy and the start offset of
+// a service's consumer group. It is not safe for concurrent use.
+type consumerOffsets struct {
+	cfg    config.ConsumerOffsets
+	logger *zap.Logger
+}
+
+func newConsumerOffsets(cfg config.ConsumerOffsets, logger *zap.Logger) *consumerOffsets {
+	return &consumerOffsets{cfg: cfg, logger: logger}
+}
+
+// opts returns the given consumer options along with the options of the
+// commit strategy and the start offset. The config has been validated, so
+// the start timestamp can be parsed.
+func (o *consumerOffsets) opts(opts ...kgo.Opt) []kgo.Opt {
+	switch o.cfg.CommitStrategy {
+	case config.CommitStrategyAuto:
+		opts = append(opts, kgo.AutoCommitInterval(o.cfg.AutoCommitInterval))
+	case config.CommitStrategyBatch, config.CommitStrategyRebalance:
+		// Partitions that are revoked are committed, so that the new
+		// owner continues where this consumer has stopped
+		opts = append(opts, kgo.DisableAutoCommit(), kgo.OnPartitionsRevoked(o.commitRevoked))
+	}
+
+	return o.startOpts(opts...)
+}
+
+// startOpts returns the given consumer options along with the options of the
+// start offset only. It is used by transactional consumers, whose offsets are
+// committed in their transactions regardless of the commit strategy.
+func (o *consumerOffsets) startOpts(opts ...kgo.Opt) []kgo.Opt {
+	switch o.cfg.StartOffset {
+	case config.StartOffsetEarliest:
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	case config.StartOffsetLatest:
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()))
+	case config.StartOffsetTimestamp:
+		startTime, _ := o.cfg.StartTime()
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AfterMilli(startTime.UnixMilli())))
+	}
+
+	return opts
+}
+
+// commitPolled commits the offsets of all records that have been polled by
+// the client if the batch strategy is used. It is called before each poll, so
+// that the offsets of the previous batch are committed once it has been
+// processed. Services stop their poll loop before they close the client, see
+// closeClients, so that the offsets of the last batch are committed as well.
+func (o *consumerOffsets) commitPolled(client *kgo.Client) {
+	if o.cfg.CommitStrategy != config.CommitStrategyBatch {
+		return
+	}
+
+	if err := client.CommitUncommittedOffsets(context.Background()); err != nil {
+		o.logger.Warn("failed to commit offsets of polled batch", zap.Error(err))
+	}
+}
+
+func (o *consumerOffsets) commitRevoked(ctx context.Context, client *kgo.Client, _ map[string][]int32) {
+	if err := client.CommitUncommittedOffsets(ctx); err != nil {
+		o.logger.Warn("failed to commit offsets of revoked partitions", zap.Error(err))
+	}
+}
@@ -0,0 +1,114 @@
+package shop
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// consumerThrottle slows down a service's consumer if the slow consumer mode
+// is enabled. Stopping it interrupts the consumer's poll, so that the poll
+// loop can return before its client is closed. It is not safe for concurrent
+// use, except for stop.
+type consumerThrottle struct {
+	enabled bool
+
+	recordDelay time.Duration
+	// minGap is the minimum duration between two consumed records, which
+	// bounds the throughput.
+	minGap time.Duration
+	// maxPollRecords limits the records per poll to roughly one second of
+	// work. Polling small batches ensures that the committed offsets lag
+	// behind, because offsets are committed for all polled records.
+	maxPollRecords int
+
+	lastRecordAt time.Time
+
+	// ctx is canceled once the throttle has been stopped.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func newConsumerThrottle(cfg config.SlowConsumer) *consumerThrottle {
+	ctx, cancel := context.WithCancel(context.Background())
+	throttle := &consumerThrottle{
+		enabled: cfg.Enabled,
+		ctx:     ctx,
+		cancel:  cancel,
+		stopCh:  make(chan struct{}),
+	}
+	if !cfg.Enabled {
+		return throttle
+	}
+
+	throttle.recordDelay = cfg.RecordDelay
+	if cfg.MaxRecordsPerSecond > 0 {
+		throttle.minGap = time.Second / time.Duration(cfg.MaxRecordsPerSecond)
+	}
+
+	// The throughput is bounded by whichever of both is slower
+	gap := throttle.recordDelay
+	if throttle.minGap > gap {
+		gap = throttle.minGap
+	}
+	throttle.maxPollRecords = 1
+	if gap > 0 && gap < time.Second {
+		throttle.maxPollRecords = int(time.Second / gap)
+	}
+
+	return throttle
+}
+
+// poll fetches the next batch of records from the given client until the
+// throttle has been stopped.
+func (t *consumerThrottle) poll(client *kgo.Client) kgo.Fetches {
+	if !t.enabled {
+		return client.PollFetches(t.ctx)
+	}
+	return client.PollRecords(t.ctx, t.maxPollRecords)
+}
+
+// done returns whether the poll loop shall return, because the throttle has
+// been stopped or the client has been closed.
+func (t *consumerThrottle) done(fetches kgo.Fetches) bool {
+	return fetches.IsClientClosed() || t.ctx.Err() != nil
+}
+
+// wait blocks for the artificial processing time of a single record. It returns
+// immediately once the throttle has been stopped.
+func (t *consumerThrottle) wait() {
+	if !t.enabled {
+		return
+	}
+
+	delay := t.recordDelay
+	if remaining := t.minGap - time.Since(t.lastRecordAt); remaining > delay {
+		delay = remaining
+	}
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-t.stopCh:
+			timer.Stop()
+		}
+	}
+	t.lastRecordAt = time.Now()
+}
+
+// stop releases all waiting and future calls to wait and interrupts the poll,
+// so that a service can be shut down without waiting for the artificial
+// delays.
+func (t *consumerThrottle) stop() {
+	t.stopOnce.Do(func() {
+		close(t.stopCh)
+		t.cancel()
+	})
+}
@@ -0,0 +1,112 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// changeCustomer produces the given change of a customer. If the change stream
+// is enabled, it is produced as change event to the customer changes topic,
+// otherwise the customer or its tombstone is produced to the customers topic
+// directly.
+func (svc *CustomerService) changeCustomer(ctx context.Context, customer fake.Customer, changeType fake.CustomerChangeType) error {
+	ctx = withCustomer(ctx, customer)
+	if svc.consumerClient != nil {
+		return svc.produceChange(ctx, fake.NewCustomerChange(customer, changeType, svc.clock.now()))
+	}
+
+	if changeType == fake.CustomerChangeTypeDeleted {
+		svc.produceTombstone(ctx, customer.ID)
+		return nil
+	}
+	return svc.produceCustomer(ctx, customer)
+}
+
+// Start consuming the customer changes and apply each change to the customers
+// topic, so that it holds the latest state of each customer. If the loyalty
+// program is enabled, the orders are consumed in the background. It returns
+// immediately if the change stream is disabled.
+func (svc *CustomerService) Start() {
+	if svc.loyaltyClient != nil {
+		go svc.consumeOrders()
+	}
+	if svc.consumerClient == nil {
+		return
+	}
+	defer close(svc.consumerStopped)
+
+	for {
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeCustomerChangeConsumed}).
+				Inc()
+
+			change := fake.CustomerChange{}
+			if err := json.Unmarshal(rec.Value, &change); err != nil {
+				// Skip message
+				svc.logger.Warn("failed to deserialize customer change", zap.Error(err))
+				return
+			}
+
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			defer span.End()
+			ctx = withEventType(ctx, EventTypeCustomerSnapshotUpdated)
+			if change.Customer == nil {
+				svc.produceTombstone(ctx, change.CustomerID)
+			} else if err := svc.produceCustomer(withCustomer(ctx, *change.Customer), *change.Customer); err != nil {
+				svc.logger.Warn("failed to produce customer", zap.Error(err))
+				return
+			}
+			kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeCustomerSnapshotUpdated}).Inc()
+		})
+	}
+}
+
+func (svc *CustomerService) produceChange(ctx context.Context, change fake.CustomerChange) error {
+	serialized, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to serialize customer change struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameChanges, change.CustomerID, change.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "change_type", Value: []byte(change.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameChanges,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
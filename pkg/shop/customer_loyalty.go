@@ -0,0 +1,312 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// loyaltyLedger tracks the loyalty attributes of the customers that have
+// placed orders, because the customers that are embedded in the orders may
+// lag behind the latest credit. A nil ledger tracks nothing.
+type loyaltyLedger struct {
+	cfg     config.Loyalty
+	maxSize int
+
+	mu       sync.Mutex
+	accounts map[string]loyaltyAccount
+	// deleted are the ids of the deleted customers, so that orders that are
+	// still in flight don't recreate them. Unlike the accounts, the oldest
+	// deletion is evicted first, whose orders are the least likely to still
+	// be in flight.
+	deleted    map[string]bool
+	deletedIDs []string
+}
+
+// loyaltyAccount holds the loyalty attributes of a customer, as well as the
+// customer's latest revision.
+type loyaltyAccount struct {
+	segment       fake.CustomerSegment
+	tier          fake.LoyaltyTier
+	points        int
+	lifetimeValue int
+	orderCount    int
+	revision      int
+}
+
+// newLoyaltyLedger returns nil if the loyalty program is disabled. Once the
+// ledger holds the given number of customers, a random one is evicted. It
+// keeps up to the same number of deleted customers.
+func newLoyaltyLedger(cfg config.Loyalty, maxSize int) *loyaltyLedger {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &loyaltyLedger{
+		cfg:      cfg,
+		maxSize:  maxSize,
+		accounts: make(map[string]loyaltyAccount),
+		deleted:  make(map[string]bool),
+	}
+}
+
+// credit credits the given order to its customer. It returns the customer
+// with the updated loyalty attributes and a new revision, the earned points
+// and the customer's previous tier. It returns false if the customer has been
+// deleted.
+func (l *loyaltyLedger) credit(order fake.Order) (fake.Customer, int, fake.LoyaltyTier, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	customer := order.Customer
+	if l.deleted[customer.ID] {
+		return fake.Customer{}, 0, "", false
+	}
+	account, ok := l.accounts[customer.ID]
+	if !ok {
+		account = loyaltyAccount{
+			segment:       customer.Segment,
+			tier:          customer.LoyaltyTier,
+			points:        customer.LoyaltyPoints,
+			lifetimeValue: customer.LifetimeValue,
+			orderCount:    customer.OrderCount,
+			revision:      customer.Revision,
+		}
+	}
+	previousTier := account.tier
+	if previousTier == "" {
+		previousTier = fake.LoyaltyTierBronze
+	}
+
+	// Order values are given in the order's currency
+	exchangeRate := order.ExchangeRate
+	if exchangeRate <= 0 {
+		exchangeRate = 1
+	}
+	value := int(math.Round(float64(order.OrderValue) / exchangeRate))
+	points := value * l.cfg.PointsPerUnit / 100
+
+	account.points += points
+	account.lifetimeValue += value
+	account.orderCount++
+	if account.revision < customer.Revision {
+		account.revision = customer.Revision
+	}
+	account.revision++
+	account.segment = l.segment(account)
+	account.tier = l.tier(account.points)
+	l.put(customer.ID, account)
+
+	return account.apply(customer), points, previousTier, true
+}
+
+// current returns the given customer with its tracked loyalty attributes and
+// revision, if any. It is used by changes of customers that don't stem from
+// orders, so that they don't revert the loyalty attributes.
+func (l *loyaltyLedger) current(customer fake.Customer) fake.Customer {
+	if l == nil {
+		return customer
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	account, ok := l.accounts[customer.ID]
+	if !ok {
+		return customer
+	}
+	if account.revision < customer.Revision {
+		account.revision = customer.Revision
+	}
+	return account.apply(customer)
+}
+
+// track updates the revision of the given customer after it has been changed,
+// if its loyalty attributes are tracked.
+func (l *loyaltyLedger) track(customer fake.Customer) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if account, ok := l.accounts[customer.ID]; ok {
+		account.revision = customer.Revision
+		l.accounts[customer.ID] = account
+	}
+}
+
+// remove marks the customer with the given id as deleted, so that its orders
+// are no longer credited.
+func (l *loyaltyLedger) remove(customerID string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.accounts, customerID)
+	if l.deleted[customerID] {
+		return
+	}
+	for len(l.deletedIDs) >= l.maxSize {
+		delete(l.deleted, l.deletedIDs[0])
+		l.deletedIDs = l.deletedIDs[1:]
+	}
+	l.deleted[customerID] = true
+	l.deletedIDs = append(l.deletedIDs, customerID)
+}
+
+// put tracks the given account. The caller must hold the lock.
+func (l *loyaltyLedger) put(customerID string, account loyaltyAccount) {
+	if _, ok := l.accounts[customerID]; !ok && len(l.accounts) >= l.maxSize {
+		for id := range l.accounts {
+			delete(l.accounts, id)
+			break
+		}
+	}
+	l.accounts[customerID] = account
+}
+
+// segment returns the segment of the given account.
+func (l *loyaltyLedger) segment(account loyaltyAccount) fake.CustomerSegment {
+	switch {
+	case account.lifetimeValue >= l.cfg.VIPLifetimeValue:
+		return fake.CustomerSegmentVIP
+	case account.orderCount >= l.cfg.ReturningOrders:
+		return fake.CustomerSegmentReturning
+	default:
+		return fake.CustomerSegmentNew
+	}
+}
+
+// tier returns the tier of the given loyalty points.
+func (l *loyaltyLedger) tier(points int) fake.LoyaltyTier {
+	switch {
+	case points >= l.cfg.Tiers.Platinum:
+		return fake.LoyaltyTierPlatinum
+	case points >= l.cfg.Tiers.Gold:
+		return fake.LoyaltyTierGold
+	case points >= l.cfg.Tiers.Silver:
+		return fake.LoyaltyTierSilver
+	default:
+		return fake.LoyaltyTierBronze
+	}
+}
+
+// apply returns the given customer with the account's loyalty attributes
+// and revision.
+func (a loyaltyAccount) apply(customer fake.Customer) fake.Customer {
+	customer.Segment = a.segment
+	customer.LoyaltyTier = a.tier
+	customer.LoyaltyPoints = a.points
+	customer.LifetimeValue = a.lifetimeValue
+	customer.OrderCount = a.orderCount
+	customer.Revision = a.revision
+	return customer
+}
+
+// consumeOrders consumes the orders topic and credits each order to its
+// customer. The updated customer is produced like any other modification
+// and the earned points are produced to the loyalty points topic.
+func (svc *CustomerService) consumeOrders() {
+	defer close(svc.loyaltyStopped)
+
+	for {
+		fetches := svc.loyaltyThrottle.poll(svc.loyaltyClient)
+
+		if svc.loyaltyThrottle.done(fetches) {
+			svc.logger.Warn("loyalty client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.loyaltyThrottle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeOrderConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+
+			order := fake.Order{}
+			if err := svc.orderSerde.Decode(rec.Value, &order); err != nil {
+				// Skip message
+				svc.logger.Warn("failed to deserialize order", zap.Error(err))
+				return
+			}
+
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			defer span.End()
+			svc.creditOrder(ctx, order)
+		})
+	}
+}
+
+// creditOrder updates the loyalty attributes of the order's customer and
+// produces the customer as well as the earned points.
+func (svc *CustomerService) creditOrder(ctx context.Context, order fake.Order) {
+	before := svc.loyalty.current(order.Customer)
+	customer, points, previousTier, ok := svc.loyalty.credit(order)
+	if !ok {
+		return
+	}
+
+	err := svc.changeCustomer(withEventType(ctx, EventTypeCustomerModified), customer, fake.CustomerChangeTypeModified)
+	if err != nil {
+		svc.logger.Warn("failed to produce customer", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeCustomerModified}).Inc()
+	svc.cdc.emit(ctx, cdcOpUpdate, customer.ID, before, customer)
+
+	err = svc.produceLoyaltyPoints(ctx, fake.NewLoyaltyPointsEvent(customer, order.ID, points, previousTier, svc.clock.now()))
+	if err != nil {
+		svc.logger.Warn("failed to produce loyalty points", zap.Error(err))
+	}
+}
+
+func (svc *CustomerService) produceLoyaltyPoints(ctx context.Context, event fake.LoyaltyPointsEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize loyalty points struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameLoyaltyPoints, event.CustomerID, event.CustomerID),
+		Value:     serialized,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameLoyaltyPoints,
+	}
+
+	svc.producer.Produce(withEventType(ctx, EventTypeLoyaltyPointsEarned), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeLoyaltyPointsEarned}).Inc()
+
+	return nil
+}
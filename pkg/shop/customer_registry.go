@@ -0,0 +1,155 @@
+package shop
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// customerRegistry tracks the current state of the customers topic, so that
+// records of other topics only reference customers that have been created and
+// not been deleted. It is fed by consuming the compacted customers topic:
+// records upsert customers and tombstones remove them. Customers are picked
+// at random, so that each customer may place multiple orders. Whales are
+// tracked apart from the other customers and are picked with the configured
+// ratio.
+type customerRegistry struct {
+	maxSize int
+	whales  config.Whales
+
+	mu sync.RWMutex
+	// indexes are the positions of the customers by their ID, so that
+	// customers can be removed in constant time.
+	customers []fake.Customer
+	indexes   map[string]int
+	// whaleCustomers are never replaced by new customers, so that the same
+	// keys stay hot.
+	whaleCustomers []fake.Customer
+}
+
+func newCustomerRegistry(maxSize int, whales config.Whales) *customerRegistry {
+	return &customerRegistry{
+		maxSize:   maxSize,
+		whales:    whales,
+		customers: make([]fake.Customer, 0, maxSize),
+		indexes:   make(map[string]int, maxSize),
+	}
+}
+
+// put adds the given customer or replaces its previous version. Once the
+// registry is full, a new customer replaces a random existing one.
+func (r *customerRegistry) put(customer fake.Customer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.putWhale(customer) {
+		return
+	}
+
+	if i, ok := r.indexes[customer.ID]; ok {
+		r.customers[i] = customer
+		return
+	}
+
+	if len(r.customers) >= r.maxSize {
+		i := rand.Intn(len(r.customers))
+		delete(r.indexes, r.customers[i].ID)
+		r.customers[i] = customer
+		r.indexes[customer.ID] = i
+		return
+	}
+
+	r.indexes[customer.ID] = len(r.customers)
+	r.customers = append(r.customers, customer)
+}
+
+// putWhale adds the given customer as a whale or replaces its previous
+// version, as long as there are less whales than configured. It returns false
+// if the customer is not a whale.
+func (r *customerRegistry) putWhale(customer fake.Customer) bool {
+	if !r.whales.Enabled {
+		return false
+	}
+
+	for i, whale := range r.whaleCustomers {
+		if whale.ID == customer.ID {
+			r.whaleCustomers[i] = customer
+			return true
+		}
+	}
+
+	if len(r.whaleCustomers) >= r.whales.Count {
+		return false
+	}
+	// A tracked customer is promoted, e.g. after a whale has been deleted
+	if _, ok := r.indexes[customer.ID]; ok {
+		r.removeCustomer(customer.ID)
+	}
+	r.whaleCustomers = append(r.whaleCustomers, customer)
+
+	return true
+}
+
+// remove removes the customer with the given ID, if it is tracked.
+func (r *customerRegistry) remove(customerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, whale := range r.whaleCustomers {
+		if whale.ID == customerID {
+			r.whaleCustomers = append(r.whaleCustomers[:i], r.whaleCustomers[i+1:]...)
+			return
+		}
+	}
+	r.removeCustomer(customerID)
+}
+
+func (r *customerRegistry) removeCustomer(customerID string) {
+	i, ok := r.indexes[customerID]
+	if !ok {
+		return
+	}
+
+	// Move the last customer into the gap
+	last := len(r.customers) - 1
+	r.customers[i] = r.customers[last]
+	r.indexes[r.customers[i].ID] = i
+	r.customers = r.customers[:last]
+	delete(r.indexes, customerID)
+}
+
+// get returns the customer with the given ID. It returns false if the
+// customer is not tracked.
+func (r *customerRegistry) get(customerID string) (fake.Customer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, whale := range r.whaleCustomers {
+		if whale.ID == customerID {
+			return whale, true
+		}
+	}
+	if i, ok := r.indexes[customerID]; ok {
+		return r.customers[i], true
+	}
+
+	return fake.Customer{}, false
+}
+
+// random returns a random customer, which is one of the whales with the
+// configured traffic ratio. It returns false if no customer is tracked yet.
+func (r *customerRegistry) random() (fake.Customer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.whaleCustomers) > 0 && (len(r.customers) == 0 || rand.Float64() < r.whales.TrafficRatio) {
+		return r.whaleCustomers[rand.Intn(len(r.whaleCustomers))], true
+	}
+	if len(r.customers) == 0 {
+		return fake.Customer{}, false
+	}
+
+	return r.customers[rand.Intn(len(r.customers))], true
+}
@@ -2,14 +2,14 @@ package shop
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
-	"github.com/brianvoe/gofakeit/v5"
+	"github.com/mroth/weightedrand"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/cloudhut/owl-shop/pkg/config"
@@ -21,19 +21,46 @@ import (
 // its topic every time a new user registers in the fake store. It produces to
 // a compacted topic and regularly produces an updated version of an existing
 // customer to simulate a change event. It also sends a tombstone for existing
-// customers to simulate a delete request by a customer.
+// customers to simulate a delete request by a customer. If the change stream is
+// enabled, all changes are produced to the append-only customer-changes topic
+// instead and an internal consumer applies them to the customers topic.
 type CustomerService struct {
 	cfg    config.Shop
 	logger *zap.Logger
+	clock  *simulationClock
 
 	kafkaFactory *kafka.Factory
+	metrics      *clientMetrics
+	tracer       trace.Tracer
 	metaClient   *kgo.Client
+	producer     recordProducer
+	serde        *TopicSerde
+	locales      *weightedrand.Chooser
+	payloads     *fake.PayloadCache
+	cdc          *cdcTable
+
+	// consumerClient consumes the customer changes, it is only set if the
+	// change stream is enabled.
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+
+	// loyaltyClient consumes the orders to update the loyalty attributes of
+	// the ordering customers, it is only set if the loyalty program is
+	// enabled.
+	loyaltyClient   *kgo.Client
+	loyaltyStopped  chan struct{}
+	loyaltyThrottle *consumerThrottle
+	orderSerde      *TopicSerde
+	loyalty         *loyaltyLedger
 
 	bufferSize        int
 	recentCustomersMu sync.RWMutex
 	recentCustomers   []fake.Customer
 
-	topicName string
+	topicName              string
+	topicNameChanges       string
+	topicNameLoyaltyPoints string
 }
 
 // NewCustomerService creates a new CustomerService.
@@ -41,12 +68,61 @@ func NewCustomerService(
 	cfg config.Shop,
 	logger *zap.Logger,
 	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
 ) (*CustomerService, error) {
-	clientID := cfg.GlobalPrefix + "customer-service"
-	metaClient, err := kafkaFactory.NewKafkaClient(clientID)
+	locales, err := newLocaleChooser(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID := cfg.Services.Customer.ClientIDFor(cfg.GlobalPrefix, "customer-service")
+	metrics := newClientMetrics("customer_service")
+	headers := newRecordHeaders(cfg.Headers, "customer-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "customer-service")
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kafka client: %w", err)
 	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var consumerClient *kgo.Client
+	if cfg.Customers.ChangeStream {
+		consumerClient, err = kafkaFactory.NewKafkaClient(
+			clientID,
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("customer-service")),
+			kgo.ConsumeTopics(cfg.TopicName("customer-changes")),
+			kgo.AutoCommitInterval(500*time.Millisecond),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create consumer client: %w", err)
+		}
+	}
+
+	var loyaltyClient *kgo.Client
+	if cfg.Customers.Loyalty.Enabled {
+		loyaltyClient, err = kafkaFactory.NewKafkaClient(
+			clientID,
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("customer-service-loyalty")),
+			kgo.ConsumeTopics(cfg.TopicName("orders")),
+			kgo.AutoCommitInterval(500*time.Millisecond),
+			readCommittedOrders(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create loyalty consumer client: %w", err)
+		}
+	}
 
 	// This slice is used to keep some customers in the buffer so that they can be modified or deleted
 	bufferSize := 500
@@ -55,28 +131,49 @@ func NewCustomerService(
 	return &CustomerService{
 		cfg:    cfg,
 		logger: logger.With(zap.String("service", "customer_service")),
+		clock:  clock,
 
 		kafkaFactory: kafkaFactory,
+		metrics:      metrics,
+		tracer:       headers.tracer,
 		metaClient:   metaClient,
+		producer:     producer,
+		serde:        serdes.Customers,
+		locales:      locales,
+		payloads:     newPayloadCache(cfg.PayloadCache),
+		cdc:          newCDCTable(cfg, logger, clock, metaClient, producer, "customers", fake.Customer{}),
+
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Customer.SlowConsumer),
+
+		loyaltyClient:   loyaltyClient,
+		loyaltyStopped:  make(chan struct{}),
+		loyaltyThrottle: newConsumerThrottle(cfg.Services.Customer.SlowConsumer),
+		orderSerde:      serdes.Orders,
+		loyalty:         newLoyaltyLedger(cfg.Customers.Loyalty, cfg.Customers.RegistrySize),
 
 		bufferSize:        bufferSize,
 		recentCustomersMu: sync.RWMutex{},
 		recentCustomers:   recentCustomers,
 
-		topicName: cfg.GlobalPrefix + "customers",
+		topicName:              cfg.TopicName("customers"),
+		topicNameChanges:       cfg.TopicName("customer-changes"),
+		topicNameLoyaltyPoints: cfg.TopicName("loyalty-points"),
 	}, nil
 }
 
-// Initialize creates the customer topic with cleanup policy compact.
+// Initialize creates the customer topic with cleanup policy compact and, if the
+// change stream is enabled, the customer changes topic with cleanup policy
+// delete.
 func (svc *CustomerService) Initialize(ctx context.Context) error {
 	svc.logger.Info("initializing customer service")
 
-	err := kafka.ReconcileTopic(
+	err := reconcileTopic(
 		ctx,
+		svc.cfg,
 		svc.metaClient,
 		svc.topicName,
-		svc.cfg.TopicPartitionCount,
-		svc.cfg.TopicReplicationFactor,
 		map[string]*string{
 			"cleanup.policy": kadm.StringPtr("compact"),
 		},
@@ -85,28 +182,93 @@ func (svc *CustomerService) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to reconcile topic: %w", err)
 	}
 
+	if svc.consumerClient != nil {
+		err = reconcileTopic(
+			ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameChanges,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile customer changes topic: %w", err)
+		}
+	}
+
+	if svc.loyaltyClient != nil {
+		err = reconcileTopic(
+			ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameLoyaltyPoints,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile loyalty points topic: %w", err)
+		}
+	}
+
+	if err := svc.cdc.Initialize(ctx); err != nil {
+		return err
+	}
+
 	svc.logger.Info("successfully initialized customer service")
 
 	return nil
 }
 
-// CreateCustomer creates a fake customer struct and then produces the JSON serialized
+// Close stops consuming the customer changes and the orders, flushes all
+// buffered records and closes the Kafka clients.
+func (svc *CustomerService) Close(ctx context.Context) error {
+	if svc.loyaltyClient != nil {
+		svc.loyaltyThrottle.stop()
+		select {
+		case <-svc.loyaltyStopped:
+		case <-ctx.Done():
+			svc.loyaltyClient.Close()
+			return fmt.Errorf("failed to wait for loyalty consumer to stop: %w", ctx.Err())
+		}
+		svc.loyaltyClient.Close()
+	}
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// CreateCustomer creates a fake customer struct and then produces the serialized
 // customer to the customer's topic.
 func (svc *CustomerService) CreateCustomer() {
-	customer := fake.NewCustomer()
+	if _, err := svc.RegisterCustomer(); err != nil {
+		svc.logger.Warn("failed to produce customer", zap.Error(err))
+	}
+}
+
+// RegisterCustomer creates and produces a fake customer like CreateCustomer,
+// e.g. to trigger a registration on demand, and returns the customer.
+func (svc *CustomerService) RegisterCustomer() (fake.Customer, error) {
+	customer := svc.payloads.NewCustomer(svc.locales.Pick().(string))
+	if svc.cfg.B2B.Enabled {
+		customer.MakeBusiness()
+	}
+	if svc.loyaltyClient != nil {
+		customer.Segment = fake.CustomerSegmentNew
+		customer.LoyaltyTier = fake.LoyaltyTierBronze
+	}
 	svc.recentCustomersMu.Lock()
 	if len(svc.recentCustomers) < svc.bufferSize {
 		svc.recentCustomers = append(svc.recentCustomers, customer)
 	}
 	svc.recentCustomersMu.Unlock()
 
-	err := svc.produceCustomer(customer)
+	err := svc.changeCustomer(withEventType(context.Background(), EventTypeCustomerCreated), customer, fake.CustomerChangeTypeCreated)
 	if err != nil {
-		svc.logger.Warn("failed to produce customer", zap.Error(err))
-		return
+		return fake.Customer{}, err
 	}
 	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeCustomerCreated}).Inc()
-	return
+	svc.cdc.emit(context.Background(), cdcOpCreate, customer.ID, nil, customer)
+	return customer, nil
 }
 
 // ModifyCustomer takes an existing customer from the cache, modifies the last name
@@ -118,16 +280,20 @@ func (svc *CustomerService) ModifyCustomer() {
 		return
 	}
 
-	customer.LastName = gofakeit.LastName()
+	customer = svc.loyalty.current(customer)
+	before := customer
+	customer.LastName = fake.NewLastName(customer.Locale)
 	customer.Revision++
+	svc.loyalty.track(customer)
 	svc.logger.Debug("modified customer")
 
-	err = svc.produceCustomer(customer)
+	err = svc.changeCustomer(withEventType(context.Background(), EventTypeCustomerModified), customer, fake.CustomerChangeTypeModified)
 	if err != nil {
 		svc.logger.Warn("failed to produce customer", zap.Error(err))
 		return
 	}
 	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeCustomerModified}).Inc()
+	svc.cdc.emit(context.Background(), cdcOpUpdate, customer.ID, before, customer)
 	return
 }
 
@@ -140,14 +306,37 @@ func (svc *CustomerService) DeleteCustomer() {
 		return
 	}
 
+	svc.loyalty.remove(customer.ID)
 	svc.logger.Debug("deleted customer")
 
-	svc.produceTombstone(customer.ID)
+	err = svc.changeCustomer(withEventType(context.Background(), EventTypeCustomerDeleted), customer, fake.CustomerChangeTypeDeleted)
 	if err != nil {
 		svc.logger.Warn("failed to produce customer tombstone", zap.Error(err))
 		return
 	}
 	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeCustomerDeleted}).Inc()
+	svc.cdc.emit(context.Background(), cdcOpDelete, customer.ID, customer, nil)
+}
+
+// DeleteCustomersPeriodically deletes an existing customer in each configured
+// tombstone interval until the given context is cancelled. It returns
+// immediately if the periodic deletions are disabled.
+func (svc *CustomerService) DeleteCustomersPeriodically(ctx context.Context) {
+	if svc.cfg.Customers.TombstoneInterval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(svc.cfg.Customers.TombstoneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			svc.DeleteCustomer()
+		}
+	}
 }
 
 func (svc *CustomerService) popCustomerFromBuffer() (fake.Customer, error) {
@@ -164,15 +353,15 @@ func (svc *CustomerService) popCustomerFromBuffer() (fake.Customer, error) {
 	return customer, nil
 }
 
-func (svc *CustomerService) produceTombstone(customerID string) {
+func (svc *CustomerService) produceTombstone(ctx context.Context, customerID string) {
 	rec := kgo.Record{
-		Key:       []byte(customerID),
+		Key:       recordKey(svc.cfg, svc.topicName, customerID, customerID),
 		Value:     nil,
-		Timestamp: time.Now(),
+		Timestamp: svc.clock.now(),
 		Topic:     svc.topicName,
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
 		if err != nil {
 			svc.logger.Error("failed to produce tombstone record",
 				zap.String("topic_name", rec.Topic),
@@ -183,21 +372,21 @@ func (svc *CustomerService) produceTombstone(customerID string) {
 	})
 }
 
-func (svc *CustomerService) produceCustomer(customer fake.Customer) error {
-	serialized, err := json.Marshal(customer)
+func (svc *CustomerService) produceCustomer(ctx context.Context, customer fake.Customer) error {
+	serialized, err := svc.serde.Encode(customer)
 	if err != nil {
 		return fmt.Errorf("failed to serialize customer struct: %w", err)
 	}
 
 	rec := kgo.Record{
-		Key:       []byte(customer.ID),
+		Key:       recordKey(svc.cfg, svc.topicName, customer.ID, customer.ID),
 		Value:     serialized,
 		Headers:   []kgo.RecordHeader{{Key: "revision", Value: []byte("0")}},
-		Timestamp: time.Now(),
+		Timestamp: svc.clock.now(),
 		Topic:     svc.topicName,
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
 		if err != nil {
 			svc.logger.Error("failed to produce record",
 				zap.String("topic_name", rec.Topic),
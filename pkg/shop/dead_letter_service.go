@@ -0,0 +1,328 @@
+package shop
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+const (
+	poisonTypeTruncatedPayload = "TRUNCATED_PAYLOAD"
+	poisonTypeUnknownSchemaID  = "UNKNOWN_SCHEMA_ID"
+	poisonTypeInvalidMagicByte = "INVALID_MAGIC_BYTE"
+)
+
+var poisonTypes = []string{poisonTypeTruncatedPayload, poisonTypeUnknownSchemaID, poisonTypeInvalidMagicByte}
+
+// poisonTarget is a topic into which poison messages can be injected.
+type poisonTarget struct {
+	serde *TopicSerde
+	// cluster is the name of the cluster of the service that owns the topic.
+	cluster string
+	// newValue returns a valid record value, from which the poison messages
+	// are derived.
+	newValue func() any
+	// newDecodeTarget returns a pointer to decode the topic's records into.
+	newDecodeTarget func() any
+}
+
+// poisonTargets returns all topics into which poison messages can be injected,
+// keyed by the topic name without the topic prefix. The keys must match
+// config.DeadLetterTopics. The valid record values are created at the time of
+// the given clock.
+func poisonTargets(services config.Services, serdes *Serdes, pricing fake.Pricing, clock *simulationClock) map[string]poisonTarget {
+	return map[string]poisonTarget{
+		"customers": {
+			serde:           serdes.Customers,
+			cluster:         services.Customer.Cluster,
+			newValue:        func() any { return fake.NewCustomer(fake.DefaultLocale) },
+			newDecodeTarget: func() any { return &fake.Customer{} },
+		},
+		"frontend-events": {
+			serde:   serdes.FrontendEvents,
+			cluster: services.Frontend.Cluster,
+			newValue: func() any {
+				session := fake.NewBouncedFrontendSession()
+				return session.NextEvent()
+			},
+			newDecodeTarget: func() any { return &fake.FrontendEvent{} },
+		},
+		"products": {
+			serde:           serdes.Products,
+			cluster:         services.ProductCatalog.Cluster,
+			newValue:        func() any { return fake.NewProduct(pricing, clock.now()) },
+			newDecodeTarget: func() any { return &fake.Product{} },
+		},
+	}
+}
+
+// DeadLetterService injects poison messages into the configured topic and
+// consumes the same topic, so that all records that can't be decoded are
+// routed to the dead letter queue topic. Dead letters keep the original key,
+// value and headers and carry the origin and the decoding error as additional
+// headers.
+type DeadLetterService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	target          poisonTarget
+
+	sourceTopicName string
+	topicName       string
+}
+
+// NewDeadLetterService creates a new DeadLetterService for the given poison
+// target.
+func NewDeadLetterService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	target poisonTarget,
+	tracing *tracing,
+	clock *simulationClock,
+) (*DeadLetterService, error) {
+	clientID := cfg.GlobalPrefix + "dead-letter-service"
+	metrics := newClientMetrics("dead_letter_service")
+	headers := newRecordHeaders(cfg.Headers, "dead-letter-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "dead-letter-service")
+	sourceTopicName := cfg.TopicName(cfg.DeadLetters.Topic)
+
+	hooks := []kgo.Hook{cloudEvents, metrics, headers}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		metrics.hook(),
+		cloudEvents.hook(),
+		kgo.ConsumerGroup(cfg.GroupID("dead-letter-service")),
+		kgo.ConsumeTopics(sourceTopicName),
+		kgo.AutoCommitInterval(500*time.Millisecond),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	return &DeadLetterService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "dead_letter_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		target:          target,
+
+		sourceTopicName: sourceTopicName,
+		topicName:       cfg.TopicName("dlq"),
+	}, nil
+}
+
+// Initialize dead letter service by reconciling the dead letter queue topic.
+func (svc *DeadLetterService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing dead letter service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized dead letter service")
+
+	return nil
+}
+
+// Close stops consuming the poisoned topic, flushes all buffered records and
+// closes the Kafka clients.
+func (svc *DeadLetterService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, nil, svc.producer, svc.metaClient)
+}
+
+// Start consuming messages from the poisoned topic and route all records that
+// can't be decoded to the dead letter queue.
+func (svc *DeadLetterService) Start() {
+	defer close(svc.consumerStopped)
+
+	for {
+		fetches := svc.consumerClient.PollFetches(context.Background())
+
+		if fetches.IsClientClosed() {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeDeadLetterSourceConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+
+			err := svc.target.serde.Decode(rec.Value, svc.target.newDecodeTarget())
+			if err == nil {
+				return
+			}
+
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			svc.produceDeadLetter(ctx, rec, err)
+			span.End()
+			kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeDeadLetterProduced}).Inc()
+		})
+	}
+}
+
+// InjectPoisonMessagesPeriodically produces a poison message into the
+// configured topic in each configured interval until the given context is
+// cancelled.
+func (svc *DeadLetterService) InjectPoisonMessagesPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(svc.cfg.DeadLetters.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			svc.InjectPoisonMessage()
+		}
+	}
+}
+
+// InjectPoisonMessage produces a single malformed record of a random poison
+// type into the configured topic.
+func (svc *DeadLetterService) InjectPoisonMessage() {
+	poisonType := poisonTypes[rand.Intn(len(poisonTypes))]
+
+	value, err := svc.newPoisonValue(poisonType)
+	if err != nil {
+		svc.logger.Warn("failed to create poison message", zap.Error(err))
+		return
+	}
+
+	rec := kgo.Record{
+		Key:       []byte(strconv.Itoa(rand.Int())),
+		Value:     value,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.sourceTopicName,
+	}
+
+	ctx := withEventType(context.Background(), EventTypePoisonMessageProduced)
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce poison message",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypePoisonMessageProduced}).Inc()
+}
+
+// newPoisonValue derives a malformed record value of the given poison type from
+// a valid record value. Schema registry headers are prepended to JSON values,
+// so that each poison type fails to decode regardless of the topic's format.
+func (svc *DeadLetterService) newPoisonValue(poisonType string) ([]byte, error) {
+	valid, err := svc.target.serde.Encode(svc.target.newValue())
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize valid record: %w", err)
+	}
+
+	// Payload without the schema registry header (magic byte and schema id)
+	payload := valid
+	if svc.target.serde.format != config.SerdeJSON && len(valid) >= 5 {
+		payload = valid[5:]
+	}
+
+	switch poisonType {
+	case poisonTypeTruncatedPayload:
+		return valid[:len(valid)/2], nil
+	case poisonTypeUnknownSchemaID:
+		header := make([]byte, 5)
+		binary.BigEndian.PutUint32(header[1:], uint32(math.MaxInt32-rand.Intn(1000)))
+		return append(header, payload...), nil
+	case poisonTypeInvalidMagicByte:
+		header := []byte{byte(1 + rand.Intn(255)), 0, 0, 0, 1}
+		return append(header, payload...), nil
+	default:
+		return nil, fmt.Errorf("unknown poison type '%v'", poisonType)
+	}
+}
+
+func (svc *DeadLetterService) produceDeadLetter(ctx context.Context, source *kgo.Record, decodeErr error) {
+	headers := make([]kgo.RecordHeader, 0, len(source.Headers)+4)
+	headers = append(headers, source.Headers...)
+	headers = append(headers,
+		kgo.RecordHeader{Key: "dlq.original.topic", Value: []byte(source.Topic)},
+		kgo.RecordHeader{Key: "dlq.original.partition", Value: []byte(strconv.Itoa(int(source.Partition)))},
+		kgo.RecordHeader{Key: "dlq.original.offset", Value: []byte(strconv.FormatInt(source.Offset, 10))},
+		kgo.RecordHeader{Key: "dlq.error", Value: []byte(decodeErr.Error())},
+	)
+
+	rec := kgo.Record{
+		Key:       source.Key,
+		Value:     source.Value,
+		Headers:   headers,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	// The dead letter keeps the original headers, including the trace context
+	svc.producer.Produce(withEventType(ctx, EventTypeDeadLetterProduced), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce dead letter",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+}
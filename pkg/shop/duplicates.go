@@ -0,0 +1,80 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// duplicateKey is the context key that marks a record as an injected
+// duplicate, so that duplicates are not duplicated again.
+type duplicateKey struct{}
+
+// duplicates re-sends a share of the acknowledged records of a service's
+// producing Kafka client. The duplicates are exact copies of the original
+// records, including the key, the headers and the timestamp, as if the
+// producer had retried the request after the acknowledgement got lost. It is
+// registered as a hook on the client and must be attached to it once the
+// client has been created.
+type duplicates struct {
+	cfg     config.Duplicates
+	metrics *clientMetrics
+	logger  *zap.Logger
+	client  *kgo.Client
+}
+
+var _ kgo.HookProduceRecordUnbuffered = (*duplicates)(nil)
+
+func newDuplicates(cfg config.Duplicates, metrics *clientMetrics, logger *zap.Logger) *duplicates {
+	return &duplicates{cfg: cfg, metrics: metrics, logger: logger}
+}
+
+// hook returns the client option that registers the duplicates hook.
+func (d *duplicates) hook() kgo.Opt {
+	return kgo.WithHooks(d)
+}
+
+// attach sets the client that re-sends the records. It must be called before
+// any record is produced.
+func (d *duplicates) attach(client *kgo.Client) {
+	d.client = client
+}
+
+// OnProduceRecordUnbuffered re-sends the acknowledged record with the
+// configured ratio. Duplicates are dropped rather than blocking the client if
+// its buffer is full.
+func (d *duplicates) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	if !d.cfg.Enabled || err != nil || d.client == nil || rand.Float64() >= d.cfg.Ratio {
+		return
+	}
+	if _, ok := r.Context.Value(duplicateKey{}).(bool); ok {
+		return
+	}
+
+	duplicate := &kgo.Record{
+		Key:       r.Key,
+		Value:     r.Value,
+		Headers:   append([]kgo.RecordHeader(nil), r.Headers...),
+		Timestamp: r.Timestamp,
+		Topic:     r.Topic,
+		Context:   context.WithValue(r.Context, duplicateKey{}, true),
+	}
+	d.client.TryProduce(duplicate.Context, duplicate, func(rec *kgo.Record, err error) {
+		if errors.Is(err, kgo.ErrMaxBuffered) {
+			return
+		}
+		if err != nil {
+			d.logger.Warn("failed to produce duplicate",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+		kafkaDuplicatesProducedTotal.With(d.metrics.labels(rec.Topic)).Inc()
+	})
+}
@@ -0,0 +1,369 @@
+package shop
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+const (
+	execGeneratorDefaultTimeout = 10 * time.Second
+	// execGeneratorMaxLineBytes is the max size of a single response.
+	execGeneratorMaxLineBytes = 16 * 1024 * 1024
+	// execGeneratorRestartBackoff is the min duration between two starts of
+	// the subprocess, so that a crashing subprocess isn't restarted in a
+	// tight loop.
+	execGeneratorRestartBackoff = time.Second
+)
+
+// execGenerator is an event generator that runs as a subprocess. Each call of
+// the generator is sent as a JSON request line to the subprocess' stdin, which
+// responds with a single JSON line on stdout:
+//
+//	{"type": "initialize", "name": "...", "settings": {...}}  responds with the topics to reconcile
+//	{"type": "generate"}                                      responds with the records to produce
+//	{"type": "stop"}                                          is sent before stdin is closed
+//
+// A response of {"error": "..."} fails the request. Requests are sent one at a
+// time, so the subprocess does not need to handle concurrent requests. Lines
+// that the subprocess writes to stderr are logged. If the subprocess exits or
+// is killed before the generator has been stopped, it is restarted on the
+// next request and initialized again.
+type execGenerator struct {
+	deps    GeneratorDeps
+	cfg     config.GeneratorExec
+	logger  *zap.Logger
+	timeout time.Duration
+	client  *kgo.Client
+
+	// mu serializes the requests and guards the fields below.
+	mu        sync.Mutex
+	proc      *execProcess
+	startedAt time.Time
+	// initialized is set once the initialize request has succeeded, so that
+	// restarted subprocesses are initialized again.
+	initialized bool
+	stopped     bool
+}
+
+// execProcess is a started subprocess of an execGenerator.
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+	// exited is closed once the subprocess has exited.
+	exited  chan struct{}
+	waitErr error
+}
+
+var _ EventGenerator = (*execGenerator)(nil)
+
+type execRequest struct {
+	Type     string                 `json:"type"`
+	Name     string                 `json:"name,omitempty"`
+	Settings map[string]interface{} `json:"settings,omitempty"`
+}
+
+type execResponse struct {
+	Topics  []execTopic  `json:"topics"`
+	Records []execRecord `json:"records"`
+	Error   string       `json:"error"`
+}
+
+// execTopic is a topic of the generator. Its name is prefixed with the shop's
+// topic prefix.
+type execTopic struct {
+	Name    string            `json:"name"`
+	Configs map[string]string `json:"configs"`
+}
+
+// execRecord is a record to produce. JSON values are produced as they are,
+// binary values can be passed base64 encoded instead. A null value produces a
+// tombstone. The topic name is prefixed with the shop's topic prefix.
+type execRecord struct {
+	Topic       string            `json:"topic"`
+	Key         string            `json:"key"`
+	Headers     map[string]string `json:"headers"`
+	Value       json.RawMessage   `json:"value"`
+	ValueBase64 []byte            `json:"valueBase64"`
+}
+
+// newExecGenerator starts the subprocess of the external generator. It is
+// created by newGenerators for all generators with an exec command.
+func newExecGenerator(deps GeneratorDeps, cfg config.GeneratorExec) (*execGenerator, error) {
+	client, err := deps.NewKafkaClient()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = execGeneratorDefaultTimeout
+	}
+	g := &execGenerator{
+		deps:    deps,
+		cfg:     cfg,
+		logger:  deps.Logger,
+		timeout: timeout,
+		client:  client,
+	}
+	if err := g.startProcess(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// startProcess starts a new subprocess. The caller must hold the lock, unless
+// the generator is being created.
+func (g *execGenerator) startProcess() error {
+	cmd := exec.Command(g.cfg.Command, g.cfg.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range g.cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command '%v': %w", g.cfg.Command, err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), execGeneratorMaxLineBytes)
+	proc := &execProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: scanner,
+		exited: make(chan struct{}),
+	}
+	go g.logStderr(stderr)
+	go func() {
+		proc.waitErr = cmd.Wait()
+		close(proc.exited)
+	}()
+
+	g.proc = proc
+	g.startedAt = time.Now()
+	return nil
+}
+
+// Initialize sends the initialize request and reconciles the topics of its
+// response.
+func (g *execGenerator) Initialize(ctx context.Context) error {
+	res, err := g.request(execRequest{Type: "initialize", Name: g.deps.Name, Settings: g.deps.Settings})
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range res.Topics {
+		configs := make(map[string]*string, len(topic.Configs))
+		for key, value := range topic.Configs {
+			value := value
+			configs[key] = &value
+		}
+		if _, err := g.deps.ReconcileTopic(ctx, g.client, topic.Name, configs); err != nil {
+			return fmt.Errorf("failed to reconcile topic '%v': %w", topic.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Start does nothing, as the subprocess is started by newExecGenerator.
+func (g *execGenerator) Start() {}
+
+// Generate sends a generate request and produces the records of its response.
+func (g *execGenerator) Generate() {
+	res, err := g.request(execRequest{Type: "generate"})
+	if err != nil {
+		g.logger.Warn("failed to generate events", zap.Error(err))
+		return
+	}
+
+	for _, r := range res.Records {
+		rec := &kgo.Record{
+			Topic:     g.deps.Config.TopicName(r.Topic),
+			Value:     r.ValueBase64,
+			Timestamp: g.deps.Now(),
+		}
+		if r.Key != "" {
+			rec.Key = []byte(r.Key)
+		}
+		if len(r.Value) > 0 && string(r.Value) != "null" {
+			rec.Value = r.Value
+		}
+		for key, value := range r.Headers {
+			rec.Headers = append(rec.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+		}
+
+		g.client.Produce(withEventType(context.Background(), g.deps.Name), rec, func(rec *kgo.Record, err error) {
+			if err != nil {
+				g.logger.Error("failed to produce record",
+					zap.String("topic_name", rec.Topic),
+					zap.Error(err),
+				)
+			}
+		})
+		kafkaMessagesProducedTotal.With(map[string]string{"event_type": g.deps.Name}).Inc()
+	}
+}
+
+// Stop sends the stop request, closes the subprocess' stdin and waits until
+// it has exited. The subprocess is killed if it does not exit before the
+// context is done. Afterwards all buffered records are flushed.
+func (g *execGenerator) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	g.stopped = true
+	proc := g.proc
+	g.mu.Unlock()
+
+	if _, err := g.request(execRequest{Type: "stop"}); err != nil {
+		g.logger.Warn("failed to send stop request", zap.Error(err))
+	}
+	_ = proc.stdin.Close()
+
+	select {
+	case <-proc.exited:
+	case <-ctx.Done():
+		_ = proc.cmd.Process.Kill()
+		<-proc.exited
+	}
+	if proc.waitErr != nil {
+		g.logger.Warn("generator subprocess exited with error", zap.Error(proc.waitErr))
+	}
+
+	return closeClients(ctx, nil, nil, nil, g.client, g.client)
+}
+
+// request sends the request and waits for its response. If the subprocess has
+// exited, it is restarted and initialized again first, unless the generator
+// is being stopped.
+func (g *execGenerator) request(req execRequest) (execResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-g.proc.exited:
+		if err := g.restart(); err != nil {
+			return execResponse{}, err
+		}
+	default:
+	}
+
+	res, err := g.roundTrip(req)
+	if err == nil && req.Type == "initialize" {
+		g.initialized = true
+	}
+	return res, err
+}
+
+// restart starts a new subprocess in place of the exited one and sends it the
+// initialize request, if the generator has been initialized before. The
+// caller must hold the lock.
+func (g *execGenerator) restart() error {
+	exitErr := fmt.Errorf("generator subprocess has exited: %v", g.proc.waitErr)
+	if g.stopped {
+		return exitErr
+	}
+	if wait := execGeneratorRestartBackoff - time.Since(g.startedAt); wait > 0 {
+		return fmt.Errorf("%w, restarting in %v", exitErr, wait)
+	}
+
+	g.logger.Warn("restarting generator subprocess", zap.Error(exitErr))
+	if err := g.startProcess(); err != nil {
+		return fmt.Errorf("failed to restart generator subprocess: %w", err)
+	}
+	if !g.initialized {
+		return nil
+	}
+	// The topics have been reconciled by the first initialization already
+	_, err := g.roundTrip(execRequest{Type: "initialize", Name: g.deps.Name, Settings: g.deps.Settings})
+	if err != nil {
+		return fmt.Errorf("failed to initialize restarted generator subprocess: %w", err)
+	}
+	return nil
+}
+
+// roundTrip sends the request to the current subprocess and waits for its
+// response. The subprocess is killed if it does not respond within the
+// timeout, as its responses would not match the requests anymore. The caller
+// must hold the lock.
+func (g *execGenerator) roundTrip(req execRequest) (execResponse, error) {
+	proc := g.proc
+	serialized, err := json.Marshal(req)
+	if err != nil {
+		return execResponse{}, fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	type result struct {
+		res execResponse
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if _, err := proc.stdin.Write(append(serialized, '\n')); err != nil {
+			done <- result{err: fmt.Errorf("failed to write request: %w", err)}
+			return
+		}
+		if !proc.stdout.Scan() {
+			err := proc.stdout.Err()
+			if err == nil {
+				err = io.EOF
+			}
+			done <- result{err: fmt.Errorf("failed to read response: %w", err)}
+			return
+		}
+		var res execResponse
+		if err := json.Unmarshal(proc.stdout.Bytes(), &res); err != nil {
+			done <- result{err: fmt.Errorf("failed to deserialize response: %w", err)}
+			return
+		}
+		done <- result{res: res}
+	}()
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return execResponse{}, r.err
+		}
+		if r.res.Error != "" {
+			return execResponse{}, fmt.Errorf("generator responded with error to %v request: %v", req.Type, r.res.Error)
+		}
+		return r.res, nil
+	case <-timer.C:
+		_ = proc.cmd.Process.Kill()
+		<-proc.exited
+		return execResponse{}, fmt.Errorf("generator did not respond to %v request within %v", req.Type, g.timeout)
+	}
+}
+
+func (g *execGenerator) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		g.logger.Info("generator subprocess output", zap.String("line", scanner.Text()))
+	}
+}
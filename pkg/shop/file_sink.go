@@ -0,0 +1,509 @@
+package shop
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hamba/avro/ocf"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	embedavro "github.com/cloudhut/owl-shop/pkg/shop/schemas/avro"
+	"github.com/cloudhut/owl-shop/pkg/storage"
+)
+
+// fileSinkPrefix is the prefix of all files that are written by the file
+// sink. Only files with this prefix are deleted on rotation.
+const fileSinkPrefix = "records-"
+
+// fileSink writes a sample of all records that have been acknowledged by Kafka
+// to local NDJSON or Avro files, which are rotated once they reach the max
+// size. Each file is created once its first record is written. Records are
+// buffered and written by a single background goroutine. If the buffer is
+// full, records are dropped rather than blocking the producing service. If an
+// upload URL is configured, each file is uploaded to the object storage once
+// it has been closed. Uploads are retried and run in another background
+// goroutine, so that slow uploads do not delay writing. Records of
+// transactions are only written once their transaction has been committed.
+type fileSink struct {
+	cfg    config.FileSink
+	logger *zap.Logger
+
+	// bucket is nil if files are not uploaded. Objects are named after their
+	// file within the upload prefix.
+	bucket       storage.Bucket
+	uploadPrefix string
+
+	records chan *kgo.Record
+	// quit is closed by shutdown, after which the buffered records are
+	// written and stopped is closed.
+	quit    chan struct{}
+	stopped chan struct{}
+
+	// uploads are the paths of the closed files that are waiting to be
+	// uploaded. uploadQueued is signaled once a path has been added.
+	uploadsMu    sync.Mutex
+	uploads      []string
+	uploadQueued chan struct{}
+	// uploadCtx is canceled if shutdown times out before all files have
+	// been uploaded. uploadsStopped is closed once all files that have been
+	// closed before stopped have been uploaded.
+	uploadCtx      context.Context
+	cancelUploads  context.CancelFunc
+	uploadsStopped chan struct{}
+}
+
+var _ kgo.HookProduceRecordUnbuffered = (*fileSink)(nil)
+
+// sampledRecord is a record in the Avro files, see sampled_record.avsc. The
+// NDJSON files contain the same events as the webhook requests.
+type sampledRecord struct {
+	Topic     string          `avro:"topic"`
+	Partition int32           `avro:"partition"`
+	Offset    int64           `avro:"offset"`
+	Timestamp time.Time       `avro:"timestamp"`
+	Key       []byte          `avro:"key"`
+	Headers   []sampledHeader `avro:"headers"`
+	Value     []byte          `avro:"value"`
+	Tombstone bool            `avro:"tombstone"`
+}
+
+type sampledHeader struct {
+	Key   string `avro:"key"`
+	Value string `avro:"value"`
+}
+
+// sampleFile is a single file of the file sink that records are written to.
+type sampleFile interface {
+	// path returns the path of the file in the directory.
+	path() string
+	write(r *kgo.Record) error
+	flush() error
+	// size returns the number of bytes that have been flushed to the file.
+	size() int
+	close() error
+}
+
+// newFileSink creates the file sink and, if it is enabled, starts writing
+// records in the background until shutdown is called.
+func newFileSink(cfg config.FileSink, logger *zap.Logger) (*fileSink, error) {
+	uploadCtx, cancelUploads := context.WithCancel(context.Background())
+	s := &fileSink{
+		cfg:     cfg,
+		logger:  logger,
+		records: make(chan *kgo.Record, cfg.BufferSize),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+
+		uploadQueued:   make(chan struct{}, 1),
+		uploadCtx:      uploadCtx,
+		cancelUploads:  cancelUploads,
+		uploadsStopped: make(chan struct{}),
+	}
+	if !cfg.Enabled {
+		close(s.stopped)
+		close(s.uploadsStopped)
+		return s, nil
+	}
+
+	if err := os.MkdirAll(cfg.Directory, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create file sink directory: %w", err)
+	}
+	if cfg.UploadURL != "" {
+		bucket, prefix, err := storage.Open(cfg.Storage, cfg.UploadURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file sink upload url: %w", err)
+		}
+		s.bucket, s.uploadPrefix = bucket, prefix
+		go s.runUploads()
+	} else {
+		close(s.uploadsStopped)
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+// OnProduceRecordUnbuffered buffers the record with the configured sample
+// ratio if it has been produced successfully. It is called once the record
+// has been acknowledged, so the record carries its partition and offset.
+func (s *fileSink) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	if !s.cfg.Enabled || err != nil || rand.Float64() >= s.cfg.SampleRatio {
+		return
+	}
+	if deferUntilCommitted(r, func() { s.buffer(r) }) {
+		return
+	}
+	s.buffer(r)
+}
+
+// buffer adds the record to the buffer, unless it is full.
+func (s *fileSink) buffer(r *kgo.Record) {
+	select {
+	case s.records <- r:
+	default:
+		fileSinkRecordsTotal.With(map[string]string{"result": "dropped"}).Inc()
+	}
+}
+
+// shutdown writes the buffered records, closes the current file and waits for
+// the pending uploads. Records that are acknowledged afterwards are not
+// written anymore. Uploads are aborted once the context is done.
+func (s *fileSink) shutdown(ctx context.Context) error {
+	if s.cfg.Enabled {
+		close(s.quit)
+	}
+	select {
+	case <-s.stopped:
+	case <-ctx.Done():
+		s.cancelUploads()
+		return fmt.Errorf("failed to wait for file sink to write buffered records: %w", ctx.Err())
+	}
+	select {
+	case <-s.uploadsStopped:
+	case <-ctx.Done():
+		s.cancelUploads()
+		return fmt.Errorf("failed to wait for file sink to upload files: %w", ctx.Err())
+	}
+
+	return nil
+}
+
+func (s *fileSink) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var file sampleFile
+	write := func(r *kgo.Record) {
+		if file == nil {
+			created, err := s.create()
+			if err != nil {
+				s.logger.Warn("failed to create file", zap.Error(err))
+				fileSinkRecordsTotal.With(map[string]string{"result": "failed"}).Inc()
+				return
+			}
+			file = created
+		}
+		if err := file.write(r); err != nil {
+			s.logger.Warn("failed to write record to file", zap.Error(err))
+			fileSinkRecordsTotal.With(map[string]string{"result": "failed"}).Inc()
+			return
+		}
+		fileSinkRecordsTotal.With(map[string]string{"result": "written"}).Inc()
+	}
+	flush := func() {
+		if file == nil {
+			return
+		}
+		if err := file.flush(); err != nil {
+			s.logger.Warn("failed to flush file", zap.Error(err))
+		}
+		if file.size() < s.cfg.MaxFileBytes {
+			return
+		}
+		s.close(file)
+		file = nil
+	}
+
+	for {
+		select {
+		case r := <-s.records:
+			write(r)
+			if file != nil && file.size() >= s.cfg.MaxFileBytes {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.quit:
+			for {
+				select {
+				case r := <-s.records:
+					write(r)
+				default:
+					if file != nil {
+						s.close(file)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// close closes the given file and queues its upload, if an upload URL is
+// configured.
+func (s *fileSink) close(file sampleFile) {
+	if err := file.close(); err != nil {
+		s.logger.Warn("failed to close file", zap.Error(err))
+		return
+	}
+	if s.bucket == nil {
+		return
+	}
+
+	s.uploadsMu.Lock()
+	s.uploads = append(s.uploads, file.path())
+	s.uploadsMu.Unlock()
+	select {
+	case s.uploadQueued <- struct{}{}:
+	default:
+	}
+}
+
+// runUploads uploads the queued files until the writer has stopped and all
+// of its files have been uploaded.
+func (s *fileSink) runUploads() {
+	defer close(s.uploadsStopped)
+
+	for {
+		if filePath, ok := s.nextUpload(); ok {
+			s.uploadWithRetries(filePath)
+			continue
+		}
+		select {
+		case <-s.uploadQueued:
+		case <-s.stopped:
+			// The writer has queued its last file before it stopped
+			for filePath, ok := s.nextUpload(); ok; filePath, ok = s.nextUpload() {
+				s.uploadWithRetries(filePath)
+			}
+			return
+		}
+	}
+}
+
+// nextUpload removes the oldest queued file and returns its path.
+func (s *fileSink) nextUpload() (string, bool) {
+	s.uploadsMu.Lock()
+	defer s.uploadsMu.Unlock()
+
+	if len(s.uploads) == 0 {
+		return "", false
+	}
+	filePath := s.uploads[0]
+	s.uploads = s.uploads[1:]
+	return filePath, true
+}
+
+// uploadWithRetries uploads the file and retries it up to the configured
+// max retries. Uploaded files are deleted from the directory, files that
+// failed to be uploaded are kept.
+func (s *fileSink) uploadWithRetries(filePath string) {
+	backoff := s.cfg.UploadRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.upload(filePath)
+		if err == nil {
+			break
+		}
+		// Files that have been rotated meanwhile are not retried
+		if errors.Is(err, os.ErrNotExist) || attempt >= s.cfg.UploadMaxRetries || s.uploadCtx.Err() != nil {
+			s.logger.Warn("failed to upload file",
+				zap.String("file_name", filePath),
+				zap.Int("attempts", attempt+1),
+				zap.Error(err))
+			fileSinkUploadsTotal.With(map[string]string{"result": "failed"}).Inc()
+			return
+		}
+		s.logger.Debug("retrying upload of file", zap.String("file_name", filePath), zap.Duration("backoff", backoff), zap.Error(err))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-s.uploadCtx.Done():
+			timer.Stop()
+		}
+		backoff *= 2
+	}
+
+	fileSinkUploadsTotal.With(map[string]string{"result": "uploaded"}).Inc()
+	if err := os.Remove(filePath); err != nil {
+		s.logger.Warn("failed to delete uploaded file", zap.Error(err))
+	}
+}
+
+func (s *fileSink) upload(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	name := path.Join(s.uploadPrefix, filepath.Base(filePath))
+	if err := s.bucket.Put(s.uploadCtx, name, f, info.Size()); err != nil {
+		return err
+	}
+	s.logger.Debug("uploaded file", zap.String("object_name", name))
+
+	return nil
+}
+
+// deleteOldest deletes the oldest files beyond the max number of files.
+func (s *fileSink) deleteOldest() error {
+	if s.cfg.MaxFiles == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.cfg.Directory)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), fileSinkPrefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	// File names start with their creation time, so they sort by age. The
+	// current file is kept in addition to the max number of files
+	sort.Strings(names)
+	for len(names) > s.cfg.MaxFiles+1 {
+		if err := os.Remove(filepath.Join(s.cfg.Directory, names[0])); err != nil {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		names = names[1:]
+	}
+
+	return nil
+}
+
+// create creates the next file, which is named after its creation time, and
+// rotates the previous files.
+func (s *fileSink) create() (sampleFile, error) {
+	name := fileSinkPrefix + time.Now().UTC().Format("20060102T150405.000000000") + "." + s.cfg.Format
+	f, err := os.Create(filepath.Join(s.cfg.Directory, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	s.logger.Debug("created file", zap.String("file_name", name))
+	if err := s.deleteOldest(); err != nil {
+		s.logger.Warn("failed to rotate files", zap.Error(err))
+	}
+
+	counter := &countingWriter{w: f}
+	if s.cfg.Format == "avro" {
+		encoder, err := ocf.NewEncoder(embedavro.SampledRecordAvro, counter)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to create avro encoder: %w", err)
+		}
+		return &avroSampleFile{file: f, counter: counter, encoder: encoder}, nil
+	}
+	return &ndjsonSampleFile{file: f, counter: counter, writer: bufio.NewWriter(counter)}, nil
+}
+
+// countingWriter counts the bytes that are written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// ndjsonSampleFile writes one webhook event per line.
+type ndjsonSampleFile struct {
+	file    *os.File
+	counter *countingWriter
+	writer  *bufio.Writer
+}
+
+func (f *ndjsonSampleFile) path() string {
+	return f.file.Name()
+}
+
+func (f *ndjsonSampleFile) write(r *kgo.Record) error {
+	line, err := json.Marshal(newWebhookEvent(r))
+	if err != nil {
+		return fmt.Errorf("failed to serialize record: %w", err)
+	}
+	if _, err := f.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+func (f *ndjsonSampleFile) flush() error {
+	return f.writer.Flush()
+}
+
+func (f *ndjsonSampleFile) size() int {
+	return f.counter.n
+}
+
+func (f *ndjsonSampleFile) close() error {
+	if err := f.writer.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// avroSampleFile writes an Avro object container file of sampled records.
+// Records are written in blocks, each flush starts a new block.
+type avroSampleFile struct {
+	file    *os.File
+	counter *countingWriter
+	encoder *ocf.Encoder
+}
+
+func (f *avroSampleFile) path() string {
+	return f.file.Name()
+}
+
+func (f *avroSampleFile) write(r *kgo.Record) error {
+	record := sampledRecord{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: r.Timestamp,
+		Key:       r.Key,
+		Headers:   make([]sampledHeader, 0, len(r.Headers)),
+		Value:     r.Value,
+		Tombstone: r.Value == nil,
+	}
+	for _, header := range r.Headers {
+		record.Headers = append(record.Headers, sampledHeader{Key: header.Key, Value: string(header.Value)})
+	}
+	if err := f.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	return nil
+}
+
+func (f *avroSampleFile) flush() error {
+	return f.encoder.Flush()
+}
+
+func (f *avroSampleFile) size() int {
+	return f.counter.n
+}
+
+func (f *avroSampleFile) close() error {
+	if err := f.encoder.Close(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,108 @@
+package shop
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// followUps buffers the follow-up events of a service until they are due by
+// the simulation clock, e.g. the replies of support tickets. The number of
+// pending follow-ups is limited, once it is exceeded new follow-ups are
+// dropped and counted by their kind.
+type followUps[T any] struct {
+	clock   *simulationClock
+	logger  *zap.Logger
+	kind    string
+	maxSize int
+
+	mu      sync.Mutex
+	pending []followUp[T]
+}
+
+// followUp is a single pending follow-up.
+type followUp[T any] struct {
+	value T
+	dueAt time.Time
+	// ctx carries the trace of the record that caused the follow-up, which
+	// all of its events continue.
+	ctx context.Context
+}
+
+func newFollowUps[T any](clock *simulationClock, logger *zap.Logger, kind string, maxSize int) *followUps[T] {
+	return &followUps[T]{
+		clock:   clock,
+		logger:  logger,
+		kind:    kind,
+		maxSize: maxSize,
+		pending: make([]followUp[T], 0),
+	}
+}
+
+// schedule buffers the given follow-up until it is due. If the buffer is full,
+// the follow-up is dropped.
+func (f *followUps[T]) schedule(ctx context.Context, value T, dueAt time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.pending) >= f.maxSize {
+		followUpsDroppedTotal.With(map[string]string{"kind": f.kind}).Inc()
+		f.logger.Debug("dropped follow-up, because the buffer is full", zap.String("kind", f.kind))
+		return
+	}
+	f.pending = append(f.pending, followUp[T]{value: value, dueAt: dueAt, ctx: ctx})
+}
+
+// remove removes the first pending follow-up whose value matches and returns
+// its value. Follow-ups that are being handled are not pending anymore.
+func (f *followUps[T]) remove(match func(T) bool) (T, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, pending := range f.pending {
+		if match(pending.value) {
+			f.pending = append(f.pending[:i], f.pending[i+1:]...)
+			return pending.value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// run regularly calls handle for all follow-ups that are due and removes them
+// from the buffer. Follow-ups that have another step must be scheduled again
+// by handle. It returns once the quit channel has been closed.
+func (f *followUps[T]) run(quit <-chan struct{}, handle func(ctx context.Context, value T, now time.Time)) {
+	ticker := time.NewTicker(f.clock.realDuration(time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+		now := f.clock.now()
+
+		// The due follow-ups are handled without holding the lock, so that
+		// handle and the consumer can schedule follow-ups meanwhile
+		var due []followUp[T]
+		f.mu.Lock()
+		remaining := f.pending[:0]
+		for _, pending := range f.pending {
+			if pending.dueAt.After(now) {
+				remaining = append(remaining, pending)
+				continue
+			}
+			due = append(due, pending)
+		}
+		f.pending = remaining
+		f.mu.Unlock()
+
+		for _, pending := range due {
+			handle(pending.ctx, pending.value, now)
+		}
+	}
+}
@@ -2,12 +2,14 @@ package shop
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/cloudhut/owl-shop/pkg/config"
@@ -17,48 +19,101 @@ import (
 
 // FrontendService simulates a service that produces a Kafka message every
 // time someone makes a request to the fake shop. Therefore, it is a
-// high throughput topic relative to the other topics.
+// high throughput topic relative to the other topics. Requests belong to user
+// sessions: a user arrives at a landing page, browses several pages over
+// seconds to minutes and then either converts or leaves the shop. All requests
+// of a session are keyed by the session id, so that they can be sessionized
+// by stream processors.
 type FrontendService struct {
 	cfg    config.Shop
 	logger *zap.Logger
+	clock  *simulationClock
 
 	kafkaFactory *kafka.Factory
+	metrics      *clientMetrics
+	tracer       trace.Tracer
 	metaClient   *kgo.Client
+	producer     recordProducer
+	serde        *TopicSerde
+	// search produces the search queries of the sessions, it is only set if
+	// search queries are enabled.
+	search *SearchService
+
+	activeSessionsMu sync.Mutex
+	activeSessions   []activeSession
 
 	topicName string
 }
 
+// activeSession is a session whose user has not yet left the shop.
+type activeSession struct {
+	session fake.FrontendSession
+	// dueAt is the time at which the user views the next page.
+	dueAt time.Time
+	// ctx carries the trace of the session, which all of its page views
+	// belong to. Its span is ended once the user has left the shop.
+	ctx  context.Context
+	span trace.Span
+}
+
 // NewFrontendService creates a new FrontendService.
 func NewFrontendService(
 	cfg config.Shop,
 	logger *zap.Logger,
 	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	productCatalog *ProductCatalogService,
+	tracing *tracing,
+	clock *simulationClock,
 ) (*FrontendService, error) {
-	clientID := cfg.GlobalPrefix + "frontend-service"
-	metaClient, err := kafkaFactory.NewKafkaClient(clientID)
+	clientID := cfg.Services.Frontend.ClientIDFor(cfg.GlobalPrefix, "frontend-service")
+	metrics := newClientMetrics("frontend_service")
+	headers := newRecordHeaders(cfg.Headers, "frontend-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "frontend-service")
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
 	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var search *SearchService
+	if cfg.Search.Enabled {
+		search = NewSearchService(cfg, logger, metaClient, producer, productCatalog, clock)
+	}
 
 	return &FrontendService{
 		cfg:    cfg,
 		logger: logger.With(zap.String("service", "frontend_service")),
+		clock:  clock,
 
 		kafkaFactory: kafkaFactory,
+		metrics:      metrics,
+		tracer:       headers.tracer,
 		metaClient:   metaClient,
+		producer:     producer,
+		serde:        serdes.FrontendEvents,
+		search:       search,
+
+		activeSessionsMu: sync.Mutex{},
+		activeSessions:   make([]activeSession, 0),
 
-		topicName: cfg.GlobalPrefix + "frontend-events",
+		topicName: cfg.TopicName("frontend-events"),
 	}, nil
 }
 
 func (svc *FrontendService) Initialize(ctx context.Context) error {
 	svc.logger.Info("initializing frontend service")
-	err := kafka.ReconcileTopic(
+	err := reconcileTopic(
 		ctx,
+		svc.cfg,
 		svc.metaClient,
 		svc.topicName,
-		svc.cfg.TopicPartitionCount,
-		svc.cfg.TopicReplicationFactor,
 		map[string]*string{
 			"cleanup.policy":  kadm.StringPtr("delete"),
 			"retention.bytes": kadm.StringPtr("3221225472"), // 3GiB
@@ -68,36 +123,158 @@ func (svc *FrontendService) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to reconcile topic: %w", err)
 	}
 
+	if svc.search != nil {
+		if err := svc.search.Initialize(ctx); err != nil {
+			return err
+		}
+	}
+
 	svc.logger.Info("successfully initialized frontend service")
 
 	return nil
 }
 
+// Close ends the traces of the active sessions, flushes all buffered records
+// and closes the Kafka client.
+func (svc *FrontendService) Close(ctx context.Context) error {
+	svc.activeSessionsMu.Lock()
+	for _, active := range svc.activeSessions {
+		active.span.End()
+	}
+	svc.activeSessionsMu.Unlock()
+
+	return closeClients(ctx, nil, nil, nil, svc.producer, svc.metaClient)
+}
+
+// CreateFrontendEvent lets a new user arrive at the shop. The landing page is
+// produced right away, the following pages of the session are produced by
+// AdvanceSessions. If the maximum number of active sessions has been reached,
+// the user bounces.
 func (svc *FrontendService) CreateFrontendEvent() {
-	event := fake.NewFrontendEvent()
-	err := svc.produceFrontendEvent(event)
+	cfg := svc.cfg.Sessions
+
+	svc.activeSessionsMu.Lock()
+	full := len(svc.activeSessions) >= cfg.MaxActive
+	svc.activeSessionsMu.Unlock()
+
+	var session fake.FrontendSession
+	if full {
+		session = fake.NewBouncedFrontendSession()
+	} else {
+		session = fake.NewFrontendSession(cfg.MaxPages, cfg.BounceRatio, cfg.ConversionRatio)
+	}
+	ctx, span := startTrace(context.Background(), svc.tracer, "frontend session")
+	svc.viewNextPage(ctx, &session)
+	if session.Ended() {
+		span.End()
+		return
+	}
+
+	svc.activeSessionsMu.Lock()
+	svc.activeSessions = append(svc.activeSessions, activeSession{
+		session: session,
+		dueAt:   svc.clock.now().Add(svc.nextPageDelay()),
+		ctx:     ctx,
+		span:    span,
+	})
+	svc.activeSessionsMu.Unlock()
+}
+
+// AdvanceSessions regularly produces the next page views of all active
+// sessions that are due until the given context is cancelled. Sessions whose
+// user has left the shop are removed.
+func (svc *FrontendService) AdvanceSessions(ctx context.Context) {
+	ticker := time.NewTicker(svc.clock.realDuration(time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			svc.advanceDueSessions()
+		}
+	}
+}
+
+// advanceDueSessions produces the next page view of each active session that
+// is due. Due times are compared against the simulation clock, so that
+// sessions also advance during the historical backfill.
+func (svc *FrontendService) advanceDueSessions() {
+	now := svc.clock.now()
+
+	// The page views are produced without holding the lock, so that new
+	// sessions can arrive meanwhile
+	var due []activeSession
+	svc.activeSessionsMu.Lock()
+	remaining := svc.activeSessions[:0]
+	for _, active := range svc.activeSessions {
+		if active.dueAt.After(now) {
+			remaining = append(remaining, active)
+			continue
+		}
+		due = append(due, active)
+	}
+	svc.activeSessions = remaining
+	svc.activeSessionsMu.Unlock()
+
+	advanced := due[:0]
+	for _, active := range due {
+		svc.viewNextPage(active.ctx, &active.session)
+		if active.session.Ended() {
+			active.span.End()
+			continue
+		}
+		active.dueAt = now.Add(svc.nextPageDelay())
+		advanced = append(advanced, active)
+	}
+
+	svc.activeSessionsMu.Lock()
+	svc.activeSessions = append(svc.activeSessions, advanced...)
+	svc.activeSessionsMu.Unlock()
+}
+
+// nextPageDelay returns a random duration between the configured min and max page delay.
+func (svc *FrontendService) nextPageDelay() time.Duration {
+	spread := svc.cfg.Sessions.MaxPageDelay - svc.cfg.Sessions.MinPageDelay
+	if spread <= 0 {
+		return svc.cfg.Sessions.MinPageDelay
+	}
+	return svc.cfg.Sessions.MinPageDelay + time.Duration(rand.Int63n(int64(spread)))
+}
+
+// viewNextPage produces the next page view of the session and, if it is a
+// search, its search query. Both continue the trace of the session in the
+// given context.
+func (svc *FrontendService) viewNextPage(ctx context.Context, session *fake.FrontendSession) {
+	event := session.NextEvent()
+	err := svc.produceFrontendEvent(withEventType(ctx, EventTypeFrontendEventCreated), event)
 	if err != nil {
-		svc.logger.Warn("failed to produce address", zap.Error(err))
+		svc.logger.Warn("failed to produce frontend event", zap.Error(err))
 		return
 	}
 	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeFrontendEventCreated}).Inc()
+
+	if svc.search != nil {
+		svc.search.Search(ctx, session, event)
+	}
 }
 
-func (svc *FrontendService) produceFrontendEvent(event fake.FrontendEvent) error {
-	serialized, err := json.Marshal(event)
+func (svc *FrontendService) produceFrontendEvent(ctx context.Context, event fake.FrontendEvent) error {
+	serialized, err := svc.serde.Encode(event)
 	if err != nil {
 		return fmt.Errorf("failed to serialize event struct: %w", err)
 	}
 
 	rec := kgo.Record{
-		Key:       nil,
+		Key:       recordKey(svc.cfg, svc.topicName, event.SessionID, ""),
 		Value:     serialized,
 		Headers:   nil,
-		Timestamp: time.Now(),
+		Timestamp: svc.clock.now(),
 		Topic:     svc.topicName,
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
 		if err != nil {
 			svc.logger.Error("failed to produce record",
 				zap.String("topic_name", rec.Topic),
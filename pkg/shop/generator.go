@@ -0,0 +1,214 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// EventGenerator is a custom component of the shop that produces its own
+// events, e.g. a loyalty points service. Generators are registered by name
+// via RegisterGenerator, usually in the init func of a plugin package that is
+// imported by the main package, and are created for each shop whose config
+// contains a generator of the same name below shop.generators.
+type EventGenerator interface {
+	// Initialize prepares the generator before any traffic is simulated, e.g.
+	// by creating its topics. It is retried like the initialization of the
+	// built-in services, so it must be idempotent.
+	Initialize(ctx context.Context) error
+
+	// Start starts the generator's background tasks, such as consumers, once
+	// all components have been initialized. It is called in its own goroutine
+	// and may block until Stop is called.
+	Start()
+
+	// Stop flushes all buffered records and closes the generator's clients.
+	Stop(ctx context.Context) error
+
+	// Generate produces a single event. It is triggered by simulated page
+	// impressions in accordance with the generator's weight and may be called
+	// concurrently.
+	Generate()
+}
+
+// GeneratorFactory creates an event generator of a shop.
+type GeneratorFactory func(deps GeneratorDeps) (EventGenerator, error)
+
+// GeneratorDeps are the dependencies of an event generator, which are shared
+// with the built-in services of the shop.
+type GeneratorDeps struct {
+	// Name of the generator, as it has been registered.
+	Name string
+
+	// Config is the config of the shop and Settings the generator's own
+	// settings of its generator config.
+	Config   config.Shop
+	Settings map[string]interface{}
+
+	Logger *zap.Logger
+
+	// KafkaFactory creates clients for the generator's cluster, using its
+	// producer overrides.
+	KafkaFactory *kafka.Factory
+
+	tracing *tracing
+	clock   *simulationClock
+}
+
+var (
+	generatorFactoriesMu sync.RWMutex
+	generatorFactories   = make(map[string]GeneratorFactory)
+)
+
+// RegisterGenerator registers the factory of an event generator under the
+// given name, which is the key of its config below shop.generators and the
+// name of its event weight. It panics if the name has been registered
+// already, like database/sql.Register.
+func RegisterGenerator(name string, factory GeneratorFactory) {
+	generatorFactoriesMu.Lock()
+	defer generatorFactoriesMu.Unlock()
+
+	if factory == nil {
+		panic("shop: generator factory of " + name + " is nil")
+	}
+	if _, ok := generatorFactories[name]; ok {
+		panic("shop: generator " + name + " has been registered already")
+	}
+	generatorFactories[name] = factory
+}
+
+// RegisteredGenerators returns the sorted names of all registered generators.
+func RegisteredGenerators() []string {
+	generatorFactoriesMu.RLock()
+	defer generatorFactoriesMu.RUnlock()
+
+	names := make([]string, 0, len(generatorFactories))
+	for name := range generatorFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Now returns the current time of the simulation, which should be used for
+// the timestamps of the generator's records.
+func (d GeneratorDeps) Now() time.Time {
+	return d.clock.now()
+}
+
+// NewKafkaClient creates a Kafka client with the hooks of the built-in
+// services, so that the generator's records are counted by the client metrics
+// and carry the configured headers and CloudEvents attributes.
+func (d GeneratorDeps) NewKafkaClient(opts ...kgo.Opt) (*kgo.Client, error) {
+	service := d.Name + "-generator"
+	metrics := newClientMetrics(strings.ReplaceAll(service, "-", "_"))
+	headers := newRecordHeaders(d.Config.Headers, service, d.tracing)
+	cloudEvents := newCloudEvents(d.Config.CloudEvents, service)
+	opts = append([]kgo.Opt{cloudEvents.hook(), metrics.hook(), headers.hook()}, opts...)
+
+	client, err := d.KafkaFactory.NewKafkaClient(d.Config.GlobalPrefix+service, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	return client, nil
+}
+
+// ReconcileTopic creates the topic with the shop's topic config or, if it
+// exists already, alters its configs. The name is prefixed with the shop's
+// topic prefix.
+func (d GeneratorDeps) ReconcileTopic(ctx context.Context, client *kgo.Client, name string, configs map[string]*string) (string, error) {
+	topicName := d.Config.TopicName(name)
+	if err := reconcileTopic(ctx, d.Config, client, topicName, configs); err != nil {
+		return "", err
+	}
+
+	return topicName, nil
+}
+
+// namedGenerator is an event generator that has been created for a shop.
+type namedGenerator struct {
+	name      string
+	weight    uint
+	generator EventGenerator
+}
+
+// newGenerators creates the configured event generators in the order of
+// their names. Generators with an exec command are run as a subprocess and
+// the given factories take precedence over the registered ones. The given
+// Kafka factory func returns the factory of a generator's cluster and producer
+// overrides.
+func newGenerators(
+	cfg config.Shop,
+	logger *zap.Logger,
+	factories map[string]GeneratorFactory,
+	kafkaFactory func(config.Service) *kafka.Factory,
+	tracing *tracing,
+	clock *simulationClock,
+) ([]namedGenerator, error) {
+	names := make([]string, 0, len(cfg.Generators))
+	for name := range cfg.Generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	generatorFactoriesMu.RLock()
+	defer generatorFactoriesMu.RUnlock()
+
+	generators := make([]namedGenerator, 0, len(names))
+	for _, name := range names {
+		generatorCfg := cfg.Generators[name]
+		// External generators run as a subprocess rather than being
+		// registered
+		factory, ok := factories[name]
+		if !ok {
+			factory, ok = generatorFactories[name]
+		}
+		if generatorCfg.Exec.Command != "" {
+			factory = func(deps GeneratorDeps) (EventGenerator, error) {
+				return newExecGenerator(deps, generatorCfg.Exec)
+			}
+		} else if !ok {
+			return nil, fmt.Errorf("generator '%v' is configured, but has neither been registered nor has an exec command", name)
+		}
+
+		generator, err := factory(GeneratorDeps{
+			Name:         name,
+			Config:       cfg,
+			Settings:     generatorCfg.Settings,
+			Logger:       logger.Named(name + "_generator"),
+			KafkaFactory: kafkaFactory(config.Service{Cluster: generatorCfg.Cluster, Producer: generatorCfg.Producer}),
+			tracing:      tracing,
+			clock:        clock,
+		})
+		if err != nil {
+			stopGenerators(generators, logger)
+			return nil, fmt.Errorf("failed to create %v generator: %w", name, err)
+		}
+		generators = append(generators, namedGenerator{name: name, weight: generatorCfg.Weight, generator: generator})
+	}
+
+	return generators, nil
+}
+
+// stopGenerators stops the given generators, e.g. if creating another one has
+// failed, so that neither their clients nor their subprocesses leak.
+func stopGenerators(generators []namedGenerator, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), execGeneratorDefaultTimeout)
+	defer cancel()
+	for _, g := range generators {
+		if err := g.generator.Stop(ctx); err != nil {
+			logger.Warn("failed to stop generator", zap.String("generator", g.name), zap.Error(err))
+		}
+	}
+}
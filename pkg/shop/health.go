@@ -0,0 +1,197 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// healthPingTimeout is the max duration of a Kafka connectivity check.
+const healthPingTimeout = 5 * time.Second
+
+// healthChecker serves the liveness and readiness probes. The process is live
+// as long as all Kafka clusters are reachable and no component has failed. It
+// is ready once the components of all shops have been initialized (topics
+// created, schemas registered) and until it is stopped.
+type healthChecker struct {
+	logger *zap.Logger
+
+	// clients are used to check the connectivity of each Kafka cluster,
+	// keyed by the cluster name.
+	clients map[string]*kgo.Client
+
+	mu          sync.RWMutex
+	initialized map[string]bool
+	// failures are the errors of the components that have stopped working
+	// before the shop has been stopped, keyed by component.
+	failures map[string]string
+	stopping bool
+}
+
+// healthStatus is the response body of the probes.
+type healthStatus struct {
+	Status     string            `json:"status"`
+	Kafka      map[string]string `json:"kafka"`
+	Components map[string]bool   `json:"components,omitempty"`
+	Failures   map[string]string `json:"failures,omitempty"`
+	Stopping   bool              `json:"stopping,omitempty"`
+}
+
+// newHealthChecker creates a health checker for the given Kafka clusters. The
+// components that must be initialized, before the shops are ready, are added by
+// each shop.
+func newHealthChecker(
+	clientID string,
+	logger *zap.Logger,
+	kafkaFactories map[string]*kafka.Factory,
+) (*healthChecker, error) {
+	clients := make(map[string]*kgo.Client, len(kafkaFactories))
+	for name, factory := range kafkaFactories {
+		client, err := factory.NewKafkaClient(clientID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create health check client: %w", err)
+		}
+		clients[name] = client
+	}
+
+	return &healthChecker{
+		logger:      logger,
+		clients:     clients,
+		initialized: make(map[string]bool),
+		failures:    make(map[string]string),
+	}, nil
+}
+
+// addComponents adds components that must be initialized, before the shops are
+// ready.
+func (h *healthChecker) addComponents(components ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, component := range components {
+		h.initialized[component] = false
+	}
+}
+
+// registerRoutes registers the liveness probe on /healthz and the readiness
+// probe on /readyz.
+func (h *healthChecker) registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status, healthy := h.checkKafka(r.Context())
+		h.mu.RLock()
+		healthy = h.addFailures(&status) && healthy
+		h.mu.RUnlock()
+		h.writeStatus(w, status, healthy)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status, healthy := h.checkKafka(r.Context())
+
+		h.mu.RLock()
+		status.Components = make(map[string]bool, len(h.initialized))
+		for component, initialized := range h.initialized {
+			status.Components[component] = initialized
+			healthy = healthy && initialized
+		}
+		status.Stopping = h.stopping
+		healthy = h.addFailures(&status) && healthy && !h.stopping
+		h.mu.RUnlock()
+
+		h.writeStatus(w, status, healthy)
+	})
+}
+
+// setInitialized marks the given component as initialized.
+func (h *healthChecker) setInitialized(component string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.initialized[component] = true
+}
+
+// setFailed makes the liveness probe fail, because the given component has
+// stopped working with the given error, so that the process is restarted.
+func (h *healthChecker) setFailed(component string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures[component] = err.Error()
+}
+
+// addFailures adds the failed components to the status and returns whether
+// none has failed. The caller must hold the read lock.
+func (h *healthChecker) addFailures(status *healthStatus) bool {
+	if len(h.failures) == 0 {
+		return true
+	}
+	status.Failures = make(map[string]string, len(h.failures))
+	for component, err := range h.failures {
+		status.Failures[component] = err
+	}
+	return false
+}
+
+// setStopping makes the readiness probe fail, so that no more traffic is
+// routed to the shop while it shuts down.
+func (h *healthChecker) setStopping() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopping = true
+}
+
+// checkKafka pings all Kafka clusters and reports whether all of them are
+// reachable.
+func (h *healthChecker) checkKafka(ctx context.Context) (healthStatus, bool) {
+	ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(h.clients))
+	for name := range h.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := healthStatus{Kafka: make(map[string]string, len(names))}
+	healthy := true
+	for _, name := range names {
+		label := name
+		if label == "" {
+			label = "default"
+		}
+		if err := h.clients[name].Ping(ctx); err != nil {
+			status.Kafka[label] = err.Error()
+			healthy = false
+			continue
+		}
+		status.Kafka[label] = "ok"
+	}
+
+	return status, healthy
+}
+
+func (h *healthChecker) writeStatus(w http.ResponseWriter, status healthStatus, healthy bool) {
+	statusCode := http.StatusOK
+	status.Status = "ok"
+	if !healthy {
+		statusCode = http.StatusServiceUnavailable
+		status.Status = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		h.logger.Warn("failed to write health status", zap.Error(err))
+	}
+}
+
+// close closes the Kafka clients of the health checker.
+func (h *healthChecker) close() {
+	for _, client := range h.clients {
+		client.Close()
+	}
+}
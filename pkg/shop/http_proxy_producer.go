@@ -0,0 +1,345 @@
+package shop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+const (
+	httpProxyContentType = "application/vnd.kafka.binary.v2+json"
+	httpProxyAccept      = "application/vnd.kafka.v2+json"
+
+	// httpProxyDefaultBatchMaxBytes is the max size of a batch if the batch
+	// max bytes are not configured, like the Kafka client's default.
+	httpProxyDefaultBatchMaxBytes = 1000012
+	// httpProxyDefaultMaxRequests is the max number of requests in flight if
+	// the max in flight are not configured, like the Kafka client's default.
+	httpProxyDefaultMaxRequests = 5
+	httpProxyRequestTimeout     = 30 * time.Second
+)
+
+// httpProxyProducer produces records via the Kafka REST API of an HTTP proxy,
+// e.g. Redpanda's pandaproxy, rather than the Kafka protocol. Records are
+// batched per topic for the producer's linger and sent as a single request
+// per batch in the binary embedded format. At most the producer's max in
+// flight requests are sent at a time, further records block in Produce like
+// they do if the Kafka client's buffer is full.
+//
+// The embedded format only consists of the key, value and partition of the
+// records. Records whose partition does not depend on the number of
+// partitions, i.e. of the manual partitioner and partition pins, are sent
+// with their partition, all others are partitioned by the proxy. The proxy
+// sets the timestamps of the records and drops their headers, so the config
+// validation rejects the options that rely on them. The response sets the
+// partitions and offsets of the records.
+//
+// The produce hooks of the service's meta client (e.g. metrics, CloudEvents
+// and the webhook sink) are called as if the records were produced by the
+// meta client, so that they work for both protocols. Injected duplicates are
+// produced by the meta client via the Kafka protocol.
+type httpProxyProducer struct {
+	logger *zap.Logger
+
+	address       string
+	basicAuth     config.HTTPBasicAuth
+	linger        time.Duration
+	batchMaxBytes int
+	httpClient    *http.Client
+
+	// client is the service's meta client, whose hooks are called.
+	client          *kgo.Client
+	bufferedHooks   []kgo.HookProduceRecordBuffered
+	unbufferedHooks []kgo.HookProduceRecordUnbuffered
+	partitioner     *topicPartitioner
+
+	// requests has a slot for each request that may be in flight.
+	requests chan struct{}
+
+	mu sync.Mutex
+	// batches are the batches that are lingering, by topic.
+	batches map[string]*httpProxyBatch
+	// inFlight is the number of records that have not been finished yet.
+	// drained is closed once it drops to 0.
+	inFlight int
+	drained  chan struct{}
+}
+
+type httpProxyBatch struct {
+	records []promisedRecord
+	bytes   int
+	timer   *time.Timer
+}
+
+type promisedRecord struct {
+	record  *kgo.Record
+	promise func(*kgo.Record, error)
+}
+
+// httpProxyRecord is a record of a produce request in the binary embedded
+// format, whose key and value are base64 encoded. Records without partition
+// are partitioned by the proxy.
+type httpProxyRecord struct {
+	Key       []byte `json:"key,omitempty"`
+	Value     []byte `json:"value"`
+	Partition *int32 `json:"partition,omitempty"`
+}
+
+// httpProxyOffset is the result of a single record of a produce request. The
+// error code is set if producing the record failed.
+type httpProxyOffset struct {
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	ErrorCode *int   `json:"error_code"`
+	Error     string `json:"error"`
+}
+
+// newHTTPProxyProducer creates the producer of the given cluster config. The
+// hooks are the produce hooks of the meta client, which are called by the
+// producer, and the partitioner provides the explicit partitions of records.
+func newHTTPProxyProducer(cfg config.Kafka, client *kgo.Client, hooks []kgo.Hook, partitioner *topicPartitioner, logger *zap.Logger) (*httpProxyProducer, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.HTTPProxy.TLS.Enabled {
+		tlsCfg, err := cfg.HTTPProxy.TLS.TLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls config: %w", err)
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	batchMaxBytes := int(cfg.Producer.BatchMaxBytes)
+	if batchMaxBytes == 0 {
+		batchMaxBytes = httpProxyDefaultBatchMaxBytes
+	}
+	maxRequests := cfg.Producer.MaxInFlight
+	if maxRequests == 0 {
+		maxRequests = httpProxyDefaultMaxRequests
+	}
+
+	p := &httpProxyProducer{
+		logger: logger,
+
+		address:       strings.TrimSuffix(cfg.HTTPProxy.Address, "/"),
+		basicAuth:     cfg.HTTPProxy.BasicAuth,
+		linger:        cfg.Producer.Linger,
+		batchMaxBytes: batchMaxBytes,
+		httpClient: &http.Client{
+			Timeout:   httpProxyRequestTimeout,
+			Transport: transport,
+		},
+
+		client:      client,
+		partitioner: partitioner,
+		requests:    make(chan struct{}, maxRequests),
+		batches:     make(map[string]*httpProxyBatch),
+	}
+	for _, hook := range hooks {
+		if h, ok := hook.(kgo.HookProduceRecordBuffered); ok {
+			p.bufferedHooks = append(p.bufferedHooks, h)
+		}
+		if h, ok := hook.(kgo.HookProduceRecordUnbuffered); ok {
+			p.unbufferedHooks = append(p.unbufferedHooks, h)
+		}
+	}
+
+	return p, nil
+}
+
+// Produce adds the record to the batch of its topic. The batch is sent once
+// the linger has elapsed or it has reached the batch max bytes.
+func (p *httpProxyProducer) Produce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.Context == nil {
+		r.Context = ctx
+	}
+	for _, h := range p.bufferedHooks {
+		h.OnProduceRecordBuffered(r)
+	}
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now()
+	}
+
+	p.mu.Lock()
+	if p.inFlight == 0 {
+		p.drained = make(chan struct{})
+	}
+	p.inFlight++
+	batch, ok := p.batches[r.Topic]
+	if !ok {
+		batch = &httpProxyBatch{}
+		p.batches[r.Topic] = batch
+	}
+	batch.records = append(batch.records, promisedRecord{record: r, promise: promise})
+	batch.bytes += len(r.Key) + len(r.Value)
+
+	if p.linger == 0 || batch.bytes >= p.batchMaxBytes {
+		delete(p.batches, r.Topic)
+		if batch.timer != nil {
+			batch.timer.Stop()
+		}
+		p.mu.Unlock()
+		p.sendAsync(r.Topic, batch.records)
+		return
+	}
+	if batch.timer == nil {
+		topic := r.Topic
+		batch.timer = time.AfterFunc(p.linger, func() { p.sendLingering(topic, batch) })
+	}
+	p.mu.Unlock()
+}
+
+// Flush sends all lingering batches and waits until all records have been
+// acknowledged. Afterwards the meta client is flushed, as it may produce
+// injected duplicates.
+func (p *httpProxyProducer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	lingering := p.batches
+	p.batches = make(map[string]*httpProxyBatch)
+	p.mu.Unlock()
+	for topic, batch := range lingering {
+		batch.timer.Stop()
+		p.sendAsync(topic, batch.records)
+	}
+
+	p.mu.Lock()
+	drained := p.drained
+	if p.inFlight == 0 {
+		drained = nil
+	}
+	p.mu.Unlock()
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for http proxy requests: %w", ctx.Err())
+		}
+	}
+
+	return p.client.Flush(ctx)
+}
+
+// sendAsync sends the records in a goroutine once a request slot is free.
+// It blocks until then, so that no more than the max requests are in flight.
+func (p *httpProxyProducer) sendAsync(topic string, records []promisedRecord) {
+	p.requests <- struct{}{}
+	go func() {
+		defer func() { <-p.requests }()
+		p.send(topic, records)
+	}()
+}
+
+// sendLingering sends the batch of the topic once its linger has elapsed,
+// unless it has been sent already.
+func (p *httpProxyProducer) sendLingering(topic string, batch *httpProxyBatch) {
+	p.mu.Lock()
+	if p.batches[topic] != batch {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.batches, topic)
+	p.mu.Unlock()
+
+	p.requests <- struct{}{}
+	defer func() { <-p.requests }()
+	p.send(topic, batch.records)
+}
+
+// send produces the records to the topic with a single request and finishes
+// each record with its result.
+func (p *httpProxyProducer) send(topic string, records []promisedRecord) {
+	offsets, err := p.post(topic, records)
+	for i, rec := range records {
+		recErr := err
+		if recErr == nil {
+			offset := offsets[i]
+			rec.record.Partition = offset.Partition
+			rec.record.Offset = offset.Offset
+			if offset.ErrorCode != nil && *offset.ErrorCode != 0 {
+				recErr = fmt.Errorf("http proxy failed to produce record with error code %v: %v", *offset.ErrorCode, offset.Error)
+			}
+		}
+		p.finish(rec, recErr)
+	}
+}
+
+func (p *httpProxyProducer) post(topic string, records []promisedRecord) ([]httpProxyOffset, error) {
+	body := struct {
+		Records []httpProxyRecord `json:"records"`
+	}{Records: make([]httpProxyRecord, len(records))}
+	for i, rec := range records {
+		body.Records[i] = httpProxyRecord{Key: rec.record.Key, Value: rec.record.Value}
+		if partition, ok := p.partitioner.explicitPartition(rec.record); ok {
+			body.Records[i].Partition = &partition
+		}
+	}
+	serialized, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize records: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.address+"/topics/"+url.PathEscape(topic), bytes.NewReader(serialized))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", httpProxyContentType)
+	req.Header.Set("Accept", httpProxyAccept)
+	if p.basicAuth.Username != "" {
+		req.SetBasicAuth(p.basicAuth.Username, p.basicAuth.Password)
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("http proxy responded with status %v: %s", res.StatusCode, resBody)
+	}
+
+	var response struct {
+		Offsets []httpProxyOffset `json:"offsets"`
+	}
+	if err := json.Unmarshal(resBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to deserialize response: %w", err)
+	}
+	if len(response.Offsets) != len(records) {
+		return nil, fmt.Errorf("http proxy responded with %d offsets for %d records", len(response.Offsets), len(records))
+	}
+
+	return response.Offsets, nil
+}
+
+func (p *httpProxyProducer) finish(rec promisedRecord, err error) {
+	for _, h := range p.unbufferedHooks {
+		h.OnProduceRecordUnbuffered(rec.record, err)
+	}
+	if rec.promise != nil {
+		rec.promise(rec.record, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight--
+	if p.inFlight == 0 {
+		close(p.drained)
+	}
+}
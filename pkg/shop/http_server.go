@@ -0,0 +1,91 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// httpServer is an HTTP listener of the shop. Each listener has a dedicated
+// mux, so that the metrics and the admin API can be bound to different
+// addresses.
+type httpServer struct {
+	name   string
+	server *http.Server
+	tls    config.HTTPServerTLS
+}
+
+// newHTTPServers creates the configured HTTP listeners, which all serve the
+// health probes. The admin API is served by the metrics listener, unless it
+// has a listen address of its own. The returned mux is the one on which the
+// admin routes shall be registered, it is nil if the admin API is disabled.
+func newHTTPServers(cfg config.Config, health *healthChecker) ([]*httpServer, *http.ServeMux, error) {
+	var servers []*httpServer
+	var adminMux *http.ServeMux
+
+	metricsCfg := cfg.Metrics
+	adminCfg := cfg.Shop.AdminAPI
+	adminOnMetrics := adminCfg.Enabled && adminCfg.ListenAddress == ""
+
+	if metricsCfg.Enabled {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		health.registerRoutes(mux)
+		if adminOnMetrics {
+			adminMux = mux
+		}
+		servers = append(servers, &httpServer{
+			name:   "metrics",
+			server: &http.Server{Addr: metricsCfg.ListenAddress, Handler: mux},
+			tls:    metricsCfg.TLS,
+		})
+	} else if adminOnMetrics {
+		return nil, nil, fmt.Errorf("admin api requires a listen address of its own if the metrics listener is disabled")
+	}
+
+	if adminCfg.Enabled && !adminOnMetrics {
+		adminMux = http.NewServeMux()
+		health.registerRoutes(adminMux)
+		servers = append(servers, &httpServer{
+			name:   "admin api",
+			server: &http.Server{Addr: adminCfg.ListenAddress, Handler: adminMux},
+			tls:    adminCfg.TLS,
+		})
+	}
+
+	return servers, adminMux, nil
+}
+
+// serve blocks until the listener has been shut down or failed.
+func (h *httpServer) serve(logger *zap.Logger) {
+	logger = logger.With(zap.String("listener", h.name), zap.String("listen_address", h.server.Addr))
+	logger.Info("starting http listener", zap.Bool("tls", h.tls.Enabled))
+
+	var err error
+	if h.tls.Enabled {
+		err = h.server.ListenAndServeTLS(h.tls.CertFilepath, h.tls.KeyFilepath)
+	} else {
+		err = h.server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		logger.Info("http listener quit")
+		return
+	}
+	logger.Error("http listener failed", zap.Error(err))
+}
+
+// shutdown gracefully shuts down the listener. If its connections are not
+// idle before the context is done, they are closed right away.
+func (h *httpServer) shutdown(ctx context.Context) error {
+	if err := h.server.Shutdown(ctx); err != nil {
+		h.server.Close()
+		return fmt.Errorf("failed to shutdown %v listener: %w", h.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// initializer initializes a single component of the shop, e.g. by creating
+// its topics or registering its schemas.
+type initializer struct {
+	name       string
+	initialize func(context.Context) error
+}
+
+// initialize initializes all components in order. Each component is retried
+// with an exponential backoff until it succeeds, the given number of attempts
+// is exhausted or the context is cancelled. Zero max attempts retry until the
+// context is cancelled. Once all components have been initialized, the
+// services are started.
+func (s *Shop) initialize(ctx context.Context, maxAttempts int) error {
+	for _, component := range s.initializers {
+		if err := s.initializeComponent(ctx, component, maxAttempts); err != nil {
+			return err
+		}
+		s.health.setInitialized(component.name)
+	}
+
+	s.startServices()
+	close(s.initialized)
+	s.logger.Info("successfully initialized all components")
+
+	return nil
+}
+
+func (s *Shop) initializeComponent(ctx context.Context, component initializer, maxAttempts int) error {
+	cfg := s.cfg.Shop.Initialization
+	backoff := cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, cfg.AttemptTimeout)
+		err := component.initialize(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return fmt.Errorf("failed to initialize %v after %d attempts: %w", component.name, attempt, err)
+		}
+		s.logger.Warn("failed to initialize component, retrying",
+			zap.String("component", component.name),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to initialize %v: %w", component.name, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
+// startServices starts the consumers of all services and the background tasks
+// that are not bound to the traffic simulation. It only starts them once.
+func (s *Shop) startServices() {
+	s.startServicesOnce.Do(func() {
+		if s.cfg.Shop.SchemaEvolution.Enabled {
+			go s.serdes.EvolveOrderSchema(s.backgroundCtx)
+		}
+		go s.customerSvc.DeleteCustomersPeriodically(s.backgroundCtx)
+		go s.frontendSvc.AdvanceSessions(s.backgroundCtx)
+		go s.productCatalogSvc.ChangePricesPeriodically(s.backgroundCtx)
+		if s.deadLetterSvc != nil {
+			go s.deadLetterSvc.InjectPoisonMessagesPeriodically(s.backgroundCtx)
+			go s.deadLetterSvc.Start()
+		}
+		if s.manyTopicsSvc != nil {
+			go s.manyTopicsSvc.ProduceRecordsPeriodically(s.backgroundCtx)
+		}
+		if s.rebalancer != nil {
+			go s.rebalancer.rebalancePeriodically(s.backgroundCtx)
+		}
+		if s.sales != nil {
+			go s.sales.run(s.backgroundCtx)
+		}
+		if s.verifier != nil {
+			go s.verifier.Start()
+		}
+
+		go s.customerSvc.Start()
+		go s.addressSvc.Start()
+		go s.orderSvc.Start()
+		go s.inventorySvc.Start()
+		go s.paymentSvc.Start()
+		go s.shipmentSvc.Start()
+		go s.reviewSvc.Start()
+		go s.cartSvc.Start()
+		if s.notificationSvc != nil {
+			go s.notificationSvc.Start()
+		}
+		if s.returnSvc != nil {
+			go s.returnSvc.Start()
+		}
+		if s.supportSvc != nil {
+			go s.supportSvc.Start()
+		}
+		for _, g := range s.generators {
+			go g.generator.Start()
+		}
+	})
+}
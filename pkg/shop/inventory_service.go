@@ -0,0 +1,317 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// InventoryService consumes the orders topic and reserves the stock for each
+// ordered line item by producing a stock reservation event to the inventory
+// topic. Some of these reservations are released again later on, to simulate
+// orders that have not been fulfilled. If warehouses are enabled, the stock
+// levels are tracked per warehouse, so that the inventory topic also contains
+// transfers between warehouses, stock-outs and restocks.
+type InventoryService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	orderSerde      *TopicSerde
+	serde           *TopicSerde
+
+	bufferSize           int
+	recentReservationsMu sync.RWMutex
+	recentReservations   []fake.InventoryEvent
+
+	// warehouses is nil if warehouses are disabled.
+	warehouses *warehouseStock
+
+	topicName string
+}
+
+// NewInventoryService creates a new InventoryService.
+func NewInventoryService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
+) (*InventoryService, error) {
+	clientID := cfg.Services.Inventory.ClientIDFor(cfg.GlobalPrefix, "inventory-service")
+	metrics := newClientMetrics("inventory_service")
+	headers := newRecordHeaders(cfg.Headers, "inventory-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "inventory-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Inventory.Offsets, logger)
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("inventory-service")),
+			kgo.ConsumeTopics(cfg.TopicName("orders")),
+			readCommittedOrders(),
+		)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	// This slice is used to keep some reservations in the buffer so that they can be released
+	bufferSize := 500
+	recentReservations := make([]fake.InventoryEvent, 0, bufferSize)
+
+	return &InventoryService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "inventory_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Inventory.SlowConsumer),
+		offsets:         offsets,
+		orderSerde:      serdes.Orders,
+		serde:           serdes.Inventory,
+
+		bufferSize:           bufferSize,
+		recentReservationsMu: sync.RWMutex{},
+		recentReservations:   recentReservations,
+
+		warehouses: newWarehouseStock(cfg.Warehouses, clock, logger, cfg.PendingFollowUps),
+
+		topicName: cfg.TopicName("inventory"),
+	}, nil
+}
+
+// Initialize inventory service by reconciling the inventory topic.
+func (svc *InventoryService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing inventory service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized inventory service")
+
+	return nil
+}
+
+// Close stops consuming the orders topic, flushes all buffered records and
+// closes the Kafka clients.
+func (svc *InventoryService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// Start consuming messages from the orders topic and reserve the stock for
+// each consumed order. If warehouses are enabled, the articles are restocked
+// in the background after their stock-outs until the consumer has been
+// closed.
+func (svc *InventoryService) Start() {
+	defer close(svc.consumerStopped)
+
+	if svc.warehouses != nil {
+		quit := make(chan struct{})
+		restocksStopped := make(chan struct{})
+		go func() {
+			defer close(restocksStopped)
+			svc.warehouses.restocks.run(quit, svc.restockArticle)
+		}()
+		defer func() {
+			close(quit)
+			<-restocksStopped
+		}()
+	}
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeOrderConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+
+			order := fake.Order{}
+			err := svc.orderSerde.Decode(rec.Value, &order)
+			if err != nil {
+				// Skip message
+				svc.logger.Warn("failed to deserialize order", zap.Error(err))
+				return
+			}
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			svc.reserveStock(ctx, order)
+			span.End()
+		})
+	}
+}
+
+// reserveStock reserves the stock of all line items of the order. The
+// reservations continue the trace of the given context, as do the transfers,
+// stock-outs and restocks of the warehouses.
+func (svc *InventoryService) reserveStock(ctx context.Context, order fake.Order) {
+	for _, item := range order.LineItems {
+		now := svc.clock.now()
+		events := []fake.InventoryEvent{fake.NewStockReservation(order.ID, item, now)}
+		if svc.warehouses != nil {
+			events = svc.warehouses.reserve(ctx, order.ID, item, now)
+		}
+
+		for _, event := range events {
+			eventType := inventoryEventTypeMetricLabels[event.Type]
+			err := svc.produceInventoryEvent(withEventType(ctx, eventType), event)
+			if err != nil {
+				svc.logger.Warn("failed to produce inventory event", zap.Error(err))
+				continue
+			}
+			kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+
+			if event.Type != fake.InventoryEventTypeStockReserved {
+				continue
+			}
+			svc.recentReservationsMu.Lock()
+			if len(svc.recentReservations) < svc.bufferSize {
+				svc.recentReservations = append(svc.recentReservations, event)
+			}
+			svc.recentReservationsMu.Unlock()
+		}
+	}
+}
+
+// restockArticle restocks the article of the given pending restock, which is
+// due, and produces its restock events.
+func (svc *InventoryService) restockArticle(ctx context.Context, restock articleRestock, now time.Time) {
+	for _, event := range svc.warehouses.restockDue(restock, now) {
+		err := svc.produceInventoryEvent(withEventType(ctx, EventTypeStockRestocked), event)
+		if err != nil {
+			svc.logger.Warn("failed to produce stock restock", zap.Error(err))
+			continue
+		}
+		kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeStockRestocked}).Inc()
+	}
+}
+
+// ReleaseStock takes an existing reservation from the cache and produces
+// an event that releases the reserved stock again.
+func (svc *InventoryService) ReleaseStock() {
+	reservation, err := svc.popReservationFromBuffer()
+	if err != nil {
+		svc.logger.Debug("failed to pop reservation from buffer", zap.Error(err))
+		return
+	}
+
+	release := fake.NewStockRelease(reservation, svc.clock.now())
+	if svc.warehouses != nil {
+		release.StockLevel = svc.warehouses.release(reservation)
+	}
+	err = svc.produceInventoryEvent(withEventType(context.Background(), EventTypeStockReleased), release)
+	if err != nil {
+		svc.logger.Warn("failed to produce stock release", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeStockReleased}).Inc()
+}
+
+func (svc *InventoryService) produceInventoryEvent(ctx context.Context, event fake.InventoryEvent) error {
+	serialized, err := svc.serde.Encode(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize inventory event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, event.ArticleID, ""),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
+
+func (svc *InventoryService) popReservationFromBuffer() (fake.InventoryEvent, error) {
+	svc.recentReservationsMu.Lock()
+	defer svc.recentReservationsMu.Unlock()
+
+	if len(svc.recentReservations) == 0 {
+		// No reservations in buffer yet
+		return fake.InventoryEvent{}, fmt.Errorf("buffer is empty")
+	}
+	reservation := svc.recentReservations[0]
+	svc.recentReservations = svc.recentReservations[1:]
+
+	return reservation, nil
+}
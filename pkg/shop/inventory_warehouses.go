@@ -0,0 +1,216 @@
+package shop
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// warehouseStock tracks the stock level of each ordered article per
+// warehouse. Articles are stocked with the initial stock in all warehouses
+// once they are ordered for the first time. A nil stock tracks nothing.
+type warehouseStock struct {
+	cfg config.Warehouses
+
+	// restocks are the pending restocks after stock-outs, which are due
+	// after the restock delay.
+	restocks *followUps[articleRestock]
+
+	mu       sync.Mutex
+	articles map[string]*articleStock
+}
+
+// articleStock holds the stock levels of an article, indexed like the
+// configured warehouse names.
+type articleStock struct {
+	levels []int
+	// restockAt is the time at which the article is restocked in each
+	// warehouse after a stock-out. It is zero if no restock is pending.
+	restockAt []time.Time
+}
+
+// articleRestock is the pending restock of an article in a warehouse, indexed
+// like the configured warehouse names.
+type articleRestock struct {
+	articleID string
+	warehouse int
+}
+
+// newWarehouseStock returns nil if warehouses are disabled. Once the
+// configured max articles are tracked, a random one is evicted. At most the
+// given number of restocks are pending at a time.
+func newWarehouseStock(cfg config.Warehouses, clock *simulationClock, logger *zap.Logger, maxRestocks int) *warehouseStock {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &warehouseStock{
+		cfg:      cfg,
+		restocks: newFollowUps[articleRestock](clock, logger, "restock", maxRestocks),
+		articles: make(map[string]*articleStock),
+	}
+}
+
+// reserve reserves the stock of the given line item and returns the inventory
+// events of all stock changes in the order they happened. The item is
+// reserved in a random warehouse, or in the warehouse with the most stock if
+// the random one can't fulfill it. Warehouses that are named first receive
+// more orders, so that their stock runs low sooner. If no warehouse can
+// fulfill it, a stock-out event is returned instead of a reservation and the
+// restock of the random warehouse is scheduled after the restock delay. A
+// warehouse that runs low afterwards is supplied by the warehouse with the
+// most stock, as long as that one doesn't run low itself. Restocks continue
+// the trace of the given context.
+func (w *warehouseStock) reserve(ctx context.Context, orderID string, item fake.OrderLineItem, now time.Time) []fake.InventoryEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stock := w.article(item.ArticleID)
+	events := w.restock(item.ArticleID, stock, now)
+
+	primary := w.randomWarehouse()
+	warehouse := primary
+	if stock.levels[warehouse] < item.Quantity {
+		warehouse = stock.fullest(-1)
+	}
+	if stock.levels[warehouse] < item.Quantity {
+		if stock.restockAt[primary].IsZero() {
+			stock.restockAt[primary] = now.Add(w.cfg.RestockDelay)
+			w.restocks.schedule(ctx, articleRestock{articleID: item.ArticleID, warehouse: primary}, stock.restockAt[primary])
+		}
+		return append(events, fake.NewStockOut(orderID, item, w.cfg.Names[primary], stock.levels[primary], now))
+	}
+
+	stock.levels[warehouse] -= item.Quantity
+	reservation := fake.NewStockReservation(orderID, item, now)
+	reservation.WarehouseID = w.cfg.Names[warehouse]
+	reservation.StockLevel = stock.levels[warehouse]
+	events = append(events, reservation)
+
+	if stock.levels[warehouse] >= w.cfg.TransferThreshold || len(w.cfg.Names) == 1 {
+		return events
+	}
+	source := stock.fullest(warehouse)
+	if stock.levels[source]-w.cfg.TransferQuantity < w.cfg.TransferThreshold {
+		return events
+	}
+	stock.levels[source] -= w.cfg.TransferQuantity
+	stock.levels[warehouse] += w.cfg.TransferQuantity
+	return append(events, fake.NewStockTransfer(item.ArticleID, w.cfg.Names[source], w.cfg.Names[warehouse], w.cfg.TransferQuantity, stock.levels[source], now))
+}
+
+// release adds the quantity of the given reservation back to the stock of its
+// warehouse and returns the warehouse's new stock level. If the article has
+// been evicted meanwhile, its stock starts over with the initial stock once
+// it is ordered again, which is returned without adding the quantity.
+func (w *warehouseStock) release(reservation fake.InventoryEvent) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stock, ok := w.articles[reservation.ArticleID]
+	if !ok {
+		return w.cfg.InitialStock
+	}
+	for i, name := range w.cfg.Names {
+		if name == reservation.WarehouseID {
+			stock.levels[i] += reservation.Quantity
+			return stock.levels[i]
+		}
+	}
+	return 0
+}
+
+// randomWarehouse returns the index of a random warehouse. Each warehouse is
+// weighted by the number of warehouses that are named after it, plus one.
+func (w *warehouseStock) randomWarehouse() int {
+	n := len(w.cfg.Names)
+	pick := rand.Intn(n * (n + 1) / 2)
+	for i := 0; i < n; i++ {
+		weight := n - i
+		if pick < weight {
+			return i
+		}
+		pick -= weight
+	}
+	return n - 1
+}
+
+// article returns the stock of the given article. The caller must hold the
+// lock.
+func (w *warehouseStock) article(articleID string) *articleStock {
+	if stock, ok := w.articles[articleID]; ok {
+		return stock
+	}
+
+	if len(w.articles) >= w.cfg.MaxArticles {
+		for id := range w.articles {
+			delete(w.articles, id)
+			break
+		}
+	}
+	stock := &articleStock{
+		levels:    make([]int, len(w.cfg.Names)),
+		restockAt: make([]time.Time, len(w.cfg.Names)),
+	}
+	for i := range stock.levels {
+		stock.levels[i] = w.cfg.InitialStock
+	}
+	w.articles[articleID] = stock
+	return stock
+}
+
+// restockDue restocks the article of the given pending restock in all
+// warehouses whose restock is due and returns their restock events. Evicted
+// articles are not restocked.
+func (w *warehouseStock) restockDue(restock articleRestock, now time.Time) []fake.InventoryEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stock, ok := w.articles[restock.articleID]
+	if !ok {
+		return nil
+	}
+	return w.restock(restock.articleID, stock, now)
+}
+
+// restock restocks the given article to the initial stock in all warehouses
+// whose restock is due and returns their restock events. Restocks that are
+// due are usually done by restockDue already, unless they have been dropped.
+// The caller must hold the lock.
+func (w *warehouseStock) restock(articleID string, stock *articleStock, now time.Time) []fake.InventoryEvent {
+	var events []fake.InventoryEvent
+	for i, restockAt := range stock.restockAt {
+		if restockAt.IsZero() || restockAt.After(now) {
+			continue
+		}
+		stock.restockAt[i] = time.Time{}
+
+		quantity := w.cfg.InitialStock - stock.levels[i]
+		if quantity <= 0 {
+			continue
+		}
+		stock.levels[i] += quantity
+		events = append(events, fake.NewStockRestock(articleID, w.cfg.Names[i], quantity, stock.levels[i], now))
+	}
+	return events
+}
+
+// fullest returns the index of the warehouse with the most stock, excluding
+// the warehouse with the given index.
+func (s *articleStock) fullest(exclude int) int {
+	fullest := -1
+	for i, level := range s.levels {
+		if i == exclude {
+			continue
+		}
+		if fullest == -1 || level > s.levels[fullest] {
+			fullest = i
+		}
+	}
+	return fullest
+}
@@ -0,0 +1,111 @@
+package shop
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jsonSchemaDraft is the JSON Schema version of the generated schemas, which
+// is the latest version that is supported by all common schema registries.
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// newJSONSchema returns the JSON Schema of the JSON serialization of the given
+// value. The schema is derived from the value's type, so that it always
+// matches the records that encoding/json produces. Fields without omitempty
+// are always serialized and thus required. Additional properties are allowed,
+// so that fields can be added without breaking compatibility.
+func newJSONSchema(v any) (string, error) {
+	t := reflect.TypeOf(v)
+	schema := jsonSchemaOf(t)
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = t.Name()
+
+	serialized, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal json schema: %w", err)
+	}
+
+	return string(serialized), nil
+}
+
+func jsonSchemaOf(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return map[string]any{"anyOf": []any{map[string]any{"type": "null"}, jsonSchemaOf(t.Elem())}}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are serialized as base64 encoded strings
+			return map[string]any{"type": []any{"string", "null"}}
+		}
+		// Nil slices are serialized as null
+		return map[string]any{"type": []any{"array", "null"}, "items": jsonSchemaOf(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": []any{"object", "null"}, "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		return jsonSchemaOfStruct(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+func jsonSchemaOfStruct(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		// Fields of embedded structs without tag are promoted to the parent
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := jsonSchemaOfStruct(field.Type)
+			for k, v := range embedded["properties"].(map[string]any) {
+				properties[k] = v
+			}
+			required = append(required, embedded["required"].([]string)...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = jsonSchemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
@@ -0,0 +1,136 @@
+package shop
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// lateRecords holds back a share of the produced records of a service for
+// their lateness in simulated time, so that they arrive out of order, with a
+// timestamp in the past, and after the watermarks of stream processors may
+// have passed them. Records of transactions are never late, because they must
+// be produced before the transaction is committed, and neither are the
+// records of generators, which produce with their own clients.
+type lateRecords struct {
+	cfg     config.LateRecords
+	clock   *simulationClock
+	metrics *clientMetrics
+}
+
+func newLateRecords(cfg config.LateRecords, clock *simulationClock, metrics *clientMetrics) *lateRecords {
+	return &lateRecords{cfg: cfg, clock: clock, metrics: metrics}
+}
+
+// producer returns the given producer wrapped by a producer that holds back
+// the late records, or the producer itself if late records are disabled.
+func (l *lateRecords) producer(producer recordProducer) recordProducer {
+	if l == nil || !l.cfg.Enabled {
+		return producer
+	}
+	return &lateProducer{late: l, producer: producer}
+}
+
+// lateness returns the random duration by which a late record is held back.
+func (l *lateRecords) lateness() time.Duration {
+	lateness := l.cfg.MinLateness
+	if spread := l.cfg.MaxLateness - l.cfg.MinLateness; spread > 0 {
+		lateness += time.Duration(rand.Int63n(int64(spread)))
+	}
+	return lateness
+}
+
+// lateProducer passes the records on to the wrapped producer, except for the
+// late records, which are buffered until they are due by the simulation clock.
+type lateProducer struct {
+	late     *lateRecords
+	producer recordProducer
+
+	mu      sync.Mutex
+	pending []lateRecord
+	// polling is true while a goroutine produces the due records, which
+	// returns once no records are pending anymore.
+	polling bool
+}
+
+// lateRecord is a record that is held back until it is due.
+type lateRecord struct {
+	ctx     context.Context
+	record  *kgo.Record
+	promise func(*kgo.Record, error)
+	dueAt   time.Time
+}
+
+// Produce produces the record or, with the configured ratio, holds it back.
+// The timestamp of a held back record is set before, so that it is the time
+// of the event rather than the time the record is produced.
+func (p *lateProducer) Produce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error)) {
+	if rand.Float64() >= p.late.cfg.Ratio {
+		p.producer.Produce(ctx, r, promise)
+		return
+	}
+
+	now := p.late.clock.now()
+	if r.Timestamp.IsZero() {
+		r.Timestamp = now
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, lateRecord{ctx: ctx, record: r, promise: promise, dueAt: now.Add(p.late.lateness())})
+	if !p.polling {
+		p.polling = true
+		go p.poll()
+	}
+}
+
+// poll regularly produces the records that are due, until no records are
+// pending anymore.
+func (p *lateProducer) poll() {
+	ticker := time.NewTicker(p.late.clock.realDuration(time.Second))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !p.produceDue(p.late.clock.now(), false) {
+			return
+		}
+	}
+}
+
+// produceDue produces the pending records that are due at the given time, or
+// all of them. It returns false, once no records are pending anymore.
+func (p *lateProducer) produceDue(now time.Time, all bool) bool {
+	p.mu.Lock()
+	var due []lateRecord
+	remaining := p.pending[:0]
+	for _, pending := range p.pending {
+		if !all && pending.dueAt.After(now) {
+			remaining = append(remaining, pending)
+			continue
+		}
+		due = append(due, pending)
+	}
+	p.pending = remaining
+	if len(remaining) == 0 {
+		p.polling = false
+	}
+	p.mu.Unlock()
+
+	for _, pending := range due {
+		p.producer.Produce(pending.ctx, pending.record, pending.promise)
+		kafkaLateRecordsProducedTotal.With(p.late.metrics.labels(pending.record.Topic)).Inc()
+	}
+	return len(remaining) > 0
+}
+
+// Flush produces all pending records, regardless of whether they are due, and
+// then flushes the producer, so that no late records are lost on shutdown.
+func (p *lateProducer) Flush(ctx context.Context) error {
+	p.produceDue(time.Time{}, true)
+	return p.producer.Flush(ctx)
+}
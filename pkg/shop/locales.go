@@ -0,0 +1,35 @@
+package shop
+
+import (
+	"fmt"
+
+	"github.com/mroth/weightedrand"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// newLocaleChooser returns a chooser that picks the locale of each new
+// customer from the configured regions by weight. Without regions, all
+// customers are given the configured locale.
+func newLocaleChooser(cfg config.Shop) (*weightedrand.Chooser, error) {
+	regions := cfg.Regions
+	if len(regions) == 0 {
+		regions = []config.Region{{Locale: cfg.Locale, Weight: 1}}
+	}
+
+	choices := make([]weightedrand.Choice, len(regions))
+	for i, region := range regions {
+		if err := fake.ValidateLocale(region.Locale); err != nil {
+			return nil, err
+		}
+		choices[i] = weightedrand.Choice{Item: region.Locale, Weight: region.Weight}
+	}
+
+	chooser, err := weightedrand.NewChooser(choices...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create locale chooser: %w", err)
+	}
+
+	return chooser, nil
+}
@@ -0,0 +1,204 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// createTopicsBatchSize is the max number of topics that are created with a
+// single request.
+const createTopicsBatchSize = 100
+
+var (
+	stressTopicDomains  = []string{"orders", "payments", "inventory", "shipments", "carts", "customers", "products", "reviews", "pricing", "loyalty"}
+	stressTopicEntities = []string{"refunds", "invoices", "discounts", "returns", "wishlists", "ratings", "recommendations", "bundles", "vouchers", "warehouses"}
+	stressTopicKinds    = []string{"events", "changelog", "snapshots", "audit", "commands", "dlq", "retry", "metrics", "enriched", "aggregated"}
+)
+
+// stressTopicNames returns the given number of distinct topic names with
+// shop related names, e.g. owlshop-stress-payments-refunds-audit. Once all
+// combinations of the name parts have been used, a running number is
+// appended.
+func stressTopicNames(prefix string, count int) []string {
+	combinations := len(stressTopicDomains) * len(stressTopicEntities) * len(stressTopicKinds)
+	names := make([]string, count)
+	for i := range names {
+		n := i % combinations
+		kind := stressTopicKinds[n%len(stressTopicKinds)]
+		n /= len(stressTopicKinds)
+		entity := stressTopicEntities[n%len(stressTopicEntities)]
+		n /= len(stressTopicEntities)
+		domain := stressTopicDomains[n]
+
+		name := prefix + "stress-" + domain + "-" + entity + "-" + kind
+		if round := i / combinations; round > 0 {
+			name += "-" + strconv.Itoa(round)
+		}
+		names[i] = name
+	}
+
+	return names
+}
+
+// ManyTopicsService creates many low traffic topics and trickles records into
+// them, so that Kafka tooling can be stress tested with a high topic count.
+type ManyTopicsService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	metaClient *kgo.Client
+	producer   recordProducer
+
+	topicNames []string
+}
+
+// NewManyTopicsService creates a new ManyTopicsService.
+func NewManyTopicsService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	tracing *tracing,
+	clock *simulationClock,
+) (*ManyTopicsService, error) {
+	clientID := cfg.GlobalPrefix + "many-topics-service"
+	metrics := newClientMetrics("many_topics_service")
+	headers := newRecordHeaders(cfg.Headers, "many-topics-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "many-topics-service")
+
+	hooks := []kgo.Hook{cloudEvents, metrics, headers}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ManyTopicsService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "many_topics_service")),
+		clock:  clock,
+
+		metaClient: metaClient,
+		producer:   producer,
+
+		topicNames: stressTopicNames(cfg.TopicNamePrefix(), cfg.ManyTopics.Count),
+	}, nil
+}
+
+// Initialize many topics service by creating all topics that don't exist
+// yet. The topics are created in batches, as brokers may time out requests
+// that create thousands of topics at once.
+func (svc *ManyTopicsService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing many topics service", zap.Int("topics", len(svc.topicNames)))
+
+	adminClient := kadm.NewClient(svc.metaClient)
+	existing, err := adminClient.ListTopics(ctx, svc.topicNames...)
+	if err != nil {
+		return fmt.Errorf("failed to get metadata to check which topics exist: %w", err)
+	}
+
+	missing := make([]string, 0, len(svc.topicNames))
+	for _, topicName := range svc.topicNames {
+		if !existing.Has(topicName) {
+			missing = append(missing, topicName)
+		}
+	}
+
+	configs := map[string]*string{"cleanup.policy": kadm.StringPtr("delete")}
+	for start := 0; start < len(missing); start += createTopicsBatchSize {
+		end := start + createTopicsBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		responses, err := adminClient.CreateTopics(ctx,
+			svc.cfg.ManyTopics.PartitionCount,
+			svc.cfg.TopicReplicationFactor,
+			configs,
+			missing[start:end]...)
+		if err != nil {
+			return fmt.Errorf("failed to create topics: %w", err)
+		}
+		for _, res := range responses {
+			if res.Err != nil && !errors.Is(res.Err, kerr.TopicAlreadyExists) {
+				return fmt.Errorf("failed to create topic '%v': %w", res.Topic, res.Err)
+			}
+		}
+		svc.logger.Debug("created topics", zap.Int("created", end), zap.Int("missing", len(missing)))
+	}
+
+	svc.logger.Info("successfully initialized many topics service", zap.Int("created_topics", len(missing)))
+
+	return nil
+}
+
+// Close flushes all buffered records and closes the Kafka client.
+func (svc *ManyTopicsService) Close(ctx context.Context) error {
+	return closeClients(ctx, nil, nil, nil, svc.producer, svc.metaClient)
+}
+
+// ProduceRecordsPeriodically produces a record into a random topic in each
+// configured interval until the given context is cancelled.
+func (svc *ManyTopicsService) ProduceRecordsPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(svc.cfg.ManyTopics.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			svc.ProduceRecord()
+		}
+	}
+}
+
+// ProduceRecord produces a small record into a random topic.
+func (svc *ManyTopicsService) ProduceRecord() {
+	topicName := gofakeit.RandomString(svc.topicNames)
+	key := gofakeit.UUID()
+	value, err := json.Marshal(map[string]interface{}{
+		"id":        key,
+		"topic":     topicName,
+		"createdAt": svc.clock.now(),
+	})
+	if err != nil {
+		svc.logger.Warn("failed to serialize stress record", zap.Error(err))
+		return
+	}
+
+	rec := kgo.Record{
+		Key:       []byte(key),
+		Value:     value,
+		Timestamp: svc.clock.now(),
+		Topic:     topicName,
+	}
+
+	ctx := withEventType(context.Background(), EventTypeStressRecordProduced)
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeStressRecordProduced}).Inc()
+}
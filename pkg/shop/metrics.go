@@ -3,21 +3,213 @@ package shop
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
 )
 
 const (
-	EventTypeAddressCreated = "ADDRESS_CREATED"
+	EventTypeAddressCreated  = "ADDRESS_CREATED"
+	EventTypeAddressModified = "ADDRESS_MODIFIED"
+	EventTypeAddressDeleted  = "ADDRESS_DELETED"
 
 	EventTypeCustomerCreated  = "CUSTOMER_CREATED"
 	EventTypeCustomerModified = "CUSTOMER_MODIFIED"
 	EventTypeCustomerDeleted  = "CUSTOMER_DELETED"
 	EventTypeCustomerConsumed = "CUSTOMER_CONSUMED"
 
-	EventTypeOrderCreated = "ORDER_CREATED"
+	EventTypeCustomerChangeConsumed  = "CUSTOMER_CHANGE_CONSUMED"
+	EventTypeCustomerSnapshotUpdated = "CUSTOMER_SNAPSHOT_UPDATED"
+
+	EventTypeLoyaltyPointsEarned = "LOYALTY_POINTS_EARNED"
+
+	EventTypeOrderCreated  = "ORDER_CREATED"
+	EventTypeOrderConsumed = "ORDER_CONSUMED"
+
+	EventTypeOrderLifecycleCreated   = "ORDER_LIFECYCLE_CREATED"
+	EventTypeOrderLifecycleConfirmed = "ORDER_LIFECYCLE_CONFIRMED"
+	EventTypeOrderLifecyclePacked    = "ORDER_LIFECYCLE_PACKED"
+	EventTypeOrderLifecycleShipped   = "ORDER_LIFECYCLE_SHIPPED"
+	EventTypeOrderLifecycleCancelled = "ORDER_LIFECYCLE_CANCELLED"
+
+	EventTypeOrderCancelled = "ORDER_CANCELLED"
+	EventTypeOrderRefunded  = "ORDER_REFUNDED"
+
+	EventTypeFraudSignalCreated = "FRAUD_SIGNAL_CREATED"
+
+	EventTypePromoCodeRedeemed = "PROMO_CODE_REDEEMED"
+
+	EventTypeCustomerActivityCreated = "CUSTOMER_ACTIVITY_CREATED"
 
 	EventTypeFrontendEventCreated = "FRONTEND_EVENT_CREATED"
+
+	EventTypeSearchQueryCreated = "SEARCH_QUERY_CREATED"
+
+	EventTypeProductCreated  = "PRODUCT_CREATED"
+	EventTypeProductModified = "PRODUCT_MODIFIED"
+
+	EventTypeProductMediaCreated = "PRODUCT_MEDIA_CREATED"
+
+	EventTypePriceUpdated     = "PRICE_UPDATED"
+	EventTypePromotionStarted = "PROMOTION_STARTED"
+	EventTypePromotionEnded   = "PROMOTION_ENDED"
+
+	EventTypeStockReserved    = "STOCK_RESERVED"
+	EventTypeStockReleased    = "STOCK_RELEASED"
+	EventTypeStockTransferred = "STOCK_TRANSFERRED"
+	EventTypeStockOut         = "STOCK_OUT"
+	EventTypeStockRestocked   = "STOCK_RESTOCKED"
+
+	EventTypePaymentAuthorized = "PAYMENT_AUTHORIZED"
+	EventTypePaymentCaptured   = "PAYMENT_CAPTURED"
+	EventTypePaymentDeclined   = "PAYMENT_DECLINED"
+	EventTypePaymentRefunded   = "PAYMENT_REFUNDED"
+	EventTypePaymentConsumed   = "PAYMENT_CONSUMED"
+
+	EventTypeInvoiceIssued  = "INVOICE_ISSUED"
+	EventTypeInvoiceOverdue = "INVOICE_OVERDUE"
+	EventTypeInvoicePaid    = "INVOICE_PAID"
+
+	EventTypeShipmentLabelCreated = "SHIPMENT_LABEL_CREATED"
+	EventTypeShipmentPickedUp     = "SHIPMENT_PICKED_UP"
+	EventTypeShipmentInTransit    = "SHIPMENT_IN_TRANSIT"
+	EventTypeShipmentDelivered    = "SHIPMENT_DELIVERED"
+	EventTypeShipmentConsumed     = "SHIPMENT_CONSUMED"
+
+	EventTypeReturnRequested    = "RETURN_REQUESTED"
+	EventTypeReturnReceived     = "RETURN_RECEIVED"
+	EventTypeReturnRefundIssued = "RETURN_REFUND_ISSUED"
+
+	EventTypeSupportTicketOpened   = "SUPPORT_TICKET_OPENED"
+	EventTypeSupportTicketReplied  = "SUPPORT_TICKET_REPLIED"
+	EventTypeSupportTicketResolved = "SUPPORT_TICKET_RESOLVED"
+
+	EventTypeNotificationQueued    = "NOTIFICATION_QUEUED"
+	EventTypeNotificationSent      = "NOTIFICATION_SENT"
+	EventTypeNotificationDelivered = "NOTIFICATION_DELIVERED"
+	EventTypeNotificationBounced   = "NOTIFICATION_BOUNCED"
+	EventTypeNotificationFailed    = "NOTIFICATION_FAILED"
+
+	EventTypeReviewCreated  = "REVIEW_CREATED"
+	EventTypeReviewModified = "REVIEW_MODIFIED"
+	EventTypeReviewDeleted  = "REVIEW_DELETED"
+
+	EventTypeCartCreated     = "CART_CREATED"
+	EventTypeCartItemAdded   = "CART_ITEM_ADDED"
+	EventTypeCartItemRemoved = "CART_ITEM_REMOVED"
+	EventTypeCartCheckedOut  = "CART_CHECKED_OUT"
+	EventTypeCartAbandoned   = "CART_ABANDONED"
+
+	EventTypePoisonMessageProduced    = "POISON_MESSAGE_PRODUCED"
+	EventTypeDeadLetterProduced       = "DEAD_LETTER_PRODUCED"
+	EventTypeDeadLetterSourceConsumed = "DEAD_LETTER_SOURCE_CONSUMED"
+
+	EventTypeChangeEventProduced     = "CHANGE_EVENT_PRODUCED"
+	EventTypeChangeTombstoneProduced = "CHANGE_TOMBSTONE_PRODUCED"
+
+	EventTypeRepartitionProduced = "REPARTITION_PRODUCED"
+	EventTypeRepartitionConsumed = "REPARTITION_CONSUMED"
+	EventTypeChangelogProduced   = "CHANGELOG_PRODUCED"
+
+	EventTypeStressRecordProduced = "STRESS_RECORD_PRODUCED"
 )
 
+// paymentEventTypeMetricLabels maps each payment event type to the event type
+// label that is used in the metrics.
+var paymentEventTypeMetricLabels = map[fake.PaymentEventType]string{
+	fake.PaymentEventTypeAuthorized: EventTypePaymentAuthorized,
+	fake.PaymentEventTypeCaptured:   EventTypePaymentCaptured,
+	fake.PaymentEventTypeDeclined:   EventTypePaymentDeclined,
+	fake.PaymentEventTypeRefunded:   EventTypePaymentRefunded,
+}
+
+// inventoryEventTypeMetricLabels maps each inventory event type to the event
+// type label that is used in the metrics.
+var inventoryEventTypeMetricLabels = map[fake.InventoryEventType]string{
+	fake.InventoryEventTypeStockReserved:    EventTypeStockReserved,
+	fake.InventoryEventTypeStockReleased:    EventTypeStockReleased,
+	fake.InventoryEventTypeStockTransferred: EventTypeStockTransferred,
+	fake.InventoryEventTypeStockOut:         EventTypeStockOut,
+	fake.InventoryEventTypeStockRestocked:   EventTypeStockRestocked,
+}
+
+// priceChangeTypeMetricLabels maps each price change type to the event type
+// label that is used in the metrics.
+var priceChangeTypeMetricLabels = map[fake.PriceChangeType]string{
+	fake.PriceChangeTypeUpdated:          EventTypePriceUpdated,
+	fake.PriceChangeTypePromotionStarted: EventTypePromotionStarted,
+	fake.PriceChangeTypePromotionEnded:   EventTypePromotionEnded,
+}
+
+// invoiceEventTypeMetricLabels maps each invoice event type to the event type
+// label that is used in the metrics.
+var invoiceEventTypeMetricLabels = map[fake.InvoiceEventType]string{
+	fake.InvoiceEventTypeIssued:  EventTypeInvoiceIssued,
+	fake.InvoiceEventTypeOverdue: EventTypeInvoiceOverdue,
+	fake.InvoiceEventTypePaid:    EventTypeInvoicePaid,
+}
+
+// orderEventTypeMetricLabels maps each order event type to the event type
+// label that is used in the metrics.
+var orderEventTypeMetricLabels = map[fake.OrderEventType]string{
+	fake.OrderEventTypeCreated:   EventTypeOrderLifecycleCreated,
+	fake.OrderEventTypeConfirmed: EventTypeOrderLifecycleConfirmed,
+	fake.OrderEventTypePacked:    EventTypeOrderLifecyclePacked,
+	fake.OrderEventTypeShipped:   EventTypeOrderLifecycleShipped,
+	fake.OrderEventTypeCancelled: EventTypeOrderLifecycleCancelled,
+}
+
+// orderCompensationTypeMetricLabels maps each order compensation type to the
+// event type label that is used in the metrics.
+var orderCompensationTypeMetricLabels = map[fake.OrderCompensationType]string{
+	fake.OrderCompensationTypeCancelled: EventTypeOrderCancelled,
+	fake.OrderCompensationTypeRefunded:  EventTypeOrderRefunded,
+}
+
+// shipmentEventTypeMetricLabels maps each shipment event type to the event type
+// label that is used in the metrics.
+var shipmentEventTypeMetricLabels = map[fake.ShipmentEventType]string{
+	fake.ShipmentEventTypeLabelCreated: EventTypeShipmentLabelCreated,
+	fake.ShipmentEventTypePickedUp:     EventTypeShipmentPickedUp,
+	fake.ShipmentEventTypeInTransit:    EventTypeShipmentInTransit,
+	fake.ShipmentEventTypeDelivered:    EventTypeShipmentDelivered,
+}
+
+// returnEventTypeMetricLabels maps each return event type to the event type
+// label that is used in the metrics.
+var returnEventTypeMetricLabels = map[fake.ReturnEventType]string{
+	fake.ReturnEventTypeRequested:    EventTypeReturnRequested,
+	fake.ReturnEventTypeReceived:     EventTypeReturnReceived,
+	fake.ReturnEventTypeRefundIssued: EventTypeReturnRefundIssued,
+}
+
+// supportTicketEventTypeMetricLabels maps each support ticket event type to the
+// event type label that is used in the metrics.
+var supportTicketEventTypeMetricLabels = map[fake.SupportTicketEventType]string{
+	fake.SupportTicketEventTypeOpened:   EventTypeSupportTicketOpened,
+	fake.SupportTicketEventTypeReplied:  EventTypeSupportTicketReplied,
+	fake.SupportTicketEventTypeResolved: EventTypeSupportTicketResolved,
+}
+
+// notificationStatusMetricLabels maps each notification status to the event
+// type label that is used in the metrics.
+var notificationStatusMetricLabels = map[fake.NotificationStatus]string{
+	fake.NotificationStatusQueued:    EventTypeNotificationQueued,
+	fake.NotificationStatusSent:      EventTypeNotificationSent,
+	fake.NotificationStatusDelivered: EventTypeNotificationDelivered,
+	fake.NotificationStatusBounced:   EventTypeNotificationBounced,
+	fake.NotificationStatusFailed:    EventTypeNotificationFailed,
+}
+
+// cartEventTypeMetricLabels maps each cart event type to the event type label
+// that is used in the metrics.
+var cartEventTypeMetricLabels = map[fake.CartEventType]string{
+	fake.CartEventTypeCreated:     EventTypeCartCreated,
+	fake.CartEventTypeItemAdded:   EventTypeCartItemAdded,
+	fake.CartEventTypeItemRemoved: EventTypeCartItemRemoved,
+	fake.CartEventTypeCheckedOut:  EventTypeCartCheckedOut,
+	fake.CartEventTypeAbandoned:   EventTypeCartAbandoned,
+}
+
 var (
 	promNamespace = "owl_shop"
 
@@ -27,6 +219,42 @@ var (
 		Help:      "The number of page impressions simulated",
 	})
 
+	pageImpressionsQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: promNamespace,
+		Name:      "impressions_queued",
+		Help:      "The number of page impressions that wait for an idle worker",
+	})
+
+	pageImpressionsInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: promNamespace,
+		Name:      "impressions_in_progress",
+		Help:      "The number of page impressions that are simulated by a worker",
+	})
+
+	pageImpressionsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "impressions_dropped_total",
+		Help:      "The number of page impressions that have been dropped, because the queue was full",
+	})
+
+	followUpsDroppedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "follow_ups_dropped_total",
+		Help:      "The number of scheduled follow-up events, e.g. invoice payments, that have been dropped by their kind, because the buffer of pending follow-ups was full",
+	}, []string{"kind"})
+
+	pageImpressionsDelayed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "impressions_delayed_total",
+		Help:      "The number of page impressions that have been delayed, because the queue was full",
+	})
+
+	pageImpressionsDelaySeconds = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "impressions_delay_seconds_total",
+		Help:      "The total duration for which page impressions have been delayed, because the queue was full",
+	})
+
 	kafkaMessagesProducedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: promNamespace,
 		Name:      "kafka_messages_produced_total",
@@ -37,4 +265,138 @@ var (
 		Name:      "kafka_messages_consumed_total",
 		Help:      "The number of Kafka messages consumed",
 	}, []string{"event_type"})
+	kafkaTransactionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_transactions_total",
+		Help:      "The number of Kafka transactions by their result (committed or aborted)",
+	}, []string{"result"})
+	kafkaGroupMembersJoinedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_group_members_joined_total",
+		Help:      "The number of extra members that have joined a consumer group to trigger a rebalance",
+	}, []string{"group"})
+
+	kafkaRecordsProducedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_records_produced_total",
+		Help:      "The number of records that have been successfully produced by a service to a topic",
+	}, []string{"service", "topic"})
+	kafkaRecordsConsumedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_records_consumed_total",
+		Help:      "The number of records that have been consumed by a service from a topic",
+	}, []string{"service", "topic"})
+	kafkaProduceLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_produce_latency_seconds",
+		Help:      "The duration from buffering a record until it has been acknowledged by the broker",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"service", "topic"})
+	kafkaProducedBatchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_produced_batches_total",
+		Help:      "The number of record batches that have been written by a service to a topic",
+	}, []string{"service", "topic"})
+	kafkaProducedUncompressedBytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_produced_uncompressed_bytes_total",
+		Help:      "The size of the record batches that have been written by a service to a topic before compression",
+	}, []string{"service", "topic"})
+	kafkaProducedCompressedBytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_produced_compressed_bytes_total",
+		Help:      "The size of the record batches that have been written by a service to a topic after compression",
+	}, []string{"service", "topic"})
+	kafkaDuplicatesProducedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_duplicates_produced_total",
+		Help:      "The number of injected duplicates that have been produced by a service to a topic",
+	}, []string{"service", "topic"})
+	kafkaLateRecordsProducedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_late_records_produced_total",
+		Help:      "The number of late records that have been held back by a service before producing them to a topic",
+	}, []string{"service", "topic"})
+	kafkaClientErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_client_errors_total",
+		Help:      "The number of errors of a service when producing to or fetching from a topic",
+	}, []string{"service", "topic", "operation"})
+	kafkaInjectedProduceRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_injected_produce_retries_total",
+		Help:      "The number of retries of records to a topic after an injected produce failure by the error of the failed attempt",
+	}, []string{"topic", "error"})
+	kafkaInjectedProduceFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_injected_produce_failures_total",
+		Help:      "The number of records to a topic that have failed with an injected error after exhausting their retries",
+	}, []string{"topic", "error"})
+	webhookEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "webhook_events_total",
+		Help:      "The number of produced records that have been sent to the webhook sink by their result (delivered, failed or dropped)",
+	}, []string{"result"})
+	fileSinkRecordsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "file_sink_records_total",
+		Help:      "The number of sampled records that have been passed to the file sink by their result (written, failed or dropped)",
+	}, []string{"result"})
+	fileSinkUploadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "file_sink_uploads_total",
+		Help:      "The number of files of the file sink that have been uploaded to the object storage by their result (uploaded or failed)",
+	}, []string{"result"})
+	outageBufferedRecords = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: promNamespace,
+		Name:      "outage_buffered_records",
+		Help:      "The number of records that are buffered by all clients, but not yet acknowledged or failed",
+	})
+	outageDroppedRecordsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "outage_dropped_records_total",
+		Help:      "The number of records that have been dropped because the buffer of their client was full",
+	})
+	outageActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: promNamespace,
+		Name:      "outage_active",
+		Help:      "Whether the brokers are currently considered unavailable (1) or not (0)",
+	})
+	outageRecoverySeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: promNamespace,
+		Name:      "outage_recovery_seconds",
+		Help:      "The durations from the last acknowledged record before a broker outage until the first acknowledged record after it",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	})
+	verifierRecordsConsumedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "verifier_records_consumed_total",
+		Help:      "The number of records that have been consumed by the verifier",
+	}, []string{"topic"})
+	verifierEndToEndLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: promNamespace,
+		Name:      "verifier_end_to_end_latency_seconds",
+		Help:      "The duration from the timestamp of a record until it has been consumed by the verifier",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+	}, []string{"topic"})
+	verifierOffsetGapsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "verifier_offset_gaps_total",
+		Help:      "The number of times the verifier has consumed a record whose offset is not the successor of the previous record of its partition",
+	}, []string{"topic"})
+	verifierOrderingViolationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "verifier_ordering_violations_total",
+		Help:      "The number of records whose offset or timestamp is lower than the one of the previous record of its partition, by kind (offset or timestamp)",
+	}, []string{"topic", "kind"})
+	verifierIntegrityViolationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "verifier_integrity_violations_total",
+		Help:      "The number of lost, duplicated and reordered records that have been detected by the verifier's sequence numbers, by kind",
+	}, []string{"topic", "kind"})
+	kafkaRecordsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: promNamespace,
+		Name:      "kafka_records_in_flight",
+		Help:      "The number of records of a service that have been buffered, but not yet been acknowledged",
+	}, []string{"service", "topic"})
 )
@@ -0,0 +1,328 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// NotificationService simulates the outbox of a notification system. It
+// consumes the orders, shipments and customers topics and produces an order
+// confirmation for each order, a shipping update for each shipment event and
+// a password reset for a share of the customer changes. Each notification is
+// produced with the status QUEUED first and is followed up by its delivery
+// status over time. All statuses of a notification are keyed by its id.
+type NotificationService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	orderSerde      *TopicSerde
+	shipmentSerde   *TopicSerde
+	customerSerde   *TopicSerde
+
+	// orders are the consumed orders, so that the shipping updates of their
+	// shipments can be addressed to the ordering customer.
+	orders *orderBook
+
+	pendingNotifications *followUps[fake.Notification]
+
+	topicNameOrders    string
+	topicNameShipments string
+	topicNameCustomers string
+	topicName          string
+}
+
+// NewNotificationService creates a new NotificationService.
+func NewNotificationService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
+) (*NotificationService, error) {
+	clientID := cfg.Services.Notification.ClientIDFor(cfg.GlobalPrefix, "notification-service")
+	metrics := newClientMetrics("notification_service")
+	headers := newRecordHeaders(cfg.Headers, "notification-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "notification-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Notification.Offsets, logger)
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("notification-service")),
+			kgo.ConsumeTopics(cfg.TopicName("orders"), cfg.TopicName("shipments"), cfg.TopicName("customers")),
+			readCommittedOrders(),
+		)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	return &NotificationService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "notification_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Notification.SlowConsumer),
+		offsets:         offsets,
+		orderSerde:      serdes.Orders,
+		shipmentSerde:   serdes.Shipments,
+		customerSerde:   serdes.Customers,
+
+		orders:               newOrderBook(cfg.RecentOrders),
+		pendingNotifications: newFollowUps[fake.Notification](clock, logger, "notification", cfg.PendingFollowUps),
+
+		topicNameOrders:    cfg.TopicName("orders"),
+		topicNameShipments: cfg.TopicName("shipments"),
+		topicNameCustomers: cfg.TopicName("customers"),
+		topicName:          cfg.TopicName("notifications"),
+	}, nil
+}
+
+// Initialize notification service by reconciling the notifications topic.
+func (svc *NotificationService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing notification service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized notification service")
+
+	return nil
+}
+
+// Close stops consuming, flushes all buffered records and closes the Kafka
+// clients.
+func (svc *NotificationService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// Start consuming the orders, shipments and customers and queue their
+// notifications. The delivery statuses of the pending notifications are
+// advanced in the background until the consumer has been closed.
+func (svc *NotificationService) Start() {
+	defer close(svc.consumerStopped)
+
+	quit := make(chan struct{})
+	advanceStopped := make(chan struct{})
+	go func() {
+		defer close(advanceStopped)
+		svc.pendingNotifications.run(quit, svc.advanceNotification)
+	}()
+	defer func() {
+		close(quit)
+		<-advanceStopped
+	}()
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			if rec.Value == nil {
+				return
+			}
+
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			defer span.End()
+			if notification, ok := svc.notificationOf(rec); ok {
+				svc.queue(ctx, notification)
+			}
+		})
+	}
+}
+
+// notificationOf returns the notification that is triggered by the given
+// record of one of the consumed topics. It returns false if the record
+// doesn't trigger a notification.
+func (svc *NotificationService) notificationOf(rec *kgo.Record) (fake.Notification, bool) {
+	switch rec.Topic {
+	case svc.topicNameOrders:
+		kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeOrderConsumed}).Inc()
+		order := fake.Order{}
+		if err := svc.orderSerde.Decode(rec.Value, &order); err != nil {
+			// Skip message
+			svc.logger.Warn("failed to deserialize order", zap.Error(err))
+			return fake.Notification{}, false
+		}
+		svc.orders.put(order)
+		return fake.NewOrderConfirmation(order, svc.clock.now()), true
+	case svc.topicNameShipments:
+		kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeShipmentConsumed}).Inc()
+		event := fake.ShipmentEvent{}
+		if err := svc.shipmentSerde.Decode(rec.Value, &event); err != nil {
+			// Skip message
+			svc.logger.Warn("failed to deserialize shipment event", zap.Error(err))
+			return fake.Notification{}, false
+		}
+		// Shipments of orders that haven't been consumed yet are not notified
+		order, ok := svc.orders.get(event.Shipment.OrderID)
+		if !ok {
+			return fake.Notification{}, false
+		}
+		return fake.NewShippingUpdate(order.Customer, event, svc.clock.now()), true
+	case svc.topicNameCustomers:
+		kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeCustomerConsumed}).Inc()
+		if rand.Float64() >= svc.cfg.Notifications.PasswordResetRatio {
+			return fake.Notification{}, false
+		}
+		customer := fake.Customer{}
+		if err := svc.customerSerde.Decode(rec.Value, &customer); err != nil {
+			// Skip message
+			svc.logger.Warn("failed to deserialize customer", zap.Error(err))
+			return fake.Notification{}, false
+		}
+		return fake.NewPasswordReset(customer, svc.clock.now()), true
+	}
+
+	return fake.Notification{}, false
+}
+
+// queue produces the given notification with its initial status and tracks
+// it until its delivery status is final. If too many notifications are
+// pending, the notification remains queued.
+func (svc *NotificationService) queue(ctx context.Context, notification fake.Notification) {
+	if err := svc.produceNotification(ctx, notification); err != nil {
+		svc.logger.Warn("failed to produce notification", zap.Error(err))
+		return
+	}
+
+	svc.pendingNotifications.schedule(ctx, notification, notification.UpdatedAt.Add(svc.nextStatusDelay()))
+}
+
+// advanceNotification produces the next delivery status of the given pending
+// notification, which is due. Notifications whose status is not final yet are
+// scheduled again.
+func (svc *NotificationService) advanceNotification(ctx context.Context, notification fake.Notification, now time.Time) {
+	notification.Status = svc.nextStatus(notification.Status)
+	notification.UpdatedAt = now
+	if err := svc.produceNotification(ctx, notification); err != nil {
+		svc.logger.Warn("failed to produce notification", zap.Error(err))
+	}
+
+	if notification.Status == fake.NotificationStatusSent {
+		svc.pendingNotifications.schedule(ctx, notification, now.Add(svc.nextStatusDelay()))
+	}
+}
+
+// nextStatus returns the delivery status that follows the given one. Queued
+// notifications are sent or fail, sent ones are delivered or bounce.
+func (svc *NotificationService) nextStatus(status fake.NotificationStatus) fake.NotificationStatus {
+	cfg := svc.cfg.Notifications
+	if status == fake.NotificationStatusQueued {
+		if rand.Float64() < cfg.FailureRatio {
+			return fake.NotificationStatusFailed
+		}
+		return fake.NotificationStatusSent
+	}
+
+	if rand.Float64() < cfg.BounceRatio {
+		return fake.NotificationStatusBounced
+	}
+	return fake.NotificationStatusDelivered
+}
+
+// nextStatusDelay returns a random duration between the configured min and
+// max status delay.
+func (svc *NotificationService) nextStatusDelay() time.Duration {
+	spread := svc.cfg.Notifications.MaxStatusDelay - svc.cfg.Notifications.MinStatusDelay
+	if spread <= 0 {
+		return svc.cfg.Notifications.MinStatusDelay
+	}
+	return svc.cfg.Notifications.MinStatusDelay + time.Duration(rand.Int63n(int64(spread)))
+}
+
+func (svc *NotificationService) produceNotification(ctx context.Context, notification fake.Notification) error {
+	serialized, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to serialize notification struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, notification.ID, notification.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "status", Value: []byte(notification.Status)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	eventType := notificationStatusMetricLabels[notification.Status]
+	svc.producer.Produce(withEventType(ctx, eventType), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+
+	return nil
+}
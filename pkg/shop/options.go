@@ -0,0 +1,209 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// Option configures a shop that is created by New.
+type Option func(*options)
+
+// options are the dependencies of a shop that can be injected by embedding
+// programs. The zero value uses the defaults of the owl-shop binary.
+type options struct {
+	cfg    config.Config
+	logger *zap.Logger
+	clock  Clock
+
+	// kafkaOpts are added to all Kafka clients of the shop.
+	kafkaOpts []kgo.Opt
+	// generators are generator factories that take precedence over the
+	// registered ones.
+	generators map[string]GeneratorFactory
+	// mux is the mux on which the admin routes and health probes are
+	// registered, if not nil.
+	mux *http.ServeMux
+}
+
+// WithConfig replaces the config, including the changes of all previous
+// options. The config is expected to have its defaults set, see
+// config.Config.SetDefaults.
+func WithConfig(cfg config.Config) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithBrokers sets the seed brokers of the default Kafka cluster.
+func WithBrokers(brokers ...string) Option {
+	return func(o *options) {
+		o.cfg.Kafka.Brokers = brokers
+	}
+}
+
+// WithShopConfig changes the shop config, e.g. to set event weights or enable
+// optional features.
+func WithShopConfig(fn func(cfg *config.Shop)) Option {
+	return func(o *options) {
+		fn(&o.cfg.Shop)
+	}
+}
+
+// WithEventsPerSecond sets the number of simulated page impressions per
+// second.
+func WithEventsPerSecond(eventsPerSecond float64) Option {
+	return func(o *options) {
+		o.cfg.Shop.EventsPerSecond = eventsPerSecond
+	}
+}
+
+// WithMaxEvents makes Start return after the given number of page
+// impressions has been simulated.
+func WithMaxEvents(maxEvents int) Option {
+	return func(o *options) {
+		o.cfg.Shop.MaxEvents = maxEvents
+	}
+}
+
+// WithLogger sets the logger of the shop. Defaults to a no-op logger.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithClock sets the clock that provides the timestamps of the produced
+// records and drives the delays of the simulation. Defaults to the system
+// clock. A ManualClock lets tests advance the simulated time
+// deterministically.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithTimeAcceleration sets the factor by which the simulated time passes
+// faster than the clock, see config.Shop.TimeAcceleration.
+func WithTimeAcceleration(factor float64) Option {
+	return func(o *options) {
+		o.cfg.Shop.TimeAcceleration = factor
+	}
+}
+
+// WithKafkaOpts adds the given options to all Kafka clients of the shop, e.g.
+// a custom dialer or additional hooks.
+func WithKafkaOpts(opts ...kgo.Opt) Option {
+	return func(o *options) {
+		o.kafkaOpts = append(o.kafkaOpts, opts...)
+	}
+}
+
+// WithGenerator adds an event generator with the given weight to the shop
+// without registering it globally via RegisterGenerator.
+func WithGenerator(name string, weight uint, factory GeneratorFactory) Option {
+	return func(o *options) {
+		if o.generators == nil {
+			o.generators = make(map[string]GeneratorFactory)
+		}
+		o.generators[name] = factory
+
+		generators := make(map[string]config.Generator, len(o.cfg.Shop.Generators)+1)
+		for key, generator := range o.cfg.Shop.Generators {
+			generators[key] = generator
+		}
+		generator := generators[name]
+		generator.Weight = weight
+		generators[name] = generator
+		o.cfg.Shop.Generators = generators
+	}
+}
+
+// WithAdminMux registers the admin API routes and the health probes on the
+// given mux, which is served by the embedding program. No HTTP listeners are
+// started by New.
+func WithAdminMux(mux *http.ServeMux) Option {
+	return func(o *options) {
+		o.mux = mux
+	}
+}
+
+// New creates a single shop for embedding it in other Go programs, e.g. in
+// test harnesses, and initializes its components. Unlike NewRunner, it does
+// not start any HTTP listeners. Without options, the shop uses the defaults
+// of the owl-shop config and produces to localhost:9092:
+//
+//	s, err := shop.New(shop.WithBrokers("localhost:9092"), shop.WithMaxEvents(1000))
+//	if err != nil {
+//		return err
+//	}
+//	err = s.Start()
+//	_ = s.Stop(context.Background())
+//
+// Profiles and tenants are not supported, they are run by NewRunner. The
+// global random source, which is shared with the embedding program, is only
+// seeded if the shop config has a seed.
+func New(opts ...Option) (*Shop, error) {
+	o := options{logger: zap.NewNop()}
+	o.cfg.SetDefaults()
+	o.cfg.Kafka.Brokers = []string{"localhost:9092"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(o.cfg.Profiles) > 0 || len(o.cfg.Shop.Tenants) > 0 {
+		return nil, fmt.Errorf("profiles and tenants are not supported by New, use NewRunner instead")
+	}
+	if err := o.cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+
+	// Like NewRunner, see there. The global random source is shared with the
+	// embedding program, so it is only seeded if a seed has been configured
+	if o.cfg.Shop.Seed != 0 {
+		gofakeit.Seed(o.cfg.Shop.Seed)
+	}
+
+	kafkaFactories, err := kafka.NewFactories(o.cfg.Kafka, o.logger.Named("kafka_client"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka factories: %w", err)
+	}
+	for name, factory := range kafkaFactories {
+		kafkaFactories[name] = factory.WithOpts(o.kafkaOpts...)
+	}
+	health, err := newHealthChecker(o.cfg.Shop.GlobalPrefix+"health-check", o.logger.Named("health"), kafkaFactories)
+	if err != nil {
+		return nil, err
+	}
+	if o.mux != nil {
+		health.registerRoutes(o.mux)
+	}
+
+	s, err := newShop(o.cfg, "", o.logger, health, o.mux, o)
+	if err != nil {
+		health.close()
+		return nil, err
+	}
+	s.ownsHealth = true
+
+	if err := s.startInitialization(); err != nil {
+		// The services' clients, the sinks and the health checker are
+		// closed, as the shop must not be started
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if closeErr := s.closeServices(ctx); closeErr != nil {
+			o.logger.Warn("failed to close shop after failed initialization", zap.Error(closeErr))
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,75 @@
+package shop
+
+import (
+	"sync"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// orderBook tracks the most recently placed orders by their ID, e.g. so that
+// they can be cancelled on demand. Once it is full, the oldest order is
+// dropped.
+type orderBook struct {
+	maxSize int
+
+	mu     sync.Mutex
+	orders map[string]fake.Order
+	// orderIDs are the keys of orders in the order they have been placed.
+	orderIDs []string
+}
+
+func newOrderBook(maxSize int) *orderBook {
+	return &orderBook{
+		maxSize:  maxSize,
+		orders:   make(map[string]fake.Order, maxSize),
+		orderIDs: make([]string, 0, maxSize),
+	}
+}
+
+// put tracks the given placed order.
+func (b *orderBook) put(order fake.Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.orders[order.ID]; ok {
+		b.orders[order.ID] = order
+		return
+	}
+	for len(b.orderIDs) >= b.maxSize {
+		delete(b.orders, b.orderIDs[0])
+		b.orderIDs = b.orderIDs[1:]
+	}
+	b.orderIDs = append(b.orderIDs, order.ID)
+	b.orders[order.ID] = order
+}
+
+// remove removes the order with the given ID and returns it. It returns false
+// if the order is not tracked.
+func (b *orderBook) remove(orderID string) (fake.Order, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	order, ok := b.orders[orderID]
+	if !ok {
+		return fake.Order{}, false
+	}
+	delete(b.orders, orderID)
+	for i, id := range b.orderIDs {
+		if id == orderID {
+			b.orderIDs = append(b.orderIDs[:i], b.orderIDs[i+1:]...)
+			break
+		}
+	}
+
+	return order, true
+}
+
+// get returns the order with the given ID. It returns false if the order is
+// not tracked.
+func (b *orderBook) get(orderID string) (fake.Order, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	order, ok := b.orders[orderID]
+	return order, ok
+}
@@ -0,0 +1,73 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// CancelOrder cancels the recently placed order with the given ID, e.g. to
+// trigger a cancellation on demand. If the order lifecycle is enabled, the
+// order's cancelled event is produced, unless its lifecycle has reached a
+// final state already. If order compensations are enabled, its cancellation
+// is produced right away, replacing a scheduled compensation. It returns the
+// cancelled order.
+func (svc *OrderService) CancelOrder(orderID string) (fake.Order, error) {
+	if !svc.cfg.OrderLifecycle.Enabled && !svc.cfg.OrderCompensations.Enabled() {
+		return fake.Order{}, fmt.Errorf("%w: cancelling orders requires the order lifecycle or order compensations", errConflict)
+	}
+
+	svc.cancelMu.Lock()
+	defer svc.cancelMu.Unlock()
+
+	order, ok := svc.orders.get(orderID)
+	if !ok {
+		return fake.Order{}, fmt.Errorf("%w: order '%v' has not been placed recently or has been cancelled already", errNotFound, orderID)
+	}
+
+	ctx, span := startTrace(context.Background(), svc.tracer, "cancel order")
+	defer span.End()
+
+	if svc.cfg.OrderLifecycle.Enabled {
+		if err := svc.cancelOrderLifecycle(ctx, orderID); err != nil {
+			return fake.Order{}, err
+		}
+	}
+
+	if svc.cfg.OrderCompensations.Enabled() {
+		svc.pendingCompensations.remove(func(pending pendingCompensation) bool {
+			return pending.order.ID == orderID
+		})
+
+		compensation := fake.NewOrderCompensation(order, fake.OrderCompensationTypeCancelled, svc.clock.now())
+		compensation.Reason = "CUSTOMER_REQUEST"
+		if err := svc.produceCompensation(ctx, compensation); err != nil {
+			return fake.Order{}, fmt.Errorf("failed to produce order compensation: %w", err)
+		}
+		svc.cdcRows.update(ctx, orderID, string(compensation.Type))
+	}
+
+	// The order can only be cancelled again if producing its events has failed
+	svc.orders.remove(orderID)
+
+	return order, nil
+}
+
+// cancelOrderLifecycle produces the cancelled event of the order with the
+// given ID and stops tracking its lifecycle.
+func (svc *OrderService) cancelOrderLifecycle(ctx context.Context, orderID string) error {
+	pending, ok := svc.pendingOrders.remove(func(pending pendingOrder) bool {
+		return pending.orderID == orderID
+	})
+	if !ok {
+		return fmt.Errorf("%w: the lifecycle of order '%v' has reached a final state already", errConflict, orderID)
+	}
+
+	event := fake.NewOrderEvent(orderID, fake.OrderEventTypeCancelled, pending.sequence+1, svc.clock.now())
+	if err := svc.produceOrderEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to produce order event: %w", err)
+	}
+	svc.cdcRows.update(ctx, orderID, cdcOrderStatus(event.Type))
+	return nil
+}
@@ -0,0 +1,82 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// pendingCompensation is a placed order that will be cancelled or refunded
+// once it is due.
+type pendingCompensation struct {
+	order            fake.Order
+	compensationType fake.OrderCompensationType
+}
+
+// scheduleCompensation decides whether the given placed order is cancelled or
+// refunded later on, as configured, and tracks the order until then. Orders
+// are not compensated once the buffer is full.
+func (svc *OrderService) scheduleCompensation(ctx context.Context, order fake.Order) {
+	cfg := svc.cfg.OrderCompensations
+
+	var compensationType fake.OrderCompensationType
+	switch r := rand.Float64(); {
+	case r < cfg.CancelRatio:
+		compensationType = fake.OrderCompensationTypeCancelled
+	case r < cfg.CancelRatio+cfg.RefundRatio:
+		compensationType = fake.OrderCompensationTypeRefunded
+	default:
+		return
+	}
+
+	svc.pendingCompensations.schedule(ctx, pendingCompensation{
+		order:            order,
+		compensationType: compensationType,
+	}, svc.clock.now().Add(randomDelay(cfg.MinDelay, cfg.MaxDelay)))
+}
+
+// compensateOrder produces the compensation of the pending order that is due.
+func (svc *OrderService) compensateOrder(ctx context.Context, pending pendingCompensation, now time.Time) {
+	compensation := fake.NewOrderCompensation(pending.order, pending.compensationType, now)
+	if err := svc.produceCompensation(ctx, compensation); err != nil {
+		svc.logger.Warn("failed to produce order compensation", zap.Error(err))
+		return
+	}
+	svc.cdcRows.update(ctx, pending.order.ID, string(compensation.Type))
+}
+
+func (svc *OrderService) produceCompensation(ctx context.Context, compensation fake.OrderCompensation) error {
+	serialized, err := json.Marshal(compensation)
+	if err != nil {
+		return fmt.Errorf("failed to serialize order compensation struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameCompensations, compensation.OrderID, compensation.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "compensation_type", Value: []byte(compensation.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameCompensations,
+	}
+
+	metricLabel := orderCompensationTypeMetricLabels[compensation.Type]
+	svc.producer.Produce(withEventType(ctx, metricLabel), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": metricLabel}).Inc()
+
+	return nil
+}
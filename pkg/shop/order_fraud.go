@@ -0,0 +1,77 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// placeScoredOrder places the given order and produces its fraud signal. A
+// share of the orders is made suspicious on purpose, either by an unusually
+// high order value, a country mismatch or by placing several orders of the
+// same customer in quick succession.
+func (svc *OrderService) placeScoredOrder(ctx context.Context, order fake.Order) {
+	cfg := svc.cfg.Fraud
+
+	var reasons []fake.FraudReason
+	if rand.Float64() < cfg.SuspiciousRatio {
+		reasons = []fake.FraudReason{fake.FraudReasons[rand.Intn(len(fake.FraudReasons))]}
+	}
+
+	orders := []fake.Order{order}
+	for _, reason := range reasons {
+		switch reason {
+		case fake.FraudReasonHighOrderValue:
+			order.MultiplyQuantities(gofakeit.Number(10, 50))
+			orders[0] = order
+		case fake.FraudReasonRapidFire:
+			for i := 1; i < cfg.RapidFireOrders; i++ {
+				products := svc.productCatalog.RandomProducts(gofakeit.Number(8, 45))
+				orders = append(orders, fake.NewOrder(order.Customer, products, svc.productCatalog.pricing, svc.clock.now()))
+			}
+		}
+	}
+
+	for _, order := range orders {
+		if !svc.PlaceOrder(ctx, &order) {
+			continue
+		}
+		if err := svc.produceFraudSignal(ctx, fake.NewFraudSignal(order, reasons, svc.clock.now())); err != nil {
+			svc.logger.Warn("failed to produce fraud signal", zap.Error(err))
+		}
+	}
+}
+
+func (svc *OrderService) produceFraudSignal(ctx context.Context, signal fake.FraudSignal) error {
+	serialized, err := json.Marshal(signal)
+	if err != nil {
+		return fmt.Errorf("failed to serialize fraud signal struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameFraudSignals, signal.OrderID, signal.CustomerID),
+		Value:     serialized,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameFraudSignals,
+	}
+
+	svc.producer.Produce(withEventType(ctx, EventTypeFraudSignalCreated), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeFraudSignalCreated}).Inc()
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// pendingOrder is an order whose lifecycle has not reached a final state yet.
+type pendingOrder struct {
+	orderID string
+	state   fake.OrderEventType
+	// sequence is the position of the last produced event of the order.
+	sequence int
+}
+
+// startOrderLifecycle produces the created event of the given order and tracks
+// the order until its lifecycle has reached a final state. Orders are not
+// tracked once the buffer is full.
+func (svc *OrderService) startOrderLifecycle(ctx context.Context, order fake.Order) {
+	now := svc.clock.now()
+	event := fake.NewOrderCreatedEvent(order, now)
+	if err := svc.produceOrderEvent(ctx, event); err != nil {
+		svc.logger.Warn("failed to produce order event", zap.Error(err))
+		return
+	}
+
+	svc.pendingOrders.schedule(ctx, pendingOrder{
+		orderID:  order.ID,
+		state:    event.Type,
+		sequence: event.Sequence,
+	}, now.Add(svc.nextLifecycleStepDelay()))
+}
+
+// advanceOrderLifecycle produces the next event of the pending order that is
+// due. Orders that have not been shipped or cancelled are scheduled again.
+func (svc *OrderService) advanceOrderLifecycle(ctx context.Context, pending pendingOrder, now time.Time) {
+	pending.state = svc.nextOrderState(pending.state)
+	pending.sequence++
+	event := fake.NewOrderEvent(pending.orderID, pending.state, pending.sequence, now)
+	if err := svc.produceOrderEvent(ctx, event); err != nil {
+		svc.logger.Warn("failed to produce order event", zap.Error(err))
+	} else {
+		svc.cdcRows.update(ctx, pending.orderID, cdcOrderStatus(pending.state))
+	}
+
+	if pending.state != fake.OrderEventTypeShipped && pending.state != fake.OrderEventTypeCancelled {
+		svc.pendingOrders.schedule(ctx, pending, now.Add(svc.nextLifecycleStepDelay()))
+	}
+}
+
+// nextOrderState returns the state that follows the given non-final state. The
+// order advances with the configured ratio and is cancelled otherwise.
+func (svc *OrderService) nextOrderState(state fake.OrderEventType) fake.OrderEventType {
+	cfg := svc.cfg.OrderLifecycle
+
+	next, ratio := fake.OrderEventTypeShipped, cfg.ShipRatio
+	switch state {
+	case fake.OrderEventTypeCreated:
+		next, ratio = fake.OrderEventTypeConfirmed, cfg.ConfirmRatio
+	case fake.OrderEventTypeConfirmed:
+		next, ratio = fake.OrderEventTypePacked, cfg.PackRatio
+	}
+
+	if rand.Float64() < ratio {
+		return next
+	}
+	return fake.OrderEventTypeCancelled
+}
+
+// nextLifecycleStepDelay returns a random duration between the configured min
+// and max step delay.
+func (svc *OrderService) nextLifecycleStepDelay() time.Duration {
+	return randomDelay(svc.cfg.OrderLifecycle.MinStepDelay, svc.cfg.OrderLifecycle.MaxStepDelay)
+}
+
+// produceOrderEvent produces the event keyed by its order ID. Its type is set
+// in the order_event_type header, so that the event_type header of the
+// configured headers carries the metric label like on all other topics.
+func (svc *OrderService) produceOrderEvent(ctx context.Context, event fake.OrderEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize order event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameOrderEvents, event.OrderID, ""),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "order_event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameOrderEvents,
+	}
+
+	metricLabel := orderEventTypeMetricLabels[event.Type]
+	svc.producer.Produce(withEventType(ctx, metricLabel), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": metricLabel}).Inc()
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// applyPromoCodes redeems the promo code of each active sale for the given
+// order, in accordance with the sale's redemption ratio, and subtracts the
+// discounts from the order value. Discounts of several promo codes apply one
+// after another. It returns the usages of the redeemed promo codes.
+func (svc *OrderService) applyPromoCodes(order *fake.Order) []fake.PromoCodeUsage {
+	var usages []fake.PromoCodeUsage
+	for _, sale := range svc.sales.active() {
+		if sale.PromoCode == "" || gofakeit.Float64Range(0, 1) >= sale.RedemptionRatio {
+			continue
+		}
+		usage := fake.NewPromoCodeUsage(*order, sale.Name, sale.PromoCode, sale.DiscountPercent, svc.clock.now())
+		order.OrderValue -= usage.DiscountValue
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// redeemPromoCodes produces the given promo code usages of a placed order.
+func (svc *OrderService) redeemPromoCodes(ctx context.Context, usages []fake.PromoCodeUsage) {
+	for _, usage := range usages {
+		if err := svc.producePromoCodeUsage(ctx, usage); err != nil {
+			svc.logger.Warn("failed to produce promo code usage", zap.Error(err))
+		}
+	}
+}
+
+func (svc *OrderService) producePromoCodeUsage(ctx context.Context, usage fake.PromoCodeUsage) error {
+	serialized, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("failed to serialize promo code usage struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNamePromoCodeUsages, usage.PromoCode, usage.CustomerID),
+		Value:     serialized,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNamePromoCodeUsages,
+	}
+
+	svc.producer.Produce(withEventType(ctx, EventTypePromoCodeRedeemed), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypePromoCodeRedeemed}).Inc()
+
+	return nil
+}
@@ -3,15 +3,16 @@ package shop
 import (
 	"context"
 	_ "embed"
-	"encoding/json"
 	"fmt"
+	"math/rand"
 	"sync"
-	"time"
 
+	"github.com/brianvoe/gofakeit/v5"
 	"github.com/hamba/avro"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/sr"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
 
@@ -27,24 +28,68 @@ import (
 // When a new customer order is received this service will produce a message
 // on the order topics in different formats (JSON and Protobuf).
 // Because orders belong to a customer, this service also consumes the customers
-// topic
+// topic and tracks the existing customers in a registry, from which the
+// customers of new orders are picked. Line items reference products from the
+// product catalog.
 type OrderService struct {
 	cfg    config.Shop
 	logger *zap.Logger
-
-	kafkaFactory   *kafka.Factory
-	consumerClient *kgo.Client
-	metaClient     *kgo.Client
-	srClient       *sr.Client
-
-	bufferSize        int
-	recentCustomersMu sync.RWMutex
-	recentCustomers   []fake.Customer
-
-	topicName              string
-	topicNameProtobufPlain string
-	topicNameProtobufSr    string
-	topicNameAvroSr        string
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	metaClient      *kgo.Client
+	producer        recordProducer
+	// txnClient is the transactional producer, which is only set if the
+	// transactional mode is enabled. Only one transaction can be in flight
+	// at a time. The records of the transactions are produced by
+	// txnProducer, which drops them like the producer.
+	txnClient     *kgo.Client
+	txnProducer   recordProducer
+	txnMu         sync.Mutex
+	srClient      *sr.Client
+	serde         *TopicSerde
+	customerSerde *TopicSerde
+	cdc           *cdcTable
+	cdcRows       *cdcOrderRows
+	// streams is the simulated stream processing application of the orders,
+	// it is only set if it is enabled.
+	streams *streamsApp
+	// sales are the scheduled sales whose promo codes are redeemed by the
+	// orders, it is only set if sales are configured.
+	sales *saleCalendar
+
+	productCatalog *ProductCatalogService
+
+	customers *customerRegistry
+	// orders are the recently placed orders, which are only tracked if they
+	// can be cancelled, see CancelOrder. Cancellations are serialized by
+	// cancelMu, so that an order is cancelled at most once.
+	orders   *orderBook
+	cancelMu sync.Mutex
+
+	// pendingOrders are the orders whose lifecycle has not reached a final
+	// state yet, which are only tracked if the order lifecycle is enabled.
+	pendingOrders *followUps[pendingOrder]
+
+	// pendingCompensations are the placed orders that will be cancelled or
+	// refunded later on.
+	pendingCompensations *followUps[pendingCompensation]
+
+	topicName                 string
+	topicNameCustomerActivity string
+	topicNameProtobufPlain    string
+	topicNameProtobufSr       string
+	topicNameAvroSr           string
+	topicNameOrderEvents      string
+	topicNameCompensations    string
+	topicNameFraudSignals     string
+	topicNamePromoCodeUsages  string
 
 	protobufSerde sr.Serde
 	avroSerde     sr.Serde
@@ -57,56 +102,191 @@ func NewOrderService(
 	logger *zap.Logger,
 	kafkaFactory *kafka.Factory,
 	srClient *sr.Client,
+	serdes *Serdes,
+	productCatalog *ProductCatalogService,
+	tracing *tracing,
+	clock *simulationClock,
 ) (*OrderService, error) {
-	clientID := cfg.GlobalPrefix + "order-service"
-
-	metaClient, err := kafkaFactory.NewKafkaClient(clientID)
+	clientID := cfg.Services.Order.ClientIDFor(cfg.GlobalPrefix, "order-service")
+	metrics := newClientMetrics("order_service")
+	headers := newRecordHeaders(cfg.Headers, "order-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "order-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kafka service: %w", err)
 	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		metaClient.Close()
+		return nil, err
+	}
 
+	offsets := newConsumerOffsets(cfg.Services.Order.Offsets, logger)
 	consumerClient, err := kafkaFactory.NewKafkaClient(
 		clientID,
-		kgo.ConsumerGroup(clientID),
-		kgo.ConsumeTopics(cfg.GlobalPrefix+"customers"),
-		kgo.AutoCommitInterval(500*time.Millisecond),
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("order-service")),
+			kgo.ConsumeTopics(cfg.TopicName("customers")),
+		)...,
 	)
 	if err != nil {
+		metaClient.Close()
 		return nil, fmt.Errorf("failed to create kafka consumer client: %w", err)
 	}
 
-	// This slice is used to keep some customers in the buffer so that they can be modified or deleted
-	bufferSize := 500
-	recentCustomers := make([]fake.Customer, 0, bufferSize)
+	streams, err := newStreamsApp(cfg, logger, kafkaFactory, metrics, metaClient, producer, serdes.Orders, clock)
+	if err != nil {
+		consumerClient.Close()
+		metaClient.Close()
+		return nil, err
+	}
+
+	var txnClient *kgo.Client
+	if cfg.Transactions.Enabled {
+		txnClient, err = kafkaFactory.NewKafkaClient(
+			clientID,
+			cloudEvents.hook(),
+			metrics.hook(),
+			headers.hook(),
+			kgo.TransactionalID(clientID+"-transactional"),
+		)
+		if err != nil {
+			if streams != nil {
+				streams.consumerClient.Close()
+			}
+			consumerClient.Close()
+			metaClient.Close()
+			return nil, fmt.Errorf("failed to create transactional kafka client: %w", err)
+		}
+	}
+
+	cdc := newCDCTable(cfg, logger, clock, metaClient, producer, "orders", cdcOrder{})
 
 	return &OrderService{
 		cfg:    cfg,
 		logger: logger.With(zap.String("service", "order_service")),
-
-		kafkaFactory:   kafkaFactory,
-		consumerClient: consumerClient,
-		metaClient:     metaClient,
-		srClient:       srClient,
-
-		bufferSize:        bufferSize,
-		recentCustomersMu: sync.RWMutex{},
-		recentCustomers:   recentCustomers,
-
-		topicName:              cfg.GlobalPrefix + "orders",
-		topicNameProtobufPlain: cfg.GlobalPrefix + "orders" + "-protobuf-plain",
-		topicNameProtobufSr:    cfg.GlobalPrefix + "orders" + "-protobuf-sr",
-		topicNameAvroSr:        cfg.GlobalPrefix + "orders" + "-avro-sr",
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Order.SlowConsumer),
+		offsets:         offsets,
+		metaClient:      metaClient,
+		producer:        producer,
+		txnClient:       txnClient,
+		txnProducer:     clientProducer(kafkaFactory.Config, txnClient),
+		txnMu:           sync.Mutex{},
+		srClient:        srClient,
+		serde:           serdes.Orders,
+		customerSerde:   serdes.Customers,
+		cdc:             cdc,
+		cdcRows:         newCDCOrderRows(cdc, clock, cfg.RecentOrders),
+		streams:         streams,
+		sales:           newSaleCalendar(cfg.Sales, clock),
+
+		productCatalog: productCatalog,
+
+		customers: newCustomerRegistry(cfg.Customers.RegistrySize, cfg.Customers.Whales),
+		orders:    newOrderBook(cfg.RecentOrders),
+
+		pendingOrders:        newFollowUps[pendingOrder](clock, logger, "order_lifecycle", cfg.PendingFollowUps),
+		pendingCompensations: newFollowUps[pendingCompensation](clock, logger, "order_compensation", cfg.PendingFollowUps),
+
+		topicName:                 cfg.TopicName("orders"),
+		topicNameCustomerActivity: cfg.TopicName("customer-activity"),
+		topicNameProtobufPlain:    cfg.TopicName("orders-protobuf-plain"),
+		topicNameProtobufSr:       cfg.TopicName("orders-protobuf-sr"),
+		topicNameAvroSr:           cfg.TopicName("orders-avro-sr"),
+		topicNameOrderEvents:      cfg.TopicName("order-events"),
+		topicNameCompensations:    cfg.TopicName("order-compensations"),
+		topicNameFraudSignals:     cfg.TopicName("fraud-signals"),
+		topicNamePromoCodeUsages:  cfg.TopicName("promo-code-usages"),
 
 		protobufSerde: sr.Serde{}, // Has to be registered after creating the schema
 	}, nil
 }
 
-// Start starts polling for new messages on the customers topic.
+// Close stops consuming the customers topic, flushes all buffered records and
+// closes the Kafka clients.
+func (svc *OrderService) Close(ctx context.Context) error {
+	svc.throttle.stop()
+	if svc.txnClient != nil {
+		// Wait for the transaction in flight, so that it is not left open
+		svc.txnMu.Lock()
+		svc.txnClient.Close()
+		svc.txnMu.Unlock()
+	}
+	// Close all clients, even if closing any of them fails
+	var streamsErr error
+	if svc.streams != nil {
+		streamsErr = svc.streams.Close(ctx)
+	}
+	if err := closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient); err != nil {
+		return err
+	}
+	return streamsErr
+}
+
+// Start starts polling for new messages on the customers topic. If the order
+// lifecycle or compensations are enabled, pending orders are advanced and
+// compensated in the background until the consumer has been closed. If
+// enabled, the simulated stream processing application consumes the
+// repartitioned orders in the background.
 func (svc *OrderService) Start() {
+	defer close(svc.consumerStopped)
+
+	if svc.streams != nil {
+		go svc.streams.Start()
+	}
+
+	if svc.cfg.OrderLifecycle.Enabled {
+		quit := make(chan struct{})
+		advanceStopped := make(chan struct{})
+		go func() {
+			defer close(advanceStopped)
+			svc.pendingOrders.run(quit, svc.advanceOrderLifecycle)
+		}()
+		defer func() {
+			close(quit)
+			<-advanceStopped
+		}()
+	}
+
+	if svc.cfg.OrderCompensations.Enabled() {
+		quit := make(chan struct{})
+		compensateStopped := make(chan struct{})
+		go func() {
+			defer close(compensateStopped)
+			svc.pendingCompensations.run(quit, svc.compensateOrder)
+		}()
+		defer func() {
+			close(quit)
+			<-compensateStopped
+		}()
+	}
+
 	for {
-		fetches := svc.consumerClient.PollFetches(context.Background())
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
 
 		errors := fetches.Errors()
+		for _, fetchErr := range errors {
+			svc.metrics.fetchError(fetchErr.Topic)
+		}
 		if errors != nil {
 			svc.logger.Warn("failed to poll fetches", zap.Error(errors[0].Err))
 		}
@@ -114,23 +294,22 @@ func (svc *OrderService) Start() {
 		iter := fetches.RecordIter()
 		for !iter.Done() {
 			rec := iter.Next()
+			svc.throttle.wait()
 			kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeCustomerConsumed}).Inc()
 
 			if rec.Value == nil {
+				// Deleted customers must not place any more orders
+				svc.customers.remove(string(rec.Key))
 				continue
 			}
 			customer := fake.Customer{}
-			err := json.Unmarshal(rec.Value, &customer)
+			err := svc.customerSerde.Decode(rec.Value, &customer)
 			if err != nil {
 				// Skip message
 				svc.logger.Warn("failed to deserialize customer", zap.Error(err))
 				continue
 			}
-			svc.recentCustomersMu.Lock()
-			if len(svc.recentCustomers) < svc.bufferSize {
-				svc.recentCustomers = append(svc.recentCustomers, customer)
-			}
-			svc.recentCustomersMu.Unlock()
+			svc.customers.put(customer)
 		}
 	}
 }
@@ -142,35 +321,112 @@ func (svc *OrderService) Initialize(ctx context.Context) error {
 	topicCfg := map[string]*string{
 		"cleanup.policy": kadm.StringPtr("compact"),
 	}
-	err := kafka.ReconcileTopic(ctx,
+	err := reconcileTopic(ctx,
+		svc.cfg,
 		svc.metaClient,
 		svc.topicName,
-		svc.cfg.TopicPartitionCount,
-		svc.cfg.TopicReplicationFactor,
 		topicCfg,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create topic: %w", err)
 	}
 
-	err = kafka.ReconcileTopic(ctx,
+	if svc.txnClient != nil {
+		err = reconcileTopic(ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameCustomerActivity,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create customer activity topic: %w", err)
+		}
+	}
+
+	if svc.cfg.OrderLifecycle.Enabled {
+		err = reconcileTopic(ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameOrderEvents,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create order events topic: %w", err)
+		}
+	}
+
+	if svc.cfg.OrderCompensations.Enabled() {
+		err = reconcileTopic(ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameCompensations,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create order compensations topic: %w", err)
+		}
+	}
+
+	if svc.cfg.Fraud.Enabled {
+		err = reconcileTopic(ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameFraudSignals,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create fraud signals topic: %w", err)
+		}
+	}
+
+	if svc.sales != nil {
+		err = reconcileTopic(ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNamePromoCodeUsages,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create promo code usages topic: %w", err)
+		}
+	}
+
+	err = reconcileTopic(ctx,
+		svc.cfg,
 		svc.metaClient,
 		svc.topicNameProtobufPlain,
-		svc.cfg.TopicPartitionCount,
-		svc.cfg.TopicReplicationFactor,
 		topicCfg,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create protobuf plain topic: %w", err)
 	}
 
+	if err := svc.cdc.Initialize(ctx); err != nil {
+		return err
+	}
+
+	if svc.streams != nil {
+		if err := svc.streams.Initialize(ctx); err != nil {
+			return err
+		}
+	}
+
 	if svc.srClient != nil {
 		// 1. Protobuf Setup
-		if err := kafka.ReconcileTopic(ctx,
+		if err := reconcileTopic(ctx,
+			svc.cfg,
 			svc.metaClient,
 			svc.topicNameProtobufSr,
-			svc.cfg.TopicPartitionCount,
-			svc.cfg.TopicReplicationFactor,
 			topicCfg,
 		); err != nil {
 			return fmt.Errorf("failed to create protobuf sr topic: %w", err)
@@ -191,36 +447,29 @@ func (svc *OrderService) Initialize(ctx context.Context) error {
 		)
 
 		// 2. Avro Setup
-		if err := kafka.ReconcileTopic(ctx,
+		if err := reconcileTopic(ctx,
+			svc.cfg,
 			svc.metaClient,
 			svc.topicNameAvroSr,
-			svc.cfg.TopicPartitionCount,
-			svc.cfg.TopicReplicationFactor,
 			topicCfg,
 		); err != nil {
 			return fmt.Errorf("failed to create avro sr topic: %w", err)
 		}
 
-		orderAvroSchemaID, err := svc.registerAvroSchema(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to register avro schemas in schema registry: %w", err)
-		}
-
 		// Parse all schemas to add them to the global cache
-		avro.DefaultConfig = avro.Config{
-			TagKey: "json",
-		}.Freeze()
-		if _, err := avro.Parse(embedavro.CustomerV2Avro); err != nil {
-			return fmt.Errorf("failed to parse customerV2 avro schema with avro lib: %w", err)
-		}
-		if _, err := avro.Parse(embedavro.AddressAvro); err != nil {
-			return fmt.Errorf("failed to parse address avro schema with avro lib: %w", err)
+		if err := parseAvroReferenceSchemas(); err != nil {
+			return err
 		}
 		orderAvroSchema, err := avro.Parse(embedavro.OrderAvro)
 		if err != nil {
 			return fmt.Errorf("failed to parse order avro schema with avro lib: %w", err)
 		}
 
+		orderAvroSchemaID, err := svc.registerAvroSchema(ctx, orderAvroSchema)
+		if err != nil {
+			return fmt.Errorf("failed to register avro schemas in schema registry: %w", err)
+		}
+
 		svc.avroSerde.Register(
 			orderAvroSchemaID,
 			fake.Order{},
@@ -238,65 +487,18 @@ func (svc *OrderService) Initialize(ctx context.Context) error {
 // If successful, it returns the schema id.
 func (svc *OrderService) registerProtobufSchema(ctx context.Context) (int, error) {
 	// Register dependency schemas first, then main schema with references
-	customerProtoSubject := "shop/v1/customer.proto"
-
-	// This registers an older proto version first, so that we simulate
-	// a schema evolution as well.
-	_, err := svc.srClient.CreateSchema(
-		ctx,
-		customerProtoSubject,
-		sr.Schema{
-			Schema: embedproto.CustomerV1,
-			Type:   sr.TypeProtobuf,
-		},
-	)
-	if err != nil {
-		return -1, fmt.Errorf("failed to register customer schema: %w", err)
-	}
-
-	_, err = svc.srClient.CreateSchema(
-		ctx,
-		customerProtoSubject,
-		sr.Schema{
-			Schema: embedproto.CustomerV2,
-			Type:   sr.TypeProtobuf,
-		},
-	)
-	if err != nil {
-		return -1, fmt.Errorf("failed to register customer schema: %w", err)
-	}
-
-	addressProtoSubject := "shop/v1/address.proto"
-	_, err = svc.srClient.CreateSchema(
-		ctx,
-		addressProtoSubject,
-		sr.Schema{
-			Schema: embedproto.Address,
-			Type:   sr.TypeProtobuf,
-		},
-	)
+	references, err := registerProtobufReferenceSchemas(ctx, svc.srClient)
 	if err != nil {
-		return -1, fmt.Errorf("failed to register address schema: %w", err)
+		return -1, err
 	}
 
 	orderSchema, err := svc.srClient.CreateSchema(
 		ctx,
-		svc.topicNameProtobufSr+"-value",
+		svc.cfg.SubjectName("orders-protobuf-sr", protoRecordName(&shoppb.Order{})),
 		sr.Schema{
-			Schema: embedproto.Order,
-			Type:   sr.TypeProtobuf,
-			References: []sr.SchemaReference{
-				{
-					Name:    customerProtoSubject,
-					Subject: customerProtoSubject,
-					Version: 2,
-				},
-				{
-					Name:    addressProtoSubject,
-					Subject: addressProtoSubject,
-					Version: 1,
-				},
-			},
+			Schema:     embedproto.Order,
+			Type:       sr.TypeProtobuf,
+			References: references,
 		},
 	)
 	if err != nil {
@@ -308,130 +510,200 @@ func (svc *OrderService) registerProtobufSchema(ctx context.Context) (int, error
 
 // registerAvroSchema registers the used avro schemas in the schema registry, so that
 // serialized messages can be deserialized by other tools like Redpanda Console or CLIs.
-// If successful, it returns the schema id.
-func (svc *OrderService) registerAvroSchema(ctx context.Context) (int, error) {
-	// This registers an older proto version first, so that we simulate
-	// a schema evolution as well.
-	customerV1, err := svc.srClient.CreateSchema(
-		ctx,
-		"com.shop.v1.avro.Customer",
-		sr.Schema{
-			Schema: embedavro.CustomerV1Avro,
-			Type:   sr.TypeAvro,
-		},
-	)
+// The given order schema must have been parsed already. If successful, it
+// returns the schema id.
+func (svc *OrderService) registerAvroSchema(ctx context.Context, orderSchema avro.Schema) (int, error) {
+	references, err := registerAvroReferenceSchemas(ctx, svc.srClient)
 	if err != nil {
-		return -1, fmt.Errorf("failed to register customer v1 schema: %w", err)
+		return -1, err
 	}
 
-	customerV2, err := svc.srClient.CreateSchema(
+	subjectSchema, err := svc.srClient.CreateSchema(
 		ctx,
-		customerV1.Subject,
+		svc.cfg.SubjectName("orders-avro-sr", avroRecordName(orderSchema)),
 		sr.Schema{
-			Schema: embedavro.CustomerV2Avro,
-			Type:   sr.TypeAvro,
+			Schema:     embedavro.OrderAvro,
+			Type:       sr.TypeAvro,
+			References: references,
 		},
 	)
 	if err != nil {
-		return -1, fmt.Errorf("failed to register customer v2 schema: %w", err)
+		return -1, fmt.Errorf("failed to register order schema: %w", err)
 	}
 
-	address, err := svc.srClient.CreateSchema(
-		ctx,
-		"com.shop.v1.avro.Address",
-		sr.Schema{
-			Schema: embedavro.AddressAvro,
-			Type:   sr.TypeAvro,
-		},
-	)
-	if err != nil {
-		return -1, fmt.Errorf("failed to register address schema: %w", err)
+	return subjectSchema.ID, nil
+}
+
+// CreateOrder creates a new fake order message. It picks a random customer from
+// the customer registry, so that the order references a customer that has been
+// created and not been deleted. The ordered products are picked from the
+// product catalog. If enabled, the order is scored by the fraud detection. In
+// wholesale mode only a share of the calls places an order, and only for
+// business customers.
+func (svc *OrderService) CreateOrder() {
+	if svc.cfg.B2B.Enabled && rand.Float64() >= svc.cfg.B2B.OrderRatio {
+		return
 	}
+	customer, ok := svc.customers.random()
+	if !ok {
+		svc.logger.Debug("skipped creating order, because the customer registry is empty")
+		return
+	}
+	if svc.cfg.B2B.Enabled && customer.CustomerType != fake.CustomerTypeBusiness {
+		return
+	}
+	products := svc.productCatalog.RandomProducts(gofakeit.Number(8, 45))
+	if len(products) == 0 {
+		svc.logger.Debug("skipped creating order, because the catalog is empty")
+		return
+	}
+	ctx, span := startTrace(context.Background(), svc.tracer, "create order")
+	defer span.End()
+	order := svc.newOrder(customer, products)
+	if svc.cfg.Fraud.Enabled {
+		svc.placeScoredOrder(ctx, order)
+		return
+	}
+	svc.PlaceOrder(ctx, &order)
+}
 
-	orderSchema, err := svc.srClient.CreateSchema(
-		ctx,
-		svc.topicNameAvroSr+"-value",
-		sr.Schema{
-			Schema: embedavro.OrderAvro,
-			Type:   sr.TypeAvro,
-			References: []sr.SchemaReference{
-				{
-					Name:    customerV2.Subject,
-					Subject: customerV2.Subject,
-					Version: 2,
-				},
-				{
-					Name:    address.Subject,
-					Subject: address.Subject,
-					Version: 1,
-				},
-			},
-		},
-	)
-	if err != nil {
-		return -1, fmt.Errorf("failed to register order schema: %w", err)
+// PlaceCustomerOrder places a new fake order of the customer with the given
+// ID, e.g. to trigger an order on demand. Unlike CreateOrder, the order is
+// never made suspicious by the fraud detection, so that its outcome is
+// predictable. It returns the placed order.
+func (svc *OrderService) PlaceCustomerOrder(customerID string) (fake.Order, error) {
+	customer, ok := svc.customers.get(customerID)
+	if !ok {
+		return fake.Order{}, fmt.Errorf("%w: customer '%v' has not been consumed by the order service yet or has been deleted", errNotFound, customerID)
+	}
+	products := svc.productCatalog.RandomProducts(gofakeit.Number(8, 45))
+	if len(products) == 0 {
+		return fake.Order{}, fmt.Errorf("%w: the product catalog is empty", errConflict)
 	}
 
-	return orderSchema.ID, nil
+	ctx, span := startTrace(context.Background(), svc.tracer, "place customer order")
+	defer span.End()
+	order := svc.newOrder(customer, products)
+	if !svc.PlaceOrder(ctx, &order) {
+		return fake.Order{}, fmt.Errorf("failed to place order")
+	}
+
+	return order, nil
 }
 
-// CreateOrder creates a new fake order message. It pops a previously produced
-// fake customer from the in-memory cache so that an existing customer can be
-// referenced in the order message.
-func (svc *OrderService) CreateOrder() {
-	customer, err := svc.popCustomerFromBuffer()
-	if err != nil {
-		svc.logger.Debug("failed to pop customer from buffer", zap.Error(err))
-		return
+// newOrder creates a new fake order of the given customer and products, which
+// is a wholesale order in wholesale mode.
+func (svc *OrderService) newOrder(customer fake.Customer, products []fake.Product) fake.Order {
+	order := fake.NewOrder(customer, products, svc.productCatalog.pricing, svc.clock.now())
+	if svc.cfg.B2B.Enabled {
+		order.MakeWholesale(gofakeit.Number(svc.cfg.B2B.MinQuantityFactor, svc.cfg.B2B.MaxQuantityFactor), svc.cfg.B2B.PaymentTermDays)
 	}
-	order := fake.NewOrder(customer)
+	return order
+}
 
-	err = svc.produceOrderJSON(order)
-	if err != nil {
-		svc.logger.Warn("failed to produce order (json)", zap.Error(err))
-		return
+// PlaceOrder produces the given order to all order topics. The promo codes of
+// active sales are redeemed first and their discounts are subtracted from the
+// order value. In transactional mode the order is written to the orders topic
+// within a transaction, see produceOrderTransaction. If enabled, the
+// lifecycle of the placed order is started and its compensation is scheduled.
+// It returns whether the order has been placed, which is not the case if
+// producing the order failed or its transaction has been aborted. All order
+// records belong to the trace of the given context.
+func (svc *OrderService) PlaceOrder(ctx context.Context, order *fake.Order) bool {
+	usages := svc.applyPromoCodes(order)
+	return svc.placeOrder(ctx, *order, usages)
+}
+
+// placeOrder places the given order, see PlaceOrder, and produces the usages
+// of the promo codes that it redeemed once it has been placed.
+func (svc *OrderService) placeOrder(ctx context.Context, order fake.Order, usages []fake.PromoCodeUsage) bool {
+	ctx = withCustomer(withEventType(ctx, EventTypeOrderCreated), order.Customer)
+	if svc.txnClient != nil {
+		committed, err := svc.produceOrderTransaction(ctx, order)
+		if err != nil {
+			svc.logger.Warn("failed to produce order transaction", zap.Error(err))
+			return false
+		}
+		if !committed {
+			// The order has never been placed, so it must not show up on the other order topics
+			return false
+		}
+	} else {
+		err := svc.produceOrder(ctx, order)
+		if err != nil {
+			svc.logger.Warn("failed to produce order", zap.Error(err))
+			return false
+		}
+	}
+
+	svc.cdcRows.insert(ctx, order)
+	if svc.cfg.OrderLifecycle.Enabled || svc.cfg.OrderCompensations.Enabled() {
+		svc.orders.put(order)
+	}
+	if svc.streams != nil {
+		svc.streams.repartition(ctx, order)
+	}
+	if svc.cfg.OrderLifecycle.Enabled {
+		svc.startOrderLifecycle(ctx, order)
+	}
+	if svc.cfg.OrderCompensations.Enabled() {
+		svc.scheduleCompensation(ctx, order)
 	}
-	err = svc.produceOrderPlainProtobuf(order)
+	svc.redeemPromoCodes(ctx, usages)
+
+	// The order has been placed once it has been produced to the orders topic,
+	// the remaining topics only contain the same order in different formats.
+	err := svc.produceOrderPlainProtobuf(ctx, order)
 	if err != nil {
 		svc.logger.Warn("failed to produce order (protobuf)", zap.Error(err))
-		return
+		return true
 	}
 	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeOrderCreated}).Add(2)
 
 	if svc.srClient != nil {
-		err = svc.produceOrderSrProtobuf(order)
+		err = svc.produceOrderSrProtobuf(ctx, order)
 		if err != nil {
 			svc.logger.Warn("failed to produce order (protobuf sr)", zap.Error(err))
-			return
+			return true
 		}
 		kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeOrderCreated}).Add(1)
 
-		err = svc.produceOrderSrAvro(order)
+		err = svc.produceOrderSrAvro(ctx, order)
 		if err != nil {
 			svc.logger.Warn("failed to produce order (avro sr)", zap.Error(err))
-			return
+			return true
 		}
 		kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeOrderCreated}).Add(1)
 	}
 
-	return
+	return true
 }
 
-func (svc *OrderService) produceOrderJSON(order fake.Order) error {
-	serialized, err := json.Marshal(order)
+// orderRecord returns the record for the given order in the configured
+// serialization format.
+func (svc *OrderService) orderRecord(order fake.Order) (*kgo.Record, error) {
+	serialized, err := svc.serde.Encode(order)
 	if err != nil {
-		return fmt.Errorf("failed to serialize customer struct: %w", err)
+		return nil, fmt.Errorf("failed to serialize order struct: %w", err)
 	}
 
-	rec := kgo.Record{
-		Key:       []byte(order.ID),
+	return &kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, order.ID, order.Customer.ID),
 		Value:     serialized,
 		Headers:   []kgo.RecordHeader{{Key: "revision", Value: []byte("0")}},
-		Timestamp: time.Now(),
+		Timestamp: svc.clock.now(),
 		Topic:     svc.topicName,
+	}, nil
+}
+
+// produceOrder produces the order in the configured serialization format.
+func (svc *OrderService) produceOrder(ctx context.Context, order fake.Order) error {
+	rec, err := svc.orderRecord(order)
+	if err != nil {
+		return err
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, rec, func(rec *kgo.Record, err error) {
 		if err != nil {
 			svc.logger.Error("failed to produce record",
 				zap.String("topic_name", rec.Topic),
@@ -444,7 +716,7 @@ func (svc *OrderService) produceOrderJSON(order fake.Order) error {
 	return nil
 }
 
-func (svc *OrderService) produceOrderPlainProtobuf(order fake.Order) error {
+func (svc *OrderService) produceOrderPlainProtobuf(ctx context.Context, order fake.Order) error {
 	pbOrder := order.Protobuf()
 	serialized, err := proto.Marshal(pbOrder)
 	if err != nil {
@@ -452,17 +724,17 @@ func (svc *OrderService) produceOrderPlainProtobuf(order fake.Order) error {
 	}
 
 	rec := kgo.Record{
-		Key:   []byte(order.ID),
+		Key:   recordKey(svc.cfg, svc.topicNameProtobufPlain, order.ID, order.Customer.ID),
 		Value: serialized,
 		Headers: []kgo.RecordHeader{
 			{Key: "revision", Value: []byte("0")},
 			{Key: "proto_message_type", Value: []byte("Order")},
 		},
-		Timestamp: time.Now(),
+		Timestamp: svc.clock.now(),
 		Topic:     svc.topicNameProtobufPlain,
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
 		if err != nil {
 			svc.logger.Error("failed to produce record",
 				zap.String("topic_name", rec.Topic),
@@ -476,7 +748,7 @@ func (svc *OrderService) produceOrderPlainProtobuf(order fake.Order) error {
 }
 
 // produceOrderSrProtobuf produces a protobuf message with schema registry encoding.
-func (svc *OrderService) produceOrderSrProtobuf(order fake.Order) error {
+func (svc *OrderService) produceOrderSrProtobuf(ctx context.Context, order fake.Order) error {
 	pbOrder := order.Protobuf()
 	serialized, err := svc.protobufSerde.Encode(pbOrder)
 	if err != nil {
@@ -484,17 +756,17 @@ func (svc *OrderService) produceOrderSrProtobuf(order fake.Order) error {
 	}
 
 	rec := kgo.Record{
-		Key:   []byte(order.ID),
+		Key:   recordKey(svc.cfg, svc.topicNameProtobufSr, order.ID, order.Customer.ID),
 		Value: serialized,
 		Headers: []kgo.RecordHeader{
 			{Key: "revision", Value: []byte("0")},
 			{Key: "proto_message_type", Value: []byte("Order")},
 		},
-		Timestamp: time.Now(),
+		Timestamp: svc.clock.now(),
 		Topic:     svc.topicNameProtobufSr,
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
 		if err != nil {
 			svc.logger.Error("failed to produce record",
 				zap.String("topic_name", rec.Topic),
@@ -508,24 +780,24 @@ func (svc *OrderService) produceOrderSrProtobuf(order fake.Order) error {
 }
 
 // produceOrderSrAvro produces an avro message with schema registry encoding.
-func (svc *OrderService) produceOrderSrAvro(order fake.Order) error {
+func (svc *OrderService) produceOrderSrAvro(ctx context.Context, order fake.Order) error {
 	serialized, err := svc.avroSerde.Encode(order)
 	if err != nil {
 		return fmt.Errorf("failed to encode avro order: %w", err)
 	}
 
 	rec := kgo.Record{
-		Key:   []byte(order.ID),
+		Key:   recordKey(svc.cfg, svc.topicNameAvroSr, order.ID, order.Customer.ID),
 		Value: serialized,
 		Headers: []kgo.RecordHeader{
 			{Key: "revision", Value: []byte("0")},
 			{Key: "avro_message_type", Value: []byte("Order")},
 		},
-		Timestamp: time.Now(),
+		Timestamp: svc.clock.now(),
 		Topic:     svc.topicNameAvroSr,
 	}
 
-	svc.metaClient.Produce(context.Background(), &rec, func(rec *kgo.Record, err error) {
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
 		if err != nil {
 			svc.logger.Error("failed to produce record",
 				zap.String("topic_name", rec.Topic),
@@ -537,17 +809,3 @@ func (svc *OrderService) produceOrderSrAvro(order fake.Order) error {
 
 	return nil
 }
-
-func (svc *OrderService) popCustomerFromBuffer() (fake.Customer, error) {
-	svc.recentCustomersMu.Lock()
-	defer svc.recentCustomersMu.Unlock()
-
-	if len(svc.recentCustomers) == 0 {
-		// No customers in buffer yet
-		return fake.Customer{}, fmt.Errorf("buffer is empty")
-	}
-	customer := svc.recentCustomers[0]
-	svc.recentCustomers = svc.recentCustomers[1:]
-
-	return customer, nil
-}
@@ -0,0 +1,128 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// produceOrderTransaction atomically writes the order, the decremented stock
+// of all ordered products and the customer's activity record within a single
+// Kafka transaction. Depending on the configured abort ratio the transaction
+// is aborted rather than committed, so that the aborted records are only
+// visible to read_uncommitted consumers. The product catalog is only updated
+// if the transaction has been committed. It returns whether the transaction
+// has been committed.
+func (svc *OrderService) produceOrderTransaction(ctx context.Context, order fake.Order) (bool, error) {
+	txn := &recordTransaction{}
+	ctx = withRecordTransaction(ctx, txn)
+
+	orderRec, err := svc.orderRecord(order)
+	if err != nil {
+		return false, err
+	}
+	orderRec.Context = ctx
+
+	products := svc.productCatalog.DecrementedStock(order.LineItems)
+	recs := []*kgo.Record{orderRec}
+	for _, product := range products {
+		productRec, err := svc.productCatalog.productRecord(product)
+		if err != nil {
+			return false, err
+		}
+		productRec.Context = withEventType(ctx, EventTypeProductModified)
+		recs = append(recs, productRec)
+	}
+
+	activity := fake.NewOrderPlacedActivity(order, svc.clock.now())
+	serializedActivity, err := json.Marshal(activity)
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize customer activity struct: %w", err)
+	}
+	recs = append(recs, &kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameCustomerActivity, activity.CustomerID, activity.CustomerID),
+		Value:     serializedActivity,
+		Headers:   []kgo.RecordHeader{{Key: "activity_type", Value: []byte(activity.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameCustomerActivity,
+		Context:   withEventType(ctx, EventTypeCustomerActivityCreated),
+	})
+
+	svc.txnMu.Lock()
+	defer svc.txnMu.Unlock()
+
+	if err := svc.txnClient.BeginTransaction(); err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	var produceErrMu sync.Mutex
+	var produceErr error
+	for _, rec := range recs {
+		svc.txnProducer.Produce(ctx, rec, func(rec *kgo.Record, err error) {
+			if err == nil {
+				return
+			}
+			svc.logger.Error("failed to produce transactional record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			produceErrMu.Lock()
+			produceErr = err
+			produceErrMu.Unlock()
+		})
+	}
+
+	// All records must be flushed before the transaction can be ended
+	if err := svc.txnClient.Flush(ctx); err != nil {
+		txn.end(false)
+		if abortErr := svc.txnClient.EndTransaction(ctx, kgo.TryAbort); abortErr != nil {
+			svc.logger.Warn("failed to abort transaction", zap.Error(abortErr))
+		}
+		return false, fmt.Errorf("failed to flush transactional records: %w", err)
+	}
+
+	// The promises have been called on the client's goroutines
+	produceErrMu.Lock()
+	recordErr := produceErr
+	produceErrMu.Unlock()
+
+	commit := kgo.TryCommit
+	if recordErr != nil || rand.Float64() < svc.cfg.Transactions.AbortRatio {
+		commit = kgo.TryAbort
+	}
+	if err := svc.txnClient.EndTransaction(ctx, commit); err != nil {
+		txn.end(false)
+		return false, fmt.Errorf("failed to end transaction: %w", err)
+	}
+	txn.end(commit == kgo.TryCommit)
+
+	if commit == kgo.TryAbort {
+		kafkaTransactionsTotal.With(map[string]string{"result": "aborted"}).Inc()
+		if recordErr != nil {
+			return false, fmt.Errorf("aborted transaction because producing a record failed: %w", recordErr)
+		}
+		svc.logger.Debug("aborted order transaction on purpose", zap.String("order_id", order.ID))
+		return false, nil
+	}
+
+	kafkaTransactionsTotal.With(map[string]string{"result": "committed"}).Inc()
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeProductModified}).Add(float64(len(products)))
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeCustomerActivityCreated}).Inc()
+	svc.productCatalog.ApplyProducts(products)
+
+	return true, nil
+}
+
+// readCommittedOrders returns the isolation level of the consumers of the
+// orders topic. Orders of aborted transactions have never been placed, so they
+// must not be consumed.
+func readCommittedOrders() kgo.Opt {
+	return kgo.FetchIsolationLevel(kgo.ReadCommitted())
+}
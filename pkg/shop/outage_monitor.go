@@ -0,0 +1,253 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// outageMonitorInterval is the interval in which the outage monitor checks
+// whether the brokers have become unavailable or recovered.
+const outageMonitorInterval = 100 * time.Millisecond
+
+// outageLogInterval is the min interval between two log entries with the same
+// message about dropped records.
+const outageLogInterval = 10 * time.Second
+
+// outageMonitor detects broker outages from the produced records of all
+// clients. The brokers are considered unavailable once records have been
+// buffered for the detection timeout without any of them being acknowledged,
+// and recovered once the next record is acknowledged. The time to recovery is
+// measured from the last acknowledged record before the outage.
+type outageMonitor struct {
+	cfg    config.OutageTolerance
+	logger *zap.Logger
+
+	buffered atomic.Int64
+	dropped  atomic.Int64
+	// progressedAt is the unix nano time at which the last record has been
+	// acknowledged, or at which the first record has been buffered after
+	// all buffered records had been finished.
+	progressedAt atomic.Int64
+	// acknowledgedAt is the unix nano time at which the last record has been
+	// acknowledged.
+	acknowledgedAt atomic.Int64
+
+	// quit is closed by shutdown, after which stopped is closed.
+	quit    chan struct{}
+	stopped chan struct{}
+}
+
+var (
+	_ kgo.HookProduceRecordBuffered   = (*outageMonitor)(nil)
+	_ kgo.HookProduceRecordUnbuffered = (*outageMonitor)(nil)
+)
+
+// newOutageMonitor creates the outage monitor and, if outage tolerance is
+// enabled, starts checking for outages in the background until shutdown is
+// called.
+func newOutageMonitor(cfg config.OutageTolerance, logger *zap.Logger) *outageMonitor {
+	m := &outageMonitor{
+		cfg:     cfg,
+		logger:  logger,
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if !cfg.Enabled {
+		close(m.stopped)
+		return m
+	}
+
+	now := time.Now().UnixNano()
+	m.progressedAt.Store(now)
+	m.acknowledgedAt.Store(now)
+	go m.run()
+
+	return m
+}
+
+// OnProduceRecordBuffered counts the buffered record.
+func (m *outageMonitor) OnProduceRecordBuffered(_ *kgo.Record) {
+	if !m.cfg.Enabled {
+		return
+	}
+	if m.buffered.Add(1) == 1 {
+		m.progressedAt.Store(time.Now().UnixNano())
+	}
+}
+
+// OnProduceRecordUnbuffered counts the finished record, which has been
+// dropped if the client's buffer was full.
+func (m *outageMonitor) OnProduceRecordUnbuffered(_ *kgo.Record, err error) {
+	if !m.cfg.Enabled {
+		return
+	}
+	m.buffered.Add(-1)
+
+	switch {
+	case err == nil:
+		now := time.Now().UnixNano()
+		m.progressedAt.Store(now)
+		m.acknowledgedAt.Store(now)
+	case errors.Is(err, kgo.ErrMaxBuffered):
+		m.dropped.Add(1)
+		outageDroppedRecordsTotal.Inc()
+	}
+}
+
+func (m *outageMonitor) run() {
+	defer close(m.stopped)
+
+	ticker := time.NewTicker(outageMonitorInterval)
+	defer ticker.Stop()
+
+	// droppedBefore is the number of dropped records at the last recovery,
+	// so that records that have been dropped before the outage has been
+	// detected are reported as well
+	var (
+		outageStartedAt time.Time
+		droppedBefore   int64
+	)
+	for {
+		select {
+		case <-m.quit:
+			return
+		case <-ticker.C:
+		}
+
+		buffered := m.buffered.Load()
+		outageBufferedRecords.Set(float64(buffered))
+
+		if outageStartedAt.IsZero() {
+			progressedAt := time.Unix(0, m.progressedAt.Load())
+			if buffered == 0 || time.Since(progressedAt) < m.cfg.DetectionTimeout {
+				continue
+			}
+			outageStartedAt = progressedAt
+			outageActive.Set(1)
+			m.logger.Warn("brokers are unavailable, buffering produced records",
+				zap.Time("last_progress", progressedAt),
+				zap.Int64("buffered", buffered),
+				zap.Int64("dropped", m.dropped.Load()-droppedBefore))
+			continue
+		}
+
+		acknowledgedAt := time.Unix(0, m.acknowledgedAt.Load())
+		if !acknowledgedAt.After(outageStartedAt) {
+			continue
+		}
+		timeToRecovery := acknowledgedAt.Sub(outageStartedAt)
+		dropped := m.dropped.Load()
+		outageRecoverySeconds.Observe(timeToRecovery.Seconds())
+		outageActive.Set(0)
+		m.logger.Info("brokers have recovered",
+			zap.Duration("time_to_recovery", timeToRecovery),
+			zap.Int64("buffered", buffered),
+			zap.Int64("dropped", dropped-droppedBefore))
+		outageStartedAt = time.Time{}
+		droppedBefore = dropped
+	}
+}
+
+// shutdown stops checking for outages.
+func (m *outageMonitor) shutdown(ctx context.Context) error {
+	if m.cfg.Enabled {
+		close(m.quit)
+	}
+
+	select {
+	case <-m.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// droppedRecordsCore rate-limits the log entries about records that have been
+// dropped, because their client's buffer was full. While the brokers are
+// unavailable, most produced records are dropped, whose errors would flood
+// the log otherwise. Entries with the same message are written at most once
+// per interval and carry the number of entries that have been suppressed
+// since the last one. All other entries are written as they are.
+type droppedRecordsCore struct {
+	zapcore.Core
+	limiter *droppedRecordsLimiter
+}
+
+// droppedRecordsLimiter is shared by a core and all cores that have been
+// derived from it with With.
+type droppedRecordsLimiter struct {
+	mu      sync.Mutex
+	entries map[string]*droppedRecordsEntry
+}
+
+type droppedRecordsEntry struct {
+	loggedAt   time.Time
+	suppressed int
+}
+
+// rateLimitDroppedRecords returns a logger whose entries about dropped records
+// are rate-limited, see droppedRecordsCore.
+func rateLimitDroppedRecords(logger *zap.Logger) *zap.Logger {
+	limiter := &droppedRecordsLimiter{entries: make(map[string]*droppedRecordsEntry)}
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &droppedRecordsCore{Core: core, limiter: limiter}
+	}))
+}
+
+func (c *droppedRecordsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &droppedRecordsCore{Core: c.Core.With(fields), limiter: c.limiter}
+}
+
+func (c *droppedRecordsCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *droppedRecordsCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	for _, field := range fields {
+		err, ok := field.Interface.(error)
+		if field.Type != zapcore.ErrorType || !ok || !errors.Is(err, kgo.ErrMaxBuffered) {
+			continue
+		}
+		suppressed, ok := c.limiter.allow(entry.Message, entry.Time)
+		if !ok {
+			return nil
+		}
+		if suppressed > 0 {
+			fields = append(fields, zap.Int("suppressed", suppressed))
+		}
+		break
+	}
+	return c.Core.Write(entry, fields)
+}
+
+// allow returns whether an entry with the given message may be written at
+// the given time and how many entries have been suppressed before it.
+func (l *droppedRecordsLimiter) allow(message string, now time.Time) (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[message]
+	if !ok {
+		l.entries[message] = &droppedRecordsEntry{loggedAt: now}
+		return 0, true
+	}
+	if now.Sub(entry.loggedAt) < outageLogInterval {
+		entry.suppressed++
+		return 0, false
+	}
+	suppressed := entry.suppressed
+	entry.loggedAt, entry.suppressed = now, 0
+	return suppressed, true
+}
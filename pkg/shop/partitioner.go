@@ -0,0 +1,191 @@
+package shop
+
+import (
+	"context"
+	"hash/crc32"
+	"hash/fnv"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// customerClassKey is the context key of the class of the customer that a
+// record refers to.
+type customerClassKey struct{}
+
+// customerClass is the class of a customer that partition pins match.
+type customerClass struct {
+	segment      string
+	customerType string
+	loyaltyTier  string
+}
+
+// topicPartitioner partitions the records of each topic with the partitioner
+// of the topic's override, so that the partitioning of different clients can
+// be compared on the same data. Topics without partitioner use the default
+// partitioner of the Kafka client.
+type topicPartitioner struct {
+	cfg config.Shop
+}
+
+var _ kgo.Partitioner = (*topicPartitioner)(nil)
+
+func newTopicPartitioner(cfg config.Shop) *topicPartitioner {
+	return &topicPartitioner{cfg: cfg}
+}
+
+// opt returns the client option that registers the partitioner.
+func (p *topicPartitioner) opt() kgo.Opt {
+	return kgo.RecordPartitioner(p)
+}
+
+// ForTopic returns the partitioner of the given topic. It is called once per
+// topic by each client.
+func (p *topicPartitioner) ForTopic(topic string) kgo.TopicPartitioner {
+	override := topicOverride(p.cfg, topic)
+
+	var partitioner kgo.Partitioner
+	switch override.Partitioner {
+	case config.PartitionerMurmur2:
+		partitioner = kgo.StickyKeyPartitioner(nil)
+	case config.PartitionerCRC32:
+		partitioner = kgo.StickyKeyPartitioner(crc32Hasher)
+	case config.PartitionerFNV1a:
+		partitioner = kgo.StickyKeyPartitioner(kgo.SaramaHasher(fnv1a))
+	case config.PartitionerRoundRobin:
+		partitioner = kgo.RoundRobinPartitioner()
+	case config.PartitionerManual:
+		partition := int(override.Partition)
+		partitioner = kgo.BasicConsistentPartitioner(func(string) func(*kgo.Record, int) int {
+			return func(*kgo.Record, int) int { return partition }
+		})
+	default:
+		// The default of the Kafka client
+		partitioner = kgo.UniformBytesPartitioner(64<<10, true, true, nil)
+	}
+
+	topicPartitioner := partitioner.ForTopic(topic)
+
+	pins := p.pins(topic)
+	if len(pins) == 0 {
+		return topicPartitioner
+	}
+	return &pinningTopicPartitioner{inner: topicPartitioner, pins: pins}
+}
+
+// explicitPartition returns the partition of the record if it does not depend
+// on the number of partitions of the topic, which is the case for records of
+// the manual partitioner and pinned records. It is used by producers that do
+// not partition the records themselves, e.g. the httpProxyProducer.
+func (p *topicPartitioner) explicitPartition(r *kgo.Record) (int32, bool) {
+	if partition, ok := pinnedPartition(p.pins(r.Topic), r); ok {
+		return int32(partition), true
+	}
+	if override := topicOverride(p.cfg, r.Topic); override.Partitioner == config.PartitionerManual {
+		return override.Partition, true
+	}
+	return 0, false
+}
+
+// pins returns the partition pins of the given topic.
+func (p *topicPartitioner) pins(topic string) []config.PartitionPin {
+	var pins []config.PartitionPin
+	for _, pin := range p.cfg.PartitionPins {
+		if pin.Topic == strings.TrimPrefix(topic, p.cfg.TopicNamePrefix()) {
+			pins = append(pins, pin)
+		}
+	}
+	return pins
+}
+
+// pinningTopicPartitioner produces the records of pinned customer classes to
+// the partition of the first matching pin. All other records are partitioned
+// by the inner partitioner of the topic.
+type pinningTopicPartitioner struct {
+	inner kgo.TopicPartitioner
+	pins  []config.PartitionPin
+}
+
+var (
+	_ kgo.TopicBackupPartitioner     = (*pinningTopicPartitioner)(nil)
+	_ kgo.TopicPartitionerOnNewBatch = (*pinningTopicPartitioner)(nil)
+)
+
+// pinnedPartition returns the partition of the first of the pins that matches
+// the customer class of the record.
+func pinnedPartition(pins []config.PartitionPin, r *kgo.Record) (int, bool) {
+	if r.Context == nil {
+		return 0, false
+	}
+	class, ok := r.Context.Value(customerClassKey{}).(customerClass)
+	if !ok {
+		return 0, false
+	}
+	for _, pin := range pins {
+		if pin.Matches(class.segment, class.customerType, class.loyaltyTier) {
+			return int(pin.Partition), true
+		}
+	}
+	return 0, false
+}
+
+// RequiresConsistency is true for pinned records, so that they are produced
+// to their partition even if it is temporarily unavailable.
+func (p *pinningTopicPartitioner) RequiresConsistency(r *kgo.Record) bool {
+	if _, ok := pinnedPartition(p.pins, r); ok {
+		return true
+	}
+	return p.inner.RequiresConsistency(r)
+}
+
+func (p *pinningTopicPartitioner) Partition(r *kgo.Record, n int) int {
+	if partition, ok := pinnedPartition(p.pins, r); ok {
+		return partition
+	}
+	return p.inner.Partition(r, n)
+}
+
+// PartitionByBackup is used by the Kafka client instead of Partition for all
+// records that do not require consistency, so it must be forwarded to the
+// inner partitioner if it has a backup.
+func (p *pinningTopicPartitioner) PartitionByBackup(r *kgo.Record, n int, backup kgo.TopicBackupIter) int {
+	if partition, ok := pinnedPartition(p.pins, r); ok {
+		return partition
+	}
+	if inner, ok := p.inner.(kgo.TopicBackupPartitioner); ok {
+		return inner.PartitionByBackup(r, n, backup)
+	}
+	return p.inner.Partition(r, n)
+}
+
+func (p *pinningTopicPartitioner) OnNewBatch() {
+	if inner, ok := p.inner.(kgo.TopicPartitionerOnNewBatch); ok {
+		inner.OnNewBatch()
+	}
+}
+
+// withCustomer returns a copy of the context that carries the class of the
+// given customer, so that the records produced with it are pinned to the
+// partitions of the matching partition pins.
+func withCustomer(ctx context.Context, customer fake.Customer) context.Context {
+	return context.WithValue(ctx, customerClassKey{}, customerClass{
+		segment:      string(customer.Segment),
+		customerType: string(customer.CustomerType),
+		loyaltyTier:  string(customer.LoyaltyTier),
+	})
+}
+
+// crc32Hasher picks the partition of a key like librdkafka, which takes the
+// unsigned CRC32 checksum of the key modulo the number of partitions.
+func crc32Hasher(key []byte, n int) int {
+	return int(crc32.ChecksumIEEE(key) % uint32(n))
+}
+
+func fnv1a(key []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return h.Sum32()
+}
@@ -0,0 +1,15 @@
+package shop
+
+import (
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// newPayloadCache returns the cache of pre-generated customers or addresses of
+// a service, which is nil if it is disabled.
+func newPayloadCache(cfg config.PayloadCache) *fake.PayloadCache {
+	if !cfg.Enabled {
+		return nil
+	}
+	return fake.NewPayloadCache(cfg.Size)
+}
@@ -0,0 +1,93 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+)
+
+// consumeTransactional consumes the orders topic in consume-transform-produce
+// transactions until the service has been closed. The payment events
+// of each polled batch are produced in one transaction, which also commits
+// the batch's offsets for the consumer group. If the transaction is aborted,
+// either on purpose or because the group has rebalanced in the meantime, the
+// consumer is reset to the committed offsets, so that the orders are processed
+// again within the next transaction. If a transaction can't be begun or
+// ended, which kgo does not retry, the consumer stops and the service is
+// reported as failed.
+func (svc *PaymentService) consumeTransactional() {
+	for {
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+		if fetches.NumRecords() == 0 {
+			continue
+		}
+
+		if err := svc.session.Begin(); err != nil {
+			svc.logger.Error("failed to begin transaction, stopping to consume orders", zap.Error(err))
+			svc.fail(fmt.Errorf("failed to begin transaction: %w", err))
+			return
+		}
+		txn := &recordTransaction{}
+		ctx := withRecordTransaction(context.Background(), txn)
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeOrderConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+			svc.processOrderRecord(ctx, rec)
+		})
+
+		commit := kgo.TryCommit
+		if rand.Float64() < svc.cfg.ExactlyOnce.AbortRatio {
+			// The records are written before the transaction is aborted, so
+			// that they are visible to read_uncommitted consumers
+			if err := svc.consumerClient.Flush(context.Background()); err != nil {
+				svc.logger.Warn("failed to flush transactional records", zap.Error(err))
+			}
+			commit = kgo.TryAbort
+		}
+
+		committed, err := svc.session.End(context.Background(), commit)
+		txn.end(err == nil && committed)
+		if err != nil {
+			if errors.Is(err, kgo.ErrClientClosed) {
+				svc.logger.Warn("client closed")
+				return
+			}
+			svc.logger.Error("failed to end transaction, stopping to consume orders", zap.Error(err))
+			svc.fail(fmt.Errorf("failed to end transaction: %w", err))
+			return
+		}
+
+		if !committed {
+			kafkaTransactionsTotal.With(map[string]string{"result": "aborted"}).Inc()
+			svc.logger.Debug("aborted payment transaction, its orders are processed again",
+				zap.Int("orders", fetches.NumRecords()),
+				zap.Bool("on_purpose", commit == kgo.TryAbort))
+			continue
+		}
+		kafkaTransactionsTotal.With(map[string]string{"result": "committed"}).Inc()
+	}
+}
@@ -0,0 +1,149 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// pendingInvoice is an invoice of a wholesale order that has not been paid
+// yet.
+type pendingInvoice struct {
+	order   fake.Order
+	invoice fake.Invoice
+	// next is the next event of the invoice, which is either overdue or paid.
+	next  fake.InvoiceEventType
+	dueAt time.Time
+	// ctx carries the trace of the order, which all invoice events continue.
+	ctx context.Context
+}
+
+// invoiceOrder issues the invoice of the given wholesale order. Most invoices
+// are paid within the payment terms, late ones become overdue on their due
+// date and are paid within half the payment terms afterwards.
+func (svc *PaymentService) invoiceOrder(ctx context.Context, order fake.Order) {
+	terms := time.Duration(svc.cfg.B2B.PaymentTermDays) * 24 * time.Hour
+	now := svc.clock.now()
+	invoice := fake.NewInvoice(order, now, terms)
+	if err := svc.produceInvoiceEvent(ctx, fake.NewInvoiceEvent(invoice, fake.InvoiceEventTypeIssued, now)); err != nil {
+		svc.logger.Warn("failed to produce invoice event", zap.Error(err))
+		return
+	}
+
+	pending := pendingInvoice{
+		order:   order,
+		invoice: invoice,
+		next:    fake.InvoiceEventTypePaid,
+		dueAt:   now.Add(time.Duration(rand.Int63n(int64(terms)))),
+		ctx:     ctx,
+	}
+	if rand.Float64() < svc.cfg.B2B.LatePaymentRatio {
+		pending.next = fake.InvoiceEventTypeOverdue
+		pending.dueAt = now.Add(terms)
+	}
+	svc.scheduleInvoice(pending)
+}
+
+// scheduleInvoice buffers the given pending invoice until its next event is
+// due. If the buffer is full, the invoice is dropped and never paid.
+func (svc *PaymentService) scheduleInvoice(pending pendingInvoice) {
+	svc.pendingInvoicesMu.Lock()
+	defer svc.pendingInvoicesMu.Unlock()
+
+	if len(svc.pendingInvoices) >= svc.bufferSize {
+		followUpsDroppedTotal.With(map[string]string{"kind": "invoice"}).Inc()
+		svc.logger.Debug("dropped pending invoice, because the buffer is full", zap.String("invoice_id", pending.invoice.ID))
+		return
+	}
+	svc.pendingInvoices = append(svc.pendingInvoices, pending)
+}
+
+// advanceInvoices regularly produces the next event for all pending invoices
+// that are due. Once an invoice is paid, the payment is captured and the
+// invoice is removed from the buffer. It returns once the quit channel has
+// been closed.
+func (svc *PaymentService) advanceInvoices(quit <-chan struct{}) {
+	ticker := time.NewTicker(svc.clock.realDuration(time.Second))
+	defer ticker.Stop()
+
+	terms := time.Duration(svc.cfg.B2B.PaymentTermDays) * 24 * time.Hour
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+		now := svc.clock.now()
+
+		// The due invoices are produced without holding the lock, so that
+		// new invoices can be scheduled meanwhile
+		var due []pendingInvoice
+		svc.pendingInvoicesMu.Lock()
+		remaining := svc.pendingInvoices[:0]
+		for _, pending := range svc.pendingInvoices {
+			if pending.dueAt.After(now) {
+				remaining = append(remaining, pending)
+				continue
+			}
+			due = append(due, pending)
+		}
+		svc.pendingInvoices = remaining
+		svc.pendingInvoicesMu.Unlock()
+
+		for _, pending := range due {
+			err := svc.produceInvoiceEvent(pending.ctx, fake.NewInvoiceEvent(pending.invoice, pending.next, now))
+			if err != nil {
+				svc.logger.Warn("failed to produce invoice event", zap.Error(err))
+			}
+			if pending.next == fake.InvoiceEventTypeOverdue {
+				pending.next = fake.InvoiceEventTypePaid
+				pending.dueAt = now.Add(time.Duration(rand.Int63n(int64(terms/2) + 1)))
+				svc.scheduleInvoice(pending)
+				continue
+			}
+
+			eventCtx := withEventType(pending.ctx, EventTypePaymentCaptured)
+			if err := svc.producePaymentEvent(eventCtx, fake.NewPaymentEvent(pending.order, fake.PaymentEventTypeCaptured, now)); err != nil {
+				svc.logger.Warn("failed to produce payment event", zap.Error(err))
+				continue
+			}
+			kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypePaymentCaptured}).Inc()
+		}
+	}
+}
+
+func (svc *PaymentService) produceInvoiceEvent(ctx context.Context, event fake.InvoiceEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize invoice event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameInvoices, event.Invoice.OrderID, event.Invoice.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameInvoices,
+	}
+
+	eventType := invoiceEventTypeMetricLabels[event.Type]
+	svc.producer.Produce(withEventType(ctx, eventType), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+
+	return nil
+}
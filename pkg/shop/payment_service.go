@@ -0,0 +1,354 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mroth/weightedrand"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// PaymentService consumes the orders topic and processes the payment for each
+// order. Depending on the randomly chosen outcome it produces one or more
+// payment events (authorized, captured, declined, refunded) to the payments
+// topic. In wholesale mode, orders with payment terms are invoiced instead, the
+// lifecycle of their invoices is produced to the invoices topic and their
+// payment is captured once the invoice has been paid. In exactly-once mode, the
+// payment events of each polled batch of orders are produced in a transaction
+// together with the batch's offsets.
+type PaymentService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	orderSerde      *TopicSerde
+	serde           *TopicSerde
+
+	// session is the transactional consumer and producer, which is only set
+	// in exactly-once mode. Its client is the consumer client and the
+	// producer then.
+	session *kgo.GroupTransactSession
+	// fail is called if the transactional consumer stops before the service
+	// has been closed, because its transaction could not be begun or ended.
+	fail func(err error)
+
+	// outcomeChooser picks the sequence of payment events that shall be
+	// produced for a consumed order.
+	outcomeChooser *weightedrand.Chooser
+
+	bufferSize        int
+	pendingInvoicesMu sync.Mutex
+	pendingInvoices   []pendingInvoice
+
+	topicName         string
+	topicNameInvoices string
+}
+
+// NewPaymentService creates a new PaymentService.
+func NewPaymentService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
+) (*PaymentService, error) {
+	clientID := cfg.Services.Payment.ClientIDFor(cfg.GlobalPrefix, "payment-service")
+	metrics := newClientMetrics("payment_service")
+	headers := newRecordHeaders(cfg.Headers, "payment-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "payment-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Payment.Offsets, logger)
+	consumerOpts := []kgo.Opt{
+		kgo.ConsumerGroup(cfg.GroupID("payment-service")),
+		kgo.ConsumeTopics(cfg.TopicName("orders")),
+		readCommittedOrders(),
+	}
+	var (
+		session        *kgo.GroupTransactSession
+		consumerClient *kgo.Client
+	)
+	if cfg.ExactlyOnce.Enabled {
+		session, err = kafkaFactory.NewGroupTransactSession(
+			clientID,
+			offsets.startOpts(append(
+				[]kgo.Opt{cloudEvents.hook(), metrics.hook(), headers.hook()},
+				append(consumerOpts, kgo.TransactionalID(clientID+"-exactly-once"), kgo.RequireStableFetchOffsets())...,
+			)...)...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transactional consumer: %w", err)
+		}
+		consumerClient = session.Client()
+		producer = consumerClient
+	} else {
+		consumerClient, err = kafkaFactory.NewKafkaClient(clientID, offsets.opts(append([]kgo.Opt{metrics.hook(), cloudEvents.hook()}, consumerOpts...)...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create consumer client: %w", err)
+		}
+	}
+
+	outcomeChooser, err := weightedrand.NewChooser(
+		weightedrand.Choice{
+			Item:   []fake.PaymentEventType{fake.PaymentEventTypeAuthorized},
+			Weight: cfg.Payments.AuthorizedWeight,
+		},
+		weightedrand.Choice{
+			Item:   []fake.PaymentEventType{fake.PaymentEventTypeAuthorized, fake.PaymentEventTypeCaptured},
+			Weight: cfg.Payments.CapturedWeight,
+		},
+		weightedrand.Choice{
+			Item:   []fake.PaymentEventType{fake.PaymentEventTypeDeclined},
+			Weight: cfg.Payments.DeclinedWeight,
+		},
+		weightedrand.Choice{
+			Item: []fake.PaymentEventType{
+				fake.PaymentEventTypeAuthorized,
+				fake.PaymentEventTypeCaptured,
+				fake.PaymentEventTypeRefunded,
+			},
+			Weight: cfg.Payments.RefundedWeight,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment outcome chooser: %w", err)
+	}
+
+	return &PaymentService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "payment_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Payment.SlowConsumer),
+		offsets:         offsets,
+		orderSerde:      serdes.Orders,
+		serde:           serdes.Payments,
+
+		session: session,
+		fail:    func(error) {},
+
+		outcomeChooser: outcomeChooser,
+
+		// Invoices are pending for up to the payment terms of simulated time
+		bufferSize:        10000,
+		pendingInvoicesMu: sync.Mutex{},
+
+		topicName:         cfg.TopicName("payments"),
+		topicNameInvoices: cfg.TopicName("invoices"),
+	}, nil
+}
+
+// Initialize payment service by reconciling the payments topic and, in
+// wholesale mode, the invoices topic.
+func (svc *PaymentService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing payment service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	if svc.cfg.B2B.Enabled {
+		err := reconcileTopic(ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameInvoices,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile invoices topic: %w", err)
+		}
+	}
+
+	svc.logger.Info("successfully initialized payment service")
+
+	return nil
+}
+
+// Close stops consuming the orders topic, flushes all buffered records and
+// closes the Kafka clients. In exactly-once mode, the transactional session is
+// closed once its last transaction has been ended, so that it leaves the
+// group.
+func (svc *PaymentService) Close(ctx context.Context) error {
+	if svc.session == nil {
+		return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+	}
+
+	svc.throttle.stop()
+	var stopErr error
+	select {
+	case <-svc.consumerStopped:
+	case <-ctx.Done():
+		stopErr = fmt.Errorf("failed to wait for transactional consumer to stop: %w", ctx.Err())
+	}
+	svc.session.Close()
+	if err := closeClients(ctx, nil, nil, nil, svc.metaClient, svc.metaClient); err != nil {
+		return err
+	}
+	return stopErr
+}
+
+// Start consuming messages from the orders topic and process the payment
+// for each consumed order. In wholesale mode, pending invoices are advanced
+// in the background until the consumer has been closed.
+func (svc *PaymentService) Start() {
+	defer close(svc.consumerStopped)
+
+	if svc.session != nil {
+		svc.consumeTransactional()
+		return
+	}
+
+	if svc.cfg.B2B.Enabled {
+		quit := make(chan struct{})
+		advanceStopped := make(chan struct{})
+		go func() {
+			defer close(advanceStopped)
+			svc.advanceInvoices(quit)
+		}()
+		defer func() {
+			close(quit)
+			<-advanceStopped
+		}()
+	}
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeOrderConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+
+			svc.processOrderRecord(context.Background(), rec)
+		})
+	}
+}
+
+// processOrderRecord decodes the consumed order and processes its payment
+// within the order's trace, which continues the given context.
+func (svc *PaymentService) processOrderRecord(ctx context.Context, rec *kgo.Record) {
+	order := fake.Order{}
+	err := svc.orderSerde.Decode(rec.Value, &order)
+	if err != nil {
+		// Skip message
+		svc.logger.Warn("failed to deserialize order", zap.Error(err))
+		return
+	}
+	ctx, span := continueTrace(ctx, svc.tracer, rec)
+	svc.processPayment(ctx, order)
+	span.End()
+}
+
+// processPayment picks a random payment outcome for the order and produces
+// all payment events that lead to this outcome. The payment events continue
+// the trace of the given context. Orders with payment terms are invoiced in
+// wholesale mode.
+func (svc *PaymentService) processPayment(ctx context.Context, order fake.Order) {
+	if svc.cfg.B2B.Enabled && order.Payment.Terms != "" {
+		svc.invoiceOrder(ctx, order)
+		return
+	}
+
+	eventTypes := svc.outcomeChooser.Pick().([]fake.PaymentEventType)
+	for _, eventType := range eventTypes {
+		eventCtx := withEventType(ctx, paymentEventTypeMetricLabels[eventType])
+		err := svc.producePaymentEvent(eventCtx, fake.NewPaymentEvent(order, eventType, svc.clock.now()))
+		if err != nil {
+			svc.logger.Warn("failed to produce payment event", zap.Error(err))
+			return
+		}
+		kafkaMessagesProducedTotal.With(map[string]string{"event_type": paymentEventTypeMetricLabels[eventType]}).Inc()
+	}
+}
+
+func (svc *PaymentService) producePaymentEvent(ctx context.Context, event fake.PaymentEvent) error {
+	serialized, err := svc.serde.Encode(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize payment event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, event.OrderID, event.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
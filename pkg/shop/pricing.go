@@ -0,0 +1,21 @@
+package shop
+
+import (
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// newPricing returns the price model of the given pricing config, whose
+// categories have been validated by the config.
+func newPricing(cfg config.Pricing) fake.Pricing {
+	categories := make(map[fake.ProductCategory]fake.PriceDistribution, len(cfg.Categories))
+	for name, distribution := range cfg.Categories {
+		categories[fake.ProductCategory(name)] = fake.PriceDistribution{Median: distribution.Median, Sigma: distribution.Sigma}
+	}
+
+	return fake.Pricing{
+		BaseCurrency:  cfg.BaseCurrency,
+		Categories:    categories,
+		ExchangeRates: cfg.ExchangeRates,
+	}
+}
@@ -0,0 +1,196 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// productPromotion is a running promotion of a product.
+type productPromotion struct {
+	// regularPrice is the price that the product reverts to once the
+	// promotion has ended.
+	regularPrice int
+	endsAt       time.Time
+}
+
+// productPriceChange is a price change along with the updated product.
+type productPriceChange struct {
+	change  fake.PriceChange
+	product fake.Product
+}
+
+// ChangePricesPeriodically changes the price of a random product in the
+// configured interval and ends all promotions that are due, until the given
+// context is done.
+func (svc *ProductCatalogService) ChangePricesPeriodically(ctx context.Context) {
+	if !svc.cfg.PriceChanges.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(svc.clock.realDuration(svc.cfg.PriceChanges.Interval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, change := range svc.endPromotions() {
+				svc.producePriceChange(change)
+			}
+			if change, ok := svc.changePrice(); ok {
+				svc.producePriceChange(change)
+			}
+		}
+	}
+}
+
+// changePrice either puts a random product on sale or updates its regular
+// price in the catalog. Products that are on sale already are not changed.
+// It returns false if no price has been changed.
+func (svc *ProductCatalogService) changePrice() (productPriceChange, bool) {
+	cfg := svc.cfg.PriceChanges
+	now := svc.clock.now()
+
+	svc.productsMu.Lock()
+	defer svc.productsMu.Unlock()
+
+	if len(svc.products) == 0 {
+		return productPriceChange{}, false
+	}
+	i := rand.Intn(len(svc.products))
+	product := svc.products[i]
+	if _, ok := svc.promotions[product.ID]; ok {
+		return productPriceChange{}, false
+	}
+
+	var change fake.PriceChange
+	if rand.Float64() < cfg.PromotionRatio {
+		duration := cfg.MinPromotionDuration
+		if spread := cfg.MaxPromotionDuration - cfg.MinPromotionDuration; spread > 0 {
+			duration += time.Duration(rand.Int63n(int64(spread)))
+		}
+		change = fake.NewPromotionStart(product, gofakeit.Number(cfg.MinDiscountPercent, cfg.MaxDiscountPercent), now, duration)
+		svc.promotions[product.ID] = productPromotion{
+			regularPrice: product.Price,
+			endsAt:       *change.PromotionEndsAt,
+		}
+	} else {
+		percent := gofakeit.Number(1, cfg.MaxChangePercent)
+		if gofakeit.Bool() {
+			percent = -percent
+		}
+		newPrice := product.Price * (100 + percent) / 100
+		if newPrice < 1 {
+			newPrice = 1
+		}
+		change = fake.NewPriceUpdate(product, newPrice, now)
+	}
+
+	product.Price = change.NewPrice
+	product.Revision++
+	svc.products[i] = product
+
+	return productPriceChange{change: change, product: product}, true
+}
+
+// endPromotions reverts all products whose promotion is due to their regular
+// price in the catalog and returns the price changes. The promotions of
+// products that are no longer in the catalog are dropped.
+func (svc *ProductCatalogService) endPromotions() []productPriceChange {
+	now := svc.clock.now()
+
+	svc.productsMu.Lock()
+	defer svc.productsMu.Unlock()
+
+	var changes []productPriceChange
+	running := 0
+	for i, product := range svc.products {
+		promotion, ok := svc.promotions[product.ID]
+		if !ok {
+			continue
+		}
+		if promotion.endsAt.After(now) {
+			running++
+			continue
+		}
+		delete(svc.promotions, product.ID)
+
+		change := fake.NewPromotionEnd(product, promotion.regularPrice, now)
+		product.Price = change.NewPrice
+		product.Revision++
+		svc.products[i] = product
+		changes = append(changes, productPriceChange{change: change, product: product})
+	}
+
+	// Promotions of products that are not in the catalog would never end
+	if len(svc.promotions) > running {
+		products := make(map[string]bool, len(svc.products))
+		for _, product := range svc.products {
+			products[product.ID] = true
+		}
+		for productID := range svc.promotions {
+			if !products[productID] {
+				delete(svc.promotions, productID)
+			}
+		}
+	}
+
+	return changes
+}
+
+// producePriceChange produces the given price change to the price changes
+// topic and the updated product to the products topic.
+func (svc *ProductCatalogService) producePriceChange(change productPriceChange) {
+	eventType := priceChangeTypeMetricLabels[change.change.Type]
+	ctx := withEventType(context.Background(), eventType)
+
+	if err := svc.producePriceChangeRecord(ctx, change.change); err != nil {
+		svc.logger.Warn("failed to produce price change", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+
+	err := svc.produceProduct(withEventType(context.Background(), EventTypeProductModified), change.product)
+	if err != nil {
+		svc.logger.Warn("failed to produce product", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeProductModified}).Inc()
+}
+
+func (svc *ProductCatalogService) producePriceChangeRecord(ctx context.Context, change fake.PriceChange) error {
+	serialized, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to serialize price change struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNamePriceChanges, change.SKU, ""),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(change.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNamePriceChanges,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
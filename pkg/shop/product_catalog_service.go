@@ -0,0 +1,322 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// ProductCatalogService emulates the service that manages the shop's product
+// catalog. It produces all products to a compacted topic, keyed by the product
+// ID, and occasionally adds new products or updates the stock count of existing
+// ones. If price changes are enabled, it also updates the prices of existing
+// products and puts some of them on sale for a while. Other services (e.g. the
+// OrderService) can pick products from the in-memory catalog, so that records
+// across topics reference the same products.
+type ProductCatalogService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory *kafka.Factory
+	metrics      *clientMetrics
+	metaClient   *kgo.Client
+	producer     recordProducer
+	serde        *TopicSerde
+
+	// pricing is the price model of the catalog's products, which the order
+	// and cart services use to price their line items.
+	pricing fake.Pricing
+
+	initialCatalogSize int
+	maxCatalogSize     int
+	productsMu         sync.RWMutex
+	products           []fake.Product
+	// promotions are the running promotions, keyed by the product id. They
+	// are guarded by the products mutex.
+	promotions map[string]productPromotion
+
+	topicName             string
+	topicNameMedia        string
+	topicNamePriceChanges string
+}
+
+// NewProductCatalogService creates a new ProductCatalogService.
+func NewProductCatalogService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
+) (*ProductCatalogService, error) {
+	pricing := newPricing(cfg.Pricing)
+
+	clientID := cfg.Services.ProductCatalog.ClientIDFor(cfg.GlobalPrefix, "product-catalog-service")
+	metrics := newClientMetrics("product_catalog_service")
+	headers := newRecordHeaders(cfg.Headers, "product-catalog-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "product-catalog-service")
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	opts := []kgo.Opt{kgo.WithHooks(hooks...)}
+	// The max batch size must fit the largest product media record, unless a
+	// larger one is configured already
+	batchMaxBytes := int32(cfg.LargeMessages.MaxBytes + productMediaBatchOverhead)
+	if cfg.LargeMessages.Enabled && kafkaFactory.Config.Producer.BatchMaxBytes < batchMaxBytes {
+		opts = append(opts, kgo.ProducerBatchMaxBytes(batchMaxBytes))
+	}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// The catalog is seeded with some products on startup and may grow up to the
+	// max catalog size while the shop is running.
+	initialCatalogSize := 250
+	maxCatalogSize := 1000
+
+	return &ProductCatalogService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "product_catalog_service")),
+		clock:  clock,
+
+		kafkaFactory: kafkaFactory,
+		metrics:      metrics,
+		metaClient:   metaClient,
+		producer:     producer,
+		serde:        serdes.Products,
+
+		pricing: pricing,
+
+		initialCatalogSize: initialCatalogSize,
+		maxCatalogSize:     maxCatalogSize,
+		productsMu:         sync.RWMutex{},
+		products:           make([]fake.Product, 0, maxCatalogSize),
+		promotions:         make(map[string]productPromotion),
+
+		topicName:             cfg.TopicName("products"),
+		topicNameMedia:        cfg.TopicName("product-media"),
+		topicNamePriceChanges: cfg.TopicName("price-changes"),
+	}, nil
+}
+
+// Initialize creates the products topic with cleanup policy compact and
+// seeds the initial product catalog. The price changes topic keeps the
+// history of all prices and is created with cleanup policy delete.
+func (svc *ProductCatalogService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing product catalog service")
+
+	err := reconcileTopic(
+		ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("compact"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	if svc.cfg.LargeMessages.Enabled {
+		err := reconcileTopic(
+			ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNameMedia,
+			map[string]*string{
+				"cleanup.policy":    kadm.StringPtr("compact"),
+				"max.message.bytes": kadm.StringPtr(strconv.Itoa(svc.productMediaMaxBytes())),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile media topic: %w", err)
+		}
+	}
+
+	if svc.cfg.PriceChanges.Enabled {
+		err := reconcileTopic(
+			ctx,
+			svc.cfg,
+			svc.metaClient,
+			svc.topicNamePriceChanges,
+			map[string]*string{
+				"cleanup.policy": kadm.StringPtr("delete"),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile price changes topic: %w", err)
+		}
+	}
+
+	for i := 0; i < svc.initialCatalogSize; i++ {
+		svc.CreateProduct()
+	}
+
+	svc.logger.Info("successfully initialized product catalog service",
+		zap.Int("catalog_size", svc.initialCatalogSize))
+
+	return nil
+}
+
+// Close flushes all buffered records and closes the Kafka client.
+func (svc *ProductCatalogService) Close(ctx context.Context) error {
+	return closeClients(ctx, nil, nil, nil, svc.producer, svc.metaClient)
+}
+
+// CreateProduct adds a new fake product to the catalog and produces the
+// serialized product to the products topic. Once the catalog has reached its
+// max size no further products will be added.
+func (svc *ProductCatalogService) CreateProduct() {
+	product := fake.NewProduct(svc.pricing, svc.clock.now())
+	svc.productsMu.Lock()
+	if len(svc.products) >= svc.maxCatalogSize {
+		svc.productsMu.Unlock()
+		return
+	}
+	svc.products = append(svc.products, product)
+	svc.productsMu.Unlock()
+
+	err := svc.produceProduct(withEventType(context.Background(), EventTypeProductCreated), product)
+	if err != nil {
+		svc.logger.Warn("failed to produce product", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeProductCreated}).Inc()
+	svc.produceMediaSometimes(context.Background(), product)
+}
+
+// ModifyProduct picks a random product from the catalog, changes its stock count
+// and sends the updated product version to the products topic.
+func (svc *ProductCatalogService) ModifyProduct() {
+	svc.productsMu.Lock()
+	if len(svc.products) == 0 {
+		svc.productsMu.Unlock()
+		svc.logger.Debug("skipped modifying product, because the catalog is empty")
+		return
+	}
+	i := rand.Intn(len(svc.products))
+	svc.products[i].StockCount = gofakeit.Number(0, 5000)
+	svc.products[i].Revision++
+	product := svc.products[i]
+	svc.productsMu.Unlock()
+
+	err := svc.produceProduct(withEventType(context.Background(), EventTypeProductModified), product)
+	if err != nil {
+		svc.logger.Warn("failed to produce product", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeProductModified}).Inc()
+	svc.produceMediaSometimes(context.Background(), product)
+}
+
+// RandomProducts returns up to count distinct products from the catalog.
+func (svc *ProductCatalogService) RandomProducts(count int) []fake.Product {
+	svc.productsMu.RLock()
+	defer svc.productsMu.RUnlock()
+
+	if count > len(svc.products) {
+		count = len(svc.products)
+	}
+	products := make([]fake.Product, count)
+	for i, j := range rand.Perm(len(svc.products))[:count] {
+		products[i] = svc.products[j]
+	}
+
+	return products
+}
+
+// DecrementedStock returns updated versions of the ordered products, with the
+// ordered quantities subtracted from their stock count. The catalog itself is
+// not changed until the updated products are applied via ApplyProducts, so that
+// products of aborted transactions can be discarded.
+func (svc *ProductCatalogService) DecrementedStock(items []fake.OrderLineItem) []fake.Product {
+	svc.productsMu.RLock()
+	defer svc.productsMu.RUnlock()
+
+	products := make([]fake.Product, 0, len(items))
+	for _, item := range items {
+		for _, product := range svc.products {
+			if product.ID != item.ArticleID {
+				continue
+			}
+			product.StockCount -= item.Quantity
+			if product.StockCount < 0 {
+				product.StockCount = 0
+			}
+			product.Revision++
+			products = append(products, product)
+			break
+		}
+	}
+
+	return products
+}
+
+// ApplyProducts replaces the catalog's products with the given updated versions.
+// Products that have been modified in the meantime are skipped.
+func (svc *ProductCatalogService) ApplyProducts(products []fake.Product) {
+	svc.productsMu.Lock()
+	defer svc.productsMu.Unlock()
+
+	for _, product := range products {
+		for i := range svc.products {
+			if svc.products[i].ID == product.ID && svc.products[i].Revision+1 == product.Revision {
+				svc.products[i] = product
+				break
+			}
+		}
+	}
+}
+
+// productRecord returns the record for the given product.
+func (svc *ProductCatalogService) productRecord(product fake.Product) (*kgo.Record, error) {
+	serialized, err := svc.serde.Encode(product)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize product struct: %w", err)
+	}
+
+	return &kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, product.ID, ""),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "revision", Value: []byte(strconv.Itoa(product.Revision))}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}, nil
+}
+
+func (svc *ProductCatalogService) produceProduct(ctx context.Context, product fake.Product) error {
+	rec, err := svc.productRecord(product)
+	if err != nil {
+		return err
+	}
+
+	svc.producer.Produce(ctx, rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// productMediaBatchOverhead is the room that is left for the record and batch
+// overhead of the Kafka protocol beyond the max size of a product media record.
+const productMediaBatchOverhead = 64 * 1024
+
+// productMediaMaxBytes returns the max.message.bytes of the product-media topic
+// and the max batch size of the producer.
+func (svc *ProductCatalogService) productMediaMaxBytes() int {
+	return svc.cfg.LargeMessages.MaxBytes + productMediaBatchOverhead
+}
+
+// produceMediaSometimes produces the media of the given product with the
+// configured ratio. The record size is spread evenly between the configured
+// bounds.
+func (svc *ProductCatalogService) produceMediaSometimes(ctx context.Context, product fake.Product) {
+	cfg := svc.cfg.LargeMessages
+	if !cfg.Enabled || rand.Float64() >= cfg.Ratio {
+		return
+	}
+
+	size := cfg.MinBytes + rand.Intn(cfg.MaxBytes-cfg.MinBytes+1)
+	media := fake.NewProductMedia(product, size, svc.clock.now())
+	if err := svc.produceMedia(withEventType(ctx, EventTypeProductMediaCreated), media); err != nil {
+		svc.logger.Warn("failed to produce product media", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeProductMediaCreated}).Inc()
+}
+
+func (svc *ProductCatalogService) produceMedia(ctx context.Context, media fake.ProductMedia) error {
+	serialized, err := json.Marshal(media)
+	if err != nil {
+		return fmt.Errorf("failed to serialize product media struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicNameMedia, media.ProductID, ""),
+		Value:     serialized,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicNameMedia,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Int("record_bytes", len(rec.Value)),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// rebalanceTarget is a consumer group that extra members can join.
+type rebalanceTarget struct {
+	// topic is the name of the consumed topic without the topic prefix.
+	topic string
+	// cluster is the name of the cluster of the service that owns the group.
+	cluster string
+}
+
+// rebalanceTargets returns the consumer groups of all services that consume the
+// customers or orders topic, keyed by the group name without the group prefix.
+func rebalanceTargets(services config.Services) map[string]rebalanceTarget {
+	return map[string]rebalanceTarget{
+		"address-service":   {topic: "customers", cluster: services.Address.Cluster},
+		"cart-service":      {topic: "customers", cluster: services.Cart.Cluster},
+		"order-service":     {topic: "customers", cluster: services.Order.Cluster},
+		"inventory-service": {topic: "orders", cluster: services.Inventory.Cluster},
+		"payment-service":   {topic: "orders", cluster: services.Payment.Cluster},
+		"review-service":    {topic: "orders", cluster: services.Review.Cluster},
+		"shipment-service":  {topic: "orders", cluster: services.Shipment.Cluster},
+	}
+}
+
+// rebalancer periodically lets extra members join and leave the consumer
+// groups of the services, so that rebalances, generation bumps and partition
+// reassignments can be observed. The extra members pause fetching right away
+// and never commit, so that no records of the services get lost.
+type rebalancer struct {
+	cfg    config.Shop
+	logger *zap.Logger
+
+	kafkaFactories map[string]*kafka.Factory
+	targets        map[string]rebalanceTarget
+	// groups are the names of the targets, sorted so that seeded runs pick
+	// the same groups.
+	groups []string
+}
+
+func newRebalancer(cfg config.Shop, logger *zap.Logger, kafkaFactories map[string]*kafka.Factory) (*rebalancer, error) {
+	targets := rebalanceTargets(cfg.Services)
+
+	groups := cfg.Rebalances.Groups
+	if len(groups) == 0 {
+		for group := range targets {
+			groups = append(groups, group)
+		}
+	}
+	for _, group := range groups {
+		if _, ok := targets[group]; !ok {
+			return nil, fmt.Errorf("extra members can't join consumer group '%v'", group)
+		}
+	}
+	groups = append([]string(nil), groups...)
+	sort.Strings(groups)
+
+	return &rebalancer{
+		cfg:            cfg,
+		logger:         logger.With(zap.String("service", "rebalancer")),
+		kafkaFactories: kafkaFactories,
+		targets:        targets,
+		groups:         groups,
+	}, nil
+}
+
+// rebalancePeriodically lets extra members join a random consumer group in
+// each configured interval until the given context is cancelled.
+func (r *rebalancer) rebalancePeriodically(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Rebalances.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.rebalance(ctx, r.groups[rand.Intn(len(r.groups))])
+		}
+	}
+}
+
+// rebalance lets the extra members join the given group and blocks until they
+// have left it again after the configured duration or until the context is
+// cancelled.
+func (r *rebalancer) rebalance(ctx context.Context, group string) {
+	target := r.targets[group]
+	topicName := r.cfg.TopicName(target.topic)
+	logger := r.logger.With(zap.String("group", group))
+
+	members := make([]*kgo.Client, 0, r.cfg.Rebalances.ExtraMembers)
+	defer func() {
+		for _, member := range members {
+			member.Close()
+		}
+		if len(members) > 0 {
+			logger.Info("extra group members left", zap.Int("members", len(members)))
+		}
+	}()
+
+	for i := 0; i < r.cfg.Rebalances.ExtraMembers; i++ {
+		// Static members would neither trigger a rebalance when they join
+		// with a known instance ID nor when they leave
+		member, err := r.kafkaFactories[target.cluster].WithoutInstanceID().NewKafkaClient(
+			r.cfg.GlobalPrefix+"rebalancer",
+			kgo.ConsumerGroup(r.cfg.GroupID(group)),
+			kgo.ConsumeTopics(topicName),
+			kgo.DisableAutoCommit(),
+		)
+		if err != nil {
+			logger.Warn("failed to create extra group member", zap.Error(err))
+			return
+		}
+		member.PauseFetchTopics(topicName)
+		members = append(members, member)
+		kafkaGroupMembersJoinedTotal.With(map[string]string{"group": group}).Inc()
+	}
+	logger.Info("extra group members joined", zap.Int("members", len(members)))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(r.cfg.Rebalances.Duration):
+	}
+}
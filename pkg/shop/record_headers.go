@@ -0,0 +1,108 @@
+package shop
+
+import (
+	"context"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+const (
+	headerEventType = "event_type"
+	headerSource    = "source"
+	headerVersion   = "version"
+)
+
+// eventTypeKey is the context key of the event type of a record.
+type eventTypeKey struct{}
+
+// recordHeaders adds the configured headers to all records that are produced
+// by a service's Kafka clients and traces each produced record as a span of
+// the trace in the context that is passed to Produce. The event type is passed
+// via the same context. It is registered as a hook on all producing Kafka
+// clients of a service.
+type recordHeaders struct {
+	cfg    config.Headers
+	source string
+	tracer trace.Tracer
+}
+
+var (
+	_ kgo.HookProduceRecordBuffered   = (*recordHeaders)(nil)
+	_ kgo.HookProduceRecordUnbuffered = (*recordHeaders)(nil)
+)
+
+func newRecordHeaders(cfg config.Headers, source string, tracing *tracing) *recordHeaders {
+	return &recordHeaders{cfg: cfg, source: source, tracer: tracing.tracer(source)}
+}
+
+// hook returns the client option that registers the headers hook.
+func (h *recordHeaders) hook() kgo.Opt {
+	return kgo.WithHooks(h)
+}
+
+// OnProduceRecordBuffered starts the span of the record and adds the headers
+// before the record is buffered. It is called synchronously within Produce, so
+// that the record can still be modified.
+func (h *recordHeaders) OnProduceRecordBuffered(r *kgo.Record) {
+	ctx, _ := h.tracer.Start(r.Context, r.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(r.Topic),
+		),
+	)
+	r.Context = ctx
+
+	if !h.cfg.Enabled {
+		return
+	}
+
+	if eventType, ok := r.Context.Value(eventTypeKey{}).(string); ok {
+		setHeaderIfAbsent(r, headerEventType, eventType)
+	}
+	setHeaderIfAbsent(r, headerSource, h.source)
+	setHeaderIfAbsent(r, headerVersion, h.cfg.Version)
+	if h.cfg.TraceContext {
+		traceContextPropagator.Inject(r.Context, recordCarrier{r})
+	}
+	for key, value := range h.cfg.Static {
+		setHeaderIfAbsent(r, key, value)
+	}
+}
+
+// OnProduceRecordUnbuffered ends the span of the record with the produce
+// result.
+func (h *recordHeaders) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	span := trace.SpanFromContext(r.Context)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(
+			semconv.MessagingKafkaDestinationPartition(int(r.Partition)),
+			semconv.MessagingKafkaMessageOffset(int(r.Offset)),
+		)
+	}
+	span.End()
+}
+
+// withEventType returns a context for producing a record of the given event
+// type.
+func withEventType(ctx context.Context, eventType string) context.Context {
+	return context.WithValue(ctx, eventTypeKey{}, eventType)
+}
+
+func setHeaderIfAbsent(r *kgo.Record, key string, value string) {
+	for _, header := range r.Headers {
+		if header.Key == key {
+			return
+		}
+	}
+	r.Headers = append(r.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+}
@@ -0,0 +1,35 @@
+package shop
+
+import (
+	"strings"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// topicOverride returns the overrides of the given topic, which are keyed by
+// the topic name without the topic prefix.
+func topicOverride(cfg config.Shop, topicName string) config.Topic {
+	return cfg.Topics[strings.TrimPrefix(topicName, cfg.TopicNamePrefix())]
+}
+
+// recordKey returns the key of a record that is produced to the given topic
+// according to the topic's key strategy. The entity id is the topic's default
+// key. The customer id is empty for records that do not refer to a customer,
+// in which case the customer and composite strategies fall back to the entity
+// id. So do composite keys of entities that are customers themselves, so that
+// consumers of the customer keyed topics can rely on the key.
+func recordKey(cfg config.Shop, topicName string, entityID string, customerID string) []byte {
+	switch topicOverride(cfg, topicName).Key {
+	case config.KeyStrategyNull:
+		return nil
+	case config.KeyStrategyCustomer:
+		if customerID != "" {
+			return []byte(customerID)
+		}
+	case config.KeyStrategyComposite:
+		if customerID != "" && customerID != entityID {
+			return []byte(customerID + ":" + entityID)
+		}
+	}
+	return []byte(entityID)
+}
@@ -0,0 +1,79 @@
+package shop
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// recordLog logs a sample of all produced records at debug level. The number
+// of logged records is limited by a token bucket that is shared by all
+// clients, sampled records beyond its rate are suppressed.
+type recordLog struct {
+	cfg     config.RecordLog
+	logger  *zap.Logger
+	limiter *rate.Limiter
+
+	// suppressed is the number of sampled records that have not been logged
+	// since the last logged record.
+	suppressed atomic.Int64
+}
+
+var _ kgo.HookProduceRecordUnbuffered = (*recordLog)(nil)
+
+func newRecordLog(cfg config.RecordLog, logger *zap.Logger) *recordLog {
+	return &recordLog{
+		cfg:     cfg,
+		logger:  logger,
+		limiter: rate.NewLimiter(rate.Limit(cfg.MaxPerSecond), burstSize(cfg.MaxPerSecond, 0)),
+	}
+}
+
+// OnProduceRecordUnbuffered logs the record with the configured sample ratio
+// once it has been acknowledged or failed to be produced.
+func (l *recordLog) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	if !l.cfg.Enabled || !l.logger.Core().Enabled(zap.DebugLevel) || rand.Float64() >= l.cfg.SampleRatio {
+		return
+	}
+	if !l.limiter.Allow() {
+		l.suppressed.Add(1)
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("topic_name", r.Topic),
+		zap.Int32("partition", r.Partition),
+		zap.Int64("offset", r.Offset),
+		zap.ByteString("key", r.Key),
+		zap.Int("key_bytes", len(r.Key)),
+		zap.Int("value_bytes", len(r.Value)),
+		zap.Int("headers", len(r.Headers)),
+		zap.Time("timestamp", r.Timestamp),
+	}
+	payload := r.Value
+	if len(payload) > l.cfg.MaxPayloadBytes {
+		payload = payload[:l.cfg.MaxPayloadBytes]
+		fields = append(fields, zap.Bool("truncated", true))
+	}
+	// Binary payloads, e.g. Avro or Protobuf, are logged base64 encoded
+	if utf8.Valid(payload) {
+		fields = append(fields, zap.ByteString("payload", payload))
+	} else {
+		fields = append(fields, zap.Binary("payload", payload))
+	}
+	if suppressed := l.suppressed.Swap(0); suppressed > 0 {
+		fields = append(fields, zap.Int64("suppressed", suppressed))
+	}
+	if err != nil {
+		l.logger.Debug("failed to produce record", append(fields, zap.Error(err))...)
+		return
+	}
+
+	l.logger.Debug("produced record", fields...)
+}
@@ -0,0 +1,102 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// recordProducer produces records asynchronously. It is implemented by
+// kgo.Client for the kafka protocol and by httpProxyProducer for the http
+// protocol, so that services produce their records regardless of the
+// configured protocol.
+type recordProducer interface {
+	// Produce produces the record and calls the promise once it has been
+	// acknowledged or failed.
+	Produce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error))
+
+	// Flush waits until all produced records have been acknowledged.
+	Flush(ctx context.Context) error
+}
+
+// newRecordProducer returns the producer of a service, which uses the
+// protocol of the factory's producer config. The given meta client is
+// returned for the kafka protocol. For the http protocol, the records are sent
+// to the cluster's HTTP proxy, calling the hooks of the factory and the given
+// hooks of the meta client. If producer failures are enabled, the producer
+// injects them, see kafka.Factory.WithInjectedFailures. If the given late
+// records are not nil, the producer holds back the late records.
+func newRecordProducer(cfg config.Shop, kafkaFactory *kafka.Factory, metaClient *kgo.Client, hooks []kgo.Hook, late *lateRecords, logger *zap.Logger) (recordProducer, error) {
+	producer, err := newProtocolProducer(cfg, kafkaFactory, metaClient, hooks, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return late.producer(kafkaFactory.WithInjectedFailures(producer, countInjectedFailure)), nil
+}
+
+// countInjectedFailure counts an injected failure of a record's attempt as a
+// retry, or as a failure once its retries are exhausted. Kafka errors are
+// labeled by their name, all other errors by their message.
+func countInjectedFailure(r *kgo.Record, err error, retried bool) {
+	message := err.Error()
+	var kafkaErr *kerr.Error
+	if errors.As(err, &kafkaErr) {
+		message = kafkaErr.Message
+	}
+	labels := prometheus.Labels{"topic": r.Topic, "error": message}
+	if retried {
+		kafkaInjectedProduceRetriesTotal.With(labels).Inc()
+		return
+	}
+	kafkaInjectedProduceFailuresTotal.With(labels).Inc()
+}
+
+// newProtocolProducer returns the producer of the factory's producer
+// protocol. If outage tolerance is enabled, the meta client is wrapped by a
+// droppingProducer.
+func newProtocolProducer(cfg config.Shop, kafkaFactory *kafka.Factory, metaClient *kgo.Client, hooks []kgo.Hook, logger *zap.Logger) (recordProducer, error) {
+	if kafkaFactory.Config.Producer.Protocol != config.ProducerProtocolHTTP {
+		return clientProducer(kafkaFactory.Config, metaClient), nil
+	}
+
+	if kafkaFactory.Config.HTTPProxy.Address == "" {
+		return nil, fmt.Errorf("producing with the http protocol requires an http proxy address of the cluster")
+	}
+
+	hooks = append(append([]kgo.Hook{}, kafkaFactory.Hooks()...), hooks...)
+	producer, err := newHTTPProxyProducer(kafkaFactory.Config, metaClient, hooks, newTopicPartitioner(cfg), logger.Named("http_proxy_producer"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http proxy producer: %w", err)
+	}
+
+	return producer, nil
+}
+
+// clientProducer returns the given client, or a droppingProducer of it if
+// outage tolerance is enabled for the cluster.
+func clientProducer(cfg config.Kafka, client *kgo.Client) recordProducer {
+	if cfg.OutageTolerance.Enabled {
+		return droppingProducer{client}
+	}
+	return client
+}
+
+// droppingProducer fails records with kgo.ErrMaxBuffered if the client's
+// buffer is full, rather than blocking until buffered records have been
+// acknowledged.
+type droppingProducer struct {
+	*kgo.Client
+}
+
+func (p droppingProducer) Produce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error)) {
+	p.TryProduce(ctx, r, promise)
+}
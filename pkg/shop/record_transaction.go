@@ -0,0 +1,63 @@
+package shop
+
+import (
+	"context"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// recordTransactionKey is the context key of the transaction that a record is
+// produced in.
+type recordTransactionKey struct{}
+
+// recordTransaction defers the handling of the acknowledged records of a Kafka
+// transaction by hooks that mirror the records elsewhere, e.g. the webhook
+// sink, until the transaction has been committed. Records of aborted
+// transactions are never seen by read committed consumers, so they are
+// discarded.
+type recordTransaction struct {
+	mu       sync.Mutex
+	deferred []func()
+}
+
+// withRecordTransaction returns a context for producing the records of the
+// given transaction.
+func withRecordTransaction(ctx context.Context, txn *recordTransaction) context.Context {
+	return context.WithValue(ctx, recordTransactionKey{}, txn)
+}
+
+// deferUntilCommitted defers fn until the transaction of the record has been
+// committed. It returns false without deferring fn if the record has not been
+// produced in a transaction, in which case fn should be called right away.
+func deferUntilCommitted(r *kgo.Record, fn func()) bool {
+	if r.Context == nil {
+		return false
+	}
+	txn, ok := r.Context.Value(recordTransactionKey{}).(*recordTransaction)
+	if !ok {
+		return false
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	txn.deferred = append(txn.deferred, fn)
+	return true
+}
+
+// end runs the deferred functions if the transaction has been committed and
+// discards them otherwise. All records of the transaction must have been
+// acknowledged.
+func (t *recordTransaction) end(committed bool) {
+	t.mu.Lock()
+	deferred := t.deferred
+	t.deferred = nil
+	t.mu.Unlock()
+
+	if !committed {
+		return
+	}
+	for _, fn := range deferred {
+		fn()
+	}
+}
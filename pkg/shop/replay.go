@@ -0,0 +1,404 @@
+package shop
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hamba/avro"
+	"github.com/hamba/avro/ocf"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+	"github.com/cloudhut/owl-shop/pkg/storage"
+)
+
+// ReplayOptions configure a replay run.
+type ReplayOptions struct {
+	// Paths are the recorded NDJSON or Avro files of the file sink, which are
+	// replayed in the given order. All files of a directory are replayed in
+	// the order in which they have been recorded. Paths may also be s3://,
+	// gs:// or azblob:// URLs of objects or of prefixes whose files are all
+	// replayed, which are accessed with the file sink's storage config.
+	Paths []string
+	// Speed is the factor by which the recorded timing is accelerated, e.g. 2
+	// replays a recording of an hour within 30 minutes. 0 produces all
+	// records as fast as possible.
+	Speed float64
+	// KeepTimestamps produces the records with their recorded timestamps
+	// rather than the time at which they are replayed.
+	KeepTimestamps bool
+	// FlushTimeout bounds the flush of the buffered records once all records
+	// have been produced or the replay has been stopped. Defaults to 10s.
+	FlushTimeout time.Duration
+}
+
+// ReplayResult is the outcome of a replay run.
+type ReplayResult struct {
+	// Records is the number of acknowledged records.
+	Records int64
+	// Errors is the number of records that failed to be produced.
+	Errors  int64
+	Elapsed time.Duration
+}
+
+// Replay produces all records of the recorded files to the topics and
+// partitions that they have been recorded from on the default cluster, with
+// their keys, headers and values as they have been produced. The topics must
+// have at least as many partitions as they had when the records have been
+// recorded. The records are produced with their recorded timing, accelerated
+// by the configured speed. It bypasses the traffic simulation and all services
+// and returns once all records have been produced or the context has been
+// cancelled.
+//
+// Values are replayed byte by byte, so the schema IDs of serialized values
+// refer to the schema registry that they have been recorded with. If the
+// default cluster uses another schema registry, the IDs dangle or refer to
+// other schemas, unless the schemas have been registered with the same IDs.
+// Replay does not register any schema.
+func Replay(ctx context.Context, cfg config.Config, logger *zap.Logger, opts ReplayOptions) (ReplayResult, error) {
+	if len(opts.Paths) == 0 {
+		return ReplayResult{}, fmt.Errorf("at least one file or directory must be given")
+	}
+	if opts.Speed < 0 {
+		return ReplayResult{}, fmt.Errorf("speed must not be negative")
+	}
+	files, err := replayFiles(ctx, cfg.Shop.FileSink.Storage, opts.Paths)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	factory := kafka.NewFactory(cfg.Kafka, logger.Named("kafka_client"))
+	client, err := factory.NewKafkaClient(cfg.Shop.GlobalPrefix+"replay", kgo.RecordPartitioner(kgo.ManualPartitioner()))
+	if err != nil {
+		return ReplayResult{}, fmt.Errorf("failed to create producer client: %w", err)
+	}
+	defer client.Close()
+
+	logger.Info("starting replay",
+		zap.Int("files", len(files)),
+		zap.Float64("speed", opts.Speed),
+		zap.Bool("keep_timestamps", opts.KeepTimestamps))
+
+	var records, errs atomic.Int64
+	startedAt := time.Now()
+	var recordedStart time.Time
+	produce := func(rec *kgo.Record) error {
+		if recordedStart.IsZero() {
+			recordedStart = rec.Timestamp
+		}
+		// Records that have been recorded out of order, e.g. late records,
+		// are produced right away
+		if opts.Speed > 0 {
+			offset := time.Duration(float64(rec.Timestamp.Sub(recordedStart)) / opts.Speed)
+			if wait := time.Until(startedAt.Add(offset)); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		if !opts.KeepTimestamps {
+			rec.Timestamp = time.Now()
+		}
+
+		client.Produce(ctx, rec, func(rec *kgo.Record, err error) {
+			switch {
+			case err == nil:
+				records.Add(1)
+			case errors.Is(err, context.Canceled):
+				// Records that are buffered once the replay is stopped
+			default:
+				errs.Add(1)
+				logger.Warn("failed to produce record", zap.String("topic_name", rec.Topic), zap.Error(err))
+			}
+		})
+		return ctx.Err()
+	}
+
+	for _, file := range files {
+		logger.Info("replaying file", zap.String("file_name", file))
+		err := readReplayFile(ctx, cfg.Shop.FileSink.Storage, file, produce)
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			return ReplayResult{}, err
+		}
+	}
+
+	flushTimeout := opts.FlushTimeout
+	if flushTimeout == 0 {
+		flushTimeout = 10 * time.Second
+	}
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelFlush()
+	flushErr := client.Flush(flushCtx)
+
+	result := ReplayResult{
+		Records: records.Load(),
+		Errors:  errs.Load(),
+		Elapsed: time.Since(startedAt),
+	}
+	if flushErr != nil {
+		return result, fmt.Errorf("failed to flush replayed records: %w", flushErr)
+	}
+	logger.Info("completed replay",
+		zap.Int64("records", result.Records),
+		zap.Int64("errors", result.Errors),
+		zap.Duration("elapsed", result.Elapsed))
+
+	return result, nil
+}
+
+// replayFiles resolves the given paths into the files to replay. Directories
+// and object storage prefixes are replaced by the file sink files they
+// contain, which sort by the time they have been created.
+func replayFiles(ctx context.Context, storageCfg config.ObjectStorage, paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		if storage.IsURL(path) {
+			objects, err := replayObjects(ctx, storageCfg, path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, objects...)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open '%v': %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of '%v': %w", path, err)
+		}
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasPrefix(entry.Name(), fileSinkPrefix) {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+
+	return files, nil
+}
+
+// replayObjects returns the URL of the given object, or the URLs of all file
+// sink objects within the given prefix.
+func replayObjects(ctx context.Context, storageCfg config.ObjectStorage, rawURL string) ([]string, error) {
+	if ext := path.Ext(rawURL); ext == ".ndjson" || ext == ".avro" {
+		return []string{rawURL}, nil
+	}
+
+	bucket, prefix, err := storage.Open(storageCfg, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	names, err := bucket.List(ctx, path.Join(prefix, fileSinkPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of '%v': %w", rawURL, err)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+	urls := make([]string, 0, len(names))
+	for _, name := range names {
+		urls = append(urls, (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/" + name}).String())
+	}
+	sort.Strings(urls)
+
+	return urls, nil
+}
+
+// readReplayFile calls fn for each record of the given file or object until
+// fn returns an error. The format is derived from the file extension.
+func readReplayFile(ctx context.Context, storageCfg config.ObjectStorage, filePath string, fn func(rec *kgo.Record) error) error {
+	var r io.ReadCloser
+	if storage.IsURL(filePath) {
+		bucket, name, err := storage.Open(storageCfg, filePath)
+		if err != nil {
+			return err
+		}
+		r, err = bucket.Get(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to download '%v': %w", filePath, err)
+		}
+	} else {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		r = f
+	}
+	defer r.Close()
+
+	switch path.Ext(filePath) {
+	case ".ndjson":
+		return readReplayNDJSON(r, fn)
+	case ".avro":
+		return readReplayAvro(r, fn)
+	default:
+		return fmt.Errorf("failed to replay '%v': file extension must be either .ndjson or .avro", filePath)
+	}
+}
+
+// readReplayNDJSON reads one webhook event per line.
+func readReplayNDJSON(r io.Reader, fn func(rec *kgo.Record) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var event webhookEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				return fmt.Errorf("failed to deserialize record: %w", err)
+			}
+			rec := &kgo.Record{
+				Topic:     event.Topic,
+				Partition: event.Partition,
+				Timestamp: event.Timestamp,
+			}
+			if event.Key != "" {
+				rec.Key = []byte(event.Key)
+			}
+			switch {
+			case event.Tombstone:
+			case event.ValueBase64 != nil:
+				rec.Value = event.ValueBase64
+			default:
+				rec.Value = []byte(event.Value)
+			}
+			for _, header := range event.Headers {
+				rec.Headers = append(rec.Headers, kgo.RecordHeader{Key: header.Key, Value: []byte(header.Value)})
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+}
+
+// readReplayAvro reads the sampled records of an Avro object container file.
+func readReplayAvro(r io.Reader, fn func(rec *kgo.Record) error) error {
+	decoder, err := ocf.NewDecoder(r)
+	if err != nil {
+		return fmt.Errorf("failed to create avro decoder: %w", err)
+	}
+	legacy, err := hasLegacyHeaders(decoder)
+	if err != nil {
+		return err
+	}
+	for decoder.HasNext() {
+		var record sampledRecord
+		if legacy {
+			var legacyRecord legacySampledRecord
+			if err := decoder.Decode(&legacyRecord); err != nil {
+				return fmt.Errorf("failed to deserialize record: %w", err)
+			}
+			record = legacyRecord.sampledRecord()
+		} else if err := decoder.Decode(&record); err != nil {
+			return fmt.Errorf("failed to deserialize record: %w", err)
+		}
+		rec := &kgo.Record{
+			Topic:     record.Topic,
+			Partition: record.Partition,
+			Key:       record.Key,
+			Value:     record.Value,
+			Timestamp: record.Timestamp,
+		}
+		if record.Tombstone {
+			rec.Value = nil
+		}
+		for _, header := range record.Headers {
+			rec.Headers = append(rec.Headers, kgo.RecordHeader{Key: header.Key, Value: []byte(header.Value)})
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	if err := decoder.Error(); err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return nil
+}
+
+// legacySampledRecord is a record of the Avro files of previous versions,
+// whose headers are a map that kept a single value per key.
+type legacySampledRecord struct {
+	Topic     string            `avro:"topic"`
+	Partition int32             `avro:"partition"`
+	Offset    int64             `avro:"offset"`
+	Timestamp time.Time         `avro:"timestamp"`
+	Key       []byte            `avro:"key"`
+	Headers   map[string]string `avro:"headers"`
+	Value     []byte            `avro:"value"`
+	Tombstone bool              `avro:"tombstone"`
+}
+
+func (r legacySampledRecord) sampledRecord() sampledRecord {
+	record := sampledRecord{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: r.Timestamp,
+		Key:       r.Key,
+		Value:     r.Value,
+		Tombstone: r.Tombstone,
+	}
+	for key, value := range r.Headers {
+		record.Headers = append(record.Headers, sampledHeader{Key: key, Value: value})
+	}
+	return record
+}
+
+// hasLegacyHeaders returns whether the file has been written by a previous
+// version, whose headers are a map.
+func hasLegacyHeaders(decoder *ocf.Decoder) (bool, error) {
+	schema, err := avro.Parse(string(decoder.Metadata()["avro.schema"]))
+	if err != nil {
+		return false, fmt.Errorf("failed to parse schema of file: %w", err)
+	}
+	record, ok := schema.(*avro.RecordSchema)
+	if !ok {
+		return false, fmt.Errorf("schema of file is not a record")
+	}
+	for _, field := range record.Fields() {
+		if field.Name() == "headers" {
+			return field.Type().Type() == avro.Map, nil
+		}
+	}
+	return false, nil
+}
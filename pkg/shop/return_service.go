@@ -0,0 +1,281 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// ReturnService consumes the orders and shipments topics and lets a share of
+// the delivered orders be returned. Each return passes through its lifecycle
+// over several days of simulated time, so that the returns topic contains
+// keyed event streams that reference the original order and line items.
+type ReturnService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	orderSerde      *TopicSerde
+	shipmentSerde   *TopicSerde
+
+	// orders are the consumed orders that have not been delivered yet, so
+	// that the returns of delivered orders can reference their line items.
+	orders *orderBook
+
+	pendingReturns *followUps[pendingReturn]
+
+	topicNameOrders string
+	topicName       string
+}
+
+// pendingReturn is a return whose refund has not been issued yet.
+type pendingReturn struct {
+	r fake.Return
+	// nextStep is the index of the next lifecycle event in fake.ReturnLifecycle.
+	nextStep int
+}
+
+// NewReturnService creates a new ReturnService.
+func NewReturnService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
+) (*ReturnService, error) {
+	clientID := cfg.Services.Return.ClientIDFor(cfg.GlobalPrefix, "return-service")
+	metrics := newClientMetrics("return_service")
+	headers := newRecordHeaders(cfg.Headers, "return-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "return-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Return.Offsets, logger)
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("return-service")),
+			kgo.ConsumeTopics(cfg.TopicName("orders"), cfg.TopicName("shipments")),
+			readCommittedOrders(),
+		)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	return &ReturnService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "return_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Return.SlowConsumer),
+		offsets:         offsets,
+		orderSerde:      serdes.Orders,
+		shipmentSerde:   serdes.Shipments,
+
+		orders:         newOrderBook(cfg.RecentOrders),
+		pendingReturns: newFollowUps[pendingReturn](clock, logger, "return", cfg.PendingFollowUps),
+
+		topicNameOrders: cfg.TopicName("orders"),
+		topicName:       cfg.TopicName("returns"),
+	}, nil
+}
+
+// Initialize return service by reconciling the returns topic.
+func (svc *ReturnService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing return service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized return service")
+
+	return nil
+}
+
+// Close stops consuming, flushes all buffered records and closes the Kafka
+// clients.
+func (svc *ReturnService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// Start consuming the orders and shipments and create a return for a share
+// of the delivered orders. Pending returns are advanced in the background
+// until the consumer has been closed.
+func (svc *ReturnService) Start() {
+	defer close(svc.consumerStopped)
+
+	quit := make(chan struct{})
+	advanceStopped := make(chan struct{})
+	go func() {
+		defer close(advanceStopped)
+		svc.pendingReturns.run(quit, svc.advanceReturn)
+	}()
+	defer func() {
+		close(quit)
+		<-advanceStopped
+	}()
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			if rec.Value == nil {
+				return
+			}
+
+			if rec.Topic == svc.topicNameOrders {
+				kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeOrderConsumed}).Inc()
+				order := fake.Order{}
+				if err := svc.orderSerde.Decode(rec.Value, &order); err != nil {
+					// Skip message
+					svc.logger.Warn("failed to deserialize order", zap.Error(err))
+					return
+				}
+				svc.orders.put(order)
+				return
+			}
+
+			kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeShipmentConsumed}).Inc()
+			event := fake.ShipmentEvent{}
+			if err := svc.shipmentSerde.Decode(rec.Value, &event); err != nil {
+				// Skip message
+				svc.logger.Warn("failed to deserialize shipment event", zap.Error(err))
+				return
+			}
+			if event.Type != fake.ShipmentEventTypeDelivered {
+				return
+			}
+			// Deliveries of orders that haven't been consumed are not returned
+			order, ok := svc.orders.remove(event.Shipment.OrderID)
+			if !ok || len(order.LineItems) == 0 || rand.Float64() >= svc.cfg.Returns.Ratio {
+				return
+			}
+
+			// The processing span only covers buffering the return, the
+			// return events are produced as its children later on
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			dueAt := svc.clock.now().Add(randomDelay(svc.cfg.Returns.MinRequestDelay, svc.cfg.Returns.MaxRequestDelay))
+			svc.pendingReturns.schedule(ctx, pendingReturn{r: fake.NewReturn(order), nextStep: 0}, dueAt)
+			span.End()
+		})
+	}
+}
+
+// advanceReturn produces the next lifecycle event of the given pending
+// return, which is due. Returns whose refund has not been issued yet are
+// scheduled again.
+func (svc *ReturnService) advanceReturn(ctx context.Context, pending pendingReturn, now time.Time) {
+	eventType := fake.ReturnLifecycle[pending.nextStep]
+	if err := svc.produceReturnEvent(ctx, fake.NewReturnEvent(pending.r, eventType, now)); err != nil {
+		svc.logger.Warn("failed to produce return event", zap.Error(err))
+	}
+
+	pending.nextStep++
+	if pending.nextStep < len(fake.ReturnLifecycle) {
+		dueAt := now.Add(randomDelay(svc.cfg.Returns.MinStepDelay, svc.cfg.Returns.MaxStepDelay))
+		svc.pendingReturns.schedule(ctx, pending, dueAt)
+	}
+}
+
+// randomDelay returns a random duration between the given min and max delay.
+func randomDelay(min, max time.Duration) time.Duration {
+	spread := max - min
+	if spread <= 0 {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(spread)))
+}
+
+func (svc *ReturnService) produceReturnEvent(ctx context.Context, event fake.ReturnEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize return event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, event.Return.OrderID, event.Return.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	eventType := returnEventTypeMetricLabels[event.Type]
+	svc.producer.Produce(withEventType(ctx, eventType), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+
+	return nil
+}
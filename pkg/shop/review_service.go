@@ -0,0 +1,334 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// ReviewService produces product reviews to the compacted reviews topic. It
+// consumes the orders topic, so that reviews are written by customers who
+// have actually ordered in the shop. Most reviews are verified purchases that
+// reference the order in which the customer has bought the reviewed product.
+// Reviews are occasionally edited or deleted.
+type ReviewService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	orderSerde      *TopicSerde
+	serde           *TopicSerde
+
+	productCatalog *ProductCatalogService
+
+	bufferSize     int
+	recentOrdersMu sync.Mutex
+	recentOrders   []fake.Order
+
+	recentReviewsMu sync.Mutex
+	recentReviews   []fake.Review
+
+	topicName string
+}
+
+// NewReviewService creates a new ReviewService.
+func NewReviewService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	productCatalog *ProductCatalogService,
+	tracing *tracing,
+	clock *simulationClock,
+) (*ReviewService, error) {
+	clientID := cfg.Services.Review.ClientIDFor(cfg.GlobalPrefix, "review-service")
+	metrics := newClientMetrics("review_service")
+	headers := newRecordHeaders(cfg.Headers, "review-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "review-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Review.Offsets, logger)
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("review-service")),
+			kgo.ConsumeTopics(cfg.TopicName("orders")),
+			readCommittedOrders(),
+		)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	// These slices are used to keep some orders and reviews in the buffer so that
+	// reviews can be written for these orders and be edited or deleted later on
+	bufferSize := 500
+
+	return &ReviewService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "review_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Review.SlowConsumer),
+		offsets:         offsets,
+		orderSerde:      serdes.Orders,
+		serde:           serdes.Reviews,
+
+		productCatalog: productCatalog,
+
+		bufferSize:      bufferSize,
+		recentOrdersMu:  sync.Mutex{},
+		recentOrders:    make([]fake.Order, 0, bufferSize),
+		recentReviewsMu: sync.Mutex{},
+		recentReviews:   make([]fake.Review, 0, bufferSize),
+
+		topicName: cfg.TopicName("reviews"),
+	}, nil
+}
+
+// Initialize review service by reconciling the reviews topic.
+func (svc *ReviewService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing review service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("compact"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized review service")
+
+	return nil
+}
+
+// Close stops consuming the orders topic, flushes all buffered records and
+// closes the Kafka clients.
+func (svc *ReviewService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// Start consuming messages from the orders topic and keep the consumed orders
+// in the buffer, so that they can be reviewed.
+func (svc *ReviewService) Start() {
+	defer close(svc.consumerStopped)
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeOrderConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+
+			order := fake.Order{}
+			err := svc.orderSerde.Decode(rec.Value, &order)
+			if err != nil {
+				// Skip message
+				svc.logger.Warn("failed to deserialize order", zap.Error(err))
+				return
+			}
+
+			svc.recentOrdersMu.Lock()
+			if len(svc.recentOrders) < svc.bufferSize {
+				svc.recentOrders = append(svc.recentOrders, order)
+			}
+			svc.recentOrdersMu.Unlock()
+		})
+	}
+}
+
+// CreateReview takes a consumed order from the buffer and lets its customer
+// review a product. Usually the product is part of the order, so that the
+// review is a verified purchase. Otherwise the customer reviews a random
+// product from the catalog.
+func (svc *ReviewService) CreateReview() {
+	svc.recentOrdersMu.Lock()
+	if len(svc.recentOrders) == 0 {
+		svc.recentOrdersMu.Unlock()
+		svc.logger.Debug("skipped creating review, because no orders are buffered")
+		return
+	}
+	order := svc.recentOrders[0]
+	svc.recentOrders = svc.recentOrders[1:]
+	svc.recentOrdersMu.Unlock()
+
+	var review fake.Review
+	if len(order.LineItems) > 0 && gofakeit.Number(1, 100) <= 80 {
+		item := order.LineItems[rand.Intn(len(order.LineItems))]
+		review = fake.NewReview(item.ArticleID, order.Customer.ID, &order.ID, svc.clock.now())
+	} else {
+		products := svc.productCatalog.RandomProducts(1)
+		if len(products) == 0 {
+			svc.logger.Debug("skipped creating review, because the catalog is empty")
+			return
+		}
+		review = fake.NewReview(products[0].ID, order.Customer.ID, nil, svc.clock.now())
+	}
+
+	svc.recentReviewsMu.Lock()
+	if len(svc.recentReviews) < svc.bufferSize {
+		svc.recentReviews = append(svc.recentReviews, review)
+	}
+	svc.recentReviewsMu.Unlock()
+
+	err := svc.produceReview(withEventType(context.Background(), EventTypeReviewCreated), review)
+	if err != nil {
+		svc.logger.Warn("failed to produce review", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeReviewCreated}).Inc()
+}
+
+// ModifyReview takes an existing review from the buffer, changes its rating and
+// text and sends the updated review version to the reviews topic.
+func (svc *ReviewService) ModifyReview() {
+	review, err := svc.popReviewFromBuffer()
+	if err != nil {
+		svc.logger.Debug("failed to pop review from buffer", zap.Error(err))
+		return
+	}
+
+	review.Edit(svc.clock.now())
+
+	err = svc.produceReview(withEventType(context.Background(), EventTypeReviewModified), review)
+	if err != nil {
+		svc.logger.Warn("failed to produce review", zap.Error(err))
+		return
+	}
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeReviewModified}).Inc()
+}
+
+// DeleteReview sends a tombstone for an existing review from the buffer.
+func (svc *ReviewService) DeleteReview() {
+	review, err := svc.popReviewFromBuffer()
+	if err != nil {
+		svc.logger.Debug("failed to pop review from buffer", zap.Error(err))
+		return
+	}
+
+	svc.produceTombstone(withEventType(context.Background(), EventTypeReviewDeleted), review)
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeReviewDeleted}).Inc()
+}
+
+func (svc *ReviewService) popReviewFromBuffer() (fake.Review, error) {
+	svc.recentReviewsMu.Lock()
+	defer svc.recentReviewsMu.Unlock()
+
+	if len(svc.recentReviews) == 0 {
+		// No reviews in buffer yet
+		return fake.Review{}, fmt.Errorf("buffer is empty")
+	}
+	review := svc.recentReviews[0]
+	svc.recentReviews = svc.recentReviews[1:]
+
+	return review, nil
+}
+
+func (svc *ReviewService) produceReview(ctx context.Context, review fake.Review) error {
+	serialized, err := svc.serde.Encode(review)
+	if err != nil {
+		return fmt.Errorf("failed to serialize review struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, review.ID, review.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "revision", Value: []byte(strconv.Itoa(review.Revision))}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
+
+func (svc *ReviewService) produceTombstone(ctx context.Context, review fake.Review) {
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, review.ID, review.CustomerID),
+		Value:     nil,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce tombstone",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+}
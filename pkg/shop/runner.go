@@ -0,0 +1,175 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// Runner runs the shops of all configured profiles concurrently in one process.
+// The shops share the HTTP listeners, which serve the metrics, the health
+// probes and the admin routes of all shops.
+type Runner struct {
+	logger *zap.Logger
+
+	shops       []*Shop
+	httpServers []*httpServer
+	health      *healthChecker
+}
+
+// NewRunner creates the shops of all profiles and initializes their components.
+func NewRunner(cfg config.Config, logger *zap.Logger) (*Runner, error) {
+	profiles := cfg.Shops()
+
+	// gofakeit and weightedrand both use the global random source, which is
+	// thus seeded once before any data is generated. A zero seed makes
+	// gofakeit seed it with the current time. Seeds are only allowed with a
+	// single profile.
+	seed := profiles[0].Shop.Seed
+	gofakeit.Seed(seed)
+	if seed != 0 {
+		logger.Info("seeded random data generation", zap.Int64("seed", seed))
+	}
+
+	kafkaFactories, err := kafka.NewFactories(cfg.Kafka, logger.Named("kafka_client"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka factories: %w", err)
+	}
+	health, err := newHealthChecker(cfg.Shop.GlobalPrefix+"health-check", logger.Named("health"), kafkaFactories)
+	if err != nil {
+		return nil, err
+	}
+
+	httpServers, adminMux, err := newHTTPServers(cfg, health)
+	if err != nil {
+		health.close()
+		return nil, fmt.Errorf("failed to create http listeners: %w", err)
+	}
+
+	r := &Runner{
+		logger:      logger,
+		httpServers: httpServers,
+		health:      health,
+	}
+	for _, profile := range profiles {
+		profileCfg := cfg
+		profileCfg.Shop = profile.Shop
+		profileLogger := logger
+		if profile.Name != "" {
+			profileLogger = logger.With(zap.String("profile", profile.Name))
+		}
+
+		shop, err := newShop(profileCfg, profile.Name, profileLogger, health, adminMux, options{})
+		if err != nil {
+			r.closeAfterFailure()
+			if profile.Name == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to create shop of profile '%v': %w", profile.Name, err)
+		}
+		r.shops = append(r.shops, shop)
+	}
+
+	// The listeners are started right away, so that the health probes reflect
+	// the initialization progress
+	for _, server := range httpServers {
+		go server.serve(logger)
+	}
+
+	for _, shop := range r.shops {
+		if err := shop.startInitialization(); err != nil {
+			r.closeAfterFailure()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// closeAfterFailure closes the shops that have been created, shuts down the
+// HTTP listeners and closes the health checker, as the runner must not be
+// started.
+func (r *Runner) closeAfterFailure() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, shop := range r.shops {
+		if err := shop.closeServices(ctx); err != nil {
+			r.logger.Warn("failed to close shop after failed initialization", zap.Error(err))
+		}
+	}
+	for _, server := range r.httpServers {
+		if err := server.shutdown(ctx); err != nil {
+			r.logger.Warn("failed to shutdown http listener after failed initialization", zap.Error(err))
+		}
+	}
+	r.health.close()
+}
+
+// Start starts the traffic simulation of all shops, see Shop.Start. It blocks
+// until the traffic simulation of all shops has returned or until the first of
+// them has failed.
+func (r *Runner) Start() error {
+	errCh := make(chan error, len(r.shops))
+	var wg sync.WaitGroup
+	for _, shop := range r.shops {
+		wg.Add(1)
+		go func(shop *Shop) {
+			defer wg.Done()
+			if err := shop.Start(); err != nil {
+				errCh <- err
+			}
+		}(shop)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-done:
+		return nil
+	}
+}
+
+// Stop gracefully stops all shops concurrently, see Shop.Stop. Afterwards the
+// HTTP listeners are shut down. The first error is returned. Stop must only be
+// called after Start.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.health.setStopping()
+
+	errCh := make(chan error, len(r.shops))
+	var wg sync.WaitGroup
+	for _, shop := range r.shops {
+		wg.Add(1)
+		go func(shop *Shop) {
+			defer wg.Done()
+			if err := shop.Stop(ctx); err != nil {
+				errCh <- err
+			}
+		}(shop)
+	}
+	wg.Wait()
+	close(errCh)
+
+	firstErr := <-errCh
+	for _, server := range r.httpServers {
+		if err := server.shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.health.close()
+
+	return firstErr
+}
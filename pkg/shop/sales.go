@@ -0,0 +1,108 @@
+package shop
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// saleCalendar tells which of the configured sales are active at the current
+// simulated time. A nil calendar has no sales.
+type saleCalendar struct {
+	sales []config.Sale
+	clock *simulationClock
+}
+
+// newSaleCalendar returns the calendar of the given sales. It returns nil if
+// no sales are configured.
+func newSaleCalendar(sales []config.Sale, clock *simulationClock) *saleCalendar {
+	if len(sales) == 0 {
+		return nil
+	}
+	return &saleCalendar{sales: sales, clock: clock}
+}
+
+// active returns the sales that are active right now.
+func (c *saleCalendar) active() []config.Sale {
+	if c == nil {
+		return nil
+	}
+
+	now := c.clock.now()
+	active := make([]config.Sale, 0, len(c.sales))
+	for _, sale := range c.sales {
+		if sale.ActiveAt(now) {
+			active = append(active, sale)
+		}
+	}
+
+	return active
+}
+
+// saleScheduler boosts the traffic while sales are active and returns it to
+// its baseline once they have ended.
+type saleScheduler struct {
+	logger   *zap.Logger
+	calendar *saleCalendar
+	traffic  *trafficController
+	clock    *simulationClock
+}
+
+// newSaleScheduler returns nil if no sales are configured.
+func newSaleScheduler(cfg config.Shop, logger *zap.Logger, traffic *trafficController, clock *simulationClock) *saleScheduler {
+	calendar := newSaleCalendar(cfg.Sales, clock)
+	if calendar == nil {
+		return nil
+	}
+	return &saleScheduler{
+		logger:   logger,
+		calendar: calendar,
+		traffic:  traffic,
+		clock:    clock,
+	}
+}
+
+// run regularly checks which sales are active and boosts the traffic of
+// their services until the given context is cancelled. The factors of
+// overlapping sales are multiplied.
+func (s *saleScheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.clock.realDuration(time.Second))
+	defer ticker.Stop()
+
+	var current string
+	for {
+		active := s.calendar.active()
+		names := make([]string, len(active))
+		boosts := make(map[string]float64)
+		for i, sale := range active {
+			names[i] = sale.Name
+			for _, service := range sale.BoostedServices() {
+				if _, ok := boosts[service]; !ok {
+					boosts[service] = 1
+				}
+				boosts[service] *= sale.Factor
+			}
+		}
+		sort.Strings(names)
+
+		if key := strings.Join(names, ","); key != current {
+			if err := s.traffic.setBoosts(boosts); err != nil {
+				s.logger.Warn("failed to boost traffic of sales", zap.Strings("sales", names), zap.Error(err))
+			} else {
+				s.logger.Info("changed active sales", zap.Strings("sales", names), zap.Any("boosts", boosts))
+				current = key
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// scenarioRunner runs the steps of a scenario at their offsets from the start
+// of the traffic simulation, which are measured in simulated time. Steps change the traffic simulation the same way
+// as the admin API does, so that a demo narrative such as "at 30s create 100
+// customers, at 60s spike orders 10x for a minute, at 120s emit 5 poison
+// messages" can be reproduced by a config file.
+type scenarioRunner struct {
+	logger  *zap.Logger
+	clock   *simulationClock
+	traffic *trafficController
+	repeat  bool
+	steps   []config.ScenarioStep
+
+	// poison injects a poison message. It is nil if dead letters are disabled.
+	poison func()
+}
+
+// scenarioAction is a step or the end of a spike that is due at the given
+// offset.
+type scenarioAction struct {
+	at   time.Duration
+	name string
+	run  func() (restore func(), err error)
+	// duration after which the func returned by run is called, if any.
+	duration time.Duration
+}
+
+// newScenarioRunner loads the steps of the scenario and checks that all
+// referenced events exist. It returns nil if no scenario is configured.
+func newScenarioRunner(
+	cfg config.Scenario,
+	logger *zap.Logger,
+	traffic *trafficController,
+	deadLetterSvc *DeadLetterService,
+	clock *simulationClock,
+) (*scenarioRunner, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	steps, err := cfg.LoadSteps()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].At < steps[j].At })
+
+	r := &scenarioRunner{
+		logger:  logger,
+		clock:   clock,
+		traffic: traffic,
+		repeat:  cfg.Repeat,
+		steps:   steps,
+	}
+	if deadLetterSvc != nil {
+		r.poison = deadLetterSvc.InjectPoisonMessage
+	}
+
+	for i, step := range steps {
+		if step.Event != "" {
+			if _, err := traffic.eventFunc(step.Event); err != nil {
+				return nil, fmt.Errorf("failed to validate scenario step at %v: %w", step.At, err)
+			}
+		}
+		if step.Action == config.ScenarioActionPoison && r.poison == nil {
+			return nil, fmt.Errorf("scenario step %d at %v injects poison messages, which requires dead letters to be enabled", i, step.At)
+		}
+	}
+
+	return r, nil
+}
+
+// run runs all steps until the given context is cancelled. If the scenario
+// repeats, it is restarted once its last step and spike have ended.
+func (r *scenarioRunner) run(ctx context.Context) {
+	for {
+		r.logger.Info("starting scenario", zap.Int("steps", len(r.steps)))
+		if !r.runOnce(ctx) {
+			return
+		}
+		r.logger.Info("finished scenario")
+		if !r.repeat {
+			return
+		}
+	}
+}
+
+// runOnce runs all steps relative to now. It returns false if the context
+// has been cancelled.
+func (r *scenarioRunner) runOnce(ctx context.Context) bool {
+	queue := make([]scenarioAction, len(r.steps))
+	for i, step := range r.steps {
+		queue[i] = r.action(step)
+	}
+
+	startedAt := r.clock.now()
+	for len(queue) > 0 {
+		action := queue[0]
+		queue = queue[1:]
+
+		if wait := startedAt.Add(action.at).Sub(r.clock.now()); wait > 0 {
+			timer := time.NewTimer(r.clock.realDuration(wait))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		restore, err := action.run()
+		if err != nil {
+			r.logger.Warn("failed to run scenario step",
+				zap.String("step", action.name),
+				zap.Duration("at", action.at),
+				zap.Error(err))
+			continue
+		}
+		r.logger.Info("ran scenario step", zap.String("step", action.name), zap.Duration("at", action.at))
+
+		if restore == nil {
+			continue
+		}
+		end := scenarioAction{
+			at:   action.at + action.duration,
+			name: "end of " + action.name,
+			run: func() (func(), error) {
+				restore()
+				return nil, nil
+			},
+		}
+		i := sort.Search(len(queue), func(i int) bool { return queue[i].at > end.at })
+		queue = append(queue[:i], append([]scenarioAction{end}, queue[i:]...)...)
+	}
+
+	return true
+}
+
+// action returns the action that runs the given step.
+func (r *scenarioRunner) action(step config.ScenarioStep) scenarioAction {
+	count := step.Count
+	if count == 0 {
+		count = 1
+	}
+
+	action := scenarioAction{at: step.At, name: step.Action}
+	switch step.Action {
+	case config.ScenarioActionTrigger:
+		action.name = fmt.Sprintf("trigger %d x %v", count, step.Event)
+		action.run = func() (func(), error) {
+			fn, err := r.traffic.eventFunc(step.Event)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < count; i++ {
+				fn()
+			}
+			return nil, nil
+		}
+	case config.ScenarioActionSpike:
+		action.duration = step.Duration
+		if step.Event == "" {
+			action.name = fmt.Sprintf("spike events per second %vx", step.Multiplier)
+			action.run = func() (func(), error) {
+				return r.spikeRate(step)
+			}
+		} else {
+			action.name = fmt.Sprintf("spike %v %vx", step.Event, step.Multiplier)
+			action.run = func() (func(), error) {
+				return r.spikeWeight(step)
+			}
+		}
+	case config.ScenarioActionRate:
+		action.name = fmt.Sprintf("rate %v events per second", step.EventsPerSecond)
+		action.run = func() (func(), error) {
+			return nil, r.traffic.setRate(step.EventsPerSecond, step.Burst)
+		}
+	case config.ScenarioActionPause:
+		action.run = func() (func(), error) {
+			r.traffic.setPaused(true)
+			return nil, nil
+		}
+	case config.ScenarioActionResume:
+		action.run = func() (func(), error) {
+			r.traffic.setPaused(false)
+			return nil, nil
+		}
+	case config.ScenarioActionPoison:
+		action.name = fmt.Sprintf("poison %d messages", count)
+		action.run = func() (func(), error) {
+			for i := 0; i < count; i++ {
+				r.poison()
+			}
+			return nil, nil
+		}
+	}
+
+	return action
+}
+
+// spikeRate multiplies the events per second. The returned func restores the
+// previous rate if the spike has a duration.
+func (r *scenarioRunner) spikeRate(step config.ScenarioStep) (func(), error) {
+	settings := r.traffic.settings()
+	if err := r.traffic.setRate(settings.EventsPerSecond*step.Multiplier, settings.Burst); err != nil {
+		return nil, err
+	}
+	if step.Duration == 0 {
+		return nil, nil
+	}
+
+	return func() {
+		if err := r.traffic.setRate(settings.EventsPerSecond, settings.Burst); err != nil {
+			r.logger.Warn("failed to restore events per second after spike", zap.Error(err))
+		}
+	}, nil
+}
+
+// spikeWeight multiplies the weight of the step's event, so that its share of
+// the page impressions increases without changing the total rate. The
+// returned func restores the previous weight if the spike has a duration.
+func (r *scenarioRunner) spikeWeight(step config.ScenarioStep) (func(), error) {
+	weight := r.traffic.settings().EventWeights[step.Event]
+	spiked := uint(math.Round(float64(weight) * step.Multiplier))
+	if err := r.traffic.setWeights(map[string]uint{step.Event: spiked}); err != nil {
+		return nil, err
+	}
+	if step.Duration == 0 {
+		return nil, nil
+	}
+
+	return func() {
+		if err := r.traffic.setWeights(map[string]uint{step.Event: weight}); err != nil {
+			r.logger.Warn("failed to restore event weight after spike", zap.String("event", step.Event), zap.Error(err))
+		}
+	}, nil
+}
@@ -0,0 +1,93 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// evolvedOrderFields are added one after another to the avro order schema when
+// the schema evolution is enabled. All fields are optional with a null default,
+// so that each new schema version is backward and forward compatible.
+var evolvedOrderFields = []string{
+	"couponCode",
+	"giftMessage",
+	"salesChannel",
+	"affiliateId",
+	"loyaltyProgramId",
+	"preferredDeliveryWindow",
+	"invoiceReference",
+	"customerNote",
+}
+
+// EvolveOrderSchema regularly registers a new version of the avro order schema,
+// each with one more optional field than the previous version. New orders are
+// serialized with the latest schema version. It returns once all evolved fields
+// have been added or the given context is cancelled.
+func (s *Serdes) EvolveOrderSchema(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.SchemaEvolution.Interval)
+	defer ticker.Stop()
+
+	for fieldCount := 1; fieldCount <= len(evolvedOrderFields); fieldCount++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		registerCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := s.registerEvolvedOrderSchema(registerCtx, fieldCount)
+		cancel()
+		if err != nil {
+			s.logger.Warn("failed to register evolved order schema", zap.Error(err))
+			continue
+		}
+		s.logger.Info("registered evolved order schema",
+			zap.String("added_field", evolvedOrderFields[fieldCount-1]),
+			zap.Int("evolved_field_count", fieldCount))
+	}
+
+	s.logger.Info("order schema evolution completed, no further schema versions will be registered")
+}
+
+func (s *Serdes) registerEvolvedOrderSchema(ctx context.Context, fieldCount int) error {
+	orderSchema, err := s.avroOrderSchema()
+	if err != nil {
+		return err
+	}
+	schema, err := newEvolvedOrderSchema(orderSchema, fieldCount)
+	if err != nil {
+		return err
+	}
+
+	return s.register(ctx, s.Orders,
+		fake.Order{},
+		schema,
+		"",
+		s.orderReferences,
+		orderCodec(),
+	)
+}
+
+// newEvolvedOrderSchema returns the given avro order schema with the first
+// fieldCount evolved fields appended.
+func newEvolvedOrderSchema(orderSchema string, fieldCount int) (string, error) {
+	fields := make([]any, 0, fieldCount)
+	for _, name := range evolvedOrderFields[:fieldCount] {
+		fields = append(fields, map[string]any{
+			"name":    name,
+			"type":    []any{"null", "string"},
+			"default": nil,
+		})
+	}
+
+	schema, err := appendAvroFields(orderSchema, fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to evolve order avro schema: %w", err)
+	}
+	return schema, nil
+}
@@ -0,0 +1,218 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hamba/avro"
+	"github.com/twmb/franz-go/pkg/sr"
+
+	embedavro "github.com/cloudhut/owl-shop/pkg/shop/schemas/avro"
+	embedproto "github.com/cloudhut/owl-shop/proto"
+)
+
+// Subjects of the schemas that are referenced by the order schema. They are
+// not prefixed, because the referencing schemas must import them by these
+// names.
+const (
+	customerProtoSubject      = "shop/v1/customer.proto"
+	addressProtoSubject       = "shop/v1/address.proto"
+	orderLineItemProtoSubject = "shop/v1/order_line_item.proto"
+	customerAvroSubject       = "com.shop.v1.avro.Customer"
+	addressAvroSubject        = "com.shop.v1.avro.Address"
+	orderLineItemAvroSubject  = "com.shop.v1.avro.OrderLineItem"
+)
+
+// referenceSubjects are the subjects of all referenced schemas.
+var referenceSubjects = []string{
+	customerProtoSubject, addressProtoSubject, orderLineItemProtoSubject,
+	customerAvroSubject, addressAvroSubject, orderLineItemAvroSubject,
+}
+
+// registerProtobufReferenceSchemas registers the customer, address and line
+// item protobuf schemas which are imported by the order schema. If successful,
+// it returns the schema references that must be used when registering the
+// order schema.
+//
+// The line items used to be the nested Order.LineItem message. The schema
+// registry considers the changed type of the line items field incompatible,
+// so registering the order schema fails on order subjects that still hold a
+// version with the nested message. Such subjects must be deleted once, e.g.
+// with the cleanup command, or the order topics moved to a new subject prefix.
+func registerProtobufReferenceSchemas(ctx context.Context, srClient *sr.Client) ([]sr.SchemaReference, error) {
+	// This registers an older proto version first, so that we simulate
+	// a schema evolution as well.
+	_, err := srClient.CreateSchema(
+		ctx,
+		customerProtoSubject,
+		sr.Schema{
+			Schema: embedproto.CustomerV1,
+			Type:   sr.TypeProtobuf,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register customer schema: %w", err)
+	}
+
+	customer, err := srClient.CreateSchema(
+		ctx,
+		customerProtoSubject,
+		sr.Schema{
+			Schema: embedproto.CustomerV2,
+			Type:   sr.TypeProtobuf,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register customer schema: %w", err)
+	}
+
+	address, err := srClient.CreateSchema(
+		ctx,
+		addressProtoSubject,
+		sr.Schema{
+			Schema: embedproto.Address,
+			Type:   sr.TypeProtobuf,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register address schema: %w", err)
+	}
+
+	lineItem, err := srClient.CreateSchema(
+		ctx,
+		orderLineItemProtoSubject,
+		sr.Schema{
+			Schema: embedproto.OrderLineItem,
+			Type:   sr.TypeProtobuf,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register order line item schema: %w", err)
+	}
+
+	return []sr.SchemaReference{
+		{
+			Name:    customerProtoSubject,
+			Subject: customer.Subject,
+			Version: customer.Version,
+		},
+		{
+			Name:    addressProtoSubject,
+			Subject: address.Subject,
+			Version: address.Version,
+		},
+		{
+			Name:    orderLineItemProtoSubject,
+			Subject: lineItem.Subject,
+			Version: lineItem.Version,
+		},
+	}, nil
+}
+
+// registerAvroReferenceSchemas registers the customer, address and line item
+// avro schemas which are referenced by the order schema. If successful, it
+// returns the schema references that must be used when registering the order
+// schema. The referenced line item has the same full name as the formerly
+// inlined record, so the order schema stays compatible.
+func registerAvroReferenceSchemas(ctx context.Context, srClient *sr.Client) ([]sr.SchemaReference, error) {
+	// This registers the older schema versions first, so that we simulate
+	// a schema evolution as well.
+	customerV1, err := srClient.CreateSchema(
+		ctx,
+		customerAvroSubject,
+		sr.Schema{
+			Schema: embedavro.CustomerV1Avro,
+			Type:   sr.TypeAvro,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register customer v1 schema: %w", err)
+	}
+
+	customerV2, err := srClient.CreateSchema(
+		ctx,
+		customerV1.Subject,
+		sr.Schema{
+			Schema: embedavro.CustomerV2Avro,
+			Type:   sr.TypeAvro,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register customer v2 schema: %w", err)
+	}
+
+	customerV3, err := srClient.CreateSchema(
+		ctx,
+		customerV2.Subject,
+		sr.Schema{
+			Schema: embedavro.CustomerV3Avro,
+			Type:   sr.TypeAvro,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register customer v3 schema: %w", err)
+	}
+
+	address, err := srClient.CreateSchema(
+		ctx,
+		addressAvroSubject,
+		sr.Schema{
+			Schema: embedavro.AddressAvro,
+			Type:   sr.TypeAvro,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register address schema: %w", err)
+	}
+
+	lineItem, err := srClient.CreateSchema(
+		ctx,
+		orderLineItemAvroSubject,
+		sr.Schema{
+			Schema: embedavro.OrderLineItemAvro,
+			Type:   sr.TypeAvro,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register order line item schema: %w", err)
+	}
+
+	return []sr.SchemaReference{
+		{
+			Name:    customerV3.Subject,
+			Subject: customerV3.Subject,
+			Version: customerV3.Version,
+		},
+		{
+			Name:    address.Subject,
+			Subject: address.Subject,
+			Version: address.Version,
+		},
+		{
+			Name:    lineItem.Subject,
+			Subject: lineItem.Subject,
+			Version: lineItem.Version,
+		},
+	}, nil
+}
+
+// parseAvroReferenceSchemas parses the customer, address and line item avro
+// schemas, which adds them to the avro lib's global cache. This is required
+// before parsing any schema that references these types, such as the order
+// schema.
+func parseAvroReferenceSchemas() error {
+	avro.DefaultConfig = avro.Config{
+		TagKey: "json",
+	}.Freeze()
+	if _, err := avro.Parse(embedavro.CustomerV3Avro); err != nil {
+		return fmt.Errorf("failed to parse customerV3 avro schema with avro lib: %w", err)
+	}
+	if _, err := avro.Parse(embedavro.AddressAvro); err != nil {
+		return fmt.Errorf("failed to parse address avro schema with avro lib: %w", err)
+	}
+	if _, err := avro.Parse(embedavro.OrderLineItemAvro); err != nil {
+		return fmt.Errorf("failed to parse order line item avro schema with avro lib: %w", err)
+	}
+
+	return nil
+}
@@ -7,8 +7,28 @@ var (
 	CustomerV1Avro string
 	//go:embed customer_v2.avsc
 	CustomerV2Avro string
+	//go:embed customer_v3.avsc
+	CustomerV3Avro string
 	//go:embed address.avsc
 	AddressAvro string
 	//go:embed order.avsc
 	OrderAvro string
+	//go:embed order_line_item.avsc
+	OrderLineItemAvro string
+	//go:embed frontend_event.avsc
+	FrontendEventAvro string
+	//go:embed product.avsc
+	ProductAvro string
+	//go:embed inventory_event.avsc
+	InventoryEventAvro string
+	//go:embed payment_event.avsc
+	PaymentEventAvro string
+	//go:embed shipment_event.avsc
+	ShipmentEventAvro string
+	//go:embed review.avsc
+	ReviewAvro string
+	//go:embed cart_event.avsc
+	CartEventAvro string
+	//go:embed sampled_record.avsc
+	SampledRecordAvro string
 )
@@ -0,0 +1,119 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+)
+
+// SearchService is part of the FrontendService and produces a search query
+// for each view of the search page. Search queries are keyed by the session
+// id and share the correlation id with the page view, so that they can be
+// joined with the frontend events by search analytics pipelines. It uses the
+// Kafka clients of the frontend service.
+type SearchService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	metaClient     *kgo.Client
+	producer       recordProducer
+	productCatalog *ProductCatalogService
+
+	topicName string
+}
+
+// NewSearchService creates a new SearchService, which produces with the given
+// clients of the frontend service. Search results are taken from the given
+// product catalog.
+func NewSearchService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	metaClient *kgo.Client,
+	producer recordProducer,
+	productCatalog *ProductCatalogService,
+	clock *simulationClock,
+) *SearchService {
+	return &SearchService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "search_service")),
+		clock:  clock,
+
+		metaClient:     metaClient,
+		producer:       producer,
+		productCatalog: productCatalog,
+
+		topicName: cfg.TopicName("search-queries"),
+	}
+}
+
+// Initialize search service by reconciling the search queries topic.
+func (svc *SearchService) Initialize(ctx context.Context) error {
+	err := reconcileTopic(
+		ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile search queries topic: %w", err)
+	}
+
+	return nil
+}
+
+// Search produces the search query of the given frontend event, if it is a
+// view of the search page. If a result has been clicked, the session views
+// the clicked product next.
+func (svc *SearchService) Search(ctx context.Context, session *fake.FrontendSession, event fake.FrontendEvent) {
+	cfg := svc.cfg.Search
+	products := svc.productCatalog.RandomProducts(fake.SearchResultsPerPage)
+	query, ok := fake.NewSearchQuery(event, products, cfg.ZeroResultRatio, cfg.ClickRatio, cfg.MaxResults, svc.clock.now())
+	if !ok {
+		return
+	}
+	if query.ClickedProductID != nil {
+		session.ClickedProductID = *query.ClickedProductID
+	}
+
+	if err := svc.produceSearchQuery(ctx, query); err != nil {
+		svc.logger.Warn("failed to produce search query", zap.Error(err))
+	}
+}
+
+func (svc *SearchService) produceSearchQuery(ctx context.Context, query fake.SearchQuery) error {
+	serialized, err := json.Marshal(query)
+	if err != nil {
+		return fmt.Errorf("failed to serialize search query struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, query.SessionID, ""),
+		Value:     serialized,
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	svc.producer.Produce(withEventType(ctx, EventTypeSearchQueryCreated), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeSearchQueryCreated}).Inc()
+
+	return nil
+}
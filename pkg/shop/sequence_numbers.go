@@ -0,0 +1,176 @@
+package shop
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+const (
+	headerProducerID = "owlshop_producer_id"
+	headerSequence   = "owlshop_sequence"
+)
+
+// sequenceNumbers adds a sequence number to all records with a key that are
+// produced by a client of the shop. The sequence numbers increase
+// monotonically per topic and key, starting at 1, and are scoped to the
+// producer id of the client, because the order of the records of different
+// clients is undefined. Records that carry a sequence number already, such as
+// injected duplicates, keep it. Each Kafka client of the shop registers its
+// own hook, see newSequenceNumbers.
+//
+// Once a key is evicted, its sequence restarts at 1 under the next generation
+// of the producer id, so that the verifier does not mistake the restart for a
+// reordered record.
+type sequenceNumbers struct {
+	enabled    bool
+	producerID string
+
+	mu         sync.Mutex
+	sequences  *keyLRU[producedSequence]
+	generation uint64
+}
+
+// producedSequence is the last sequence number of a key and the generation
+// of the producer id that it has been produced with.
+type producedSequence struct {
+	generation uint64
+	sequence   uint64
+}
+
+var _ kgo.HookProduceRecordBuffered = (*sequenceNumbers)(nil)
+
+// newSequenceNumbers returns a function that creates the hook of a client.
+// The producer ids of the clients consist of an id that is random for each
+// process and the number of the client.
+func newSequenceNumbers(cfg config.Integrity) (func() kgo.Hook, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate producer id: %w", err)
+	}
+	processID := hex.EncodeToString(id)
+
+	var clients atomic.Uint64
+	return func() kgo.Hook {
+		return &sequenceNumbers{
+			enabled:    cfg.Enabled,
+			producerID: processID + "-" + strconv.FormatUint(clients.Add(1), 10),
+			sequences:  newKeyLRU[producedSequence](cfg.MaxKeys),
+		}
+	}, nil
+}
+
+// OnProduceRecordBuffered adds the producer id and the next sequence number of
+// the record's topic and key. It is called synchronously within Produce, so
+// the sequence numbers follow the order in which the records are produced.
+func (s *sequenceNumbers) OnProduceRecordBuffered(r *kgo.Record) {
+	if !s.enabled || r.Key == nil {
+		return
+	}
+	for _, header := range r.Headers {
+		if header.Key == headerSequence {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	produced, ok := s.sequences.get(r.Topic, string(r.Key))
+	if !ok {
+		produced.generation = s.generation
+	}
+	produced.sequence++
+	if evicted := s.sequences.put(r.Topic, string(r.Key), produced); evicted {
+		s.generation++
+	}
+	s.mu.Unlock()
+
+	setHeaderIfAbsent(r, headerProducerID, s.producerID+"-"+strconv.FormatUint(produced.generation, 10))
+	setHeaderIfAbsent(r, headerSequence, strconv.FormatUint(produced.sequence, 10))
+}
+
+// recordSequence returns the producer id and sequence number of the record,
+// if it has any.
+func recordSequence(r *kgo.Record) (string, uint64, bool) {
+	var producerID string
+	var sequence uint64
+	var hasSequence bool
+	for _, header := range r.Headers {
+		switch header.Key {
+		case headerProducerID:
+			producerID = string(header.Value)
+		case headerSequence:
+			parsed, err := strconv.ParseUint(string(header.Value), 10, 64)
+			if err != nil {
+				return "", 0, false
+			}
+			sequence, hasSequence = parsed, true
+		}
+	}
+
+	return producerID, sequence, hasSequence && producerID != ""
+}
+
+// keyLRU holds a value, such as the last sequence number, of the most
+// recently used keys of each topic. The least recently used key is evicted
+// once the max number of keys is exceeded. It is not safe for concurrent use.
+type keyLRU[V any] struct {
+	maxKeys  int
+	order    *list.List
+	elements map[sequenceKey]*list.Element
+}
+
+type sequenceKey struct {
+	topic string
+	key   string
+}
+
+type sequenceEntry[V any] struct {
+	key   sequenceKey
+	value V
+}
+
+func newKeyLRU[V any](maxKeys int) *keyLRU[V] {
+	return &keyLRU[V]{
+		maxKeys:  maxKeys,
+		order:    list.New(),
+		elements: make(map[sequenceKey]*list.Element),
+	}
+}
+
+// get returns the value of the key and whether it is known.
+func (l *keyLRU[V]) get(topic, key string) (V, bool) {
+	element, ok := l.elements[sequenceKey{topic: topic, key: key}]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return element.Value.(*sequenceEntry[V]).value, true
+}
+
+// put sets the value of the key and marks it as most recently used. It
+// returns whether the least recently used key has been evicted.
+func (l *keyLRU[V]) put(topic, key string, value V) bool {
+	k := sequenceKey{topic: topic, key: key}
+	if element, ok := l.elements[k]; ok {
+		element.Value.(*sequenceEntry[V]).value = value
+		l.order.MoveToFront(element)
+		return false
+	}
+
+	l.elements[k] = l.order.PushFront(&sequenceEntry[V]{key: k, value: value})
+	if l.order.Len() <= l.maxKeys {
+		return false
+	}
+	oldest := l.order.Back()
+	l.order.Remove(oldest)
+	delete(l.elements, oldest.Value.(*sequenceEntry[V]).key)
+	return true
+}
@@ -0,0 +1,550 @@
+package shop
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/hamba/avro"
+	"github.com/twmb/franz-go/pkg/sr"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	shoppb "github.com/cloudhut/owl-shop/pkg/protogen/shop/v1"
+	embedavro "github.com/cloudhut/owl-shop/pkg/shop/schemas/avro"
+	embedproto "github.com/cloudhut/owl-shop/proto"
+)
+
+// TopicSerde serializes and deserializes the records of a single topic in the
+// configured format. JSON Schema, Avro and Protobuf records use the schema
+// registry wire format, so that other tools can look up the schema that is
+// required to deserialize or validate the record.
+type TopicSerde struct {
+	// topic is the name of the topic without prefix, e.g. orders.
+	topic    string
+	format   string
+	serde    sr.Serde
+	failures config.SchemaRegistryFailures
+}
+
+func newTopicSerde(topic string, format string, failures config.SchemaRegistryFailures) *TopicSerde {
+	return &TopicSerde{topic: topic, format: format, failures: failures}
+}
+
+// Encode serializes the given value in the topic's format. If schema registry
+// failures are enabled, the wire format of some records references the
+// unknown schema ID.
+func (s *TopicSerde) Encode(v any) ([]byte, error) {
+	if s.format == config.SerdeJSON {
+		return json.Marshal(v)
+	}
+	b, err := s.serde.Encode(v)
+	if err != nil || !s.failures.Enabled || gofakeit.Float64() >= s.failures.UnknownSchemaIDRatio {
+		return b, err
+	}
+
+	// The schema ID follows the magic byte in all wire formats
+	binary.BigEndian.PutUint32(b[1:5], uint32(s.failures.UnknownSchemaID))
+	return b, nil
+}
+
+// registerSchema registers the encode and decode functions of the given type
+// for the schema ID. If schema registry failures are enabled, they are
+// registered for the unknown schema ID as well, so that records referencing
+// it can still be decoded. The schema ID is used for encoding.
+func (s *TopicSerde) registerSchema(id int, v any, opts ...sr.SerdeOpt) {
+	if s.failures.Enabled {
+		s.serde.Register(s.failures.UnknownSchemaID, v, opts...)
+	}
+	s.serde.Register(id, v, opts...)
+}
+
+// Decode deserializes the given record value into v, which must be a pointer.
+func (s *TopicSerde) Decode(b []byte, v any) error {
+	if s.format == config.SerdeJSON {
+		return json.Unmarshal(b, v)
+	}
+	return s.serde.Decode(b, v)
+}
+
+// Serdes holds the serdes for the topics whose serialization format
+// can be configured. Producing and consuming services share the same
+// serdes, so that consumers can always decode the records of the topics
+// they consume.
+type Serdes struct {
+	cfg      config.Shop
+	logger   *zap.Logger
+	srClient *sr.Client
+
+	// orderReferences are the schema references of the order schema.
+	orderReferences []sr.SchemaReference
+
+	Customers      *TopicSerde
+	Addresses      *TopicSerde
+	FrontendEvents *TopicSerde
+	Orders         *TopicSerde
+	Products       *TopicSerde
+	Inventory      *TopicSerde
+	Payments       *TopicSerde
+	Shipments      *TopicSerde
+	Reviews        *TopicSerde
+	Carts          *TopicSerde
+}
+
+// NewSerdes creates the serdes for all configurable topic formats. The schema
+// registry client may be nil, as long as all topics use the JSON format.
+func NewSerdes(cfg config.Shop, logger *zap.Logger, srClient *sr.Client) (*Serdes, error) {
+	for name, svcCfg := range cfg.Services.ByName() {
+		if svcCfg.Serde != config.SerdeJSON && srClient == nil {
+			return nil, fmt.Errorf("serde '%v' of the %v service requires a schema registry to be configured", svcCfg.Serde, name)
+		}
+	}
+
+	serdes := &Serdes{
+		cfg:      cfg,
+		logger:   logger,
+		srClient: srClient,
+
+		Customers:      newTopicSerde("customers", cfg.Services.Customer.Serde, cfg.SchemaRegistryFailures),
+		Addresses:      newTopicSerde("addresses", cfg.Services.Address.Serde, cfg.SchemaRegistryFailures),
+		FrontendEvents: newTopicSerde("frontend-events", cfg.Services.Frontend.Serde, cfg.SchemaRegistryFailures),
+		Orders:         newTopicSerde("orders", cfg.Services.Order.Serde, cfg.SchemaRegistryFailures),
+		Products:       newTopicSerde("products", cfg.Services.ProductCatalog.Serde, cfg.SchemaRegistryFailures),
+		Inventory:      newTopicSerde("inventory", cfg.Services.Inventory.Serde, cfg.SchemaRegistryFailures),
+		Payments:       newTopicSerde("payments", cfg.Services.Payment.Serde, cfg.SchemaRegistryFailures),
+		Shipments:      newTopicSerde("shipments", cfg.Services.Shipment.Serde, cfg.SchemaRegistryFailures),
+		Reviews:        newTopicSerde("reviews", cfg.Services.Review.Serde, cfg.SchemaRegistryFailures),
+		Carts:          newTopicSerde("carts", cfg.Services.Cart.Serde, cfg.SchemaRegistryFailures),
+	}
+
+	for _, topic := range cfg.SchemaRegistryFailures.SkipRegistration {
+		if cfg.SchemaRegistryFailures.Enabled && serdes.byTopic(topic) == nil {
+			return nil, fmt.Errorf("skip registration topic '%v' has no serde", topic)
+		}
+	}
+
+	return serdes, nil
+}
+
+// byTopic returns the serde of the given topic, without prefix, or nil if the
+// topic has none.
+func (s *Serdes) byTopic(topic string) *TopicSerde {
+	for _, ts := range []*TopicSerde{
+		s.Customers, s.Addresses, s.FrontendEvents, s.Orders, s.Products,
+		s.Inventory, s.Payments, s.Shipments, s.Reviews, s.Carts,
+	} {
+		if ts.topic == topic {
+			return ts
+		}
+	}
+	return nil
+}
+
+// entityCodec converts between the fake structs and their protobuf messages.
+type entityCodec struct {
+	newMessage  func() proto.Message
+	toMessage   func(v any) proto.Message
+	fromMessage func(m proto.Message, v any)
+
+	// encodeAvro and decodeAvro optionally override how avro records are
+	// encoded and decoded. This is required for types that can't be encoded
+	// or decoded directly by the avro lib.
+	encodeAvro func(schema avro.Schema, v any) ([]byte, error)
+	decodeAvro func(schema avro.Schema, b []byte, v any) error
+}
+
+// avroOrder is used to decode avro orders. The avro lib can encode, but not
+// decode, the non-pointer time fields that are nullable in the avro schema.
+type avroOrder struct {
+	CreatedAt     *time.Time `json:"createdAt"`
+	LastUpdatedAt *time.Time `json:"lastUpdatedAt"`
+	fake.Order
+}
+
+// orderCodec returns the codec for orders, which is also used when registering
+// evolved versions of the order schema.
+func orderCodec() entityCodec {
+	return entityCodec{
+		newMessage: func() proto.Message { return &shoppb.Order{} },
+		toMessage: func(v any) proto.Message {
+			order := v.(fake.Order)
+			return order.Protobuf()
+		},
+		fromMessage: func(m proto.Message, v any) {
+			*v.(*fake.Order) = fake.NewOrderFromProtobuf(m.(*shoppb.Order))
+		},
+		encodeAvro: func(schema avro.Schema, v any) ([]byte, error) {
+			return avro.Marshal(schema, newAvroLogicalOrder(v.(fake.Order)))
+		},
+		decodeAvro: func(schema avro.Schema, b []byte, v any) error {
+			var order avroOrder
+			if err := avro.Unmarshal(schema, b, &order); err != nil {
+				return err
+			}
+			if order.CreatedAt != nil {
+				order.Order.CreatedAt = *order.CreatedAt
+			}
+			if order.LastUpdatedAt != nil {
+				order.Order.LastUpdatedAt = *order.LastUpdatedAt
+			}
+			*v.(*fake.Order) = order.Order
+			return nil
+		},
+	}
+}
+
+// Initialize registers the schemas of all JSON Schema, Avro and Protobuf topics
+// in the schema registry.
+func (s *Serdes) Initialize(ctx context.Context) error {
+	if s.srClient == nil {
+		return nil
+	}
+
+	err := parseAvroReferenceSchemas()
+	if err != nil {
+		return err
+	}
+
+	err = s.register(ctx, s.Customers,
+		fake.Customer{},
+		embedavro.CustomerV3Avro,
+		embedproto.CustomerV2,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.Customer{} },
+			toMessage: func(v any) proto.Message {
+				customer := v.(fake.Customer)
+				return customer.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.Customer) = fake.NewCustomerFromProtobuf(m.(*shoppb.Customer))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register customer schema: %w", err)
+	}
+
+	err = s.register(ctx, s.Addresses,
+		fake.Address{},
+		embedavro.AddressAvro,
+		embedproto.Address,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.Address{} },
+			toMessage: func(v any) proto.Message {
+				address := v.(fake.Address)
+				return address.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.Address) = fake.NewAddressFromProtobuf(m.(*shoppb.Address))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register address schema: %w", err)
+	}
+
+	err = s.register(ctx, s.FrontendEvents,
+		fake.FrontendEvent{},
+		embedavro.FrontendEventAvro,
+		embedproto.FrontendEvent,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.FrontendEvent{} },
+			toMessage: func(v any) proto.Message {
+				event := v.(fake.FrontendEvent)
+				return event.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.FrontendEvent) = fake.NewFrontendEventFromProtobuf(m.(*shoppb.FrontendEvent))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register frontend event schema: %w", err)
+	}
+
+	// The order schemas reference the customer, address and line item schemas
+	switch s.Orders.format {
+	case config.SerdeAvro:
+		s.orderReferences, err = registerAvroReferenceSchemas(ctx, s.srClient)
+	case config.SerdeProtobuf:
+		s.orderReferences, err = registerProtobufReferenceSchemas(ctx, s.srClient)
+	}
+	if err != nil {
+		return err
+	}
+	orderSchema, err := s.avroOrderSchema()
+	if err != nil {
+		return err
+	}
+	err = s.register(ctx, s.Orders,
+		fake.Order{},
+		orderSchema,
+		embedproto.Order,
+		s.orderReferences,
+		orderCodec(),
+	)
+	if err != nil {
+		var responseErr *sr.ResponseError
+		if s.Orders.format == config.SerdeProtobuf && errors.As(err, &responseErr) && responseErr.ErrorCode == http.StatusConflict {
+			return fmt.Errorf("failed to register order schema, order subjects with the formerly nested line item message must be deleted once: %w", err)
+		}
+		return fmt.Errorf("failed to register order schema: %w", err)
+	}
+
+	err = s.register(ctx, s.Products,
+		fake.Product{},
+		embedavro.ProductAvro,
+		embedproto.Product,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.Product{} },
+			toMessage: func(v any) proto.Message {
+				product := v.(fake.Product)
+				return product.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.Product) = fake.NewProductFromProtobuf(m.(*shoppb.Product))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register product schema: %w", err)
+	}
+
+	err = s.register(ctx, s.Inventory,
+		fake.InventoryEvent{},
+		embedavro.InventoryEventAvro,
+		embedproto.InventoryEvent,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.InventoryEvent{} },
+			toMessage: func(v any) proto.Message {
+				event := v.(fake.InventoryEvent)
+				return event.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.InventoryEvent) = fake.NewInventoryEventFromProtobuf(m.(*shoppb.InventoryEvent))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register inventory event schema: %w", err)
+	}
+
+	err = s.register(ctx, s.Payments,
+		fake.PaymentEvent{},
+		embedavro.PaymentEventAvro,
+		embedproto.PaymentEvent,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.PaymentEvent{} },
+			toMessage: func(v any) proto.Message {
+				event := v.(fake.PaymentEvent)
+				return event.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.PaymentEvent) = fake.NewPaymentEventFromProtobuf(m.(*shoppb.PaymentEvent))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register payment event schema: %w", err)
+	}
+
+	err = s.register(ctx, s.Shipments,
+		fake.ShipmentEvent{},
+		embedavro.ShipmentEventAvro,
+		embedproto.ShipmentEvent,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.ShipmentEvent{} },
+			toMessage: func(v any) proto.Message {
+				event := v.(fake.ShipmentEvent)
+				return event.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.ShipmentEvent) = fake.NewShipmentEventFromProtobuf(m.(*shoppb.ShipmentEvent))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register shipment event schema: %w", err)
+	}
+
+	err = s.register(ctx, s.Reviews,
+		fake.Review{},
+		embedavro.ReviewAvro,
+		embedproto.Review,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.Review{} },
+			toMessage: func(v any) proto.Message {
+				review := v.(fake.Review)
+				return review.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.Review) = fake.NewReviewFromProtobuf(m.(*shoppb.Review))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register review schema: %w", err)
+	}
+
+	err = s.register(ctx, s.Carts,
+		fake.CartEvent{},
+		embedavro.CartEventAvro,
+		embedproto.CartEvent,
+		nil,
+		entityCodec{
+			newMessage: func() proto.Message { return &shoppb.CartEvent{} },
+			toMessage: func(v any) proto.Message {
+				event := v.(fake.CartEvent)
+				return event.Protobuf()
+			},
+			fromMessage: func(m proto.Message, v any) {
+				*v.(*fake.CartEvent) = fake.NewCartEventFromProtobuf(m.(*shoppb.CartEvent))
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register cart event schema: %w", err)
+	}
+
+	return nil
+}
+
+// register creates the schema for the topic's format in the schema registry
+// and registers the encode and decode functions for the given type. Plain JSON
+// topics are skipped.
+func (s *Serdes) register(
+	ctx context.Context,
+	ts *TopicSerde,
+	v any,
+	avroSchema string,
+	protoSchema string,
+	references []sr.SchemaReference,
+	codec entityCodec,
+) error {
+	switch ts.format {
+	case config.SerdeJSONSchema:
+		jsonSchema, err := newJSONSchema(v)
+		if err != nil {
+			return err
+		}
+		// The title of the JSON schema is the record name
+		id, err := s.createSchema(ctx, ts.topic, reflect.TypeOf(v).Name(), sr.Schema{
+			Schema: jsonSchema,
+			Type:   sr.TypeJSON,
+		})
+		if err != nil {
+			return err
+		}
+		ts.registerSchema(
+			id,
+			v,
+			sr.EncodeFn(json.Marshal),
+			sr.DecodeFn(json.Unmarshal),
+		)
+	case config.SerdeAvro:
+		schema, err := avro.Parse(avroSchema)
+		if err != nil {
+			return fmt.Errorf("failed to parse avro schema with avro lib: %w", err)
+		}
+		id, err := s.createSchema(ctx, ts.topic, avroRecordName(schema), sr.Schema{
+			Schema:     avroSchema,
+			Type:       sr.TypeAvro,
+			References: references,
+		})
+		if err != nil {
+			return err
+		}
+		ts.registerSchema(
+			id,
+			v,
+			sr.EncodeFn(func(v any) ([]byte, error) {
+				if codec.encodeAvro != nil {
+					return codec.encodeAvro(schema, v)
+				}
+				return avro.Marshal(schema, v)
+			}),
+			sr.DecodeFn(func(b []byte, v any) error {
+				if codec.decodeAvro != nil {
+					return codec.decodeAvro(schema, b, v)
+				}
+				return avro.Unmarshal(schema, b, v)
+			}),
+		)
+	case config.SerdeProtobuf:
+		id, err := s.createSchema(ctx, ts.topic, protoRecordName(codec.newMessage()), sr.Schema{
+			Schema:     protoSchema,
+			Type:       sr.TypeProtobuf,
+			References: references,
+		})
+		if err != nil {
+			return err
+		}
+		ts.registerSchema(
+			id,
+			v,
+			sr.EncodeFn(func(v any) ([]byte, error) {
+				return proto.Marshal(codec.toMessage(v))
+			}),
+			sr.DecodeFn(func(b []byte, v any) error {
+				m := codec.newMessage()
+				if err := proto.Unmarshal(b, m); err != nil {
+					return err
+				}
+				codec.fromMessage(m, v)
+				return nil
+			}),
+			sr.Index(0),
+		)
+	}
+
+	return nil
+}
+
+// createSchema creates the schema in the topic's subject and returns its ID.
+// The subject is named by the topic's subject name strategy, which may
+// include the fully qualified name of the schema's record. If the
+// registration of the topic's schema is skipped, the unknown schema ID is
+// returned instead.
+func (s *Serdes) createSchema(ctx context.Context, topic string, recordName string, schema sr.Schema) (int, error) {
+	if s.cfg.SchemaRegistryFailures.Skips(topic) {
+		s.logger.Info("skipping schema registration", zap.String("topic_name", topic))
+		return s.cfg.SchemaRegistryFailures.UnknownSchemaID, nil
+	}
+
+	subjectSchema, err := s.srClient.CreateSchema(ctx, s.cfg.SubjectName(topic, recordName), schema)
+	if err != nil {
+		return 0, err
+	}
+	return subjectSchema.ID, nil
+}
+
+// avroRecordName returns the fully qualified name of the avro schema's
+// record, e.g. "com.shop.v1.avro.Order".
+func avroRecordName(schema avro.Schema) string {
+	if named, ok := schema.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	return string(schema.Type())
+}
+
+// protoRecordName returns the fully qualified name of the protobuf message,
+// e.g. "shop.v1.Order".
+func protoRecordName(m proto.Message) string {
+	return string(m.ProtoReflect().Descriptor().FullName())
+}
@@ -0,0 +1,302 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// ShipmentService consumes the orders topic and creates a shipment for each
+// order. Each shipment passes through several lifecycle steps which are
+// spread over time, so that the shipments topic contains keyed event streams
+// that evolve over several minutes.
+type ShipmentService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	orderSerde      *TopicSerde
+	serde           *TopicSerde
+
+	bufferSize         int
+	pendingShipmentsMu sync.Mutex
+	pendingShipments   []pendingShipment
+
+	topicName string
+}
+
+// pendingShipment is a shipment that has not yet been delivered.
+type pendingShipment struct {
+	shipment fake.Shipment
+	// nextStep is the index of the next lifecycle event in fake.ShipmentLifecycle.
+	nextStep int
+	dueAt    time.Time
+	// ctx carries the trace of the order, which all shipment events continue.
+	ctx context.Context
+}
+
+// NewShipmentService creates a new ShipmentService.
+func NewShipmentService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
+) (*ShipmentService, error) {
+	clientID := cfg.Services.Shipment.ClientIDFor(cfg.GlobalPrefix, "shipment-service")
+	metrics := newClientMetrics("shipment_service")
+	headers := newRecordHeaders(cfg.Headers, "shipment-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "shipment-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Shipment.Offsets, logger)
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("shipment-service")),
+			kgo.ConsumeTopics(cfg.TopicName("orders")),
+			readCommittedOrders(),
+		)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	// This slice is used to keep track of all shipments that are not yet delivered
+	bufferSize := 500
+	pendingShipments := make([]pendingShipment, 0, bufferSize)
+
+	return &ShipmentService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "shipment_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Shipment.SlowConsumer),
+		offsets:         offsets,
+		orderSerde:      serdes.Orders,
+		serde:           serdes.Shipments,
+
+		bufferSize:         bufferSize,
+		pendingShipmentsMu: sync.Mutex{},
+		pendingShipments:   pendingShipments,
+
+		topicName: cfg.TopicName("shipments"),
+	}, nil
+}
+
+// Initialize shipment service by reconciling the shipments topic.
+func (svc *ShipmentService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing shipment service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized shipment service")
+
+	return nil
+}
+
+// Close stops consuming the orders topic, flushes all buffered records and
+// closes the Kafka clients.
+func (svc *ShipmentService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// Start consuming messages from the orders topic and create a shipment for
+// each consumed order. Pending shipments are advanced in the background until
+// the consumer has been closed.
+func (svc *ShipmentService) Start() {
+	defer close(svc.consumerStopped)
+
+	quit := make(chan struct{})
+	advanceStopped := make(chan struct{})
+	go func() {
+		defer close(advanceStopped)
+		svc.advanceShipments(quit)
+	}()
+	defer func() {
+		close(quit)
+		<-advanceStopped
+	}()
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			kafkaMessagesConsumedTotal.
+				With(map[string]string{"event_type": EventTypeOrderConsumed}).
+				Inc()
+
+			if rec.Value == nil {
+				return
+			}
+
+			order := fake.Order{}
+			err := svc.orderSerde.Decode(rec.Value, &order)
+			if err != nil {
+				// Skip message
+				svc.logger.Warn("failed to deserialize order", zap.Error(err))
+				return
+			}
+
+			// The processing span only covers buffering the shipment, the
+			// shipment events are produced as its children later on
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			svc.pendingShipmentsMu.Lock()
+			if len(svc.pendingShipments) < svc.bufferSize {
+				svc.pendingShipments = append(svc.pendingShipments, pendingShipment{
+					shipment: fake.NewShipment(order),
+					nextStep: 0,
+					dueAt:    svc.clock.now(),
+					ctx:      ctx,
+				})
+			}
+			svc.pendingShipmentsMu.Unlock()
+			span.End()
+		})
+	}
+}
+
+// advanceShipments regularly produces the next lifecycle event for all pending
+// shipments that are due. Delivered shipments are removed from the buffer.
+// It returns once the quit channel has been closed.
+func (svc *ShipmentService) advanceShipments(quit <-chan struct{}) {
+	ticker := time.NewTicker(svc.clock.realDuration(time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+		now := svc.clock.now()
+
+		svc.pendingShipmentsMu.Lock()
+		remaining := svc.pendingShipments[:0]
+		for _, pending := range svc.pendingShipments {
+			if pending.dueAt.After(now) {
+				remaining = append(remaining, pending)
+				continue
+			}
+
+			eventType := fake.ShipmentLifecycle[pending.nextStep]
+			eventCtx := withEventType(pending.ctx, shipmentEventTypeMetricLabels[eventType])
+			err := svc.produceShipmentEvent(eventCtx, fake.NewShipmentEvent(pending.shipment, eventType, now))
+			if err != nil {
+				svc.logger.Warn("failed to produce shipment event", zap.Error(err))
+			} else {
+				kafkaMessagesProducedTotal.With(map[string]string{"event_type": shipmentEventTypeMetricLabels[eventType]}).Inc()
+			}
+
+			pending.nextStep++
+			if pending.nextStep < len(fake.ShipmentLifecycle) {
+				pending.dueAt = now.Add(svc.nextStepDelay())
+				remaining = append(remaining, pending)
+			}
+		}
+		svc.pendingShipments = remaining
+		svc.pendingShipmentsMu.Unlock()
+	}
+}
+
+// nextStepDelay returns a random duration between the configured min and max step delay.
+func (svc *ShipmentService) nextStepDelay() time.Duration {
+	spread := svc.cfg.Shipments.MaxStepDelay - svc.cfg.Shipments.MinStepDelay
+	if spread <= 0 {
+		return svc.cfg.Shipments.MinStepDelay
+	}
+	return svc.cfg.Shipments.MinStepDelay + time.Duration(rand.Int63n(int64(spread)))
+}
+
+func (svc *ShipmentService) produceShipmentEvent(ctx context.Context, event fake.ShipmentEvent) error {
+	serialized, err := svc.serde.Encode(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize shipment event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, event.Shipment.OrderID, ""),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	svc.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+
+	return nil
+}
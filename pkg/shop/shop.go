@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/mroth/weightedrand"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/twmb/franz-go/pkg/kgo"
 	"go.uber.org/zap"
 
 	"github.com/cloudhut/owl-shop/pkg/config"
@@ -15,18 +16,158 @@ import (
 	"github.com/cloudhut/owl-shop/pkg/sr"
 )
 
+// Shop simulates the traffic of a single shop profile. All shops of a process
+// share the HTTP listeners and the health checker, which are owned by the
+// Runner. A shop that is embedded via New owns its health checker and does
+// not start any HTTP listeners.
 type Shop struct {
 	cfg    config.Config
+	name   string
 	logger *zap.Logger
 
-	chooser *weightedrand.Chooser
+	traffic *trafficController
+	workers *workerPool
+	clock   *simulationClock
+
+	serdes  *Serdes
+	tracing *tracing
+	webhook *webhookSink
+	files   *fileSink
+	outages *outageMonitor
+
+	// backgroundCtx is cancelled by cancelBackgroundTasks, which stops all
+	// background tasks that are not bound to the traffic simulation, such as
+	// the schema evolution.
+	backgroundCtx         context.Context
+	cancelBackgroundTasks context.CancelFunc
+
+	// initializers initialize the components upon startup. initialized is
+	// closed once all of them have succeeded and the services have been
+	// started, initializationDone once the initialization has returned.
+	// initializationStarted is set once startInitialization has been called.
+	initializers          []initializer
+	initialized           chan struct{}
+	initializationStarted bool
+	initializationDone    chan struct{}
+	initializationCtx     context.Context
+	cancelInitialization  context.CancelFunc
+	startServicesOnce     sync.Once
+
+	health *healthChecker
+
+	// stopCh is closed once the shop shall stop simulating traffic and
+	// trafficStopped is closed once the traffic simulation has returned.
+	stopCh         chan struct{}
+	stopOnce       sync.Once
+	trafficStopped chan struct{}
+
+	// ownsHealth is set if the shop has been created by New, so that it
+	// closes the health checker once it has stopped.
+	ownsHealth bool
+
+	// pageImpressionsWg tracks the page impressions that are in progress.
+	pageImpressionsWg sync.WaitGroup
+	// seededMu serializes the page impressions if a seed has been configured.
+	seededMu sync.Mutex
 
 	// Services
-	customerSvc *CustomerService
+	customerSvc       *CustomerService
+	addressSvc        *AddressService
+	frontendSvc       *FrontendService
+	productCatalogSvc *ProductCatalogService
+	orderSvc          *OrderService
+	inventorySvc      *InventoryService
+	paymentSvc        *PaymentService
+	shipmentSvc       *ShipmentService
+	reviewSvc         *ReviewService
+	cartSvc           *CartService
+	deadLetterSvc     *DeadLetterService
+	manyTopicsSvc     *ManyTopicsService
+	notificationSvc   *NotificationService
+	returnSvc         *ReturnService
+	supportSvc        *SupportService
+	rebalancer        *rebalancer
+	generators        []namedGenerator
+	verifier          *verifier
+
+	// scenario runs the configured scenario alongside the traffic simulation.
+	// It is nil if no scenario is configured.
+	scenario *scenarioRunner
+	// sales boosts the traffic of the configured sales. It is nil if no sales
+	// are configured.
+	sales *saleScheduler
 }
 
-func New(cfg config.Config, logger *zap.Logger) (*Shop, error) {
-	kafkaFactory := kafka.NewFactory(cfg.Kafka, logger.Named("kafka_client"))
+// newShop creates the shop of the given profile. The name is empty for the
+// unnamed profile of the root shop config. Its components are registered with
+// the health checker and its admin routes on the admin mux, if not nil. The
+// components are initialized by initialize. The options of New are passed as
+// they are, the Runner passes the zero options.
+func newShop(
+	cfg config.Config,
+	name string,
+	logger *zap.Logger,
+	health *healthChecker,
+	adminMux *http.ServeMux,
+	opts options,
+) (_ *Shop, err error) {
+	// Records are dropped while the brokers are unavailable, which the
+	// services would log for each record otherwise
+	if cfg.Kafka.OutageTolerance.Enabled {
+		logger = rateLimitDroppedRecords(logger)
+	}
+	// Each service uses the factory of the cluster it is pinned to
+	kafkaFactories, err := kafka.NewFactories(cfg.Kafka, logger.Named("kafka_client"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka factories: %w", err)
+	}
+	services := cfg.Shop.Services
+	for name, svc := range services.ByName() {
+		if _, ok := kafkaFactories[svc.Cluster]; !ok {
+			return nil, fmt.Errorf("%v service is pinned to cluster '%v', which is not configured", name, svc.Cluster)
+		}
+	}
+	// All clients send their produced records to the webhook and file sinks,
+	// log a sample of them, add sequence numbers to them and report broker
+	// outages, if enabled. They partition the records of each topic with the
+	// topic's partitioner
+	webhook := newWebhookSink(cfg.Shop.Webhook, logger.Named("webhook_sink"))
+	records := newRecordLog(cfg.Shop.RecordLog, logger.Named("record_log"))
+	files, err := newFileSink(cfg.Shop.FileSink, logger.Named("file_sink"))
+	if err != nil {
+		// The webhook sink sends in the background already
+		_ = webhook.shutdown(context.Background())
+		return nil, err
+	}
+	sequences, err := newSequenceNumbers(cfg.Shop.Integrity)
+	if err != nil {
+		_ = webhook.shutdown(context.Background())
+		_ = files.shutdown(context.Background())
+		return nil, err
+	}
+	outages := newOutageMonitor(cfg.Kafka.OutageTolerance, logger.Named("outage_monitor"))
+	// The sinks and the outage monitor run in the background already, so
+	// they are shut down if the shop can't be created
+	defer func() {
+		if err != nil {
+			_ = webhook.shutdown(context.Background())
+			_ = files.shutdown(context.Background())
+			_ = outages.shutdown(context.Background())
+		}
+	}()
+	for name, factory := range kafkaFactories {
+		kafkaFactories[name] = factory.WithHooks(webhook, files, records, outages).WithClientHooks(sequences).WithOpts(append([]kgo.Opt{newTopicPartitioner(cfg.Shop).opt()}, opts.kafkaOpts...)...)
+	}
+	// Each service's clients also use the service's producer overrides.
+	// Duplicates are only written if idempotence is disabled.
+	serviceFactory := func(svc config.Service) *kafka.Factory {
+		producer := svc.Producer
+		if cfg.Shop.Duplicates.Enabled {
+			producer.DisableIdempotence = true
+		}
+		return kafkaFactories[svc.Cluster].WithProducer(producer)
+	}
+
 	schemaFactory := sr.NewFactory(cfg.SchemaRegistry, logger.Named("schema_registry"))
 
 	// srClient may be nil if schema registry hasn't been configured
@@ -35,102 +176,574 @@ func New(cfg config.Config, logger *zap.Logger) (*Shop, error) {
 		return nil, fmt.Errorf("failed to create schema registry client")
 	}
 
-	customerSvc, err := NewCustomerService(cfg.Shop, logger, kafkaFactory)
+	clock := newSimulationClock(opts.clock, cfg.Shop.TimeAcceleration)
+
+	tracing, err := newTracing(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing: %w", err)
+	}
+
+	serdes, err := NewSerdes(cfg.Shop, logger.Named("serdes"), srClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create serdes: %w", err)
+	}
+
+	customerSvc, err := NewCustomerService(cfg.Shop, logger, serviceFactory(services.Customer), serdes, tracing, clock)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create customer service: %w", err)
 	}
 
-	addressSvc, err := NewAddressService(cfg.Shop, logger.Named("address_svc"), kafkaFactory)
+	addressSvc, err := NewAddressService(cfg.Shop, logger.Named("address_svc"), serviceFactory(services.Address), serdes, tracing, clock)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create address service: %w", err)
 	}
 
-	frontendSvc, err := NewFrontendService(cfg.Shop, logger.Named("frontend_svc"), kafkaFactory)
+	productCatalogSvc, err := NewProductCatalogService(cfg.Shop, logger.Named("product_catalog_svc"), serviceFactory(services.ProductCatalog), serdes, tracing, clock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create product catalog service: %w", err)
+	}
+
+	frontendSvc, err := NewFrontendService(cfg.Shop, logger.Named("frontend_svc"), serviceFactory(services.Frontend), serdes, productCatalogSvc, tracing, clock)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create frontend service: %w", err)
 	}
 
-	orderSvc, err := NewOrderService(cfg.Shop, logger.Named("order_svc"), kafkaFactory, srClient)
+	orderSvc, err := NewOrderService(cfg.Shop, logger.Named("order_svc"), serviceFactory(services.Order), srClient, serdes, productCatalogSvc, tracing, clock)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order service: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
+	inventorySvc, err := NewInventoryService(cfg.Shop, logger.Named("inventory_svc"), serviceFactory(services.Inventory), serdes, tracing, clock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inventory service: %w", err)
+	}
 
-	err = customerSvc.Initialize(ctx)
+	paymentSvc, err := NewPaymentService(cfg.Shop, logger.Named("payment_svc"), serviceFactory(services.Payment), serdes, tracing, clock)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize customer service: %w", err)
+		return nil, fmt.Errorf("failed to create payment service: %w", err)
+	}
+	// A stopped transactional consumer fails the liveness probe
+	paymentComponent := "payment service"
+	if name != "" {
+		paymentComponent = name + "/" + paymentComponent
 	}
+	paymentSvc.fail = func(err error) { health.setFailed(paymentComponent, err) }
 
-	err = addressSvc.Initialize(ctx)
+	shipmentSvc, err := NewShipmentService(cfg.Shop, logger.Named("shipment_svc"), serviceFactory(services.Shipment), serdes, tracing, clock)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize address service: %w", err)
+		return nil, fmt.Errorf("failed to create shipment service: %w", err)
 	}
 
-	err = frontendSvc.Initialize(ctx)
+	reviewSvc, err := NewReviewService(cfg.Shop, logger.Named("review_svc"), serviceFactory(services.Review), serdes, productCatalogSvc, tracing, clock)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize frontend service: %w", err)
+		return nil, fmt.Errorf("failed to create review service: %w", err)
 	}
 
-	err = orderSvc.Initialize(ctx)
+	cartSvc, err := NewCartService(cfg.Shop, logger.Named("cart_svc"), serviceFactory(services.Cart), serdes, productCatalogSvc, orderSvc, tracing, clock)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize order service: %w", err)
+		return nil, fmt.Errorf("failed to create cart service: %w", err)
 	}
 
-	go addressSvc.Start()
-	go orderSvc.Start()
+	// Poison messages are only injected on demand, the dead letter service
+	// remains nil otherwise
+	var deadLetterSvc *DeadLetterService
+	if cfg.Shop.DeadLetters.Enabled {
+		target, ok := poisonTargets(services, serdes, productCatalogSvc.pricing, clock)[cfg.Shop.DeadLetters.Topic]
+		if !ok {
+			return nil, fmt.Errorf("poison messages can't be injected into topic '%v'", cfg.Shop.DeadLetters.Topic)
+		}
+		deadLetterSvc, err = NewDeadLetterService(cfg.Shop, logger.Named("dead_letter_svc"), kafkaFactories[target.cluster], target, tracing, clock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dead letter service: %w", err)
+		}
+	}
+
+	// The many topics service remains nil if the stress mode is disabled
+	var manyTopicsSvc *ManyTopicsService
+	if cfg.Shop.ManyTopics.Enabled {
+		manyTopicsSvc, err = NewManyTopicsService(cfg.Shop, logger.Named("many_topics_svc"), kafkaFactories[cfg.Shop.ManyTopics.Cluster], tracing, clock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create many topics service: %w", err)
+		}
+	}
+
+	// The notification service remains nil if notifications are disabled
+	var notificationSvc *NotificationService
+	if cfg.Shop.Notifications.Enabled {
+		notificationSvc, err = NewNotificationService(cfg.Shop, logger.Named("notification_svc"), serviceFactory(services.Notification), serdes, tracing, clock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create notification service: %w", err)
+		}
+	}
+
+	// The return service remains nil if returns are disabled
+	var returnSvc *ReturnService
+	if cfg.Shop.Returns.Enabled {
+		returnSvc, err = NewReturnService(cfg.Shop, logger.Named("return_svc"), serviceFactory(services.Return), serdes, tracing, clock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create return service: %w", err)
+		}
+	}
+
+	// The support service remains nil if support tickets are disabled
+	var supportSvc *SupportService
+	if cfg.Shop.Support.Enabled {
+		supportSvc, err = NewSupportService(cfg.Shop, logger.Named("support_svc"), serviceFactory(services.Support), serdes, tracing, clock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create support service: %w", err)
+		}
+	}
+
+	// Consumer groups are only rebalanced on demand, the rebalancer remains
+	// nil otherwise
+	var rebalancer *rebalancer
+	if cfg.Shop.Rebalances.Enabled {
+		rebalancer, err = newRebalancer(cfg.Shop, logger, kafkaFactories)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rebalancer: %w", err)
+		}
+	}
+
+	// The verifier remains nil if it is disabled
+	verifier, err := newVerifier(cfg.Shop, logger, kafkaFactories[cfg.Shop.Verifier.Cluster])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verifier: %w", err)
+	}
 
-	// Random chooser
-	wr, err := weightedrand.NewChooser(
-		weightedrand.Choice{Item: frontendSvc.CreateFrontendEvent, Weight: 1000},
-		weightedrand.Choice{Item: customerSvc.CreateCustomer, Weight: 50},
-		weightedrand.Choice{Item: addressSvc.CreateAddress, Weight: 30},
-		weightedrand.Choice{Item: customerSvc.DeleteCustomer, Weight: 8},
-		weightedrand.Choice{Item: customerSvc.ModifyCustomer, Weight: 6},
-		weightedrand.Choice{Item: orderSvc.CreateOrder, Weight: 5},
-	)
+	// Custom event generators are created after the built-in services and
+	// initialized after them as well
+	generators, err := newGenerators(cfg.Shop, logger, opts.generators, serviceFactory, tracing, clock)
+	if err != nil {
+		return nil, err
+	}
+
+	// Components are initialized in this order, before any traffic is simulated.
+	// Their names are qualified by the profile name, as all profiles share the
+	// health checker.
+	initializers := []initializer{
+		{"serdes", serdes.Initialize},
+		{"customer service", customerSvc.Initialize},
+		{"address service", addressSvc.Initialize},
+		{"frontend service", frontendSvc.Initialize},
+		{"product catalog service", productCatalogSvc.Initialize},
+		{"order service", orderSvc.Initialize},
+		{"inventory service", inventorySvc.Initialize},
+		{"payment service", paymentSvc.Initialize},
+		{"shipment service", shipmentSvc.Initialize},
+		{"review service", reviewSvc.Initialize},
+		{"cart service", cartSvc.Initialize},
+	}
+	if deadLetterSvc != nil {
+		initializers = append(initializers, initializer{"dead letter service", deadLetterSvc.Initialize})
+	}
+	if manyTopicsSvc != nil {
+		initializers = append(initializers, initializer{"many topics service", manyTopicsSvc.Initialize})
+	}
+	if notificationSvc != nil {
+		initializers = append(initializers, initializer{"notification service", notificationSvc.Initialize})
+	}
+	if returnSvc != nil {
+		initializers = append(initializers, initializer{"return service", returnSvc.Initialize})
+	}
+	if supportSvc != nil {
+		initializers = append(initializers, initializer{"support service", supportSvc.Initialize})
+	}
+	for _, g := range generators {
+		initializers = append(initializers, initializer{g.name + " generator", g.generator.Initialize})
+	}
+
+	components := make([]string, len(initializers))
+	for i := range initializers {
+		if name != "" {
+			initializers[i].name = name + "/" + initializers[i].name
+		}
+		components[i] = initializers[i].name
+	}
+	health.addComponents(components...)
+
+	// Events that are randomly triggered by simulated page impressions. The event names
+	// match the yaml keys of the event weights config.
+	weights := cfg.Shop.EventWeights
+	events := []trafficEvent{
+		{name: "createFrontendEvent", fn: frontendSvc.CreateFrontendEvent, weight: weights.CreateFrontendEvent, service: "frontend"},
+		{name: "createCustomer", fn: customerSvc.CreateCustomer, weight: weights.CreateCustomer, service: "customer"},
+		{name: "createAddress", fn: addressSvc.CreateAddress, weight: weights.CreateAddress, service: "address"},
+		{name: "modifyAddress", fn: addressSvc.ModifyAddress, weight: weights.ModifyAddress, service: "address"},
+		{name: "deleteCustomer", fn: customerSvc.DeleteCustomer, weight: weights.DeleteCustomer, service: "customer"},
+		{name: "modifyCustomer", fn: customerSvc.ModifyCustomer, weight: weights.ModifyCustomer, service: "customer"},
+		{name: "createOrder", fn: orderSvc.CreateOrder, weight: weights.CreateOrder, service: "order"},
+		{name: "modifyProduct", fn: productCatalogSvc.ModifyProduct, weight: weights.ModifyProduct, service: "productCatalog"},
+		{name: "createProduct", fn: productCatalogSvc.CreateProduct, weight: weights.CreateProduct, service: "productCatalog"},
+		{name: "releaseStock", fn: inventorySvc.ReleaseStock, weight: weights.ReleaseStock, service: "inventory"},
+		{name: "createReview", fn: reviewSvc.CreateReview, weight: weights.CreateReview, service: "review"},
+		{name: "modifyReview", fn: reviewSvc.ModifyReview, weight: weights.ModifyReview, service: "review"},
+		{name: "deleteReview", fn: reviewSvc.DeleteReview, weight: weights.DeleteReview, service: "review"},
+		{name: "createCart", fn: cartSvc.CreateCart, weight: weights.CreateCart, service: "cart"},
+		{name: "updateCart", fn: cartSvc.UpdateCart, weight: weights.UpdateCart, service: "cart"},
+	}
+	for _, g := range generators {
+		for _, event := range events {
+			if event.name == g.name {
+				return nil, fmt.Errorf("generator '%v' must not be named like a built-in event", g.name)
+			}
+		}
+		events = append(events, trafficEvent{name: g.name, fn: g.generator.Generate, weight: g.weight})
+	}
+	traffic, err := newTrafficController(cfg.Shop, events, clock)
+	if err != nil {
+		return nil, err
+	}
+	scenario, err := newScenarioRunner(cfg.Shop.Scenario, logger.Named("scenario"), traffic, deadLetterSvc, clock)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create random chooser: %w", err)
+		return nil, fmt.Errorf("failed to create scenario runner: %w", err)
 	}
 
-	return &Shop{
+	backgroundCtx, cancelBackgroundTasks := context.WithCancel(context.Background())
+	initializationCtx, cancelInitialization := context.WithCancel(context.Background())
+
+	shop := &Shop{
 		cfg:    cfg,
+		name:   name,
 		logger: logger,
 
-		chooser: wr,
+		traffic: traffic,
+		workers: newWorkerPool(cfg.Shop.Workers),
+		clock:   clock,
+		serdes:  serdes,
+		tracing: tracing,
+		webhook: webhook,
+		files:   files,
+		outages: outages,
+
+		backgroundCtx:         backgroundCtx,
+		cancelBackgroundTasks: cancelBackgroundTasks,
+
+		initializers:         initializers,
+		initialized:          make(chan struct{}),
+		initializationDone:   make(chan struct{}),
+		cancelInitialization: cancelInitialization,
+
+		health: health,
+
+		stopCh:         make(chan struct{}),
+		trafficStopped: make(chan struct{}),
 
-		customerSvc: customerSvc,
-	}, nil
+		customerSvc:       customerSvc,
+		addressSvc:        addressSvc,
+		frontendSvc:       frontendSvc,
+		productCatalogSvc: productCatalogSvc,
+		orderSvc:          orderSvc,
+		inventorySvc:      inventorySvc,
+		paymentSvc:        paymentSvc,
+		shipmentSvc:       shipmentSvc,
+		reviewSvc:         reviewSvc,
+		cartSvc:           cartSvc,
+		deadLetterSvc:     deadLetterSvc,
+		manyTopicsSvc:     manyTopicsSvc,
+		notificationSvc:   notificationSvc,
+		returnSvc:         returnSvc,
+		supportSvc:        supportSvc,
+		rebalancer:        rebalancer,
+		generators:        generators,
+		verifier:          verifier,
+
+		scenario: scenario,
+		sales:    newSaleScheduler(cfg.Shop, logger.Named("sales"), traffic, clock),
+	}
+	shop.initializationCtx = initializationCtx
+	if adminMux != nil {
+		shop.registerAdminRoutes(adminMux, adminPathPrefix(name))
+	}
+
+	return shop, nil
+}
+
+// startInitialization initializes all components, either synchronously or in
+// the background as configured. Once it returns an error, the shop must not
+// be started.
+func (s *Shop) startInitialization() error {
+	s.initializationStarted = true
+	if s.cfg.Shop.Initialization.InBackground {
+		go func() {
+			defer close(s.initializationDone)
+			if err := s.initialize(s.initializationCtx, 0); err != nil {
+				s.logger.Info("stopped initialization in the background", zap.Error(err))
+			}
+		}()
+		return nil
+	}
+
+	defer close(s.initializationDone)
+	if err := s.initialize(s.initializationCtx, s.cfg.Shop.Initialization.MaxAttempts); err != nil {
+		s.cancelInitialization()
+		s.cancelBackgroundTasks()
+		return err
+	}
+
+	return nil
 }
 
 // Start starts all shop components and triggers events (e.g. customer registration) in accordance with the
-// config for traffic simulation.
+// config for traffic simulation. If enabled, the historical backfill is performed before the live traffic
+// simulation starts. If the components are initialized in the background, the traffic simulation starts once
+// the initialization has succeeded. It blocks until Stop is called or, if configured, until the max events have
+// been simulated or the run duration has elapsed.
 func (s *Shop) Start() error {
-	http.Handle("/metrics", promhttp.Handler())
+	defer close(s.trafficStopped)
+
+	select {
+	case <-s.initialized:
+	case <-s.stopCh:
+		return nil
+	}
+
+	if s.cfg.Shop.Backfill.Enabled && !s.backfill() {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		err := http.ListenAndServe(":8080", nil)
-		s.logger.Info("prometheus http handler quit", zap.Error(err))
+		select {
+		case <-s.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
-	for {
-		for i := 0; i < s.cfg.Shop.RequestRate; i++ {
-			pageImpressionsSimulated.Inc()
-			s.SimulatePageImpression()
+	// The run duration cancels the context rather than setting its deadline,
+	// because the rate limiter fails early on events beyond the deadline
+	var runDurationElapsed atomic.Bool
+	if runDuration := s.cfg.Shop.RunDuration; runDuration > 0 {
+		timer := time.AfterFunc(runDuration, func() {
+			runDurationElapsed.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+
+	// The scenario's offsets are relative to the start of the live traffic
+	if s.scenario != nil {
+		s.pageImpressionsWg.Add(1)
+		go func() {
+			defer s.pageImpressionsWg.Done()
+			s.scenario.run(ctx)
+		}()
+	}
+
+	// Services with a rate of their own trigger their events alongside the
+	// weighted page impressions. Only the weighted page impressions count
+	// towards the max events
+	for _, svc := range s.traffic.services {
+		s.pageImpressionsWg.Add(1)
+		go func(svc *serviceTraffic) {
+			defer s.pageImpressionsWg.Done()
+			for s.traffic.waitService(ctx, svc) == nil {
+				pageImpressionsSimulated.Inc()
+				s.simulate(func() func() { return s.traffic.pickService(svc) })
+			}
+		}(svc)
+	}
+
+	maxEvents := s.cfg.Shop.MaxEvents
+	for i := 0; maxEvents == 0 || i < maxEvents; i++ {
+		if err := s.traffic.wait(ctx); err != nil {
+			if ctx.Err() == nil {
+				return fmt.Errorf("failed to wait for the next page impression: %w", err)
+			}
+			if runDurationElapsed.Load() {
+				s.logger.Info("run duration has elapsed, stopped traffic simulation",
+					zap.Duration("run_duration", s.cfg.Shop.RunDuration),
+					zap.Int("simulated_page_impressions", i))
+			}
+			return nil
+		}
+		pageImpressionsSimulated.Inc()
+		s.SimulatePageImpression()
+	}
+
+	s.logger.Info("simulated max events, stopped traffic simulation", zap.Int("max_events", maxEvents))
+
+	return nil
+}
+
+// Stop gracefully shuts down the shop. It stops the traffic simulation, waits
+// for all page impressions that are in progress and then closes all services,
+// so that all buffered records are flushed to Kafka and consumed offsets are
+// committed. Services are closed in the order of their dependencies, so that
+// producing services are closed before the services consuming their topics.
+// The shared HTTP listeners are shut down by the Runner. Stop must only be
+// called after Start.
+func (s *Shop) Stop(ctx context.Context) error {
+	s.logger.Info("stopping shop")
+	s.health.setStopping()
+
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	select {
+	case <-s.trafficStopped:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for traffic simulation to stop: %w", ctx.Err())
+	}
+	s.pageImpressionsWg.Wait()
+	s.workers.close()
+
+	return s.closeServices(ctx)
+}
+
+// closeServices closes all services, flushes their records and shuts down the
+// sinks and the tracing. It is called by Stop once the traffic simulation has
+// stopped, or by New and NewRunner if the shop's initialization has failed.
+func (s *Shop) closeServices(ctx context.Context) error {
+	// Services whose initialization has not completed are started anyway, so
+	// that their consumers return once they are closed
+	s.cancelInitialization()
+	if s.initializationStarted {
+		<-s.initializationDone
+	}
+	s.cancelBackgroundTasks()
+	s.startServices()
+
+	type closableService struct {
+		name  string
+		close func(context.Context) error
+	}
+	// Custom generators are closed first, as they may use the built-in
+	// services' topics but are not used by them. The built-in services are
+	// closed before the services that consume their records, so that the
+	// records that they flush are still consumed. The order service consumes
+	// the customers, but is closed before the customer service, whose loyalty
+	// consumer consumes the orders and produces the updated customers.
+	builtIn := []closableService{
+		{"frontend", s.frontendSvc.Close},
+		{"product catalog", s.productCatalogSvc.Close},
+		{"cart", s.cartSvc.Close},
+		{"order", s.orderSvc.Close},
+		{"customer", s.customerSvc.Close},
+		{"address", s.addressSvc.Close},
+		{"inventory", s.inventorySvc.Close},
+		{"payment", s.paymentSvc.Close},
+		{"shipment", s.shipmentSvc.Close},
+		{"review", s.reviewSvc.Close},
+	}
+	services := make([]closableService, 0, len(s.generators)+len(builtIn))
+	for _, g := range s.generators {
+		services = append(services, closableService{g.name + " generator", g.generator.Stop})
+	}
+	services = append(services, builtIn...)
+	if s.deadLetterSvc != nil {
+		services = append(services, closableService{"dead letter", s.deadLetterSvc.Close})
+	}
+	if s.manyTopicsSvc != nil {
+		services = append(services, closableService{"many topics", s.manyTopicsSvc.Close})
+	}
+	if s.notificationSvc != nil {
+		services = append(services, closableService{"notification", s.notificationSvc.Close})
+	}
+	if s.returnSvc != nil {
+		services = append(services, closableService{"return", s.returnSvc.Close})
+	}
+	if s.supportSvc != nil {
+		services = append(services, closableService{"support", s.supportSvc.Close})
+	}
+
+	// Keep closing the remaining services if one of them fails, so that as
+	// many records as possible are flushed. The first error is returned.
+	var firstErr error
+	for _, svc := range services {
+		if err := svc.close(ctx); err != nil {
+			s.logger.Warn("failed to close service", zap.String("service", svc.name), zap.Error(err))
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to close %v service: %w", svc.name, err)
+			}
+		}
+	}
+
+	// The verifier is closed once all records have been flushed, so that it
+	// may still consume the last ones
+	if s.verifier != nil {
+		// Integrity violations take precedence over the errors of the
+		// services, which have been logged already, so that the shop exits
+		// with their code
+		if err := s.verifier.Close(); err != nil {
+			firstErr = err
 		}
-		time.Sleep(s.cfg.Shop.RequestRateInterval)
 	}
+
+	// The webhook and file sinks are stopped once all records have been
+	// flushed, so that they receive all acknowledged records
+	if err := s.webhook.shutdown(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if err := s.files.shutdown(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if err := s.outages.shutdown(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+
+	// Spans are exported last, so that the spans of all flushed records are
+	// included
+	if err := s.tracing.shutdown(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if s.ownsHealth {
+		s.health.close()
+	}
+
+	s.logger.Info("shop stopped")
+
+	return firstErr
+}
+
+// CustomerService returns the customer service, e.g. for registering customers
+// on demand in a test harness.
+func (s *Shop) CustomerService() *CustomerService {
+	return s.customerSvc
+}
+
+// OrderService returns the order service, e.g. for placing or cancelling
+// orders on demand in a test harness.
+func (s *Shop) OrderService() *OrderService {
+	return s.orderSvc
+}
+
+// TrafficSettings returns the current traffic simulation settings.
+func (s *Shop) TrafficSettings() TrafficSettings {
+	return s.traffic.settings()
 }
 
 // SimulatePageImpression simulates a user visiting a page in our imaginary owl shop. This page impression can be a
 // user registration, oder, viewing articles or doing anything else a common user would do in a shop.
+//
+// Page impressions are queued for a bounded number of workers. While the queue
+// is full, this blocks or drops the page impression as configured. If a seed
+// has been configured, page impressions are simulated sequentially, including
+// the ones of services with a rate of their own, so that the random values
+// are drawn in a reproducible order.
 func (s *Shop) SimulatePageImpression() {
+	s.simulate(s.traffic.pick)
+}
 
-	go func() {
-		fn, isOk := s.chooser.Pick().(func())
-		if !isOk {
-			s.logger.Fatal("randomly picked method is not a func")
-		}
+// simulate triggers the event func that is returned by the given pick func,
+// see SimulatePageImpression.
+func (s *Shop) simulate(pick func() func()) {
+	if s.cfg.Shop.Seed != 0 {
+		// Services with a rate of their own simulate from their own
+		// goroutines, which must not draw random values at the same time
+		s.seededMu.Lock()
+		defer s.seededMu.Unlock()
+		fn := pick()
 		fn()
-	}()
+		return
+	}
+
+	s.pageImpressionsWg.Add(1)
+	queued := s.workers.submit(func() {
+		defer s.pageImpressionsWg.Done()
+		fn := pick()
+		fn()
+	})
+	if !queued {
+		s.pageImpressionsWg.Done()
+	}
 }
@@ -0,0 +1,55 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// closeClients gracefully closes the Kafka clients of a service. If the
+// service's poll loop is throttled by the given throttle, the throttle is
+// stopped and the loop returns before the consumer client is closed, so that
+// the loop commits the offsets of its last batch on an open client.
+// Otherwise, closing the consumer client stops the loop. Closing the consumer
+// client commits the consumed offsets and leaves the consumer group. Once the
+// service's poll loop has returned, all buffered records of the service's
+// producer are flushed before the meta client is closed as well. The
+// consumer client, its stopped channel and the throttle are nil for services
+// that do not consume any topic.
+func closeClients(
+	ctx context.Context,
+	consumerClient *kgo.Client,
+	consumerStopped <-chan struct{},
+	throttle *consumerThrottle,
+	producer recordProducer,
+	metaClient *kgo.Client,
+) error {
+	if consumerClient != nil {
+		if throttle != nil {
+			throttle.stop()
+		} else {
+			consumerClient.Close()
+		}
+		var stopErr error
+		select {
+		case <-consumerStopped:
+		case <-ctx.Done():
+			stopErr = fmt.Errorf("failed to wait for consumer to stop: %w", ctx.Err())
+		}
+		if throttle != nil {
+			consumerClient.Close()
+		}
+		if stopErr != nil {
+			return stopErr
+		}
+	}
+
+	err := producer.Flush(ctx)
+	metaClient.Close()
+	if err != nil {
+		return fmt.Errorf("failed to flush buffered records: %w", err)
+	}
+
+	return nil
+}
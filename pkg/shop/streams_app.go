@@ -0,0 +1,312 @@
+package shop
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// streamsAppMaxCustomers is the number of customers whose order counts are
+// kept in memory. If it is exceeded, the count of an arbitrary customer is
+// dropped and starts over.
+const streamsAppMaxCustomers = 100000
+
+// streamsApp simulates a Kafka Streams application that counts the orders of
+// each customer, which is the topology
+//
+//	orders.groupBy(customerID).count()
+//
+// The order service produces each placed order to the repartition topic keyed
+// by customer ID, so the repartitioned orders are consistent with the orders
+// topic. The application consumes the repartition topic in the consumer group
+// of its application ID and produces the updated count of the customer to the
+// compacted changelog topic of its count store. Keys are strings and counts
+// are big-endian longs, like the serdes of Kafka Streams. On startup, the
+// counts are restored from the changelog topic.
+type streamsApp struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+	serde  *TopicSerde
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+
+	countsMu sync.Mutex
+	counts   map[string]int64
+
+	topicNameRepartition string
+	topicNameChangelog   string
+}
+
+// newStreamsApp creates the simulated application of the order service, whose
+// meta client is used to create the internal topics and whose producer is used
+// to produce the repartitioned orders and the changelog.
+// The orders are repartitioned in the format of the given serde. It returns
+// nil if the application is disabled.
+func newStreamsApp(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	metrics *clientMetrics,
+	metaClient *kgo.Client,
+	producer recordProducer,
+	serde *TopicSerde,
+	clock *simulationClock,
+) (*streamsApp, error) {
+	if !cfg.Streams.Enabled {
+		return nil, nil
+	}
+
+	appID := cfg.Streams.ApplicationID
+	topicNameRepartition := appID + "-orders-by-customer-repartition"
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		appID+"-StreamThread-1-consumer",
+		metrics.hook(),
+		kgo.ConsumerGroup(appID),
+		kgo.ConsumeTopics(topicNameRepartition),
+		kgo.AutoCommitInterval(500*time.Millisecond),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create streams consumer client: %w", err)
+	}
+
+	return &streamsApp{
+		cfg:    cfg,
+		logger: logger.With(zap.String("streams_application_id", appID)),
+		clock:  clock,
+		serde:  serde,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Order.SlowConsumer),
+
+		counts: make(map[string]int64),
+
+		topicNameRepartition: topicNameRepartition,
+		topicNameChangelog:   appID + "-order-count-by-customer-changelog",
+	}, nil
+}
+
+// Initialize creates the repartition topic with cleanup policy delete and the
+// changelog topic with cleanup policy compact and restores the counts from the
+// changelog topic.
+func (a *streamsApp) Initialize(ctx context.Context) error {
+	err := reconcileTopic(ctx, a.cfg, a.metaClient, a.topicNameRepartition, map[string]*string{
+		"cleanup.policy": kadm.StringPtr("delete"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create repartition topic: %w", err)
+	}
+
+	err = reconcileTopic(ctx, a.cfg, a.metaClient, a.topicNameChangelog, map[string]*string{
+		"cleanup.policy": kadm.StringPtr("compact"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create changelog topic: %w", err)
+	}
+
+	if err := a.restore(ctx); err != nil {
+		return fmt.Errorf("failed to restore order counts: %w", err)
+	}
+
+	return nil
+}
+
+// restore reads the changelog topic up to its end offsets into the counts, so
+// that the counts continue where they were before a restart.
+func (a *streamsApp) restore(ctx context.Context) error {
+	endOffsets, err := kadm.NewClient(a.metaClient).ListEndOffsets(ctx, a.topicNameChangelog)
+	if err != nil {
+		return fmt.Errorf("failed to list end offsets of changelog topic: %w", err)
+	}
+	if err := endOffsets.Error(); err != nil {
+		return fmt.Errorf("failed to list end offsets of changelog topic: %w", err)
+	}
+
+	remaining := make(map[int32]int64)
+	partitions := make(map[int32]kgo.Offset)
+	endOffsets.Each(func(o kadm.ListedOffset) {
+		if o.Offset > 0 {
+			remaining[o.Partition] = o.Offset
+			partitions[o.Partition] = kgo.NewOffset().AtStart()
+		}
+	})
+	if len(remaining) == 0 {
+		return nil
+	}
+
+	restoreClient, err := a.kafkaFactory.NewKafkaClient(
+		a.cfg.Streams.ApplicationID+"-StreamThread-1-restore-consumer",
+		a.metrics.hook(),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{a.topicNameChangelog: partitions}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create restore consumer client: %w", err)
+	}
+	defer restoreClient.Close()
+
+	for len(remaining) > 0 {
+		fetches := restoreClient.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return fmt.Errorf("failed to poll changelog topic: %w", errs[0].Err)
+		}
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			a.restoreCount(rec)
+			if end, ok := remaining[rec.Partition]; ok && rec.Offset+1 >= end {
+				delete(remaining, rec.Partition)
+			}
+		})
+	}
+
+	a.logger.Info("restored order counts from changelog topic", zap.Int("customers", len(a.counts)))
+	return nil
+}
+
+// restoreCount sets the count of the customer of the given changelog record.
+// Tombstones delete the count.
+func (a *streamsApp) restoreCount(rec *kgo.Record) {
+	a.countsMu.Lock()
+	defer a.countsMu.Unlock()
+
+	customerID := string(rec.Key)
+	if rec.Value == nil {
+		delete(a.counts, customerID)
+		return
+	}
+	if len(rec.Value) != 8 {
+		return
+	}
+	if _, ok := a.counts[customerID]; !ok && len(a.counts) >= streamsAppMaxCustomers {
+		return
+	}
+	a.counts[customerID] = int64(binary.BigEndian.Uint64(rec.Value))
+}
+
+// Start consumes the repartitioned orders until the application has been
+// closed.
+func (a *streamsApp) Start() {
+	defer close(a.consumerStopped)
+
+	for {
+		fetches := a.throttle.poll(a.consumerClient)
+		if a.throttle.done(fetches) {
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			a.logger.Warn("failed to poll repartitioned orders",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeRepartitionConsumed}).Inc()
+			a.throttle.wait()
+			a.produceCount(withEventType(context.Background(), EventTypeChangelogProduced), string(rec.Key))
+		})
+	}
+}
+
+// Close stops consuming the repartitioned orders. The meta client is closed
+// by the order service.
+func (a *streamsApp) Close(ctx context.Context) error {
+	a.throttle.stop()
+	select {
+	case <-a.consumerStopped:
+	case <-ctx.Done():
+		a.consumerClient.Close()
+		return fmt.Errorf("failed to wait for streams consumer to stop: %w", ctx.Err())
+	}
+	a.consumerClient.Close()
+
+	return nil
+}
+
+// repartition produces the given order to the repartition topic, keyed by the
+// ID of the customer who placed it.
+func (a *streamsApp) repartition(ctx context.Context, order fake.Order) {
+	serialized, err := a.serde.Encode(order)
+	if err != nil {
+		a.logger.Warn("failed to serialize repartitioned order", zap.Error(err))
+		return
+	}
+
+	rec := kgo.Record{
+		Key:       []byte(order.Customer.ID),
+		Value:     serialized,
+		Timestamp: a.clock.now(),
+		Topic:     a.topicNameRepartition,
+	}
+
+	a.producer.Produce(withEventType(ctx, EventTypeRepartitionProduced), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			a.logger.Error("failed to produce repartitioned order",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeRepartitionProduced}).Inc()
+}
+
+// produceCount increments the order count of the given customer and produces
+// the new count to the changelog topic.
+func (a *streamsApp) produceCount(ctx context.Context, customerID string) {
+	a.countsMu.Lock()
+	count, ok := a.counts[customerID]
+	if !ok && len(a.counts) >= streamsAppMaxCustomers {
+		for id := range a.counts {
+			delete(a.counts, id)
+			break
+		}
+	}
+	count++
+	a.counts[customerID] = count
+	a.countsMu.Unlock()
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(count))
+	rec := kgo.Record{
+		Key:       []byte(customerID),
+		Value:     value,
+		Timestamp: a.clock.now(),
+		Topic:     a.topicNameChangelog,
+	}
+
+	a.producer.Produce(ctx, &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			a.logger.Error("failed to produce changelog record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": EventTypeChangelogProduced}).Inc()
+}
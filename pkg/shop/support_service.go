@@ -0,0 +1,346 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/fake"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// SupportService consumes the orders, payments and shipments topics and opens
+// support tickets for some of the orders. Declined payments and delayed
+// shipments are much more likely to open a ticket than orders that are
+// delivered in time, so that the tickets correlate with the other topics.
+// Each ticket is replied to a few times before it is resolved.
+type SupportService struct {
+	cfg    config.Shop
+	logger *zap.Logger
+	clock  *simulationClock
+
+	kafkaFactory    *kafka.Factory
+	metrics         *clientMetrics
+	tracer          trace.Tracer
+	metaClient      *kgo.Client
+	producer        recordProducer
+	consumerClient  *kgo.Client
+	consumerStopped chan struct{}
+	throttle        *consumerThrottle
+	offsets         *consumerOffsets
+	orderSerde      *TopicSerde
+	paymentSerde    *TopicSerde
+	shipmentSerde   *TopicSerde
+
+	// orders are the consumed orders that have not been delivered yet, so
+	// that the tickets of shipments reference the ordering customer.
+	orders *orderBook
+	// shipmentStarts are the timestamps of the label creation of the
+	// shipments that have not been delivered yet, keyed by the order id. They
+	// are only accessed by the consumer.
+	shipmentStarts map[string]time.Time
+
+	pendingTickets *followUps[pendingTicket]
+
+	topicNameOrders   string
+	topicNamePayments string
+	topicName         string
+}
+
+// pendingTicket is a support ticket that has not been resolved yet.
+type pendingTicket struct {
+	ticket fake.SupportTicket
+	// replies is the number of replies before the ticket is resolved.
+	replies int
+	// nextStep is 0 for opening the ticket, followed by the replies and the
+	// resolution.
+	nextStep int
+}
+
+// NewSupportService creates a new SupportService.
+func NewSupportService(
+	cfg config.Shop,
+	logger *zap.Logger,
+	kafkaFactory *kafka.Factory,
+	serdes *Serdes,
+	tracing *tracing,
+	clock *simulationClock,
+) (*SupportService, error) {
+	clientID := cfg.Services.Support.ClientIDFor(cfg.GlobalPrefix, "support-service")
+	metrics := newClientMetrics("support_service")
+	headers := newRecordHeaders(cfg.Headers, "support-service", tracing)
+	cloudEvents := newCloudEvents(cfg.CloudEvents, "support-service")
+
+	duplicates := newDuplicates(cfg.Duplicates, metrics, logger)
+	hooks := []kgo.Hook{cloudEvents, metrics, headers, duplicates}
+	metaClient, err := kafkaFactory.NewKafkaClient(clientID, kgo.WithHooks(hooks...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta client: %w", err)
+	}
+	duplicates.attach(metaClient)
+	producer, err := newRecordProducer(cfg, kafkaFactory, metaClient, hooks, newLateRecords(cfg.LateRecords, clock, metrics), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := newConsumerOffsets(cfg.Services.Support.Offsets, logger)
+	consumerClient, err := kafkaFactory.NewKafkaClient(
+		clientID,
+		offsets.opts(
+			metrics.hook(),
+			cloudEvents.hook(),
+			kgo.ConsumerGroup(cfg.GroupID("support-service")),
+			kgo.ConsumeTopics(cfg.TopicName("orders"), cfg.TopicName("payments"), cfg.TopicName("shipments")),
+			readCommittedOrders(),
+		)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer client: %w", err)
+	}
+
+	return &SupportService{
+		cfg:    cfg,
+		logger: logger.With(zap.String("service", "support_service")),
+		clock:  clock,
+
+		kafkaFactory:    kafkaFactory,
+		metrics:         metrics,
+		tracer:          headers.tracer,
+		metaClient:      metaClient,
+		producer:        producer,
+		consumerClient:  consumerClient,
+		consumerStopped: make(chan struct{}),
+		throttle:        newConsumerThrottle(cfg.Services.Support.SlowConsumer),
+		offsets:         offsets,
+		orderSerde:      serdes.Orders,
+		paymentSerde:    serdes.Payments,
+		shipmentSerde:   serdes.Shipments,
+
+		orders:         newOrderBook(cfg.RecentOrders),
+		shipmentStarts: make(map[string]time.Time),
+
+		pendingTickets: newFollowUps[pendingTicket](clock, logger, "support_ticket", cfg.PendingFollowUps),
+
+		topicNameOrders:   cfg.TopicName("orders"),
+		topicNamePayments: cfg.TopicName("payments"),
+		topicName:         cfg.TopicName("support-tickets"),
+	}, nil
+}
+
+// Initialize support service by reconciling the support tickets topic.
+func (svc *SupportService) Initialize(ctx context.Context) error {
+	svc.logger.Info("initializing support service")
+
+	err := reconcileTopic(ctx,
+		svc.cfg,
+		svc.metaClient,
+		svc.topicName,
+		map[string]*string{
+			"cleanup.policy": kadm.StringPtr("delete"),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile topic: %w", err)
+	}
+
+	svc.logger.Info("successfully initialized support service")
+
+	return nil
+}
+
+// Close stops consuming, flushes all buffered records and closes the Kafka
+// clients.
+func (svc *SupportService) Close(ctx context.Context) error {
+	return closeClients(ctx, svc.consumerClient, svc.consumerStopped, svc.throttle, svc.producer, svc.metaClient)
+}
+
+// Start consuming the orders, payments and shipments and open tickets for
+// some of them. Pending tickets are advanced in the background until the
+// consumer has been closed.
+func (svc *SupportService) Start() {
+	defer close(svc.consumerStopped)
+
+	quit := make(chan struct{})
+	advanceStopped := make(chan struct{})
+	go func() {
+		defer close(advanceStopped)
+		svc.pendingTickets.run(quit, svc.advanceTicket)
+	}()
+	defer func() {
+		close(quit)
+		<-advanceStopped
+	}()
+
+	for {
+		svc.offsets.commitPolled(svc.consumerClient)
+		fetches := svc.throttle.poll(svc.consumerClient)
+
+		if svc.throttle.done(fetches) {
+			svc.logger.Warn("client closed")
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			svc.metrics.fetchError(topic)
+			svc.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		fetches.EachRecord(func(rec *kgo.Record) {
+			svc.throttle.wait()
+			if rec.Value == nil {
+				return
+			}
+
+			ticket, ok := svc.ticketOf(rec)
+			if !ok {
+				return
+			}
+
+			// The processing span only covers buffering the ticket, the
+			// ticket events are produced as its children later on
+			ctx, span := continueTrace(context.Background(), svc.tracer, rec)
+			svc.pendingTickets.schedule(ctx, pendingTicket{
+				ticket:   ticket,
+				replies:  gofakeit.Number(1, svc.cfg.Support.MaxReplies),
+				nextStep: 0,
+			}, svc.clock.now())
+			span.End()
+		})
+	}
+}
+
+// ticketOf returns the support ticket that is opened due to the given record
+// of one of the consumed topics. It returns false if no ticket is opened.
+func (svc *SupportService) ticketOf(rec *kgo.Record) (fake.SupportTicket, bool) {
+	cfg := svc.cfg.Support
+
+	switch rec.Topic {
+	case svc.topicNameOrders:
+		kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeOrderConsumed}).Inc()
+		order := fake.Order{}
+		if err := svc.orderSerde.Decode(rec.Value, &order); err != nil {
+			// Skip message
+			svc.logger.Warn("failed to deserialize order", zap.Error(err))
+			return fake.SupportTicket{}, false
+		}
+		svc.orders.put(order)
+		return fake.SupportTicket{}, false
+	case svc.topicNamePayments:
+		kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypePaymentConsumed}).Inc()
+		event := fake.PaymentEvent{}
+		if err := svc.paymentSerde.Decode(rec.Value, &event); err != nil {
+			// Skip message
+			svc.logger.Warn("failed to deserialize payment event", zap.Error(err))
+			return fake.SupportTicket{}, false
+		}
+		if event.Type != fake.PaymentEventTypeDeclined || rand.Float64() >= cfg.DeclinedPaymentRatio {
+			return fake.SupportTicket{}, false
+		}
+		return fake.NewSupportTicket(event.OrderID, event.CustomerID, fake.SupportTicketCategoryPaymentDeclined), true
+	}
+
+	kafkaMessagesConsumedTotal.With(map[string]string{"event_type": EventTypeShipmentConsumed}).Inc()
+	event := fake.ShipmentEvent{}
+	if err := svc.shipmentSerde.Decode(rec.Value, &event); err != nil {
+		// Skip message
+		svc.logger.Warn("failed to deserialize shipment event", zap.Error(err))
+		return fake.SupportTicket{}, false
+	}
+	orderID := event.Shipment.OrderID
+	switch event.Type {
+	case fake.ShipmentEventTypeLabelCreated:
+		if len(svc.shipmentStarts) >= svc.orders.maxSize {
+			for id := range svc.shipmentStarts {
+				delete(svc.shipmentStarts, id)
+				break
+			}
+		}
+		svc.shipmentStarts[orderID] = rec.Timestamp
+		return fake.SupportTicket{}, false
+	case fake.ShipmentEventTypeDelivered:
+	default:
+		return fake.SupportTicket{}, false
+	}
+
+	startedAt, started := svc.shipmentStarts[orderID]
+	delete(svc.shipmentStarts, orderID)
+	// Deliveries of orders that haven't been consumed don't open tickets
+	order, ok := svc.orders.remove(orderID)
+	if !ok {
+		return fake.SupportTicket{}, false
+	}
+
+	if started && rec.Timestamp.Sub(startedAt) > cfg.DelayedShipmentAfter {
+		if rand.Float64() >= cfg.DelayedShipmentRatio {
+			return fake.SupportTicket{}, false
+		}
+		return fake.NewSupportTicket(orderID, order.Customer.ID, fake.SupportTicketCategoryShippingDelay), true
+	}
+	if rand.Float64() >= cfg.BaseRatio {
+		return fake.SupportTicket{}, false
+	}
+	return fake.NewSupportTicket(orderID, order.Customer.ID, fake.SupportTicketCategoryProductQuestion), true
+}
+
+// advanceTicket produces the next event of the given pending ticket, which is
+// due. Tickets that have not been resolved yet are scheduled again.
+func (svc *SupportService) advanceTicket(ctx context.Context, pending pendingTicket, now time.Time) {
+	eventType := fake.SupportTicketEventTypeReplied
+	switch pending.nextStep {
+	case 0:
+		eventType = fake.SupportTicketEventTypeOpened
+	case pending.replies + 1:
+		eventType = fake.SupportTicketEventTypeResolved
+	}
+	event := fake.NewSupportTicketEvent(pending.ticket, eventType, pending.nextStep-1, now)
+	if err := svc.produceTicketEvent(ctx, event); err != nil {
+		svc.logger.Warn("failed to produce support ticket event", zap.Error(err))
+	}
+
+	pending.nextStep++
+	if eventType != fake.SupportTicketEventTypeResolved {
+		dueAt := now.Add(randomDelay(svc.cfg.Support.MinReplyDelay, svc.cfg.Support.MaxReplyDelay))
+		svc.pendingTickets.schedule(ctx, pending, dueAt)
+	}
+}
+
+func (svc *SupportService) produceTicketEvent(ctx context.Context, event fake.SupportTicketEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize support ticket event struct: %w", err)
+	}
+
+	rec := kgo.Record{
+		Key:       recordKey(svc.cfg, svc.topicName, event.Ticket.ID, event.Ticket.CustomerID),
+		Value:     serialized,
+		Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.Type)}},
+		Timestamp: svc.clock.now(),
+		Topic:     svc.topicName,
+	}
+
+	eventType := supportTicketEventTypeMetricLabels[event.Type]
+	svc.producer.Produce(withEventType(ctx, eventType), &rec, func(rec *kgo.Record, err error) {
+		if err != nil {
+			svc.logger.Error("failed to produce record",
+				zap.String("topic_name", rec.Topic),
+				zap.Error(err),
+			)
+			return
+		}
+	})
+	kafkaMessagesProducedTotal.With(map[string]string{"event_type": eventType}).Inc()
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package shop
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// reconcileTopic ensures that the given topic exists. The topic is created with
+// the shop's partition count and replication factor and the given configs,
+// unless they are overridden in the topic's config.
+func reconcileTopic(
+	ctx context.Context,
+	cfg config.Shop,
+	kafkaClient *kgo.Client,
+	topicName string,
+	configs map[string]*string,
+) error {
+	override := topicOverride(cfg, topicName)
+
+	partitions := cfg.TopicPartitionCount
+	if override.PartitionCount != 0 {
+		partitions = override.PartitionCount
+	}
+	replicationFactor := cfg.TopicReplicationFactor
+	if override.ReplicationFactor != 0 {
+		replicationFactor = override.ReplicationFactor
+	}
+
+	// Copy the configs, because they may be shared by multiple topics
+	topicConfigs := make(map[string]*string, len(configs))
+	for key, value := range configs {
+		topicConfigs[key] = value
+	}
+	if override.RetentionMs != 0 {
+		topicConfigs["retention.ms"] = kadm.StringPtr(strconv.FormatInt(override.RetentionMs, 10))
+	}
+	if override.RetentionBytes != 0 {
+		topicConfigs["retention.bytes"] = kadm.StringPtr(strconv.FormatInt(override.RetentionBytes, 10))
+	}
+	if override.CleanupPolicy != "" {
+		topicConfigs["cleanup.policy"] = kadm.StringPtr(override.CleanupPolicy)
+	}
+
+	return kafka.ReconcileTopic(ctx, kafkaClient, topicName, partitions, replicationFactor, topicConfigs)
+}
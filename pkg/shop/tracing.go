@@ -0,0 +1,164 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// tracerName is the instrumentation name of all tracers.
+const tracerName = "github.com/cloudhut/owl-shop/pkg/shop"
+
+// traceContextPropagator propagates the trace context via the traceparent
+// record header.
+var traceContextPropagator = propagation.TraceContext{}
+
+// tracing provides a tracer per service, so that each service shows up as a
+// service of its own in the tracing backend. All tracer providers share one
+// OTLP exporter. If tracing is disabled, spans are still created so that the
+// trace context can be propagated via record headers, but they are never
+// exported.
+type tracing struct {
+	globalPrefix string
+	sampler      sdktrace.Sampler
+	// exporter is nil if tracing is disabled.
+	exporter  sdktrace.SpanExporter
+	providers []*sdktrace.TracerProvider
+}
+
+func newTracing(cfg config.Config) (*tracing, error) {
+	t := &tracing{
+		globalPrefix: cfg.Shop.GlobalPrefix,
+		sampler:      sdktrace.AlwaysSample(),
+	}
+	if !cfg.Tracing.Enabled {
+		return t, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Tracing.Endpoint)}
+	if cfg.Tracing.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Tracing.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Tracing.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+	}
+
+	t.exporter = exporter
+	t.sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))
+
+	return t, nil
+}
+
+// tracer returns a tracer whose spans belong to the given service.
+func (t *tracing) tracer(service string) trace.Tracer {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(t.sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(t.globalPrefix+service),
+		)),
+	}
+	if t.exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(sharedExporter{t.exporter}))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	t.providers = append(t.providers, provider)
+
+	return provider.Tracer(tracerName)
+}
+
+// shutdown exports all remaining spans and shuts down the exporter.
+func (t *tracing) shutdown(ctx context.Context) error {
+	for _, provider := range t.providers {
+		if err := provider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown tracer provider: %w", err)
+		}
+	}
+	if t.exporter == nil {
+		return nil
+	}
+	if err := t.exporter.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown otlp exporter: %w", err)
+	}
+
+	return nil
+}
+
+// sharedExporter prevents the tracer providers from shutting down the shared
+// exporter, which is shut down once all providers have been shut down.
+type sharedExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (sharedExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// startTrace starts the root span of a new trace, which all records that are
+// produced with the returned context belong to. The span must be ended by the
+// caller.
+func startTrace(ctx context.Context, tracer trace.Tracer, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithNewRoot())
+}
+
+// continueTrace starts a span for processing the given consumed record, which
+// continues the trace that is propagated via the record's traceparent header.
+// Records that are produced with the returned context belong to the same
+// trace. If the record carries no trace context, a new trace is started. The
+// span must be ended by the caller.
+func continueTrace(ctx context.Context, tracer trace.Tracer, rec *kgo.Record) (context.Context, trace.Span) {
+	ctx = traceContextPropagator.Extract(ctx, recordCarrier{rec})
+
+	return tracer.Start(ctx, rec.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingSourceName(rec.Topic),
+			semconv.MessagingKafkaSourcePartition(int(rec.Partition)),
+			semconv.MessagingKafkaMessageOffset(int(rec.Offset)),
+		),
+	)
+}
+
+// recordCarrier adapts the headers of a record to a
+// propagation.TextMapCarrier. Headers that the record carries already are not
+// overwritten.
+type recordCarrier struct {
+	rec *kgo.Record
+}
+
+func (c recordCarrier) Get(key string) string {
+	for _, header := range c.rec.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c recordCarrier) Set(key string, value string) {
+	setHeaderIfAbsent(c.rec, key, value)
+}
+
+func (c recordCarrier) Keys() []string {
+	keys := make([]string, len(c.rec.Headers))
+	for i, header := range c.rec.Headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
@@ -0,0 +1,467 @@
+package shop
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mroth/weightedrand"
+	"golang.org/x/time/rate"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// trafficEvent is an action that can be randomly triggered by a simulated page
+// impression, such as a customer registration or an order.
+type trafficEvent struct {
+	name   string
+	fn     func()
+	weight uint
+	// service is the yaml key of the service that the event belongs to, e.g.
+	// frontend. It is empty for custom generators.
+	service string
+}
+
+// TrafficSettings describes the current traffic simulation of the shop.
+type TrafficSettings struct {
+	EventsPerSecond float64         `json:"eventsPerSecond"`
+	Burst           int             `json:"burst"`
+	Paused          bool            `json:"paused"`
+	EventWeights    map[string]uint `json:"eventWeights"`
+
+	// Pattern is the configured load shape and CurrentEventsPerSecond is the
+	// rate after the pattern and the time acceleration have been applied.
+	Pattern                string  `json:"pattern"`
+	CurrentEventsPerSecond float64 `json:"currentEventsPerSecond"`
+
+	// ServiceEventsPerSecond are the rates of the services whose events are
+	// triggered independently of the weighted page impressions.
+	ServiceEventsPerSecond map[string]float64 `json:"serviceEventsPerSecond,omitempty"`
+
+	// Boosts are the factors by which the active sales multiply the traffic
+	// of services, keyed by the services' yaml keys.
+	Boosts map[string]float64 `json:"boosts,omitempty"`
+}
+
+// pausedPollInterval is the interval in which a paused traffic simulation
+// checks whether it has been resumed.
+const pausedPollInterval = 100 * time.Millisecond
+
+// trafficController holds the traffic settings that can be changed while the
+// shop is running. All methods are safe for concurrent use.
+type trafficController struct {
+	mu sync.RWMutex
+
+	eventsPerSecond float64
+	burst           int
+	paused          bool
+	limiter         *rate.Limiter
+
+	patternName string
+	pattern     trafficPattern
+	clock       *simulationClock
+	startedAt   time.Time
+
+	events []trafficEvent
+	// chooser picks the events of all services without a rate of their own.
+	// It is nil if every event belongs to such a service.
+	chooser  *weightedrand.Chooser
+	services []*serviceTraffic
+
+	// boosts are the factors by which the traffic of services is multiplied,
+	// see setBoosts. weightedBoost is the resulting factor of the rate of the
+	// weighted page impressions.
+	boosts        map[string]float64
+	weightedBoost float64
+}
+
+// serviceTraffic triggers the events of a service that has a rate of its own,
+// independently of the weighted page impressions.
+type serviceTraffic struct {
+	name            string
+	eventsPerSecond float64
+	limiter         *rate.Limiter
+	// chooser picks the service's events by their weights.
+	chooser *weightedrand.Chooser
+}
+
+func newTrafficController(cfg config.Shop, events []trafficEvent, clock *simulationClock) (*trafficController, error) {
+	rates := serviceRates(cfg.Services)
+	chooser, err := newEventChooser(events, func(event trafficEvent) bool { return rates[event.service] == 0 })
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(rates))
+	for name, eventsPerSecond := range rates {
+		if eventsPerSecond > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	services := make([]*serviceTraffic, 0, len(names))
+	for _, name := range names {
+		name := name
+		serviceChooser, err := newEventChooser(events, func(event trafficEvent) bool { return event.service == name })
+		if err != nil {
+			return nil, err
+		}
+		if serviceChooser == nil {
+			return nil, fmt.Errorf("the %v service has a rate of its own, but none of its events has a weight", name)
+		}
+		services = append(services, &serviceTraffic{
+			name:            name,
+			eventsPerSecond: rates[name],
+			limiter:         rate.NewLimiter(rate.Limit(rates[name]), burstSize(rates[name], 0)),
+			chooser:         serviceChooser,
+		})
+	}
+
+	pattern, err := newTrafficPattern(cfg.Traffic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &trafficController{
+		eventsPerSecond: cfg.EventsPerSecond,
+		burst:           cfg.Burst,
+		limiter:         rate.NewLimiter(rate.Limit(cfg.EventsPerSecond), burstSize(cfg.EventsPerSecond, cfg.Burst)),
+		patternName:     cfg.Traffic.Pattern,
+		pattern:         pattern,
+		clock:           clock,
+		startedAt:       clock.now(),
+		events:          events,
+		chooser:         chooser,
+		services:        services,
+		weightedBoost:   1,
+	}, nil
+}
+
+// serviceRates returns the rates of the services that can trigger events,
+// keyed by their yaml key. A rate of zero means that the service's events are
+// part of the weighted page impressions.
+func serviceRates(services config.Services) map[string]float64 {
+	return map[string]float64{
+		"customer":       services.Customer.EventsPerSecond,
+		"address":        services.Address.EventsPerSecond,
+		"frontend":       services.Frontend.EventsPerSecond,
+		"order":          services.Order.EventsPerSecond,
+		"productCatalog": services.ProductCatalog.EventsPerSecond,
+		"inventory":      services.Inventory.EventsPerSecond,
+		"review":         services.Review.EventsPerSecond,
+		"cart":           services.Cart.EventsPerSecond,
+	}
+}
+
+// newEventChooser returns a chooser that picks one of the included events by
+// their weights. It returns nil if none of the included events has a weight.
+func newEventChooser(events []trafficEvent, include func(trafficEvent) bool) (*weightedrand.Chooser, error) {
+	choices := make([]weightedrand.Choice, 0, len(events))
+	for _, event := range events {
+		if include(event) && event.weight > 0 {
+			choices = append(choices, weightedrand.Choice{Item: event.fn, Weight: event.weight})
+		}
+	}
+	if len(choices) == 0 {
+		return nil, nil
+	}
+	chooser, err := weightedrand.NewChooser(choices...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create random chooser: %w", err)
+	}
+
+	return chooser, nil
+}
+
+// burstSize returns the configured burst or, if it is zero, a tenth of a
+// second's worth of events, but at least one.
+func burstSize(eventsPerSecond float64, burst int) int {
+	if burst > 0 {
+		return burst
+	}
+	return int(math.Max(1, math.Ceil(eventsPerSecond/10)))
+}
+
+// wait blocks until the next page impression shall be simulated or the given
+// context is cancelled. The rate is scaled by the traffic pattern before each
+// page impression and no page impressions are simulated while the simulation
+// is paused, the scaled rate is zero or all events belong to services with a
+// rate of their own.
+func (t *trafficController) wait(ctx context.Context) error {
+	return t.waitFor(ctx, t.limiter, func() (float64, bool) {
+		return t.eventsPerSecond * t.weightedBoost, t.chooser != nil
+	})
+}
+
+// waitService blocks until the next event of the given service shall be
+// triggered or the given context is cancelled, like wait.
+func (t *trafficController) waitService(ctx context.Context, svc *serviceTraffic) error {
+	return t.waitFor(ctx, svc.limiter, func() (float64, bool) {
+		return svc.eventsPerSecond * t.boost(svc.name), true
+	})
+}
+
+// waitFor waits for the given limiter, whose rate is set to the scaled rate
+// that is returned by the given func. The func is called with the lock held
+// and returns false if no events can be picked.
+func (t *trafficController) waitFor(ctx context.Context, limiter *rate.Limiter, eventsPerSecond func() (float64, bool)) error {
+	for {
+		t.mu.RLock()
+		paused := t.paused
+		base, pickable := eventsPerSecond()
+		limit := rate.Limit(t.scaledRate(base))
+		t.mu.RUnlock()
+
+		if !paused && pickable && limit > 0 {
+			if limiter.Limit() != limit {
+				limiter.SetLimit(limit)
+			}
+			return limiter.Wait(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pausedPollInterval):
+		}
+	}
+}
+
+// currentRate returns the events per second of the wall clock after applying
+// the boosts, the traffic pattern and the time acceleration. The caller must
+// hold the lock.
+func (t *trafficController) currentRate() float64 {
+	return t.scaledRate(t.eventsPerSecond * t.weightedBoost)
+}
+
+// scaledRate applies the traffic pattern and the time acceleration to the
+// given events per second. The pattern is applied to the simulated time.
+func (t *trafficController) scaledRate(eventsPerSecond float64) float64 {
+	now := t.clock.now()
+	elapsed := now.Sub(t.startedAt)
+	if elapsed < 0 {
+		// A backfill is in progress
+		elapsed = 0
+	}
+	return eventsPerSecond * t.clock.acceleration * t.pattern.multiplier(now, elapsed)
+}
+
+// pick returns a random event func in accordance with the event weights. The
+// events of services with a rate of their own are not picked.
+func (t *trafficController) pick() func() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.chooser == nil {
+		return func() {}
+	}
+	return t.chooser.Pick().(func())
+}
+
+// pickService returns a random event func of the given service in accordance
+// with the event weights.
+func (t *trafficController) pickService(svc *serviceTraffic) func() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return svc.chooser.Pick().(func())
+}
+
+// eventFunc returns the func of the event with the given name.
+func (t *trafficController) eventFunc(name string) (func(), error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, event := range t.events {
+		if event.name == name {
+			return event.fn, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown event '%v', valid events are: %v", name, eventNames(t.events))
+}
+
+func (t *trafficController) settings() TrafficSettings {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	weights := make(map[string]uint, len(t.events))
+	for _, event := range t.events {
+		weights[event.name] = event.weight
+	}
+	var serviceRates map[string]float64
+	if len(t.services) > 0 {
+		serviceRates = make(map[string]float64, len(t.services))
+		for _, svc := range t.services {
+			serviceRates[svc.name] = svc.eventsPerSecond
+		}
+	}
+
+	return TrafficSettings{
+		EventsPerSecond:        t.eventsPerSecond,
+		Burst:                  t.burst,
+		Paused:                 t.paused,
+		EventWeights:           weights,
+		Pattern:                t.patternName,
+		CurrentEventsPerSecond: t.currentRate(),
+		ServiceEventsPerSecond: serviceRates,
+		Boosts:                 t.boosts,
+	}
+}
+
+// setRate changes the events per second and the burst. A zero burst is
+// derived from the events per second, see config.Shop.
+func (t *trafficController) setRate(eventsPerSecond float64, burst int) error {
+	if eventsPerSecond <= 0 {
+		return fmt.Errorf("events per second must be a positive number")
+	}
+	if burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.eventsPerSecond = eventsPerSecond
+	t.burst = burst
+	t.limiter.SetBurst(burstSize(eventsPerSecond, burst))
+
+	return nil
+}
+
+func (t *trafficController) setPaused(paused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.paused = paused
+}
+
+// setWeights changes the weights of the given events. Events that are not part
+// of the given weights keep their current weight.
+func (t *trafficController) setWeights(weights map[string]uint) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]trafficEvent, len(t.events))
+	copy(events, t.events)
+
+	for name, weight := range weights {
+		found := false
+		for i := range events {
+			if events[i].name == name {
+				events[i].weight = weight
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown event '%v', valid events are: %v", name, eventNames(events))
+		}
+	}
+
+	return t.setEvents(events, t.boosts)
+}
+
+// setBoosts multiplies the traffic of the given services by their factors,
+// keyed by the services' yaml keys. The events of a boosted service are
+// picked more often by the weighted page impressions and their rate is
+// increased accordingly, so that the traffic of the other services stays
+// the same. The rate of a service with a rate of its own is multiplied
+// instead. Services that are not part of the given boosts return to their
+// baseline.
+func (t *trafficController) setBoosts(boosts map[string]float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var copied map[string]float64
+	for service, factor := range boosts {
+		if factor <= 0 {
+			return fmt.Errorf("boost of the %v service must be a positive number", service)
+		}
+		if copied == nil {
+			copied = make(map[string]float64, len(boosts))
+		}
+		copied[service] = factor
+	}
+
+	return t.setEvents(t.events, copied)
+}
+
+// setEvents replaces the events and the boosts and recreates the choosers of
+// the boosted events. The caller must hold the lock.
+func (t *trafficController) setEvents(events []trafficEvent, boosts map[string]float64) error {
+	boosted := make([]trafficEvent, len(events))
+	var weights, boostedWeights float64
+	for i, event := range events {
+		boosted[i] = event
+		factor, ok := boosts[event.service]
+		if ok && event.weight > 0 {
+			boosted[i].weight = uint(math.Max(1, math.Round(float64(event.weight)*factor)))
+		}
+		if !t.hasOwnRate(event.service) {
+			weights += float64(event.weight)
+			boostedWeights += float64(boosted[i].weight)
+		}
+	}
+
+	chooser, err := newEventChooser(boosted, func(event trafficEvent) bool { return !t.hasOwnRate(event.service) })
+	if err != nil {
+		return err
+	}
+	serviceChoosers := make([]*weightedrand.Chooser, len(t.services))
+	for i, svc := range t.services {
+		serviceChoosers[i], err = newEventChooser(boosted, func(event trafficEvent) bool { return event.service == svc.name })
+		if err != nil {
+			return err
+		}
+		if serviceChoosers[i] == nil {
+			return fmt.Errorf("the %v service has a rate of its own, so at least one of its events must keep a weight", svc.name)
+		}
+	}
+	t.events = events
+	t.chooser = chooser
+	for i, svc := range t.services {
+		svc.chooser = serviceChoosers[i]
+	}
+	t.boosts = boosts
+	t.weightedBoost = 1
+	if weights > 0 {
+		t.weightedBoost = boostedWeights / weights
+	}
+
+	return nil
+}
+
+// boost returns the factor by which the traffic of the given service is
+// multiplied. The caller must hold the lock.
+func (t *trafficController) boost(service string) float64 {
+	if factor, ok := t.boosts[service]; ok {
+		return factor
+	}
+	return 1
+}
+
+// hasOwnRate returns true if the given service triggers its events
+// independently of the weighted page impressions. The caller must hold the
+// lock.
+func (t *trafficController) hasOwnRate(service string) bool {
+	for _, svc := range t.services {
+		if svc.name == service {
+			return true
+		}
+	}
+	return false
+}
+
+func eventNames(events []trafficEvent) []string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.name
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,135 @@
+package shop
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// trafficPattern shapes the simulated load over time. It returns the factor by
+// which the configured request rate is multiplied at the given simulated time,
+// which is the given duration after the traffic simulation has been started.
+type trafficPattern interface {
+	multiplier(now time.Time, elapsed time.Duration) float64
+}
+
+func newTrafficPattern(cfg config.Traffic) (trafficPattern, error) {
+	switch cfg.Pattern {
+	case config.TrafficPatternConstant:
+		return constantPattern{}, nil
+	case config.TrafficPatternSinusoidal:
+		return sinusoidalPattern{cfg: cfg.Sinusoidal}, nil
+	case config.TrafficPatternSpikes:
+		return spikesPattern{cfg: cfg.Spikes}, nil
+	case config.TrafficPatternRamp:
+		return rampPattern{cfg: cfg.Ramp}, nil
+	case config.TrafficPatternDiurnal:
+		return newDiurnalPattern(cfg.Diurnal)
+	default:
+		return nil, fmt.Errorf("unknown traffic pattern '%v'", cfg.Pattern)
+	}
+}
+
+// constantPattern keeps the configured request rate.
+type constantPattern struct{}
+
+func (constantPattern) multiplier(time.Time, time.Duration) float64 {
+	return 1
+}
+
+// sinusoidalPattern oscillates between (1 - amplitude) and (1 + amplitude).
+type sinusoidalPattern struct {
+	cfg config.SinusoidalTraffic
+}
+
+func (p sinusoidalPattern) multiplier(_ time.Time, elapsed time.Duration) float64 {
+	phase := 2 * math.Pi * float64(elapsed) / float64(p.cfg.Period)
+	return 1 + p.cfg.Amplitude*math.Sin(phase)
+}
+
+// spikesPattern multiplies the request rate by the spike factor at the start
+// of each interval.
+type spikesPattern struct {
+	cfg config.SpikesTraffic
+}
+
+func (p spikesPattern) multiplier(_ time.Time, elapsed time.Duration) float64 {
+	if elapsed%p.cfg.Interval < p.cfg.Duration {
+		return p.cfg.Factor
+	}
+	return 1
+}
+
+// rampPattern linearly ramps up to the configured request rate, holds it and
+// ramps down to zero again. The cycle repeats afterwards.
+type rampPattern struct {
+	cfg config.RampTraffic
+}
+
+func (p rampPattern) multiplier(_ time.Time, elapsed time.Duration) float64 {
+	cycle := p.cfg.RampUp + p.cfg.Hold + p.cfg.RampDown
+	pos := elapsed % cycle
+
+	switch {
+	case pos < p.cfg.RampUp:
+		return float64(pos) / float64(p.cfg.RampUp)
+	case pos < p.cfg.RampUp+p.cfg.Hold:
+		return 1
+	default:
+		return 1 - float64(pos-p.cfg.RampUp-p.cfg.Hold)/float64(p.cfg.RampDown)
+	}
+}
+
+// defaultHourlyWeights is the relative load of an e-commerce shop for each hour
+// of the day, starting at midnight. It is lowest at 4am and peaks at lunch and
+// in the evening.
+var defaultHourlyWeights = []float64{
+	0.45, 0.32, 0.24, 0.2, 0.18, 0.22, 0.35, 0.55, 0.75, 0.9, 1.0, 1.15,
+	1.4, 1.35, 1.1, 1.0, 1.0, 1.1, 1.3, 1.55, 1.7, 1.6, 1.2, 0.75,
+}
+
+// diurnalPattern follows the hourly weights in the configured time zone, which
+// are normalized so that the average of a weekday equals the configured
+// request rate. Weekends are boosted on top.
+type diurnalPattern struct {
+	location     *time.Location
+	weights      []float64
+	weekendBoost float64
+}
+
+func newDiurnalPattern(cfg config.DiurnalTraffic) (diurnalPattern, error) {
+	location, err := cfg.Location()
+	if err != nil {
+		return diurnalPattern{}, err
+	}
+
+	weights := cfg.HourlyWeights
+	if len(weights) == 0 {
+		weights = defaultHourlyWeights
+	}
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	normalized := make([]float64, len(weights))
+	for i, weight := range weights {
+		normalized[i] = weight * float64(len(weights)) / total
+	}
+
+	return diurnalPattern{location: location, weights: normalized, weekendBoost: cfg.WeekendBoost}, nil
+}
+
+func (p diurnalPattern) multiplier(now time.Time, _ time.Duration) float64 {
+	local := now.In(p.location)
+	hour := local.Hour()
+	fraction := (float64(local.Minute()) + float64(local.Second())/60) / 60
+	next := p.weights[(hour+1)%len(p.weights)]
+	multiplier := p.weights[hour] + (next-p.weights[hour])*fraction
+
+	if weekday := local.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		multiplier *= p.weekendBoost
+	}
+	return multiplier
+}
@@ -0,0 +1,230 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/kafka"
+)
+
+// verifier consumes all topics of the shop from their end and verifies the
+// consumed records of each partition. It measures the end-to-end latency from
+// the record timestamp until the record has been consumed and counts offset
+// gaps and ordering violations. It does not join a consumer group, so each
+// instance of the shop verifies all partitions.
+//
+// Offset gaps are expected for transactional and compacted topics, because
+// of control records and removed records. The latency is only meaningful if
+// the record timestamps are the produce times, i.e. without a backfill, late
+// records or time acceleration.
+//
+// Records with sequence numbers, see sequenceNumbers, are verified per
+// producer, topic and key to detect lost, duplicated and reordered records.
+type verifier struct {
+	logger *zap.Logger
+	client *kgo.Client
+	// ctx is canceled by Close to interrupt the poll of the consume loop.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// stopped is closed once the consume loop has returned.
+	stopped chan struct{}
+
+	partitions map[verifiedPartition]verifiedRecord
+
+	// sequences are the highest sequence numbers of each producer, topic
+	// and key.
+	sequences        *keyLRU[uint64]
+	failOnViolations bool
+	violations       integrityViolations
+}
+
+// ErrIntegrityViolations is returned by Stop if the verifier has detected
+// lost, duplicated or reordered records and the shop is configured to fail
+// on integrity violations.
+var ErrIntegrityViolations = errors.New("integrity violations detected")
+
+// integrityViolations are the violations that have been detected by the
+// verifier. Lost records are counted by the gaps in the sequence numbers, so
+// a reordered record may be counted as lost as well.
+type integrityViolations struct {
+	lost       uint64
+	duplicated uint64
+	reordered  uint64
+}
+
+type verifiedPartition struct {
+	topic     string
+	partition int32
+}
+
+// verifiedRecord is the last verified record of a partition.
+type verifiedRecord struct {
+	offset    int64
+	timestamp time.Time
+}
+
+// newVerifier creates the verifier or returns nil if it is disabled.
+func newVerifier(cfg config.Shop, logger *zap.Logger, kafkaFactory *kafka.Factory) (*verifier, error) {
+	if !cfg.Verifier.Enabled {
+		return nil, nil
+	}
+
+	topicRegex := cfg.Verifier.TopicRegex
+	if topicRegex == "" {
+		topicRegex = "^" + regexp.QuoteMeta(cfg.TopicNamePrefix())
+	}
+	client, err := kafkaFactory.NewKafkaClient(
+		cfg.GlobalPrefix+"verifier",
+		newClientMetrics("verifier").hook(),
+		kgo.ConsumeTopics(topicRegex),
+		kgo.ConsumeRegex(),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()),
+		kgo.MetadataMaxAge(30*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &verifier{
+		logger:     logger.With(zap.String("service", "verifier")),
+		client:     client,
+		ctx:        ctx,
+		cancel:     cancel,
+		stopped:    make(chan struct{}),
+		partitions: make(map[verifiedPartition]verifiedRecord),
+
+		sequences:        newKeyLRU[uint64](cfg.Integrity.MaxKeys),
+		failOnViolations: cfg.Integrity.FailOnViolations,
+	}, nil
+}
+
+// Start consumes and verifies the records until Close is called.
+func (v *verifier) Start() {
+	defer close(v.stopped)
+
+	for {
+		fetches := v.client.PollFetches(v.ctx)
+		if fetches.IsClientClosed() || v.ctx.Err() != nil {
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			v.logger.Error("failed to poll fetches",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Error(err))
+		})
+
+		now := time.Now()
+		fetches.EachRecord(func(rec *kgo.Record) {
+			v.verify(rec, now)
+		})
+	}
+}
+
+// verify records the latency of the record and compares it with the previous
+// record of its partition.
+func (v *verifier) verify(rec *kgo.Record, consumedAt time.Time) {
+	verifierRecordsConsumedTotal.With(map[string]string{"topic": rec.Topic}).Inc()
+
+	latency := consumedAt.Sub(rec.Timestamp)
+	if latency < 0 {
+		latency = 0
+	}
+	verifierEndToEndLatencySeconds.With(map[string]string{"topic": rec.Topic}).Observe(latency.Seconds())
+
+	v.verifySequence(rec)
+
+	key := verifiedPartition{topic: rec.Topic, partition: rec.Partition}
+	previous, ok := v.partitions[key]
+	v.partitions[key] = verifiedRecord{offset: rec.Offset, timestamp: rec.Timestamp}
+	if !ok {
+		return
+	}
+
+	switch {
+	case rec.Offset <= previous.offset:
+		verifierOrderingViolationsTotal.With(map[string]string{"topic": rec.Topic, "kind": "offset"}).Inc()
+		v.logger.Debug("consumed record with non-increasing offset",
+			zap.String("topic", rec.Topic),
+			zap.Int32("partition", rec.Partition),
+			zap.Int64("offset", rec.Offset),
+			zap.Int64("previous_offset", previous.offset))
+	case rec.Offset > previous.offset+1:
+		verifierOffsetGapsTotal.With(map[string]string{"topic": rec.Topic}).Inc()
+	}
+
+	if rec.Timestamp.Before(previous.timestamp) {
+		verifierOrderingViolationsTotal.With(map[string]string{"topic": rec.Topic, "kind": "timestamp"}).Inc()
+	}
+}
+
+// verifySequence compares the sequence number of the record, if any, with
+// the highest one of its producer, topic and key. The first sequence number
+// of a key is the baseline of the following ones. Producers use a new
+// producer id once they have evicted a key, so a sequence number that
+// restarts at 1 is a violation.
+func (v *verifier) verifySequence(rec *kgo.Record) {
+	producerID, sequence, ok := recordSequence(rec)
+	if !ok {
+		return
+	}
+
+	key := producerID + "/" + string(rec.Key)
+	last, _ := v.sequences.get(rec.Topic, key)
+	if sequence > last {
+		v.sequences.put(rec.Topic, key, sequence)
+	}
+
+	switch {
+	case last == 0 || sequence == last+1:
+		return
+	case sequence > last+1:
+		lost := sequence - last - 1
+		v.violations.lost += lost
+		verifierIntegrityViolationsTotal.With(map[string]string{"topic": rec.Topic, "kind": "lost"}).Add(float64(lost))
+	case sequence == last:
+		v.violations.duplicated++
+		verifierIntegrityViolationsTotal.With(map[string]string{"topic": rec.Topic, "kind": "duplicated"}).Inc()
+	default:
+		v.violations.reordered++
+		verifierIntegrityViolationsTotal.With(map[string]string{"topic": rec.Topic, "kind": "reordered"}).Inc()
+	}
+	v.logger.Debug("detected integrity violation",
+		zap.String("topic", rec.Topic),
+		zap.String("key", string(rec.Key)),
+		zap.Uint64("sequence", sequence),
+		zap.Uint64("last_sequence", last))
+}
+
+// Close stops the consume loop, waits for it to return and closes the
+// client. It returns ErrIntegrityViolations if any violations have been
+// detected and the verifier shall fail on them.
+func (v *verifier) Close() error {
+	v.cancel()
+	<-v.stopped
+	v.client.Close()
+
+	violations := v.violations
+	if violations == (integrityViolations{}) {
+		return nil
+	}
+	v.logger.Warn("detected integrity violations",
+		zap.Uint64("lost", violations.lost),
+		zap.Uint64("duplicated", violations.duplicated),
+		zap.Uint64("reordered", violations.reordered))
+	if !v.failOnViolations {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d lost, %d duplicated and %d reordered records",
+		ErrIntegrityViolations, violations.lost, violations.duplicated, violations.reordered)
+}
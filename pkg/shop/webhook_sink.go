@@ -0,0 +1,269 @@
+package shop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// webhookSink POSTs all records that have been acknowledged by Kafka to the
+// configured webhook endpoint. Records are buffered and sent in batches by a
+// single background goroutine, which retries failed requests with an
+// exponential backoff. If the buffer is full, records are dropped rather than
+// blocking the producing service. Records of transactions are only sent once
+// their transaction has been committed.
+type webhookSink struct {
+	cfg    config.Webhook
+	logger *zap.Logger
+	client *http.Client
+
+	events chan webhookEvent
+	// quit is closed by shutdown, after which the buffered events are sent
+	// and stopped is closed.
+	quit    chan struct{}
+	stopped chan struct{}
+}
+
+var _ kgo.HookProduceRecordUnbuffered = (*webhookSink)(nil)
+
+// webhookEvent is a produced record in the request body. JSON values are
+// embedded as they are, all other values are base64 encoded.
+type webhookEvent struct {
+	Topic       string          `json:"topic"`
+	Partition   int32           `json:"partition"`
+	Offset      int64           `json:"offset"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Key         string          `json:"key,omitempty"`
+	Headers     webhookHeaders  `json:"headers,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	ValueBase64 []byte          `json:"value_base64,omitempty"`
+	Tombstone   bool            `json:"tombstone,omitempty"`
+}
+
+// webhookHeaders are the record headers of an event in the order of the
+// record, including repeated keys.
+type webhookHeaders []webhookHeader
+
+type webhookHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// UnmarshalJSON also accepts the headers object of events that have been
+// written by previous versions, which kept a single value per key.
+func (h *webhookHeaders) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || data[0] != '{' {
+		return json.Unmarshal(data, (*[]webhookHeader)(h))
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal(data, &headers); err != nil {
+		return err
+	}
+	*h = make(webhookHeaders, 0, len(headers))
+	for key, value := range headers {
+		*h = append(*h, webhookHeader{Key: key, Value: value})
+	}
+	return nil
+}
+
+// newWebhookEvent converts the acknowledged record into an event. The file
+// sink writes the same events as lines of its NDJSON files.
+func newWebhookEvent(r *kgo.Record) webhookEvent {
+	event := webhookEvent{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: r.Timestamp,
+		Key:       string(r.Key),
+		Tombstone: r.Value == nil,
+	}
+	for _, header := range r.Headers {
+		event.Headers = append(event.Headers, webhookHeader{Key: header.Key, Value: string(header.Value)})
+	}
+	if json.Valid(r.Value) {
+		event.Value = r.Value
+	} else {
+		event.ValueBase64 = r.Value
+	}
+	return event
+}
+
+// newWebhookSink creates the webhook sink and, if it is enabled, starts
+// sending batches in the background until shutdown is called.
+func newWebhookSink(cfg config.Webhook, logger *zap.Logger) *webhookSink {
+	w := &webhookSink{
+		cfg:     cfg,
+		logger:  logger,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		events:  make(chan webhookEvent, cfg.BufferSize),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if !cfg.Enabled {
+		close(w.stopped)
+		return w
+	}
+
+	go w.run()
+
+	return w
+}
+
+// OnProduceRecordUnbuffered buffers the record if it has been produced
+// successfully. It is called once the record has been acknowledged, so the
+// event carries the record's partition and offset.
+func (w *webhookSink) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	if !w.cfg.Enabled || err != nil {
+		return
+	}
+	if deferUntilCommitted(r, func() { w.buffer(r) }) {
+		return
+	}
+	w.buffer(r)
+}
+
+// buffer adds the event of the record to the buffer, unless it is full.
+func (w *webhookSink) buffer(r *kgo.Record) {
+	select {
+	case w.events <- newWebhookEvent(r):
+	default:
+		webhookEventsTotal.With(map[string]string{"result": "dropped"}).Inc()
+	}
+}
+
+// shutdown sends the buffered events and stops the sink. Events of records
+// that are acknowledged afterwards are not sent anymore.
+func (w *webhookSink) shutdown(ctx context.Context) error {
+	if w.cfg.Enabled {
+		close(w.quit)
+	}
+	select {
+	case <-w.stopped:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for webhook sink to send buffered events: %w", ctx.Err())
+	}
+
+	return nil
+}
+
+func (w *webhookSink) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]webhookEvent, 0, w.cfg.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			w.send(batch)
+			batch = batch[:0]
+		}
+	}
+	add := func(event webhookEvent) {
+		batch = append(batch, event)
+		if len(batch) >= w.cfg.BatchSize {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case event := <-w.events:
+			add(event)
+		case <-ticker.C:
+			flush()
+		case <-w.quit:
+			for {
+				select {
+				case event := <-w.events:
+					add(event)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send POSTs the batch and retries it up to the configured max retries.
+func (w *webhookSink) send(batch []webhookEvent) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		w.logger.Warn("failed to serialize webhook batch", zap.Error(err))
+		webhookEventsTotal.With(map[string]string{"result": "failed"}).Add(float64(len(batch)))
+		return
+	}
+
+	backoff := w.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.post(body)
+		if err == nil {
+			webhookEventsTotal.With(map[string]string{"result": "delivered"}).Add(float64(len(batch)))
+			return
+		}
+		if !retryable || attempt >= w.cfg.MaxRetries {
+			w.logger.Warn("failed to send webhook batch",
+				zap.Int("events", len(batch)),
+				zap.Int("attempts", attempt+1),
+				zap.Error(err))
+			webhookEventsTotal.With(map[string]string{"result": "failed"}).Add(float64(len(batch)))
+			return
+		}
+		w.logger.Debug("retrying webhook batch", zap.Duration("backoff", backoff), zap.Error(err))
+		// The batch is not retried anymore once the sink is shut down
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-w.quit:
+			timer.Stop()
+			w.logger.Warn("failed to send webhook batch before shutdown",
+				zap.Int("events", len(batch)),
+				zap.Int("attempts", attempt+1),
+				zap.Error(err))
+			webhookEventsTotal.With(map[string]string{"result": "failed"}).Add(float64(len(batch)))
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the serialized batch once. It returns whether the request may
+// succeed if it is retried.
+func (w *webhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send request: %w", err)
+	}
+	// Drain the body, so that the connection can be reused
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with status %v", res.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook responded with status %v", res.StatusCode)
+	}
+}
@@ -0,0 +1,76 @@
+package shop
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// workerPool runs the submitted funcs on a fixed number of goroutines, which
+// are started on the first submission. Funcs wait in a bounded queue for an
+// idle worker. Once the queue is full, submissions are delayed or dropped as
+// configured, which is tracked by the page impression metrics.
+type workerPool struct {
+	cfg  config.Workers
+	work chan func()
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	stopped   sync.WaitGroup
+}
+
+func newWorkerPool(cfg config.Workers) *workerPool {
+	return &workerPool{
+		cfg:  cfg,
+		work: make(chan func(), cfg.QueueSize),
+	}
+}
+
+// submit queues the given func and returns false if it has been dropped,
+// because the queue was full. It must not be called once the pool has been
+// closed.
+func (p *workerPool) submit(fn func()) bool {
+	p.startOnce.Do(p.start)
+
+	select {
+	case p.work <- fn:
+		pageImpressionsQueued.Inc()
+		return true
+	default:
+	}
+
+	if p.cfg.Overflow == config.WorkersOverflowDrop {
+		pageImpressionsDropped.Inc()
+		return false
+	}
+
+	delayedAt := time.Now()
+	p.work <- fn
+	pageImpressionsQueued.Inc()
+	pageImpressionsDelayed.Inc()
+	pageImpressionsDelaySeconds.Add(time.Since(delayedAt).Seconds())
+	return true
+}
+
+func (p *workerPool) start() {
+	p.stopped.Add(p.cfg.Count)
+	for i := 0; i < p.cfg.Count; i++ {
+		go func() {
+			defer p.stopped.Done()
+			for fn := range p.work {
+				pageImpressionsQueued.Dec()
+				pageImpressionsInProgress.Inc()
+				fn()
+				pageImpressionsInProgress.Dec()
+			}
+		}()
+	}
+}
+
+// close stops the workers once they have completed all queued funcs and waits
+// for them.
+func (p *workerPool) close() {
+	p.closeOnce.Do(func() { close(p.work) })
+	p.stopped.Wait()
+}
@@ -2,13 +2,19 @@ package sr
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/twmb/franz-go/pkg/sr"
 	"go.uber.org/zap"
 
 	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/oauth"
 )
 
+// requestTimeout is the max duration of a single schema registry request.
+const requestTimeout = 5 * time.Second
+
 // Factory allows requesters to create new preconfigured Schema Registry clients
 // based on the given configuration.
 type Factory struct {
@@ -39,13 +45,26 @@ func (s *Factory) NewSchemaRegistryClient(additionalOpts ...sr.Opt) (*sr.Client,
 		opts = append(opts, sr.BasicAuth(s.Config.BasicAuth.Username, s.Config.BasicAuth.Password))
 	}
 
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	if s.Config.TLS.Enabled {
 		tlsCfg, err := s.Config.TLS.TLSConfig()
 		if err != nil {
 			return nil, fmt.Errorf("failed to load tls config: %w", err)
 		}
-		opts = append(opts, sr.DialTLSConfig(tlsCfg))
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if s.Config.BearerAuth.IsConfigured() {
+		roundTripper = &oauth.Transport{
+			Source: oauth.NewTokenSource(s.Config.BearerAuth),
+			Base:   transport,
+		}
 	}
+	opts = append(opts, sr.HTTPClient(&http.Client{
+		Timeout:   requestTimeout,
+		Transport: roundTripper,
+	}))
 
 	opts = append(opts, additionalOpts...)
 
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// azureVersion is the version of the Blob service REST API.
+const azureVersion = "2021-08-06"
+
+// azureBucket is a container of Azure Blob Storage. Requests are authorized
+// with the shared access signature, which is appended to each request's
+// query.
+type azureBucket struct {
+	endpoint   string
+	container  string
+	sasQuery   url.Values
+	httpClient *http.Client
+}
+
+// azureListResponse is the relevant part of the response of the List Blobs
+// operation.
+type azureListResponse struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func newAzureBucket(cfg config.ObjectStorageAzure, container string, httpClient *http.Client) (*azureBucket, error) {
+	account := cfg.Account
+	if account == "" {
+		account = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+	sasToken := cfg.SASToken
+	if sasToken == "" {
+		sasToken = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+	if sasToken == "" {
+		return nil, fmt.Errorf("no azure sas token configured or found in the environment")
+	}
+	sasQuery, err := url.ParseQuery(strings.TrimPrefix(sasToken, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse azure sas token: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		if account == "" {
+			return nil, fmt.Errorf("no azure storage account configured or found in the environment")
+		}
+		endpoint = fmt.Sprintf("https://%v.blob.core.windows.net", account)
+	}
+
+	return &azureBucket{
+		endpoint:   endpoint,
+		container:  container,
+		sasQuery:   sasQuery,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (b *azureBucket) Put(ctx context.Context, name string, body io.Reader, size int64) error {
+	req, err := b.newRequest(ctx, http.MethodPut, name, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+
+	res, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put blob: %w", err)
+	}
+	if err := checkResponse(res, "put blob"); err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (b *azureBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := b.newRequest(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+	if err := checkResponse(res, "get blob"); err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func (b *azureBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{}
+		query.Set("restype", "container")
+		query.Set("comp", "list")
+		query.Set("prefix", prefix)
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := b.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := b.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+		if err := checkResponse(res, "list blobs"); err != nil {
+			return nil, err
+		}
+		var response azureListResponse
+		err = xml.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list blobs response: %w", err)
+		}
+
+		for _, blob := range response.Blobs {
+			names = append(names, blob.Name)
+		}
+		if response.NextMarker == "" {
+			return names, nil
+		}
+		marker = response.NextMarker
+	}
+}
+
+// newRequest creates the request for the given blob, or the container if the
+// name is empty, including the shared access signature.
+func (b *azureBucket) newRequest(ctx context.Context, method string, name string, query url.Values, body io.Reader) (*http.Request, error) {
+	endpoint, err := url.Parse(b.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse azure endpoint: %w", err)
+	}
+	u := endpoint.JoinPath(b.container, name)
+
+	if query == nil {
+		query = url.Values{}
+	}
+	for key, values := range b.sasQuery {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create azure request: %w", err)
+	}
+	req.Header.Set("X-Ms-Version", azureVersion)
+
+	return req, nil
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+	"github.com/cloudhut/owl-shop/pkg/oauth"
+)
+
+// gcsMetadataTokenURL is the endpoint of the GCE metadata server that issues
+// access tokens for the default service account.
+const gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcsBucket is a bucket of Google Cloud Storage, which is accessed via the
+// JSON API. Access tokens that are issued by the metadata server are cached
+// until shortly before they expire.
+type gcsBucket struct {
+	cfg        config.ObjectStorageGCS
+	bucket     string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// gcsListResponse is the relevant part of the response of the objects list
+// method.
+type gcsListResponse struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func newGCSBucket(cfg config.ObjectStorageGCS, bucket string, httpClient *http.Client) *gcsBucket {
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &gcsBucket{
+		cfg:        cfg,
+		bucket:     bucket,
+		httpClient: httpClient,
+	}
+}
+
+func (b *gcsBucket) Put(ctx context.Context, name string, body io.Reader, size int64) error {
+	query := url.Values{}
+	query.Set("uploadType", "media")
+	query.Set("name", name)
+	u := fmt.Sprintf("%v/upload/storage/v1/b/%v/o?%v", b.cfg.Endpoint, url.PathEscape(b.bucket), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
+	if err != nil {
+		return fmt.Errorf("failed to create gcs request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	res, err := b.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	if err := checkResponse(res, "put object"); err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (b *gcsBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	u := fmt.Sprintf("%v/storage/v1/b/%v/o/%v?alt=media", b.cfg.Endpoint, url.PathEscape(b.bucket), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcs request: %w", err)
+	}
+
+	res, err := b.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	if err := checkResponse(res, "get object"); err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func (b *gcsBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("prefix", prefix)
+		query.Set("fields", "items(name),nextPageToken")
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		u := fmt.Sprintf("%v/storage/v1/b/%v/o?%v", b.cfg.Endpoint, url.PathEscape(b.bucket), query.Encode())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gcs request: %w", err)
+		}
+
+		res, err := b.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if err := checkResponse(res, "list objects"); err != nil {
+			return nil, err
+		}
+		var response gcsListResponse
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list objects response: %w", err)
+		}
+
+		for _, item := range response.Items {
+			names = append(names, item.Name)
+		}
+		if response.NextPageToken == "" {
+			return names, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+// do authenticates and sends the request.
+func (b *gcsBucket) do(req *http.Request) (*http.Response, error) {
+	token, err := b.accessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return b.httpClient.Do(req)
+}
+
+// accessToken returns the configured access token, the one of the environment
+// or the cached token that has been issued by the metadata server.
+func (b *gcsBucket) accessToken(ctx context.Context) (string, error) {
+	if b.cfg.AccessToken != "" {
+		return b.cfg.AccessToken, nil
+	}
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.token != "" && time.Now().Before(b.expiresAt) {
+		return b.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	res, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token from metadata server: %w", err)
+	}
+	if err := checkResponse(res, "request access token from metadata server"); err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode metadata server response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("metadata server returned no access token")
+	}
+
+	b.token = token.AccessToken
+	b.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - oauth.TokenExpiryMargin)
+
+	return b.token, nil
+}
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// s3Bucket is a bucket of Amazon S3 or an S3 compatible storage. Requests are
+// signed with AWS Signature Version 4. Payloads are not signed, so that
+// uploads can be streamed.
+type s3Bucket struct {
+	cfg        config.ObjectStorageS3
+	bucket     string
+	region     string
+	httpClient *http.Client
+}
+
+// listBucketResult is the relevant part of the response of the
+// ListObjectsV2 action.
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func newS3Bucket(cfg config.ObjectStorageS3, bucket string, httpClient *http.Client) *s3Bucket {
+	region := cfg.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &s3Bucket{
+		cfg:        cfg,
+		bucket:     bucket,
+		region:     region,
+		httpClient: httpClient,
+	}
+}
+
+func (b *s3Bucket) Put(ctx context.Context, name string, body io.Reader, size int64) error {
+	req, err := b.newRequest(ctx, http.MethodPut, name, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	res, err := b.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	if err := checkResponse(res, "put object"); err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (b *s3Bucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := b.newRequest(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	if err := checkResponse(res, "get object"); err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func (b *s3Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := b.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := b.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if err := checkResponse(res, "list objects"); err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list objects response: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			names = append(names, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return names, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// newRequest creates the request for the given object, or the bucket if the
+// name is empty. Buckets are addressed by host on Amazon S3 and by path on
+// custom endpoints. The path is sent as it is encoded for the signature.
+func (b *s3Bucket) newRequest(ctx context.Context, method string, name string, query url.Values, body io.Reader) (*http.Request, error) {
+	var u *url.URL
+	if b.cfg.Endpoint != "" {
+		endpoint, err := url.Parse(b.cfg.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse s3 endpoint: %w", err)
+		}
+		u = endpoint.JoinPath(b.bucket, name)
+	} else {
+		u = &url.URL{
+			Scheme: "https",
+			Host:   fmt.Sprintf("%v.s3.%v.amazonaws.com", b.bucket, b.region),
+			Path:   "/" + name,
+		}
+	}
+	u.RawPath = canonicalPath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 request: %w", err)
+	}
+
+	return req, nil
+}
+
+// do signs and sends the request.
+func (b *s3Bucket) do(req *http.Request) (*http.Response, error) {
+	accessKey, secretKey, sessionToken := b.cfg.AccessKey, b.cfg.SecretKey, b.cfg.SessionToken
+	if accessKey == "" {
+		accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if accessKey == "" {
+		return nil, fmt.Errorf("no aws credentials configured or found in the environment")
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+	b.sign(req, accessKey, secretKey, time.Now())
+
+	return b.httpClient.Do(req)
+}
+
+// sign sets the X-Amz-Date and Authorization headers of the request. All
+// other x-amz-* headers must have been set beforehand, including the payload
+// hash.
+func (b *s3Bucket) sign(req *http.Request, accessKey string, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key := range req.Header {
+		if lower := strings.ToLower(key); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(key))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalPath encodes the path as required by Signature Version 4 for S3,
+// i.e. each byte except the unreserved characters and slashes is percent
+// encoded. Unlike url.PathEscape, this also encodes characters such as '+',
+// '=' and ':'.
+func canonicalPath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// canonicalQuery encodes the query sorted by key, with spaces encoded as %20
+// as required by Signature Version 4.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
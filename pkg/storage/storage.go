@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/owl-shop/pkg/config"
+)
+
+// Bucket is a bucket or container of an object storage. Object names are
+// relative to the bucket.
+type Bucket interface {
+	// Put uploads the object with the given name, overwriting an existing
+	// object. Size is the number of bytes of the body.
+	Put(ctx context.Context, name string, body io.Reader, size int64) error
+	// Get downloads the object with the given name. The caller must close
+	// the returned body.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of all objects that start with the given
+	// prefix, in lexicographical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// IsURL returns whether the given path is the URL of an object storage
+// rather than a local path.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "gs://") || strings.HasPrefix(path, "azblob://")
+}
+
+// Open returns the bucket of the given s3://, gs:// or azblob:// URL and the
+// object name or prefix within the bucket, which is the URL's path without
+// the leading slash.
+func Open(cfg config.ObjectStorage, rawURL string) (Bucket, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse url: %w", err)
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("url '%v' has no bucket", rawURL)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+
+	httpClient := &http.Client{Timeout: 5 * time.Minute}
+	switch u.Scheme {
+	case "s3":
+		return newS3Bucket(cfg.S3, u.Host, httpClient), key, nil
+	case "gs":
+		return newGCSBucket(cfg.GCS, u.Host, httpClient), key, nil
+	case "azblob":
+		bucket, err := newAzureBucket(cfg.Azure, u.Host, httpClient)
+		if err != nil {
+			return nil, "", err
+		}
+		return bucket, key, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported object storage scheme '%v', must be s3, gs or azblob", u.Scheme)
+	}
+}
+
+// checkResponse returns an error including the body of the response if its
+// status code is not 2xx. The body is closed in that case.
+func checkResponse(res *http.Response, operation string) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	return fmt.Errorf("failed to %v: storage returned status code %d: %s", operation, res.StatusCode, body)
+}
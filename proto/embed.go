@@ -1,5 +1,11 @@
+// Package proto embeds the protobuf schemas, so that they can be registered in
+// the schema registry. The Go types in pkg/protogen are generated from the same
+// files via buf, run "task proto:generate" or "go generate ./proto" after
+// changing a schema. "task proto:check" fails if they are out of date.
 package proto
 
+//go:generate sh -c "cd .. && buf generate --include-imports --exclude-path proto/shop/v1/customer_v1.proto"
+
 import _ "embed"
 
 var (
@@ -11,4 +17,20 @@ var (
 	CustomerV2 string
 	//go:embed shop/v1/order.proto
 	Order string
+	//go:embed shop/v1/order_line_item.proto
+	OrderLineItem string
+	//go:embed shop/v1/frontend_event.proto
+	FrontendEvent string
+	//go:embed shop/v1/product.proto
+	Product string
+	//go:embed shop/v1/inventory_event.proto
+	InventoryEvent string
+	//go:embed shop/v1/payment_event.proto
+	PaymentEvent string
+	//go:embed shop/v1/shipment_event.proto
+	ShipmentEvent string
+	//go:embed shop/v1/review.proto
+	Review string
+	//go:embed shop/v1/cart_event.proto
+	CartEvent string
 )